@@ -18,6 +18,7 @@ import (
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -25,6 +26,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/store"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -75,6 +77,7 @@ func main() {
 	var iflowLogin bool
 	var iflowCookie bool
 	var noBrowser bool
+	var deviceLogin bool
 	var oauthCallbackPort int
 	var antigravityLogin bool
 	var kimiLogin bool
@@ -86,6 +89,8 @@ func main() {
 	var githubCopilotLogin bool
 	var projectID string
 	var vertexImport string
+	var migrateAuthEncryption bool
+	var validateConfig bool
 	var configPath string
 	var password string
 	var noIncognito bool
@@ -99,6 +104,7 @@ func main() {
 	flag.BoolVar(&iflowLogin, "iflow-login", false, "Login to iFlow using OAuth")
 	flag.BoolVar(&iflowCookie, "iflow-cookie", false, "Login to iFlow using Cookie")
 	flag.BoolVar(&noBrowser, "no-browser", false, "Don't open browser automatically for OAuth")
+	flag.BoolVar(&deviceLogin, "device", false, "Use the OAuth device code flow for --login on headless servers")
 	flag.IntVar(&oauthCallbackPort, "oauth-callback-port", 0, "Override OAuth callback port (defaults to provider-specific port)")
 	flag.BoolVar(&useIncognito, "incognito", false, "Open browser in incognito/private mode for OAuth (useful for multiple accounts)")
 	flag.BoolVar(&noIncognito, "no-incognito", false, "Force disable incognito mode (uses existing browser session)")
@@ -113,6 +119,8 @@ func main() {
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
+	flag.BoolVar(&migrateAuthEncryption, "migrate-auth-encryption", false, "Encrypt all plaintext auth files with the configured auth encryption key")
+	flag.BoolVar(&validateConfig, "validate-config", false, "Validate the config file and exit; exits nonzero when it is invalid")
 	flag.StringVar(&password, "password", "", "")
 
 	flag.CommandLine.Usage = func() {
@@ -408,6 +416,14 @@ func main() {
 		configFilePath = filepath.Join(wd, "config.yaml")
 		cfg, err = config.LoadConfigOptional(configFilePath, isCloudDeploy)
 	}
+	if validateConfig {
+		if _, errValidate := config.ValidateConfigFile(configFilePath); errValidate != nil {
+			fmt.Fprintf(os.Stderr, "invalid config %s:\n%v\n", configFilePath, errValidate)
+			os.Exit(1)
+		}
+		fmt.Printf("config %s is valid\n", configFilePath)
+		return
+	}
 	if err != nil {
 		log.Errorf("failed to load config: %v", err)
 		return
@@ -437,7 +453,23 @@ func main() {
 		}
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
+	if errDist := usage.ApplyCacheDistributionConfig(cfg); errDist != nil {
+		var errSetting *usage.DistributionError
+		if errors.As(errDist, &errSetting) {
+			log.Errorf("invalid cache-distribution %s, keeping the previous distribution: %v", errSetting.Field, errDist)
+		} else {
+			log.Errorf("failed to apply cache distribution config: %v", errDist)
+		}
+	}
+	usage.ApplyPricingConfig(cfg)
+	if errBudgets := thinking.SetLevelBudgets(cfg.Reasoning.EffortBudgets); errBudgets != nil {
+		log.Errorf("failed to apply reasoning effort budgets: %v", errBudgets)
+	}
+	if errUsage := usage.ConfigurePersistence(cfg); errUsage != nil {
+		log.Errorf("failed to configure usage persistence: %v", errUsage)
+	}
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	coreauth.SetQuotaBackoff(cfg.QuotaExceeded.QuotaBackoff())
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
 		log.Errorf("failed to configure log output: %v", err)
@@ -457,10 +489,23 @@ func main() {
 	}
 	managementasset.SetCurrentConfig(cfg)
 
+	encryptionKey, errKey := authcrypt.ResolveKey(cfg.AuthEncryption.Key, cfg.AuthEncryption.KeyFile)
+	if errKey == nil {
+		errKey = authcrypt.SetKey(encryptionKey)
+	}
+	if errKey != nil {
+		log.Errorf("failed to configure auth encryption: %v", errKey)
+		return
+	}
+	if usePostgresStore && authcrypt.Enabled() {
+		log.Warn("auth encryption only covers the local auth spool; the Postgres auth table still stores plaintext JSON")
+	}
+
 	// Create login options to be used in authentication flows.
 	options := &cmd.LoginOptions{
 		NoBrowser:    noBrowser,
 		CallbackPort: oauthCallbackPort,
+		Device:       deviceLogin,
 	}
 
 	// Register the shared token store once so all components use the same persistence backend.
@@ -482,6 +527,8 @@ func main() {
 	if vertexImport != "" {
 		// Handle Vertex service account import
 		cmd.DoVertexImport(cfg, vertexImport)
+	} else if migrateAuthEncryption {
+		cmd.DoMigrateAuthEncryption(cfg)
 	} else if login {
 		// Handle Google/Gemini login
 		cmd.DoLogin(cfg, projectID, options)
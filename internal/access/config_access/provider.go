@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -17,20 +18,20 @@ func Register(cfg *sdkconfig.SDKConfig) {
 	}
 
 	keys := normalizeKeys(cfg.APIKeys)
-	if len(keys) == 0 {
+	if len(keys) == 0 && len(cfg.ClientKeys) == 0 {
 		sdkaccess.UnregisterProvider(sdkaccess.AccessProviderTypeConfigAPIKey)
 		return
 	}
 
-	sdkaccess.RegisterProvider(
-		sdkaccess.AccessProviderTypeConfigAPIKey,
-		newProvider(sdkaccess.DefaultAccessProviderName, keys),
-	)
+	p := newProvider(sdkaccess.DefaultAccessProviderName, keys)
+	p.clientKeys = append([]sdkconfig.ClientKey(nil), cfg.ClientKeys...)
+	sdkaccess.RegisterProvider(sdkaccess.AccessProviderTypeConfigAPIKey, p)
 }
 
 type provider struct {
-	name string
-	keys map[string]struct{}
+	name       string
+	keys       map[string]struct{}
+	clientKeys []sdkconfig.ClientKey
 }
 
 func newProvider(name string, keys []string) *provider {
@@ -56,7 +57,7 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 	if p == nil {
 		return nil, sdkaccess.NewNotHandledError()
 	}
-	if len(p.keys) == 0 {
+	if len(p.keys) == 0 && len(p.clientKeys) == 0 {
 		return nil, sdkaccess.NewNotHandledError()
 	}
 	authHeader := r.Header.Get("Authorization")
@@ -98,11 +99,32 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 				},
 			}, nil
 		}
+		if key, ok := p.clientKey(candidate.value); ok {
+			return &sdkaccess.Result{
+				Provider:  p.Identifier(),
+				Principal: candidate.value,
+				Metadata: map[string]string{
+					"source":                       candidate.source,
+					sdkaccess.ClientKeyMetadataKey: key.Name,
+				},
+			}, nil
+		}
 	}
 
 	return nil, sdkaccess.NewInvalidCredentialError()
 }
 
+// clientKey returns the unexpired client key whose secret is secret.
+func (p *provider) clientKey(secret string) (sdkconfig.ClientKey, bool) {
+	now := time.Now()
+	for _, key := range p.clientKeys {
+		if key.Matches(secret) && !key.Expired(now) {
+			return key, true
+		}
+	}
+	return sdkconfig.ClientKey{}, false
+}
+
 func extractBearerToken(header string) string {
 	if header == "" {
 		return ""
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
@@ -32,6 +33,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kimi"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/qwen"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
@@ -345,7 +347,7 @@ func (h *Handler) listAuthFilesFromDisk(c *gin.Context) {
 
 			// Read file to get type field
 			full := filepath.Join(h.cfg.AuthDir, name)
-			if data, errRead := os.ReadFile(full); errRead == nil {
+			if data, errRead := authcrypt.ReadFile(full); errRead == nil {
 				typeValue := gjson.GetBytes(data, "type").String()
 				emailValue := gjson.GetBytes(data, "email").String()
 				fileData["type"] = typeValue
@@ -411,6 +413,16 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if !auth.LastRefreshedAt.IsZero() {
 		entry["last_refresh"] = auth.LastRefreshedAt
 	}
+	if expiry, ok := auth.ExpirationTime(); ok {
+		entry["expires_at"] = expiry
+	}
+	if auth.RefreshFailures > 0 {
+		entry["refresh_failures"] = auth.RefreshFailures
+		entry["next_refresh_after"] = auth.NextRefreshAfter
+		if auth.RefreshError != nil {
+			entry["refresh_error"] = auth.RefreshError.Message
+		}
+	}
 	if path != "" {
 		entry["path"] = path
 		entry["source"] = "file"
@@ -430,9 +442,49 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if claims := extractCodexIDTokenClaims(auth); claims != nil {
 		entry["id_token"] = claims
 	}
+	now := time.Now()
+	if auth.NextRetryAfter.After(now) {
+		entry["next_retry_after"] = auth.NextRetryAfter
+	}
+	if auth.Quota.Exceeded {
+		entry["quota"] = auth.Quota
+	}
+	if parked := parkedModelEntries(auth, now); len(parked) > 0 {
+		entry["parked_models"] = parked
+	}
 	return entry
 }
 
+// parkedModelEntries lists the models of auth that are cooling down, sorted by model name.
+// Models whose retry time has passed are omitted; they are re-probed on the next request.
+func parkedModelEntries(auth *coreauth.Auth, now time.Time) []gin.H {
+	if auth == nil || len(auth.ModelStates) == 0 {
+		return nil
+	}
+	models := make([]string, 0, len(auth.ModelStates))
+	for model, state := range auth.ModelStates {
+		if state != nil && state.Unavailable && state.NextRetryAfter.After(now) {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+	out := make([]gin.H, 0, len(models))
+	for _, model := range models {
+		state := auth.ModelStates[model]
+		parked := gin.H{
+			"model":            model,
+			"status":           state.Status,
+			"status_message":   state.StatusMessage,
+			"next_retry_after": state.NextRetryAfter,
+		}
+		if state.Quota.Exceeded {
+			parked["quota"] = state.Quota
+		}
+		out = append(out, parked)
+	}
+	return out
+}
+
 func extractCodexIDTokenClaims(auth *coreauth.Auth) gin.H {
 	if auth == nil || auth.Metadata == nil {
 		return nil
@@ -519,7 +571,7 @@ func (h *Handler) DownloadAuthFile(c *gin.Context) {
 		return
 	}
 	full := filepath.Join(h.cfg.AuthDir, name)
-	data, err := os.ReadFile(full)
+	data, err := authcrypt.ReadFile(full)
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
@@ -551,13 +603,13 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 				dst = abs
 			}
 		}
-		if errSave := c.SaveUploadedFile(file, dst); errSave != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to save file: %v", errSave)})
+		data, errRead := readUploadedFile(file)
+		if errRead != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("failed to read uploaded file: %v", errRead)})
 			return
 		}
-		data, errRead := os.ReadFile(dst)
-		if errRead != nil {
-			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read saved file: %v", errRead)})
+		if errSave := authcrypt.WriteFile(dst, data, 0o600); errSave != nil {
+			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to save file: %v", errSave)})
 			return
 		}
 		if errReg := h.registerAuthFromFile(ctx, dst, data); errReg != nil {
@@ -587,7 +639,7 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			dst = abs
 		}
 	}
-	if errWrite := os.WriteFile(dst, data, 0o600); errWrite != nil {
+	if errWrite := authcrypt.WriteFile(dst, data, 0o600); errWrite != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to write file: %v", errWrite)})
 		return
 	}
@@ -598,6 +650,17 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
+// readUploadedFile returns the contents of a multipart upload, so it can be saved
+// through authcrypt like every other auth file write.
+func readUploadedFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = src.Close() }()
+	return io.ReadAll(src)
+}
+
 // Delete auth files: single by name or all
 func (h *Handler) DeleteAuthFile(c *gin.Context) {
 	if h.authManager == nil {
@@ -692,14 +755,34 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 	}
 	if data == nil {
 		var err error
-		data, err = os.ReadFile(path)
+		data, err = authcrypt.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("failed to read auth file: %w", err)
 		}
 	}
+	auth, err := h.authFromFileData(path, data)
+	if err != nil {
+		return err
+	}
+	if existing, ok := h.authManager.GetByID(auth.ID); ok {
+		auth.CreatedAt = existing.CreatedAt
+		if auth.LastRefreshedAt.IsZero() {
+			auth.LastRefreshedAt = existing.LastRefreshedAt
+		}
+		auth.NextRefreshAfter = existing.NextRefreshAfter
+		auth.Runtime = existing.Runtime
+		_, err = h.authManager.Update(ctx, auth)
+		return err
+	}
+	_, err = h.authManager.Register(ctx, auth)
+	return err
+}
+
+// authFromFileData builds the core auth for the auth file JSON data stored at path.
+func (h *Handler) authFromFileData(path string, data []byte) (*coreauth.Auth, error) {
 	metadata := make(map[string]any)
 	if err := json.Unmarshal(data, &metadata); err != nil {
-		return fmt.Errorf("invalid auth file: %w", err)
+		return nil, fmt.Errorf("invalid auth file: %w", err)
 	}
 	provider, _ := metadata["type"].(string)
 	if provider == "" {
@@ -733,18 +816,7 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 	if hasLastRefresh {
 		auth.LastRefreshedAt = lastRefresh
 	}
-	if existing, ok := h.authManager.GetByID(authID); ok {
-		auth.CreatedAt = existing.CreatedAt
-		if !hasLastRefresh {
-			auth.LastRefreshedAt = existing.LastRefreshedAt
-		}
-		auth.NextRefreshAfter = existing.NextRefreshAfter
-		auth.Runtime = existing.Runtime
-		_, err := h.authManager.Update(ctx, auth)
-		return err
-	}
-	_, err := h.authManager.Register(ctx, auth)
-	return err
+	return auth, nil
 }
 
 // PatchAuthFileStatus toggles the disabled state of an auth file
@@ -940,7 +1012,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 					SetOAuthSessionError(state, "Timeout waiting for OAuth callback")
 					return nil, fmt.Errorf("timeout waiting for OAuth callback")
 				}
-				data, errRead := os.ReadFile(path)
+				data, errRead := authcrypt.ReadFile(path)
 				if errRead == nil {
 					var m map[string]string
 					_ = json.Unmarshal(data, &m)
@@ -1077,7 +1149,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 				SetOAuthSessionError(state, "OAuth flow timed out")
 				return
 			}
-			if data, errR := os.ReadFile(waitFile); errR == nil {
+			if data, errR := authcrypt.ReadFile(waitFile); errR == nil {
 				var m map[string]string
 				_ = json.Unmarshal(data, &m)
 				_ = os.Remove(waitFile)
@@ -1344,7 +1416,7 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 				SetOAuthSessionError(state, "Timeout waiting for OAuth callback")
 				return
 			}
-			if data, errR := os.ReadFile(waitFile); errR == nil {
+			if data, errR := authcrypt.ReadFile(waitFile); errR == nil {
 				var m map[string]string
 				_ = json.Unmarshal(data, &m)
 				_ = os.Remove(waitFile)
@@ -1472,7 +1544,7 @@ func (h *Handler) RequestAntigravityToken(c *gin.Context) {
 				SetOAuthSessionError(state, "OAuth flow timed out")
 				return
 			}
-			if data, errReadFile := os.ReadFile(waitFile); errReadFile == nil {
+			if data, errReadFile := authcrypt.ReadFile(waitFile); errReadFile == nil {
 				var payload map[string]string
 				_ = json.Unmarshal(data, &payload)
 				_ = os.Remove(waitFile)
@@ -1760,7 +1832,7 @@ func (h *Handler) RequestIFlowToken(c *gin.Context) {
 				fmt.Println("Authentication failed: timeout waiting for callback")
 				return
 			}
-			if data, errR := os.ReadFile(waitFile); errR == nil {
+			if data, errR := authcrypt.ReadFile(waitFile); errR == nil {
 				_ = os.Remove(waitFile)
 				_ = json.Unmarshal(data, &resultMap)
 				break
@@ -2627,7 +2699,7 @@ func (h *Handler) RequestKiroToken(c *gin.Context) {
 					SetOAuthSessionError(state, "OAuth flow timed out")
 					return
 				}
-				if data, errRead := os.ReadFile(waitFile); errRead == nil {
+				if data, errRead := authcrypt.ReadFile(waitFile); errRead == nil {
 					var m map[string]string
 					_ = json.Unmarshal(data, &m)
 					_ = os.Remove(waitFile)
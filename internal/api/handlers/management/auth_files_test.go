@@ -0,0 +1,71 @@
+package management
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func assertEncryptedAuthFile(t *testing.T, path, want string) {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if !authcrypt.IsEncrypted(raw) {
+		t.Fatalf("%s was written in plaintext: %s", path, raw)
+	}
+	plain, err := authcrypt.ReadFile(path)
+	if err != nil || string(plain) != want {
+		t.Fatalf("authcrypt.ReadFile(%s) = %s, %v, want %s", path, plain, err, want)
+	}
+}
+
+func TestUploadAuthFile_EncryptsWithKey(t *testing.T) {
+	if err := authcrypt.SetKey("master-key"); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	t.Cleanup(func() { _ = authcrypt.SetKey("") })
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	h := &Handler{cfg: &config.Config{AuthDir: dir}, authManager: coreauth.NewManager(nil, nil, nil)}
+	r := gin.New()
+	r.POST("/auth-files", h.UploadAuthFile)
+
+	const body = `{"type":"codex","email":"raw@example.com"}`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth-files?name=codex-raw.json", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("raw upload = %d %s", rec.Code, rec.Body.String())
+	}
+	assertEncryptedAuthFile(t, filepath.Join(dir, "codex-raw.json"), body)
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("file", "codex-form.json")
+	_, _ = part.Write([]byte(body))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/auth-files", &form)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("multipart upload = %d %s", rec.Code, rec.Body.String())
+	}
+	assertEncryptedAuthFile(t, filepath.Join(dir, "codex-form.json"), body)
+
+	path, err := WriteOAuthCallbackFile(dir, "codex", "state-1", "code-1", "")
+	if err != nil {
+		t.Fatalf("WriteOAuthCallbackFile() error = %v", err)
+	}
+	assertEncryptedAuthFile(t, path, `{"code":"code-1","state":"state-1","error":""}`)
+}
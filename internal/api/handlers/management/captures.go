@@ -0,0 +1,62 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+const defaultCaptureListLimit = 20
+
+// GetCaptures lists the IDs of the latest capture bundles, newest first. The limit
+// query parameter sets how many are returned (default 20).
+func (h *Handler) GetCaptures(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "configuration unavailable"})
+		return
+	}
+	limit := defaultCaptureListLimit
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	ids, err := logging.ListCaptures(h.captureDirectory(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list captures: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"captures": ids})
+}
+
+// GetCapture returns the files of one capture bundle by name.
+func (h *Handler) GetCapture(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "configuration unavailable"})
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	files, err := logging.ReadCapture(h.captureDirectory(), id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "capture not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "files": files})
+}
+
+func (h *Handler) captureDirectory() string {
+	return logging.CaptureDir(h.cfg.Capture, h.logDirectory())
+}
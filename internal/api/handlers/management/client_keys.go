@@ -0,0 +1,118 @@
+package management
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// clientKeyView is a client-keys entry as listed by the management API, without its
+// secret.
+type clientKeyView struct {
+	Name             string    `json:"name"`
+	ExpiresAt        time.Time `json:"expires-at,omitzero"`
+	Expired          bool      `json:"expired"`
+	AllowedProviders []string  `json:"allowed-providers,omitempty"`
+	AllowedModels    []string  `json:"allowed-models,omitempty"`
+	RateLimit        string    `json:"rate-limit,omitempty"`
+}
+
+// GetClientKeys lists the configured client keys. Secrets are never returned.
+func (h *Handler) GetClientKeys(c *gin.Context) {
+	now := time.Now()
+	keys := make([]clientKeyView, 0, len(h.cfg.ClientKeys))
+	for _, key := range h.cfg.ClientKeys {
+		keys = append(keys, clientKeyView{
+			Name:             key.Name,
+			ExpiresAt:        key.ExpiresAt,
+			Expired:          key.Expired(now),
+			AllowedProviders: key.AllowedProviders,
+			AllowedModels:    key.AllowedModels,
+			RateLimit:        key.RateLimit,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"client-keys": keys})
+}
+
+// PostClientKey creates a client key with a generated secret. Only the secret's hash
+// is stored, so the response is the only place the secret is ever shown.
+func (h *Handler) PostClientKey(c *gin.Context) {
+	var body struct {
+		Name             string    `json:"name"`
+		ExpiresAt        time.Time `json:"expires-at"`
+		AllowedProviders []string  `json:"allowed-providers"`
+		AllowedModels    []string  `json:"allowed-models"`
+		RateLimit        string    `json:"rate-limit"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	key := config.ClientKey{
+		Name:             strings.TrimSpace(body.Name),
+		ExpiresAt:        body.ExpiresAt,
+		AllowedProviders: body.AllowedProviders,
+		AllowedModels:    body.AllowedModels,
+		RateLimit:        strings.TrimSpace(body.RateLimit),
+	}
+	if key.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if key.RateLimit != "" {
+		if _, ok := h.cfg.RateLimit.Profiles[key.RateLimit]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown rate-limit profile %q", key.RateLimit)})
+			return
+		}
+	}
+	for i, provider := range key.AllowedProviders {
+		key.AllowedProviders[i] = strings.ToLower(strings.TrimSpace(provider))
+	}
+	secret, err := generateClientKeySecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to generate key: %v", err)})
+		return
+	}
+	key.KeyHash = config.HashClientKey(secret)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if slices.ContainsFunc(h.cfg.ClientKeys, func(k config.ClientKey) bool { return k.Name == key.Name }) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("client key %q already exists", key.Name)})
+		return
+	}
+	h.cfg.ClientKeys = append(h.cfg.ClientKeys, key)
+	if err = config.SaveConfigPreserveComments(h.configFilePath, h.cfg); err != nil {
+		h.cfg.ClientKeys = h.cfg.ClientKeys[:len(h.cfg.ClientKeys)-1]
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"name": key.Name, "key": secret})
+}
+
+// DeleteClientKey revokes the client key named by the name path parameter.
+func (h *Handler) DeleteClientKey(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("name"))
+	idx := slices.IndexFunc(h.cfg.ClientKeys, func(k config.ClientKey) bool { return k.Name == name })
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+		return
+	}
+	h.cfg.ClientKeys = slices.Delete(h.cfg.ClientKeys, idx, idx+1)
+	h.persist(c)
+}
+
+func generateClientKeySecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,68 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestClientKeys_CreateListRevoke(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{cfg: &config.Config{}, configFilePath: configPath}
+	r := gin.New()
+	r.GET("/client-keys", h.GetClientKeys)
+	r.POST("/client-keys", h.PostClientKey)
+	r.DELETE("/client-keys/:name", h.DeleteClientKey)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/client-keys", `{"name":"ci-bot","allowed-providers":["Kiro"],"allowed-models":["claude-*"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body)
+	}
+	var created struct{ Name, Key string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || !strings.HasPrefix(created.Key, "sk-") {
+		t.Fatalf("create response = %s, %v", rec.Body, err)
+	}
+	if len(h.cfg.ClientKeys) != 1 || h.cfg.ClientKeys[0].Key != "" || !h.cfg.ClientKeys[0].Matches(created.Key) {
+		t.Fatalf("stored keys = %+v, want hash only", h.cfg.ClientKeys)
+	}
+	if got := h.cfg.ClientKeys[0].AllowedProviders; len(got) != 1 || got[0] != "kiro" {
+		t.Fatalf("allowed providers = %v", got)
+	}
+	saved, _ := os.ReadFile(configPath)
+	if strings.Contains(string(saved), created.Key) || !strings.Contains(string(saved), config.HashClientKey(created.Key)) {
+		t.Fatalf("saved config = %s", saved)
+	}
+
+	if rec = serve(http.MethodPost, "/client-keys", `{"name":"ci-bot"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate status = %d, want 409", rec.Code)
+	}
+	if rec = serve(http.MethodPost, "/client-keys", `{"name":"x","rate-limit":"missing"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown profile status = %d, want 400", rec.Code)
+	}
+	if rec = serve(http.MethodGet, "/client-keys", ""); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "sha256:") || !strings.Contains(rec.Body.String(), `"ci-bot"`) {
+		t.Fatalf("list = %d %s", rec.Code, rec.Body)
+	}
+
+	if rec = serve(http.MethodDelete, "/client-keys/ci-bot", ""); rec.Code != http.StatusOK || len(h.cfg.ClientKeys) != 0 {
+		t.Fatalf("revoke status = %d, keys %+v", rec.Code, h.cfg.ClientKeys)
+	}
+	if rec = serve(http.MethodDelete, "/client-keys/ci-bot", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("second revoke status = %d, want 404", rec.Code)
+	}
+}
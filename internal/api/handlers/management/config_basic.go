@@ -23,12 +23,22 @@ const (
 	latestReleaseUserAgent = "CLIProxyAPIPlus"
 )
 
+// GetConfig returns the effective config with secrets redacted: API keys, tokens,
+// secrets and client key hashes come back masked like "****abcd", and proxy URL
+// passwords as "****". A client that GETs the config, edits it and PATCHes a whole
+// list such as api-keys back therefore writes the redacted values into the config;
+// patch only the fields being changed instead.
 func (h *Handler) GetConfig(c *gin.Context) {
 	if h == nil || h.cfg == nil {
 		c.JSON(200, gin.H{})
 		return
 	}
-	c.JSON(200, new(*h.cfg))
+	doc, err := redactedConfig(h.cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encode_failed", "message": err.Error()})
+		return
+	}
+	c.JSON(200, doc)
 }
 
 type releaseInfo struct {
@@ -108,6 +118,20 @@ func WriteConfig(path string, data []byte) error {
 	return f.Close()
 }
 
+// PostConfigReload re-reads and validates the config file, swapping it in only when it
+// is valid. Validation errors are returned with 422.
+func (h *Handler) PostConfigReload(c *gin.Context) {
+	if h.reloadConfig == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "reload_unavailable", "message": "config reload is not available"})
+		return
+	}
+	if err := h.reloadConfig(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
 func (h *Handler) PutConfigYAML(c *gin.Context) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -119,7 +143,7 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()})
 		return
 	}
-	// Validate config using LoadConfigOptional with optional=false to enforce parsing
+	// Validate config with the same checks the hot reload applies
 	tmpDir := filepath.Dir(h.configFilePath)
 	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
 	if err != nil {
@@ -141,7 +165,7 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 	defer func() {
 		_ = os.Remove(tempFile)
 	}()
-	_, err = config.LoadConfigOptional(tempFile, false)
+	_, err = config.ValidateConfigFile(tempFile)
 	if err != nil {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
 		return
@@ -282,10 +306,14 @@ func (h *Handler) PutForceModelPrefix(c *gin.Context) {
 func normalizeRoutingStrategy(strategy string) (string, bool) {
 	normalized := strings.ToLower(strings.TrimSpace(strategy))
 	switch normalized {
-	case "", "round-robin", "roundrobin", "rr":
+	case "", "round-robin", "round_robin", "roundrobin", "rr":
 		return "round-robin", true
-	case "fill-first", "fillfirst", "ff":
+	case "fill-first", "fill_first", "fillfirst", "ff":
 		return "fill-first", true
+	case "weighted", "weighted-round-robin", "wrr":
+		return "weighted", true
+	case "least-loaded", "least_loaded", "leastloaded", "ll":
+		return "least-loaded", true
 	default:
 		return "", false
 	}
@@ -317,6 +345,21 @@ func (h *Handler) PutRoutingStrategy(c *gin.Context) {
 	h.persist(c)
 }
 
+// GetSessionAffinity reports the session affinity settings together with the size
+// and hit rate of the live pin table.
+func (h *Handler) GetSessionAffinity(c *gin.Context) {
+	stats := h.authManager.SessionAffinityStats()
+	c.JSON(200, gin.H{
+		"enabled":     stats.Enabled,
+		"ttl-seconds": int64(stats.TTL / time.Second),
+		"max-entries": stats.MaxEntries,
+		"entries":     stats.Entries,
+		"hits":        stats.Hits,
+		"misses":      stats.Misses,
+		"hit-rate":    stats.HitRate(),
+	})
+}
+
 // Proxy URL
 func (h *Handler) GetProxyURL(c *gin.Context) { c.JSON(200, gin.H{"proxy-url": h.cfg.ProxyURL}) }
 func (h *Handler) PutProxyURL(c *gin.Context) {
@@ -0,0 +1,228 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// immutableConfigFields are the top-level config fields that only take effect on
+// restart, with the reason reported when a patch touches them.
+var immutableConfigFields = map[string]string{
+	"host":            "the listen address cannot be changed at runtime; restart the proxy",
+	"port":            "the listen port cannot be changed at runtime; restart the proxy",
+	"tls":             "TLS settings cannot be changed at runtime; restart the proxy",
+	"auth-encryption": "the auth encryption key cannot be changed at runtime; restart the proxy",
+}
+
+// PatchConfig applies a JSON merge patch (RFC 7386) to the active config. The patched
+// config goes through the same validation as a config file reload and is swapped in
+// only when valid. With persist=true it is written back to the config file and then
+// reloaded from it. Either mode answers 503 when the server has not provided the
+// callback it needs.
+func (h *Handler) PatchConfig(c *gin.Context) {
+	persist := false
+	if raw := c.Query("persist"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_persist", "message": "persist must be true or false"})
+			return
+		}
+		persist = value
+	}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	var patch map[string]any
+	if err := decoder.Decode(&patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_patch", "message": "body must be a JSON object"})
+		return
+	}
+	if fields := immutableFieldErrors(patch); len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "immutable_field", "fields": fields})
+		return
+	}
+	if !persist && h.applyConfig == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "apply_unavailable", "message": "runtime config changes are not available; use persist=true"})
+		return
+	}
+	if persist && h.reloadConfig == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "reload_unavailable", "message": "config reload is not available; the written config would not take effect"})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	current, err := yaml.Marshal(h.cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encode_failed", "message": err.Error()})
+		return
+	}
+	var doc map[string]any
+	if err = yaml.Unmarshal(current, &doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encode_failed", "message": err.Error()})
+		return
+	}
+	patched, err := yaml.Marshal(mergePatch(doc, normalizePatchNumbers(patch)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_patch", "message": err.Error()})
+		return
+	}
+	newCfg, err := validateConfigData(patched)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
+		return
+	}
+
+	if persist {
+		if err = config.SaveConfigPreserveComments(h.configFilePath, newCfg); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
+			return
+		}
+		err = h.reloadConfig()
+	} else {
+		err = h.applyConfig(newCfg)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "apply_failed", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "persisted": persist})
+}
+
+// validateConfigData runs ValidateConfigFile on data through a temporary file.
+func validateConfigData(data []byte) (*config.Config, error) {
+	tmpFile, err := os.CreateTemp("", "config-patch-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	tempPath := tmpFile.Name()
+	defer func() {
+		_ = os.Remove(tempPath)
+	}()
+	if _, err = tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return nil, err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return nil, err
+	}
+	return config.ValidateConfigFile(tempPath)
+}
+
+func immutableFieldErrors(patch map[string]any) map[string]string {
+	fields := make(map[string]string)
+	for key := range patch {
+		if reason, ok := immutableConfigFields[key]; ok {
+			fields[key] = reason
+		}
+	}
+	return fields
+}
+
+// mergePatch applies patch to target following RFC 7386: objects merge recursively,
+// null removes a member and any other value replaces it.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// normalizePatchNumbers turns JSON numbers into int64 or float64, so that they encode
+// as YAML numbers of the right kind.
+func normalizePatchNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizePatchNumbers(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = normalizePatchNumbers(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactedConfig returns the JSON form of cfg with API keys, tokens, secrets and proxy
+// credentials redacted.
+func redactedConfig(cfg *config.Config) (map[string]any, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return redactConfigValue("", doc).(map[string]any), nil
+}
+
+func redactConfigValue(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = redactConfigValue(k, item)
+		}
+		return out
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = redactConfigValue(key, item)
+		}
+		return items
+	case string:
+		switch {
+		case v == "":
+			return v
+		case isSecretKey(key) || key == "key" || key == "key-hash":
+			return redactSecret(v)
+		case strings.HasSuffix(key, "proxy-url"):
+			return redactProxyURL(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactProxyURL hides the password of a proxy URL with credentials.
+func redactProxyURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), "****")
+	}
+	return parsed.String()
+}
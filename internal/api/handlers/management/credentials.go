@@ -0,0 +1,411 @@
+package management
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// credentialTestTimeout bounds the upstream probe issued by TestCredential.
+const credentialTestTimeout = 30 * time.Second
+
+// ListCredentials returns all credentials known to the auth manager with secrets redacted.
+func (h *Handler) ListCredentials(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	auths := h.authManager.List()
+	credentials := make([]gin.H, 0, len(auths))
+	for _, auth := range auths {
+		if entry := h.buildCredentialEntry(auth); entry != nil {
+			credentials = append(credentials, entry)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		idI, _ := credentials[i]["id"].(string)
+		idJ, _ := credentials[j]["id"].(string)
+		return strings.ToLower(idI) < strings.ToLower(idJ)
+	})
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// GetCredential returns a single credential with secrets redacted.
+func (h *Handler) GetCredential(c *gin.Context) {
+	auth, ok := h.credentialFromParam(c)
+	if !ok {
+		return
+	}
+	entry := h.buildCredentialEntry(auth)
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// CreateCredential validates an auth file JSON payload, stores it through the token
+// store and registers it with the auth manager. The optional ?name= selects the file
+// name; it defaults to the provider type followed by a hash of the payload. When the
+// store cannot persist the credential (for example a read-only auth directory) it is
+// kept in memory only and the response reports persisted=false.
+func (h *Handler) CreateCredential(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	provider, errValidate := validateCredentialPayload(data)
+	if errValidate != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errValidate.Error()})
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		sum := sha256.Sum256(data)
+		name = provider + "-" + hex.EncodeToString(sum[:4])
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".json") {
+		name += ".json"
+	}
+	path := filepath.Join(h.cfg.AuthDir, name)
+	if !filepath.IsAbs(path) {
+		if abs, errAbs := filepath.Abs(path); errAbs == nil {
+			path = abs
+		}
+	}
+	auth, err := h.authFromFileData(path, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, exists := h.findCredential(auth.ID); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "credential already exists"})
+		return
+	}
+	if _, exists := h.findCredential(name); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "credential already exists"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	persisted := true
+	if _, errSave := h.saveTokenRecord(ctx, auth.Clone()); errSave != nil {
+		log.WithError(errSave).Warnf("management: credential %s kept in memory only", auth.ID)
+		persisted = false
+		auth.Attributes["runtime_only"] = "true"
+	}
+	registered, errRegister := h.authManager.Register(coreauth.WithSkipPersist(ctx), auth)
+	if errRegister != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to register credential: %v", errRegister)})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "ok", "id": registered.ID, "persisted": persisted})
+}
+
+// DeleteCredential removes a credential from the token store and stops routing to it.
+// Requests already using the credential run to completion.
+func (h *Handler) DeleteCredential(c *gin.Context) {
+	auth, ok := h.credentialFromParam(c)
+	if !ok {
+		return
+	}
+	if isConfigBackedAuth(auth) {
+		c.JSON(http.StatusConflict, gin.H{"error": "credential is defined in the config file"})
+		return
+	}
+	ctx := c.Request.Context()
+	persisted := true
+	if path := strings.TrimSpace(authAttribute(auth, "path")); path != "" && !isRuntimeOnlyAuth(auth) {
+		if err := h.deleteTokenRecord(ctx, path); err != nil {
+			log.WithError(err).Warnf("management: failed to delete stored credential %s", auth.ID)
+			persisted = false
+		}
+	}
+	auth.Disabled = true
+	auth.Status = coreauth.StatusDisabled
+	auth.StatusMessage = "removed via management API"
+	auth.UpdatedAt = time.Now()
+	if _, err := h.authManager.Update(coreauth.WithSkipPersist(ctx), auth); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update credential: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "id": auth.ID, "persisted": persisted})
+}
+
+// PatchCredential enables or disables a credential. The change applies to the next
+// selection; requests already using the credential are not interrupted.
+func (h *Handler) PatchCredential(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+	auth, ok := h.credentialFromParam(c)
+	if !ok {
+		return
+	}
+	auth.Disabled = !*req.Enabled
+	if auth.Disabled {
+		auth.Status = coreauth.StatusDisabled
+		auth.StatusMessage = "disabled via management API"
+	} else {
+		auth.Status = coreauth.StatusActive
+		auth.StatusMessage = ""
+	}
+	auth.UpdatedAt = time.Now()
+	if _, err := h.authManager.Update(c.Request.Context(), auth); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update credential: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "id": auth.ID, "enabled": *req.Enabled})
+}
+
+// TestCredential sends a one-token completion through the credential and reports
+// whether it succeeded and how long it took. The optional JSON body field "model"
+// selects the model; it defaults to the first model registered for the credential.
+func (h *Handler) TestCredential(c *gin.Context) {
+	auth, ok := h.credentialFromParam(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	model := strings.TrimSpace(req.Model)
+	if model == "" {
+		if models := registry.GetGlobalRegistry().GetModelsForClient(auth.ID); len(models) > 0 {
+			model = models[0].ID
+		}
+	}
+	if model == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "no models registered for credential; specify model"})
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]any{
+		"model":      model,
+		"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+		"max_tokens": 1,
+		"stream":     false,
+	})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), credentialTestTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := h.authManager.ExecuteOnAuth(ctx, auth.ID, cliproxyexecutor.Request{
+		Model:   model,
+		Payload: payload,
+		Format:  sdktranslator.FormatOpenAI,
+	}, cliproxyexecutor.Options{
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FormatOpenAI,
+	})
+	result := gin.H{
+		"id":         auth.ID,
+		"model":      model,
+		"success":    err == nil,
+		"latency_ms": time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result["error"] = err.Error()
+		var statusErr cliproxyexecutor.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode() > 0 {
+			result["status_code"] = statusErr.StatusCode()
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// credentialFromParam resolves the :id path parameter, writing an error response and
+// returning false when the credential cannot be found.
+func (h *Handler) credentialFromParam(c *gin.Context) (*coreauth.Auth, bool) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return nil, false
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return nil, false
+	}
+	auth, ok := h.findCredential(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+		return nil, false
+	}
+	return auth, true
+}
+
+// findCredential looks a credential up by auth ID, then by file name. Credentials
+// removed via the management API are treated as absent.
+func (h *Handler) findCredential(id string) (*coreauth.Auth, bool) {
+	auth, ok := h.authManager.GetByID(id)
+	if !ok {
+		for _, candidate := range h.authManager.List() {
+			if candidate.FileName == id {
+				auth, ok = candidate, true
+				break
+			}
+		}
+	}
+	if !ok || isRemovedCredential(auth) {
+		return nil, false
+	}
+	return auth, true
+}
+
+func isRemovedCredential(auth *coreauth.Auth) bool {
+	return auth.Disabled && strings.EqualFold(strings.TrimSpace(auth.StatusMessage), "removed via management api")
+}
+
+// isConfigBackedAuth reports whether auth was synthesized from an API key in the config file.
+func isConfigBackedAuth(auth *coreauth.Auth) bool {
+	return strings.HasPrefix(authAttribute(auth, "source"), "config:")
+}
+
+func (h *Handler) buildCredentialEntry(auth *coreauth.Auth) gin.H {
+	if auth == nil || isRemovedCredential(auth) {
+		return nil
+	}
+	entry := h.buildAuthFileEntry(auth)
+	if entry == nil {
+		if !isConfigBackedAuth(auth) {
+			return nil
+		}
+		entry = gin.H{
+			"id":       auth.ID,
+			"name":     auth.ID,
+			"type":     auth.Provider,
+			"provider": auth.Provider,
+			"label":    auth.Label,
+			"status":   auth.Status,
+			"disabled": auth.Disabled,
+			"source":   "config",
+		}
+	}
+	if len(auth.Metadata) > 0 {
+		entry["metadata"] = redactSecrets(auth.Metadata)
+	}
+	if len(auth.Attributes) > 0 {
+		attributes := make(map[string]string, len(auth.Attributes))
+		for key, value := range auth.Attributes {
+			if isSecretKey(key) {
+				value = redactSecret(value)
+			}
+			attributes[key] = value
+		}
+		entry["attributes"] = attributes
+	}
+	return entry
+}
+
+// validateCredentialPayload checks that data is a JSON object with a "type" naming
+// the provider, and returns the provider.
+func validateCredentialPayload(data []byte) (string, error) {
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil || payload == nil {
+		return "", fmt.Errorf("credential payload must be a JSON object")
+	}
+	provider, _ := payload["type"].(string)
+	provider = strings.TrimSpace(provider)
+	if provider == "" {
+		return "", fmt.Errorf("credential type is required")
+	}
+	if strings.ContainsAny(provider, `/\`+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid credential type %q", provider)
+	}
+	if email, exists := payload["email"]; exists {
+		if _, ok := email.(string); !ok {
+			return "", fmt.Errorf("credential email must be a string")
+		}
+	}
+	return provider, nil
+}
+
+// isSecretKey reports whether a metadata or attribute key names a secret value.
+func isSecretKey(key string) bool {
+	k := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	if strings.Contains(k, "type") || strings.Contains(k, "expire") {
+		return false
+	}
+	for _, marker := range []string{"token", "secret", "password", "cookie", "apikey", "privatekey", "authorization", "credential"} {
+		if strings.Contains(k, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecret keeps only the last four characters of value.
+func redactSecret(value string) string {
+	if len(value) <= 4 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+// redactSecrets returns a copy of metadata with secret string values redacted,
+// descending into nested objects and arrays.
+func redactSecrets(metadata map[string]any) map[string]any {
+	out := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		secret := isSecretKey(key)
+		out[key] = redactValue(value, secret)
+	}
+	return out
+}
+
+func redactValue(value any, secret bool) any {
+	switch v := value.(type) {
+	case string:
+		if secret {
+			return redactSecret(v)
+		}
+		return v
+	case map[string]any:
+		return redactSecrets(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item, secret)
+		}
+		return items
+	default:
+		return v
+	}
+}
@@ -0,0 +1,207 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type probeExecutor struct {
+	err   error
+	calls []string
+}
+
+func (e *probeExecutor) Identifier() string { return "probe" }
+
+func (e *probeExecutor) Execute(_ context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.calls = append(e.calls, auth.ID+":"+req.Model)
+	return cliproxyexecutor.Response{Payload: []byte("{}")}, e.err
+}
+
+func (e *probeExecutor) ExecuteStream(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *probeExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *probeExecutor) CountTokens(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *probeExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newCredentialsRouter(t *testing.T) (*gin.Engine, *Handler, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	h := &Handler{
+		cfg:         &config.Config{AuthDir: dir},
+		authManager: coreauth.NewManager(nil, nil, nil),
+		tokenStore:  sdkAuth.NewFileTokenStore(),
+	}
+	r := gin.New()
+	r.GET("/credentials", h.ListCredentials)
+	r.POST("/credentials", h.CreateCredential)
+	r.GET("/credentials/:id", h.GetCredential)
+	r.DELETE("/credentials/:id", h.DeleteCredential)
+	r.PATCH("/credentials/:id", h.PatchCredential)
+	r.POST("/credentials/:id/test", h.TestCredential)
+	return r, h, dir
+}
+
+func serveCredentials(r *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestCredentials_CreateListRedacts(t *testing.T) {
+	r, h, dir := newCredentialsRouter(t)
+
+	body := `{"type":"probe","email":"dev@example.com","access_token":"sk-live-abcdef1234","token_type":"Bearer","nested":{"refresh_token":"rt-9876"}}`
+	if rec := serveCredentials(r, http.MethodPost, "/credentials", `{"email":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("create without type status = %d, want 400", rec.Code)
+	}
+	rec := serveCredentials(r, http.MethodPost, "/credentials?name=probe-one", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "probe-one.json")); err != nil {
+		t.Fatalf("credential not stored: %v", err)
+	}
+	if _, ok := h.authManager.GetByID("probe-one.json"); !ok {
+		t.Fatal("credential not registered with the auth manager")
+	}
+	if rec := serveCredentials(r, http.MethodPost, "/credentials?name=probe-one.json", body); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate create status = %d, want 409", rec.Code)
+	}
+
+	rec = serveCredentials(r, http.MethodGet, "/credentials", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list status = %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "sk-live-abcdef1234") || strings.Contains(rec.Body.String(), "rt-9876") {
+		t.Fatalf("list leaked a secret: %s", rec.Body.String())
+	}
+	var payload struct {
+		Credentials []struct {
+			ID       string         `json:"id"`
+			Metadata map[string]any `json:"metadata"`
+		} `json:"credentials"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(payload.Credentials) != 1 {
+		t.Fatalf("credentials = %+v, want 1", payload.Credentials)
+	}
+	meta := payload.Credentials[0].Metadata
+	if meta["access_token"] != "****1234" || meta["token_type"] != "Bearer" || meta["email"] != "dev@example.com" {
+		t.Fatalf("unexpected redacted metadata: %v", meta)
+	}
+	if nested, _ := meta["nested"].(map[string]any); nested["refresh_token"] != "****9876" {
+		t.Fatalf("nested secret not redacted: %v", meta["nested"])
+	}
+}
+
+func TestCredentials_PatchAndDelete(t *testing.T) {
+	r, h, dir := newCredentialsRouter(t)
+	if rec := serveCredentials(r, http.MethodPost, "/credentials?name=a.json", `{"type":"probe","api_key":"k-1"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serveCredentials(r, http.MethodPatch, "/credentials/a.json", `{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("patch without enabled status = %d, want 400", rec.Code)
+	}
+	if rec := serveCredentials(r, http.MethodPatch, "/credentials/a.json", `{"enabled":false}`); rec.Code != http.StatusOK {
+		t.Fatalf("disable status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if auth, _ := h.authManager.GetByID("a.json"); !auth.Disabled || auth.Status != coreauth.StatusDisabled {
+		t.Fatalf("auth not disabled: %+v", auth)
+	}
+	if rec := serveCredentials(r, http.MethodPatch, "/credentials/a.json", `{"enabled":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("enable status = %d", rec.Code)
+	}
+	if auth, _ := h.authManager.GetByID("a.json"); auth.Disabled {
+		t.Fatal("auth still disabled after enable")
+	}
+
+	if rec := serveCredentials(r, http.MethodDelete, "/credentials/a.json", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); !os.IsNotExist(err) {
+		t.Fatalf("stored credential not removed: %v", err)
+	}
+	if auth, _ := h.authManager.GetByID("a.json"); !auth.Disabled {
+		t.Fatal("deleted credential is still routable")
+	}
+	if rec := serveCredentials(r, http.MethodGet, "/credentials/a.json", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get after delete status = %d, want 404", rec.Code)
+	}
+	if rec := serveCredentials(r, http.MethodDelete, "/credentials/missing.json", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("delete missing status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCredentials_Test(t *testing.T) {
+	r, h, _ := newCredentialsRouter(t)
+	executor := &probeExecutor{}
+	h.authManager.RegisterExecutor(executor)
+	if rec := serveCredentials(r, http.MethodPost, "/credentials?name=p.json", `{"type":"probe","api_key":"k-1"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serveCredentials(r, http.MethodPost, "/credentials/p.json/test", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("test without models status = %d, want 422", rec.Code)
+	}
+
+	rec := serveCredentials(r, http.MethodPost, "/credentials/p.json/test", `{"model":"probe-1"}`)
+	var result struct {
+		Success   bool   `json:"success"`
+		LatencyMS *int64 `json:"latency_ms"`
+		Error     string `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("test status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if !result.Success || result.LatencyMS == nil {
+		t.Fatalf("unexpected test result: %s", rec.Body.String())
+	}
+	if len(executor.calls) != 1 || executor.calls[0] != "p.json:probe-1" {
+		t.Fatalf("executor calls = %v", executor.calls)
+	}
+
+	executor.err = errors.New("upstream unauthorized")
+	rec = serveCredentials(r, http.MethodPost, "/credentials/p.json/test", `{"model":"probe-1"}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode test result: %v", err)
+	}
+	if result.Success || result.Error != "upstream unauthorized" {
+		t.Fatalf("failed probe result = %s", rec.Body.String())
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	cases := map[string]string{"": "****", "abc": "****", "abcd": "****", "abcdefgh": "****efgh"}
+	for in, want := range cases {
+		if got := redactSecret(in); got != want {
+			t.Fatalf("redactSecret(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -47,6 +47,8 @@ type Handler struct {
 	allowRemoteOverride bool
 	envSecret           string
 	logDir              string
+	reloadConfig        func() error
+	applyConfig         func(*config.Config) error
 }
 
 // NewHandler creates a new management handler instance.
@@ -115,6 +117,13 @@ func (h *Handler) SetUsageStatistics(stats *usage.RequestStatistics) { h.usageSt
 // SetLocalPassword configures the runtime-local password accepted for localhost requests.
 func (h *Handler) SetLocalPassword(password string) { h.localPassword = password }
 
+// SetConfigReloader sets the functions that re-read and apply the config file, for the
+// config reload endpoint, and that apply a validated config, for config patches.
+func (h *Handler) SetConfigReloader(reload func() error, apply func(*config.Config) error) {
+	h.reloadConfig = reload
+	h.applyConfig = apply
+}
+
 // SetLogDirectory updates the directory where main.log should be looked up.
 func (h *Handler) SetLogDirectory(dir string) {
 	if dir == "" {
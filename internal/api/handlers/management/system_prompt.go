@@ -0,0 +1,37 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// PostSystemPromptPreview is a dry run of the payload.system-prompt rules: it returns
+// the system prompt a request to the given model and protocol would reach the upstream
+// with when the client sent the given system prompt. Nothing is sent upstream.
+func (h *Handler) PostSystemPromptPreview(c *gin.Context) {
+	var body struct {
+		Model    string `json:"model"`
+		Protocol string `json:"protocol"`
+		System   string `json:"system"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	model := strings.TrimSpace(body.Model)
+	if model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model is required"})
+		return
+	}
+	protocol := strings.ToLower(strings.TrimSpace(body.Protocol))
+	system, matched := executor.PreviewSystemPrompt(h.cfg, model, protocol, body.System)
+	c.JSON(http.StatusOK, gin.H{
+		"model":         model,
+		"protocol":      protocol,
+		"matched-rules": matched,
+		"system":        system,
+	})
+}
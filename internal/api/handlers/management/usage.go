@@ -2,11 +2,16 @@ package management
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/shopspring/decimal"
 )
 
 type usageExportPayload struct {
@@ -20,8 +25,53 @@ type usageImportPayload struct {
 	Usage   usage.StatisticsSnapshot `json:"usage"`
 }
 
+const (
+	defaultUsageGroupLimit = 100
+	maxUsageGroupLimit     = 1000
+)
+
+// usageAggregateParams lists the query parameters that switch GET /usage to aggregate mode.
+var usageAggregateParams = []string{"group_by", "since", "limit", "offset"}
+
+type usageTotalsPayload struct {
+	Requests                 int64 `json:"requests"`
+	FailedRequests           int64 `json:"failed_requests"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	// EstimatedCostUSD is the list price from the pricing config, encoded as a decimal string.
+	EstimatedCostUSD decimal.Decimal `json:"estimated_cost_usd"`
+}
+
+type usageGroupPayload struct {
+	Day      string `json:"day,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Account  string `json:"account,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	usageTotalsPayload
+}
+
+type usageAggregatePayload struct {
+	GroupBy     []string            `json:"group_by"`
+	Since       *time.Time          `json:"since,omitempty"`
+	Totals      usageTotalsPayload  `json:"totals"`
+	TotalGroups int                 `json:"total_groups"`
+	Offset      int                 `json:"offset"`
+	Limit       int                 `json:"limit"`
+	Groups      []usageGroupPayload `json:"groups"`
+}
+
 // GetUsageStatistics returns the in-memory request statistics snapshot.
+// When any of group_by, since, limit or offset is supplied it instead returns
+// aggregates from the active usage recorder; see getUsageAggregates.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
+	for _, param := range usageAggregateParams {
+		if _, ok := c.GetQuery(param); ok {
+			h.getUsageAggregates(c)
+			return
+		}
+	}
 	var snapshot usage.StatisticsSnapshot
 	if h != nil && h.usageStats != nil {
 		snapshot = h.usageStats.Snapshot()
@@ -77,3 +127,112 @@ func (h *Handler) ImportUsageStatistics(c *gin.Context) {
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// getUsageAggregates returns token and request totals grouped by the comma-separated
+// group_by dimensions (model, account, provider or day; default account). since accepts
+// RFC3339 or YYYY-MM-DD. Groups are paginated with offset and limit (default 100, max 1000).
+func (h *Handler) getUsageAggregates(c *gin.Context) {
+	groupBy := []string{usage.GroupByAccount}
+	if raw := strings.TrimSpace(c.Query("group_by")); raw != "" {
+		groupBy = groupBy[:0]
+		for _, dim := range strings.Split(raw, ",") {
+			if dim = strings.TrimSpace(dim); dim != "" {
+				groupBy = append(groupBy, strings.ToLower(dim))
+			}
+		}
+	}
+
+	query := usage.UsageQuery{GroupBy: groupBy}
+	var since *time.Time
+	if raw := strings.TrimSpace(c.Query("since")); raw != "" {
+		ts, err := parseUsageSince(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since: expected RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		query.From = ts
+		since = &ts
+	}
+
+	limit := defaultUsageGroupLimit
+	if raw := c.Query("limit"); strings.TrimSpace(raw) != "" {
+		parsed, err := parseLimit(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %v", err)})
+			return
+		}
+		limit = min(parsed, maxUsageGroupLimit)
+	}
+	offset := 0
+	if raw := strings.TrimSpace(c.Query("offset")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset: must be a non-negative integer"})
+			return
+		}
+		offset = parsed
+	}
+
+	groups, err := usage.QueryActiveUsage(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, usage.ErrUnsupportedGroupBy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload := usageAggregatePayload{
+		GroupBy:     groupBy,
+		Since:       since,
+		TotalGroups: len(groups),
+		Offset:      offset,
+		Limit:       limit,
+		Groups:      []usageGroupPayload{},
+	}
+	for i, group := range groups {
+		totals := toUsageTotalsPayload(group.UsageTotals)
+		payload.Totals.add(totals)
+		if i < offset || i >= offset+limit {
+			continue
+		}
+		payload.Groups = append(payload.Groups, usageGroupPayload{
+			Day:                group.Day,
+			Model:              group.Model,
+			Account:            group.Account,
+			Provider:           group.Provider,
+			usageTotalsPayload: totals,
+		})
+	}
+	c.JSON(http.StatusOK, payload)
+}
+
+func parseUsageSince(raw string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+		return ts, nil
+	}
+	return time.ParseInLocation(time.DateOnly, raw, time.UTC)
+}
+
+func toUsageTotalsPayload(t usage.UsageTotals) usageTotalsPayload {
+	return usageTotalsPayload{
+		Requests:                 t.Requests,
+		FailedRequests:           t.Failures,
+		InputTokens:              t.InputTokens,
+		OutputTokens:             t.OutputTokens,
+		CacheCreationInputTokens: t.CacheCreationInputTokens,
+		CacheReadInputTokens:     t.CacheReadInputTokens,
+		EstimatedCostUSD:         t.Cost,
+	}
+}
+
+func (t *usageTotalsPayload) add(other usageTotalsPayload) {
+	t.Requests += other.Requests
+	t.FailedRequests += other.FailedRequests
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.CacheCreationInputTokens += other.CacheCreationInputTokens
+	t.CacheReadInputTokens += other.CacheReadInputTokens
+	t.EstimatedCostUSD = t.EstimatedCostUSD.Add(other.EstimatedCostUSD)
+}
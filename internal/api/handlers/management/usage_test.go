@@ -0,0 +1,120 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func serveUsageAggregates(t *testing.T, target string) (*httptest.ResponseRecorder, usageAggregatePayload) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	(&Handler{}).GetUsageStatistics(c)
+
+	var payload usageAggregatePayload
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rec, payload
+}
+
+func TestGetUsageStatistics_Aggregates(t *testing.T) {
+	recorder := usage.NewMemoryRecorder(16)
+	usage.SetRecorder(recorder)
+	t.Cleanup(func() { usage.SetRecorder(nil) })
+
+	now := time.Now().UTC()
+	ctx := context.Background()
+	recorder.Record(ctx, usage.RequestUsage{Timestamp: now.Add(-48 * time.Hour), Provider: "kiro", Model: "m1", AuthID: "old.json", Distribution: usage.CacheTokenDistribution{InputTokens: 5}})
+	recorder.Record(ctx, usage.RequestUsage{Timestamp: now, Provider: "kiro", Model: "m1", AuthID: "a.json", OutputTokens: 10, Distribution: usage.DistributeCacheTokens(1000)})
+	recorder.Record(ctx, usage.RequestUsage{Timestamp: now, Provider: "kiro", Model: "m2", AuthID: "a.json", Failed: true, Distribution: usage.CacheTokenDistribution{InputTokens: 20}})
+	recorder.Record(ctx, usage.RequestUsage{Timestamp: now, Provider: "claude", Model: "m1", AuthID: "b.json", OutputTokens: 3, Distribution: usage.CacheTokenDistribution{InputTokens: 7}})
+
+	since := now.Add(-time.Hour).Format(time.RFC3339)
+	rec, payload := serveUsageAggregates(t, "/v0/management/usage?since="+since)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if payload.TotalGroups != 2 || len(payload.Groups) != 2 {
+		t.Fatalf("expected 2 account groups, got %+v", payload)
+	}
+	a := payload.Groups[0]
+	if a.Account != "a.json" || a.Requests != 2 || a.FailedRequests != 1 || a.OutputTokens != 10 {
+		t.Fatalf("unexpected a.json group: %+v", a)
+	}
+	if a.InputTokens != 55 || a.CacheCreationInputTokens != 71 || a.CacheReadInputTokens != 894 {
+		t.Fatalf("unexpected a.json token totals: %+v", a)
+	}
+	if payload.Totals.Requests != 3 || payload.Totals.InputTokens != 62 {
+		t.Fatalf("unexpected overall totals: %+v", payload.Totals)
+	}
+
+	_, byModel := serveUsageAggregates(t, "/v0/management/usage?group_by=model&limit=1&offset=1")
+	if byModel.TotalGroups != 2 || len(byModel.Groups) != 1 || byModel.Groups[0].Model != "m2" {
+		t.Fatalf("unexpected paginated model groups: %+v", byModel)
+	}
+	if byModel.Groups[0].Account != "" {
+		t.Fatalf("ungrouped dimension should be omitted, got %+v", byModel.Groups[0])
+	}
+}
+
+func TestGetUsageStatistics_AggregateRejectsInvalidParams(t *testing.T) {
+	usage.SetRecorder(usage.NewMemoryRecorder(1))
+	t.Cleanup(func() { usage.SetRecorder(nil) })
+
+	for _, target := range []string{
+		"/v0/management/usage?group_by=secret",
+		"/v0/management/usage?since=yesterday",
+		"/v0/management/usage?limit=0",
+		"/v0/management/usage?offset=-1",
+	} {
+		if rec, _ := serveUsageAggregates(t, target); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestGetUsageStatistics_AggregatesIncludeCost(t *testing.T) {
+	recorder := usage.NewMemoryRecorder(4)
+	usage.SetRecorder(recorder)
+	usage.ApplyPricingConfig(&config.Config{Pricing: config.PricingConfig{
+		Models: []config.ModelPrice{{Model: "claude-sonnet-4*", Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}},
+	}})
+	t.Cleanup(func() {
+		usage.SetRecorder(nil)
+		usage.ApplyPricingConfig(nil)
+	})
+
+	recorder.Record(context.Background(), usage.RequestUsage{
+		Timestamp:    time.Now().UTC(),
+		Model:        "claude-sonnet-4.5",
+		AuthID:       "a.json",
+		OutputTokens: 1000,
+		Distribution: usage.CacheTokenDistribution{InputTokens: 1000, CacheCreationInputTokens: 1000, CacheReadInputTokens: 10000},
+	})
+
+	rec, payload := serveUsageAggregates(t, "/v0/management/usage?group_by=account")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	// 0.003 + 0.00375 + 0.003 + 0.015
+	want := "0.02475"
+	if got := payload.Totals.EstimatedCostUSD.String(); got != want {
+		t.Fatalf("totals cost = %s, want %s", got, want)
+	}
+	if len(payload.Groups) != 1 || payload.Groups[0].EstimatedCostUSD.String() != want {
+		t.Fatalf("unexpected group cost: %+v", payload.Groups)
+	}
+}
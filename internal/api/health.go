@@ -0,0 +1,330 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// providerReadiness is the /readyz detail of one provider.
+type providerReadiness struct {
+	Ready    bool `json:"ready"`
+	Required bool `json:"required"`
+	// Reason explains why the provider is not ready.
+	Reason      string `json:"reason,omitempty"`
+	Credentials int    `json:"credentials"`
+	Available   int    `json:"available"`
+	// LastRefresh is the latest successful token refresh of any of its credentials.
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	// RefreshError is set when every available credential's last refresh failed.
+	RefreshError string       `json:"refresh_error,omitempty"`
+	Probe        *probeResult `json:"probe,omitempty"`
+}
+
+// probeResult is the cached outcome of the latest upstream probe of a provider.
+type probeResult struct {
+	OK        bool      `json:"ok"`
+	CheckedAt time.Time `json:"checked_at"`
+	AuthID    string    `json:"auth_id,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// serveHealth answers liveness checks. It never looks at providers, so it stays cheap.
+func (s *Server) serveHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// serveReadiness reports per-provider readiness with 200 when every provider marked
+// required in health.providers is ready, and 503 otherwise.
+func (s *Server) serveReadiness(c *gin.Context) {
+	providers, ready := s.readiness(time.Now())
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "providers": providers})
+}
+
+// readiness evaluates every provider that has credentials or health settings. A
+// provider is ready when it has a non-parked credential, not all of its available
+// credentials failed their last token refresh, and its upstream probe, when enabled,
+// last succeeded.
+func (s *Server) readiness(now time.Time) (map[string]*providerReadiness, bool) {
+	var settings map[string]config.ProviderHealthConfig
+	if health := s.prober.healthConfig(); health != nil {
+		settings = health.Providers
+	}
+	providers := make(map[string]*providerReadiness)
+	entry := func(provider string) *providerReadiness {
+		p, ok := providers[provider]
+		if !ok {
+			p = &providerReadiness{Required: settings[provider].Required}
+			providers[provider] = p
+		}
+		return p
+	}
+	refreshing := make(map[string]int)
+	if manager := s.authManager(); manager != nil {
+		for _, a := range manager.List() {
+			p := entry(a.Provider)
+			p.Credentials++
+			if !a.LastRefreshedAt.IsZero() && (p.LastRefresh == nil || a.LastRefreshedAt.After(*p.LastRefresh)) {
+				refreshedAt := a.LastRefreshedAt
+				p.LastRefresh = &refreshedAt
+			}
+			if credentialState(a, now) != credentialAvailable {
+				continue
+			}
+			p.Available++
+			if a.RefreshError == nil {
+				refreshing[a.Provider]++
+			} else if p.RefreshError == "" {
+				p.RefreshError = a.RefreshError.Error()
+			}
+		}
+	}
+	for provider := range settings {
+		entry(provider)
+	}
+
+	ready := true
+	for provider, p := range providers {
+		if refreshing[provider] > 0 {
+			p.RefreshError = ""
+		}
+		switch {
+		case p.Available == 0:
+			p.Reason = "no available credential"
+		case refreshing[provider] == 0:
+			p.Reason = "token refresh failing"
+		}
+		if settings[provider].Probe {
+			if result, ok := s.prober.result(provider); ok {
+				p.Probe = &result
+				if !result.OK && p.Reason == "" {
+					p.Reason = "upstream probe failed"
+				}
+			} else if p.Reason == "" {
+				p.Reason = "upstream probe pending"
+			}
+		}
+		p.Ready = p.Reason == ""
+		if p.Required && !p.Ready {
+			ready = false
+		}
+	}
+	return providers, ready
+}
+
+func (s *Server) authManager() *auth.Manager {
+	if s.handlers == nil {
+		return nil
+	}
+	return s.handlers.AuthManager
+}
+
+// applyHealthConfig hands the health settings to the prober and starts it once any
+// provider enables probing. The prober reads its settings on every round, so later
+// reloads need no restart.
+func (s *Server) applyHealthConfig(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	s.prober.update(cfg.Health)
+	for _, health := range cfg.Health.Providers {
+		if health.Probe {
+			s.prober.start()
+			return
+		}
+	}
+}
+
+// upstreamProber periodically sends a minimal request through one available
+// credential of every provider with probing enabled and caches the outcome for
+// /readyz, so readiness checks never reach the upstream themselves. Probes bypass the
+// API middleware and carry coreusage.WithoutUsage, so they count against neither rate
+// limits nor usage records.
+type upstreamProber struct {
+	server *Server
+	// health is a snapshot of the health settings, replaced on every reload, so the
+	// prober goroutine and /readyz never read the server config while it is swapped.
+	health atomic.Pointer[config.HealthConfig]
+
+	once     sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.RWMutex
+	results map[string]probeResult
+}
+
+func newUpstreamProber(server *Server) *upstreamProber {
+	return &upstreamProber{server: server, stop: make(chan struct{})}
+}
+
+func (p *upstreamProber) start() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() { go p.run() })
+}
+
+func (p *upstreamProber) close() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// update replaces the health settings snapshot with a copy of health.
+func (p *upstreamProber) update(health config.HealthConfig) {
+	if p == nil {
+		return
+	}
+	health.Providers = maps.Clone(health.Providers)
+	p.health.Store(&health)
+}
+
+func (p *upstreamProber) healthConfig() *config.HealthConfig {
+	if p == nil {
+		return nil
+	}
+	return p.health.Load()
+}
+
+func (p *upstreamProber) result(provider string) (probeResult, bool) {
+	if p == nil {
+		return probeResult{}, false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result, ok := p.results[provider]
+	return result, ok
+}
+
+func (p *upstreamProber) run() {
+	for {
+		interval := p.probeAll()
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-p.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// probeAll probes every provider with probing enabled concurrently, replaces the
+// cached results and returns the interval until the next round.
+func (p *upstreamProber) probeAll() time.Duration {
+	cfg := p.healthConfig()
+	if cfg == nil {
+		return time.Minute
+	}
+	interval := time.Duration(cfg.ProbeIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	timeout := time.Duration(cfg.ProbeTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]probeResult)
+	)
+	for provider, health := range cfg.Providers {
+		if !health.Probe {
+			continue
+		}
+		wg.Add(1)
+		go func(provider string, health config.ProviderHealthConfig) {
+			defer wg.Done()
+			result := p.probe(provider, health.ProbeModel, timeout)
+			if !result.OK {
+				log.Warnf("readiness: upstream probe of %s failed: %s", provider, result.Error)
+			}
+			mu.Lock()
+			results[provider] = result
+			mu.Unlock()
+		}(provider, health)
+	}
+	wg.Wait()
+	p.mu.Lock()
+	p.results = results
+	p.mu.Unlock()
+	return interval
+}
+
+// probe sends a one-token chat request through the first available credential of
+// provider, by ID, to model or to the credential's first registered model.
+func (p *upstreamProber) probe(provider, model string, timeout time.Duration) probeResult {
+	result := probeResult{CheckedAt: time.Now(), Model: model}
+	manager := p.server.authManager()
+	if manager == nil {
+		result.Error = "auth manager unavailable"
+		return result
+	}
+	candidates := make([]*auth.Auth, 0)
+	for _, a := range manager.List() {
+		if a.Provider == provider && credentialState(a, result.CheckedAt) == credentialAvailable {
+			candidates = append(candidates, a)
+		}
+	}
+	if len(candidates) == 0 {
+		result.Error = "no available credential"
+		return result
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+	result.AuthID = candidates[0].ID
+	if result.Model == "" {
+		if models := registry.GetGlobalRegistry().GetModelsForClient(result.AuthID); len(models) > 0 {
+			result.Model = models[0].ID
+		}
+	}
+	if result.Model == "" {
+		result.Error = "no model registered for credential; set probe-model"
+		return result
+	}
+
+	payload, _ := json.Marshal(map[string]any{
+		"model":      result.Model,
+		"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+		"max_tokens": 1,
+		"stream":     false,
+	})
+	ctx, cancel := context.WithTimeout(coreusage.WithoutUsage(context.Background()), timeout)
+	defer cancel()
+	_, err := manager.ExecuteOnAuth(ctx, result.AuthID, cliproxyexecutor.Request{
+		Model:   result.Model,
+		Payload: payload,
+		Format:  sdktranslator.FormatOpenAI,
+	}, cliproxyexecutor.Options{
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FormatOpenAI,
+	})
+	result.LatencyMS = time.Since(result.CheckedAt).Milliseconds()
+	result.OK = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
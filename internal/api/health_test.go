@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type healthProbeExecutor struct {
+	err        error
+	calls      []string
+	suppressed bool
+}
+
+func (e *healthProbeExecutor) Identifier() string { return "probed" }
+
+func (e *healthProbeExecutor) Execute(ctx context.Context, a *auth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.calls = append(e.calls, a.ID+":"+req.Model)
+	e.suppressed = coreusage.IsUsageSuppressed(ctx)
+	return cliproxyexecutor.Response{Payload: []byte("{}")}, e.err
+}
+
+func (e *healthProbeExecutor) ExecuteStream(context.Context, *auth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *healthProbeExecutor) Refresh(_ context.Context, a *auth.Auth) (*auth.Auth, error) {
+	return a, nil
+}
+
+func (e *healthProbeExecutor) CountTokens(context.Context, *auth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *healthProbeExecutor) HttpRequest(context.Context, *auth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newHealthTestServer(t *testing.T, manager *auth.Manager, cfg *proxyconfig.Config) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := &Server{engine: gin.New(), cfg: cfg, handlers: handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)}
+	s.prober = newUpstreamProber(s)
+	if cfg != nil {
+		s.prober.update(cfg.Health)
+	}
+	s.engine.GET("/healthz", s.serveHealth)
+	s.engine.GET("/readyz", s.serveReadiness)
+	return s
+}
+
+func TestServeReadiness(t *testing.T) {
+	manager := auth.NewManager(nil, nil, nil)
+	future := time.Now().Add(time.Hour)
+	for _, a := range []*auth.Auth{
+		{ID: "claude-1", Provider: "claude", LastRefreshedAt: time.Now()},
+		{ID: "claude-2", Provider: "claude", RefreshError: &auth.Error{Message: "invalid_grant"}},
+		{ID: "kiro-1", Provider: "kiro", Unavailable: true, NextRetryAfter: future},
+		{ID: "gemini-1", Provider: "gemini", RefreshError: &auth.Error{Message: "invalid_grant"}},
+	} {
+		if _, err := manager.Register(context.Background(), a); err != nil {
+			t.Fatalf("Register(%s) error = %v", a.ID, err)
+		}
+	}
+	cfg := &proxyconfig.Config{Health: proxyconfig.HealthConfig{Providers: map[string]proxyconfig.ProviderHealthConfig{
+		"claude": {Required: true, Probe: true},
+		"kiro":   {Required: true},
+		"codex":  {},
+	}}}
+	s := newHealthTestServer(t, manager, cfg)
+
+	readyz := func() (int, map[string]providerReadiness) {
+		recorder := httptest.NewRecorder()
+		s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body struct {
+			Providers map[string]providerReadiness `json:"providers"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode /readyz: %v (%s)", err, recorder.Body.String())
+		}
+		return recorder.Code, body.Providers
+	}
+
+	code, providers := readyz()
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz status = %d, want 503", code)
+	}
+	wantReasons := map[string]string{
+		"claude": "upstream probe pending",
+		"kiro":   "no available credential",
+		"gemini": "token refresh failing",
+		"codex":  "no available credential",
+	}
+	for provider, reason := range wantReasons {
+		if got := providers[provider]; got.Ready || got.Reason != reason {
+			t.Fatalf("%s readiness = %+v, want reason %q", provider, got, reason)
+		}
+	}
+	if claude := providers["claude"]; claude.Credentials != 2 || claude.Available != 2 || claude.LastRefresh == nil || claude.RefreshError != "" {
+		t.Fatalf("claude readiness = %+v", claude)
+	}
+	if gemini := providers["gemini"]; gemini.Required || gemini.RefreshError == "" {
+		t.Fatalf("gemini readiness = %+v", gemini)
+	}
+
+	s.prober.results = map[string]probeResult{"claude": {OK: true, CheckedAt: time.Now()}}
+	cfg.Health.Providers["kiro"] = proxyconfig.ProviderHealthConfig{}
+	if _, providers = readyz(); providers["kiro"].Ready || !providers["kiro"].Required {
+		t.Fatalf("kiro readiness = %+v, want the settings snapshot unchanged until reload", providers["kiro"])
+	}
+	s.prober.update(cfg.Health)
+	code, providers = readyz()
+	if code != http.StatusOK || !providers["claude"].Ready || providers["claude"].Probe == nil {
+		t.Fatalf("/readyz = %d %+v, want 200 with claude ready", code, providers["claude"])
+	}
+
+	recorder := httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("/healthz status = %d", recorder.Code)
+	}
+}
+
+func TestUpstreamProber_ProbeSkipsUsage(t *testing.T) {
+	manager := auth.NewManager(nil, nil, nil)
+	executor := &healthProbeExecutor{}
+	manager.RegisterExecutor(executor)
+	for _, a := range []*auth.Auth{
+		{ID: "b", Provider: "probed"},
+		{ID: "a", Provider: "probed", Unavailable: true, NextRetryAfter: time.Now().Add(time.Hour)},
+	} {
+		if _, err := manager.Register(context.Background(), a); err != nil {
+			t.Fatalf("Register(%s) error = %v", a.ID, err)
+		}
+	}
+	s := newHealthTestServer(t, manager, &proxyconfig.Config{})
+
+	result := s.prober.probe("probed", "probe-model", time.Second)
+	if !result.OK || result.AuthID != "b" || len(executor.calls) != 1 || executor.calls[0] != "b:probe-model" {
+		t.Fatalf("probe = %+v, calls %v", result, executor.calls)
+	}
+	if !executor.suppressed {
+		t.Fatal("probe context does not suppress usage records")
+	}
+
+	executor.err = errors.New("upstream down")
+	if result = s.prober.probe("probed", "probe-model", time.Second); result.OK || result.Error != "upstream down" {
+		t.Fatalf("failing probe = %+v", result)
+	}
+	if result = s.prober.probe("missing", "probe-model", time.Second); result.OK || result.Error != "no available credential" {
+		t.Fatalf("probe without credentials = %+v", result)
+	}
+}
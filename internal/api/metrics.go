@@ -0,0 +1,205 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// Credential states reported by proxy_credentials.
+const (
+	credentialAvailable = "available"
+	credentialParked    = "parked"
+	credentialDisabled  = "disabled"
+)
+
+// initMetrics creates the server's Prometheus registry and registers the request,
+// token and credential collectors with it. Token counters are only registered in
+// binaries built with the prometheus tag. The registry exists whether or not metrics
+// are enabled, so that enabling them on reload needs no re-registration.
+func (s *Server) initMetrics() {
+	s.metricsRegistry = prometheus.NewRegistry()
+	m, err := metrics.New(s.metricsRegistry)
+	if err != nil {
+		log.Errorf("failed to register request metrics: %v", err)
+		return
+	}
+	s.metrics = m
+	if err = registerTokenMetrics(s.metricsRegistry); err != nil {
+		log.Errorf("failed to register token metrics: %v", err)
+	}
+	if err = s.metricsRegistry.Register(&credentialCollector{server: s}); err != nil {
+		log.Errorf("failed to register credential metrics: %v", err)
+	}
+	if err = s.metricsRegistry.Register(&responseCacheCollector{server: s}); err != nil {
+		log.Errorf("failed to register response cache metrics: %v", err)
+	}
+}
+
+// applyMetricsConfig turns the metrics endpoint and request metrics on or off.
+func (s *Server) applyMetricsConfig(cfg *config.Config) {
+	enabled := cfg != nil && cfg.Metrics.Enable && s.metrics != nil
+	s.metricsEnabled.Store(enabled)
+	if enabled {
+		metrics.SetActive(s.metrics)
+	} else {
+		metrics.SetActive(nil)
+	}
+}
+
+// MetricsRegistry returns the Prometheus registry served at /metrics.
+func (s *Server) MetricsRegistry() *prometheus.Registry {
+	return s.metricsRegistry
+}
+
+// serveMetrics serves the server's registry, or 404 while metrics are disabled.
+func (s *Server) serveMetrics(c *gin.Context) {
+	if !s.metricsEnabled.Load() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	promhttp.HandlerFor(s.metricsRegistry, promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+}
+
+// metricsMiddleware records the status, duration and, for event streams, the time to
+// first token of every API request. Management, metrics and health check requests are
+// not recorded.
+func (s *Server) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !s.metricsEnabled.Load() || path == "/metrics" || path == "/healthz" || path == "/readyz" || path == "/management.html" || strings.HasPrefix(path, "/v0/management") {
+			c.Next()
+			return
+		}
+		writer := &metricsResponseWriter{ResponseWriter: c.Writer, start: time.Now()}
+		c.Writer = writer
+		c.Next()
+
+		provider := c.Writer.Header().Get(handlers.ProviderHeader)
+		if provider == "" {
+			provider = metrics.UnknownLabel
+		}
+		model := metrics.Model(c)
+		if !writer.firstWrite.IsZero() {
+			s.metrics.ObserveTimeToFirstToken(provider, model, writer.firstWrite.Sub(writer.start))
+		}
+		s.metrics.ObserveRequest(provider, model, c.Writer.Status(), time.Since(writer.start))
+	}
+}
+
+// metricsResponseWriter notes when the first body bytes of an event stream are written.
+type metricsResponseWriter struct {
+	gin.ResponseWriter
+	start      time.Time
+	firstWrite time.Time
+}
+
+func (w *metricsResponseWriter) Write(data []byte) (int, error) {
+	w.noteWrite()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *metricsResponseWriter) WriteString(data string) (int, error) {
+	w.noteWrite()
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *metricsResponseWriter) noteWrite() {
+	if w.firstWrite.IsZero() && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.firstWrite = time.Now()
+	}
+}
+
+// credentialCollector reports the number of credentials per provider and state from
+// the server's auth manager at scrape time.
+type credentialCollector struct {
+	server *Server
+}
+
+var credentialsDesc = prometheus.NewDesc(
+	"proxy_credentials",
+	"Credentials by provider and state (available, parked, disabled).",
+	[]string{"provider", "state"}, nil,
+)
+
+func (c *credentialCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- credentialsDesc
+}
+
+func (c *credentialCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.server.handlers == nil || c.server.handlers.AuthManager == nil {
+		return
+	}
+	type key struct{ provider, state string }
+	counts := make(map[key]int)
+	now := time.Now()
+	for _, a := range c.server.handlers.AuthManager.List() {
+		counts[key{a.Provider, credentialState(a, now)}]++
+	}
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(credentialsDesc, prometheus.GaugeValue, float64(n), k.provider, k.state)
+	}
+}
+
+// credentialState classifies a credential. It is parked while the whole credential,
+// or every model it has state for, is cooling down.
+func credentialState(a *auth.Auth, now time.Time) string {
+	if a.Disabled || a.Status == auth.StatusDisabled {
+		return credentialDisabled
+	}
+	if a.Unavailable && a.NextRetryAfter.After(now) {
+		return credentialParked
+	}
+	if len(a.ModelStates) == 0 {
+		return credentialAvailable
+	}
+	for _, state := range a.ModelStates {
+		if state == nil || !state.Unavailable || !state.NextRetryAfter.After(now) {
+			return credentialAvailable
+		}
+	}
+	return credentialParked
+}
+
+// responseCacheCollector reports the response cache counters of the server's handlers
+// at scrape time.
+type responseCacheCollector struct {
+	server *Server
+}
+
+var (
+	responseCacheHitsDesc      = prometheus.NewDesc("proxy_response_cache_hits_total", "Requests answered from the response cache.", nil, nil)
+	responseCacheMissesDesc    = prometheus.NewDesc("proxy_response_cache_misses_total", "Cacheable requests not found in the response cache.", nil, nil)
+	responseCacheEvictionsDesc = prometheus.NewDesc("proxy_response_cache_evictions_total", "Responses evicted from the response cache on expiry or to stay within its bounds.", nil, nil)
+	responseCacheEntriesDesc   = prometheus.NewDesc("proxy_response_cache_entries", "Responses held by the response cache.", nil, nil)
+	responseCacheBytesDesc     = prometheus.NewDesc("proxy_response_cache_bytes", "Response bytes held by the response cache.", nil, nil)
+)
+
+func (c *responseCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- responseCacheHitsDesc
+	ch <- responseCacheMissesDesc
+	ch <- responseCacheEvictionsDesc
+	ch <- responseCacheEntriesDesc
+	ch <- responseCacheBytesDesc
+}
+
+func (c *responseCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.server.handlers == nil {
+		return
+	}
+	stats := c.server.handlers.ResponseCacheStats()
+	ch <- prometheus.MustNewConstMetric(responseCacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(responseCacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(responseCacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(responseCacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(responseCacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// newMetricsTestServer serves /stream, which routes to a registered model and writes an
+// event stream, alongside /metrics.
+func newMetricsTestServer(t *testing.T, manager *auth.Manager) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	clientID := "metrics-api-test-client"
+	registry.GetGlobalRegistry().RegisterClient(clientID, "claude", []*registry.ModelInfo{{ID: "claude-sonnet"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(clientID) })
+
+	s := &Server{engine: gin.New(), handlers: handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)}
+	s.initMetrics()
+	s.engine.Use(s.metricsMiddleware())
+	s.applyMetricsConfig(&proxyconfig.Config{Metrics: proxyconfig.MetricsConfig{Enable: true}})
+	t.Cleanup(func() { metrics.SetActive(nil) })
+	s.engine.GET("/metrics", s.serveMetrics)
+	s.engine.POST("/stream", func(c *gin.Context) {
+		metrics.SetModel(context.WithValue(context.Background(), "gin", c), c.Query("model"))
+		c.Header(handlers.ProviderHeader, "claude")
+		c.Header("Content-Type", "text/event-stream")
+		time.Sleep(5 * time.Millisecond)
+		_, _ = c.Writer.Write([]byte("data: {}\n\n"))
+	})
+	return s
+}
+
+func TestMetricsMiddleware_LabelsRequests(t *testing.T) {
+	s := newMetricsTestServer(t, nil)
+	for _, model := range []string{"claude-sonnet(high)", "claude-sonnet", "client-typed-name"} {
+		recorder := httptest.NewRecorder()
+		s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/stream?model="+model, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d", recorder.Code)
+		}
+	}
+
+	want := `
+# HELP proxy_requests_total Requests handled, by serving provider, model and HTTP status.
+# TYPE proxy_requests_total counter
+proxy_requests_total{model="claude-sonnet",provider="claude",status="200"} 2
+proxy_requests_total{model="other",provider="claude",status="200"} 1
+`
+	if err := testutil.GatherAndCompare(s.MetricsRegistry(), strings.NewReader(want), "proxy_requests_total"); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+	if got := testutil.CollectAndCount(s.MetricsRegistry(), "proxy_time_to_first_token_seconds"); got != 2 {
+		t.Fatalf("time-to-first-token series = %d, want 2", got)
+	}
+}
+
+func TestMetrics_CountsRetriesAndFailoversWhileEnabled(t *testing.T) {
+	s := newMetricsTestServer(t, nil)
+	metrics.RecordFailover([]string{"kiro"}, []string{"claude"})
+	metrics.RecordRetry([]string{"kiro"})
+
+	want := `
+# HELP proxy_failovers_total Requests moved to the next stage of a failover chain.
+# TYPE proxy_failovers_total counter
+proxy_failovers_total{from="kiro",to="claude"} 1
+# HELP proxy_retries_total Requests retried after every credential of the providers failed.
+# TYPE proxy_retries_total counter
+proxy_retries_total{provider="kiro"} 1
+`
+	if err := testutil.GatherAndCompare(s.MetricsRegistry(), strings.NewReader(want), "proxy_failovers_total", "proxy_retries_total"); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+
+	s.applyMetricsConfig(&proxyconfig.Config{})
+	metrics.RecordRetry([]string{"kiro"})
+	if err := testutil.GatherAndCompare(s.MetricsRegistry(), strings.NewReader(want), "proxy_failovers_total", "proxy_retries_total"); err != nil {
+		t.Fatalf("retry counted while disabled: %v", err)
+	}
+}
+
+func TestServeMetrics_FollowsConfig(t *testing.T) {
+	s := newMetricsTestServer(t, nil)
+	s.engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/stream?model=claude-sonnet", nil))
+	recorder := httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "proxy_requests_total") {
+		t.Fatalf("enabled /metrics = %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	s.applyMetricsConfig(&proxyconfig.Config{})
+	recorder = httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("disabled /metrics = %d, want 404", recorder.Code)
+	}
+}
+
+func TestCredentialCollector_CountsStates(t *testing.T) {
+	manager := auth.NewManager(nil, nil, nil)
+	future := time.Now().Add(time.Hour)
+	for _, a := range []*auth.Auth{
+		{ID: "a", Provider: "claude"},
+		{ID: "b", Provider: "claude", Unavailable: true, NextRetryAfter: future},
+		{ID: "c", Provider: "kiro", ModelStates: map[string]*auth.ModelState{
+			"m1": {Unavailable: true, NextRetryAfter: future},
+		}},
+		{ID: "d", Provider: "kiro", ModelStates: map[string]*auth.ModelState{
+			"m1": {Unavailable: true, NextRetryAfter: future},
+			"m2": {},
+		}},
+		{ID: "e", Provider: "kiro", Disabled: true},
+	} {
+		if _, err := manager.Register(context.Background(), a); err != nil {
+			t.Fatalf("Register(%s) error = %v", a.ID, err)
+		}
+	}
+	s := newMetricsTestServer(t, manager)
+
+	want := `
+# HELP proxy_credentials Credentials by provider and state (available, parked, disabled).
+# TYPE proxy_credentials gauge
+proxy_credentials{provider="claude",state="available"} 1
+proxy_credentials{provider="claude",state="parked"} 1
+proxy_credentials{provider="kiro",state="available"} 1
+proxy_credentials{provider="kiro",state="disabled"} 1
+proxy_credentials{provider="kiro",state="parked"} 1
+`
+	if err := testutil.GatherAndCompare(s.MetricsRegistry(), strings.NewReader(want), "proxy_credentials"); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// BodyLimiter rejects API requests whose body exceeds a configurable size with 413.
+// Management requests are not limited. It is safe for concurrent use.
+type BodyLimiter struct {
+	limit atomic.Int64
+}
+
+// NewBodyLimiter returns a limiter that admits every body until SetLimit is called.
+func NewBodyLimiter() *BodyLimiter {
+	return &BodyLimiter{}
+}
+
+// SetLimit sets the largest admitted body in bytes. <= 0 disables the limit.
+func (l *BodyLimiter) SetLimit(limit int64) {
+	if l == nil {
+		return
+	}
+	l.limit.Store(limit)
+}
+
+// Middleware enforces the limit. Bodies without a Content-Length are read up to the
+// limit, so that oversized chunked bodies get 413 too instead of failing mid-read in
+// the handler.
+func (l *BodyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := l.limit.Load()
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody || strings.HasPrefix(c.Request.URL.Path, "/v0/management") {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			abortBodyTooLarge(c, limit)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+		_ = c.Request.Body.Close()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{Message: fmt.Sprintf("failed to read request body: %v", err), Type: "invalid_request_error"},
+			})
+			return
+		}
+		if int64(len(data)) > limit {
+			abortBodyTooLarge(c, limit)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context, limit int64) {
+	message := fmt.Sprintf("request body exceeds the maximum size of %d bytes", limit)
+	c.Header("Connection", "close")
+	c.Data(http.StatusRequestEntityTooLarge, "application/json", handlers.BuildDialectErrorBody(c.Request.URL.Path, http.StatusRequestEntityTooLarge, message))
+	c.Abort()
+}
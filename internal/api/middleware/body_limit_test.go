@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestBodyLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewBodyLimiter()
+	l.SetLimit(8)
+	engine := gin.New()
+	engine.Use(l.Middleware())
+	echo := func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(data))
+	}
+	engine.POST("/v1/messages", echo)
+	engine.POST("/v1/chat/completions", echo)
+	engine.PUT("/v0/management/config.yaml", echo)
+
+	serve := func(path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if strings.HasPrefix(path, "/v0/") {
+			req.Method = http.MethodPut
+		}
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("/v1/messages", "12345678", false); rec.Code != http.StatusOK || rec.Body.String() != "12345678" {
+		t.Fatalf("body at the limit = %d %q", rec.Code, rec.Body)
+	}
+	rec := serve("/v1/messages", "123456789", false)
+	if rec.Code != http.StatusRequestEntityTooLarge || gjson.Get(rec.Body.String(), "error.type").String() != "request_too_large" {
+		t.Fatalf("oversized Claude body = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve("/v1/chat/completions", "123456789", true)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(gjson.Get(rec.Body.String(), "error.message").String(), "8 bytes") {
+		t.Fatalf("oversized chunked body = %d %s", rec.Code, rec.Body)
+	}
+	if rec = serve("/v1/chat/completions", "1234", true); rec.Code != http.StatusOK || rec.Body.String() != "1234" {
+		t.Fatalf("small chunked body = %d %q", rec.Code, rec.Body)
+	}
+	if rec = serve("/v0/management/config.yaml", "123456789", false); rec.Code != http.StatusOK {
+		t.Fatalf("management body was limited: %d", rec.Code)
+	}
+
+	l.SetLimit(0)
+	if rec = serve("/v1/messages", "123456789", false); rec.Code != http.StatusOK {
+		t.Fatalf("disabled limit = %d", rec.Code)
+	}
+}
@@ -0,0 +1,336 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+// rateLimitWindow is the sliding window length shared by all budgets.
+const rateLimitWindow = time.Minute
+
+// reservationCharsPerToken approximates the token count of a streaming request body
+// for the up-front reservation.
+const reservationCharsPerToken = 4
+
+type tokenEvent struct {
+	at     time.Time
+	tokens int64
+}
+
+type keyWindow struct {
+	requests []time.Time
+	tokens   []tokenEvent
+	used     int64
+	reserved int64
+}
+
+// prune drops requests and token events that left the window ending at now.
+func (w *keyWindow) prune(now time.Time) {
+	cutoff := now.Add(-rateLimitWindow)
+	n := 0
+	for n < len(w.requests) && !w.requests[n].After(cutoff) {
+		n++
+	}
+	w.requests = w.requests[n:]
+	n = 0
+	for n < len(w.tokens) && !w.tokens[n].at.After(cutoff) {
+		w.used -= w.tokens[n].tokens
+		n++
+	}
+	w.tokens = w.tokens[n:]
+}
+
+// RateLimiter enforces config.RateLimitConfig budgets per client API key.
+// Token consumption is committed from usage records, so it also implements
+// coreusage.Plugin. It is safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	cfg      config.RateLimitConfig
+	limits   map[string]config.APIKeyRateLimit
+	windows  map[string]*keyWindow
+	now      func() time.Time
+	disabled bool
+}
+
+// NewRateLimiter returns a limiter with no budgets configured.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		limits:   map[string]config.APIKeyRateLimit{},
+		windows:  map[string]*keyWindow{},
+		now:      time.Now,
+		disabled: true,
+	}
+}
+
+var defaultRateLimiter = NewRateLimiter()
+
+func init() {
+	coreusage.RegisterPlugin(defaultRateLimiter)
+}
+
+// DefaultRateLimiter returns the process-wide limiter fed by the usage pipeline.
+func DefaultRateLimiter() *RateLimiter { return defaultRateLimiter }
+
+// SetConfig replaces the configured budgets. Windows of keys that are still limited
+// are kept so a config reload does not reset their consumption.
+func (l *RateLimiter) SetConfig(cfg config.RateLimitConfig) {
+	if l == nil {
+		return
+	}
+	limits := make(map[string]config.APIKeyRateLimit, len(cfg.Keys))
+	for _, limit := range cfg.Keys {
+		if key := strings.TrimSpace(limit.APIKey); key != "" {
+			limits[key] = limit
+		}
+	}
+	disabled := !limitEnabled(cfg.Default)
+	for _, limit := range limits {
+		if limitEnabled(limit) {
+			disabled = false
+			break
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	l.limits = limits
+	l.disabled = disabled
+	if disabled {
+		l.windows = map[string]*keyWindow{}
+	}
+}
+
+func limitEnabled(limit config.APIKeyRateLimit) bool {
+	return limit.RequestsPerMinute > 0 || limit.TokensPerMinute > 0
+}
+
+func (l *RateLimiter) limitFor(apiKey string) (config.APIKeyRateLimit, bool) {
+	limit, ok := l.limits[apiKey]
+	if !ok {
+		limit = l.cfg.Default
+	}
+	return limit, limitEnabled(limit)
+}
+
+func (l *RateLimiter) window(apiKey string) *keyWindow {
+	w, ok := l.windows[apiKey]
+	if !ok {
+		w = &keyWindow{}
+		l.windows[apiKey] = w
+	}
+	return w
+}
+
+// acquire admits a request for apiKey and reserves reserve tokens until release.
+// When the request exceeds a budget it returns false and how long to wait.
+func (l *RateLimiter) acquire(apiKey string, reserve int64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit, ok := l.limitFor(apiKey)
+	if l.disabled || !ok {
+		return true, 0
+	}
+	now := l.now()
+	w := l.window(apiKey)
+	w.prune(now)
+
+	if limit.RequestsPerMinute > 0 && len(w.requests) >= limit.RequestsPerMinute {
+		return false, w.requests[len(w.requests)-limit.RequestsPerMinute].Add(rateLimitWindow).Sub(now)
+	}
+	if limit.TokensPerMinute > 0 {
+		pending := w.used + w.reserved
+		// Tokens that must leave the window before this request fits; a request without a
+		// reservation only needs the budget not to be exhausted.
+		need := pending + reserve - limit.TokensPerMinute
+		if reserve == 0 {
+			need++
+		}
+		// An idle window always admits one request so an oversized prompt is not locked out forever.
+		if pending > 0 && need > 0 {
+			return false, w.tokenRetryAfter(now, need)
+		}
+		w.reserved += reserve
+	}
+	w.requests = append(w.requests, now)
+	return true, 0
+}
+
+// tokenRetryAfter returns how long until committed tokens worth at least need leave
+// the window, or the full window when reservations alone exceed the budget.
+func (w *keyWindow) tokenRetryAfter(now time.Time, need int64) time.Duration {
+	var freed int64
+	for _, ev := range w.tokens {
+		freed += ev.tokens
+		if freed >= need {
+			return ev.at.Add(rateLimitWindow).Sub(now)
+		}
+	}
+	return rateLimitWindow
+}
+
+// release returns a reservation taken by acquire.
+func (l *RateLimiter) release(apiKey string, reserve int64) {
+	if reserve <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w, ok := l.windows[apiKey]; ok {
+		w.reserved = max(w.reserved-reserve, 0)
+	}
+}
+
+// commit records tokens actually consumed by apiKey.
+func (l *RateLimiter) commit(apiKey string, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.disabled {
+		return
+	}
+	if limit, ok := l.limitFor(apiKey); !ok || limit.TokensPerMinute <= 0 {
+		return
+	}
+	now := l.now()
+	w := l.window(apiKey)
+	w.prune(now)
+	w.tokens = append(w.tokens, tokenEvent{at: now, tokens: tokens})
+	w.used += tokens
+}
+
+// HandleUsage implements coreusage.Plugin by committing the input and output tokens
+// of each completed request to the window of its API key. Hedged duplicates are
+// committed too, since the upstream consumed their tokens.
+func (l *RateLimiter) HandleUsage(_ context.Context, record coreusage.Record) {
+	if l == nil || record.APIKey == "" {
+		return
+	}
+	l.commit(record.APIKey, record.Detail.InputTokens+record.Detail.OutputTokens)
+}
+
+// Middleware returns a Gin handler that rejects requests over their key's budget
+// with 429 and Retry-After. It must run after authentication so the API key is known.
+// Streaming requests reserve an estimate of their prompt tokens until they finish;
+// the reservation is then replaced by the usage committed through HandleUsage.
+// WebSocket upgrades are admitted, and each request sent over the socket is budgeted
+// through the openai.WebsocketAdmitter stored on the context instead.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		if websocket.IsWebSocketUpgrade(c.Request) {
+			c.Set(openai.WebsocketAdmitterKey, openai.WebsocketAdmitter(func(request []byte) (func(), error) {
+				release, retryAfter, admitted := l.admit(c, func() int64 {
+					return int64(len(request)/reservationCharsPerToken) + 1
+				})
+				if !admitted {
+					return nil, errors.New(rateLimitedMessage(retryAfter))
+				}
+				return release, nil
+			}))
+			c.Next()
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+		release, retryAfter, admitted := l.admit(c, func() int64 { return streamingReservation(c) })
+		if !admitted {
+			writeRateLimited(c, retryAfter)
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// admit admits a request of the client authenticated on c, reserving reservation()
+// tokens when its budget limits tokens. It returns the func releasing the reservation,
+// or false and how long to wait when the request is over budget.
+func (l *RateLimiter) admit(c *gin.Context, reservation func() int64) (func(), time.Duration, bool) {
+	// Named client keys are budgeted, and their usage recorded, under their name.
+	apiKey := c.GetString("apiKeyName")
+	if apiKey == "" {
+		apiKey = c.GetString("apiKey")
+	}
+	if apiKey == "" {
+		return func() {}, 0, true
+	}
+	l.mu.Lock()
+	limit, limited := l.limitFor(apiKey)
+	limited = limited && !l.disabled
+	l.mu.Unlock()
+	if !limited {
+		return func() {}, 0, true
+	}
+
+	var reserve int64
+	if limit.TokensPerMinute > 0 {
+		reserve = reservation()
+	}
+	admitted, retryAfter := l.acquire(apiKey, reserve)
+	if !admitted {
+		return nil, retryAfter, false
+	}
+	return func() { l.release(apiKey, reserve) }, 0, true
+}
+
+// streamingReservation estimates the prompt tokens of a streaming request and
+// restores the request body for downstream handlers. Non-streaming requests reserve nothing.
+func streamingReservation(c *gin.Context) int64 {
+	if c.Request.Body == nil {
+		return 0
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0
+	}
+	if !gjson.GetBytes(body, "stream").Bool() && !strings.Contains(c.Request.URL.Path, ":streamGenerateContent") {
+		return 0
+	}
+	return int64(len(body)/reservationCharsPerToken) + 1
+}
+
+// writeRateLimited aborts c with a 429 response shaped like the errors of the
+// compatible API that was called.
+func writeRateLimited(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.FormatInt(retryAfterSeconds(retryAfter), 10))
+
+	body := handlers.BuildDialectErrorBody(c.Request.URL.Path, http.StatusTooManyRequests, rateLimitedMessage(retryAfter))
+	c.Data(http.StatusTooManyRequests, "application/json", body)
+	c.Abort()
+}
+
+// retryAfterSeconds rounds retryAfter up to whole seconds, at least one.
+func retryAfterSeconds(retryAfter time.Duration) int64 {
+	return max(int64(math.Ceil(retryAfter.Seconds())), 1)
+}
+
+// rateLimitedMessage is the error message of a request rejected for retryAfter.
+func rateLimitedMessage(retryAfter time.Duration) string {
+	return fmt.Sprintf("rate limit exceeded for this API key, retry after %d seconds", retryAfterSeconds(retryAfter))
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+type rateLimitClock struct{ now time.Time }
+
+func (c *rateLimitClock) Now() time.Time { return c.now }
+
+func newTestRateLimiter(cfg config.RateLimitConfig) (*RateLimiter, *rateLimitClock) {
+	clock := &rateLimitClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter()
+	l.now = clock.Now
+	l.SetConfig(cfg)
+	return l, clock
+}
+
+func newRateLimitEngine(l *RateLimiter, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("Authorization"))
+		c.Next()
+	}, l.Middleware())
+	engine.POST("/v1/chat/completions", handler)
+	engine.POST("/v1/messages", handler)
+	engine.POST("/v1beta/models/*action", handler)
+	return engine
+}
+
+func serveRateLimited(engine *gin.Engine, path, apiKey, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", apiKey)
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func okHandler(c *gin.Context) { c.Status(http.StatusOK) }
+
+func TestRateLimiter_RequestsPerMinute(t *testing.T) {
+	l, clock := newTestRateLimiter(config.RateLimitConfig{
+		Keys: []config.APIKeyRateLimit{{APIKey: "k1", RequestsPerMinute: 2}},
+	})
+	engine := newRateLimitEngine(l, okHandler)
+
+	for i := 0; i < 2; i++ {
+		if rec := serveRateLimited(engine, "/v1/chat/completions", "k1", `{}`); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, rec.Code)
+		}
+		clock.now = clock.now.Add(10 * time.Second)
+	}
+	rec := serveRateLimited(engine, "/v1/chat/completions", "k1", `{}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "40" {
+		t.Fatalf("Retry-After = %q, want 40", got)
+	}
+	if got := gjson.Get(rec.Body.String(), "error.type").String(); got != "rate_limit_error" {
+		t.Fatalf("unexpected OpenAI error body: %s", rec.Body.String())
+	}
+
+	// Unconfigured keys are unlimited when there is no default.
+	if rec = serveRateLimited(engine, "/v1/chat/completions", "other", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("unlimited key status = %d", rec.Code)
+	}
+
+	clock.now = clock.now.Add(41 * time.Second)
+	if rec = serveRateLimited(engine, "/v1/chat/completions", "k1", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("request after window slid: status = %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_TokensPerMinuteUsesActualUsage(t *testing.T) {
+	l, clock := newTestRateLimiter(config.RateLimitConfig{
+		Default: config.APIKeyRateLimit{TokensPerMinute: 1000},
+	})
+	engine := newRateLimitEngine(l, okHandler)
+
+	if rec := serveRateLimited(engine, "/v1/messages", "k1", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d", rec.Code)
+	}
+	l.HandleUsage(context.Background(), coreusage.Record{APIKey: "k1", Detail: coreusage.Detail{InputTokens: 700, OutputTokens: 300}})
+
+	rec := serveRateLimited(engine, "/v1/messages", "k1", `{}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over-budget status = %d, want 429", rec.Code)
+	}
+	if gjson.Get(rec.Body.String(), "type").String() != "error" || gjson.Get(rec.Body.String(), "error.type").String() != "rate_limit_error" {
+		t.Fatalf("unexpected Anthropic error body: %s", rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q, want 60", got)
+	}
+
+	rec = serveRateLimited(engine, "/v1beta/models/gemini-2.5-pro:generateContent", "k1", `{}`)
+	if got := gjson.Get(rec.Body.String(), "error.status").String(); got != "RESOURCE_EXHAUSTED" {
+		t.Fatalf("unexpected Gemini error body: %s", rec.Body.String())
+	}
+
+	clock.now = clock.now.Add(61 * time.Second)
+	if rec = serveRateLimited(engine, "/v1/messages", "k1", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("request after usage expired: status = %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_StreamingReservationIsReleased(t *testing.T) {
+	l, _ := newTestRateLimiter(config.RateLimitConfig{
+		Keys: []config.APIKeyRateLimit{{APIKey: "k1", TokensPerMinute: 100}},
+	})
+	body := `{"stream":true,"messages":[{"role":"user","content":"` + strings.Repeat("x", 300) + `"}]}`
+
+	var inFlight *httptest.ResponseRecorder
+	engine := newRateLimitEngine(l, func(c *gin.Context) {
+		// While the stream is open its reservation blocks a second streaming request.
+		if inFlight == nil {
+			inFlight = serveRateLimited(newRateLimitEngine(l, okHandler), "/v1/chat/completions", "k1", body)
+		}
+		raw, _ := c.GetRawData()
+		if !gjson.GetBytes(raw, "stream").Bool() {
+			t.Errorf("request body was not restored for the handler")
+		}
+		c.Status(http.StatusOK)
+	})
+
+	if rec := serveRateLimited(engine, "/v1/chat/completions", "k1", body); rec.Code != http.StatusOK {
+		t.Fatalf("streaming request status = %d", rec.Code)
+	}
+	if inFlight.Code != http.StatusTooManyRequests {
+		t.Fatalf("concurrent streaming request status = %d, want 429", inFlight.Code)
+	}
+	l.mu.Lock()
+	reserved := l.windows["k1"].reserved
+	l.mu.Unlock()
+	if reserved != 0 {
+		t.Fatalf("reservation not released: %d", reserved)
+	}
+}
+
+func TestRateLimiter_SetConfigDisables(t *testing.T) {
+	l, _ := newTestRateLimiter(config.RateLimitConfig{
+		Keys: []config.APIKeyRateLimit{{APIKey: "k1", RequestsPerMinute: 1}},
+	})
+	engine := newRateLimitEngine(l, okHandler)
+	serveRateLimited(engine, "/v1/chat/completions", "k1", `{}`)
+	if rec := serveRateLimited(engine, "/v1/chat/completions", "k1", `{}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	l.SetConfig(config.RateLimitConfig{})
+	if rec := serveRateLimited(engine, "/v1/chat/completions", "k1", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("status after disabling limits = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiter_WebsocketBudgetsEachRequest(t *testing.T) {
+	l, _ := newTestRateLimiter(config.RateLimitConfig{
+		Keys: []config.APIKeyRateLimit{{APIKey: "k1", RequestsPerMinute: 1}},
+	})
+	var admit openai.WebsocketAdmitter
+	engine := newRateLimitEngine(l, okHandler)
+	engine.GET("/v1/chat/completions", func(c *gin.Context) {
+		value, _ := c.Get(openai.WebsocketAdmitterKey)
+		admit, _ = value.(openai.WebsocketAdmitter)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "k1")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || admit == nil {
+		t.Fatalf("upgrade status = %d, admitter set = %v", rec.Code, admit != nil)
+	}
+	release, err := admit([]byte(`{"stream":true}`))
+	if err != nil {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	release()
+	if _, err = admit([]byte(`{"stream":true}`)); err == nil || !strings.Contains(err.Error(), "rate limit exceeded") {
+		t.Fatalf("second request error = %v", err)
+	}
+}
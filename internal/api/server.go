@@ -13,12 +13,15 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
@@ -28,6 +31,8 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -41,6 +46,13 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// defaultShutdownDrainTimeout bounds draining when shutdown-drain-timeout is unset.
+	defaultShutdownDrainTimeout = 30 * time.Second
+	// drainRetryAfterSeconds is the Retry-After sent with 503s while draining.
+	drainRetryAfterSeconds = 5
+)
+
 const oauthCallbackSuccessHTML = `<html><head><meta charset="utf-8"><title>Authentication successful</title><script>setTimeout(function(){window.close();},5000);</script></head><body><h1>Authentication successful!</h1><p>You can close this window.</p><p>This window will close automatically in 5 seconds.</p></body></html>`
 
 type serverOptionConfig struct {
@@ -134,10 +146,21 @@ type Server struct {
 	// accessManager handles request authentication providers.
 	accessManager *sdkaccess.Manager
 
+	// rateLimiter enforces per-API-key request and token budgets.
+	rateLimiter *middleware.RateLimiter
+
+	// bodyLimiter rejects API request bodies over request-limits.max-body-mb.
+	bodyLimiter *middleware.BodyLimiter
+
 	// requestLogger is the request logger instance for dynamic configuration updates.
 	requestLogger logging.RequestLogger
 	loggerToggle  func(bool)
 
+	// accessLog writes the structured JSON access log when enabled.
+	accessLog *logging.AccessLogger
+	// capturer writes debug capture bundles when enabled.
+	capturer *logging.Capturer
+
 	// configFilePath is the absolute path to the YAML config file for persistence.
 	configFilePath string
 
@@ -171,6 +194,21 @@ type Server struct {
 	keepAliveOnTimeout func()
 	keepAliveHeartbeat chan struct{}
 	keepAliveStop      chan struct{}
+
+	// draining is set once shutdown begins; new requests are refused with 503.
+	draining atomic.Bool
+	// inFlight counts requests currently being served, including open streams.
+	inFlight atomic.Int64
+
+	// metricsRegistry is the Prometheus registry served at /metrics.
+	metricsRegistry *prometheus.Registry
+	// metrics holds the request collectors registered with metricsRegistry.
+	metrics *metrics.Metrics
+	// metricsEnabled mirrors cfg.Metrics.Enable; /metrics and request metrics are off while false.
+	metricsEnabled atomic.Bool
+
+	// prober caches the upstream probe results reported by /readyz.
+	prober *upstreamProber
 }
 
 // NewServer creates and initializes a new API server instance.
@@ -207,6 +245,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
+	// Enforce the body limit before any middleware buffers the body.
+	bodyLimiter := middleware.NewBodyLimiter()
+	bodyLimiter.SetLimit(int64(cfg.RequestLimits.MaxBodyMB) << 20)
+	engine.Use(bodyLimiter.Middleware())
 
 	// Add request logging middleware (positioned after recovery, before auth)
 	// Resolve logs directory relative to the configuration file directory.
@@ -240,22 +282,40 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		handlers:            handlers.NewBaseAPIHandlers(&cfg.SDKConfig, authManager),
 		cfg:                 cfg,
 		accessManager:       accessManager,
+		rateLimiter:         middleware.DefaultRateLimiter(),
+		bodyLimiter:         bodyLimiter,
 		requestLogger:       requestLogger,
 		loggerToggle:        toggle,
 		configFilePath:      configFilePath,
 		currentPath:         wd,
 		envManagementSecret: envManagementSecret,
 		wsRoutes:            make(map[string]struct{}),
-	}
+		accessLog:           logging.NewAccessLogger(),
+		capturer:            logging.NewCapturer(),
+	}
+	engine.Use(s.drainMiddleware())
+	if errAccessLog := s.accessLog.Configure(cfg.AccessLog, logging.ResolveLogDirectory(cfg)); errAccessLog != nil {
+		log.Errorf("failed to open access log: %v", errAccessLog)
+	}
+	engine.Use(s.accessLog.Middleware())
+	s.capturer.Configure(cfg.Capture, logging.ResolveLogDirectory(cfg))
+	engine.Use(s.capturer.Middleware())
+	s.initMetrics()
+	engine.Use(s.metricsMiddleware())
+	s.applyMetricsConfig(cfg)
+	s.prober = newUpstreamProber(s)
+	s.applyHealthConfig(cfg)
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
 	s.applyAccessConfig(nil, cfg)
+	s.rateLimiter.SetConfig(cfg.RateLimit.WithClientKeys(cfg.ClientKeys))
 	if authManager != nil {
 		authManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	auth.SetQuotaBackoff(cfg.QuotaExceeded.QuotaBackoff())
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -314,6 +374,9 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 // It defines the endpoints and associates them with their respective handlers.
 func (s *Server) setupRoutes() {
 	s.engine.GET("/management.html", s.serveManagementControlPanel)
+	s.engine.GET("/metrics", s.serveMetrics)
+	s.engine.GET("/healthz", s.serveHealth)
+	s.engine.GET("/readyz", s.serveReadiness)
 	openaiHandlers := openai.NewOpenAIAPIHandler(s.handlers)
 	geminiHandlers := gemini.NewGeminiAPIHandler(s.handlers)
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
@@ -322,24 +385,30 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager), s.rateLimiter.Middleware())
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
 		v1.POST("/responses/compact", openaiResponsesHandlers.Compact)
 	}
+	// Chat completions over WebSocket; clients may authenticate with their first frame,
+	// and each request sent over the socket is budgeted by the rate limiter.
+	s.engine.GET("/v1/chat/completions", WebsocketAuthMiddleware(s.accessManager), s.rateLimiter.Middleware(), openaiHandlers.ChatCompletionsWebsocket)
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager), s.rateLimiter.Middleware())
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
 		v1beta.GET("/models/*action", geminiHandlers.GeminiGetHandler)
+		v1beta.Any("/cachedContents", geminiHandlers.GeminiCachedContents)
+		v1beta.Any("/cachedContents/*name", geminiHandlers.GeminiCachedContents)
 	}
 
 	// Root endpoint
@@ -505,8 +574,11 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
 		mgmt.GET("/config", s.mgmt.GetConfig)
+		mgmt.PATCH("/config", s.mgmt.PatchConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
+		mgmt.POST("/config/reload", s.mgmt.PostConfigReload)
+		mgmt.POST("/system-prompt/preview", s.mgmt.PostSystemPromptPreview)
 		mgmt.GET("/latest-version", s.mgmt.GetLatestVersion)
 
 		mgmt.GET("/debug", s.mgmt.GetDebug)
@@ -549,6 +621,10 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
 
+		mgmt.GET("/client-keys", s.mgmt.GetClientKeys)
+		mgmt.POST("/client-keys", s.mgmt.PostClientKey)
+		mgmt.DELETE("/client-keys/:name", s.mgmt.DeleteClientKey)
+
 		mgmt.GET("/gemini-api-key", s.mgmt.GetGeminiKeys)
 		mgmt.PUT("/gemini-api-key", s.mgmt.PutGeminiKeys)
 		mgmt.PATCH("/gemini-api-key", s.mgmt.PatchGeminiKey)
@@ -559,6 +635,8 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/request-error-logs", s.mgmt.GetRequestErrorLogs)
 		mgmt.GET("/request-error-logs/:name", s.mgmt.DownloadRequestErrorLog)
 		mgmt.GET("/request-log-by-id/:id", s.mgmt.GetRequestLogByID)
+		mgmt.GET("/captures", s.mgmt.GetCaptures)
+		mgmt.GET("/captures/:id", s.mgmt.GetCapture)
 		mgmt.GET("/request-log", s.mgmt.GetRequestLog)
 		mgmt.PUT("/request-log", s.mgmt.PutRequestLog)
 		mgmt.PATCH("/request-log", s.mgmt.PutRequestLog)
@@ -604,6 +682,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/routing/strategy", s.mgmt.GetRoutingStrategy)
 		mgmt.PUT("/routing/strategy", s.mgmt.PutRoutingStrategy)
 		mgmt.PATCH("/routing/strategy", s.mgmt.PutRoutingStrategy)
+		mgmt.GET("/routing/session-affinity", s.mgmt.GetSessionAffinity)
 
 		mgmt.GET("/claude-api-key", s.mgmt.GetClaudeKeys)
 		mgmt.PUT("/claude-api-key", s.mgmt.PutClaudeKeys)
@@ -644,6 +723,13 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
+		mgmt.GET("/credentials", s.mgmt.ListCredentials)
+		mgmt.POST("/credentials", s.mgmt.CreateCredential)
+		mgmt.GET("/credentials/:id", s.mgmt.GetCredential)
+		mgmt.DELETE("/credentials/:id", s.mgmt.DeleteCredential)
+		mgmt.PATCH("/credentials/:id", s.mgmt.PatchCredential)
+		mgmt.POST("/credentials/:id/test", s.mgmt.TestCredential)
+
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
 		mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
 		mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
@@ -828,11 +914,13 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Stop gracefully shuts down the API server without interrupting any
-// active connections.
+// Stop gracefully shuts down the API server. It stops accepting connections,
+// refuses new requests on open connections with 503, and waits for in-flight
+// requests, including streams, until ctx is done. Connections still open then are
+// closed.
 //
 // Parameters:
-//   - ctx: The context for graceful shutdown
+//   - ctx: The context bounding how long in-flight requests are drained
 //
 // Returns:
 //   - error: An error if the server fails to stop
@@ -846,15 +934,58 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
-	// Shutdown the HTTP server.
+	s.prober.close()
+	s.draining.Store(true)
+	pending := s.inFlight.Load()
+	// Close the access log once the server is down, whether or not the drain timed out.
+	defer func() {
+		if s.accessLog != nil {
+			_ = s.accessLog.Close()
+		}
+	}()
+	// Shutdown the HTTP server, waiting for active requests to complete.
 	if err := s.server.Shutdown(ctx); err != nil {
+		forceClosed := s.inFlight.Load()
+		log.Warnf("API server drain timed out: %d in-flight request(s) drained, %d force-closed", max(pending-forceClosed, 0), forceClosed)
+		if errClose := s.server.Close(); errClose != nil {
+			return fmt.Errorf("failed to close HTTP server: %v", errClose)
+		}
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
 
-	log.Debug("API server stopped")
+	log.Infof("API server stopped: %d in-flight request(s) drained, 0 force-closed", pending)
 	return nil
 }
 
+// drainMiddleware tracks in-flight requests and, once shutdown has begun, answers
+// new requests with 503 and Retry-After so clients retry against another instance.
+func (s *Server) drainMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.draining.Load() {
+			c.Header("Retry-After", strconv.Itoa(drainRetryAfterSeconds))
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: "server is shutting down",
+					Type:    "server_error",
+				},
+			})
+			return
+		}
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// ShutdownDrainTimeout returns how long shutdown drains in-flight requests for cfg.
+func ShutdownDrainTimeout(cfg *config.Config) time.Duration {
+	if cfg == nil || cfg.ShutdownDrainTimeout <= 0 {
+		return defaultShutdownDrainTimeout
+	}
+	return time.Duration(cfg.ShutdownDrainTimeout) * time.Second
+}
+
 // corsMiddleware returns a Gin middleware handler that adds CORS headers
 // to every response, allowing cross-origin requests.
 //
@@ -920,6 +1051,48 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	}
 
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.CacheDistribution, cfg.CacheDistribution) {
+		if errDist := usage.ApplyCacheDistributionConfig(cfg); errDist != nil {
+			var errSetting *usage.DistributionError
+			if errors.As(errDist, &errSetting) {
+				log.Errorf("invalid cache-distribution %s, keeping the previous distribution: %v", errSetting.Field, errDist)
+			} else {
+				log.Errorf("failed to apply cache distribution config: %v", errDist)
+			}
+		}
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Reasoning.EffortBudgets, cfg.Reasoning.EffortBudgets) {
+		if errBudgets := thinking.SetLevelBudgets(cfg.Reasoning.EffortBudgets); errBudgets != nil {
+			log.Errorf("failed to apply reasoning effort budgets: %v", errBudgets)
+		}
+	}
+
+	if oldCfg != nil && oldCfg.AccessLog != cfg.AccessLog && s.accessLog != nil {
+		if errAccessLog := s.accessLog.Configure(cfg.AccessLog, logging.ResolveLogDirectory(cfg)); errAccessLog != nil {
+			log.Errorf("failed to reopen access log: %v", errAccessLog)
+		}
+	}
+
+	if oldCfg != nil && oldCfg.Capture != cfg.Capture && s.capturer != nil {
+		s.capturer.Configure(cfg.Capture, logging.ResolveLogDirectory(cfg))
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.RateLimit, cfg.RateLimit) || !reflect.DeepEqual(oldCfg.ClientKeys, cfg.ClientKeys) {
+		s.rateLimiter.SetConfig(cfg.RateLimit.WithClientKeys(cfg.ClientKeys))
+	}
+	s.bodyLimiter.SetLimit(int64(cfg.RequestLimits.MaxBodyMB) << 20)
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Pricing, cfg.Pricing) {
+		usage.ApplyPricingConfig(cfg)
+	}
+
+	if oldCfg == nil || oldCfg.Usage != cfg.Usage {
+		if errUsage := usage.ConfigurePersistence(cfg); errUsage != nil {
+			log.Errorf("failed to configure usage persistence: %v", errUsage)
+		}
+	}
+
 	if s.requestLogger != nil && (oldCfg == nil || oldCfg.ErrorLogsMaxFiles != cfg.ErrorLogsMaxFiles) {
 		if setter, ok := s.requestLogger.(interface{ SetErrorLogsMaxFiles(int) }); ok {
 			setter.SetErrorLogsMaxFiles(cfg.ErrorLogsMaxFiles)
@@ -930,10 +1103,17 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	}
 
+	if oldCfg == nil || oldCfg.QuotaExceeded != cfg.QuotaExceeded {
+		auth.SetQuotaBackoff(cfg.QuotaExceeded.QuotaBackoff())
+	}
+
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
 
+	s.applyMetricsConfig(cfg)
+	s.applyHealthConfig(cfg)
+
 	// Update log level dynamically when debug flag changes
 	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
 		util.SetLogLevel(cfg)
@@ -1029,6 +1209,15 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	)
 }
 
+// SetConfigReloader sets the functions used by the management API to reload the
+// config file on demand and to apply a validated config patched at runtime.
+func (s *Server) SetConfigReloader(reload func() error, apply func(*config.Config) error) {
+	if s == nil || s.mgmt == nil {
+		return
+	}
+	s.mgmt.SetConfigReloader(reload, apply)
+}
+
 func (s *Server) SetWebsocketAuthChangeHandler(fn func(bool, bool)) {
 	if s == nil {
 		return
@@ -1050,13 +1239,7 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 
 		result, err := manager.Authenticate(c.Request.Context(), c.Request)
 		if err == nil {
-			if result != nil {
-				c.Set("apiKey", result.Principal)
-				c.Set("accessProvider", result.Provider)
-				if len(result.Metadata) > 0 {
-					c.Set("accessMetadata", result.Metadata)
-				}
-			}
+			setAuthResult(c, result)
 			c.Next()
 			return
 		}
@@ -1068,3 +1251,51 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 		c.AbortWithStatusJSON(statusCode, gin.H{"error": err.Message})
 	}
 }
+
+// WebsocketAuthMiddleware authenticates WebSocket upgrade requests like AuthMiddleware,
+// except that an upgrade request without credentials is let through with an
+// openai.WebsocketAuthenticator on the context, so the client can send its API key in
+// the first frame instead.
+func WebsocketAuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if manager == nil {
+			c.Next()
+			return
+		}
+		result, err := manager.Authenticate(c.Request.Context(), c.Request)
+		switch {
+		case err == nil:
+			setAuthResult(c, result)
+		case sdkaccess.IsAuthErrorCode(err, sdkaccess.AuthErrorCodeNoCredentials) && websocket.IsWebSocketUpgrade(c.Request):
+			c.Set(openai.WebsocketAuthenticatorKey, openai.WebsocketAuthenticator(func(apiKey string) error {
+				req := c.Request.Clone(c.Request.Context())
+				req.Header.Set("Authorization", "Bearer "+apiKey)
+				result, authErr := manager.Authenticate(req.Context(), req)
+				if authErr != nil {
+					return errors.New(authErr.Message)
+				}
+				setAuthResult(c, result)
+				return nil
+			}))
+		default:
+			c.AbortWithStatusJSON(err.HTTPStatusCode(), gin.H{"error": err.Message})
+			return
+		}
+		c.Next()
+	}
+}
+
+// setAuthResult records an authenticated client on c for handlers and usage records.
+func setAuthResult(c *gin.Context, result *sdkaccess.Result) {
+	if result == nil {
+		return
+	}
+	c.Set("apiKey", result.Principal)
+	c.Set("accessProvider", result.Provider)
+	if len(result.Metadata) > 0 {
+		c.Set("accessMetadata", result.Metadata)
+	}
+	if name := result.Metadata[sdkaccess.ClientKeyMetadataKey]; name != "" {
+		c.Set("apiKeyName", name)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// newDrainTestServer serves a streaming-like /slow route that writes a first byte and
+// then holds the request open until release is closed.
+func newDrainTestServer(t *testing.T, release <-chan struct{}) (*Server, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := &Server{engine: gin.New()}
+	s.engine.Use(s.drainMiddleware())
+	s.engine.GET("/slow", func(c *gin.Context) {
+		c.Writer.WriteHeader(http.StatusOK)
+		_, _ = c.Writer.Write([]byte("data: first\n\n"))
+		c.Writer.Flush()
+		select {
+		case <-release:
+			_, _ = c.Writer.Write([]byte("data: last\n\n"))
+		case <-c.Request.Context().Done():
+		}
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s.server = &http.Server{Handler: s.engine}
+	go func() { _ = s.server.Serve(ln) }()
+	return s, "http://" + ln.Addr().String()
+}
+
+func TestDrainMiddleware_RefusesWhileDraining(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{engine: gin.New()}
+	s.engine.Use(s.drainMiddleware())
+	s.engine.GET("/ok", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	recorder := httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("status before draining = %d", recorder.Code)
+	}
+
+	s.draining.Store(true)
+	recorder = httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" {
+		t.Fatalf("status while draining = %d, Retry-After %q", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+}
+
+func TestStop_DrainsInFlightStream(t *testing.T) {
+	release := make(chan struct{})
+	s, base := newDrainTestServer(t, release)
+	resp, err := http.Get(base + "/slow")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	if s.inFlight.Load() != 1 {
+		t.Fatalf("in-flight = %d, want the open stream", s.inFlight.Load())
+	}
+	close(release)
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "data: first\n\ndata: last\n\n" {
+		t.Fatalf("stream was cut off: %q", body)
+	}
+	if err = <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestStop_ForceClosesAfterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	s, base := newDrainTestServer(t, release)
+	resp, err := http.Get(base + "/slow")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err = s.Stop(ctx); err == nil {
+		t.Fatal("expected Stop to report the drain timeout")
+	}
+	if _, err = io.ReadAll(resp.Body); err == nil {
+		t.Fatal("expected the stream to be force-closed")
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	if got := ShutdownDrainTimeout(nil); got != defaultShutdownDrainTimeout {
+		t.Fatalf("default = %s", got)
+	}
+	if got := ShutdownDrainTimeout(&proxyconfig.Config{ShutdownDrainTimeout: 90}); got != 90*time.Second {
+		t.Fatalf("configured = %s, want 90s", got)
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"testing"
 
 	gin "github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
 )
 
 func newTestServer(t *testing.T) *Server {
@@ -109,3 +111,49 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestChatCompletionsWebsocketAuth(t *testing.T) {
+	server := newTestServer(t)
+	httpServer := httptest.NewServer(server.engine)
+	defer httpServer.Close()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/v1/chat/completions"
+
+	// Plain GET requests still need credentials.
+	resp, err := http.Get(httpServer.URL + "/v1/chat/completions")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GET without key = %d, want 401", resp.StatusCode)
+	}
+
+	// A wrong key on the upgrade request is rejected before the upgrade.
+	if _, resp, err = websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer wrong"}}); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("upgrade with wrong key: err=%v resp=%v", err, resp)
+	}
+
+	authFrame := func(key string) string {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer func() { _ = conn.Close() }()
+		for _, frame := range []string{`{"type":"auth","api_key":"` + key + `"}`, `{"model":"missing-model","messages":[]}`} {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		}
+		_, reply, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return string(reply)
+	}
+	if reply := authFrame("wrong"); gjson.Get(reply, "status").Int() != http.StatusUnauthorized {
+		t.Fatalf("auth frame with wrong key = %s", reply)
+	}
+	if reply := authFrame("test-key"); gjson.Get(reply, "status").Int() == http.StatusUnauthorized {
+		t.Fatalf("auth frame with valid key = %s", reply)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !prometheus
+
+package api
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// registerTokenMetrics is a no-op unless the binary is built with the prometheus tag.
+func registerTokenMetrics(prometheus.Registerer) error { return nil }
@@ -0,0 +1,13 @@
+//go:build prometheus
+
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// registerTokenMetrics registers the token counters from usage.RegisterMetrics with reg.
+func registerTokenMetrics(reg prometheus.Registerer) error {
+	return usage.RegisterMetrics(reg)
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 )
 
@@ -73,21 +74,31 @@ type DeviceCodeResponse struct {
 //   - error: An error if the operation fails, nil otherwise
 func (ts *CopilotTokenStorage) SaveTokenToFile(authFilePath string) error {
 	misc.LogSavingCredentials(authFilePath)
-	ts.Type = "github-copilot"
-	if err := os.MkdirAll(filepath.Dir(authFilePath), 0700); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
-	}
-
-	f, err := os.Create(authFilePath)
+	data, err := ts.MarshalToken()
 	if err != nil {
-		return fmt.Errorf("failed to create token file: %w", err)
+		return err
 	}
-	defer func() {
-		_ = f.Close()
-	}()
-
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
+	if err = os.MkdirAll(filepath.Dir(authFilePath), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err = authcrypt.WriteFile(authFilePath, data, 0o600); err != nil {
 		return fmt.Errorf("failed to write token to file: %w", err)
 	}
 	return nil
 }
+
+// MarshalToken sets the provider type and serializes the Copilot token storage to JSON.
+// Token stores use it to write the content themselves instead of calling
+// SaveTokenToFile.
+//
+// Returns:
+//   - []byte: The JSON encoded token data
+//   - error: An error if encoding fails, nil otherwise
+func (ts *CopilotTokenStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "github-copilot"
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode token: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,173 @@
+// Package devicecode implements the polling half of the OAuth 2.0 device
+// authorization grant (RFC 8628) so providers with headless logins share the
+// same handling of pending, slow_down, expired and denied responses.
+package devicecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GrantType is the RFC 8628 grant type sent with device code token requests.
+const GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	// DefaultInterval is the polling interval used when the server does not provide one.
+	DefaultInterval = 5 * time.Second
+	// DefaultTimeout bounds polling when neither an expiry nor a timeout is set.
+	DefaultTimeout = 15 * time.Minute
+)
+
+var (
+	// ErrExpired is returned when the device code expires before the user approves it.
+	ErrExpired = errors.New("device code expired, please restart the login")
+	// ErrDenied is returned when the user rejects the authorization request.
+	ErrDenied = errors.New("authorization denied by user")
+	// ErrTimeout is returned when polling gives up before the server answers.
+	ErrTimeout = errors.New("timed out waiting for device authorization")
+)
+
+// slowDownStep is the interval increase required by RFC 8628 on slow_down.
+var slowDownStep = 5 * time.Second
+
+// PollRequest describes a device code token poll.
+type PollRequest struct {
+	// TokenURL is the provider token endpoint.
+	TokenURL string
+	// Form carries the provider specific parameters, such as client_id and
+	// device_code. grant_type defaults to GrantType when absent.
+	Form url.Values
+	// Interval is the initial wait between polls. Zero uses DefaultInterval.
+	Interval time.Duration
+	// ExpiresIn is the device code lifetime reported by the server. Zero means unknown.
+	ExpiresIn time.Duration
+	// Timeout caps the total polling time. Zero uses ExpiresIn, or DefaultTimeout
+	// when the expiry is unknown.
+	Timeout time.Duration
+}
+
+// Poll polls the token endpoint until the user approves the request and returns the
+// raw token response body. Transient network failures are retried on the next tick.
+func Poll(ctx context.Context, client *http.Client, req PollRequest) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	interval := req.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = req.ExpiresIn
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	var expiresAt time.Time
+	if req.ExpiresIn > 0 {
+		expiresAt = start.Add(req.ExpiresIn)
+	}
+
+	form := url.Values{}
+	for key, values := range req.Form {
+		form[key] = values
+	}
+	if form.Get("grant_type") == "" {
+		form.Set("grant_type", GrantType)
+	}
+	encoded := form.Encode()
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		body, errPoll := pollOnce(ctx, client, req.TokenURL, encoded)
+		switch {
+		case errPoll == nil:
+			return body, nil
+		case errors.Is(errPoll, errPending):
+		case errors.Is(errPoll, errSlowDown):
+			interval += slowDownStep
+		case errors.Is(errPoll, errTransient):
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		default:
+			return nil, errPoll
+		}
+
+		now := time.Now()
+		if !expiresAt.IsZero() && now.Add(interval).After(expiresAt) {
+			return nil, ErrExpired
+		}
+		if now.Add(interval).After(deadline) {
+			return nil, ErrTimeout
+		}
+		timer.Reset(interval)
+	}
+}
+
+var (
+	errPending   = errors.New("authorization pending")
+	errSlowDown  = errors.New("slow down")
+	errTransient = errors.New("transient poll failure")
+)
+
+func pollOnce(ctx context.Context, client *http.Client, tokenURL, form string) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form))
+	if err != nil {
+		return nil, fmt.Errorf("create device token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errTransient, err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errTransient, err)
+	}
+
+	var oauthErr struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &oauthErr)
+	if resp.StatusCode == http.StatusOK && oauthErr.Error == "" {
+		return body, nil
+	}
+	switch oauthErr.Error {
+	case "authorization_pending":
+		return nil, errPending
+	case "slow_down":
+		return nil, errSlowDown
+	case "expired_token":
+		return nil, ErrExpired
+	case "access_denied":
+		return nil, ErrDenied
+	case "":
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return nil, fmt.Errorf("%w: status %d", errTransient, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("device token poll failed: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	default:
+		return nil, fmt.Errorf("device token poll failed: %s - %s", oauthErr.Error, oauthErr.Description)
+	}
+}
@@ -0,0 +1,94 @@
+package devicecode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tokenServer(t *testing.T, responses ...string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != GrantType || r.Form.Get("device_code") != "dc-1" {
+			t.Errorf("unexpected poll form: %v", r.Form)
+		}
+		n := int(calls.Add(1)) - 1
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if responses[n] != `{"access_token":"at-1"}` {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		_, _ = w.Write([]byte(responses[n]))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func pollRequest(srv *httptest.Server) PollRequest {
+	return PollRequest{
+		TokenURL: srv.URL,
+		Form:     url.Values{"device_code": {"dc-1"}},
+		Interval: time.Millisecond,
+	}
+}
+
+func TestPollReturnsTokenAfterPending(t *testing.T) {
+	prevStep := slowDownStep
+	slowDownStep = time.Millisecond
+	t.Cleanup(func() { slowDownStep = prevStep })
+
+	srv, calls := tokenServer(t,
+		`{"error":"authorization_pending"}`,
+		`{"error":"slow_down"}`,
+		`{"access_token":"at-1"}`,
+	)
+	body, err := Poll(context.Background(), srv.Client(), pollRequest(srv))
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if string(body) != `{"access_token":"at-1"}` || calls.Load() != 3 {
+		t.Fatalf("Poll() = %s after %d calls", body, calls.Load())
+	}
+}
+
+func TestPollTerminalErrors(t *testing.T) {
+	cases := map[string]error{
+		`{"error":"expired_token"}`: ErrExpired,
+		`{"error":"access_denied"}`: ErrDenied,
+	}
+	for response, want := range cases {
+		srv, _ := tokenServer(t, response)
+		if _, err := Poll(context.Background(), srv.Client(), pollRequest(srv)); !errors.Is(err, want) {
+			t.Fatalf("Poll(%s) error = %v, want %v", response, err, want)
+		}
+	}
+
+	srv, _ := tokenServer(t, `{"error":"invalid_client","error_description":"bad client"}`)
+	if _, err := Poll(context.Background(), srv.Client(), pollRequest(srv)); err == nil || err.Error() != "device token poll failed: invalid_client - bad client" {
+		t.Fatalf("Poll(invalid_client) error = %v", err)
+	}
+}
+
+func TestPollGivesUpAtExpiryAndTimeout(t *testing.T) {
+	srv, _ := tokenServer(t, `{"error":"authorization_pending"}`)
+
+	req := pollRequest(srv)
+	req.ExpiresIn = 20 * time.Millisecond
+	if _, err := Poll(context.Background(), srv.Client(), req); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Poll() past expiry error = %v, want ErrExpired", err)
+	}
+
+	req = pollRequest(srv)
+	req.Timeout = 20 * time.Millisecond
+	if _, err := Poll(context.Background(), srv.Client(), req); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Poll() past timeout error = %v, want ErrTimeout", err)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/devicecode"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
@@ -53,6 +54,9 @@ type WebLoginOptions struct {
 	NoBrowser    bool
 	CallbackPort int
 	Prompt       func(string) (string, error)
+	// Device uses the OAuth device authorization grant instead of a local callback,
+	// for servers where neither a browser nor the callback port is reachable.
+	Device bool
 }
 
 // NewGeminiAuth creates a new instance of GeminiAuth.
@@ -124,7 +128,11 @@ func (g *GeminiAuth) GetAuthenticatedClient(ctx context.Context, ts *GeminiToken
 	// If no token is found in storage, initiate the web-based OAuth flow.
 	if ts.Token == nil {
 		fmt.Printf("Could not load token from file, starting OAuth flow.\n")
-		token, err = g.getTokenFromWeb(ctx, conf, opts)
+		if opts != nil && opts.Device {
+			token, err = g.getTokenFromDevice(ctx, conf)
+		} else {
+			token, err = g.getTokenFromWeb(ctx, conf, opts)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to get token from web: %w", err)
 		}
@@ -386,3 +394,67 @@ waitForCallback:
 	fmt.Println("Authentication successful.")
 	return token, nil
 }
+
+// getTokenFromDevice runs the OAuth2 device authorization grant. It prints the
+// verification URL and user code, then polls the token endpoint until the user
+// approves the request on another device, the code expires, or polling times out.
+//
+// Parameters:
+//   - ctx: The context for the HTTP client
+//   - config: The OAuth2 configuration
+//
+// Returns:
+//   - *oauth2.Token: The OAuth2 token obtained from the device flow
+//   - error: An error if the token acquisition fails, nil otherwise
+func (g *GeminiAuth) getTokenFromDevice(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	deviceAuth, err := config.DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %w", err)
+	}
+
+	fmt.Printf("To sign in, open this URL on any device:\n\n%s\n\nand enter the code: %s\n\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
+	fmt.Println("Waiting for device authorization...")
+
+	var expiresIn time.Duration
+	if !deviceAuth.Expiry.IsZero() {
+		expiresIn = time.Until(deviceAuth.Expiry)
+	}
+	httpClient, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	body, err := devicecode.Poll(ctx, httpClient, devicecode.PollRequest{
+		TokenURL: config.Endpoint.TokenURL,
+		Form: url.Values{
+			"client_id":     {config.ClientID},
+			"client_secret": {config.ClientSecret},
+			"device_code":   {deviceAuth.DeviceCode},
+		},
+		Interval:  time.Duration(deviceAuth.Interval) * time.Second,
+		ExpiresIn: expiresIn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("device token response missing access_token")
+	}
+	token := &oauth2.Token{
+		AccessToken:  tokenResp.AccessToken,
+		TokenType:    tokenResp.TokenType,
+		RefreshToken: tokenResp.RefreshToken,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+
+	fmt.Println("Authentication successful.")
+	return token, nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 )
 
@@ -26,19 +27,25 @@ type IFlowTokenStorage struct {
 // SaveTokenToFile serialises the token storage to disk.
 func (ts *IFlowTokenStorage) SaveTokenToFile(authFilePath string) error {
 	misc.LogSavingCredentials(authFilePath)
-	ts.Type = "iflow"
-	if err := os.MkdirAll(filepath.Dir(authFilePath), 0o700); err != nil {
+	data, err := ts.MarshalToken()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(authFilePath), 0o700); err != nil {
 		return fmt.Errorf("iflow token: create directory failed: %w", err)
 	}
-
-	f, err := os.Create(authFilePath)
-	if err != nil {
-		return fmt.Errorf("iflow token: create file failed: %w", err)
+	if err = authcrypt.WriteFile(authFilePath, data, 0o600); err != nil {
+		return fmt.Errorf("iflow token: write file failed: %w", err)
 	}
-	defer func() { _ = f.Close() }()
+	return nil
+}
 
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
-		return fmt.Errorf("iflow token: encode token failed: %w", err)
+// MarshalToken tags the storage as iflow and returns the JSON written by SaveTokenToFile.
+func (ts *IFlowTokenStorage) MarshalToken() ([]byte, error) {
+	ts.Type = "iflow"
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return nil, fmt.Errorf("iflow token: encode token failed: %w", err)
 	}
-	return nil
+	return data, nil
 }
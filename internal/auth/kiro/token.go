@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 )
 
 // KiroTokenStorage holds the persistent token data for Kiro authentication.
@@ -44,21 +46,30 @@ func (s *KiroTokenStorage) SaveTokenToFile(authFilePath string) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(s, "", "  ")
+	data, err := s.MarshalToken()
 	if err != nil {
-		return fmt.Errorf("failed to marshal token storage: %w", err)
+		return err
 	}
 
-	if err := os.WriteFile(authFilePath, data, 0600); err != nil {
+	if err := authcrypt.WriteFile(authFilePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write token file: %w", err)
 	}
 
 	return nil
 }
 
+// MarshalToken returns the indented JSON that SaveTokenToFile writes.
+func (s *KiroTokenStorage) MarshalToken() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal token storage: %w", err)
+	}
+	return data, nil
+}
+
 // LoadFromFile loads token storage from the specified file path.
 func LoadFromFile(authFilePath string) (*KiroTokenStorage, error) {
-	data, err := os.ReadFile(authFilePath)
+	data, err := authcrypt.ReadFile(authFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read token file: %w", err)
 	}
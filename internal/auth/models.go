@@ -15,3 +15,11 @@ type TokenStorage interface {
 	//   - error: An error if the save operation fails, nil otherwise
 	SaveTokenToFile(authFilePath string) error
 }
+
+// TokenMarshaler is implemented by token storages that can return the content
+// SaveTokenToFile would write, letting token stores encrypt or upload it
+// without writing a plaintext copy to disk first.
+type TokenMarshaler interface {
+	// MarshalToken returns the serialized token data.
+	MarshalToken() ([]byte, error)
+}
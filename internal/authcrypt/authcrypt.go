@@ -0,0 +1,149 @@
+// Package authcrypt encrypts auth files at rest with a process-wide master key.
+//
+// Encrypted files start with a versioned header line followed by the base64 encoded
+// AES-256-GCM nonce and ciphertext. Files without the header are treated as
+// plaintext so existing auth directories keep loading; they are encrypted the next
+// time they are saved while a key is configured.
+package authcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// KeyEnv names the environment variable consulted when no key is configured.
+const KeyEnv = "CLIPROXY_AUTH_ENCRYPTION_KEY"
+
+// header marks an encrypted auth file and records the format version.
+const header = "CLIPROXYAPI-ENCRYPTED v1\n"
+
+var (
+	// ErrKeyRequired is returned when an encrypted file is read without a configured key.
+	ErrKeyRequired = errors.New("authcrypt: file is encrypted but no encryption key is configured")
+	// ErrWrongKey is returned when an encrypted file cannot be opened with the configured key.
+	ErrWrongKey = errors.New("authcrypt: wrong encryption key or corrupted file")
+)
+
+var current atomic.Pointer[cipher.AEAD]
+
+// SetKey installs the master key used for auth files. The AES-256 key is the SHA-256
+// digest of secret, so any high-entropy string works. An empty secret disables
+// encryption for subsequent saves.
+func SetKey(secret string) error {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		current.Store(nil)
+		return nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return fmt.Errorf("authcrypt: create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("authcrypt: create gcm: %w", err)
+	}
+	current.Store(&aead)
+	return nil
+}
+
+// ResolveKey returns the configured master key: key when set, otherwise the trimmed
+// contents of keyFile, otherwise the KeyEnv environment variable. It returns "" when
+// none is set.
+func ResolveKey(key, keyFile string) (string, error) {
+	if key = strings.TrimSpace(key); key != "" {
+		return key, nil
+	}
+	if keyFile = strings.TrimSpace(keyFile); keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("authcrypt: read key file: %w", err)
+		}
+		if key = strings.TrimSpace(string(data)); key == "" {
+			return "", fmt.Errorf("authcrypt: key file %s is empty", keyFile)
+		}
+		return key, nil
+	}
+	return strings.TrimSpace(os.Getenv(KeyEnv)), nil
+}
+
+// Enabled reports whether a master key is configured.
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// IsEncrypted reports whether data carries the encrypted file header.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header))
+}
+
+// Encrypt seals plain with the configured key. Without a key, or when plain is
+// already encrypted, it is returned unchanged.
+func Encrypt(plain []byte) ([]byte, error) {
+	aead := current.Load()
+	if aead == nil || IsEncrypted(plain) {
+		return plain, nil
+	}
+	nonce := make([]byte, (*aead).NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate nonce: %w", err)
+	}
+	sealed := (*aead).Seal(nonce, nonce, plain, []byte(header))
+	out := make([]byte, 0, len(header)+base64.StdEncoding.EncodedLen(len(sealed))+1)
+	out = append(out, header...)
+	out = base64.StdEncoding.AppendEncode(out, sealed)
+	return append(out, '\n'), nil
+}
+
+// Decrypt opens data sealed by Encrypt. Plaintext data is returned unchanged.
+func Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	aead := current.Load()
+	if aead == nil {
+		return nil, ErrKeyRequired
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(header):])))
+	if err != nil || len(sealed) < (*aead).NonceSize() {
+		return nil, ErrWrongKey
+	}
+	nonceSize := (*aead).NonceSize()
+	plain, err := (*aead).Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(header))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+// ReadFile reads and decrypts the auth file at path. Decryption errors name the file.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
+
+// WriteFile encrypts plain when a key is configured and writes it to path with perm.
+func WriteFile(path string, plain []byte, perm os.FileMode) error {
+	data, err := Encrypt(plain)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
@@ -0,0 +1,116 @@
+package authcrypt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useKey(t *testing.T, secret string) {
+	t.Helper()
+	if err := SetKey(secret); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	t.Cleanup(func() { _ = SetKey("") })
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	useKey(t, "master-key")
+	plain := []byte(`{"type":"claude","refresh_token":"rt-secret"}`)
+
+	sealed, err := Encrypt(plain)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(string(sealed), "rt-secret") {
+		t.Fatalf("Encrypt() output is not sealed: %q", sealed)
+	}
+	if again, _ := Encrypt(sealed); string(again) != string(sealed) {
+		t.Fatal("Encrypt() re-encrypted an already encrypted payload")
+	}
+	opened, err := Decrypt(sealed)
+	if err != nil || string(opened) != string(plain) {
+		t.Fatalf("Decrypt() = %q, %v; want %q", opened, err, plain)
+	}
+}
+
+func TestDecryptPlaintextPassesThrough(t *testing.T) {
+	plain := []byte(`{"type":"gemini"}`)
+	if got, err := Decrypt(plain); err != nil || string(got) != string(plain) {
+		t.Fatalf("Decrypt(plaintext) without key = %q, %v", got, err)
+	}
+	if got, _ := Encrypt(plain); string(got) != string(plain) {
+		t.Fatal("Encrypt() without key changed the payload")
+	}
+	useKey(t, "master-key")
+	if got, err := Decrypt(plain); err != nil || string(got) != string(plain) {
+		t.Fatalf("Decrypt(plaintext) with key = %q, %v", got, err)
+	}
+}
+
+func TestReadFileNamesFileOnKeyErrors(t *testing.T) {
+	useKey(t, "right-key")
+	sealed, err := Encrypt([]byte(`{"type":"codex"}`))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "codex-user.json")
+	if err = os.WriteFile(path, sealed, 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	useKey(t, "wrong-key")
+	_, err = ReadFile(path)
+	if !errors.Is(err, ErrWrongKey) || !strings.Contains(err.Error(), "codex-user.json") {
+		t.Fatalf("ReadFile() with wrong key error = %v, want ErrWrongKey naming the file", err)
+	}
+
+	useKey(t, "")
+	if _, err = ReadFile(path); !errors.Is(err, ErrKeyRequired) {
+		t.Fatalf("ReadFile() without key error = %v, want ErrKeyRequired", err)
+	}
+}
+
+func TestResolveKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	t.Setenv(KeyEnv, "from-env")
+
+	if got, _ := ResolveKey(" inline ", keyFile); got != "inline" {
+		t.Fatalf("ResolveKey(inline) = %q, want %q", got, "inline")
+	}
+	if got, _ := ResolveKey("", keyFile); got != "from-file" {
+		t.Fatalf("ResolveKey(file) = %q, want %q", got, "from-file")
+	}
+	if got, _ := ResolveKey("", ""); got != "from-env" {
+		t.Fatalf("ResolveKey(env) = %q, want %q", got, "from-env")
+	}
+	if _, err := ResolveKey("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("ResolveKey(missing file) error = nil, want error")
+	}
+}
+
+func TestWriteFileEncryptsWithKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kiro-user.json")
+	if err := WriteFile(path, []byte(`{"type":"kiro"}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() without key error = %v", err)
+	}
+	if raw, _ := os.ReadFile(path); string(raw) != `{"type":"kiro"}` {
+		t.Fatalf("WriteFile() without key wrote %q, want plaintext", raw)
+	}
+
+	useKey(t, "master-key")
+	if err := WriteFile(path, []byte(`{"type":"kiro"}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if raw, _ := os.ReadFile(path); !IsEncrypted(raw) {
+		t.Fatalf("WriteFile() with key wrote %q, want encrypted", raw)
+	}
+	if plain, err := ReadFile(path); err != nil || string(plain) != `{"type":"kiro"}` {
+		t.Fatalf("ReadFile() = %q, %v", plain, err)
+	}
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ResponseEntry is a cached upstream response: the body of a non-streaming response,
+// or the chunks of a streaming one, together with the providers that served it.
+type ResponseEntry struct {
+	Payload   []byte
+	Chunks    [][]byte
+	Providers []string
+}
+
+// size returns the number of response bytes held by e.
+func (e ResponseEntry) size() int64 {
+	n := int64(len(e.Payload))
+	for _, chunk := range e.Chunks {
+		n += int64(len(chunk))
+	}
+	return n
+}
+
+// ResponseCacheStats is a snapshot of a ResponseCache's counters.
+type ResponseCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions counts entries removed before being replaced: expired ones and those
+	// dropped to stay within the entry and size bounds.
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+}
+
+// ResponseCache is an in-memory LRU cache of upstream responses, bounded by entry
+// count and total response bytes, whose entries expire after a TTL.
+type ResponseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	items      map[string]*list.Element
+	// order holds the entries from most to least recently used.
+	order *list.List
+	bytes int64
+	stats ResponseCacheStats
+	now   func() time.Time
+}
+
+type responseCacheItem struct {
+	key       string
+	entry     ResponseEntry
+	expiresAt time.Time
+	size      int64
+}
+
+// NewResponseCache creates a cache with the given bounds; see SetLimits.
+func NewResponseCache(ttl time.Duration, maxEntries int, maxBytes int64) *ResponseCache {
+	c := &ResponseCache{items: make(map[string]*list.Element), order: list.New(), now: time.Now}
+	c.SetLimits(ttl, maxEntries, maxBytes)
+	return c
+}
+
+// SetLimits changes the TTL and bounds of the cache and evicts entries until it fits
+// them. Non-positive values disable caching: Put stores nothing.
+func (c *ResponseCache) SetLimits(ttl time.Duration, maxEntries int, maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.maxEntries, c.maxBytes = ttl, maxEntries, maxBytes
+	c.shrink()
+}
+
+// Get returns the unexpired entry stored under key, counting a hit or a miss.
+func (c *ResponseCache) Get(key string) (ResponseEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return ResponseEntry{}, false
+	}
+	item := elem.Value.(*responseCacheItem)
+	if !c.now().Before(item.expiresAt) {
+		c.remove(elem)
+		c.stats.Misses++
+		return ResponseEntry{}, false
+	}
+	c.order.MoveToFront(elem)
+	c.stats.Hits++
+	return item.entry, true
+}
+
+// Put stores entry under key, replacing any previous entry, and evicts the least
+// recently used entries beyond the bounds. It reports false when caching is disabled
+// or entry alone exceeds the size bound. The cache keeps entry's slices, which must
+// not be modified afterwards.
+func (c *ResponseCache) Put(key string, entry ResponseEntry) bool {
+	size := entry.size()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 || c.maxEntries <= 0 || size > c.maxBytes {
+		return false
+	}
+	if elem, ok := c.items[key]; ok {
+		c.bytes -= elem.Value.(*responseCacheItem).size
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+	item := &responseCacheItem{key: key, entry: entry, expiresAt: c.now().Add(c.ttl), size: size}
+	c.items[key] = c.order.PushFront(item)
+	c.bytes += size
+	c.shrink()
+	return true
+}
+
+// Purge removes every entry without counting evictions.
+func (c *ResponseCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+// Stats returns the cache's counters and current size.
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.items)
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// shrink evicts least recently used entries while the cache exceeds its bounds or
+// its least recently used entry has expired. c.mu must be held.
+func (c *ResponseCache) shrink() {
+	now := c.now()
+	for elem := c.order.Back(); elem != nil; elem = c.order.Back() {
+		expired := !now.Before(elem.Value.(*responseCacheItem).expiresAt)
+		if !expired && c.order.Len() <= c.maxEntries && c.bytes <= c.maxBytes {
+			return
+		}
+		c.remove(elem)
+	}
+}
+
+// remove evicts elem. c.mu must be held.
+func (c *ResponseCache) remove(elem *list.Element) {
+	item := elem.Value.(*responseCacheItem)
+	c.order.Remove(elem)
+	delete(c.items, item.key)
+	c.bytes -= item.size
+	c.stats.Evictions++
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCache_LRUAndSizeBounds(t *testing.T) {
+	c := NewResponseCache(time.Minute, 2, 10)
+	c.Put("a", ResponseEntry{Payload: []byte("1234")})
+	c.Put("b", ResponseEntry{Chunks: [][]byte{[]byte("12"), []byte("34")}})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing")
+	}
+	// a was used last, so b is evicted for c.
+	c.Put("c", ResponseEntry{Payload: []byte("12")})
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	// 4 + 2 + 6 bytes exceed the 10 byte bound, evicting a.
+	c.Put("d", ResponseEntry{Payload: []byte("123456")})
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("size bound not enforced")
+	}
+	if c.Put("big", ResponseEntry{Payload: make([]byte, 11)}) {
+		t.Fatal("stored an entry larger than the size bound")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Evictions != 2 || stats.Entries != 2 || stats.Bytes != 8 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestResponseCache_TTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewResponseCache(time.Minute, 10, 100)
+	c.now = func() time.Time { return now }
+	c.Put("a", ResponseEntry{Payload: []byte("x")})
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("entry expired early")
+	}
+	now = now.Add(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expired entry returned")
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestResponseCache_Disabled(t *testing.T) {
+	c := NewResponseCache(time.Minute, 10, 100)
+	c.Put("a", ResponseEntry{Payload: []byte("x")})
+	c.SetLimits(0, 0, 0)
+	if c.Put("b", ResponseEntry{Payload: []byte("y")}) {
+		t.Fatal("disabled cache stored an entry")
+	}
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Fatalf("disabled cache kept %d entries", stats.Entries)
+	}
+}
@@ -0,0 +1,37 @@
+// Package cmd contains CLI helpers. This file implements bulk encryption of an
+// existing auth directory with the configured auth encryption key.
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoMigrateAuthEncryption encrypts every plaintext auth file in cfg.AuthDir. The
+// encryption key must already be installed from the configuration. The process
+// exits with status 1 when the migration cannot run or any file fails.
+func DoMigrateAuthEncryption(cfg *config.Config) {
+	result, err := sdkAuth.MigrateAuthEncryption(cfg.AuthDir)
+	if err != nil {
+		log.Errorf("migrate-auth-encryption: %v", err)
+		os.Exit(1)
+	}
+	failed := make([]string, 0, len(result.Failed))
+	for path := range result.Failed {
+		failed = append(failed, path)
+	}
+	sort.Strings(failed)
+	for _, path := range failed {
+		log.Errorf("migrate-auth-encryption: %s: %v", path, result.Failed[path])
+	}
+	if len(failed) > 0 {
+		log.Errorf("migrate-auth-encryption: %d encrypted, %d already encrypted, %d failed: %s", result.Encrypted, result.AlreadyEncrypted, len(failed), strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+	log.Infof("migrate-auth-encryption: %d encrypted, %d already encrypted, 0 failed", result.Encrypted, result.AlreadyEncrypted)
+}
@@ -72,6 +72,7 @@ func DoLogin(cfg *config.Config, projectID string, options *LoginOptions) {
 		CallbackPort: options.CallbackPort,
 		Metadata:     map[string]string{},
 		Prompt:       callbackPrompt,
+		Device:       options.Device,
 	}
 
 	authenticator := sdkAuth.NewGeminiAuthenticator()
@@ -92,6 +93,7 @@ func DoLogin(cfg *config.Config, projectID string, options *LoginOptions) {
 		NoBrowser:    options.NoBrowser,
 		CallbackPort: options.CallbackPort,
 		Prompt:       callbackPrompt,
+		Device:       options.Device,
 	})
 	if errClient != nil {
 		log.Errorf("Gemini authentication failed: %v", errClient)
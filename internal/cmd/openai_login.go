@@ -24,6 +24,9 @@ type LoginOptions struct {
 
 	// Prompt allows the caller to provide interactive input when needed.
 	Prompt func(prompt string) (string, error)
+
+	// Device selects the OAuth device authorization flow for headless logins.
+	Device bool
 }
 
 // DoCodexLogin triggers the Codex OAuth flow through the shared authentication manager.
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigCacheDistribution(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "cache-distribution:\n  Kiro:\n    input-part: 1\n    creation-part: 3\n    read-part: 20\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	got, ok := cfg.CacheDistribution["kiro"]
+	if !ok {
+		t.Fatalf("expected normalized kiro entry, got %+v", cfg.CacheDistribution)
+	}
+	if got.InputPart != 1 || got.CreationPart != 3 || got.ReadPart != 20 || got.Threshold != 0 {
+		t.Fatalf("unexpected cache distribution: %+v", got)
+	}
+}
+
+func TestLoadConfigCacheDistributionRejectsNonPositiveParts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "cache-distribution:\n  kiro:\n    input-part: 0\n    creation-part: 2\n    read-part: 25\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("expected error for zero input-part")
+	}
+	if !strings.Contains(err.Error(), "cache-distribution") {
+		t.Fatalf("expected cache-distribution error, got %v", err)
+	}
+}
+
+func TestLoadConfigOptionalKeepsConfigOnInvalidCacheDistribution(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "api-keys:\n  - client-key\ncache-distribution:\n  kiro:\n    input-part: 0\n    creation-part: 2\n    read-part: 25\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfigOptional(path, true)
+	if err != nil {
+		t.Fatalf("LoadConfigOptional: %v", err)
+	}
+	if len(cfg.APIKeys) != 1 || cfg.APIKeys[0] != "client-key" {
+		t.Fatalf("api-keys = %v, want the configured key", cfg.APIKeys)
+	}
+	if cfg.CacheDistribution != nil {
+		t.Fatalf("expected the invalid cache distribution to be dropped, got %+v", cfg.CacheDistribution)
+	}
+}
+
+func TestLoadConfigCacheDistributionFractionalParts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "cache-distribution:\n  kiro:\n    input-part: 0.1\n    creation-part: 0.3\n    read-part: 9.6\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.CacheDistribution["kiro"]; got.InputPart != 0.1 || got.CreationPart != 0.3 || got.ReadPart != 9.6 {
+		t.Fatalf("unexpected cache distribution: %+v", got)
+	}
+}
+
+func TestLoadConfigCacheDistributionPassThrough(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "cache-distribution:\n  kiro:\n    pass-through: true\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected a pass-through entry without ratio parts to load, got %v", err)
+	}
+	if got := cfg.CacheDistribution["kiro"]; !got.PassThrough {
+		t.Fatalf("expected pass-through kiro entry, got %+v", got)
+	}
+}
@@ -9,9 +9,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
@@ -32,6 +36,10 @@ type Config struct {
 	// Port is the network port on which the API server will listen.
 	Port int `yaml:"port" json:"-"`
 
+	// ShutdownDrainTimeout is how long, in seconds, shutdown waits for in-flight requests
+	// (including streams) to finish before closing their connections. <= 0 uses 30.
+	ShutdownDrainTimeout int `yaml:"shutdown-drain-timeout,omitempty" json:"shutdown-drain-timeout,omitempty"`
+
 	// TLS config controls HTTPS server settings.
 	TLS TLSConfig `yaml:"tls" json:"tls"`
 
@@ -41,12 +49,21 @@ type Config struct {
 	// AuthDir is the directory where authentication token files are stored.
 	AuthDir string `yaml:"auth-dir" json:"-"`
 
+	// AuthEncryption configures at-rest encryption of auth files.
+	AuthEncryption AuthEncryptionConfig `yaml:"auth-encryption" json:"-"`
+
 	// Debug enables or disables debug-level logging and other debug features.
 	Debug bool `yaml:"debug" json:"debug"`
 
 	// Pprof config controls the optional pprof HTTP debug server.
 	Pprof PprofConfig `yaml:"pprof" json:"pprof"`
 
+	// Metrics controls the Prometheus metrics endpoint.
+	Metrics MetricsConfig `yaml:"metrics" json:"metrics"`
+
+	// Health configures provider readiness reported by /readyz.
+	Health HealthConfig `yaml:"health" json:"health"`
+
 	// CommercialMode disables high-overhead HTTP middleware features to minimize per-request memory usage.
 	CommercialMode bool `yaml:"commercial-mode" json:"commercial-mode"`
 
@@ -61,9 +78,32 @@ type Config struct {
 	// When exceeded, the oldest error log files are deleted. Default is 10. Set to 0 to disable cleanup.
 	ErrorLogsMaxFiles int `yaml:"error-logs-max-files" json:"error-logs-max-files"`
 
+	// AccessLog configures the structured JSON access log.
+	AccessLog AccessLogConfig `yaml:"access-log" json:"access-log"`
+
+	// Capture configures per-request debug capture bundles.
+	Capture CaptureConfig `yaml:"capture" json:"capture"`
+
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// CacheDistribution configures the simulated prompt cache token split per provider.
+	// Keys are provider identifiers (e.g. "kiro"); providers without an entry use the default 1:2:25 split.
+	CacheDistribution map[string]CacheDistribution `yaml:"cache-distribution,omitempty" json:"cache-distribution,omitempty"`
+
+	// Usage configures how per-request usage records are stored.
+	Usage UsageConfig `yaml:"usage" json:"usage"`
+
+	// Pricing configures list prices used to estimate the cost of each request.
+	Pricing PricingConfig `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+
+	// RateLimit configures per-client-API-key request and token budgets.
+	RateLimit RateLimitConfig `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+
+	// ProxySelfTest logs the proxy route of every configured provider at startup and
+	// checks that its upstream is reachable through it.
+	ProxySelfTest bool `yaml:"proxy-self-test" json:"proxy-self-test"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -72,6 +112,10 @@ type Config struct {
 	// MaxRetryInterval defines the maximum wait time in seconds before retrying a cooled-down credential.
 	MaxRetryInterval int `yaml:"max-retry-interval" json:"max-retry-interval"`
 
+	// RetryPolicies configures per-provider retries of transient upstream failures and
+	// request hedging. Keys are provider identifiers; "*" applies to providers without an entry.
+	RetryPolicies map[string]RetryPolicy `yaml:"retry-policies,omitempty" json:"retry-policies,omitempty"`
+
 	// QuotaExceeded defines the behavior when a quota is exceeded.
 	QuotaExceeded QuotaExceeded `yaml:"quota-exceeded" json:"quota-exceeded"`
 
@@ -148,6 +192,69 @@ type PprofConfig struct {
 	Addr string `yaml:"addr" json:"addr"`
 }
 
+// AccessLogConfig holds settings for the structured access log, which writes one JSON
+// object per request. Credential material is always redacted.
+type AccessLogConfig struct {
+	// Enable toggles the access log.
+	Enable bool `yaml:"enable" json:"enable"`
+	// File is the access log path. Empty writes access.log in the logs directory.
+	File string `yaml:"file" json:"file"`
+	// MaxSizeMB is the size at which the file is rotated. <= 0 uses 10.
+	MaxSizeMB int `yaml:"max-size-mb" json:"max-size-mb"`
+	// MaxBackups is the number of rotated files kept. <= 0 keeps all of them.
+	MaxBackups int `yaml:"max-backups" json:"max-backups"`
+	// Stdout also writes every record to stdout.
+	Stdout bool `yaml:"stdout" json:"stdout"`
+	// LogBodies adds the request and response bodies to each record.
+	LogBodies bool `yaml:"log-bodies" json:"log-bodies"`
+	// MaxBodyBytes caps each logged body. <= 0 uses 4096.
+	MaxBodyBytes int `yaml:"max-body-bytes" json:"max-body-bytes"`
+}
+
+// CaptureConfig holds settings for debug capture, which writes a bundle per request with
+// the client request, the translated upstream request, the raw upstream response and the
+// translated client response. Secrets are redacted from every file.
+type CaptureConfig struct {
+	// Enable captures every request.
+	Enable bool `yaml:"enable" json:"enable"`
+	// AllowHeader lets clients capture single requests by sending X-CLIProxy-Capture: 1.
+	// The header is ignored while this is false.
+	AllowHeader bool `yaml:"allow-header" json:"allow-header"`
+	// Dir is the capture directory. Empty uses "captures" in the logs directory.
+	Dir string `yaml:"dir" json:"dir"`
+	// MaxBodyBytes caps each captured file; longer bodies are cut with a marker. <= 0 uses 1 MiB.
+	MaxBodyBytes int `yaml:"max-body-bytes" json:"max-body-bytes"`
+	// MaxCaptures is the number of bundles kept; older ones are pruned. <= 0 uses 100.
+	MaxCaptures int `yaml:"max-captures" json:"max-captures"`
+}
+
+// MetricsConfig holds Prometheus metrics settings.
+type MetricsConfig struct {
+	// Enable exposes GET /metrics on the API listener.
+	Enable bool `yaml:"enable" json:"enable"`
+}
+
+// HealthConfig configures provider readiness reported by GET /readyz.
+type HealthConfig struct {
+	// ProbeIntervalSeconds is how often upstream probes run. Defaults to 60.
+	ProbeIntervalSeconds int `yaml:"probe-interval-seconds,omitempty" json:"probe-interval-seconds,omitempty"`
+	// ProbeTimeoutSeconds bounds a single upstream probe. Defaults to 10.
+	ProbeTimeoutSeconds int `yaml:"probe-timeout-seconds,omitempty" json:"probe-timeout-seconds,omitempty"`
+	// Providers holds readiness settings keyed by provider ("claude", "gemini", ...).
+	Providers map[string]ProviderHealthConfig `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// ProviderHealthConfig holds the readiness settings of one provider.
+type ProviderHealthConfig struct {
+	// Required makes /readyz answer 503 while the provider is not ready.
+	Required bool `yaml:"required" json:"required"`
+	// Probe enables a periodic lightweight upstream request with one of the provider's
+	// available credentials; its cached result is part of the provider's readiness.
+	Probe bool `yaml:"probe" json:"probe"`
+	// ProbeModel is the model probed. Defaults to the first model of the probed credential.
+	ProbeModel string `yaml:"probe-model,omitempty" json:"probe-model,omitempty"`
+}
+
 // RemoteManagement holds management API configuration under 'remote-management'.
 type RemoteManagement struct {
 	// AllowRemote toggles remote (non-localhost) access to management API.
@@ -161,6 +268,15 @@ type RemoteManagement struct {
 	PanelGitHubRepository string `yaml:"panel-github-repository"`
 }
 
+// AuthEncryptionConfig holds the master key used to encrypt auth files at rest.
+// When neither field is set the CLIPROXY_AUTH_ENCRYPTION_KEY environment variable is used.
+type AuthEncryptionConfig struct {
+	// Key is the master key itself.
+	Key string `yaml:"key"`
+	// KeyFile is the path of a file containing the master key. Ignored when Key is set.
+	KeyFile string `yaml:"key-file"`
+}
+
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
 // It provides configuration options for automatic failover mechanisms.
 type QuotaExceeded struct {
@@ -169,13 +285,191 @@ type QuotaExceeded struct {
 
 	// SwitchPreviewModel indicates whether to automatically switch to a preview model when a quota is exceeded.
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
+
+	// CooldownSeconds is the initial time a quota-exhausted credential stays parked when the
+	// upstream reports no reset time. It doubles on each consecutive quota error. Zero uses 1 second.
+	CooldownSeconds int `yaml:"cooldown-seconds,omitempty" json:"cooldown-seconds,omitempty"`
+
+	// MaxCooldownSeconds caps the doubling cooldown. Zero uses 30 minutes.
+	MaxCooldownSeconds int `yaml:"max-cooldown-seconds,omitempty" json:"max-cooldown-seconds,omitempty"`
+}
+
+// QuotaBackoff returns the configured quota cooldown base and maximum.
+func (q QuotaExceeded) QuotaBackoff() (time.Duration, time.Duration) {
+	return time.Duration(q.CooldownSeconds) * time.Second, time.Duration(q.MaxCooldownSeconds) * time.Second
 }
 
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "weighted", "least-loaded".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// ProviderStrategies overrides Strategy for individual providers, keyed by provider
+	// name (e.g. "gemini-cli", "kiro").
+	ProviderStrategies map[string]string `yaml:"provider-strategies,omitempty" json:"provider-strategies,omitempty"`
+
+	// SessionAffinity pins each conversation to the credential that served it.
+	SessionAffinity SessionAffinityConfig `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+}
+
+// SessionAffinityConfig keeps consecutive requests of a conversation on the same
+// credential so upstream prompt caches are reused.
+type SessionAffinityConfig struct {
+	// Enabled turns session pinning on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// TTLSeconds is how long an unused pin is kept. Zero selects 1800.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+	// MaxEntries caps the pin table; the least recently used pins are evicted. Zero selects 10000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// TTL returns TTLSeconds as a duration.
+func (s SessionAffinityConfig) TTL() time.Duration {
+	return time.Duration(s.TTLSeconds) * time.Second
+}
+
+// Usage persistence backends accepted by UsageConfig.Persistence.
+const (
+	UsagePersistenceMemory = "memory"
+	UsagePersistenceSQLite = "sqlite"
+)
+
+// DefaultUsageSQLitePath is the database file used when usage.sqlite-path is empty.
+const DefaultUsageSQLitePath = "usage.db"
+
+// UsageConfig configures how per-request usage records are stored.
+type UsageConfig struct {
+	// Persistence selects the usage store backend.
+	// Supported values: "memory" (default), "sqlite".
+	Persistence string `yaml:"persistence,omitempty" json:"persistence,omitempty"`
+	// SQLitePath is the database file used when Persistence is "sqlite".
+	SQLitePath string `yaml:"sqlite-path,omitempty" json:"sqlite-path,omitempty"`
+	// RetentionDays prunes persisted records older than the given number of days. Zero keeps all records.
+	RetentionDays int `yaml:"retention-days,omitempty" json:"retention-days,omitempty"`
+}
+
+// RateLimitConfig defines sliding one-minute budgets for client API keys.
+type RateLimitConfig struct {
+	// Default applies to authenticated keys without an entry in Keys. Zero limits disable it.
+	Default APIKeyRateLimit `yaml:"default,omitempty" json:"default,omitempty"`
+	// Keys overrides the default budget for individual client API keys.
+	Keys []APIKeyRateLimit `yaml:"keys,omitempty" json:"keys,omitempty"`
+	// Profiles holds named budgets that client-keys entries refer to by name.
+	Profiles map[string]APIKeyRateLimit `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+}
+
+// WithClientKeys returns c with a Keys entry, keyed by the client key name, for every
+// client key that refers to a profile. Entries already in Keys win.
+func (c RateLimitConfig) WithClientKeys(keys []ClientKey) RateLimitConfig {
+	if len(keys) == 0 || len(c.Profiles) == 0 {
+		return c
+	}
+	explicit := make(map[string]struct{}, len(c.Keys))
+	for _, limit := range c.Keys {
+		explicit[limit.APIKey] = struct{}{}
+	}
+	out := append([]APIKeyRateLimit(nil), c.Keys...)
+	for _, key := range keys {
+		profile, ok := c.Profiles[key.RateLimit]
+		if _, dup := explicit[key.Name]; !ok || dup || key.RateLimit == "" {
+			continue
+		}
+		profile.APIKey = key.Name
+		out = append(out, profile)
+	}
+	c.Keys = out
+	return c
+}
+
+// APIKeyRateLimit defines the budget of a single client API key. Zero disables a limit.
+type APIKeyRateLimit struct {
+	// APIKey is the client API key from api-keys, or the name of a client-keys entry.
+	// It is ignored for the default budget and for profiles.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+	// RequestsPerMinute caps the number of requests started in any 60 second window.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+	// TokensPerMinute caps input plus output tokens consumed in any 60 second window.
+	TokensPerMinute int64 `yaml:"tokens-per-minute,omitempty" json:"tokens-per-minute,omitempty"`
+}
+
+// Retry conditions accepted in RetryPolicy.RetryOn besides HTTP status codes.
+const (
+	// RetryOnConnectionReset retries connections reset or closed by the upstream.
+	RetryOnConnectionReset = "connection-reset"
+	// RetryOnTimeout retries network timeouts and 408 responses.
+	RetryOnTimeout = "timeout"
+	// RetryOnStreamCut retries streams that fail or end before emitting any content.
+	RetryOnStreamCut = "stream-cut"
+	// RetryOnServerError retries every 5xx response.
+	RetryOnServerError = "5xx"
+)
+
+// DefaultRetryOn lists the conditions retried when a policy sets none.
+var DefaultRetryOn = []string{RetryOnConnectionReset, RetryOnTimeout, RetryOnStreamCut, "502", "503", "504"}
+
+// RetryPolicy controls how requests to one provider are retried and hedged. Retries
+// repeat the attempt on the same credential before the manager moves on to the next one.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per credential, including the first.
+	// Values <= 1 disable retries.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+	// BaseDelayMs is the delay before the first retry; it doubles on every further retry.
+	BaseDelayMs int `yaml:"base-delay-ms,omitempty" json:"base-delay-ms,omitempty"`
+	// MaxDelayMs caps the delay between retries.
+	MaxDelayMs int `yaml:"max-delay-ms,omitempty" json:"max-delay-ms,omitempty"`
+	// Jitter randomizes each delay by up to this fraction in either direction (0-1).
+	Jitter float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+	// RetryOn lists the retryable conditions: "connection-reset", "timeout",
+	// "stream-cut", "5xx" or individual status codes such as "429".
+	RetryOn []string `yaml:"retry-on,omitempty" json:"retry-on,omitempty"`
+	// HedgeAfterMs launches a second attempt on another credential when the first has
+	// not produced its first byte within this many milliseconds. 0 disables hedging.
+	HedgeAfterMs int `yaml:"hedge-after-ms,omitempty" json:"hedge-after-ms,omitempty"`
+}
+
+// PricingConfig maps model name patterns to per-million-token list prices.
+type PricingConfig struct {
+	// Default applies to models that match no entry in Models. Nil leaves them unpriced.
+	Default *ModelPrice `yaml:"default,omitempty" json:"default,omitempty"`
+	// Models are matched in order against the request model; the first match wins.
+	Models []ModelPrice `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// ModelPrice defines the USD price per million tokens for each token bucket.
+// Unset cache prices are free, not billed at the input rate.
+type ModelPrice struct {
+	// Model is a case-insensitive model name pattern where '*' matches any substring.
+	// It is ignored for the default price.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+	// Input is the price of uncached input tokens.
+	Input float64 `yaml:"input" json:"input"`
+	// Output is the price of output tokens.
+	Output float64 `yaml:"output" json:"output"`
+	// CacheRead is the price of cache read input tokens.
+	CacheRead float64 `yaml:"cache-read,omitempty" json:"cache-read,omitempty"`
+	// CacheWrite is the price of cache creation input tokens.
+	CacheWrite float64 `yaml:"cache-write,omitempty" json:"cache-write,omitempty"`
+}
+
+// CacheDistribution defines the ratio used to split input tokens into simulated
+// uncached input, cache creation and cache read buckets for a provider.
+type CacheDistribution struct {
+	// InputPart is the relative weight of uncached input tokens. Parts may be fractional.
+	InputPart float64 `yaml:"input-part" json:"input-part"`
+	// CreationPart is the relative weight of cache creation tokens.
+	CreationPart float64 `yaml:"creation-part" json:"creation-part"`
+	// ReadPart is the relative weight of cache read tokens.
+	ReadPart float64 `yaml:"read-part" json:"read-part"`
+	// Threshold is the minimum input token count that triggers distribution.
+	// Zero keeps the default threshold of 100.
+	Threshold int64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	// MinCacheRead is the smallest cache read reported once distribution applies.
+	// Zero disables the floor.
+	MinCacheRead int64 `yaml:"min-cache-read,omitempty" json:"min-cache-read,omitempty"`
+	// PassThrough disables cache simulation for the provider, reporting all input
+	// tokens as uncached; the ratio parts may then be omitted.
+	PassThrough bool `yaml:"pass-through,omitempty" json:"pass-through,omitempty"`
 }
 
 // OAuthModelAlias defines a model ID alias for a specific channel.
@@ -257,6 +551,19 @@ type PayloadConfig struct {
 	OverrideRaw []PayloadRule `yaml:"override-raw" json:"override-raw"`
 	// Filter defines rules that remove parameters from the payload by JSON path.
 	Filter []PayloadFilterRule `yaml:"filter" json:"filter"`
+	// SystemPrompt defines rules that rewrite the system prompt of the translated payload.
+	SystemPrompt []SystemPromptRule `yaml:"system-prompt" json:"system-prompt"`
+}
+
+// SystemPromptRule describes a system prompt rewrite for a list of models. Matching
+// rules are applied in order after translation.
+type SystemPromptRule struct {
+	// Models lists model entries with name pattern and protocol constraint.
+	Models []PayloadModelRule `yaml:"models" json:"models"`
+	// Mode is "prepend", "append", "replace" or "strip".
+	Mode string `yaml:"mode" json:"mode"`
+	// Text is the instruction added or substituted; it is ignored by "strip".
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
 }
 
 // PayloadFilterRule describes a rule to remove specific JSON paths from matching model payloads.
@@ -313,6 +620,10 @@ type ClaudeKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of requests under the weighted routing
+	// strategy relative to others of the same priority; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/claude-sonnet-4").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -361,6 +672,10 @@ type CodexKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of requests under the weighted routing
+	// strategy relative to others of the same priority; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gpt-5-codex").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -406,6 +721,10 @@ type GeminiKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of requests under the weighted routing
+	// strategy relative to others of the same priority; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gemini-3-pro-preview").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -479,6 +798,10 @@ type OpenAICompatibility struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// Weight sets this credential's share of requests under the weighted routing
+	// strategy relative to others of the same priority; defaults to 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
 	// Prefix optionally namespaces model aliases for this provider (e.g., "teamA/kimi-k2").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
@@ -659,6 +982,47 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	// Normalize usage persistence settings
+	cfg.SanitizeUsage()
+
+	// Drop pricing entries without a model pattern or with negative prices
+	cfg.SanitizePricing()
+
+	// Normalize per-API-key rate limits
+	cfg.SanitizeRateLimit()
+
+	// Drop client keys without a name or secret and normalize their allowlists
+	cfg.SanitizeClientKeys()
+
+	// Normalize per-provider proxy URLs
+	cfg.SanitizeProviderProxyURLs()
+
+	// Drop model mappings without a source or target model
+	cfg.SanitizeModelMappings()
+
+	// Normalize failover chains
+	cfg.SanitizeFailover()
+	cfg.SanitizeResponseCache()
+	cfg.SanitizeRequestLimits()
+	cfg.SanitizeContextManagement()
+	cfg.SanitizeHealth()
+
+	// Normalize retry policies and drop unknown retry conditions
+	cfg.SanitizeRetryPolicies()
+
+	// Normalize reasoning effort budgets and drop unknown levels
+	cfg.SanitizeReasoning()
+
+	// Reject invalid cache distribution ratios; a bad ratio would silently misreport usage.
+	// In cloud deploy mode the rest of the config is kept and the defaults apply instead.
+	if errDist := cfg.ValidateCacheDistribution(); errDist != nil {
+		if !optional {
+			return nil, fmt.Errorf("invalid cache-distribution configuration: %w", errDist)
+		}
+		log.Errorf("invalid cache-distribution configuration, using the default distribution: %v", errDist)
+		cfg.CacheDistribution = nil
+	}
+
 	// NOTE: Legacy migration persistence is intentionally disabled together with
 	// startup legacy migration to keep startup read-only for config.yaml.
 	// Re-enable the block below if automatic startup migration is needed again.
@@ -678,6 +1042,435 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	return &cfg, nil
 }
 
+// ValidateCacheDistribution normalizes provider keys and verifies that every
+// configured cache distribution has positive ratio parts, unless it is pass-through,
+// and a non-negative threshold and cache read floor.
+func (cfg *Config) ValidateCacheDistribution() error {
+	if cfg == nil || len(cfg.CacheDistribution) == 0 {
+		return nil
+	}
+	out := make(map[string]CacheDistribution, len(cfg.CacheDistribution))
+	for provider, dist := range cfg.CacheDistribution {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if key == "" {
+			return fmt.Errorf("provider name must not be empty")
+		}
+		if !dist.PassThrough && (!validRatioPart(dist.InputPart) || !validRatioPart(dist.CreationPart) || !validRatioPart(dist.ReadPart)) {
+			return fmt.Errorf("provider %s: ratio parts must be positive, got %g:%g:%g", key, dist.InputPart, dist.CreationPart, dist.ReadPart)
+		}
+		if dist.Threshold < 0 {
+			return fmt.Errorf("provider %s: threshold must not be negative, got %d", key, dist.Threshold)
+		}
+		if dist.MinCacheRead < 0 {
+			return fmt.Errorf("provider %s: min-cache-read must not be negative, got %d", key, dist.MinCacheRead)
+		}
+		out[key] = dist
+	}
+	cfg.CacheDistribution = out
+	return nil
+}
+
+// validRatioPart reports whether part is a usable cache ratio weight: positive and finite.
+func validRatioPart(part float64) bool {
+	return part > 0 && !math.IsInf(part, 0)
+}
+
+// SanitizeUsage normalizes the usage persistence backend and falls back to the
+// in-memory store when the configured backend is unknown.
+func (cfg *Config) SanitizeUsage() {
+	if cfg == nil {
+		return
+	}
+	backend := strings.ToLower(strings.TrimSpace(cfg.Usage.Persistence))
+	switch backend {
+	case "", UsagePersistenceMemory:
+		backend = UsagePersistenceMemory
+	case UsagePersistenceSQLite:
+	default:
+		log.Warnf("unknown usage.persistence %q, falling back to %s", cfg.Usage.Persistence, UsagePersistenceMemory)
+		backend = UsagePersistenceMemory
+	}
+	cfg.Usage.Persistence = backend
+	cfg.Usage.SQLitePath = strings.TrimSpace(cfg.Usage.SQLitePath)
+	if cfg.Usage.RetentionDays < 0 {
+		cfg.Usage.RetentionDays = 0
+	}
+}
+
+// SanitizePricing normalizes model patterns and drops pricing entries that have
+// no pattern or a negative price.
+func (cfg *Config) SanitizePricing() {
+	if cfg == nil {
+		return
+	}
+	if cfg.Pricing.Default != nil && !validModelPrice(*cfg.Pricing.Default) {
+		log.Warnf("pricing.default has a negative price, ignoring it")
+		cfg.Pricing.Default = nil
+	}
+	if len(cfg.Pricing.Models) == 0 {
+		return
+	}
+	out := make([]ModelPrice, 0, len(cfg.Pricing.Models))
+	for i, price := range cfg.Pricing.Models {
+		price.Model = strings.ToLower(strings.TrimSpace(price.Model))
+		if price.Model == "" {
+			log.Warnf("pricing.models[%d] has no model pattern, ignoring it", i)
+			continue
+		}
+		if !validModelPrice(price) {
+			log.Warnf("pricing.models[%d] (%s) has a negative price, ignoring it", i, price.Model)
+			continue
+		}
+		out = append(out, price)
+	}
+	cfg.Pricing.Models = out
+}
+
+func validModelPrice(price ModelPrice) bool {
+	return price.Input >= 0 && price.Output >= 0 && price.CacheRead >= 0 && price.CacheWrite >= 0
+}
+
+// SanitizeRateLimit trims API keys, clamps negative limits to zero and drops key
+// entries without an API key. Later duplicates of a key override earlier ones.
+func (cfg *Config) SanitizeRateLimit() {
+	if cfg == nil {
+		return
+	}
+	cfg.RateLimit.Default = clampRateLimit(cfg.RateLimit.Default)
+	cfg.RateLimit.Default.APIKey = ""
+	for name, profile := range cfg.RateLimit.Profiles {
+		profile = clampRateLimit(profile)
+		profile.APIKey = ""
+		cfg.RateLimit.Profiles[name] = profile
+	}
+	if len(cfg.RateLimit.Keys) == 0 {
+		return
+	}
+	out := make([]APIKeyRateLimit, 0, len(cfg.RateLimit.Keys))
+	seen := make(map[string]int, len(cfg.RateLimit.Keys))
+	for i, limit := range cfg.RateLimit.Keys {
+		limit.APIKey = strings.TrimSpace(limit.APIKey)
+		if limit.APIKey == "" {
+			log.Warnf("rate-limit.keys[%d] has no api-key, ignoring it", i)
+			continue
+		}
+		limit = clampRateLimit(limit)
+		if idx, ok := seen[limit.APIKey]; ok {
+			out[idx] = limit
+			continue
+		}
+		seen[limit.APIKey] = len(out)
+		out = append(out, limit)
+	}
+	cfg.RateLimit.Keys = out
+}
+
+// SanitizeProviderProxyURLs lower-cases provider names, trims the URLs and drops
+// entries with an empty provider or URL.
+func (cfg *Config) SanitizeProviderProxyURLs() {
+	if cfg == nil || len(cfg.ProviderProxyURLs) == 0 {
+		return
+	}
+	out := make(map[string]string, len(cfg.ProviderProxyURLs))
+	for provider, proxyURL := range cfg.ProviderProxyURLs {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		proxyURL = strings.TrimSpace(proxyURL)
+		if provider == "" || proxyURL == "" {
+			continue
+		}
+		out[provider] = proxyURL
+	}
+	cfg.ProviderProxyURLs = out
+}
+
+// ValidateProxyURL reports whether raw is a usable proxy-url value: an http, https or
+// socks5 URL with a host, or "direct" when allowDirect is set.
+func ValidateProxyURL(raw string, allowDirect bool) error {
+	if allowDirect && strings.EqualFold(raw, "direct") {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q; use http, https or socks5", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return errors.New("proxy URL has no host")
+	}
+	return nil
+}
+
+// SanitizeClientKeys trims client keys, lower-cases their allowed providers and drops
+// entries without a name or secret and later entries reusing a name. References to
+// unknown rate-limit profiles are dropped with a warning.
+func (cfg *Config) SanitizeClientKeys() {
+	if cfg == nil || len(cfg.ClientKeys) == 0 {
+		return
+	}
+	out := make([]ClientKey, 0, len(cfg.ClientKeys))
+	seen := make(map[string]struct{}, len(cfg.ClientKeys))
+	for i, key := range cfg.ClientKeys {
+		key.Name = strings.TrimSpace(key.Name)
+		key.Key = strings.TrimSpace(key.Key)
+		key.KeyHash = strings.ToLower(strings.TrimSpace(key.KeyHash))
+		if key.Name == "" || (key.Key == "" && key.KeyHash == "") {
+			log.Warnf("client-keys[%d] needs a name and a key or key-hash, ignoring it", i)
+			continue
+		}
+		if _, dup := seen[key.Name]; dup {
+			log.Warnf("client-keys[%d] reuses the name %q, ignoring it", i, key.Name)
+			continue
+		}
+		seen[key.Name] = struct{}{}
+		providers := make([]string, 0, len(key.AllowedProviders))
+		for _, provider := range key.AllowedProviders {
+			if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+				providers = append(providers, provider)
+			}
+		}
+		key.AllowedProviders = providers
+		models := make([]string, 0, len(key.AllowedModels))
+		for _, model := range key.AllowedModels {
+			if model = strings.TrimSpace(model); model != "" {
+				models = append(models, model)
+			}
+		}
+		key.AllowedModels = models
+		key.RateLimit = strings.TrimSpace(key.RateLimit)
+		if _, ok := cfg.RateLimit.Profiles[key.RateLimit]; key.RateLimit != "" && !ok {
+			log.Warnf("client-keys %q refers to unknown rate-limit profile %q, ignoring it", key.Name, key.RateLimit)
+			key.RateLimit = ""
+		}
+		out = append(out, key)
+	}
+	cfg.ClientKeys = out
+}
+
+func clampRateLimit(limit APIKeyRateLimit) APIKeyRateLimit {
+	limit.RequestsPerMinute = max(limit.RequestsPerMinute, 0)
+	limit.TokensPerMinute = max(limit.TokensPerMinute, 0)
+	return limit
+}
+
+// SanitizeModelMappings trims model mapping entries and drops those missing a
+// source name or a target model.
+func (cfg *Config) SanitizeModelMappings() {
+	if cfg == nil || len(cfg.ModelMappings) == 0 {
+		return
+	}
+	out := make([]ModelMapping, 0, len(cfg.ModelMappings))
+	for _, mapping := range cfg.ModelMappings {
+		mapping.From = strings.TrimSpace(mapping.From)
+		mapping.To = strings.TrimSpace(mapping.To)
+		if _, model := mapping.Target(); mapping.From == "" || model == "" {
+			log.Warnf("model-mappings: dropping invalid entry from=%q to=%q", mapping.From, mapping.To)
+			continue
+		}
+		out = append(out, mapping)
+	}
+	cfg.ModelMappings = out
+}
+
+// SanitizeFailover lower-cases and de-duplicates the providers of each failover chain
+// and drops chains left without a model or providers.
+func (cfg *Config) SanitizeFailover() {
+	if cfg == nil || len(cfg.Failover.Chains) == 0 {
+		return
+	}
+	chains := make(map[string][]string, len(cfg.Failover.Chains))
+	for model, providers := range cfg.Failover.Chains {
+		model = strings.TrimSpace(model)
+		seen := make(map[string]struct{}, len(providers))
+		chain := make([]string, 0, len(providers))
+		for _, provider := range providers {
+			provider = strings.ToLower(strings.TrimSpace(provider))
+			if provider == "" {
+				continue
+			}
+			if _, dup := seen[provider]; dup {
+				continue
+			}
+			seen[provider] = struct{}{}
+			chain = append(chain, provider)
+		}
+		if model == "" || len(chain) == 0 {
+			continue
+		}
+		chains[model] = chain
+	}
+	cfg.Failover.Chains = chains
+	cfg.Failover.MaxAttempts = max(cfg.Failover.MaxAttempts, 0)
+	cfg.Failover.AttemptTimeout = max(cfg.Failover.AttemptTimeout, 0)
+}
+
+// SanitizeResponseCache replaces unset or non-positive response cache bounds with
+// their defaults.
+func (cfg *Config) SanitizeResponseCache() {
+	if cfg == nil {
+		return
+	}
+	rc := &cfg.ResponseCache
+	if rc.TTL <= 0 {
+		rc.TTL = 300
+	}
+	if rc.MaxEntries <= 0 {
+		rc.MaxEntries = 1000
+	}
+	if rc.MaxSizeMB <= 0 {
+		rc.MaxSizeMB = 64
+	}
+}
+
+// SanitizeRequestLimits lower-cases context window and output token model names and
+// drops windows and limits that are not positive.
+func (cfg *Config) SanitizeRequestLimits() {
+	if cfg == nil {
+		return
+	}
+	if len(cfg.RequestLimits.ContextWindows) > 0 {
+		windows := make(map[string]int, len(cfg.RequestLimits.ContextWindows))
+		for model, window := range cfg.RequestLimits.ContextWindows {
+			model = strings.ToLower(strings.TrimSpace(model))
+			if model == "" || window <= 0 {
+				continue
+			}
+			windows[model] = window
+		}
+		cfg.RequestLimits.ContextWindows = windows
+	}
+	if len(cfg.RequestLimits.OutputTokens) > 0 {
+		limits := make(map[string]OutputTokenLimits, len(cfg.RequestLimits.OutputTokens))
+		for model, limit := range cfg.RequestLimits.OutputTokens {
+			model = strings.ToLower(strings.TrimSpace(model))
+			limit.Default = max(limit.Default, 0)
+			limit.Max = max(limit.Max, 0)
+			if model == "" || limit == (OutputTokenLimits{}) {
+				continue
+			}
+			limits[model] = limit
+		}
+		cfg.RequestLimits.OutputTokens = limits
+	}
+}
+
+// SanitizeHealth lower-cases provider names, drops empty ones and defaults the probe
+// interval and timeout.
+func (cfg *Config) SanitizeHealth() {
+	if cfg == nil {
+		return
+	}
+	if cfg.Health.ProbeIntervalSeconds <= 0 {
+		cfg.Health.ProbeIntervalSeconds = 60
+	}
+	if cfg.Health.ProbeTimeoutSeconds <= 0 {
+		cfg.Health.ProbeTimeoutSeconds = 10
+	}
+	if len(cfg.Health.Providers) == 0 {
+		return
+	}
+	providers := make(map[string]ProviderHealthConfig, len(cfg.Health.Providers))
+	for provider, health := range cfg.Health.Providers {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if provider == "" {
+			continue
+		}
+		health.ProbeModel = strings.TrimSpace(health.ProbeModel)
+		providers[provider] = health
+	}
+	cfg.Health.Providers = providers
+}
+
+// SanitizeContextManagement normalizes the truncation mode, accepting "oldest_first"
+// for TruncateOldestFirst, and defaults the output reserve.
+func (cfg *Config) SanitizeContextManagement() {
+	if cfg == nil {
+		return
+	}
+	cm := &cfg.ContextManagement
+	cm.Truncate = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(cm.Truncate)), "_", "-")
+	if cm.OutputReserve <= 0 {
+		cm.OutputReserve = 4096
+	}
+}
+
+// SanitizeReasoning lower-cases reasoning effort levels and drops budgets for unknown
+// levels or that are not positive.
+func (cfg *Config) SanitizeReasoning() {
+	if cfg == nil || len(cfg.Reasoning.EffortBudgets) == 0 {
+		return
+	}
+	budgets := make(map[string]int, len(cfg.Reasoning.EffortBudgets))
+	for level, budget := range cfg.Reasoning.EffortBudgets {
+		key := strings.ToLower(strings.TrimSpace(level))
+		switch key {
+		case "minimal", "low", "medium", "high", "xhigh":
+		default:
+			log.Warnf("reasoning.effort-budgets: dropping unknown level %q", level)
+			continue
+		}
+		if budget <= 0 {
+			log.Warnf("reasoning.effort-budgets: dropping non-positive budget %d for level %q", budget, level)
+			continue
+		}
+		budgets[key] = budget
+	}
+	cfg.Reasoning.EffortBudgets = budgets
+}
+
+// SanitizeRetryPolicies lower-cases provider keys, applies delay defaults, clamps the
+// numeric settings and drops unknown retry conditions. A policy without conditions
+// retries DefaultRetryOn.
+func (cfg *Config) SanitizeRetryPolicies() {
+	if cfg == nil || len(cfg.RetryPolicies) == 0 {
+		return
+	}
+	policies := make(map[string]RetryPolicy, len(cfg.RetryPolicies))
+	for provider, policy := range cfg.RetryPolicies {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if provider == "" {
+			continue
+		}
+		policy.MaxAttempts = max(policy.MaxAttempts, 0)
+		if policy.BaseDelayMs <= 0 {
+			policy.BaseDelayMs = 200
+		}
+		if policy.MaxDelayMs <= 0 {
+			policy.MaxDelayMs = 5000
+		}
+		policy.MaxDelayMs = max(policy.MaxDelayMs, policy.BaseDelayMs)
+		policy.Jitter = min(max(policy.Jitter, 0), 1)
+		policy.HedgeAfterMs = max(policy.HedgeAfterMs, 0)
+		conditions := make([]string, 0, len(policy.RetryOn))
+		for _, condition := range policy.RetryOn {
+			condition = strings.ToLower(strings.TrimSpace(condition))
+			if !validRetryCondition(condition) {
+				log.Warnf("retry-policies.%s: ignoring unknown retry condition %q", provider, condition)
+				continue
+			}
+			conditions = append(conditions, condition)
+		}
+		if len(conditions) == 0 {
+			conditions = append([]string(nil), DefaultRetryOn...)
+		}
+		policy.RetryOn = conditions
+		policies[provider] = policy
+	}
+	cfg.RetryPolicies = policies
+}
+
+func validRetryCondition(condition string) bool {
+	switch condition {
+	case RetryOnConnectionReset, RetryOnTimeout, RetryOnStreamCut, RetryOnServerError:
+		return true
+	}
+	code, err := strconv.Atoi(condition)
+	return err == nil && code >= 400 && code <= 599
+}
+
 // SanitizePayloadRules validates raw JSON payload rule params and drops invalid rules.
 func (cfg *Config) SanitizePayloadRules() {
 	if cfg == nil {
@@ -685,6 +1478,40 @@ func (cfg *Config) SanitizePayloadRules() {
 	}
 	cfg.Payload.DefaultRaw = sanitizePayloadRawRules(cfg.Payload.DefaultRaw, "default-raw")
 	cfg.Payload.OverrideRaw = sanitizePayloadRawRules(cfg.Payload.OverrideRaw, "override-raw")
+	cfg.Payload.SystemPrompt = sanitizeSystemPromptRules(cfg.Payload.SystemPrompt)
+}
+
+// sanitizeSystemPromptRules normalizes rule modes and drops rules with an unknown mode
+// or without the text their mode needs.
+func sanitizeSystemPromptRules(rules []SystemPromptRule) []SystemPromptRule {
+	if len(rules) == 0 {
+		return rules
+	}
+	out := make([]SystemPromptRule, 0, len(rules))
+	for i := range rules {
+		rule := rules[i]
+		rule.Mode = strings.ToLower(strings.TrimSpace(rule.Mode))
+		switch rule.Mode {
+		case "strip":
+			rule.Text = ""
+		case "prepend", "append", "replace":
+			if strings.TrimSpace(rule.Text) == "" {
+				log.WithFields(log.Fields{
+					"rule_index": i + 1,
+					"mode":       rule.Mode,
+				}).Warn("system prompt rule dropped: text is empty")
+				continue
+			}
+		default:
+			log.WithFields(log.Fields{
+				"rule_index": i + 1,
+				"mode":       rule.Mode,
+			}).Warn("system prompt rule dropped: unknown mode")
+			continue
+		}
+		out = append(out, rule)
+	}
+	return out
 }
 
 func sanitizePayloadRawRules(rules []PayloadRule, section string) []PayloadRule {
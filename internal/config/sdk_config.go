@@ -4,11 +4,24 @@
 // debug settings, proxy configuration, and API keys.
 package config
 
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
 // SDKConfig represents the application's configuration, loaded from a YAML file.
 type SDKConfig struct {
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
 
+	// ProviderProxyURLs overrides ProxyURL per provider (e.g., claude, gemini, kiro).
+	// Values take the same schemes as ProxyURL, or "direct" to connect without any
+	// proxy, ignoring ProxyURL and HTTPS_PROXY. A credential's own proxy-url wins.
+	ProviderProxyURLs map[string]string `yaml:"provider-proxy-urls,omitempty" json:"provider-proxy-urls,omitempty"`
+
 	// ForceModelPrefix requires explicit model prefixes (e.g., "teamA/gemini-3-pro-preview")
 	// to target prefixed credentials. When false, unprefixed model requests may use prefixed
 	// credentials as well.
@@ -20,12 +33,218 @@ type SDKConfig struct {
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// ClientKeys lists named client API keys restricted to some providers and models.
+	// Keys in APIKeys keep unrestricted access.
+	ClientKeys []ClientKey `yaml:"client-keys,omitempty" json:"client-keys,omitempty"`
+
+	// WebsocketAllowedOrigins lists the browser origins (e.g., "https://app.example.com")
+	// allowed to open the chat completions WebSocket besides the server's own origin;
+	// "*" allows every origin. Clients that send no Origin header are always allowed.
+	WebsocketAllowedOrigins []string `yaml:"websocket-allowed-origins,omitempty" json:"websocket-allowed-origins,omitempty"`
+
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
 
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// ModelMappings routes client-requested model names to a provider and upstream model.
+	// Exact names are matched before globs; globs are tried in order.
+	ModelMappings []ModelMapping `yaml:"model-mappings,omitempty" json:"model-mappings,omitempty"`
+
+	// StrictModels rejects requests for models that match no entry in ModelMappings
+	// instead of passing them through to the normal model lookup.
+	StrictModels bool `yaml:"strict-models" json:"strict-models"`
+
+	// DisableProviderOverride ignores the X-CLIProxy-Provider request header and the
+	// "provider/model" prefix that otherwise force a request onto one provider.
+	DisableProviderOverride bool `yaml:"disable-provider-override" json:"disable-provider-override"`
+
+	// Failover configures per-model provider fallback chains used when an upstream
+	// fails with a rate limit, server error or timeout.
+	Failover FailoverConfig `yaml:"failover,omitempty" json:"failover,omitempty"`
+
+	// Reasoning configures how reasoning_effort maps to thinking budgets and whether
+	// reasoning content is returned to OpenAI-format clients.
+	Reasoning ReasoningConfig `yaml:"reasoning,omitempty" json:"reasoning,omitempty"`
+
+	// ResponseCache replays upstream responses to identical deterministic requests
+	// from memory instead of calling the upstream again.
+	ResponseCache ResponseCacheConfig `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// RequestLimits rejects requests too large for the server, or for the context window
+	// of the routed model, before they reach the upstream.
+	RequestLimits RequestLimitsConfig `yaml:"request-limits,omitempty" json:"request-limits,omitempty"`
+
+	// ContextManagement shortens conversations that would exceed the context window of
+	// the routed model.
+	ContextManagement ContextManagementConfig `yaml:"context-management,omitempty" json:"context-management,omitempty"`
+}
+
+// ClientKey is a named client API key. Its name, not the secret, identifies the
+// client in usage records, access logs and rate limits.
+type ClientKey struct {
+	// Name identifies the key. It must be unique.
+	Name string `yaml:"name" json:"name"`
+
+	// Key is the secret in plain text. Keys created through the management API store
+	// only KeyHash instead.
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+
+	// KeyHash is "sha256:" followed by the hex SHA-256 of the secret.
+	KeyHash string `yaml:"key-hash,omitempty" json:"key-hash,omitempty"`
+
+	// ExpiresAt rejects the key from this time on. The zero value never expires.
+	ExpiresAt time.Time `yaml:"expires-at,omitempty" json:"expires-at,omitempty"`
+
+	// AllowedProviders restricts the providers that may serve the key's requests
+	// (e.g., [kiro, claude]). Empty allows every provider.
+	AllowedProviders []string `yaml:"allowed-providers,omitempty" json:"allowed-providers,omitempty"`
+
+	// AllowedModels restricts the requested models to these names or globs, where "*"
+	// matches any run of characters (e.g., "claude-sonnet-*"). Empty allows every model.
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// RateLimit names the rate-limit.profiles entry budgeting the key.
+	RateLimit string `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+}
+
+// HashClientKey returns the KeyHash form of secret.
+func HashClientKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Matches reports whether secret is the key's secret.
+func (k ClientKey) Matches(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if k.Key != "" {
+		return subtle.ConstantTimeCompare([]byte(k.Key), []byte(secret)) == 1
+	}
+	return k.KeyHash != "" && subtle.ConstantTimeCompare([]byte(strings.ToLower(k.KeyHash)), []byte(HashClientKey(secret))) == 1
+}
+
+// Expired reports whether the key is no longer valid at now.
+func (k ClientKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// ReasoningConfig holds reasoning / extended thinking translation settings.
+type ReasoningConfig struct {
+	// EffortBudgets overrides the thinking token budget for reasoning_effort levels
+	// (minimal, low, medium, high, xhigh) on backends that take a numeric budget,
+	// such as Claude's thinking.budget_tokens and Gemini 2.5's thinkingBudget.
+	EffortBudgets map[string]int `yaml:"effort-budgets,omitempty" json:"effort-budgets,omitempty"`
+
+	// StripContent drops reasoning_content from OpenAI chat completion responses.
+	// Clients can also opt out per request with "include_reasoning": false or
+	// "reasoning": {"exclude": true}.
+	StripContent bool `yaml:"strip-content" json:"strip-content"`
+}
+
+// FailoverConfig holds provider fallback chains and their retry budget.
+type FailoverConfig struct {
+	// Chains maps a model name, or a glob such as "claude-sonnet-*", to the providers
+	// tried in order (e.g., [kiro, claude, openrouter]).
+	Chains map[string][]string `yaml:"chains,omitempty" json:"chains,omitempty"`
+
+	// MaxAttempts caps the providers tried for one request. <= 0 tries the whole chain.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+
+	// AttemptTimeout bounds each provider attempt in seconds. For streaming requests it
+	// only applies until the first chunk arrives. <= 0 disables the timeout.
+	AttemptTimeout int `yaml:"attempt-timeout,omitempty" json:"attempt-timeout,omitempty"`
+}
+
+// ResponseCacheConfig bounds the in-memory response cache.
+type ResponseCacheConfig struct {
+	// Enable turns the response cache on.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// TTL is how long a response stays cached, in seconds. Defaults to 300.
+	TTL int `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// MaxEntries caps the number of cached responses. Defaults to 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// MaxSizeMB caps the total size of cached responses in MiB. Defaults to 64.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// CacheNondeterministic also caches requests whose temperature is unset or above 0,
+	// which otherwise only use the cache with an "X-CLIProxy-Cache: force" header.
+	CacheNondeterministic bool `yaml:"cache-nondeterministic" json:"cache-nondeterministic"`
+}
+
+// RequestLimitsConfig bounds the size of API requests.
+type RequestLimitsConfig struct {
+	// MaxBodyMB caps API request bodies in MiB; larger bodies are rejected with 413.
+	// <= 0 disables the limit.
+	MaxBodyMB int `yaml:"max-body-mb,omitempty" json:"max-body-mb,omitempty"`
+
+	// ContextWindowCheck estimates the input tokens of each request and rejects with 400
+	// those exceeding the routed model's context window. Models without a known context
+	// window are not checked.
+	ContextWindowCheck bool `yaml:"context-window-check" json:"context-window-check"`
+
+	// ContextWindows sets the context window in tokens of a model name, or a glob such
+	// as "local-*", overriding the model registry.
+	ContextWindows map[string]int `yaml:"context-windows,omitempty" json:"context-windows,omitempty"`
+
+	// OutputTokens sets the default and maximum output tokens of a model name,
+	// overriding the model registry.
+	OutputTokens map[string]OutputTokenLimits `yaml:"output-tokens,omitempty" json:"output-tokens,omitempty"`
+}
+
+// OutputTokenLimits bounds the output tokens requested for a model. Zero fields fall
+// back to the model registry.
+type OutputTokenLimits struct {
+	// Default is used when the request sets no output limit.
+	Default int `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// Max caps the output limit a request may set; larger values are clamped.
+	Max int `yaml:"max,omitempty" json:"max,omitempty"`
+}
+
+// TruncateOldestFirst is the ContextManagementConfig.Truncate mode that drops the
+// oldest conversation turns until the request fits.
+const TruncateOldestFirst = "oldest-first"
+
+// ContextManagementConfig configures conversation truncation.
+type ContextManagementConfig struct {
+	// Truncate selects the truncation mode: TruncateOldestFirst, or empty to disable it.
+	Truncate string `yaml:"truncate,omitempty" json:"truncate,omitempty"`
+
+	// OutputReserve is the number of tokens kept free for the response when the request
+	// sets no output limit. Defaults to 4096.
+	OutputReserve int `yaml:"output-reserve,omitempty" json:"output-reserve,omitempty"`
+}
+
+// ModelMapping maps a client-facing model name to the model actually served.
+type ModelMapping struct {
+	// From is the requested model name, or a glob where "*" matches any run of
+	// characters and "?" matches one character (e.g., "claude-3-5-*").
+	From string `yaml:"from" json:"from"`
+
+	// To is the upstream model, optionally qualified with the provider that must
+	// serve it as "provider:model" (e.g., "kiro:claude-sonnet-4").
+	To string `yaml:"to" json:"to"`
+}
+
+// IsGlob reports whether From contains glob wildcards.
+func (m ModelMapping) IsGlob() bool {
+	return strings.ContainsAny(m.From, "*?")
+}
+
+// Target splits To into the optional provider and the upstream model.
+func (m ModelMapping) Target() (provider, model string) {
+	provider, model, found := strings.Cut(m.To, ":")
+	if !found {
+		return "", strings.TrimSpace(m.To)
+	}
+	return strings.ToLower(strings.TrimSpace(provider)), strings.TrimSpace(model)
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -38,4 +257,19 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// IdleTimeoutSeconds aborts a stream whose upstream has sent nothing for this long and
+	// ends it with an error event. <= 0 disables the timeout. Default is 0.
+	IdleTimeoutSeconds int `yaml:"idle-timeout-seconds,omitempty" json:"idle-timeout-seconds,omitempty"`
+
+	// Providers overrides the keep-alive interval and idle timeout for streams served by
+	// a provider (e.g., "kiro"), falling back to the values above.
+	Providers map[string]StreamingProviderConfig `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// StreamingProviderConfig holds per-provider streaming overrides. 0 inherits the global
+// value and < 0 disables the feature for the provider.
+type StreamingProviderConfig struct {
+	KeepAliveSeconds   int `yaml:"keepalive-seconds,omitempty" json:"keepalive-seconds,omitempty"`
+	IdleTimeoutSeconds int `yaml:"idle-timeout-seconds,omitempty" json:"idle-timeout-seconds,omitempty"`
 }
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateConfigFile loads configFile like LoadConfig and additionally rejects unknown
+// fields, model globs using unsupported syntax and duplicate API keys. The returned
+// error joins every problem found; the config is only returned when there is none.
+func ValidateConfigFile(configFile string) (*Config, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	errFields := checkUnknownFields(data)
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return nil, errors.Join(errFields, err)
+	}
+	if err = errors.Join(errFields, cfg.Validate()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports the semantic problems of a loaded config that LoadConfig tolerates.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return nil
+	}
+	var errs []error
+	checkGlob := func(field, pattern string) {
+		if strings.ContainsAny(pattern, "[]{}\\") {
+			errs = append(errs, fmt.Errorf("%s: unsupported glob %q; only * and ? are wildcards", field, pattern))
+		}
+	}
+	for i, mapping := range cfg.ModelMappings {
+		checkGlob(fmt.Sprintf("model-mappings[%d].from", i), mapping.From)
+	}
+	for pattern := range cfg.Failover.Chains {
+		checkGlob("failover.chains", pattern)
+	}
+	for i, price := range cfg.Pricing.Models {
+		checkGlob(fmt.Sprintf("pricing.models[%d].model", i), price.Model)
+	}
+	for pattern := range cfg.RequestLimits.ContextWindows {
+		checkGlob("request-limits.context-windows", pattern)
+	}
+	for _, key := range cfg.ClientKeys {
+		for _, pattern := range key.AllowedModels {
+			checkGlob(fmt.Sprintf("client-keys[%s].allowed-models", key.Name), pattern)
+		}
+	}
+
+	for model, limit := range cfg.RequestLimits.OutputTokens {
+		if limit.Max > 0 && limit.Default > limit.Max {
+			errs = append(errs, fmt.Errorf("request-limits.output-tokens[%s]: default %d exceeds max %d", model, limit.Default, limit.Max))
+		}
+	}
+
+	if mode := cfg.ContextManagement.Truncate; mode != "" && mode != TruncateOldestFirst {
+		errs = append(errs, fmt.Errorf("context-management.truncate: unsupported mode %q; use %q", mode, TruncateOldestFirst))
+	}
+
+	if cfg.ProxyURL != "" {
+		if err := ValidateProxyURL(cfg.ProxyURL, false); err != nil {
+			errs = append(errs, fmt.Errorf("proxy-url: %w", err))
+		}
+	}
+	for provider, proxyURL := range cfg.ProviderProxyURLs {
+		if err := ValidateProxyURL(proxyURL, true); err != nil {
+			errs = append(errs, fmt.Errorf("provider-proxy-urls[%s]: %w", provider, err))
+		}
+	}
+
+	seen := make(map[string]string, len(cfg.APIKeys)+len(cfg.ClientKeys))
+	checkKey := func(field, key string) {
+		if key == "" {
+			return
+		}
+		if first, dup := seen[key]; dup {
+			errs = append(errs, fmt.Errorf("%s: duplicate API key (already used by %s)", field, first))
+			return
+		}
+		seen[key] = field
+	}
+	for i, key := range cfg.APIKeys {
+		checkKey(fmt.Sprintf("api-keys[%d]", i), strings.TrimSpace(key))
+	}
+	for _, key := range cfg.ClientKeys {
+		checkKey(fmt.Sprintf("client-keys[%s]", key.Name), key.Key)
+	}
+	return errors.Join(errs...)
+}
+
+// legacyFields lists the deprecated keys still handled by the legacy migration, as
+// reported by a strict decode.
+var legacyFields = []string{
+	"field auth not found in type config.Config",
+	"field generative-language-api-key not found in type config.Config",
+	"field oauth-model-mappings not found in type config.Config",
+	"field amp-upstream-url not found in type config.Config",
+	"field amp-upstream-api-key not found in type config.Config",
+	"field amp-restrict-management-to-localhost not found in type config.Config",
+	"field amp-model-mappings not found in type config.Config",
+	"field api-keys not found in type config.OpenAICompatibility",
+}
+
+// checkUnknownFields decodes data strictly and reports every field that Config does
+// not define, except deprecated keys still handled by the legacy migration.
+func checkUnknownFields(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	err := decoder.Decode(&cfg)
+	if err == nil {
+		return nil
+	}
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		// Syntax errors are reported by LoadConfig.
+		return nil
+	}
+	errs := make([]error, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		if !slices.ContainsFunc(legacyFields, func(legacy string) bool { return strings.HasSuffix(msg, legacy) }) {
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,305 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultAccessLogMaxSizeMB    = 10
+	defaultAccessLogMaxBodyBytes = 4096
+
+	ginRequestUsageKey = "__gin_request_usage__"
+)
+
+// AccessLogTokens is the token breakdown of a request in the access log.
+type AccessLogTokens struct {
+	Input         int64 `json:"input"`
+	Output        int64 `json:"output"`
+	CacheCreation int64 `json:"cache_creation"`
+	CacheRead     int64 `json:"cache_read"`
+}
+
+// requestUsage is what the executors report about a request for its access log record.
+type requestUsage struct {
+	provider string
+	model    string
+	tokens   *AccessLogTokens
+}
+
+// SetGinRequestUsage records the provider, upstream model and token breakdown of the
+// request so that the access log can report them. A nil tokens leaves them out.
+func SetGinRequestUsage(c *gin.Context, provider, model string, tokens *AccessLogTokens) {
+	if c == nil {
+		return
+	}
+	c.Set(ginRequestUsageKey, requestUsage{provider: provider, model: model, tokens: tokens})
+}
+
+// accessLogRecord is one line of the access log.
+type accessLogRecord struct {
+	Timestamp             string           `json:"timestamp"`
+	RequestID             string           `json:"request_id,omitempty"`
+	ClientKeyID           string           `json:"client_key_id,omitempty"`
+	ClientKeyName         string           `json:"client_key_name,omitempty"`
+	Method                string           `json:"method"`
+	Path                  string           `json:"path"`
+	Provider              string           `json:"provider,omitempty"`
+	Model                 string           `json:"model,omitempty"`
+	Status                int              `json:"status"`
+	DurationMS            float64          `json:"duration_ms"`
+	TTFBMS                *float64         `json:"ttfb_ms,omitempty"`
+	Tokens                *AccessLogTokens `json:"tokens,omitempty"`
+	ErrorCategory         string           `json:"error_category,omitempty"`
+	RequestBody           string           `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool             `json:"request_body_truncated,omitempty"`
+	ResponseBody          string           `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool             `json:"response_body_truncated,omitempty"`
+}
+
+// AccessLogger writes one JSON record per request to a size-rotated file and, optionally,
+// stdout. It is disabled until Configure enables it and may be reconfigured at any time.
+type AccessLogger struct {
+	mu   sync.Mutex
+	cfg  config.AccessLogConfig
+	file *lumberjack.Logger
+	out  io.Writer
+}
+
+// NewAccessLogger returns a disabled access logger.
+func NewAccessLogger() *AccessLogger {
+	return &AccessLogger{}
+}
+
+// Configure applies cfg. A relative or empty cfg.File is resolved against logDir.
+func (l *AccessLogger) Configure(cfg config.AccessLogConfig, logDir string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeLocked()
+	l.cfg = cfg
+	if !cfg.Enable {
+		return nil
+	}
+	path := cfg.File
+	if path == "" {
+		path = "access.log"
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(logDir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		l.cfg.Enable = false
+		return err
+	}
+	maxSize := cfg.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = defaultAccessLogMaxSizeMB
+	}
+	l.file = &lumberjack.Logger{Filename: path, MaxSize: maxSize, MaxBackups: max(cfg.MaxBackups, 0)}
+	l.out = l.file
+	if cfg.Stdout {
+		l.out = io.MultiWriter(l.file, os.Stdout)
+	}
+	return nil
+}
+
+// Close flushes and closes the access log file.
+func (l *AccessLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closeLocked()
+}
+
+func (l *AccessLogger) closeLocked() error {
+	l.out = nil
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *AccessLogger) settings() (enabled, logBodies bool, maxBody int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	maxBody = l.cfg.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = defaultAccessLogMaxBodyBytes
+	}
+	return l.out != nil, l.cfg.LogBodies, maxBody
+}
+
+func (l *AccessLogger) write(record accessLogRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	line = append([]byte(RedactSecrets(string(line))), '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out != nil {
+		_, _ = l.out.Write(line)
+	}
+}
+
+// Middleware returns a Gin middleware that writes an access log record for every
+// request not marked with SkipGinRequestLogging.
+func (l *AccessLogger) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, logBodies, maxBody := l.settings()
+		if !enabled {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		var requestBody *cappedBuffer
+		if logBodies && c.Request.Body != nil {
+			requestBody = &cappedBuffer{limit: maxBody}
+			c.Request.Body = teeReadCloser{Reader: io.TeeReader(c.Request.Body, requestBody), Closer: c.Request.Body}
+		}
+		writer := &accessLogWriter{ResponseWriter: c.Writer}
+		if logBodies {
+			writer.body = &cappedBuffer{limit: maxBody}
+		}
+		c.Writer = writer
+
+		c.Next()
+
+		if shouldSkipGinRequestLogging(c) {
+			return
+		}
+		path := c.Request.URL.Path
+		if raw := util.MaskSensitiveQuery(c.Request.URL.RawQuery); raw != "" {
+			path += "?" + raw
+		}
+		status := c.Writer.Status()
+		record := accessLogRecord{
+			Timestamp:     start.UTC().Format(time.RFC3339Nano),
+			RequestID:     GetGinRequestID(c),
+			ClientKeyID:   clientKeyID(c.GetString("apiKey")),
+			ClientKeyName: c.GetString("apiKeyName"),
+			Method:        c.Request.Method,
+			Path:          path,
+			Status:        status,
+			DurationMS:    milliseconds(time.Since(start)),
+			ErrorCategory: errorCategory(c.Request.Context(), status),
+		}
+		if !writer.firstByte.IsZero() {
+			ttfb := milliseconds(writer.firstByte.Sub(start))
+			record.TTFBMS = &ttfb
+		}
+		if value, ok := c.Get(ginRequestUsageKey); ok {
+			if reported, ok := value.(requestUsage); ok {
+				record.Provider, record.Model, record.Tokens = reported.provider, reported.model, reported.tokens
+			}
+		}
+		if requestBody != nil {
+			record.RequestBody, record.RequestBodyTruncated = requestBody.String(), requestBody.truncated
+		}
+		if writer.body != nil {
+			record.ResponseBody, record.ResponseBodyTruncated = writer.body.String(), writer.body.truncated
+		}
+		l.write(record)
+	}
+}
+
+// clientKeyID identifies a client API key without revealing it: the first 12 hex
+// digits of its SHA-256.
+func clientKeyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:6])
+}
+
+// errorCategory classifies a failed request for the access log.
+func errorCategory(ctx context.Context, status int) string {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return "client_canceled"
+	}
+	switch {
+	case status < http.StatusBadRequest:
+		return ""
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "auth"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status < http.StatusInternalServerError:
+		return "invalid_request"
+	default:
+		return "upstream"
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// accessLogWriter notes when the first body byte is written and optionally keeps the
+// start of the body.
+type accessLogWriter struct {
+	gin.ResponseWriter
+	firstByte time.Time
+	body      *cappedBuffer
+}
+
+func (w *accessLogWriter) Write(data []byte) (int, error) {
+	w.note(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *accessLogWriter) WriteString(data string) (int, error) {
+	w.note([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *accessLogWriter) note(data []byte) {
+	if w.firstByte.IsZero() && len(data) > 0 {
+		w.firstByte = time.Now()
+	}
+	if w.body != nil {
+		_, _ = w.body.Write(data)
+	}
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
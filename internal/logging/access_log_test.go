@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Known credentials that must never appear in serialized log output.
+var knownSecrets = []string{
+	"sk-client-0123456789abcdef",
+	"ya29.a0AfH6SMBupstreamaccesstoken",
+	"1//0refresh-token-secret-value",
+	"x-api-key-secret-value-42",
+	"AIzaSyD-gemini-query-key-0000",
+}
+
+func assertNoSecrets(t *testing.T, output string) {
+	t.Helper()
+	for _, secret := range knownSecrets {
+		if strings.Contains(output, secret) {
+			t.Errorf("log output contains secret %q:\n%s", secret, output)
+		}
+	}
+}
+
+func TestRedactSecrets_DebugLogLine(t *testing.T) {
+	logger := log.New()
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	logger.SetLevel(log.DebugLevel)
+	logger.SetFormatter(&LogFormatter{})
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer "+knownSecrets[0])
+	headers.Set("X-Api-Key", knownSecrets[3])
+	logger.Debugf("upstream request headers: %v", headers)
+	logger.Debugf(`token refresh body: {"access_token":"%s","refresh_token":"%s","expires_in":3599}`, knownSecrets[1], knownSecrets[2])
+	logger.WithField("error", "GET https://generativelanguage.googleapis.com/v1beta/models?key="+knownSecrets[4]).Debug("request failed")
+	logger.Debugf("Authorization: Bearer %s", knownSecrets[1])
+
+	out := buf.String()
+	assertNoSecrets(t, out)
+	if !strings.Contains(out, "Bearer [REDACTED]") || !strings.Contains(out, `"expires_in":3599`) {
+		t.Fatalf("redaction removed more than the credentials:\n%s", out)
+	}
+}
+
+func TestAccessLogger_WritesRedactedJSONRecords(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	logger := NewAccessLogger()
+	cfg := config.AccessLogConfig{Enable: true, LogBodies: true, MaxBodyBytes: 256}
+	if err := logger.Configure(cfg, dir); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	t.Cleanup(func() { _ = logger.Close() })
+
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		SetGinRequestID(c, "abcd1234")
+		c.Set("apiKey", knownSecrets[0])
+		c.Next()
+	})
+	engine.Use(logger.Middleware())
+	engine.POST("/v1/messages", func(c *gin.Context) {
+		var body map[string]any
+		_ = c.ShouldBindJSON(&body)
+		SetGinRequestUsage(c, "claude", "claude-sonnet", &AccessLogTokens{Input: 10, Output: 5, CacheCreation: 20, CacheRead: 300})
+		c.String(http.StatusOK, fmt.Sprintf(`{"id":"msg_1","echo_refresh_token":"%s"}`, knownSecrets[2]))
+	})
+	engine.GET("/v1/models", func(c *gin.Context) {
+		c.Status(http.StatusTooManyRequests)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages?key="+knownSecrets[4], strings.NewReader(
+		fmt.Sprintf(`{"model":"claude-sonnet","api_key":"%s","messages":[{"role":"user","content":"hi"}],"padding":"%s"}`, knownSecrets[3], strings.Repeat("x", 300))))
+	req.Header.Set("Authorization", "Bearer "+knownSecrets[0])
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	raw, err := os.ReadFile(filepath.Join(dir, "access.log"))
+	if err != nil {
+		t.Fatalf("read access log: %v", err)
+	}
+	assertNoSecrets(t, string(raw))
+
+	var records []accessLogRecord
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		var record accessLogRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("access log line is not JSON: %v\n%s", err, scanner.Text())
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %d, want 2:\n%s", len(records), raw)
+	}
+
+	first := records[0]
+	if first.RequestID != "abcd1234" || first.Provider != "claude" || first.Model != "claude-sonnet" || first.Status != http.StatusOK {
+		t.Fatalf("unexpected record: %+v", first)
+	}
+	if first.ClientKeyID != clientKeyID(knownSecrets[0]) || !strings.HasPrefix(first.ClientKeyID, "key-") {
+		t.Fatalf("client key ID = %q", first.ClientKeyID)
+	}
+	if first.Tokens == nil || *first.Tokens != (AccessLogTokens{Input: 10, Output: 5, CacheCreation: 20, CacheRead: 300}) {
+		t.Fatalf("tokens = %+v", first.Tokens)
+	}
+	if first.TTFBMS == nil || first.ErrorCategory != "" {
+		t.Fatalf("ttfb = %v, error category = %q", first.TTFBMS, first.ErrorCategory)
+	}
+	if !first.RequestBodyTruncated || len(first.RequestBody) > 256 || !strings.Contains(first.RequestBody, `"model":"claude-sonnet"`) {
+		t.Fatalf("request body = %q (truncated %v)", first.RequestBody, first.RequestBodyTruncated)
+	}
+	if !strings.Contains(first.ResponseBody, `"id":"msg_1"`) {
+		t.Fatalf("response body = %q", first.ResponseBody)
+	}
+
+	second := records[1]
+	if second.ErrorCategory != "rate_limited" || second.Tokens != nil || second.RequestBody != "" {
+		t.Fatalf("unexpected record: %+v", second)
+	}
+}
+
+func TestAccessLogger_BodiesAreOptIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	logger := NewAccessLogger()
+	if err := logger.Configure(config.AccessLogConfig{Enable: true, File: "custom/access.jsonl"}, dir); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	t.Cleanup(func() { _ = logger.Close() })
+
+	engine := gin.New()
+	engine.Use(logger.Middleware())
+	engine.POST("/echo", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("body")))
+
+	raw, err := os.ReadFile(filepath.Join(dir, "custom", "access.jsonl"))
+	if err != nil {
+		t.Fatalf("read access log: %v", err)
+	}
+	var record accessLogRecord
+	if err = json.Unmarshal(bytes.TrimSpace(raw), &record); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if record.RequestBody != "" || record.ResponseBody != "" {
+		t.Fatalf("bodies logged without log-bodies: %+v", record)
+	}
+
+	if err = logger.Configure(config.AccessLogConfig{}, dir); err != nil {
+		t.Fatalf("Configure(disabled) error = %v", err)
+	}
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/echo", nil))
+	after, _ := os.ReadFile(filepath.Join(dir, "custom", "access.jsonl"))
+	if !bytes.Equal(raw, after) {
+		t.Fatalf("disabled access log kept writing")
+	}
+}
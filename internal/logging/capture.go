@@ -0,0 +1,260 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// CaptureHeader requests a debug capture of a single request. It is honored only when
+// capture.allow-header is enabled.
+const CaptureHeader = "X-CLIProxy-Capture"
+
+const (
+	defaultCaptureMaxBodyBytes = 1 << 20
+	defaultMaxCaptures         = 100
+
+	ginCaptureKey = "__capture_request__"
+)
+
+// Files of a capture bundle.
+const (
+	CaptureClientRequestFile    = "client-request.txt"
+	CaptureUpstreamRequestFile  = "upstream-request.txt"
+	CaptureUpstreamResponseFile = "upstream-response.txt"
+	CaptureClientResponseFile   = "client-response.txt"
+)
+
+// CaptureRequested reports whether the request is being captured. Executors use it to
+// record the upstream exchange even while request logging is off.
+func CaptureRequested(c *gin.Context) bool {
+	return c != nil && c.GetBool(ginCaptureKey)
+}
+
+// Capturer writes debug capture bundles. It is disabled until Configure enables it and
+// may be reconfigured at any time.
+type Capturer struct {
+	mu  sync.Mutex
+	cfg config.CaptureConfig
+	dir string
+}
+
+// NewCapturer returns a disabled capturer.
+func NewCapturer() *Capturer {
+	return &Capturer{}
+}
+
+// Configure applies cfg, resolving the capture directory against logDir.
+func (w *Capturer) Configure(cfg config.CaptureConfig, logDir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cfg = cfg
+	w.dir = CaptureDir(cfg, logDir)
+}
+
+// CaptureDir returns the directory capture bundles are written to for cfg.
+func CaptureDir(cfg config.CaptureConfig, logDir string) string {
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		dir = "captures"
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(logDir, dir)
+	}
+	return dir
+}
+
+// shouldCapture reports whether c is captured and returns the settings to use.
+func (w *Capturer) shouldCapture(c *gin.Context) (bool, config.CaptureConfig, string) {
+	w.mu.Lock()
+	cfg, dir := w.cfg, w.dir
+	w.mu.Unlock()
+	if cfg.Enable {
+		return true, cfg, dir
+	}
+	if !cfg.AllowHeader {
+		return false, cfg, dir
+	}
+	switch strings.ToLower(strings.TrimSpace(c.GetHeader(CaptureHeader))) {
+	case "1", "true", "yes", "on":
+		return true, cfg, dir
+	}
+	return false, cfg, dir
+}
+
+// Middleware returns a Gin middleware that writes a capture bundle for each captured
+// request. Management routes are never captured.
+func (w *Capturer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		capture, cfg, dir := w.shouldCapture(c)
+		if !capture || strings.HasPrefix(c.Request.URL.Path, "/v0/management") {
+			c.Next()
+			return
+		}
+		c.Set(ginCaptureKey, true)
+		var clientRequest []byte
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				clientRequest = body
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		maxBody := cfg.MaxBodyBytes
+		if maxBody <= 0 {
+			maxBody = defaultCaptureMaxBodyBytes
+		}
+		writer := &captureWriter{ResponseWriter: c.Writer, body: &cappedBuffer{limit: maxBody}}
+		c.Writer = writer
+		started := time.Now()
+
+		c.Next()
+
+		files := map[string][]byte{
+			CaptureClientRequestFile:    clientRequest,
+			CaptureUpstreamRequestFile:  ginBytes(c, "API_REQUEST"),
+			CaptureUpstreamResponseFile: ginBytes(c, "API_RESPONSE"),
+			CaptureClientResponseFile:   writer.body.Bytes(),
+		}
+		truncated := map[string]bool{CaptureClientResponseFile: writer.body.truncated}
+		requestID := GetGinRequestID(c)
+		if requestID == "" {
+			requestID = GenerateRequestID()
+		}
+		id := started.UTC().Format("20060102T150405.000Z") + "-" + requestID
+		maxCaptures := cfg.MaxCaptures
+		if maxCaptures <= 0 {
+			maxCaptures = defaultMaxCaptures
+		}
+		if err := writeCaptureBundle(filepath.Join(dir, id), files, truncated, maxBody); err != nil {
+			log.Warnf("capture: failed to write bundle %s: %v", id, err)
+			return
+		}
+		pruneCaptures(dir, maxCaptures)
+	}
+}
+
+func ginBytes(c *gin.Context, key string) []byte {
+	value, _ := c.Get(key)
+	data, _ := value.([]byte)
+	return data
+}
+
+// writeCaptureBundle writes each file of a bundle, truncated to maxBody with a marker
+// and with secrets redacted.
+func writeCaptureBundle(dir string, files map[string][]byte, truncated map[string]bool, maxBody int) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	for name, data := range files {
+		cut := truncated[name]
+		if len(data) > maxBody {
+			data, cut = data[:maxBody], true
+		}
+		content := RedactSecrets(string(data))
+		if cut {
+			content += fmt.Sprintf("\n...[truncated at %d bytes]\n", maxBody)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListCaptures returns the IDs of the latest n capture bundles in dir, newest first.
+// A missing directory has no captures.
+func ListCaptures(dir string, n int) ([]string, error) {
+	ids, err := captureIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	if n > 0 && len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids, nil
+}
+
+// captureIDs lists bundle directories in dir. Bundle IDs start with a UTC timestamp,
+// so they sort chronologically.
+func captureIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// pruneCaptures removes the oldest bundles in dir until at most keep remain.
+func pruneCaptures(dir string, keep int) {
+	ids, err := captureIDs(dir)
+	if err != nil {
+		return
+	}
+	for _, id := range ids[:max(len(ids)-keep, 0)] {
+		if errRemove := os.RemoveAll(filepath.Join(dir, id)); errRemove != nil {
+			log.Warnf("capture: failed to prune bundle %s: %v", id, errRemove)
+		}
+	}
+}
+
+// captureWriter keeps the start of the translated client response.
+type captureWriter struct {
+	gin.ResponseWriter
+	body *cappedBuffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	_, _ = w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(data string) (int, error) {
+	_, _ = w.body.Write([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+// ReadCapture returns the files of bundle id in dir by name. It reports os.ErrNotExist
+// for unknown bundles and rejects IDs that are not a plain directory name.
+func ReadCapture(dir, id string) (map[string]string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid capture ID %q", id)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, id))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(dir, id, entry.Name()))
+		if errRead != nil {
+			return nil, errRead
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files, nil
+}
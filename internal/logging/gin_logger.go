@@ -26,7 +26,10 @@ var aiAPIPrefixes = []string{
 	"/api/provider/",
 }
 
-const skipGinLogKey = "__gin_skip_request_logging__"
+const (
+	skipGinLogKey     = "__gin_skip_request_logging__"
+	ginRequestCostKey = "__gin_request_cost__"
+)
 
 // GinLogrusLogger returns a Gin middleware handler that logs HTTP requests and responses
 // using logrus. It captures request details including method, path, status code, latency,
@@ -78,6 +81,9 @@ func GinLogrusLogger() gin.HandlerFunc {
 			requestID = "--------"
 		}
 		logLine := fmt.Sprintf("%3d | %13v | %15s | %-7s \"%s\"", statusCode, latency, clientIP, method, path)
+		if cost := ginRequestCost(c); cost != "" {
+			logLine = logLine + " | est_cost=$" + cost
+		}
 		if errorMessage != "" {
 			logLine = logLine + " | " + errorMessage
 		}
@@ -148,3 +154,21 @@ func shouldSkipGinRequestLogging(c *gin.Context) bool {
 	flag, ok := val.(bool)
 	return ok && flag
 }
+
+// SetGinRequestCost records the estimated USD cost of the request so that
+// GinLogrusLogger appends it to the access log line.
+func SetGinRequestCost(c *gin.Context, cost string) {
+	if c == nil || cost == "" {
+		return
+	}
+	c.Set(ginRequestCostKey, cost)
+}
+
+func ginRequestCost(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	cost, _ := c.Get(ginRequestCostKey)
+	value, _ := cost.(string)
+	return value
+}
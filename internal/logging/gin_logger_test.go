@@ -1,12 +1,15 @@
 package logging
 
 import (
+	"bytes"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 )
 
 func TestGinLogrusRecoveryRepanicsErrAbortHandler(t *testing.T) {
@@ -58,3 +61,24 @@ func TestGinLogrusRecoveryHandlesRegularPanic(t *testing.T) {
 		t.Fatalf("expected 500, got %d", recorder.Code)
 	}
 }
+
+func TestGinLogrusLoggerAppendsRequestCost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	prevOut := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prevOut) })
+
+	engine := gin.New()
+	engine.Use(GinLogrusLogger())
+	engine.POST("/v1/messages", func(c *gin.Context) {
+		SetGinRequestCost(c, "0.012500")
+		c.Status(http.StatusOK)
+	})
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+	if !strings.Contains(buf.String(), "est_cost=$0.012500") {
+		t.Fatalf("expected cost in access log line, got %q", buf.String())
+	}
+}
@@ -75,7 +75,9 @@ func (m *LogFormatter) Format(entry *log.Entry) ([]byte, error) {
 	} else {
 		formatted = fmt.Sprintf("[%s] [%s] [%s] %s%s\n", timestamp, reqID, levelStr, message, fieldsStr)
 	}
-	buffer.WriteString(formatted)
+	// Redact the whole line so that credentials in messages and fields never reach the
+	// log, whatever the level.
+	buffer.WriteString(RedactSecrets(formatted))
 
 	return buffer.Bytes(), nil
 }
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces credential material in log output.
+const redactedValue = "[REDACTED]"
+
+var (
+	// authSchemePattern matches credentials following an HTTP auth scheme, as in
+	// "Authorization: Bearer <token>" or a printed http.Header map.
+	authSchemePattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	// secretFieldPattern matches key/value pairs whose key names credential material, in
+	// JSON ("refresh_token":"..."), JSON nested in a JSON string (\"api_key\":\"...\"),
+	// header ("X-Api-Key: ..."), query (api_key=...) and Go map (x-goog-api-key:[...]) forms.
+	secretFieldPattern = regexp.MustCompile(`(?i)((?:x-api-key|x-goog-api-key|api[-_]?key|access[-_]?token|refresh[-_]?token|id[-_]?token|auth[-_]?token|session[-_]?token|client[-_]?secret|secret[-_]?key|password|authorization|cookie)\\?"?\s*[:=]\s*\[?\\?"?)([^"\\\s,&\]}]+)`)
+	// queryKeyPattern matches the Gemini "key" query parameter.
+	queryKeyPattern = regexp.MustCompile(`([?&]key=)[^&\s"]+`)
+	// apiKeyPattern matches bare provider API keys that appear without a key name.
+	apiKeyPattern = regexp.MustCompile(`\b(?:sk-ant-|sk-|AIza)[A-Za-z0-9_-]{12,}`)
+)
+
+// RedactSecrets replaces bearer tokens, refresh tokens, API keys and similar credential
+// values in s with [REDACTED]. Applied to every log line and access log record, it
+// keeps credentials out of the logs at every level, debug included.
+func RedactSecrets(s string) string {
+	s = authSchemePattern.ReplaceAllString(s, "${1} "+redactedValue)
+	s = secretFieldPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := secretFieldPattern.FindStringSubmatch(match)
+		value := parts[2]
+		// Auth scheme credentials were redacted above; keep the scheme name readable.
+		if strings.Trim(value, "[]") == "REDACTED" || strings.EqualFold(value, "bearer") || strings.EqualFold(value, "basic") {
+			return match
+		}
+		return parts[1] + redactedValue
+	})
+	s = queryKeyPattern.ReplaceAllString(s, "${1}"+redactedValue)
+	return apiKeyPattern.ReplaceAllString(s, redactedValue)
+}
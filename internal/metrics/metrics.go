@@ -0,0 +1,142 @@
+// Package metrics holds the Prometheus request, latency, retry and failover metrics
+// exposed at /metrics, and the hooks that feed them from the request path.
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+// modelContextKey is the gin context key holding the model label of a request.
+const modelContextKey = "cliproxy.metrics.model"
+
+const (
+	// UnknownLabel labels requests that failed before a provider or model was resolved.
+	UnknownLabel = "unknown"
+	// OtherLabel labels models that are not in the model registry, so that arbitrary
+	// client strings cannot grow the label set.
+	OtherLabel = "other"
+)
+
+// Metrics is the set of request metrics registered with one registry.
+type Metrics struct {
+	requests  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	ttft      *prometheus.HistogramVec
+	retries   *prometheus.CounterVec
+	failovers *prometheus.CounterVec
+}
+
+// New registers the request metrics with reg:
+//
+//	proxy_requests_total{provider,model,status}
+//	proxy_request_duration_seconds{provider,model}
+//	proxy_time_to_first_token_seconds{provider,model}
+//	proxy_retries_total{provider}
+//	proxy_failovers_total{from,to}
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	latencyBuckets := []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "proxy_requests_total",
+			Help: "Requests handled, by serving provider, model and HTTP status.",
+		}, []string{"provider", "model", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "proxy_request_duration_seconds",
+			Help:    "Time from receiving a request to finishing its response.",
+			Buckets: latencyBuckets,
+		}, []string{"provider", "model"}),
+		ttft: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "proxy_time_to_first_token_seconds",
+			Help:    "Time from receiving a streaming request to writing its first event.",
+			Buckets: latencyBuckets,
+		}, []string{"provider", "model"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "proxy_retries_total",
+			Help: "Requests retried after every credential of the providers failed.",
+		}, []string{"provider"}),
+		failovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "proxy_failovers_total",
+			Help: "Requests moved to the next stage of a failover chain.",
+		}, []string{"from", "to"}),
+	}
+	for _, collector := range []prometheus.Collector{m.requests, m.duration, m.ttft, m.retries, m.failovers} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveRequest records a finished request.
+func (m *Metrics) ObserveRequest(provider, model string, status int, elapsed time.Duration) {
+	m.requests.WithLabelValues(provider, model, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(provider, model).Observe(elapsed.Seconds())
+}
+
+// ObserveTimeToFirstToken records the delay before the first event of a stream.
+func (m *Metrics) ObserveTimeToFirstToken(provider, model string, elapsed time.Duration) {
+	m.ttft.WithLabelValues(provider, model).Observe(elapsed.Seconds())
+}
+
+// active receives the retry and failover hooks. It is nil while metrics are disabled.
+var active atomic.Pointer[Metrics]
+
+// SetActive routes RecordRetry and RecordFailover to m. A nil m disables them.
+func SetActive(m *Metrics) {
+	active.Store(m)
+}
+
+// RecordRetry counts a retry of a request over providers.
+func RecordRetry(providers []string) {
+	if m := active.Load(); m != nil {
+		m.retries.WithLabelValues(strings.Join(providers, ",")).Inc()
+	}
+}
+
+// RecordFailover counts a request moving from one failover stage to the next.
+func RecordFailover(from, to []string) {
+	if m := active.Load(); m != nil {
+		m.failovers.WithLabelValues(strings.Join(from, ","), strings.Join(to, ",")).Inc()
+	}
+}
+
+// ModelLabel returns the label for model: its base name without a thinking suffix when
+// the model registry knows it, OtherLabel otherwise.
+func ModelLabel(model string) string {
+	base := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if base == "" {
+		return UnknownLabel
+	}
+	if registry.GetGlobalRegistry().GetModelInfo(base, "") == nil {
+		return OtherLabel
+	}
+	return base
+}
+
+// SetModel records the routed model of the request carried by ctx, for the request
+// metrics middleware to label it with.
+func SetModel(ctx context.Context, model string) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Set(modelContextKey, ModelLabel(model))
+	}
+}
+
+// Model returns the model label stored by SetModel, or UnknownLabel.
+func Model(c *gin.Context) string {
+	if model := c.GetString(modelContextKey); model != "" {
+		return model
+	}
+	return UnknownLabel
+}
@@ -45,6 +45,9 @@ type ModelInfo struct {
 	ContextLength int `json:"context_length,omitempty"`
 	// MaxCompletionTokens is the maximum completion tokens
 	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+	// DefaultOutputTokens is the output token limit used when a request sets none.
+	// Zero falls back to the model's maximum.
+	DefaultOutputTokens int `json:"default_output_tokens,omitempty"`
 	// SupportedParameters lists supported parameters
 	SupportedParameters []string `json:"supported_parameters,omitempty"`
 	// SupportedEndpoints lists supported API endpoints (e.g., "/chat/completions", "/responses").
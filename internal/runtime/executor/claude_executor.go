@@ -120,6 +120,7 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	body = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", body, originalTranslated, requestedModel)
+	body = applyOutputTokenLimits(ctx, e.cfg, baseModel, to.String(), "", body)
 
 	// Disable thinking if tool_choice forces tool use (Anthropic API constraint)
 	body = disableThinkingIfToolChoiceForced(body)
@@ -203,6 +204,8 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 		for _, line := range lines {
 			if detail, ok := parseClaudeStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
+			} else if detail, ok = parseClaudeStreamStartUsage(line); ok {
+				reporter.observePartial(detail)
 			}
 		}
 	} else {
@@ -261,6 +264,7 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	body = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", body, originalTranslated, requestedModel)
+	body = applyOutputTokenLimits(ctx, e.cfg, baseModel, to.String(), "", body)
 
 	// Disable thinking if tool_choice forces tool use (Anthropic API constraint)
 	body = disableThinkingIfToolChoiceForced(body)
@@ -347,6 +351,8 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 				appendAPIResponseChunk(ctx, e.cfg, line)
 				if detail, ok := parseClaudeStreamUsage(line); ok {
 					reporter.publish(ctx, detail)
+				} else if detail, ok = parseClaudeStreamStartUsage(line); ok {
+					reporter.observePartial(detail)
 				}
 				if isClaudeOAuthToken(apiKey) {
 					line = stripClaudeToolPrefixFromStreamLine(line, claudeToolPrefix)
@@ -374,6 +380,8 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			if detail, ok := parseClaudeStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
+			} else if detail, ok = parseClaudeStreamStartUsage(line); ok {
+				reporter.observePartial(detail)
 			}
 			if isClaudeOAuthToken(apiKey) {
 				line = stripClaudeToolPrefixFromStreamLine(line, claudeToolPrefix)
@@ -521,6 +529,18 @@ func (e *ClaudeExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (
 	auth.Metadata["type"] = "claude"
 	now := time.Now().Format(time.RFC3339)
 	auth.Metadata["last_refresh"] = now
+	// Anthropic rotates the refresh token on every refresh; keep a login-time token
+	// storage in sync so persisting it does not write the consumed token back.
+	if storage, ok := auth.Storage.(*claudeauth.ClaudeTokenStorage); ok && storage != nil {
+		storage.AccessToken = td.AccessToken
+		storage.RefreshToken = refreshToken
+		if td.RefreshToken != "" {
+			storage.RefreshToken = td.RefreshToken
+		}
+		storage.Email = td.Email
+		storage.Expire = td.Expire
+		storage.LastRefresh = now
+	}
 	return auth, nil
 }
 
@@ -2,8 +2,18 @@ package executor
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 )
 
@@ -61,3 +71,69 @@ func TestStripClaudeToolPrefixFromStreamLine(t *testing.T) {
 		t.Fatalf("content_block.name = %q, want %q", got, "alpha")
 	}
 }
+
+func TestClaudeExecutorStream_ClientCancelClosesUpstream(t *testing.T) {
+	upstreamClosed := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		_, _ = fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":42,\"output_tokens\":1}}}\n\n")
+		flusher.Flush()
+		// A slow upstream: one delta per 50ms for far longer than the test runs.
+		for i := 0; i < 200; i++ {
+			select {
+			case <-r.Context().Done():
+				close(upstreamClosed)
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+			_, _ = fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"x\"}}\n\n")
+			flusher.Flush()
+		}
+	}))
+	defer upstream.Close()
+
+	plugin := &usageCapturePlugin{provider: "claude", records: make(chan usage.Record, 8)}
+	registerUsageCapturePlugin(t, plugin)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth := &cliproxyauth.Auth{ID: "cancel-test", Provider: "claude", Attributes: map[string]string{"api_key": "sk-test", "base_url": upstream.URL}}
+	chunks, err := NewClaudeExecutor(&config.Config{}).ExecuteStream(ctx, auth, cliproxyexecutor.Request{
+		Model:   "claude-sonnet-4-5",
+		Payload: []byte(`{"model":"claude-sonnet-4-5","max_tokens":64,"messages":[{"role":"user","content":"hi"}],"stream":true}`),
+	}, cliproxyexecutor.Options{Stream: true, SourceFormat: sdktranslator.FromString("claude")})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	if first := <-chunks; first.Err != nil {
+		t.Fatalf("first chunk: %v", first.Err)
+	}
+
+	cancel()
+	select {
+	case <-upstreamClosed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream connection stayed open after the client cancelled")
+	}
+	// The executor stops reading and closes its stream once the upstream read fails.
+	deadline := time.After(2 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-chunks:
+		case <-deadline:
+			t.Fatal("stream not closed after cancellation")
+		}
+	}
+
+	for {
+		record := captureUsageRecord(t, plugin)
+		if record.AuthID != auth.ID {
+			continue
+		}
+		if !record.Cancelled || record.Failed || record.Detail.InputTokens != 42 {
+			t.Fatalf("usage record = %+v, want cancelled with the 42 input tokens reported so far", record)
+		}
+		return
+	}
+}
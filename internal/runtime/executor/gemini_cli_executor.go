@@ -135,6 +135,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 	basePayload = fixGeminiCLIImageAspectRatio(baseModel, basePayload)
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	basePayload = applyPayloadConfigWithRoot(e.cfg, baseModel, "gemini", "request", basePayload, originalTranslated, requestedModel)
+	basePayload = applyOutputTokenLimits(ctx, e.cfg, baseModel, "gemini", "request", basePayload)
 
 	action := "generateContent"
 	if req.Metadata != nil {
@@ -289,6 +290,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 	basePayload = fixGeminiCLIImageAspectRatio(baseModel, basePayload)
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	basePayload = applyPayloadConfigWithRoot(e.cfg, baseModel, "gemini", "request", basePayload, originalTranslated, requestedModel)
+	basePayload = applyOutputTokenLimits(ctx, e.cfg, baseModel, "gemini", "request", basePayload)
 
 	projectID := resolveGeminiProjectID(auth)
 
@@ -564,10 +566,52 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 }
 
 // Refresh refreshes the authentication credentials (no-op for Gemini CLI).
-func (e *GeminiCLIExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+// Refresh renews the OAuth access token ahead of expiry using the stored refresh token.
+// Multi-project credentials share one token that is refreshed on use, so they are left as-is.
+func (e *GeminiCLIExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	if auth == nil {
+		return nil, fmt.Errorf("gemini-cli executor: auth is nil")
+	}
+	if geminicli.ResolveSharedCredential(auth.Runtime) != nil || auth.Metadata == nil {
+		return auth, nil
+	}
+	var base map[string]any
+	if tokenRaw, ok := auth.Metadata["token"].(map[string]any); ok && tokenRaw != nil {
+		base = cloneMap(tokenRaw)
+	} else {
+		base = make(map[string]any)
+	}
+	refreshToken := stringValue(base, "refresh_token")
+	if refreshToken == "" {
+		refreshToken = stringValue(auth.Metadata, "refresh_token")
+	}
+	if refreshToken == "" {
+		return auth, nil
+	}
+
+	ctxToken := ctx
+	if httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0); httpClient != nil {
+		ctxToken = context.WithValue(ctxToken, oauth2.HTTPClient, httpClient)
+	}
+	// A token without an access token is always refreshed by the token source.
+	tok, err := geminiCLIOAuthConfig().TokenSource(ctxToken, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return nil, fmt.Errorf("gemini-cli executor: refresh token: %w", err)
+	}
+	updateGeminiCLITokenMetadata(auth, base, tok)
+	auth.Metadata["last_refresh"] = time.Now().Format(time.RFC3339)
 	return auth, nil
 }
 
+func geminiCLIOAuthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     geminiOAuthClientID,
+		ClientSecret: geminiOAuthClientSecret,
+		Scopes:       geminiOAuthScopes,
+		Endpoint:     google.Endpoint,
+	}
+}
+
 func prepareGeminiCLITokenSource(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth) (oauth2.TokenSource, map[string]any, error) {
 	metadata := geminiOAuthMetadata(auth)
 	if auth == nil || metadata == nil {
@@ -605,12 +649,7 @@ func prepareGeminiCLITokenSource(ctx context.Context, cfg *config.Config, auth *
 		}
 	}
 
-	conf := &oauth2.Config{
-		ClientID:     geminiOAuthClientID,
-		ClientSecret: geminiOAuthClientSecret,
-		Scopes:       geminiOAuthScopes,
-		Endpoint:     google.Endpoint,
-	}
+	conf := geminiCLIOAuthConfig()
 
 	ctxToken := ctx
 	if httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0); httpClient != nil {
@@ -14,9 +14,12 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokencount"
+	geminiembeddings "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/embeddings"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -113,6 +116,10 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
 	defer reporter.trackFailure(ctx, &err)
 
+	if opts.Alt == "embeddings" {
+		return e.executeEmbeddings(ctx, auth, baseModel, req.Payload, reporter)
+	}
+
 	// Official Gemini API via API key or OAuth bearer
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("gemini")
@@ -132,6 +139,7 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	body = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", body, originalTranslated, requestedModel)
+	body = applyOutputTokenLimits(ctx, e.cfg, baseModel, to.String(), "", body)
 	body, _ = sjson.SetBytes(body, "model", baseModel)
 
 	action := "generateContent"
@@ -209,6 +217,94 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	return resp, nil
 }
 
+// executeEmbeddings serves an OpenAI embeddings request with batchEmbedContents,
+// splitting the inputs into batches Gemini accepts. Gemini reports no token usage for
+// embeddings, so prompt tokens are estimated from the input text.
+func (e *GeminiExecutor) executeEmbeddings(ctx context.Context, auth *cliproxyauth.Auth, baseModel string, payload []byte, reporter *usageReporter) (resp cliproxyexecutor.Response, err error) {
+	inputs, err := geminiembeddings.ParseInputs(payload)
+	if err != nil {
+		return resp, statusErr{code: http.StatusBadRequest, msg: err.Error()}
+	}
+	apiKey, bearer := geminiCreds(auth)
+	url := fmt.Sprintf("%s/%s/models/%s:batchEmbedContents", resolveGeminiBaseURL(auth), glAPIVersion, baseModel)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+
+	values := make([]string, 0, len(inputs))
+	var promptTokens int64
+	for _, batch := range geminiembeddings.Batches(inputs) {
+		body := geminiembeddings.ConvertOpenAIEmbeddingsRequestToGemini(baseModel, batch, payload)
+		httpReq, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if errReq != nil {
+			return resp, errReq
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			httpReq.Header.Set("x-goog-api-key", apiKey)
+		} else if bearer != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		applyGeminiHeaders(httpReq, auth)
+		recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+			URL:       url,
+			Method:    http.MethodPost,
+			Headers:   httpReq.Header.Clone(),
+			Body:      body,
+			Provider:  e.Identifier(),
+			AuthID:    authID,
+			AuthLabel: authLabel,
+			AuthType:  authType,
+			AuthValue: authValue,
+		})
+		data, errBatch := e.doEmbeddingsRequest(ctx, httpClient, httpReq)
+		if errBatch != nil {
+			return resp, errBatch
+		}
+		batchValues := geminiembeddings.GeminiEmbeddingValues(data)
+		if len(batchValues) != len(batch) {
+			return resp, statusErr{code: http.StatusBadGateway, msg: fmt.Sprintf("gemini returned %d embeddings for %d inputs", len(batchValues), len(batch))}
+		}
+		values = append(values, batchValues...)
+		for _, text := range batch {
+			promptTokens += tokencount.HeuristicCount(baseModel, []byte(text))
+		}
+	}
+
+	reporter.publish(ctx, usage.Detail{InputTokens: promptTokens, TotalTokens: promptTokens})
+	out := geminiembeddings.BuildOpenAIEmbeddingsResponse(baseModel, values, promptTokens, payload)
+	return cliproxyexecutor.Response{Payload: out}, nil
+}
+
+func (e *GeminiExecutor) doEmbeddingsRequest(ctx context.Context, httpClient *http.Client, httpReq *http.Request) ([]byte, error) {
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("gemini executor: close response body error: %v", errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		logWithRequestID(ctx).Debugf("request error, error status: %d, error message: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), data))
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(data)}
+	}
+	return data, nil
+}
+
 // ExecuteStream performs a streaming request to the Gemini API.
 func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
 	if opts.Alt == "responses/compact" {
@@ -239,6 +335,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := payloadRequestedModel(opts, req.Model)
 	body = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", body, originalTranslated, requestedModel)
+	body = applyOutputTokenLimits(ctx, e.cfg, baseModel, to.String(), "", body)
 	body, _ = sjson.SetBytes(body, "model", baseModel)
 
 	baseURL := resolveGeminiBaseURL(auth)
@@ -317,8 +414,12 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			if len(payload) == 0 {
 				continue
 			}
+			// Usage on the final chunk survives the filter; earlier chunks carry the
+			// running usage, kept in case the client cancels.
 			if detail, ok := parseGeminiStreamUsage(payload); ok {
 				reporter.publish(ctx, detail)
+			} else if detail, ok = parseGeminiStreamUsage(line); ok {
+				reporter.observePartial(detail)
 			}
 			lines := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, body, bytes.Clone(payload), &param)
 			for i := range lines {
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestGeminiExecutorEmbeddingsBatches(t *testing.T) {
+	var paths []string
+	var batchSizes []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		requests := gjson.GetBytes(body, "requests")
+		batchSizes = append(batchSizes, requests.Get("#").Int())
+		if got := requests.Get("0.outputDimensionality").Int(); got != 8 {
+			t.Errorf("outputDimensionality = %d, want 8", got)
+		}
+		var embeddings []string
+		requests.ForEach(func(_, request gjson.Result) bool {
+			embeddings = append(embeddings, fmt.Sprintf(`{"values":[%d.5]}`, len(embeddings)))
+			return true
+		})
+		_, _ = w.Write([]byte(`{"embeddings":[` + strings.Join(embeddings, ",") + `]}`))
+	}))
+	defer server.Close()
+
+	inputs := make([]string, 150)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("%q", fmt.Sprintf("text %d", i))
+	}
+	payload := []byte(`{"model":"gemini-embedding-001","dimensions":8,"input":[` + strings.Join(inputs, ",") + `]}`)
+	executor := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"api_key": "test", "base_url": server.URL}}
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gemini-embedding-001",
+		Payload: payload,
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "embeddings"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+
+	if len(paths) != 2 || paths[0] != "/v1beta/models/gemini-embedding-001:batchEmbedContents" {
+		t.Fatalf("paths = %v", paths)
+	}
+	if batchSizes[0] != 100 || batchSizes[1] != 50 {
+		t.Fatalf("batch sizes = %v, want [100 50]", batchSizes)
+	}
+	data := gjson.GetBytes(resp.Payload, "data")
+	if data.Get("#").Int() != 150 {
+		t.Fatalf("got %d embeddings, want 150", data.Get("#").Int())
+	}
+	if data.Get("100.index").Int() != 100 || data.Get("100.embedding.0").Float() != 0.5 {
+		t.Fatalf("embedding 100 = %s", data.Get("100").Raw)
+	}
+	if gjson.GetBytes(resp.Payload, "usage.prompt_tokens").Int() <= 0 {
+		t.Fatalf("expected estimated prompt tokens, got %s", gjson.GetBytes(resp.Payload, "usage").Raw)
+	}
+}
+
+func TestOpenAICompatExecutorEmbeddingsPassthrough(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]}],"usage":{"prompt_tokens":3,"total_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": server.URL + "/v1", "api_key": "test"}}
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "text-embedding-3-small",
+		Payload: []byte(`{"model":"text-embedding-3-small","input":[1,2,3],"encoding_format":"base64"}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "embeddings"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotPath != "/v1/embeddings" {
+		t.Fatalf("path = %q, want /v1/embeddings", gotPath)
+	}
+	if gjson.GetBytes(gotBody, "input").Raw != "[1,2,3]" || gjson.GetBytes(gotBody, "encoding_format").String() != "base64" {
+		t.Fatalf("body was not passed through: %s", gotBody)
+	}
+	if gjson.GetBytes(resp.Payload, "data.0.embedding.0").Float() != 0.1 {
+		t.Fatalf("payload = %s", resp.Payload)
+	}
+}
@@ -1,9 +1,11 @@
 package executor
 
 import (
+	"context"
 	"strings"
 	"testing"
 
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 )
@@ -240,3 +242,18 @@ func TestTranslateGitHubCopilotResponsesStreamToClaude_TextLifecycle(t *testing.
 		t.Fatalf("completed events = %#v, want message_delta + message_stop", completed)
 	}
 }
+
+func TestGitHubCopilotCountTokens_EstimatesLocally(t *testing.T) {
+	t.Parallel()
+
+	resp, err := NewGitHubCopilotExecutor(nil).CountTokens(context.Background(), nil, cliproxyexecutor.Request{
+		Model:   "gpt-4o",
+		Payload: []byte(`{"model":"gpt-4o","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Hello there"}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai")})
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Payload, "usage.prompt_tokens").Int(); got <= 0 {
+		t.Fatalf("expected a positive prompt token count, got %s", resp.Payload)
+	}
+}
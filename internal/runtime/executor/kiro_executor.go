@@ -22,7 +22,9 @@ import (
 
 	"github.com/google/uuid"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokencount"
 	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
 	kiroopenai "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/openai"
@@ -252,6 +254,9 @@ var (
 func getKiroPooledHTTPClient() *http.Client {
 	kiroHTTPClientPoolOnce.Do(func() {
 		transport := &http.Transport{
+			// Honor HTTPS_PROXY like the other executors when no proxy is configured
+			Proxy: http.ProxyFromEnvironment,
+
 			// Connection pool settings
 			MaxIdleConns:        100,              // Max idle connections across all hosts
 			MaxIdleConnsPerHost: 20,               // Max idle connections per host
@@ -294,13 +299,7 @@ func getKiroPooledHTTPClient() *http.Client {
 // This provides the best of both worlds: custom proxy support + connection reuse.
 func newKiroHTTPClientWithPooling(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
 	// Check if a proxy is configured - if so, we need a custom client
-	var proxyURL string
-	if auth != nil {
-		proxyURL = strings.TrimSpace(auth.ProxyURL)
-	}
-	if proxyURL == "" && cfg != nil {
-		proxyURL = strings.TrimSpace(cfg.ProxyURL)
-	}
+	proxyURL, _ := proxyRoute(cfg, auth)
 
 	// If proxy is configured, use the existing proxy-aware client (doesn't pool)
 	if proxyURL != "" {
@@ -538,6 +537,17 @@ func buildKiroPayloadForFormat(body []byte, modelID, profileArn, origin string,
 	}
 }
 
+// kiroBodyFormat returns the format of the translated request body that
+// buildKiroPayloadForFormat turns into the Kiro payload.
+func kiroBodyFormat(sourceFormat sdktranslator.Format) string {
+	switch sourceFormat.String() {
+	case "openai", "kiro":
+		return sourceFormat.String()
+	default:
+		return "claude"
+	}
+}
+
 // NewKiroExecutor creates a new Kiro executor instance.
 func NewKiroExecutor(cfg *config.Config) *KiroExecutor {
 	return &KiroExecutor{cfg: cfg}
@@ -690,6 +700,7 @@ func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applySystemPromptConfig(e.cfg, req.Model, "kiro", kiroBodyFormat(from), "", body, payloadRequestedModel(opts, req.Model))
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 
@@ -997,27 +1008,15 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 
 			// 1. Estimate InputTokens if missing
 			if usageInfo.InputTokens == 0 {
-				if enc, encErr := getTokenizer(req.Model); encErr == nil {
-					if inp, countErr := countOpenAIChatTokens(enc, opts.OriginalRequest); countErr == nil {
-						usageInfo.InputTokens = inp
-					}
-				}
+				usageInfo.InputTokens = countKiroInputTokens(req.Model, sdktranslator.FromString(kiroBodyFormat(from)), body)
+				usageInfo.Estimated = true
 			}
 
-			// 2. Estimate OutputTokens if missing and content is available
-			if usageInfo.OutputTokens == 0 && len(content) > 0 {
-				// Use tiktoken for more accurate output token calculation
-				if enc, encErr := getTokenizer(req.Model); encErr == nil {
-					if tokenCount, countErr := enc.Count(content); countErr == nil {
-						usageInfo.OutputTokens = int64(tokenCount)
-					}
-				}
-				// Fallback to character count estimation if tiktoken fails
-				if usageInfo.OutputTokens == 0 {
-					usageInfo.OutputTokens = int64(len(content) / 4)
-					if usageInfo.OutputTokens == 0 {
-						usageInfo.OutputTokens = 1
-					}
+			// 2. Estimate OutputTokens if missing
+			if usageInfo.OutputTokens == 0 {
+				if output := estimateKiroOutputTokens(req.Model, content, toolUses); output > 0 {
+					usageInfo.OutputTokens = output
+					usageInfo.Estimated = true
 				}
 			}
 
@@ -1119,6 +1118,7 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applySystemPromptConfig(e.cfg, req.Model, "kiro", kiroBodyFormat(from), "", body, payloadRequestedModel(opts, req.Model))
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 
@@ -1825,7 +1825,7 @@ func (e *KiroExecutor) parseEventStream(body io.Reader) (string, []kiroclaude.Ki
 
 	// Tool use state tracking for input buffering and deduplication
 	processedIDs := make(map[string]bool)
-	var currentToolUse *kiroclaude.ToolUseState
+	toolAssembler := kiroclaude.NewToolUseAssembler(processedIDs)
 
 	// Upstream usage tracking - Kiro API returns credit usage and context percentage
 	var upstreamContextPercentage float64 // Context usage percentage from upstream (e.g., 78.56)
@@ -1964,9 +1964,7 @@ func (e *KiroExecutor) parseEventStream(body io.Reader) (string, []kiroclaude.Ki
 
 		case "toolUseEvent":
 			// Handle dedicated tool use events with input buffering
-			completedToolUses, newState := kiroclaude.ProcessToolUseEvent(event, currentToolUse, processedIDs)
-			currentToolUse = newState
-			toolUses = append(toolUses, completedToolUses...)
+			toolUses = append(toolUses, toolAssembler.ProcessToolUseEvent(event)...)
 
 		case "supplementaryWebLinksEvent":
 			if inputTokens, ok := event["inputTokens"].(float64); ok {
@@ -2249,11 +2247,25 @@ func (e *KiroExecutor) parseEventStream(body io.Reader) (string, []kiroclaude.Ki
 		}
 	}
 
+	// Complete tool uses the stream ended without a stop event for
+	toolUses = append(toolUses, toolAssembler.Flush()...)
+
 	// Parse embedded tool calls from content (e.g., [Called tool_name with args: {...}])
 	contentStr := content.String()
 	cleanedContent, embeddedToolUses := kiroclaude.ParseEmbeddedToolCalls(contentStr, processedIDs)
 	toolUses = append(toolUses, embeddedToolUses...)
 
+	// Return tool uses whose input never became valid JSON as text
+	validToolUses := toolUses[:0]
+	for _, tu := range toolUses {
+		if tu.InvalidInput {
+			cleanedContent += kiroclaude.InvalidToolInputText(tu)
+			continue
+		}
+		validToolUses = append(validToolUses, tu)
+	}
+	toolUses = validToolUses
+
 	// Deduplicate all tool uses
 	toolUses = kiroclaude.DeduplicateToolUses(toolUses)
 
@@ -2497,7 +2509,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 
 	// Tool use state tracking for input buffering and deduplication
 	processedIDs := make(map[string]bool)
-	var currentToolUse *kiroclaude.ToolUseState
+	toolAssembler := kiroclaude.NewToolUseAssembler(processedIDs)
 
 	// NOTE: Duplicate content filtering removed - it was causing legitimate repeated
 	// content (like consecutive newlines) to be incorrectly filtered out.
@@ -2533,78 +2545,78 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 	// Buffer for handling partial tag matches at chunk boundaries
 	var pendingContent strings.Builder // Buffer content that might be part of a tag
 
-	// Pre-calculate input tokens from request if possible
-	// Kiro uses Claude format, so try Claude format first, then OpenAI format, then fallback
-	if enc, err := getTokenizer(model); err == nil {
-		var inputTokens int64
-		var countMethod string
-
-		// Try Claude format first (Kiro uses Claude API format)
-		if inp, err := countClaudeChatTokens(enc, claudeBody); err == nil && inp > 0 {
-			inputTokens = inp
-			countMethod = "claude"
-		} else if inp, err := countOpenAIChatTokens(enc, originalReq); err == nil && inp > 0 {
-			// Fallback to OpenAI format (for OpenAI-compatible requests)
-			inputTokens = inp
-			countMethod = "openai"
-		} else {
-			// Final fallback: estimate from raw request size (roughly 4 chars per token)
-			inputTokens = int64(len(claudeBody) / 4)
-			if inputTokens == 0 && len(claudeBody) > 0 {
-				inputTokens = 1
-			}
-			countMethod = "estimate"
-		}
-
-		totalUsage.InputTokens = inputTokens
-		log.Debugf("kiro: streamToChannel pre-calculated input tokens: %d (method: %s, claude body: %d bytes, original req: %d bytes)",
-			totalUsage.InputTokens, countMethod, len(claudeBody), len(originalReq))
-	}
+	// Pre-calculate input tokens from the request for message_start; input tokens
+	// reported by the upstream replace the estimate
+	totalUsage.InputTokens = countKiroInputTokens(model, sdktranslator.FromString(kiroBodyFormat(targetFormat)), claudeBody)
+	estimatedInputTokens := totalUsage.InputTokens
+	log.Debugf("kiro: streamToChannel pre-calculated input tokens: %d (claude body: %d bytes, original req: %d bytes)",
+		totalUsage.InputTokens, len(claudeBody), len(originalReq))
 
 	contentBlockIndex := -1
 	messageStartSent := false
 	isTextBlockOpen := false
-	var outputLen int
+	var emittedToolUses []kiroclaude.KiroToolUse // Tool uses sent to the client, for output token estimation
 
-	// Ensure usage is published even on early return
+	// Usage is published on every return. A stream that ends early records the usage
+	// counted so far, as a cancellation when the client went away and a failure otherwise.
+	completed := false
 	defer func() {
-		reporter.publish(ctx, totalUsage)
-	}()
-
-	for {
-		select {
-		case <-ctx.Done():
+		if completed {
+			reporter.publish(ctx, totalUsage)
 			return
-		default:
 		}
+		reporter.observePartial(totalUsage)
+		reporter.publishFailure(ctx)
+	}()
 
-		msg, eventErr := e.readEventStreamMessage(reader)
-		if eventErr != nil {
-			// Log the error
-			log.Errorf("kiro: streamToChannel error: %v", eventErr)
+	// emitToolUses sends completed tool uses as tool_use content blocks
+	emitToolUses := func(toolUses []kiroclaude.KiroToolUse) {
+		emittedToolUses = append(emittedToolUses, toolUses...)
+		for _, tu := range toolUses {
+			// Input that never became valid JSON is returned as assistant text
+			if tu.InvalidInput {
+				if !isTextBlockOpen {
+					contentBlockIndex++
+					isTextBlockOpen = true
+					blockStart := kiroclaude.BuildClaudeContentBlockStartEvent(contentBlockIndex, "text", "", "")
+					sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStart, &translatorParam)
+					for _, chunk := range sseData {
+						if chunk != "" {
+							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+						}
+					}
+				}
+				claudeEvent := kiroclaude.BuildClaudeStreamEvent(kiroclaude.InvalidToolInputText(tu), contentBlockIndex)
+				sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, claudeEvent, &translatorParam)
+				for _, chunk := range sseData {
+					if chunk != "" {
+						out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+					}
+				}
+				continue
+			}
 
-			// Send error to channel for client notification
-			out <- cliproxyexecutor.StreamChunk{Err: eventErr}
-			return
-		}
-		if msg == nil {
-			// Normal end of stream (EOF)
-			// Flush any incomplete tool use before ending stream
-			if currentToolUse != nil && !processedIDs[currentToolUse.ToolUseID] {
-				log.Warnf("kiro: flushing incomplete tool use at EOF: %s (ID: %s)", currentToolUse.Name, currentToolUse.ToolUseID)
-				fullInput := currentToolUse.InputBuffer.String()
-				repairedJSON := kiroclaude.RepairJSON(fullInput)
-				var finalInput map[string]interface{}
-				if err := json.Unmarshal([]byte(repairedJSON), &finalInput); err != nil {
-					log.Warnf("kiro: failed to parse incomplete tool input at EOF: %v", err)
-					finalInput = make(map[string]interface{})
+			// Check if this tool was truncated - emit with SOFT_LIMIT_REACHED marker
+			if tu.IsTruncated {
+				hasTruncatedTools = true
+				log.Infof("kiro: streamToChannel emitting truncated tool with SOFT_LIMIT_REACHED: %s (ID: %s)", tu.Name, tu.ToolUseID)
+
+				// Close text block if open
+				if isTextBlockOpen && contentBlockIndex >= 0 {
+					blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
+					sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
+					for _, chunk := range sseData {
+						if chunk != "" {
+							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+						}
+					}
+					isTextBlockOpen = false
 				}
 
-				processedIDs[currentToolUse.ToolUseID] = true
 				contentBlockIndex++
 
-				// Send tool_use content block
-				blockStart := kiroclaude.BuildClaudeContentBlockStartEvent(contentBlockIndex, "tool_use", currentToolUse.ToolUseID, currentToolUse.Name)
+				// Emit tool_use with SOFT_LIMIT_REACHED marker input
+				blockStart := kiroclaude.BuildClaudeContentBlockStartEvent(contentBlockIndex, "tool_use", tu.ToolUseID, tu.Name)
 				sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStart, &translatorParam)
 				for _, chunk := range sseData {
 					if chunk != "" {
@@ -2612,9 +2624,14 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 					}
 				}
 
-				// Send tool input as delta
-				inputBytes, _ := json.Marshal(finalInput)
-				inputDelta := kiroclaude.BuildClaudeInputJsonDeltaEvent(string(inputBytes), contentBlockIndex)
+				// Build SOFT_LIMIT_REACHED marker input
+				markerInput := map[string]interface{}{
+					"_status":  "SOFT_LIMIT_REACHED",
+					"_message": "Tool output was truncated. Split content into smaller chunks (max 300 lines). Due to potential model hallucination, you MUST re-fetch the current working directory and generate the correct file_path.",
+				}
+
+				markerJSON, _ := json.Marshal(markerInput)
+				inputDelta := kiroclaude.BuildClaudeInputJsonDeltaEvent(string(markerJSON), contentBlockIndex)
 				sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, inputDelta, &translatorParam)
 				for _, chunk := range sseData {
 					if chunk != "" {
@@ -2622,7 +2639,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 					}
 				}
 
-				// Close block
+				// Close tool_use block
 				blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
 				sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
 				for _, chunk := range sseData {
@@ -2631,9 +2648,79 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 					}
 				}
 
-				hasToolUses = true
-				currentToolUse = nil
+				hasToolUses = true // Keep this so stop_reason = tool_use
+				continue
+			}
+
+			hasToolUses = true
+
+			// Close text block if open
+			if isTextBlockOpen && contentBlockIndex >= 0 {
+				blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
+				sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
+				for _, chunk := range sseData {
+					if chunk != "" {
+						out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+					}
+				}
+				isTextBlockOpen = false
+			}
+
+			contentBlockIndex++
+
+			blockStart := kiroclaude.BuildClaudeContentBlockStartEvent(contentBlockIndex, "tool_use", tu.ToolUseID, tu.Name)
+			sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStart, &translatorParam)
+			for _, chunk := range sseData {
+				if chunk != "" {
+					out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+				}
+			}
+
+			if tu.Input != nil {
+				inputJSON, err := json.Marshal(tu.Input)
+				if err != nil {
+					log.Debugf("kiro: failed to marshal tool input in toolUseEvent: %v", err)
+				} else {
+					inputDelta := kiroclaude.BuildClaudeInputJsonDeltaEvent(string(inputJSON), contentBlockIndex)
+					sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, inputDelta, &translatorParam)
+					for _, chunk := range sseData {
+						if chunk != "" {
+							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+						}
+					}
+				}
+			}
+
+			blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
+			sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
+			for _, chunk := range sseData {
+				if chunk != "" {
+					out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
+				}
 			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		msg, eventErr := e.readEventStreamMessage(reader)
+		if eventErr != nil {
+			// Log the error
+			log.Errorf("kiro: streamToChannel error: %v", eventErr)
+
+			// Send error to channel for client notification
+			out <- cliproxyexecutor.StreamChunk{Err: eventErr}
+			return
+		}
+		if msg == nil {
+			// Normal end of stream (EOF)
+			// Flush any incomplete tool use before ending stream
+			emitToolUses(toolAssembler.Flush())
 
 			// DISABLED: Tag-based pending character flushing
 			// This code block was used for tag-based thinking detection which has been
@@ -2914,7 +3001,6 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 				// filtered out legitimate repeated content (like consecutive newlines "\n\n").
 				// Streaming naturally can have identical chunks that are valid content.
 
-				outputLen += len(contentDelta)
 				// Accumulate content for streaming token calculation
 				accumulatedContent.WriteString(contentDelta)
 
@@ -2928,20 +3014,8 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 				}
 
 				if shouldSendUsageUpdate {
-					// Calculate current output tokens using tiktoken
-					var currentOutputTokens int64
-					if enc, encErr := getTokenizer(model); encErr == nil {
-						if tokenCount, countErr := enc.Count(accumulatedContent.String()); countErr == nil {
-							currentOutputTokens = int64(tokenCount)
-						}
-					}
-					// Fallback to character estimation if tiktoken fails
-					if currentOutputTokens == 0 {
-						currentOutputTokens = int64(accumulatedContent.Len() / 4)
-						if currentOutputTokens == 0 {
-							currentOutputTokens = 1
-						}
-					}
+					// Calculate current output tokens the same way as the final estimate
+					currentOutputTokens := estimateKiroOutputTokens(model, accumulatedContent.String(), emittedToolUses)
 
 					// Only send update if token count has changed significantly (at least 10 tokens)
 					if currentOutputTokens > lastReportedOutputTokens+10 {
@@ -3288,114 +3362,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 
 		case "toolUseEvent":
 			// Handle dedicated tool use events with input buffering
-			completedToolUses, newState := kiroclaude.ProcessToolUseEvent(event, currentToolUse, processedIDs)
-			currentToolUse = newState
-
-			// Emit completed tool uses
-			for _, tu := range completedToolUses {
-				// Check if this tool was truncated - emit with SOFT_LIMIT_REACHED marker
-				if tu.IsTruncated {
-					hasTruncatedTools = true
-					log.Infof("kiro: streamToChannel emitting truncated tool with SOFT_LIMIT_REACHED: %s (ID: %s)", tu.Name, tu.ToolUseID)
-
-					// Close text block if open
-					if isTextBlockOpen && contentBlockIndex >= 0 {
-						blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
-						sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
-						for _, chunk := range sseData {
-							if chunk != "" {
-								out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-							}
-						}
-						isTextBlockOpen = false
-					}
-
-					contentBlockIndex++
-
-					// Emit tool_use with SOFT_LIMIT_REACHED marker input
-					blockStart := kiroclaude.BuildClaudeContentBlockStartEvent(contentBlockIndex, "tool_use", tu.ToolUseID, tu.Name)
-					sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStart, &translatorParam)
-					for _, chunk := range sseData {
-						if chunk != "" {
-							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-						}
-					}
-
-					// Build SOFT_LIMIT_REACHED marker input
-					markerInput := map[string]interface{}{
-						"_status":  "SOFT_LIMIT_REACHED",
-						"_message": "Tool output was truncated. Split content into smaller chunks (max 300 lines). Due to potential model hallucination, you MUST re-fetch the current working directory and generate the correct file_path.",
-					}
-
-					markerJSON, _ := json.Marshal(markerInput)
-					inputDelta := kiroclaude.BuildClaudeInputJsonDeltaEvent(string(markerJSON), contentBlockIndex)
-					sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, inputDelta, &translatorParam)
-					for _, chunk := range sseData {
-						if chunk != "" {
-							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-						}
-					}
-
-					// Close tool_use block
-					blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
-					sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
-					for _, chunk := range sseData {
-						if chunk != "" {
-							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-						}
-					}
-
-					hasToolUses = true // Keep this so stop_reason = tool_use
-					continue
-				}
-
-				hasToolUses = true
-
-				// Close text block if open
-				if isTextBlockOpen && contentBlockIndex >= 0 {
-					blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
-					sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
-					for _, chunk := range sseData {
-						if chunk != "" {
-							out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-						}
-					}
-					isTextBlockOpen = false
-				}
-
-				contentBlockIndex++
-
-				blockStart := kiroclaude.BuildClaudeContentBlockStartEvent(contentBlockIndex, "tool_use", tu.ToolUseID, tu.Name)
-				sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStart, &translatorParam)
-				for _, chunk := range sseData {
-					if chunk != "" {
-						out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-					}
-				}
-
-				if tu.Input != nil {
-					inputJSON, err := json.Marshal(tu.Input)
-					if err != nil {
-						log.Debugf("kiro: failed to marshal tool input in toolUseEvent: %v", err)
-					} else {
-						inputDelta := kiroclaude.BuildClaudeInputJsonDeltaEvent(string(inputJSON), contentBlockIndex)
-						sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, inputDelta, &translatorParam)
-						for _, chunk := range sseData {
-							if chunk != "" {
-								out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-							}
-						}
-					}
-				}
-
-				blockStop := kiroclaude.BuildClaudeContentBlockStopEvent(contentBlockIndex)
-				sseData = sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, blockStop, &translatorParam)
-				for _, chunk := range sseData {
-					if chunk != "" {
-						out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
-					}
-				}
-			}
+			emitToolUses(toolAssembler.ProcessToolUseEvent(event))
 
 		case "supplementaryWebLinksEvent":
 			if inputTokens, ok := event["inputTokens"].(float64); ok {
@@ -3586,35 +3553,14 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 		}
 	}
 
-	// Streaming token calculation - calculate output tokens from accumulated content
+	// Streaming token calculation - estimate output tokens from the response text and tool calls
 	// Only use local estimation if server didn't provide usage (server-side usage takes priority)
-	if totalUsage.OutputTokens == 0 && accumulatedContent.Len() > 0 {
-		// Try to use tiktoken for accurate counting
-		if enc, err := getTokenizer(model); err == nil {
-			if tokenCount, countErr := enc.Count(accumulatedContent.String()); countErr == nil {
-				totalUsage.OutputTokens = int64(tokenCount)
-				log.Debugf("kiro: streamToChannel calculated output tokens using tiktoken: %d", totalUsage.OutputTokens)
-			} else {
-				// Fallback on count error: estimate from character count
-				totalUsage.OutputTokens = int64(accumulatedContent.Len() / 4)
-				if totalUsage.OutputTokens == 0 {
-					totalUsage.OutputTokens = 1
-				}
-				log.Debugf("kiro: streamToChannel tiktoken count failed, estimated from chars: %d", totalUsage.OutputTokens)
-			}
-		} else {
-			// Fallback: estimate from character count (roughly 4 chars per token)
-			totalUsage.OutputTokens = int64(accumulatedContent.Len() / 4)
-			if totalUsage.OutputTokens == 0 {
-				totalUsage.OutputTokens = 1
-			}
-			log.Debugf("kiro: streamToChannel estimated output tokens from chars: %d (content len: %d)", totalUsage.OutputTokens, accumulatedContent.Len())
-		}
-	} else if totalUsage.OutputTokens == 0 && outputLen > 0 {
-		// Legacy fallback using outputLen
-		totalUsage.OutputTokens = int64(outputLen / 4)
-		if totalUsage.OutputTokens == 0 {
-			totalUsage.OutputTokens = 1
+	if totalUsage.OutputTokens == 0 {
+		if output := estimateKiroOutputTokens(model, accumulatedContent.String(), emittedToolUses); output > 0 {
+			totalUsage.OutputTokens = output
+			totalUsage.Estimated = true
+			log.Debugf("kiro: streamToChannel estimated output tokens: %d (content len: %d, tool uses: %d)",
+				totalUsage.OutputTokens, accumulatedContent.Len(), len(emittedToolUses))
 		}
 	}
 
@@ -3636,6 +3582,10 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 				upstreamContextPercentage, calculatedInputTokens, localEstimate)
 		}
 	}
+	// Input tokens still holding the pre-calculated value were not reported by the upstream
+	if upstreamContextPercentage <= 0 && totalUsage.InputTokens == estimatedInputTokens {
+		totalUsage.Estimated = true
+	}
 
 	totalUsage.TotalTokens = totalUsage.InputTokens + totalUsage.OutputTokens
 
@@ -3669,7 +3619,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 	}
 
 	// Send message_delta event
-	msgDelta := kiroclaude.BuildClaudeMessageDeltaEvent(stopReason, totalUsage)
+	msgDelta := kiroclaude.BuildClaudeMessageDeltaEvent(model, stopReason, totalUsage)
 	sseData := sdktranslator.TranslateStream(ctx, sdktranslator.FromString("kiro"), targetFormat, model, originalReq, claudeBody, msgDelta, &translatorParam)
 	for _, chunk := range sseData {
 		if chunk != "" {
@@ -3685,7 +3635,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 			out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk + "\n\n")}
 		}
 	}
-	// reporter.publish is called via defer
+	completed = true
 }
 
 // NOTE: Claude SSE event builders moved to internal/translator/kiro/claude/kiro_claude_stream.go
@@ -3694,45 +3644,58 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 // CountTokens counts tokens locally using tiktoken since Kiro API doesn't expose a token counting endpoint.
 // This provides approximate token counts for client requests.
 func (e *KiroExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	// Use tiktoken for local token counting
-	enc, err := getTokenizer(req.Model)
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("kiro")
+	count := countKiroInputTokens(req.Model, from, req.Payload)
+	translated := sdktranslator.TranslateTokenCount(ctx, to, from, count, []byte(fmt.Sprintf(`{"count":%d}`, count)))
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+// countKiroInputTokens counts prompt tokens locally, since Kiro has no count-tokens API.
+// Claude payloads include the system prompt and tool definitions; when no tokenizer is
+// available the count falls back to a per-model-family character heuristic.
+func countKiroInputTokens(model string, from sdktranslator.Format, payload []byte) int64 {
+	enc, err := tokencount.Tokenizer(model)
 	if err != nil {
 		log.Warnf("kiro: CountTokens failed to get tokenizer: %v, falling back to estimate", err)
-		// Fallback: estimate from payload size (roughly 4 chars per token)
-		estimatedTokens := len(req.Payload) / 4
-		if estimatedTokens == 0 && len(req.Payload) > 0 {
-			estimatedTokens = 1
-		}
-		return cliproxyexecutor.Response{
-			Payload: []byte(fmt.Sprintf(`{"count":%d}`, estimatedTokens)),
-		}, nil
+		return tokencount.HeuristicCount(model, payload)
 	}
 
-	// Try to count tokens from the request payload
-	var totalTokens int64
+	counter := tokencount.CountOpenAIChat
+	if from == sdktranslator.FromString("claude") {
+		counter = tokencount.CountClaudeChat
+	}
+	if tokens, countErr := counter(enc, payload); countErr == nil && tokens > 0 {
+		log.Debugf("kiro: CountTokens counted %d tokens using %s format", tokens, from)
+		return tokens
+	}
+	// Fallback: count raw payload tokens
+	if tokenCount, countErr := enc.Count(string(payload)); countErr == nil {
+		log.Debugf("kiro: CountTokens counted %d tokens from raw payload", tokenCount)
+		return int64(tokenCount)
+	}
+	tokens := tokencount.HeuristicCount(model, payload)
+	log.Debugf("kiro: CountTokens estimated %d tokens from payload size", tokens)
+	return tokens
+}
 
-	// Try OpenAI chat format first
-	if tokens, countErr := countOpenAIChatTokens(enc, req.Payload); countErr == nil && tokens > 0 {
-		totalTokens = tokens
-		log.Debugf("kiro: CountTokens counted %d tokens using OpenAI chat format", totalTokens)
-	} else {
-		// Fallback: count raw payload tokens
-		if tokenCount, countErr := enc.Count(string(req.Payload)); countErr == nil {
-			totalTokens = int64(tokenCount)
-			log.Debugf("kiro: CountTokens counted %d tokens from raw payload", totalTokens)
-		} else {
-			// Final fallback: estimate from payload size
-			totalTokens = int64(len(req.Payload) / 4)
-			if totalTokens == 0 && len(req.Payload) > 0 {
-				totalTokens = 1
-			}
-			log.Debugf("kiro: CountTokens estimated %d tokens from payload size", totalTokens)
+// estimateKiroOutputTokens estimates the completion tokens of a Kiro response for when
+// the upstream reports none: the response text plus the name and JSON input of every
+// tool call, counted with tokencount.EstimateText.
+func estimateKiroOutputTokens(model, text string, toolUses []kiroclaude.KiroToolUse) int64 {
+	var b strings.Builder
+	b.WriteString(text)
+	for _, tu := range toolUses {
+		b.WriteString(tu.Name)
+		if tu.InvalidInput {
+			b.WriteString(tu.RawInput)
+			continue
+		}
+		if input, err := json.Marshal(tu.Input); err == nil {
+			b.Write(input)
 		}
 	}
-
-	return cliproxyexecutor.Response{
-		Payload: []byte(fmt.Sprintf(`{"count":%d}`, totalTokens)),
-	}, nil
+	return tokencount.EstimateText(model, b.String())
 }
 
 // Refresh refreshes the Kiro OAuth token.
@@ -3980,7 +3943,7 @@ func (e *KiroExecutor) reloadAuthFromFile(auth *cliproxyauth.Auth) (*cliproxyaut
 	}
 
 	// 读取文件
-	raw, err := os.ReadFile(authPath)
+	raw, err := authcrypt.ReadFile(authPath)
 	if err != nil {
 		return nil, fmt.Errorf("kiro executor: failed to read auth file %s: %w", authPath, err)
 	}
@@ -4400,8 +4363,8 @@ func (e *KiroExecutor) handleWebSearchStream(
 
 		// Estimate input tokens using tokenizer (matching streamToChannel pattern)
 		var totalUsage usage.Detail
-		if enc, tokErr := getTokenizer(req.Model); tokErr == nil {
-			if inp, e := countClaudeChatTokens(enc, req.Payload); e == nil && inp > 0 {
+		if enc, tokErr := tokencount.Tokenizer(req.Model); tokErr == nil {
+			if inp, e := tokencount.CountClaudeChat(enc, req.Payload); e == nil && inp > 0 {
 				totalUsage.InputTokens = inp
 			} else {
 				totalUsage.InputTokens = int64(len(req.Payload) / 4)
@@ -4421,6 +4384,7 @@ func (e *KiroExecutor) handleWebSearchStream(
 			if accumulatedOutputLen > 0 && totalUsage.OutputTokens == 0 {
 				totalUsage.OutputTokens = 1
 			}
+			totalUsage.Estimated = true
 			reporter.publish(ctx, totalUsage)
 		}()
 
@@ -4673,6 +4637,7 @@ func (e *KiroExecutor) callKiroAndBuffer(
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applySystemPromptConfig(e.cfg, req.Model, "kiro", kiroBodyFormat(from), "", body, payloadRequestedModel(opts, req.Model))
 	log.Debugf("kiro/websearch GAR request: %d bytes", len(body))
 
 	kiroModelID := e.mapModelToKiro(req.Model)
@@ -4716,6 +4681,7 @@ func (e *KiroExecutor) callKiroDirectStream(
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applySystemPromptConfig(e.cfg, req.Model, "kiro", kiroBodyFormat(from), "", body, payloadRequestedModel(opts, req.Model))
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
@@ -4766,6 +4732,7 @@ func (e *KiroExecutor) executeNonStreamFallback(
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
 	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body = applySystemPromptConfig(e.cfg, req.Model, "kiro", kiroBodyFormat(from), "", body, payloadRequestedModel(opts, req.Model))
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func kiroCountTokens(t *testing.T, payload string) int64 {
+	t.Helper()
+	resp, err := NewKiroExecutor(nil).CountTokens(context.Background(), nil, cliproxyexecutor.Request{
+		Model:   "claude-sonnet-4.5",
+		Payload: []byte(payload),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("claude")})
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	result := gjson.ParseBytes(resp.Payload)
+	if !result.Get("input_tokens").Exists() || result.Get("count").Exists() {
+		t.Fatalf("expected Claude count_tokens shape, got %s", resp.Payload)
+	}
+	return result.Get("input_tokens").Int()
+}
+
+func TestKiroCountTokens_ClaudeShapeIncludesSystemAndTools(t *testing.T) {
+	base := kiroCountTokens(t, `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Summarize the repository layout."}]}`)
+	if base <= 0 {
+		t.Fatalf("expected a positive count, got %d", base)
+	}
+
+	withSystem := kiroCountTokens(t, `{"model":"claude-sonnet-4.5","system":[{"type":"text","text":"You are a meticulous senior engineer reviewing Go code."}],"messages":[{"role":"user","content":"Summarize the repository layout."}]}`)
+	if withSystem <= base {
+		t.Fatalf("system prompt should be counted: %d <= %d", withSystem, base)
+	}
+
+	withTools := kiroCountTokens(t, `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Summarize the repository layout."}],"tools":[{"name":"read_file","description":"Read a file from the workspace","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}}]}`)
+	if withTools <= base {
+		t.Fatalf("tool definitions should be counted: %d <= %d", withTools, base)
+	}
+}
+
+// TestEstimateKiroOutputTokens pins the output estimates reported for Kiro responses
+// without upstream usage, so tokenizer or formula changes show up as a test diff.
+func TestEstimateKiroOutputTokens(t *testing.T) {
+	readTool := []kiroclaude.KiroToolUse{{Name: "Read", Input: map[string]interface{}{"file_path": "/src/main.go"}}}
+	invalidTool := []kiroclaude.KiroToolUse{{Name: "Read", InvalidInput: true, RawInput: `{"file_path": /etc/hosts}`}}
+	tests := []struct {
+		model    string
+		text     string
+		toolUses []kiroclaude.KiroToolUse
+		want     int64
+	}{
+		{model: "claude-sonnet-4.5", text: "Hello, world!", want: 4},
+		{model: "claude-sonnet-4.5", text: "The quick brown fox jumps over the lazy dog.", want: 11},
+		{model: "gpt-4o", text: "The quick brown fox jumps over the lazy dog.", want: 10},
+		{model: "claude-sonnet-4.5", text: "Reading the file now.", toolUses: readTool, want: 14},
+		{model: "claude-sonnet-4.5", toolUses: invalidTool, want: 11},
+		{model: "claude-sonnet-4.5", want: 0},
+	}
+	for _, tt := range tests {
+		if got := estimateKiroOutputTokens(tt.model, tt.text, tt.toolUses); got != tt.want {
+			t.Fatalf("estimateKiroOutputTokens(%q, %q, %d tools) = %d, want %d", tt.model, tt.text, len(tt.toolUses), got, tt.want)
+		}
+	}
+}
@@ -51,7 +51,7 @@ type upstreamAttempt struct {
 
 // recordAPIRequest stores the upstream request metadata in Gin context for request logging.
 func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequestLog) {
-	if cfg == nil || !cfg.RequestLog {
+	if !recordsUpstream(ctx, cfg) {
 		return
 	}
 	ginCtx := ginContextFrom(ctx)
@@ -98,7 +98,7 @@ func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequ
 
 // recordAPIResponseMetadata captures upstream response status/header information for the latest attempt.
 func recordAPIResponseMetadata(ctx context.Context, cfg *config.Config, status int, headers http.Header) {
-	if cfg == nil || !cfg.RequestLog {
+	if !recordsUpstream(ctx, cfg) {
 		return
 	}
 	ginCtx := ginContextFrom(ctx)
@@ -124,7 +124,7 @@ func recordAPIResponseMetadata(ctx context.Context, cfg *config.Config, status i
 
 // recordAPIResponseError adds an error entry for the latest attempt when no HTTP response is available.
 func recordAPIResponseError(ctx context.Context, cfg *config.Config, err error) {
-	if cfg == nil || !cfg.RequestLog || err == nil {
+	if err == nil || !recordsUpstream(ctx, cfg) {
 		return
 	}
 	ginCtx := ginContextFrom(ctx)
@@ -149,7 +149,7 @@ func recordAPIResponseError(ctx context.Context, cfg *config.Config, err error)
 
 // appendAPIResponseChunk appends an upstream response chunk to Gin context for request logging.
 func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byte) {
-	if cfg == nil || !cfg.RequestLog {
+	if !recordsUpstream(ctx, cfg) {
 		return
 	}
 	data := bytes.TrimSpace(chunk)
@@ -182,6 +182,15 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 	updateAggregatedResponse(ginCtx, attempts)
 }
 
+// recordsUpstream reports whether the upstream exchange is recorded in the Gin context:
+// when request logging is on or the request is being captured.
+func recordsUpstream(ctx context.Context, cfg *config.Config) bool {
+	if cfg == nil {
+		return false
+	}
+	return cfg.RequestLog || logging.CaptureRequested(ginContextFrom(ctx))
+}
+
 func ginContextFrom(ctx context.Context) *gin.Context {
 	ginCtx, _ := ctx.Value("gin").(*gin.Context)
 	return ginCtx
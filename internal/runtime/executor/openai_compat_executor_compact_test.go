@@ -10,6 +10,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 )
@@ -56,3 +57,34 @@ func TestOpenAICompatExecutorCompactPassthrough(t *testing.T) {
 		t.Fatalf("payload = %s", string(resp.Payload))
 	}
 }
+
+func TestOpenAICompatExecutorStreamRecordsLastUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// The provider repeats its cumulative usage on every chunk.
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a\"}}],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1}}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":4}}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	plugin := &usageCapturePlugin{provider: "compat-stream-usage", records: make(chan usage.Record, 4)}
+	registerUsageCapturePlugin(t, plugin)
+	executor := NewOpenAICompatExecutor(plugin.provider, &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": server.URL + "/v1", "api_key": "test"}}
+	chunks, err := executor.ExecuteStream(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "compat-model",
+		Payload: []byte(`{"model":"compat-model","messages":[{"role":"user","content":"hi"}],"stream":true}`),
+	}, cliproxyexecutor.Options{Stream: true, SourceFormat: sdktranslator.FromString("openai")})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream chunk: %v", chunk.Err)
+		}
+	}
+	if record := captureUsageRecord(t, plugin); record.Detail.OutputTokens != 4 || record.Cancelled || record.Failed {
+		t.Fatalf("usage record = %+v", record)
+	}
+}
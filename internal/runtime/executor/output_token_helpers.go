@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// MaxTokensClampedHeader names the response header carrying the output token limit
+// applied to a request that asked for more than the model's maximum.
+const MaxTokensClampedHeader = "X-CLIProxy-Max-Tokens-Clamped"
+
+// fallbackClaudeMaxTokens fills the required Claude max_tokens when neither the config
+// nor the model registry knows the model's output limits.
+const fallbackClaudeMaxTokens = 32000
+
+// minClaudeThinkingBudget is the smallest thinking budget Claude accepts.
+const minClaudeThinkingBudget = 1024
+
+// applyOutputTokenLimits enforces the output token limits of model on a translated
+// payload in the given protocol ("claude", or "gemini" and "antigravity" with their
+// parameters under root). A request without an output limit gets the model's default;
+// Claude requires max_tokens, so its default falls back to the model maximum and then
+// to fallbackClaudeMaxTokens. A limit above the model maximum is clamped with a warning
+// and reported in MaxTokensClampedHeader. When extended thinking is on, the thinking
+// budget is lowered below the resulting limit; Claude thinking is turned off instead
+// when that would take the budget below minClaudeThinkingBudget. Other protocols are
+// returned unchanged.
+func applyOutputTokenLimits(ctx context.Context, cfg *config.Config, model, protocol, root string, payload []byte) []byte {
+	var maxPath, budgetPath string
+	switch protocol {
+	case "claude":
+		maxPath, budgetPath = "max_tokens", "thinking.budget_tokens"
+		if gjson.GetBytes(payload, "thinking.type").String() != "enabled" {
+			budgetPath = ""
+		}
+	case "gemini", "antigravity":
+		maxPath, budgetPath = "generationConfig.maxOutputTokens", "generationConfig.thinkingConfig.thinkingBudget"
+	default:
+		return payload
+	}
+	if root != "" {
+		maxPath = root + "." + maxPath
+		if budgetPath != "" {
+			budgetPath = root + "." + budgetPath
+		}
+	}
+
+	defaultTokens, maxTokens := outputTokenLimits(cfg, model)
+	if protocol == "claude" && defaultTokens <= 0 {
+		defaultTokens = maxTokens
+		if defaultTokens <= 0 {
+			defaultTokens = fallbackClaudeMaxTokens
+		}
+	}
+
+	limit := gjson.GetBytes(payload, maxPath).Int()
+	switch {
+	case limit <= 0 && defaultTokens > 0:
+		limit = int64(defaultTokens)
+		payload, _ = sjson.SetBytes(payload, maxPath, limit)
+	case maxTokens > 0 && limit > int64(maxTokens):
+		log.Warnf("output tokens: clamped %d requested output tokens to the %d maximum of %s", limit, maxTokens, model)
+		limit = int64(maxTokens)
+		payload, _ = sjson.SetBytes(payload, maxPath, limit)
+		if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+			ginCtx.Header(MaxTokensClampedHeader, strconv.FormatInt(limit, 10))
+		}
+	}
+
+	if budgetPath != "" && limit > 1 {
+		if budget := gjson.GetBytes(payload, budgetPath).Int(); budget >= limit {
+			if protocol == "claude" && limit-1 < minClaudeThinkingBudget {
+				log.Warnf("output tokens: disabled thinking for %s, the %d output token limit leaves no room for the minimum budget", model, limit)
+				payload, _ = sjson.DeleteBytes(payload, strings.TrimSuffix(budgetPath, ".budget_tokens"))
+			} else {
+				payload, _ = sjson.SetBytes(payload, budgetPath, limit-1)
+			}
+		}
+	}
+	return payload
+}
+
+// outputTokenLimits returns the default and maximum output tokens of model from
+// request-limits.output-tokens, falling back to the model registry; zero means unknown.
+// A default above the maximum is lowered to it.
+func outputTokenLimits(cfg *config.Config, model string) (defaultTokens, maxTokens int) {
+	if cfg != nil {
+		if limits, ok := cfg.RequestLimits.OutputTokens[strings.ToLower(strings.TrimSpace(model))]; ok {
+			defaultTokens, maxTokens = limits.Default, limits.Max
+		}
+	}
+	if info := registry.LookupModelInfo(model); info != nil {
+		if defaultTokens <= 0 {
+			defaultTokens = info.DefaultOutputTokens
+		}
+		if maxTokens <= 0 {
+			maxTokens = info.OutputTokenLimit
+			if maxTokens <= 0 {
+				maxTokens = info.MaxCompletionTokens
+			}
+		}
+	}
+	if maxTokens > 0 && defaultTokens > maxTokens {
+		defaultTokens = maxTokens
+	}
+	return defaultTokens, maxTokens
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyOutputTokenLimits(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RequestLimits.OutputTokens = map[string]config.OutputTokenLimits{
+		"limited-model": {Default: 8000, Max: 16000},
+		"capped-model":  {Max: 4096},
+		"tiny-model":    {Max: 1000},
+	}
+	tests := []struct {
+		name           string
+		model          string
+		protocol       string
+		root           string
+		payload        string
+		wantMax        int64
+		wantBudget     int64
+		wantClampedTo  string
+		wantNoThinking bool
+	}{
+		{name: "claude default from config", model: "limited-model", protocol: "claude", payload: `{}`, wantMax: 8000},
+		{name: "claude default falls back to the maximum", model: "capped-model", protocol: "claude", payload: `{}`, wantMax: 4096},
+		{name: "claude unknown model", model: "unknown-model", protocol: "claude", payload: `{}`, wantMax: fallbackClaudeMaxTokens},
+		{name: "claude within the maximum", model: "limited-model", protocol: "claude", payload: `{"max_tokens":12000}`, wantMax: 12000},
+		{name: "claude clamped", model: "limited-model", protocol: "claude", payload: `{"max_tokens":100000}`, wantMax: 16000, wantClampedTo: "16000"},
+		{
+			name: "claude thinking budget kept below the limit", model: "capped-model", protocol: "claude",
+			payload: `{"max_tokens":8192,"thinking":{"type":"enabled","budget_tokens":6000}}`, wantMax: 4096, wantBudget: 4095, wantClampedTo: "4096",
+		},
+		{
+			name: "claude thinking disabled below the minimum budget", model: "tiny-model", protocol: "claude",
+			payload: `{"max_tokens":8192,"thinking":{"type":"enabled","budget_tokens":6000}}`, wantMax: 1000, wantClampedTo: "1000", wantNoThinking: true,
+		},
+		{name: "gemini without default", model: "capped-model", protocol: "gemini", payload: `{}`, wantMax: 0},
+		{name: "gemini default", model: "limited-model", protocol: "gemini", payload: `{}`, wantMax: 8000},
+		{
+			name: "gemini cli clamped with thinking budget", model: "limited-model", protocol: "gemini", root: "request",
+			payload: `{"request":{"generationConfig":{"maxOutputTokens":50000,"thinkingConfig":{"thinkingBudget":24576}}}}`, wantMax: 16000, wantBudget: 15999, wantClampedTo: "16000",
+		},
+		{name: "other protocols untouched", model: "capped-model", protocol: "openai", payload: `{"max_tokens":100000}`, wantMax: 100000},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(recorder)
+			ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+			out := applyOutputTokenLimits(ctx, cfg, tt.model, tt.protocol, tt.root, []byte(tt.payload))
+			prefix := ""
+			if tt.root != "" {
+				prefix = tt.root + "."
+			}
+			maxPath, budgetPath := "max_tokens", "thinking.budget_tokens"
+			if tt.protocol == "gemini" {
+				maxPath, budgetPath = "generationConfig.maxOutputTokens", "generationConfig.thinkingConfig.thinkingBudget"
+			}
+			if got := gjson.GetBytes(out, prefix+maxPath).Int(); got != tt.wantMax {
+				t.Fatalf("output limit = %d, want %d (payload %s)", got, tt.wantMax, out)
+			}
+			if tt.wantBudget != 0 {
+				if got := gjson.GetBytes(out, prefix+budgetPath).Int(); got != tt.wantBudget {
+					t.Fatalf("thinking budget = %d, want %d", got, tt.wantBudget)
+				}
+			}
+			if thinking := gjson.GetBytes(out, "thinking"); tt.wantNoThinking && thinking.Exists() {
+				t.Fatalf("thinking = %s, want it removed", thinking.Raw)
+			}
+			if got := recorder.Header().Get(MaxTokensClampedHeader); got != tt.wantClampedTo {
+				t.Fatalf("%s = %q, want %q", MaxTokensClampedHeader, got, tt.wantClampedTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// proxySelfTestTimeout bounds each upstream probe of ProxySelfTest.
+const proxySelfTestTimeout = 10 * time.Second
+
+// proxySelfTestTargets are the upstream endpoints probed for each provider.
+var proxySelfTestTargets = map[string]string{
+	"claude":         "https://api.anthropic.com",
+	"gemini":         "https://generativelanguage.googleapis.com",
+	"gemini-cli":     "https://cloudcode-pa.googleapis.com",
+	"antigravity":    "https://cloudcode-pa.googleapis.com",
+	"codex":          "https://chatgpt.com",
+	"github-copilot": "https://api.githubcopilot.com",
+	"qwen":           "https://portal.qwen.ai",
+	"kiro":           "https://codewhisperer.us-east-1.amazonaws.com",
+}
+
+// ProxySelfTest logs the proxy route each provider's credentials will use and probes
+// the provider's upstream through that route. Every distinct provider and route pair
+// is reported once; providers without a known upstream are only logged.
+func ProxySelfTest(ctx context.Context, cfg *config.Config, auths []*cliproxyauth.Auth) {
+	type route struct {
+		provider string
+		proxyURL string
+		source   string
+		auth     *cliproxyauth.Auth
+	}
+	seen := make(map[string]bool)
+	var routes []route
+	for _, auth := range auths {
+		if auth == nil || auth.Disabled {
+			continue
+		}
+		provider := strings.ToLower(auth.Provider)
+		proxyURL, source := proxyRoute(cfg, auth)
+		key := provider + "|" + proxyURL
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		routes = append(routes, route{provider: provider, proxyURL: proxyURL, source: source, auth: auth})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].provider != routes[j].provider {
+			return routes[i].provider < routes[j].provider
+		}
+		return routes[i].proxyURL < routes[j].proxyURL
+	})
+
+	for _, r := range routes {
+		via := describeProxyRoute(r.proxyURL, r.source)
+		target, ok := proxySelfTestTargets[r.provider]
+		if !ok {
+			log.Infof("proxy self-test: %s via %s (no known upstream to probe)", r.provider, via)
+			continue
+		}
+		err := probeUpstream(ctx, newProxyAwareHTTPClient(ctx, cfg, r.auth, proxySelfTestTimeout), target)
+		if err != nil {
+			log.Warnf("proxy self-test: %s via %s: %s unreachable: %v", r.provider, via, target, err)
+			continue
+		}
+		log.Infof("proxy self-test: %s via %s: %s reachable", r.provider, via, target)
+	}
+}
+
+// describeProxyRoute renders a proxy route for logs without proxy credentials.
+func describeProxyRoute(proxyURL, source string) string {
+	switch {
+	case proxyURL == "":
+		return "environment (HTTPS_PROXY or direct)"
+	case proxyURL == directProxy:
+		return "direct (" + source + ")"
+	}
+	if parsed, err := url.Parse(proxyURL); err == nil {
+		proxyURL = parsed.Redacted()
+	}
+	return proxyURL + " (" + source + ")"
+}
+
+// probeUpstream sends a HEAD request to target. Any HTTP response counts as reachable.
+func probeUpstream(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
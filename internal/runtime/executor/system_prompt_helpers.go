@@ -0,0 +1,297 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// applySystemPromptConfig applies the payload.system-prompt rules matching model and
+// protocol to a payload in format, with its fields under root. Protocol selects the
+// rules and format describes the payload; they differ only for Kiro, whose rules run
+// on the client payload before it is converted to the Kiro request.
+func applySystemPromptConfig(cfg *config.Config, model, protocol, format, root string, payload []byte, requestedModel string) []byte {
+	if cfg == nil || len(cfg.Payload.SystemPrompt) == 0 || len(payload) == 0 {
+		return payload
+	}
+	out, _ := applySystemPromptRules(cfg.Payload.SystemPrompt, protocol, format, root, payload, payloadModelCandidates(model, requestedModel))
+	return out
+}
+
+// PreviewSystemPrompt returns the system prompt a request to model over protocol would
+// reach the upstream with when the client sent system, after the payload.system-prompt
+// rules, along with the number of rules that matched. The rules run on a payload in the
+// protocol's own format (Claude for Kiro and unknown protocols), and the resulting
+// system blocks, messages, parts or input items are joined with blank lines.
+func PreviewSystemPrompt(cfg *config.Config, model, protocol, system string) (string, int) {
+	if cfg == nil {
+		return system, 0
+	}
+	format := protocol
+	payload := []byte(`{}`)
+	switch protocol {
+	case "openai":
+		if system != "" {
+			payload, _ = sjson.SetBytes(payload, "messages.0", map[string]string{"role": "system", "content": system})
+		}
+	case "gemini", "antigravity":
+		if system != "" {
+			payload, _ = sjson.SetBytes(payload, "systemInstruction.parts.0.text", system)
+		}
+	case "codex", "openai-response":
+		if system != "" {
+			payload, _ = sjson.SetBytes(payload, "instructions", system)
+		}
+	default:
+		format = "claude"
+		if system != "" {
+			payload, _ = sjson.SetBytes(payload, "system", system)
+		}
+	}
+	out, matched := applySystemPromptRules(cfg.Payload.SystemPrompt, protocol, format, "", payload, payloadModelCandidates(model, ""))
+
+	var texts []string
+	switch format {
+	case "openai":
+		for _, message := range gjson.GetBytes(out, "messages").Array() {
+			if role := message.Get("role").String(); role == "system" || role == "developer" {
+				texts = append(texts, message.Get("content").String())
+			}
+		}
+	case "gemini", "antigravity":
+		for _, part := range gjson.GetBytes(out, "systemInstruction.parts").Array() {
+			texts = append(texts, part.Get("text").String())
+		}
+	case "codex", "openai-response":
+		if instructions := gjson.GetBytes(out, "instructions").String(); instructions != "" {
+			texts = append(texts, instructions)
+		}
+		for _, item := range gjson.GetBytes(out, "input").Array() {
+			if isResponsesSystemItem(item) {
+				texts = append(texts, item.Get("content").String())
+			}
+		}
+	default:
+		result := gjson.GetBytes(out, "system")
+		if !result.IsArray() {
+			return result.String(), matched
+		}
+		for _, block := range result.Array() {
+			texts = append(texts, block.Get("text").String())
+		}
+	}
+	return strings.Join(texts, "\n\n"), matched
+}
+
+func applySystemPromptRules(rules []config.SystemPromptRule, protocol, format, root string, payload []byte, candidates []string) ([]byte, int) {
+	matched := 0
+	for i := range rules {
+		rule := &rules[i]
+		if !payloadModelRulesMatch(rule.Models, protocol, candidates) {
+			continue
+		}
+		matched++
+		switch format {
+		case "claude":
+			payload = rewriteClaudeSystem(payload, buildPayloadPath(root, "system"), rule.Mode, rule.Text)
+		case "openai":
+			payload = rewriteOpenAISystem(payload, buildPayloadPath(root, "messages"), rule.Mode, rule.Text)
+		case "codex", "openai-response":
+			payload = rewriteResponsesSystem(payload, root, rule.Mode, rule.Text)
+		case "gemini", "gemini-cli", "antigravity":
+			payload = rewriteGeminiSystem(payload, root, rule.Mode, rule.Text)
+		}
+	}
+	return payload, matched
+}
+
+// rewriteClaudeSystem rewrites a Claude system prompt given either as a string or as
+// an array of text blocks. Blocks are added or replaced whole, so existing blocks and
+// their cache_control markers keep their structure when text is prepended or appended.
+func rewriteClaudeSystem(payload []byte, path, mode, text string) []byte {
+	system := gjson.GetBytes(payload, path)
+	switch mode {
+	case "strip":
+		payload, _ = sjson.DeleteBytes(payload, path)
+	case "replace":
+		if system.IsArray() {
+			payload, _ = sjson.SetRawBytes(payload, path, []byte("["+claudeTextBlock(text)+"]"))
+		} else {
+			payload, _ = sjson.SetBytes(payload, path, text)
+		}
+	case "prepend", "append":
+		switch {
+		case system.IsArray():
+			blocks := rawArrayItems(system)
+			if mode == "prepend" {
+				blocks = append([]string{claudeTextBlock(text)}, blocks...)
+			} else {
+				blocks = append(blocks, claudeTextBlock(text))
+			}
+			payload, _ = sjson.SetRawBytes(payload, path, []byte("["+strings.Join(blocks, ",")+"]"))
+		case system.String() == "":
+			payload, _ = sjson.SetBytes(payload, path, text)
+		case mode == "prepend":
+			payload, _ = sjson.SetBytes(payload, path, text+"\n\n"+system.String())
+		default:
+			payload, _ = sjson.SetBytes(payload, path, system.String()+"\n\n"+text)
+		}
+	}
+	return payload
+}
+
+// rewriteOpenAISystem rewrites the system and developer messages of an OpenAI chat
+// payload. Added instructions become their own message, using the role of the
+// client's first system message ("system" when there is none), placed before the
+// first or after the last system message.
+func rewriteOpenAISystem(payload []byte, path, mode, text string) []byte {
+	messages := gjson.GetBytes(payload, path)
+	if !messages.IsArray() {
+		if mode == "strip" {
+			return payload
+		}
+		payload, _ = sjson.SetRawBytes(payload, path, []byte("[]"))
+		messages = gjson.GetBytes(payload, path)
+	}
+	items := rawArrayItems(messages)
+	role, first, last := "system", -1, -1
+	for i, message := range messages.Array() {
+		if r := message.Get("role").String(); r == "system" || r == "developer" {
+			if first < 0 {
+				role, first = r, i
+			}
+			last = i
+		}
+	}
+	message, _ := sjson.Set(`{}`, "role", role)
+	message, _ = sjson.Set(message, "content", text)
+	switch mode {
+	case "strip", "replace":
+		kept := make([]string, 0, len(items)+1)
+		if mode == "replace" {
+			kept = append(kept, message)
+		}
+		for i, item := range messages.Array() {
+			if r := item.Get("role").String(); r != "system" && r != "developer" {
+				kept = append(kept, items[i])
+			}
+		}
+		items = kept
+	case "prepend":
+		items = insertRawItem(items, max(first, 0), message)
+	case "append":
+		items = insertRawItem(items, last+1, message)
+	}
+	payload, _ = sjson.SetRawBytes(payload, path, []byte("["+strings.Join(items, ",")+"]"))
+	return payload
+}
+
+// rewriteResponsesSystem rewrites the instructions of an OpenAI Responses payload under
+// root together with its system and developer input messages. Added instructions go
+// into instructions, except that an appended one becomes its own input message after
+// the client's last system or developer message, with that message's role.
+func rewriteResponsesSystem(payload []byte, root, mode, text string) []byte {
+	instructionsPath, inputPath := buildPayloadPath(root, "instructions"), buildPayloadPath(root, "input")
+	instructions := gjson.GetBytes(payload, instructionsPath).String()
+	input := gjson.GetBytes(payload, inputPath)
+	last := -1
+	if input.IsArray() {
+		for i, item := range input.Array() {
+			if isResponsesSystemItem(item) {
+				last = i
+			}
+		}
+	}
+	switch mode {
+	case "strip", "replace":
+		if mode == "strip" {
+			payload, _ = sjson.DeleteBytes(payload, instructionsPath)
+		} else {
+			payload, _ = sjson.SetBytes(payload, instructionsPath, text)
+		}
+		if last >= 0 {
+			items := rawArrayItems(input)
+			kept := make([]string, 0, len(items))
+			for i, item := range input.Array() {
+				if !isResponsesSystemItem(item) {
+					kept = append(kept, items[i])
+				}
+			}
+			payload, _ = sjson.SetRawBytes(payload, inputPath, []byte("["+strings.Join(kept, ",")+"]"))
+		}
+	case "prepend":
+		if instructions != "" {
+			text += "\n\n" + instructions
+		}
+		payload, _ = sjson.SetBytes(payload, instructionsPath, text)
+	case "append":
+		if last < 0 {
+			if instructions != "" {
+				text = instructions + "\n\n" + text
+			}
+			payload, _ = sjson.SetBytes(payload, instructionsPath, text)
+			break
+		}
+		message, _ := sjson.Set(`{"type":"message"}`, "role", input.Array()[last].Get("role").String())
+		message, _ = sjson.Set(message, "content", text)
+		items := insertRawItem(rawArrayItems(input), last+1, message)
+		payload, _ = sjson.SetRawBytes(payload, inputPath, []byte("["+strings.Join(items, ",")+"]"))
+	}
+	return payload
+}
+
+// isResponsesSystemItem reports whether a Responses input item is a system or
+// developer message.
+func isResponsesSystemItem(item gjson.Result) bool {
+	if t := item.Get("type").String(); t != "" && t != "message" {
+		return false
+	}
+	role := item.Get("role").String()
+	return role == "system" || role == "developer"
+}
+
+// rewriteGeminiSystem rewrites the Gemini systemInstruction under root, or its
+// system_instruction spelling when the payload uses that one. Added instructions
+// become their own part.
+func rewriteGeminiSystem(payload []byte, root, mode, text string) []byte {
+	path := buildPayloadPath(root, "systemInstruction")
+	if snake := buildPayloadPath(root, "system_instruction"); gjson.GetBytes(payload, snake).Exists() {
+		path = snake
+	}
+	part, _ := sjson.Set(`{}`, "text", text)
+	parts := gjson.GetBytes(payload, path+".parts")
+	switch {
+	case mode == "strip":
+		payload, _ = sjson.DeleteBytes(payload, path)
+	case mode == "replace" || !parts.IsArray() || len(parts.Array()) == 0:
+		payload, _ = sjson.SetRawBytes(payload, path, []byte(`{"role":"user","parts":[`+part+`]}`))
+	case mode == "prepend":
+		payload, _ = sjson.SetRawBytes(payload, path+".parts", []byte("["+strings.Join(insertRawItem(rawArrayItems(parts), 0, part), ",")+"]"))
+	default:
+		payload, _ = sjson.SetRawBytes(payload, path+".parts.-1", []byte(part))
+	}
+	return payload
+}
+
+func claudeTextBlock(text string) string {
+	block, _ := sjson.Set(`{"type":"text"}`, "text", text)
+	return block
+}
+
+func rawArrayItems(array gjson.Result) []string {
+	elements := array.Array()
+	items := make([]string, len(elements))
+	for i, element := range elements {
+		items[i] = element.Raw
+	}
+	return items
+}
+
+func insertRawItem(items []string, index int, item string) []string {
+	items = append(items, "")
+	copy(items[index+1:], items[index:])
+	items[index] = item
+	return items
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplySystemPromptConfig(t *testing.T) {
+	rule := func(mode, text string) config.SystemPromptRule {
+		return config.SystemPromptRule{Models: []config.PayloadModelRule{{Name: "test-*"}}, Mode: mode, Text: text}
+	}
+	tests := []struct {
+		name     string
+		rules    []config.SystemPromptRule
+		protocol string
+		format   string
+		root     string
+		payload  string
+		want     map[string]string
+		absent   []string
+	}{
+		{
+			name:    "claude string prepend",
+			rules:   []config.SystemPromptRule{rule("prepend", "ORG")},
+			format:  "claude",
+			payload: `{"system":"client"}`,
+			want:    map[string]string{"system": "ORG\n\nclient"},
+		},
+		{
+			name:    "claude blocks keep cache control when prepending",
+			rules:   []config.SystemPromptRule{rule("prepend", "ORG")},
+			format:  "claude",
+			payload: `{"system":[{"type":"text","text":"a","cache_control":{"type":"ephemeral"}},{"type":"text","text":"b","cache_control":{"type":"ephemeral"}}]}`,
+			want: map[string]string{
+				"system.#":                    "3",
+				"system.0.text":               "ORG",
+				"system.1.cache_control.type": "ephemeral",
+				"system.2.text":               "b",
+				"system.2.cache_control.type": "ephemeral",
+			},
+			absent: []string{"system.0.cache_control"},
+		},
+		{
+			name:    "claude append then replace",
+			rules:   []config.SystemPromptRule{rule("append", "ORG"), rule("replace", "ONLY")},
+			format:  "claude",
+			payload: `{"system":[{"type":"text","text":"a"}]}`,
+			want:    map[string]string{"system.#": "1", "system.0.text": "ONLY"},
+		},
+		{
+			name:    "claude append without system",
+			rules:   []config.SystemPromptRule{rule("append", "ORG")},
+			format:  "claude",
+			payload: `{"messages":[]}`,
+			want:    map[string]string{"system": "ORG"},
+		},
+		{
+			name:    "openai developer append",
+			rules:   []config.SystemPromptRule{rule("append", "ORG")},
+			format:  "openai",
+			payload: `{"messages":[{"role":"developer","content":"client"},{"role":"user","content":"hi"}]}`,
+			want: map[string]string{
+				"messages.#":         "3",
+				"messages.1.role":    "developer",
+				"messages.1.content": "ORG",
+				"messages.2.role":    "user",
+			},
+		},
+		{
+			name:    "openai replace",
+			rules:   []config.SystemPromptRule{rule("replace", "ONLY")},
+			format:  "openai",
+			payload: `{"messages":[{"role":"system","content":"a"},{"role":"user","content":"hi"},{"role":"system","content":"b"}]}`,
+			want:    map[string]string{"messages.#": "2", "messages.0.content": "ONLY", "messages.1.role": "user"},
+		},
+		{
+			name:    "gemini cli prepend",
+			rules:   []config.SystemPromptRule{rule("prepend", "ORG")},
+			format:  "gemini",
+			root:    "request",
+			payload: `{"request":{"systemInstruction":{"role":"user","parts":[{"text":"client"}]}}}`,
+			want:    map[string]string{"request.systemInstruction.parts.0.text": "ORG", "request.systemInstruction.parts.1.text": "client"},
+		},
+		{
+			name:    "gemini snake case append",
+			rules:   []config.SystemPromptRule{rule("append", "ORG")},
+			format:  "gemini",
+			payload: `{"system_instruction":{"role":"user","parts":[{"text":"client"}]}}`,
+			want:    map[string]string{"system_instruction.parts.#": "2", "system_instruction.parts.1.text": "ORG"},
+			absent:  []string{"systemInstruction"},
+		},
+		{
+			name:     "kiro strip on openai body",
+			rules:    []config.SystemPromptRule{{Models: []config.PayloadModelRule{{Name: "*", Protocol: "kiro"}}, Mode: "strip"}},
+			protocol: "kiro",
+			format:   "openai",
+			payload:  `{"messages":[{"role":"system","content":"client"},{"role":"user","content":"hi"}]}`,
+			want:     map[string]string{"messages.#": "1", "messages.0.role": "user"},
+		},
+		{
+			name:    "codex instructions prepend",
+			rules:   []config.SystemPromptRule{rule("prepend", "ORG")},
+			format:  "codex",
+			payload: `{"instructions":"client","input":[{"role":"user","content":"hi"}]}`,
+			want:    map[string]string{"instructions": "ORG\n\nclient", "input.#": "1"},
+		},
+		{
+			name:    "responses append after the last developer message",
+			rules:   []config.SystemPromptRule{rule("append", "ORG")},
+			format:  "openai-response",
+			payload: `{"instructions":"client","input":[{"type":"message","role":"developer","content":"dev"},{"type":"function_call_output","call_id":"c1","output":"ok"},{"role":"user","content":"hi"}]}`,
+			want:    map[string]string{"instructions": "client", "input.#": "4", "input.1.role": "developer", "input.1.content": "ORG", "input.2.type": "function_call_output"},
+		},
+		{
+			name:    "codex replace drops system input messages",
+			rules:   []config.SystemPromptRule{rule("replace", "ONLY")},
+			format:  "codex",
+			payload: `{"instructions":"client","input":[{"role":"system","content":"sys"},{"role":"user","content":"hi"}]}`,
+			want:    map[string]string{"instructions": "ONLY", "input.#": "1", "input.0.role": "user"},
+		},
+		{
+			name:    "responses strip with string input",
+			rules:   []config.SystemPromptRule{rule("strip", "")},
+			format:  "openai-response",
+			payload: `{"instructions":"client","input":"hi"}`,
+			want:    map[string]string{"input": "hi"},
+			absent:  []string{"instructions"},
+		},
+		{
+			name:     "protocol mismatch",
+			rules:    []config.SystemPromptRule{{Models: []config.PayloadModelRule{{Name: "*", Protocol: "kiro"}}, Mode: "strip"}},
+			protocol: "claude",
+			format:   "claude",
+			payload:  `{"system":"client"}`,
+			want:     map[string]string{"system": "client"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Payload.SystemPrompt = tt.rules
+			protocol := tt.protocol
+			if protocol == "" {
+				protocol = tt.format
+			}
+			out := applySystemPromptConfig(cfg, "test-model", protocol, tt.format, tt.root, []byte(tt.payload), "")
+			for path, want := range tt.want {
+				if got := gjson.GetBytes(out, path).String(); got != want {
+					t.Fatalf("%s = %q, want %q (payload %s)", path, got, want, out)
+				}
+			}
+			for _, path := range tt.absent {
+				if gjson.GetBytes(out, path).Exists() {
+					t.Fatalf("%s should be absent in %s", path, out)
+				}
+			}
+		})
+	}
+}
+
+func TestPreviewSystemPrompt(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Payload.SystemPrompt = []config.SystemPromptRule{
+		{Models: []config.PayloadModelRule{{Name: "claude-*"}}, Mode: "prepend", Text: "ORG"},
+		{Models: []config.PayloadModelRule{{Name: "*", Protocol: "kiro"}}, Mode: "strip"},
+	}
+	if got, matched := PreviewSystemPrompt(cfg, "claude-sonnet-4", "claude", "client"); got != "ORG\n\nclient" || matched != 1 {
+		t.Fatalf("claude preview = %q, %d", got, matched)
+	}
+	if got, matched := PreviewSystemPrompt(cfg, "kiro-claude-sonnet-4", "kiro", "client"); got != "" || matched != 1 {
+		t.Fatalf("kiro preview = %q, %d", got, matched)
+	}
+	if got, matched := PreviewSystemPrompt(cfg, "gpt-5", "openai", "client"); got != "client" || matched != 0 {
+		t.Fatalf("unmatched preview = %q, %d", got, matched)
+	}
+	for _, protocol := range []string{"openai", "gemini", "antigravity", "codex", "openai-response"} {
+		if got, matched := PreviewSystemPrompt(cfg, "claude-sonnet-4", protocol, "client"); got != "ORG\n\nclient" || matched != 1 {
+			t.Fatalf("%s preview = %q, %d", protocol, got, matched)
+		}
+	}
+	cfg.Payload.SystemPrompt = []config.SystemPromptRule{{Models: []config.PayloadModelRule{{Name: "*", Protocol: "codex"}}, Mode: "append", Text: "ORG"}}
+	if got, matched := PreviewSystemPrompt(cfg, "gpt-5-codex", "codex", ""); got != "ORG" || matched != 1 {
+		t.Fatalf("codex preview without instructions = %q, %d", got, matched)
+	}
+}
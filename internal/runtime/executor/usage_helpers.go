@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
@@ -23,7 +25,11 @@ type usageReporter struct {
 	apiKey      string
 	source      string
 	requestedAt time.Time
-	once        sync.Once
+	streamed    bool
+	// partial is the usage reported so far by a stream whose final usage is still
+	// pending, published if the client cancels before the stream completes.
+	partial usage.Detail
+	once    sync.Once
 }
 
 func newUsageReporter(ctx context.Context, provider, model string, auth *cliproxyauth.Auth) *usageReporter {
@@ -34,6 +40,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
+		streamed:    usage.IsStreaming(ctx),
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
@@ -50,6 +57,28 @@ func (r *usageReporter) publishFailure(ctx context.Context) {
 	r.publishWithOutcome(ctx, usage.Detail{}, true)
 }
 
+// observePartial remembers the usage a stream has reported before its final usage, so
+// that a cancelled request still records the tokens the upstream had counted.
+func (r *usageReporter) observePartial(detail usage.Detail) {
+	if r != nil {
+		r.partial = detail
+	}
+}
+
+// publishObserved publishes the last usage passed to observePartial, for streams that
+// repeat their cumulative usage instead of reporting it once at the end.
+func (r *usageReporter) publishObserved(ctx context.Context) {
+	if r != nil {
+		r.publish(ctx, r.partial)
+	}
+}
+
+// requestCancelled reports whether the client went away before the request finished.
+// Hedged duplicates cancelled by the winning attempt are reported as hedged instead.
+func requestCancelled(ctx context.Context) bool {
+	return ctx != nil && ctx.Err() != nil && !usage.IsHedgeLoser(ctx)
+}
+
 func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
 	if r == nil || errPtr == nil {
 		return
@@ -63,17 +92,27 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 	if r == nil {
 		return
 	}
+	// A request the client went away from is a cancellation, whether the executor saw
+	// the failure or just stopped reading, and not an upstream failure.
+	cancelled := requestCancelled(ctx)
+	if cancelled {
+		failed = false
+		if detail == (usage.Detail{}) {
+			detail = r.partial
+		}
+	}
 	if detail.TotalTokens == 0 {
 		total := detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
 		if total > 0 {
 			detail.TotalTokens = total
 		}
 	}
-	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed {
+	if detail.InputTokens == 0 && detail.OutputTokens == 0 && detail.ReasoningTokens == 0 && detail.CachedTokens == 0 && detail.TotalTokens == 0 && !failed && !cancelled {
 		return
 	}
+	detail.CacheSimulated = internalusage.SimulatesCacheUsage(r.provider)
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
+		record := usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
 			Source:      r.source,
@@ -81,12 +120,50 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
+			Latency:     time.Since(r.requestedAt),
+			Streamed:    r.streamed,
 			Failed:      failed,
+			Cancelled:   cancelled,
 			Detail:      detail,
-		})
+		}
+		usage.PublishRecord(ctx, record)
+		annotateRequestLog(ctx, record)
 	})
 }
 
+// annotateRequestLog attaches the provider, model, token breakdown and estimated cost of
+// record to the gin context so the access logs can report them. Records for unpriced
+// models carry no cost.
+func annotateRequestLog(ctx context.Context, record usage.Record) {
+	if ctx == nil {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	rec := internalusage.RequestUsageFromRecord(record)
+	dist := rec.Distribution
+	if dist.TotalInputTokens() == 0 {
+		dist = internalusage.CacheTokenDistribution{InputTokens: rec.InputTokens}
+	}
+	var tokens *logging.AccessLogTokens
+	if dist.TotalInputTokens() > 0 || rec.OutputTokens > 0 {
+		tokens = &logging.AccessLogTokens{
+			Input:         dist.InputTokens,
+			Output:        rec.OutputTokens,
+			CacheCreation: dist.CacheCreationInputTokens,
+			CacheRead:     dist.CacheReadInputTokens,
+		}
+	}
+	logging.SetGinRequestUsage(ginCtx, record.Provider, record.Model, tokens)
+	cost, ok := internalusage.RequestCost(rec)
+	if !ok {
+		return
+	}
+	logging.SetGinRequestCost(ginCtx, cost.StringFixed(6))
+}
+
 // ensurePublished guarantees that a usage record is emitted exactly once.
 // It is safe to call multiple times; only the first call wins due to once.Do.
 // This is used to ensure request counting even when upstream responses do not
@@ -96,7 +173,7 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
+		record := usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
 			Source:      r.source,
@@ -104,12 +181,19 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
+			Latency:     time.Since(r.requestedAt),
+			Streamed:    r.streamed,
 			Failed:      false,
+			Cancelled:   requestCancelled(ctx),
 			Detail:      usage.Detail{},
-		})
+		}
+		usage.PublishRecord(ctx, record)
+		annotateRequestLog(ctx, record)
 	})
 }
 
+// apiKeyFromContext returns the client API key of the request, or the name of its
+// client-keys entry so that named keys never reach usage records.
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -118,6 +202,9 @@ func apiKeyFromContext(ctx context.Context) string {
 	if !ok || ginCtx == nil {
 		return ""
 	}
+	if name := ginCtx.GetString("apiKeyName"); name != "" {
+		return name
+	}
 	if v, exists := ginCtx.Get("apiKey"); exists {
 		switch value := v.(type) {
 		case string:
@@ -296,13 +383,10 @@ func parseClaudeUsage(data []byte) usage.Detail {
 		return usage.Detail{}
 	}
 	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
-	}
-	if detail.CachedTokens == 0 {
-		// fall back to creation tokens when read tokens are absent
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+		InputTokens:         usageNode.Get("input_tokens").Int(),
+		OutputTokens:        usageNode.Get("output_tokens").Int(),
+		CachedTokens:        usageNode.Get("cache_read_input_tokens").Int(),
+		CacheCreationTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
 	return detail
@@ -317,16 +401,32 @@ func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 	if !usageNode.Exists() {
 		return usage.Detail{}, false
 	}
-	detail := usage.Detail{
-		InputTokens:  usageNode.Get("input_tokens").Int(),
-		OutputTokens: usageNode.Get("output_tokens").Int(),
-		CachedTokens: usageNode.Get("cache_read_input_tokens").Int(),
+	return claudeUsageDetail(usageNode), true
+}
+
+// parseClaudeStreamStartUsage returns the usage of a Claude message_start event, which
+// counts the prompt before any output has been generated.
+func parseClaudeStreamStartUsage(line []byte) (usage.Detail, bool) {
+	payload := jsonPayload(line)
+	if len(payload) == 0 || gjson.GetBytes(payload, "type").String() != "message_start" {
+		return usage.Detail{}, false
 	}
-	if detail.CachedTokens == 0 {
-		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
+	usageNode := gjson.GetBytes(payload, "message.usage")
+	if !usageNode.Exists() {
+		return usage.Detail{}, false
+	}
+	return claudeUsageDetail(usageNode), true
+}
+
+func claudeUsageDetail(usageNode gjson.Result) usage.Detail {
+	detail := usage.Detail{
+		InputTokens:         usageNode.Get("input_tokens").Int(),
+		OutputTokens:        usageNode.Get("output_tokens").Int(),
+		CachedTokens:        usageNode.Get("cache_read_input_tokens").Int(),
+		CacheCreationTokens: usageNode.Get("cache_creation_input_tokens").Int(),
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
-	return detail, true
+	return detail
 }
 
 func parseGeminiFamilyUsageDetail(node gjson.Result) usage.Detail {
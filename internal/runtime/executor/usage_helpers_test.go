@@ -1,6 +1,12 @@
 package executor
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
 
 func TestParseOpenAIUsageChatCompletions(t *testing.T) {
 	data := []byte(`{"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":5}}}`)
@@ -41,3 +47,88 @@ func TestParseOpenAIUsageResponses(t *testing.T) {
 		t.Fatalf("reasoning tokens = %d, want %d", detail.ReasoningTokens, 9)
 	}
 }
+
+type usageCapturePlugin struct {
+	provider string
+	records  chan usage.Record
+}
+
+func (p *usageCapturePlugin) HandleUsage(_ context.Context, record usage.Record) {
+	if record.Provider == p.provider {
+		p.records <- record
+	}
+}
+
+// registerUsageCapturePlugin registers plugin on the default usage manager for the
+// duration of the test.
+func registerUsageCapturePlugin(t *testing.T, plugin *usageCapturePlugin) {
+	t.Helper()
+	usage.RegisterPlugin(plugin)
+	t.Cleanup(func() { usage.UnregisterPlugin(plugin) })
+}
+
+func captureUsageRecord(t *testing.T, plugin *usageCapturePlugin) usage.Record {
+	t.Helper()
+	select {
+	case record := <-plugin.records:
+		return record
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for usage record")
+		return usage.Record{}
+	}
+}
+
+func TestUsageReporterPublishesStreamingFlag(t *testing.T) {
+	plugin := &usageCapturePlugin{provider: "usage-reporter-test", records: make(chan usage.Record, 4)}
+	registerUsageCapturePlugin(t, plugin)
+
+	nonStream := newUsageReporter(context.Background(), plugin.provider, "model-a", nil)
+	nonStream.publish(context.Background(), usage.Detail{InputTokens: 3, OutputTokens: 4})
+	record := captureUsageRecord(t, plugin)
+	if record.Streamed {
+		t.Fatal("expected non-streaming record")
+	}
+	if record.Detail.TotalTokens != 7 || record.Latency < 0 {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	streamCtx := usage.WithStreaming(context.Background())
+	stream := newUsageReporter(streamCtx, plugin.provider, "model-a", nil)
+	stream.publishFailure(streamCtx)
+	record = captureUsageRecord(t, plugin)
+	if !record.Streamed || !record.Failed {
+		t.Fatalf("expected failed streaming record, got %+v", record)
+	}
+}
+
+func TestUsageReporterMarksCancelledRequests(t *testing.T) {
+	plugin := &usageCapturePlugin{provider: "usage-cancel-test", records: make(chan usage.Record, 4)}
+	registerUsageCapturePlugin(t, plugin)
+
+	ctx, cancel := context.WithCancel(usage.WithStreaming(context.Background()))
+	cancel()
+
+	// A stream that just stops reading when the client goes away returns no error.
+	reporter := newUsageReporter(ctx, plugin.provider, "model-a", nil)
+	reporter.observePartial(usage.Detail{InputTokens: 10})
+	reporter.publishObserved(ctx)
+	record := captureUsageRecord(t, plugin)
+	if !record.Cancelled || record.Failed || record.Detail.InputTokens != 10 {
+		t.Fatalf("stopped stream record = %+v", record)
+	}
+
+	reporter = newUsageReporter(ctx, plugin.provider, "model-a", nil)
+	reporter.ensurePublished(ctx)
+	if record = captureUsageRecord(t, plugin); !record.Cancelled {
+		t.Fatalf("stream without usage record = %+v", record)
+	}
+
+	race := usage.NewHedgeRace()
+	usage.ClaimHedgeWin(usage.WithHedgeAttempt(ctx, race))
+	loser := usage.WithHedgeAttempt(ctx, race)
+	reporter = newUsageReporter(loser, plugin.provider, "model-a", nil)
+	reporter.publish(loser, usage.Detail{InputTokens: 10})
+	if record = captureUsageRecord(t, plugin); record.Cancelled {
+		t.Fatalf("hedge loser recorded as cancelled: %+v", record)
+	}
+}
@@ -1,7 +1,9 @@
 package thinking
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 )
@@ -35,11 +37,50 @@ var levelToBudgetMap = map[string]int{
 // Returns:
 //   - budget: The converted budget value
 //   - ok: true if level is valid, false otherwise
+//
+// Budgets installed with SetLevelBudgets take precedence over the standard mapping.
 func ConvertLevelToBudget(level string) (int, bool) {
-	budget, ok := levelToBudgetMap[strings.ToLower(level)]
+	key := strings.ToLower(level)
+	levelBudgetsMu.RLock()
+	budget, ok := levelBudgetOverrides[key]
+	levelBudgetsMu.RUnlock()
+	if ok {
+		return budget, true
+	}
+	budget, ok = levelToBudgetMap[key]
 	return budget, ok
 }
 
+var (
+	levelBudgetsMu       sync.RWMutex
+	levelBudgetOverrides map[string]int
+)
+
+// SetLevelBudgets overrides the token budgets used for reasoning levels, such as the
+// budget_tokens Claude receives for reasoning_effort "high". Keys are the levels
+// minimal, low, medium, high and xhigh, matched case-insensitively; none and auto
+// keep their meaning. Levels without an entry use the standard mapping, and a nil
+// map restores it. The overrides are left unchanged when any entry is invalid.
+func SetLevelBudgets(budgets map[string]int) error {
+	next := make(map[string]int, len(budgets))
+	for level, budget := range budgets {
+		key := strings.ToLower(strings.TrimSpace(level))
+		switch ThinkingLevel(key) {
+		case LevelMinimal, LevelLow, LevelMedium, LevelHigh, LevelXHigh:
+		default:
+			return fmt.Errorf("thinking: unknown reasoning level %q", level)
+		}
+		if budget <= 0 {
+			return fmt.Errorf("thinking: budget for level %q must be positive, got %d", level, budget)
+		}
+		next[key] = budget
+	}
+	levelBudgetsMu.Lock()
+	levelBudgetOverrides = next
+	levelBudgetsMu.Unlock()
+	return nil
+}
+
 // BudgetThreshold constants define the upper bounds for each thinking level.
 // These are used by ConvertBudgetToLevel for range-based mapping.
 const (
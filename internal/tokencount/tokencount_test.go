@@ -0,0 +1,38 @@
+package tokencount
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeuristicCount(t *testing.T) {
+	payload := []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+	if got := HeuristicCount("gpt-4o", payload); got != 9 {
+		t.Fatalf("gpt-4o estimate = %d, want 9", got)
+	}
+	if got := HeuristicCount("claude-sonnet-4.5", payload); got != 10 {
+		t.Fatalf("claude estimate = %d, want 10", got)
+	}
+	if got := HeuristicCount("gpt-4o", []byte("a")); got != 1 {
+		t.Fatalf("non-empty payload should count at least one token, got %d", got)
+	}
+	if got := HeuristicCount("gpt-4o", nil); got != 0 {
+		t.Fatalf("empty payload = %d, want 0", got)
+	}
+}
+
+func TestEstimatePrompt_Gemini(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor sit amet ", 20)
+	payload := `{"systemInstruction":{"parts":[{"text":"be brief"}]},"contents":[{"role":"user","parts":[{"text":"` + text + `"},{"inlineData":{"mimeType":"image/png","data":"AAAA"}}]}],"tools":[{"functionDeclarations":[{"name":"lookup","description":"look it up","parameters":{"type":"object"}}]}]}`
+	tokens, ok := EstimatePrompt("gemini-2.5-pro", "gemini", []byte(payload))
+	if !ok || tokens <= geminiImageTokens+100 {
+		t.Fatalf("EstimatePrompt(gemini) = %d, %v", tokens, ok)
+	}
+	wrapped, ok := EstimatePrompt("gemini-2.5-pro", "gemini-cli", []byte(`{"model":"gemini-2.5-pro","request":`+payload+`}`))
+	if !ok || wrapped != tokens {
+		t.Fatalf("EstimatePrompt(gemini-cli) = %d, %v, want %d", wrapped, ok, tokens)
+	}
+	if _, ok := EstimatePrompt("gemini-2.5-pro", "codex", []byte(payload)); ok {
+		t.Fatal("unsupported formats should not be estimated")
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/params"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -62,7 +63,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
 
 	// Base Claude message payload
-	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
+	out := fmt.Sprintf(`{"model":"","messages":[],"metadata":{"user_id":"%s"}}`, userID)
 
 	root := gjson.ParseBytes(rawJSON)
 
@@ -88,30 +89,11 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Model mapping to specify which Claude Code model to use
 	out, _ = sjson.Set(out, "model", modelName)
 
-	// Generation config extraction from Gemini format
+	// Generation parameters (maxOutputTokens, temperature, topP, topK, stopSequences, ...)
+	out = string(params.Translate(params.Gemini, params.Claude, modelName, rawJSON, []byte(out), ""))
+
+	// Thinking configuration extraction from Gemini format
 	if genConfig := root.Get("generationConfig"); genConfig.Exists() {
-		// Max output tokens configuration
-		if maxTokens := genConfig.Get("maxOutputTokens"); maxTokens.Exists() {
-			out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
-		}
-		// Temperature setting for controlling response randomness
-		if temp := genConfig.Get("temperature"); temp.Exists() {
-			out, _ = sjson.Set(out, "temperature", temp.Float())
-		} else if topP := genConfig.Get("topP"); topP.Exists() {
-			// Top P setting for nucleus sampling (filtered out if temperature is set)
-			out, _ = sjson.Set(out, "top_p", topP.Float())
-		}
-		// Stop sequences configuration for custom termination conditions
-		if stopSeqs := genConfig.Get("stopSequences"); stopSeqs.Exists() && stopSeqs.IsArray() {
-			var stopSequences []string
-			stopSeqs.ForEach(func(_, value gjson.Result) bool {
-				stopSequences = append(stopSequences, value.String())
-				return true
-			})
-			if len(stopSequences) > 0 {
-				out, _ = sjson.Set(out, "stop_sequences", stopSequences)
-			}
-		}
 		// Include thoughts configuration for reasoning process visibility
 		// Translator only does format conversion, ApplyThinking handles model capability validation.
 		if thinkingConfig := genConfig.Get("thinkingConfig"); thinkingConfig.Exists() && thinkingConfig.IsObject() {
@@ -262,15 +244,26 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 						return true
 					}
 
-					// Image content (inline_data) conversion to Claude Code format
-					if inlineData := part.Get("inline_data"); inlineData.Exists() {
-						imageContent := `{"type":"image","source":{"type":"base64","media_type":"","data":""}}`
-						if mimeType := inlineData.Get("mime_type"); mimeType.Exists() {
-							imageContent, _ = sjson.Set(imageContent, "source.media_type", mimeType.String())
+					// Image and PDF content (inline_data / inlineData) conversion to Claude Code format
+					inlineData := part.Get("inline_data")
+					if !inlineData.Exists() {
+						inlineData = part.Get("inlineData")
+					}
+					if inlineData.Exists() {
+						data := inlineData.Get("data").String()
+						declared := inlineData.Get("mime_type").String()
+						if declared == "" {
+							declared = inlineData.Get("mimeType").String()
 						}
-						if data := inlineData.Get("data"); data.Exists() {
-							imageContent, _ = sjson.Set(imageContent, "source.data", data.String())
+						mediaType := util.SniffBase64MediaType(data, declared)
+						blockType := "image"
+						if mediaType == "application/pdf" {
+							blockType = "document"
 						}
+						imageContent := `{"type":"","source":{"type":"base64","media_type":"","data":""}}`
+						imageContent, _ = sjson.Set(imageContent, "type", blockType)
+						imageContent, _ = sjson.Set(imageContent, "source.media_type", mediaType)
+						imageContent, _ = sjson.Set(imageContent, "source.data", data)
 						msg, _ = sjson.SetRaw(msg, "content.-1", imageContent)
 						return true
 					}
@@ -211,33 +211,7 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 		}
 
 		if usage := root.Get("usage"); usage.Exists() {
-			// Basic token counts for prompt and completion
-			inputTokens := usage.Get("input_tokens").Int()
-			outputTokens := usage.Get("output_tokens").Int()
-
-			// Set basic usage metadata according to Gemini API specification
-			template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", inputTokens)
-			template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", outputTokens)
-			template, _ = sjson.Set(template, "usageMetadata.totalTokenCount", inputTokens+outputTokens)
-
-			// Add cache-related token counts if present (Claude Code API cache fields)
-			if cacheCreationTokens := usage.Get("cache_creation_input_tokens"); cacheCreationTokens.Exists() {
-				template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", cacheCreationTokens.Int())
-			}
-			if cacheReadTokens := usage.Get("cache_read_input_tokens"); cacheReadTokens.Exists() {
-				// Add cache read tokens to cached content count
-				existingCacheTokens := usage.Get("cache_creation_input_tokens").Int()
-				totalCacheTokens := existingCacheTokens + cacheReadTokens.Int()
-				template, _ = sjson.Set(template, "usageMetadata.cachedContentTokenCount", totalCacheTokens)
-			}
-
-			// Add thinking tokens if present (for models with reasoning capabilities)
-			if thinkingTokens := usage.Get("thinking_tokens"); thinkingTokens.Exists() {
-				template, _ = sjson.Set(template, "usageMetadata.thoughtsTokenCount", thinkingTokens.Int())
-			}
-
-			// Set traffic type (required by Gemini API)
-			template, _ = sjson.Set(template, "usageMetadata.trafficType", "PROVISIONED_THROUGHPUT")
+			template, _ = sjson.SetRaw(template, "usageMetadata", ClaudeUsageToGemini(usage))
 		}
 		template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
 
@@ -422,37 +396,7 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 		case "message_delta":
 			// Extract final usage information using sjson for token counts and metadata
 			if usage := root.Get("usage"); usage.Exists() {
-				usageJSON := `{}`
-
-				// Basic token counts for prompt and completion
-				inputTokens := usage.Get("input_tokens").Int()
-				outputTokens := usage.Get("output_tokens").Int()
-
-				// Set basic usage metadata according to Gemini API specification
-				usageJSON, _ = sjson.Set(usageJSON, "promptTokenCount", inputTokens)
-				usageJSON, _ = sjson.Set(usageJSON, "candidatesTokenCount", outputTokens)
-				usageJSON, _ = sjson.Set(usageJSON, "totalTokenCount", inputTokens+outputTokens)
-
-				// Add cache-related token counts if present (Claude Code API cache fields)
-				if cacheCreationTokens := usage.Get("cache_creation_input_tokens"); cacheCreationTokens.Exists() {
-					usageJSON, _ = sjson.Set(usageJSON, "cachedContentTokenCount", cacheCreationTokens.Int())
-				}
-				if cacheReadTokens := usage.Get("cache_read_input_tokens"); cacheReadTokens.Exists() {
-					// Add cache read tokens to cached content count
-					existingCacheTokens := usage.Get("cache_creation_input_tokens").Int()
-					totalCacheTokens := existingCacheTokens + cacheReadTokens.Int()
-					usageJSON, _ = sjson.Set(usageJSON, "cachedContentTokenCount", totalCacheTokens)
-				}
-
-				// Add thinking tokens if present (for models with reasoning capabilities)
-				if thinkingTokens := usage.Get("thinking_tokens"); thinkingTokens.Exists() {
-					usageJSON, _ = sjson.Set(usageJSON, "thoughtsTokenCount", thinkingTokens.Int())
-				}
-
-				// Set traffic type (required by Gemini API)
-				usageJSON, _ = sjson.Set(usageJSON, "trafficType", "PROVISIONED_THROUGHPUT")
-
-				finalUsageJSON = usageJSON
+				finalUsageJSON = ClaudeUsageToGemini(usage)
 			}
 		}
 	}
@@ -485,6 +429,29 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 	return template
 }
 
+// ClaudeUsageToGemini converts a Claude usage object into Gemini usageMetadata. Claude
+// counts cache reads and writes apart from input_tokens while Gemini's promptTokenCount
+// includes them, so they are summed in; cachedContentTokenCount reports the cache reads.
+func ClaudeUsageToGemini(usage gjson.Result) string {
+	promptTokens := usage.Get("input_tokens").Int() + usage.Get("cache_creation_input_tokens").Int() + usage.Get("cache_read_input_tokens").Int()
+	outputTokens := usage.Get("output_tokens").Int()
+
+	usageJSON := `{}`
+	usageJSON, _ = sjson.Set(usageJSON, "promptTokenCount", promptTokens)
+	usageJSON, _ = sjson.Set(usageJSON, "candidatesTokenCount", outputTokens)
+	usageJSON, _ = sjson.Set(usageJSON, "totalTokenCount", promptTokens+outputTokens)
+	if cacheReadTokens := usage.Get("cache_read_input_tokens").Int(); cacheReadTokens > 0 {
+		usageJSON, _ = sjson.Set(usageJSON, "cachedContentTokenCount", cacheReadTokens)
+	}
+	// Add thinking tokens if present (for models with reasoning capabilities)
+	if thinkingTokens := usage.Get("thinking_tokens"); thinkingTokens.Exists() {
+		usageJSON, _ = sjson.Set(usageJSON, "thoughtsTokenCount", thinkingTokens.Int())
+	}
+	// Set traffic type (required by Gemini API)
+	usageJSON, _ = sjson.Set(usageJSON, "trafficType", "PROVISIONED_THROUGHPUT")
+	return usageJSON
+}
+
 func GeminiTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"totalTokens":%d,"promptTokensDetails":[{"modality":"TEXT","tokenCount":%d}]}`, count, count)
 }
@@ -9,16 +9,32 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/params"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
+// structuredOutputToolName names the tool that carries a json_schema response_format
+// to Claude; its input is returned to the client as the assistant content.
+const structuredOutputToolName = "structured_output"
+
+const (
+	structuredOutputToolDescription = "Respond with the final answer as the input of this tool."
+	structuredOutputInstruction     = "When you have the final answer, respond by calling the " + structuredOutputToolName + " tool with it as input instead of replying in text."
+	jsonOnlyInstruction             = "Respond with valid JSON only. Do not include any text before or after the JSON and do not wrap it in markdown code blocks."
+)
+
 var (
 	user    = ""
 	account = ""
@@ -59,8 +75,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
 
-	// Base Claude Code API template with default max_tokens value
-	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
+	// Base Claude Code API template; the executor fills in max_tokens when the client sets none
+	out := fmt.Sprintf(`{"model":"","messages":[],"metadata":{"user_id":"%s"}}`, userID)
 
 	root := gjson.ParseBytes(rawJSON)
 
@@ -101,34 +117,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Model mapping to specify which Claude Code model to use
 	out, _ = sjson.Set(out, "model", modelName)
 
-	// Max tokens configuration with fallback to default value
-	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
-	}
-
-	// Temperature setting for controlling response randomness
-	if temp := root.Get("temperature"); temp.Exists() {
-		out, _ = sjson.Set(out, "temperature", temp.Float())
-	} else if topP := root.Get("top_p"); topP.Exists() {
-		// Top P setting for nucleus sampling (filtered out if temperature is set)
-		out, _ = sjson.Set(out, "top_p", topP.Float())
-	}
-
-	// Stop sequences configuration for custom termination conditions
-	if stop := root.Get("stop"); stop.Exists() {
-		if stop.IsArray() {
-			var stopSequences []string
-			stop.ForEach(func(_, value gjson.Result) bool {
-				stopSequences = append(stopSequences, value.String())
-				return true
-			})
-			if len(stopSequences) > 0 {
-				out, _ = sjson.Set(out, "stop_sequences", stopSequences)
-			}
-		} else {
-			out, _ = sjson.Set(out, "stop_sequences", []string{stop.String()})
-		}
-	}
+	// Generation parameters (max_tokens, temperature, top_p, top_k, stop, ...)
+	out = string(params.Translate(params.OpenAI, params.Claude, modelName, rawJSON, []byte(out), ""))
 
 	// Stream configuration to enable or disable streaming responses
 	out, _ = sjson.Set(out, "stream", stream)
@@ -137,9 +127,15 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	if messages := root.Get("messages"); messages.Exists() && messages.IsArray() {
 		messageIndex := 0
 		systemMessageIndex := -1
+		// Index of the user message collecting the current run of tool results; Claude
+		// expects the results of parallel tool calls together in a single user turn.
+		toolResultMessageIndex := -1
 		messages.ForEach(func(_, message gjson.Result) bool {
 			role := message.Get("role").String()
 			contentResult := message.Get("content")
+			if role != "tool" {
+				toolResultMessageIndex = -1
+			}
 
 			switch role {
 			case "system":
@@ -152,12 +148,14 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				if contentResult.Exists() && contentResult.Type == gjson.String && contentResult.String() != "" {
 					textPart := `{"type":"text","text":""}`
 					textPart, _ = sjson.Set(textPart, "text", contentResult.String())
+					textPart = copyCacheControl(textPart, message)
 					out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.content.-1", systemMessageIndex), textPart)
 				} else if contentResult.Exists() && contentResult.IsArray() {
 					contentResult.ForEach(func(_, part gjson.Result) bool {
 						if part.Get("type").String() == "text" {
 							textPart := `{"type":"text","text":""}`
 							textPart, _ = sjson.Set(textPart, "text", part.Get("text").String())
+							textPart = copyCacheControl(textPart, part)
 							out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.content.-1", systemMessageIndex), textPart)
 						}
 						return true
@@ -167,10 +165,22 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				msg := `{"role":"","content":[]}`
 				msg, _ = sjson.Set(msg, "role", role)
 
+				// Restore the signed thinking block of an earlier Claude turn from its
+				// reasoning_content; unsigned thinking would be rejected, so it is dropped.
+				if reasoning := message.Get("reasoning_content").String(); role == "assistant" && reasoning != "" {
+					if signature := cache.GetCachedSignature(modelName, reasoning); signature != "" {
+						thinkingPart := `{"type":"thinking","thinking":"","signature":""}`
+						thinkingPart, _ = sjson.Set(thinkingPart, "thinking", reasoning)
+						thinkingPart, _ = sjson.Set(thinkingPart, "signature", signature)
+						msg, _ = sjson.SetRaw(msg, "content.-1", thinkingPart)
+					}
+				}
+
 				// Handle content based on its type (string or array)
 				if contentResult.Exists() && contentResult.Type == gjson.String && contentResult.String() != "" {
 					part := `{"type":"text","text":""}`
 					part, _ = sjson.Set(part, "text", contentResult.String())
+					part = copyCacheControl(part, message)
 					msg, _ = sjson.SetRaw(msg, "content.-1", part)
 				} else if contentResult.Exists() && contentResult.IsArray() {
 					contentResult.ForEach(func(_, part gjson.Result) bool {
@@ -180,24 +190,13 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 						case "text":
 							textPart := `{"type":"text","text":""}`
 							textPart, _ = sjson.Set(textPart, "text", part.Get("text").String())
+							textPart = copyCacheControl(textPart, part)
 							msg, _ = sjson.SetRaw(msg, "content.-1", textPart)
 
-						case "image_url":
-							// Convert OpenAI image format to Claude Code format
-							imageURL := part.Get("image_url.url").String()
-							if strings.HasPrefix(imageURL, "data:") {
-								// Extract base64 data and media type from data URL
-								parts := strings.Split(imageURL, ",")
-								if len(parts) == 2 {
-									mediaTypePart := strings.Split(parts[0], ";")[0]
-									mediaType := strings.TrimPrefix(mediaTypePart, "data:")
-									data := parts[1]
-
-									imagePart := `{"type":"image","source":{"type":"base64","media_type":"","data":""}}`
-									imagePart, _ = sjson.Set(imagePart, "source.media_type", mediaType)
-									imagePart, _ = sjson.Set(imagePart, "source.data", data)
-									msg, _ = sjson.SetRaw(msg, "content.-1", imagePart)
-								}
+						case "image_url", "file":
+							// Convert OpenAI image and file parts to Claude image and document blocks
+							if imagePart, ok := convertOpenAIMediaPart(part); ok {
+								msg, _ = sjson.SetRaw(msg, "content.-1", copyCacheControl(imagePart, part))
 							}
 						}
 						return true
@@ -246,13 +245,19 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 
 			case "tool":
 				// Handle tool result messages conversion
-				toolCallID := message.Get("tool_call_id").String()
-				content := message.Get("content").String()
-
-				msg := `{"role":"user","content":[{"type":"tool_result","tool_use_id":"","content":""}]}`
-				msg, _ = sjson.Set(msg, "content.0.tool_use_id", toolCallID)
-				msg, _ = sjson.Set(msg, "content.0.content", content)
+				toolResult := `{"type":"tool_result","tool_use_id":"","content":""}`
+				toolResult, _ = sjson.Set(toolResult, "tool_use_id", message.Get("tool_call_id").String())
+				toolResult, _ = sjson.SetRaw(toolResult, "content", convertOpenAIToolResultContent(contentResult))
+				toolResult = copyCacheControl(toolResult, message)
+
+				if toolResultMessageIndex >= 0 {
+					out, _ = sjson.SetRaw(out, fmt.Sprintf("messages.%d.content.-1", toolResultMessageIndex), toolResult)
+					return true
+				}
+				msg := `{"role":"user","content":[]}`
+				msg, _ = sjson.SetRaw(msg, "content.-1", toolResult)
 				out, _ = sjson.SetRaw(out, "messages.-1", msg)
+				toolResultMessageIndex = messageIndex
 				messageIndex++
 			}
 			return true
@@ -276,6 +281,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 					anthropicTool, _ = sjson.SetRaw(anthropicTool, "input_schema", parameters.Raw)
 				}
 
+				anthropicTool = copyCacheControl(anthropicTool, tool)
+				anthropicTool = copyCacheControl(anthropicTool, function)
 				out, _ = sjson.SetRaw(out, "tools.-1", anthropicTool)
 				hasAnthropicTools = true
 			}
@@ -294,7 +301,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			choice := toolChoice.String()
 			switch choice {
 			case "none":
-				// Don't set tool_choice, Claude Code will not use tools
+				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"none"}`)
 			case "auto":
 				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
 			case "required":
@@ -304,6 +311,10 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			// Specific tool choice mapping
 			if toolChoice.Get("type").String() == "function" {
 				functionName := toolChoice.Get("function.name").String()
+				if functionName == "" {
+					// Responses-style shape: {"type":"function","name":"..."}
+					functionName = toolChoice.Get("name").String()
+				}
 				toolChoiceJSON := `{"type":"tool","name":""}`
 				toolChoiceJSON, _ = sjson.Set(toolChoiceJSON, "name", functionName)
 				out, _ = sjson.SetRaw(out, "tool_choice", toolChoiceJSON)
@@ -312,5 +323,169 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
+	// Structured outputs: Claude has no response_format, so a json_schema request is
+	// served by a forced tool whose input is the requested object. The response
+	// translator unwraps that tool call back into plain assistant content.
+	switch responseFormat := root.Get("response_format"); responseFormat.Get("type").String() {
+	case "json_schema":
+		if schema := responseFormat.Get("json_schema.schema"); schema.IsObject() && gjson.Get(schema.Raw, "type").String() == "object" {
+			description := responseFormat.Get("json_schema.description").String()
+			if description == "" {
+				description = structuredOutputToolDescription
+			}
+			structuredTool := `{"name":"","description":"","input_schema":{}}`
+			structuredTool, _ = sjson.Set(structuredTool, "name", structuredOutputToolName)
+			structuredTool, _ = sjson.Set(structuredTool, "description", description)
+			structuredTool, _ = sjson.SetRaw(structuredTool, "input_schema", schema.Raw)
+			hasClientTools := gjson.Get(out, "tools").Exists()
+			out, _ = sjson.SetRaw(out, "tools.-1", structuredTool)
+
+			// Claude rejects forced tool use together with extended thinking, and forcing
+			// the tool would keep the model from calling the client's own tools.
+			if hasClientTools || gjson.Get(out, "thinking.type").String() == "enabled" {
+				out = appendClaudeSystemText(out, structuredOutputInstruction)
+			} else {
+				out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"tool","name":"`+structuredOutputToolName+`"}`)
+			}
+		} else {
+			out = appendClaudeSystemText(out, jsonOnlyInstruction)
+		}
+	case "json_object":
+		out = appendClaudeSystemText(out, jsonOnlyInstruction)
+	}
+
+	// OpenAI parallel_tool_calls=false maps to Claude's disable_parallel_tool_use, which
+	// lives on tool_choice; auto is assumed when the request did not pick a choice.
+	if parallel := root.Get("parallel_tool_calls"); parallel.Exists() && !parallel.Bool() && gjson.Get(out, "tools").Exists() {
+		if !gjson.Get(out, "tool_choice").Exists() {
+			out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
+		}
+		if gjson.Get(out, "tool_choice.type").String() != "none" {
+			out, _ = sjson.Set(out, "tool_choice.disable_parallel_tool_use", true)
+		}
+	}
+
 	return []byte(out)
 }
+
+// appendClaudeSystemText appends a text block to the top-level system prompt of out.
+func appendClaudeSystemText(out, text string) string {
+	block, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+	if system := gjson.Get(out, "system"); system.Type == gjson.String {
+		existing, _ := sjson.Set(`{"type":"text","text":""}`, "text", system.String())
+		out, _ = sjson.SetRaw(out, "system", "["+existing+"]")
+	}
+	out, _ = sjson.SetRaw(out, "system.-1", block)
+	return out
+}
+
+// convertOpenAIMediaPart converts an OpenAI image_url or file content part into a
+// Claude image block, or a document block for PDFs. Data URIs become base64 sources,
+// with the media type checked against the payload's magic bytes; http(s) URLs are
+// passed as url sources, which Claude fetches itself.
+func convertOpenAIMediaPart(part gjson.Result) (string, bool) {
+	var mediaType, data, filename string
+	switch part.Get("type").String() {
+	case "image_url":
+		imageURL := part.Get("image_url.url").String()
+		if imageURL == "" {
+			imageURL = part.Get("image_url").String()
+		}
+		if strings.HasPrefix(imageURL, "http://") || strings.HasPrefix(imageURL, "https://") {
+			blockType := "image"
+			if strings.HasSuffix(strings.ToLower(strings.SplitN(imageURL, "?", 2)[0]), ".pdf") {
+				blockType = "document"
+			}
+			block := `{"type":"","source":{"type":"url","url":""}}`
+			block, _ = sjson.Set(block, "type", blockType)
+			block, _ = sjson.Set(block, "source.url", imageURL)
+			return block, true
+		}
+		var ok bool
+		if mediaType, data, ok = util.ParseDataURI(imageURL); !ok {
+			return "", false
+		}
+	case "file":
+		fileData := part.Get("file.file_data").String()
+		filename = part.Get("file.filename").String()
+		if fileData == "" {
+			return "", false
+		}
+		if parsedType, parsedData, ok := util.ParseDataURI(fileData); ok {
+			mediaType, data = parsedType, parsedData
+		} else {
+			declared := ""
+			if dot := strings.LastIndex(filename, "."); dot >= 0 {
+				declared = misc.MimeTypes[strings.ToLower(filename[dot+1:])]
+			}
+			mediaType, data = util.SniffBase64MediaType(fileData, declared), fileData
+		}
+	default:
+		return "", false
+	}
+
+	switch {
+	case mediaType == "application/pdf":
+		block := `{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":""}}`
+		block, _ = sjson.Set(block, "source.data", data)
+		if filename != "" {
+			block, _ = sjson.Set(block, "title", filename)
+		}
+		return block, true
+	case strings.HasPrefix(mediaType, "image/"):
+		block := `{"type":"image","source":{"type":"base64","media_type":"","data":""}}`
+		block, _ = sjson.Set(block, "source.media_type", mediaType)
+		block, _ = sjson.Set(block, "source.data", data)
+		return block, true
+	default:
+		log.Warnf("claude openai translator: unsupported media type %q in content part, skipping", mediaType)
+		return "", false
+	}
+}
+
+// convertOpenAIToolResultContent returns the raw JSON for the content of a Claude
+// tool_result built from an OpenAI tool message. String content stays a string; content
+// part arrays become text, image and document blocks.
+func convertOpenAIToolResultContent(content gjson.Result) string {
+	switch {
+	case !content.Exists() || content.Type == gjson.Null:
+		return `""`
+	case content.Type == gjson.String:
+		raw, _ := json.Marshal(content.String())
+		return string(raw)
+	case content.IsArray():
+		blocks := "[]"
+		content.ForEach(func(_, part gjson.Result) bool {
+			switch {
+			case part.Type == gjson.String:
+				textPart, _ := sjson.Set(`{"type":"text","text":""}`, "text", part.String())
+				blocks, _ = sjson.SetRaw(blocks, "-1", textPart)
+			case part.Get("type").String() == "text":
+				textPart, _ := sjson.Set(`{"type":"text","text":""}`, "text", part.Get("text").String())
+				blocks, _ = sjson.SetRaw(blocks, "-1", textPart)
+			case part.Get("type").String() == "image_url", part.Get("type").String() == "file":
+				if imagePart, ok := convertOpenAIMediaPart(part); ok {
+					blocks, _ = sjson.SetRaw(blocks, "-1", imagePart)
+				}
+			}
+			return true
+		})
+		if len(gjson.Parse(blocks).Array()) == 0 {
+			return `""`
+		}
+		return blocks
+	default:
+		raw, _ := json.Marshal(content.Raw)
+		return string(raw)
+	}
+}
+
+// copyCacheControl carries a prompt caching breakpoint ("cache_control") from the
+// OpenAI-format source onto the translated Claude block, so clients that place their
+// own breakpoints on system prompts, content parts or tools keep real caching.
+func copyCacheControl(block string, source gjson.Result) string {
+	if cacheControl := source.Get("cache_control"); cacheControl.IsObject() {
+		block, _ = sjson.SetRaw(block, "cache_control", cacheControl.Raw)
+	}
+	return block
+}
@@ -0,0 +1,295 @@
+package chat_completions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	openaiclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/claude"
+	"github.com/tidwall/gjson"
+)
+
+const toolConversation = `{
+	"model": "gpt-4o",
+	"parallel_tool_calls": false,
+	"tool_choice": {"type": "function", "function": {"name": "get_weather"}},
+	"tools": [
+		{"type": "function", "function": {"name": "get_weather", "description": "Weather lookup", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}}}},
+		{"type": "function", "function": {"name": "get_time", "description": "Clock", "parameters": {"type": "object"}}}
+	],
+	"messages": [
+		{"role": "user", "content": "Weather and time in Paris?"},
+		{"role": "assistant", "content": null, "tool_calls": [
+			{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}},
+			{"id": "call_2", "type": "function", "function": {"name": "get_time", "arguments": ""}}
+		]},
+		{"role": "tool", "tool_call_id": "call_1", "content": [{"type": "text", "text": "18C"}, {"type": "text", "text": "cloudy"}]},
+		{"role": "tool", "tool_call_id": "call_2", "content": "10:00"},
+		{"role": "user", "content": "Thanks"}
+	]
+}`
+
+func TestConvertOpenAIRequestToClaude_Tools(t *testing.T) {
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(toolConversation), false))
+
+	if got := out.Get("tools.#").Int(); got != 2 {
+		t.Fatalf("expected 2 tools, got %d", got)
+	}
+	if got := out.Get("tools.0.input_schema.properties.city.type").String(); got != "string" {
+		t.Fatalf("expected input_schema to carry the parameters, got %s", out.Get("tools.0").Raw)
+	}
+	if out.Get("tool_choice.type").String() != "tool" || out.Get("tool_choice.name").String() != "get_weather" {
+		t.Fatalf("unexpected tool_choice %s", out.Get("tool_choice").Raw)
+	}
+	if !out.Get("tool_choice.disable_parallel_tool_use").Bool() {
+		t.Fatalf("expected parallel_tool_calls=false to disable parallel tool use, got %s", out.Get("tool_choice").Raw)
+	}
+
+	messages := out.Get("messages").Array()
+	if len(messages) != 4 {
+		t.Fatalf("expected user, assistant, tool results and user messages, got %d: %s", len(messages), out.Get("messages").Raw)
+	}
+	toolUses := messages[1].Get("content").Array()
+	if len(toolUses) != 2 || toolUses[0].Get("input.city").String() != "Paris" || toolUses[1].Get("input").Raw != "{}" {
+		t.Fatalf("unexpected tool_use blocks %s", messages[1].Get("content").Raw)
+	}
+
+	results := messages[2].Get("content").Array()
+	if messages[2].Get("role").String() != "user" || len(results) != 2 {
+		t.Fatalf("expected both tool results in one user message, got %s", messages[2].Raw)
+	}
+	if results[0].Get("tool_use_id").String() != "call_1" || results[0].Get("content.#").Int() != 2 || results[0].Get("content.1.text").String() != "cloudy" {
+		t.Fatalf("unexpected array tool result %s", results[0].Raw)
+	}
+	if results[1].Get("tool_use_id").String() != "call_2" || results[1].Get("content").String() != "10:00" {
+		t.Fatalf("unexpected string tool result %s", results[1].Raw)
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_ToolChoiceShapes(t *testing.T) {
+	cases := map[string]string{
+		`"none"`:                              `{"type":"none"}`,
+		`"auto"`:                              `{"type":"auto"}`,
+		`"required"`:                          `{"type":"any"}`,
+		`{"type":"function","name":"lookup"}`: `{"type":"tool","name":"lookup"}`,
+	}
+	for choice, want := range cases {
+		input := `{"tools":[{"type":"function","function":{"name":"lookup"}}],"tool_choice":` + choice + `,"messages":[{"role":"user","content":"hi"}]}`
+		got := gjson.GetBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(input), false), "tool_choice").Raw
+		if got != want {
+			t.Fatalf("tool_choice %s: got %s, want %s", choice, got, want)
+		}
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_ToolResultContentShapes(t *testing.T) {
+	for _, content := range []string{`null`, `[]`, `[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]`, `{"unexpected":true}`, `42`} {
+		input := `{"messages":[{"role":"tool","tool_call_id":"call_1","content":` + content + `}]}`
+		out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(input), false))
+		if result := out.Get("messages.0.content.0"); result.Get("type").String() != "tool_result" || !result.Get("content").Exists() {
+			t.Fatalf("content %s: unexpected tool result %s", content, result.Raw)
+		}
+	}
+}
+
+// TestToolCallsRoundTrip translates an OpenAI request to Claude and back, checking the
+// tool declarations, calls and results survive.
+func TestToolCallsRoundTrip(t *testing.T) {
+	claudeReq := ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(toolConversation), false)
+	back := gjson.ParseBytes(openaiclaude.ConvertClaudeRequestToOpenAI("gpt-4o", claudeReq, false))
+
+	orig := gjson.Parse(toolConversation)
+	if back.Get("tools.#").Int() != orig.Get("tools.#").Int() || back.Get("tools.1.function.name").String() != "get_time" {
+		t.Fatalf("tools did not survive the round trip: %s", back.Get("tools").Raw)
+	}
+	if back.Get("tool_choice.function.name").String() != "get_weather" {
+		t.Fatalf("tool_choice did not survive the round trip: %s", back.Get("tool_choice").Raw)
+	}
+	if parallel := back.Get("parallel_tool_calls"); !parallel.Exists() || parallel.Bool() {
+		t.Fatalf("expected parallel_tool_calls=false after the round trip, got %s", back.Raw)
+	}
+
+	var calls, results []gjson.Result
+	for _, message := range back.Get("messages").Array() {
+		calls = append(calls, message.Get("tool_calls").Array()...)
+		if message.Get("role").String() == "tool" {
+			results = append(results, message)
+		}
+	}
+	if len(calls) != 2 || calls[0].Get("id").String() != "call_1" || calls[1].Get("function.name").String() != "get_time" {
+		t.Fatalf("tool calls did not survive the round trip: %v", calls)
+	}
+	if args := gjson.Parse(calls[0].Get("function.arguments").String()); args.Get("city").String() != "Paris" {
+		t.Fatalf("unexpected arguments %s", calls[0].Get("function.arguments").String())
+	}
+	if calls[1].Get("function.arguments").String() != "{}" {
+		t.Fatalf("expected empty arguments to become {}, got %q", calls[1].Get("function.arguments").String())
+	}
+	if len(results) != 2 || results[0].Get("tool_call_id").String() != "call_1" || results[1].Get("content").String() != "10:00" {
+		t.Fatalf("tool results did not survive the round trip: %v", results)
+	}
+}
+
+const structuredOutputRequest = `{
+	"model": "gpt-4o",
+	"messages": [{"role": "user", "content": "List two fruits"}],
+	"response_format": {"type": "json_schema", "json_schema": {"name": "fruits", "strict": true, "schema": {
+		"type": "object",
+		"properties": {"fruits": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}, "colors": {"type": "array", "items": {"type": "string"}}}}}},
+		"required": ["fruits"],
+		"additionalProperties": false
+	}}}
+}`
+
+func TestConvertOpenAIRequestToClaude_StructuredOutput(t *testing.T) {
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(structuredOutputRequest), false))
+
+	if out.Get("tools.#").Int() != 1 || out.Get("tools.0.name").String() != structuredOutputToolName {
+		t.Fatalf("expected a single structured output tool, got %s", out.Get("tools").Raw)
+	}
+	if got := out.Get("tools.0.input_schema.properties.fruits.items.properties.colors.items.type").String(); got != "string" {
+		t.Fatalf("expected the nested schema as input_schema, got %s", out.Get("tools.0.input_schema").Raw)
+	}
+	if out.Get("tool_choice.type").String() != "tool" || out.Get("tool_choice.name").String() != structuredOutputToolName {
+		t.Fatalf("expected the structured output tool to be forced, got %s", out.Get("tool_choice").Raw)
+	}
+
+	// With client tools or thinking the tool is offered but not forced.
+	withTools := `{"tools":[{"type":"function","function":{"name":"lookup"}}],` + structuredOutputRequest[1:]
+	out = gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(withTools), false))
+	if out.Get("tools.#").Int() != 2 || out.Get("tool_choice").Exists() || !strings.Contains(out.Get("system").Raw, structuredOutputToolName) {
+		t.Fatalf("expected an instruction instead of a forced tool, got tools=%s choice=%s system=%s", out.Get("tools").Raw, out.Get("tool_choice").Raw, out.Get("system").Raw)
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_JSONObjectInstruction(t *testing.T) {
+	input := `{"messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_object"}}`
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(input), false))
+	if out.Get("system.0.text").String() != jsonOnlyInstruction || out.Get("tools").Exists() {
+		t.Fatalf("expected a JSON-only system instruction, got %s", out.Raw)
+	}
+}
+
+const visionConversation = `{
+	"model": "gpt-4o",
+	"tools": [{"type": "function", "function": {"name": "screenshot", "parameters": {"type": "object"}}}],
+	"messages": [
+		{"role": "user", "content": [
+			{"type": "text", "text": "Compare these"},
+			{"type": "image_url", "image_url": {"url": "data:image/jpeg;base64,iVBORw0KGgoAAAANSUhEUg=="}},
+			{"type": "image_url", "image_url": {"url": "https://example.com/cat.png"}},
+			{"type": "file", "file": {"filename": "spec.pdf", "file_data": "data:application/pdf;base64,JVBERi0xLjcK"}}
+		]},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "screenshot", "arguments": "{}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": [{"type": "text", "text": "captured"}, {"type": "image_url", "image_url": {"url": "data:image/gif;base64,R0lGODlhAQABAAAAACw="}}]}
+	]
+}`
+
+func TestConvertOpenAIRequestToClaude_Vision(t *testing.T) {
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(visionConversation), false))
+
+	content := out.Get("messages.0.content")
+	if got := content.Get("#").Int(); got != 4 {
+		t.Fatalf("expected 4 user blocks, got %s", content.Raw)
+	}
+	if content.Get("1.type").String() != "image" || content.Get("1.source.media_type").String() != "image/png" {
+		t.Fatalf("expected the mislabelled data URI to be sniffed as PNG, got %s", content.Get("1").Raw)
+	}
+	if content.Get("2.source.type").String() != "url" || content.Get("2.source.url").String() != "https://example.com/cat.png" {
+		t.Fatalf("expected a URL image source, got %s", content.Get("2").Raw)
+	}
+	if content.Get("3.type").String() != "document" || content.Get("3.source.media_type").String() != "application/pdf" || content.Get("3.title").String() != "spec.pdf" {
+		t.Fatalf("expected a PDF document block, got %s", content.Get("3").Raw)
+	}
+
+	result := out.Get("messages.2.content.0")
+	if result.Get("type").String() != "tool_result" || result.Get("content.1.type").String() != "image" || result.Get("content.1.source.media_type").String() != "image/gif" {
+		t.Fatalf("expected the tool result to carry the image, got %s", result.Raw)
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_RestoresSignedThinking(t *testing.T) {
+	cache.ClearSignatureCache("")
+	t.Cleanup(func() { cache.ClearSignatureCache("") })
+	cache.CacheSignature("claude-sonnet-4", "The user wants the weather.", testThinkingSignature)
+
+	request := `{
+		"model": "gpt-4o",
+		"reasoning_effort": "high",
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": "", "reasoning_content": "The user wants the weather.", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "18C"},
+			{"role": "assistant", "content": "18C", "reasoning_content": "unsigned reasoning"}
+		]
+	}`
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(request), false))
+
+	first := out.Get("messages.1.content.0")
+	if first.Get("type").String() != "thinking" || first.Get("signature").String() != testThinkingSignature || first.Get("thinking").String() != "The user wants the weather." {
+		t.Fatalf("expected the signed thinking block first, got %s", out.Get("messages.1.content").Raw)
+	}
+	if out.Get("messages.1.content.1.type").String() != "tool_use" {
+		t.Fatalf("expected tool_use after the thinking block, got %s", out.Get("messages.1.content").Raw)
+	}
+	if got := out.Get(`messages.3.content.#(type=="thinking")`); got.Exists() {
+		t.Fatalf("expected unsigned reasoning to be dropped, got %s", got.Raw)
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_EffortBudgets(t *testing.T) {
+	t.Cleanup(func() { _ = thinking.SetLevelBudgets(nil) })
+
+	request := []byte(`{"model":"gpt-4o","reasoning_effort":"high","messages":[{"role":"user","content":"hi"}]}`)
+	if got := gjson.GetBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", request, false), "thinking.budget_tokens").Int(); got != 24576 {
+		t.Fatalf("default high budget = %d, want 24576", got)
+	}
+	if err := thinking.SetLevelBudgets(map[string]int{"High": 32000}); err != nil {
+		t.Fatalf("SetLevelBudgets: %v", err)
+	}
+	if got := gjson.GetBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", request, false), "thinking.budget_tokens").Int(); got != 32000 {
+		t.Fatalf("configured high budget = %d, want 32000", got)
+	}
+	if err := thinking.SetLevelBudgets(map[string]int{"none": 10}); err == nil {
+		t.Fatalf("expected overriding none to be rejected")
+	}
+	if err := thinking.SetLevelBudgets(map[string]int{"low": 0}); err == nil {
+		t.Fatalf("expected a non-positive budget to be rejected")
+	}
+	if budget, _ := thinking.ConvertLevelToBudget("high"); budget != 32000 {
+		t.Fatalf("expected a rejected update to keep the previous budgets, got %d", budget)
+	}
+}
+
+func TestConvertOpenAIRequestToClaude_PreservesCacheControl(t *testing.T) {
+	input := `{
+		"model":"claude-sonnet-4",
+		"messages":[
+			{"role":"system","content":[{"type":"text","text":"Long instructions.","cache_control":{"type":"ephemeral"}}]},
+			{"role":"user","content":[
+				{"type":"text","text":"Read this.","cache_control":{"type":"ephemeral","ttl":"1h"}},
+				{"type":"text","text":"Then answer."}
+			]},
+			{"role":"user","content":"Plain turn.","cache_control":{"type":"ephemeral"}}
+		],
+		"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}},"cache_control":{"type":"ephemeral"}}]
+	}`
+	out := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4", []byte(input), false))
+
+	if got := out.Get("messages.0.content.0.cache_control.type").String(); got != "ephemeral" {
+		t.Fatalf("expected the system breakpoint to be kept, got %s", out.Get("messages.0").Raw)
+	}
+	if got := out.Get("messages.1.content.0.cache_control.ttl").String(); got != "1h" {
+		t.Fatalf("expected the text breakpoint with its ttl, got %s", out.Get("messages.1").Raw)
+	}
+	if out.Get("messages.1.content.1.cache_control").Exists() {
+		t.Fatalf("expected no breakpoint on the unmarked part, got %s", out.Get("messages.1").Raw)
+	}
+	if !out.Get("messages.2.content.0.cache_control").Exists() {
+		t.Fatalf("expected the message-level breakpoint on string content, got %s", out.Get("messages.2").Raw)
+	}
+	if !out.Get("tools.0.cache_control").Exists() {
+		t.Fatalf("expected the tool breakpoint to be kept, got %s", out.Get("tools").Raw)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -27,6 +28,14 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	FinishReason string
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// ToolCallCount is the number of tool calls started so far; it provides the OpenAI
+	// tool_calls index, which counts tool calls only rather than all content blocks.
+	ToolCallCount int
+	// StructuredOutput reports whether the client asked for a json_schema response_format,
+	// whose structured_output tool call is unwrapped into plain content.
+	StructuredOutput bool
+	// ThinkingText accumulates the thinking block in progress until its signature arrives.
+	ThinkingText strings.Builder
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -34,6 +43,10 @@ type ToolCallAccumulator struct {
 	ID        string
 	Name      string
 	Arguments strings.Builder
+	// Index is the OpenAI tool_calls index assigned to this call while streaming.
+	Index int
+	// Structured marks the structured_output tool call, which is returned as content.
+	Structured bool
 }
 
 // ConvertClaudeResponseToOpenAI converts Claude Code streaming response format to OpenAI Chat Completions format.
@@ -56,9 +69,10 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 	}
 	if *param == nil {
 		*param = &ConvertAnthropicResponseToOpenAIParams{
-			CreatedAt:    0,
-			ResponseID:   "",
-			FinishReason: "",
+			CreatedAt:        0,
+			ResponseID:       "",
+			FinishReason:     "",
+			StructuredOutput: requestsStructuredOutput(originalRequestRawJSON),
 		}
 	}
 
@@ -122,13 +136,27 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator = make(map[int]*ToolCallAccumulator)
 				}
 
+				if (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput && toolName == structuredOutputToolName {
+					// The structured output arrives as tool input but is sent as content
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index] = &ToolCallAccumulator{Structured: true}
+					return []string{}
+				}
+
+				toolCallIndex := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallCount
+				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallCount++
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index] = &ToolCallAccumulator{
-					ID:   toolCallID,
-					Name: toolName,
+					ID:    toolCallID,
+					Name:  toolName,
+					Index: toolCallIndex,
 				}
 
-				// Don't output anything yet - wait for complete tool call
-				return []string{}
+				// Announce the tool call; its arguments follow as incremental deltas
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", toolCallIndex)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.id", toolCallID)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.type", "function")
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.name", toolName)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", "")
+				return []string{template}
 			}
 		}
 		return []string{}
@@ -149,20 +177,27 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			case "thinking_delta":
 				// Accumulate reasoning/thinking content
 				if thinking := delta.Get("thinking"); thinking.Exists() {
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).ThinkingText.WriteString(thinking.String())
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", thinking.String())
 					hasContent = true
 				}
+			case "signature_delta":
+				cacheThinkingSignature(modelName, &(*param).(*ConvertAnthropicResponseToOpenAIParams).ThinkingText, delta.Get("signature").String())
 			case "input_json_delta":
-				// Tool use input delta - accumulate arguments for tool calls
-				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
+				// Tool use input delta - forward the arguments fragment for the tool call
+				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() && partialJSON.String() != "" {
 					index := int(root.Get("index").Int())
-					if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
-						if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
-							accumulator.Arguments.WriteString(partialJSON.String())
+					if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
+						accumulator.Arguments.WriteString(partialJSON.String())
+						if accumulator.Structured {
+							template, _ = sjson.Set(template, "choices.0.delta.content", partialJSON.String())
+							return []string{template}
 						}
+						template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", accumulator.Index)
+						template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", partialJSON.String())
+						return []string{template}
 					}
 				}
-				// Don't output anything yet - wait for complete tool call
 				return []string{}
 			}
 		}
@@ -173,24 +208,19 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		}
 
 	case "content_block_stop":
-		// End of content block - output complete tool call if it's a tool_use block
+		// End of content block - tool calls without any input still need valid arguments
 		index := int(root.Get("index").Int())
-		if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
-			if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
-				// Build complete tool call with accumulated arguments
-				arguments := accumulator.Arguments.String()
-				if arguments == "" {
-					arguments = "{}"
-				}
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.id", accumulator.ID)
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.type", "function")
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.name", accumulator.Name)
-				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", arguments)
-
-				// Clean up the accumulator for this index
-				delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
+		if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
+			// Clean up the accumulator for this index
+			delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
 
+			if accumulator.Arguments.Len() == 0 && accumulator.Structured {
+				template, _ = sjson.Set(template, "choices.0.delta.content", "{}")
+				return []string{template}
+			}
+			if accumulator.Arguments.Len() == 0 {
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", accumulator.Index)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", "{}")
 				return []string{template}
 			}
 		}
@@ -201,20 +231,17 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		if delta := root.Get("delta"); delta.Exists() {
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = mapAnthropicStopReasonToOpenAI(stopReason.String())
+				if (*param).(*ConvertAnthropicResponseToOpenAIParams).StructuredOutput && (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallCount == 0 && stopReason.String() == "tool_use" {
+					// Only the structured output tool was called, which the client sees as content
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = "stop"
+				}
 				template, _ = sjson.Set(template, "choices.0.finish_reason", (*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason)
 			}
 		}
 
 		// Handle usage information for token counts
 		if usage := root.Get("usage"); usage.Exists() {
-			inputTokens := usage.Get("input_tokens").Int()
-			outputTokens := usage.Get("output_tokens").Int()
-			cacheReadInputTokens := usage.Get("cache_read_input_tokens").Int()
-			cacheCreationInputTokens := usage.Get("cache_creation_input_tokens").Int()
-			template, _ = sjson.Set(template, "usage.prompt_tokens", inputTokens+cacheCreationInputTokens)
-			template, _ = sjson.Set(template, "usage.completion_tokens", outputTokens)
-			template, _ = sjson.Set(template, "usage.total_tokens", inputTokens+outputTokens)
-			template, _ = sjson.Set(template, "usage.prompt_tokens_details.cached_tokens", cacheReadInputTokens)
+			template = setOpenAIUsage(template, usage)
 		}
 		return []string{template}
 
@@ -242,6 +269,21 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 	}
 }
 
+// cacheThinkingSignature remembers the signature of a completed thinking block, keyed
+// by its text, so ConvertOpenAIRequestToClaude can restore the signed block when the
+// client sends the reasoning back as reasoning_content. Claude rejects tool use turns
+// whose thinking block is missing while extended thinking is enabled.
+func cacheThinkingSignature(modelName string, text *strings.Builder, signature string) {
+	cache.CacheSignature(modelName, text.String(), signature)
+	text.Reset()
+}
+
+// requestsStructuredOutput reports whether an OpenAI request asked for a json_schema
+// response_format, which the request translator serves with the structured_output tool.
+func requestsStructuredOutput(originalRequestRawJSON []byte) bool {
+	return gjson.GetBytes(originalRequestRawJSON, "response_format.type").String() == "json_schema"
+}
+
 // mapAnthropicStopReasonToOpenAI maps Anthropic stop reasons to OpenAI stop reasons
 func mapAnthropicStopReasonToOpenAI(anthropicReason string) string {
 	switch anthropicReason {
@@ -265,13 +307,14 @@ func mapAnthropicStopReasonToOpenAI(anthropicReason string) string {
 //
 // Parameters:
 //   - ctx: The context for the request, used for cancellation and timeout handling
-//   - modelName: The name of the model being used for the response (unused in current implementation)
+//   - modelName: The name of the model being used for the response, used to key cached thinking signatures
 //   - rawJSON: The raw JSON response from the Claude Code API
 //   - param: A pointer to a parameter object for the conversion (unused in current implementation)
 //
 // Returns:
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
-func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertClaudeResponseToOpenAINonStream(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+	structuredOutput := requestsStructuredOutput(originalRequestRawJSON)
 	chunks := make([][]byte, 0)
 
 	lines := bytes.Split(rawJSON, []byte("\n"))
@@ -291,6 +334,8 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	var stopReason string
 	var contentParts []string
 	var reasoningParts []string
+	var thinkingText strings.Builder
+	var sawStructuredOutput bool
 	toolCallsAccumulator := make(map[int]*ToolCallAccumulator)
 
 	for _, chunk := range chunks {
@@ -317,8 +362,9 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 					// Initialize tool call accumulator for this index
 					index := int(root.Get("index").Int())
 					toolCallsAccumulator[index] = &ToolCallAccumulator{
-						ID:   contentBlock.Get("id").String(),
-						Name: contentBlock.Get("name").String(),
+						ID:         contentBlock.Get("id").String(),
+						Name:       contentBlock.Get("name").String(),
+						Structured: structuredOutput && contentBlock.Get("name").String() == structuredOutputToolName,
 					}
 				}
 			}
@@ -337,7 +383,10 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 					// Accumulate reasoning/thinking content
 					if thinking := delta.Get("thinking"); thinking.Exists() {
 						reasoningParts = append(reasoningParts, thinking.String())
+						thinkingText.WriteString(thinking.String())
 					}
+				case "signature_delta":
+					cacheThinkingSignature(modelName, &thinkingText, delta.Get("signature").String())
 				case "input_json_delta":
 					// Accumulate tool call arguments
 					if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
@@ -356,6 +405,12 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				if accumulator.Arguments.Len() == 0 {
 					accumulator.Arguments.WriteString("{}")
 				}
+				if accumulator.Structured {
+					// The structured output tool input is the assistant content
+					contentParts = append(contentParts, accumulator.Arguments.String())
+					sawStructuredOutput = true
+					delete(toolCallsAccumulator, index)
+				}
 			}
 
 		case "message_delta":
@@ -366,14 +421,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 				}
 			}
 			if usage := root.Get("usage"); usage.Exists() {
-				inputTokens := usage.Get("input_tokens").Int()
-				outputTokens := usage.Get("output_tokens").Int()
-				cacheReadInputTokens := usage.Get("cache_read_input_tokens").Int()
-				cacheCreationInputTokens := usage.Get("cache_creation_input_tokens").Int()
-				out, _ = sjson.Set(out, "usage.prompt_tokens", inputTokens+cacheCreationInputTokens)
-				out, _ = sjson.Set(out, "usage.completion_tokens", outputTokens)
-				out, _ = sjson.Set(out, "usage.total_tokens", inputTokens+outputTokens)
-				out, _ = sjson.Set(out, "usage.prompt_tokens_details.cached_tokens", cacheReadInputTokens)
+				out = setOpenAIUsage(out, usage)
 			}
 		}
 	}
@@ -387,11 +435,10 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	messageContent := strings.Join(contentParts, "")
 	out, _ = sjson.Set(out, "choices.0.message.content", messageContent)
 
-	// Add reasoning content if available (following OpenAI reasoning format)
+	// Add reasoning content if available (the reasoning_content convention of DeepSeek and OpenRouter)
 	if len(reasoningParts) > 0 {
 		reasoningContent := strings.Join(reasoningParts, "")
-		// Add reasoning as a separate field in the message
-		out, _ = sjson.Set(out, "choices.0.message.reasoning", reasoningContent)
+		out, _ = sjson.Set(out, "choices.0.message.reasoning_content", reasoningContent)
 	}
 
 	// Set tool calls if any were accumulated during processing
@@ -428,9 +475,31 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 		} else {
 			out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 		}
+	} else if sawStructuredOutput && stopReason == "tool_use" {
+		out, _ = sjson.Set(out, "choices.0.finish_reason", "stop")
 	} else {
 		out, _ = sjson.Set(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 	}
 
 	return out
 }
+
+// setOpenAIUsage writes Claude usage onto an OpenAI response. Claude reports cache
+// reads and writes apart from input_tokens while OpenAI's prompt_tokens includes
+// them, so the upstream cache counts are summed into prompt_tokens and passed through
+// as prompt_tokens_details rather than estimated.
+func setOpenAIUsage(out string, usage gjson.Result) string {
+	inputTokens := usage.Get("input_tokens").Int()
+	outputTokens := usage.Get("output_tokens").Int()
+	cacheReadInputTokens := usage.Get("cache_read_input_tokens").Int()
+	cacheCreationInputTokens := usage.Get("cache_creation_input_tokens").Int()
+	promptTokens := inputTokens + cacheCreationInputTokens + cacheReadInputTokens
+	out, _ = sjson.Set(out, "usage.prompt_tokens", promptTokens)
+	out, _ = sjson.Set(out, "usage.completion_tokens", outputTokens)
+	out, _ = sjson.Set(out, "usage.total_tokens", promptTokens+outputTokens)
+	out, _ = sjson.Set(out, "usage.prompt_tokens_details.cached_tokens", cacheReadInputTokens)
+	if cacheCreationInputTokens > 0 {
+		out, _ = sjson.Set(out, "usage.prompt_tokens_details.cache_creation_tokens", cacheCreationInputTokens)
+	}
+	return out
+}
@@ -0,0 +1,222 @@
+package chat_completions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/tidwall/gjson"
+)
+
+// parallelToolStream is a Claude stream with a text block followed by two tool calls,
+// the second without any input.
+var parallelToolStream = []string{
+	`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4"}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+	`{"type":"content_block_stop","index":1}`,
+	`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"get_time","input":{}}}`,
+	`{"type":"content_block_stop","index":2}`,
+	`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":10,"output_tokens":5}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestConvertClaudeResponseToOpenAI_StreamsToolCallDeltas(t *testing.T) {
+	var param any
+	type call struct {
+		id, name string
+		args     strings.Builder
+		deltas   int
+	}
+	calls := map[int64]*call{}
+	finishReason := ""
+	for _, event := range parallelToolStream {
+		for _, chunk := range ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4", nil, nil, []byte("data: "+event), &param) {
+			choice := gjson.Get(chunk, "choices.0")
+			if reason := choice.Get("finish_reason").String(); reason != "" {
+				finishReason = reason
+			}
+			for _, toolCall := range choice.Get("delta.tool_calls").Array() {
+				if !toolCall.Get("index").Exists() {
+					t.Fatalf("tool call delta without index: %s", chunk)
+				}
+				index := toolCall.Get("index").Int()
+				c := calls[index]
+				if c == nil {
+					c = &call{}
+					calls[index] = c
+				}
+				if id := toolCall.Get("id").String(); id != "" {
+					c.id = id
+				}
+				if name := toolCall.Get("function.name").String(); name != "" {
+					c.name = name
+				}
+				if args := toolCall.Get("function.arguments").String(); args != "" {
+					c.args.WriteString(args)
+					c.deltas++
+				}
+			}
+		}
+	}
+
+	if len(calls) != 2 || calls[0] == nil || calls[1] == nil {
+		t.Fatalf("expected tool calls at indexes 0 and 1, got %v", calls)
+	}
+	if calls[0].id != "toolu_1" || calls[0].name != "get_weather" || calls[0].args.String() != `{"city":"Paris"}` {
+		t.Fatalf("unexpected first tool call %q %q %q", calls[0].id, calls[0].name, calls[0].args.String())
+	}
+	if calls[0].deltas != 2 {
+		t.Fatalf("expected arguments to stream in 2 fragments, got %d", calls[0].deltas)
+	}
+	if calls[1].id != "toolu_2" || calls[1].name != "get_time" || calls[1].args.String() != "{}" {
+		t.Fatalf("unexpected second tool call %q %q %q", calls[1].id, calls[1].name, calls[1].args.String())
+	}
+	if finishReason != "tool_calls" {
+		t.Fatalf("expected finish_reason tool_calls, got %q", finishReason)
+	}
+}
+
+func TestConvertClaudeResponseToOpenAINonStream_ParallelToolCalls(t *testing.T) {
+	raw := "data: " + strings.Join(parallelToolStream, "\ndata: ")
+	out := gjson.Parse(ConvertClaudeResponseToOpenAINonStream(context.Background(), "claude-sonnet-4", nil, nil, []byte(raw), nil))
+
+	calls := out.Get("choices.0.message.tool_calls").Array()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %s", out.Raw)
+	}
+	if calls[0].Get("function.arguments").String() != `{"city":"Paris"}` || calls[1].Get("function.arguments").String() != "{}" {
+		t.Fatalf("unexpected tool call arguments %s", out.Get("choices.0.message.tool_calls").Raw)
+	}
+	if out.Get("choices.0.message.content").String() != "Checking." || out.Get("choices.0.finish_reason").String() != "tool_calls" {
+		t.Fatalf("unexpected message %s", out.Raw)
+	}
+}
+
+var structuredOutputStream = []string{
+	`{"type":"message_start","message":{"id":"msg_2","model":"claude-sonnet-4"}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_9","name":"structured_output","input":{}}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"fruits\":[{\"name\":"}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"apple\"}]}"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":10,"output_tokens":5}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestConvertClaudeResponseToOpenAI_UnwrapsStructuredOutput(t *testing.T) {
+	original := []byte(`{"response_format":{"type":"json_schema","json_schema":{"name":"fruits","schema":{"type":"object"}}}}`)
+	var param any
+	var content strings.Builder
+	finishReason := ""
+	for _, event := range structuredOutputStream {
+		for _, chunk := range ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4", original, nil, []byte("data: "+event), &param) {
+			if gjson.Get(chunk, "choices.0.delta.tool_calls").Exists() {
+				t.Fatalf("structured output must not surface as a tool call: %s", chunk)
+			}
+			content.WriteString(gjson.Get(chunk, "choices.0.delta.content").String())
+			if reason := gjson.Get(chunk, "choices.0.finish_reason").String(); reason != "" {
+				finishReason = reason
+			}
+		}
+	}
+	if content.String() != `{"fruits":[{"name":"apple"}]}` || finishReason != "stop" {
+		t.Fatalf("unexpected structured stream %q finish=%q", content.String(), finishReason)
+	}
+
+	raw := "data: " + strings.Join(structuredOutputStream, "\ndata: ")
+	out := gjson.Parse(ConvertClaudeResponseToOpenAINonStream(context.Background(), "claude-sonnet-4", original, nil, []byte(raw), nil))
+	if out.Get("choices.0.message.content").String() != `{"fruits":[{"name":"apple"}]}` || out.Get("choices.0.message.tool_calls").Exists() || out.Get("choices.0.finish_reason").String() != "stop" {
+		t.Fatalf("unexpected structured response %s", out.Raw)
+	}
+
+	// Without a json_schema response_format the tool call is passed through as is.
+	out = gjson.Parse(ConvertClaudeResponseToOpenAINonStream(context.Background(), "claude-sonnet-4", []byte(`{}`), nil, []byte(raw), nil))
+	if out.Get("choices.0.message.tool_calls.0.function.name").String() != "structured_output" {
+		t.Fatalf("expected a regular tool call without response_format, got %s", out.Raw)
+	}
+}
+
+// thinkingToolStream interleaves a signed thinking block, text and a tool call.
+var thinkingToolStream = []string{
+	`{"type":"message_start","message":{"id":"msg_2","model":"claude-sonnet-4"}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"The user wants "}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"the weather."}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"` + testThinkingSignature + `"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Let me check."}}`,
+	`{"type":"content_block_stop","index":1}`,
+	`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+	`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+	`{"type":"content_block_stop","index":2}`,
+	`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":10,"output_tokens":5}}`,
+}
+
+const testThinkingSignature = "EqQBCkgIARABGAIiQL2o7ptzT1zNcJm3pHvCmfXYUk4n1Y3dq0xFObY5kRrTr5gq"
+
+func TestConvertClaudeResponseToOpenAI_StreamsReasoningInOrder(t *testing.T) {
+	cache.ClearSignatureCache("")
+	t.Cleanup(func() { cache.ClearSignatureCache("") })
+
+	var param any
+	var order []string
+	for _, event := range thinkingToolStream {
+		for _, chunk := range ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4", nil, nil, []byte("data: "+event), &param) {
+			delta := gjson.Get(chunk, "choices.0.delta")
+			switch {
+			case delta.Get("reasoning_content").Exists():
+				order = append(order, "reasoning:"+delta.Get("reasoning_content").String())
+			case delta.Get("content").Exists():
+				order = append(order, "content:"+delta.Get("content").String())
+			case delta.Get("tool_calls.0.id").Exists():
+				order = append(order, "tool:"+delta.Get("tool_calls.0.function.name").String())
+			}
+		}
+	}
+	want := []string{"reasoning:The user wants ", "reasoning:the weather.", "content:Let me check.", "tool:get_weather"}
+	if strings.Join(order, "|") != strings.Join(want, "|") {
+		t.Fatalf("delta order = %q, want %q", order, want)
+	}
+	if got := cache.GetCachedSignature("claude-sonnet-4", "The user wants the weather."); got != testThinkingSignature {
+		t.Fatalf("expected the thinking signature to be cached, got %q", got)
+	}
+}
+
+func TestConvertClaudeResponseToOpenAINonStream_ReasoningContent(t *testing.T) {
+	cache.ClearSignatureCache("")
+	t.Cleanup(func() { cache.ClearSignatureCache("") })
+
+	raw := "data: " + strings.Join(thinkingToolStream, "\ndata: ")
+	out := gjson.Parse(ConvertClaudeResponseToOpenAINonStream(context.Background(), "claude-sonnet-4", nil, nil, []byte(raw), nil))
+	if got := out.Get("choices.0.message.reasoning_content").String(); got != "The user wants the weather." {
+		t.Fatalf("expected reasoning_content, got %s", out.Get("choices.0.message").Raw)
+	}
+	if cache.GetCachedSignature("claude-sonnet-4", "The user wants the weather.") != testThinkingSignature {
+		t.Fatalf("expected the non-stream signature to be cached")
+	}
+}
+
+func TestConvertClaudeResponseToOpenAINonStream_CacheUsage(t *testing.T) {
+	raw := strings.Join([]string{
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4","usage":{"input_tokens":40,"cache_creation_input_tokens":60,"cache_read_input_tokens":900,"output_tokens":1}}}`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+		`data: {"type":"content_block_stop","index":0}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"input_tokens":40,"cache_creation_input_tokens":60,"cache_read_input_tokens":900,"output_tokens":5}}`,
+		`data: {"type":"message_stop"}`,
+	}, "\n")
+	usage := gjson.Parse(ConvertClaudeResponseToOpenAINonStream(context.Background(), "claude-sonnet-4", nil, nil, []byte(raw), nil)).Get("usage")
+	if usage.Get("prompt_tokens").Int() != 1000 || usage.Get("total_tokens").Int() != 1005 {
+		t.Fatalf("expected cache reads and writes in prompt_tokens, got %s", usage.Raw)
+	}
+	if usage.Get("prompt_tokens_details.cached_tokens").Int() != 900 || usage.Get("prompt_tokens_details.cache_creation_tokens").Int() != 60 {
+		t.Fatalf("expected upstream cache counts, got %s", usage.Raw)
+	}
+}
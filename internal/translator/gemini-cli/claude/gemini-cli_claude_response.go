@@ -14,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -251,7 +252,7 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 				// Include thinking tokens in output token count if present
 				thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
 				template, _ = sjson.Set(template, "usage.output_tokens", candidatesTokenCountResult.Int()+thoughtsTokenCount)
-				template, _ = sjson.Set(template, "usage.input_tokens", usageResult.Get("promptTokenCount").Int())
+				template = setGeminiInputUsage(template, usageResult)
 
 				output = output + template + "\n\n\n"
 			}
@@ -283,7 +284,7 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 
 	inputTokens := root.Get("response.usageMetadata.promptTokenCount").Int()
 	outputTokens := root.Get("response.usageMetadata.candidatesTokenCount").Int() + root.Get("response.usageMetadata.thoughtsTokenCount").Int()
-	out, _ = sjson.Set(out, "usage.input_tokens", inputTokens)
+	out = setGeminiInputUsage(out, root.Get("response.usageMetadata"))
 	out, _ = sjson.Set(out, "usage.output_tokens", outputTokens)
 
 	parts := root.Get("response.candidates.0.content.parts")
@@ -373,6 +374,17 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 	return out
 }
 
+// setGeminiInputUsage writes Gemini prompt usage into the Claude usage object of
+// template, reporting cached content as cache_read_input_tokens.
+func setGeminiInputUsage(template string, usageMetadata gjson.Result) string {
+	dist := internalusage.FromGeminiUsage(usageMetadata.Get("promptTokenCount").Int(), usageMetadata.Get("cachedContentTokenCount").Int())
+	template, _ = sjson.Set(template, "usage.input_tokens", dist.InputTokens)
+	if dist.CacheReadInputTokens > 0 {
+		template, _ = sjson.Set(template, "usage.cache_read_input_tokens", dist.CacheReadInputTokens)
+	}
+	return template
+}
+
 func ClaudeTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"input_tokens":%d}`, count)
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/params"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -104,11 +105,20 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						if len(toolCallIDs) > 1 {
 							funcName = strings.Join(toolCallIDs[0:len(toolCallIDs)-1], "-")
 						}
-						responseData := contentResult.Get("content").Raw
+						responseData, media := common.ClaudeToolResultMedia(contentResult.Get("content"))
 						part := `{"functionResponse":{"name":"","response":{"result":""}}}`
 						part, _ = sjson.Set(part, "functionResponse.name", funcName)
 						part, _ = sjson.Set(part, "functionResponse.response.result", responseData)
 						contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
+						// Function responses carry no images; send them as parts of the same turn.
+						for _, mediaPart := range media {
+							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", string(mediaPart))
+						}
+
+					case "image", "document":
+						if part, ok := common.ClaudeMediaPart(contentResult); ok {
+							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", string(part))
+						}
 					}
 					return true
 				})
@@ -168,15 +178,8 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			out, _ = sjson.Set(out, "generationConfig.thinkingConfig.includeThoughts", true)
 		}
 	}
-	if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.Set(out, "generationConfig.temperature", v.Num)
-	}
-	if v := gjson.GetBytes(rawJSON, "top_p"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.Set(out, "generationConfig.topP", v.Num)
-	}
-	if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.Set(out, "generationConfig.topK", v.Num)
-	}
+	// Generation parameters (temperature, top_p, top_k, max_tokens, stop_sequences)
+	out = string(params.Translate(params.Claude, params.Gemini, modelName, rawJSON, []byte(out), "generationConfig"))
 
 	result := []byte(out)
 	result = common.AttachDefaultSafetySettings(result, "safetySettings")
@@ -14,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -257,7 +258,7 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 
 				thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
 				template, _ = sjson.Set(template, "usage.output_tokens", candidatesTokenCountResult.Int()+thoughtsTokenCount)
-				template, _ = sjson.Set(template, "usage.input_tokens", usageResult.Get("promptTokenCount").Int())
+				template = setGeminiInputUsage(template, usageResult)
 
 				output = output + template + "\n\n\n"
 			}
@@ -289,7 +290,7 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 
 	inputTokens := root.Get("usageMetadata.promptTokenCount").Int()
 	outputTokens := root.Get("usageMetadata.candidatesTokenCount").Int() + root.Get("usageMetadata.thoughtsTokenCount").Int()
-	out, _ = sjson.Set(out, "usage.input_tokens", inputTokens)
+	out = setGeminiInputUsage(out, root.Get("usageMetadata"))
 	out, _ = sjson.Set(out, "usage.output_tokens", outputTokens)
 
 	parts := root.Get("candidates.0.content.parts")
@@ -379,6 +380,17 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 	return out
 }
 
+// setGeminiInputUsage writes Gemini prompt usage into the Claude usage object of
+// template, reporting cached content as cache_read_input_tokens.
+func setGeminiInputUsage(template string, usageMetadata gjson.Result) string {
+	dist := internalusage.FromGeminiUsage(usageMetadata.Get("promptTokenCount").Int(), usageMetadata.Get("cachedContentTokenCount").Int())
+	template, _ = sjson.Set(template, "usage.input_tokens", dist.InputTokens)
+	if dist.CacheReadInputTokens > 0 {
+		template, _ = sjson.Set(template, "usage.cache_read_input_tokens", dist.CacheReadInputTokens)
+	}
+	return template
+}
+
 func ClaudeTokenCount(ctx context.Context, count int64) string {
 	return fmt.Sprintf(`{"input_tokens":%d}`, count)
 }
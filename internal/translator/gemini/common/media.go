@@ -0,0 +1,155 @@
+package common
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OpenAIImagePart converts an OpenAI image_url into a Gemini content part. Data URIs
+// become inlineData, with the media type checked against the payload's magic bytes;
+// gs:// URIs become fileData; http(s) URLs are fetched and inlined, since Gemini only
+// reads remote files it hosts. It reports false when the image cannot be converted.
+func OpenAIImagePart(imageURL string) ([]byte, bool) {
+	switch {
+	case strings.HasPrefix(imageURL, "data:"):
+		mediaType, data, ok := util.ParseDataURI(imageURL)
+		if !ok {
+			return nil, false
+		}
+		return InlineDataPart(mediaType, data), true
+	case strings.HasPrefix(imageURL, "gs://"):
+		part := []byte(`{"fileData":{"mimeType":"","fileUri":""}}`)
+		mimeType := guessMediaTypeFromPath(imageURL)
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		part, _ = sjson.SetBytes(part, "fileData.mimeType", mimeType)
+		part, _ = sjson.SetBytes(part, "fileData.fileUri", imageURL)
+		return part, true
+	case strings.HasPrefix(imageURL, "http://"), strings.HasPrefix(imageURL, "https://"):
+		mediaType, data, err := util.FetchMedia(context.Background(), imageURL)
+		if err != nil {
+			log.Warnf("gemini: skipping image %s: %v", imageURL, err)
+			return nil, false
+		}
+		return InlineDataPart(mediaType, data), true
+	}
+	return nil, false
+}
+
+// InlineDataPart builds a Gemini inlineData part from a media type and base64 payload.
+func InlineDataPart(mediaType, data string) []byte {
+	part := []byte(`{"inlineData":{"mime_type":"","data":""}}`)
+	part, _ = sjson.SetBytes(part, "inlineData.mime_type", mediaType)
+	part, _ = sjson.SetBytes(part, "inlineData.data", data)
+	return part
+}
+
+// OpenAIToolResultMedia splits an OpenAI tool message content into the text used as
+// the function response and Gemini parts for the images it carries, so base64 image
+// data is not sent to the model as text.
+func OpenAIToolResultMedia(content gjson.Result) (string, [][]byte) {
+	if !content.IsArray() {
+		return content.Raw, nil
+	}
+	var texts []string
+	var parts [][]byte
+	content.ForEach(func(_, item gjson.Result) bool {
+		switch item.Get("type").String() {
+		case "image_url":
+			if part, ok := OpenAIImagePart(item.Get("image_url.url").String()); ok {
+				parts = append(parts, part)
+			}
+		case "text":
+			texts = append(texts, item.Get("text").String())
+		default:
+			texts = append(texts, item.Raw)
+		}
+		return true
+	})
+	if len(parts) == 0 {
+		return content.Raw, nil
+	}
+	result, _ := sjson.Set(`{"v":""}`, "v", strings.Join(texts, "\n"))
+	return gjson.Get(result, "v").Raw, parts
+}
+
+// ClaudeMediaPart converts a Claude image or document block into a Gemini content
+// part. Base64 sources become inlineData, URL sources are fetched and inlined, and
+// plain-text documents become text parts. It reports false for other blocks.
+func ClaudeMediaPart(block gjson.Result) ([]byte, bool) {
+	if blockType := block.Get("type").String(); blockType != "image" && blockType != "document" {
+		return nil, false
+	}
+	source := block.Get("source")
+	switch source.Get("type").String() {
+	case "base64":
+		data := source.Get("data").String()
+		if data == "" {
+			return nil, false
+		}
+		return InlineDataPart(util.SniffBase64MediaType(data, source.Get("media_type").String()), data), true
+	case "url":
+		url := source.Get("url").String()
+		if strings.HasPrefix(url, "gs://") {
+			return OpenAIImagePart(url)
+		}
+		mediaType, data, err := util.FetchMedia(context.Background(), url)
+		if err != nil {
+			log.Warnf("gemini: skipping %s %s: %v", block.Get("type").String(), url, err)
+			return nil, false
+		}
+		return InlineDataPart(mediaType, data), true
+	case "text":
+		part, _ := sjson.SetBytes([]byte(`{"text":""}`), "text", source.Get("data").String())
+		return part, true
+	}
+	return nil, false
+}
+
+// ClaudeToolResultMedia splits a Claude tool_result content into the raw JSON used as
+// the function response and Gemini parts for the images and documents it carries.
+func ClaudeToolResultMedia(content gjson.Result) (string, [][]byte) {
+	if !content.IsArray() {
+		return content.Raw, nil
+	}
+	var texts []string
+	var parts [][]byte
+	content.ForEach(func(_, item gjson.Result) bool {
+		if part, ok := ClaudeMediaPart(item); ok {
+			parts = append(parts, part)
+		} else if item.Get("type").String() == "text" {
+			texts = append(texts, item.Get("text").String())
+		} else {
+			texts = append(texts, item.Raw)
+		}
+		return true
+	})
+	if len(parts) == 0 {
+		return content.Raw, nil
+	}
+	result, _ := sjson.Set(`{"v":""}`, "v", strings.Join(texts, "\n"))
+	return gjson.Get(result, "v").Raw, parts
+}
+
+func guessMediaTypeFromPath(path string) string {
+	lower := strings.ToLower(strings.SplitN(path, "?", 2)[0])
+	switch {
+	case strings.HasSuffix(lower, ".png"):
+		return "image/png"
+	case strings.HasSuffix(lower, ".jpg"), strings.HasSuffix(lower, ".jpeg"):
+		return "image/jpeg"
+	case strings.HasSuffix(lower, ".gif"):
+		return "image/gif"
+	case strings.HasSuffix(lower, ".webp"):
+		return "image/webp"
+	case strings.HasSuffix(lower, ".pdf"):
+		return "application/pdf"
+	}
+	return ""
+}
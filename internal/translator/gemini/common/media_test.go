@@ -0,0 +1,37 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIImagePart(t *testing.T) {
+	part, ok := OpenAIImagePart("data:image/jpeg;base64,iVBORw0KGgoAAAANSUhEUg==")
+	if !ok || gjson.GetBytes(part, "inlineData.mime_type").String() != "image/png" || gjson.GetBytes(part, "inlineData.data").String() != "iVBORw0KGgoAAAANSUhEUg==" {
+		t.Fatalf("unexpected data URI part %s", part)
+	}
+	part, ok = OpenAIImagePart("gs://bucket/scan.pdf")
+	if !ok || gjson.GetBytes(part, "fileData.mimeType").String() != "application/pdf" || gjson.GetBytes(part, "fileData.fileUri").String() != "gs://bucket/scan.pdf" {
+		t.Fatalf("unexpected gs:// part %s", part)
+	}
+	if _, ok = OpenAIImagePart("ftp://example.com/cat.png"); ok {
+		t.Fatalf("expected unsupported schemes to be skipped")
+	}
+}
+
+func TestToolResultMedia(t *testing.T) {
+	result, parts := OpenAIToolResultMedia(gjson.Parse(`[{"type":"text","text":"captured"},{"type":"image_url","image_url":{"url":"data:image/gif;base64,R0lGODlhAQABAAAAACw="}}]`))
+	if result != `"captured"` || len(parts) != 1 || gjson.GetBytes(parts[0], "inlineData.mime_type").String() != "image/gif" {
+		t.Fatalf("OpenAI tool result = %s, %d parts", result, len(parts))
+	}
+
+	result, parts = ClaudeToolResultMedia(gjson.Parse(`[{"type":"text","text":"page"},{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"JVBERi0xLjcK"}}]`))
+	if result != `"page"` || len(parts) != 1 || gjson.GetBytes(parts[0], "inlineData.mime_type").String() != "application/pdf" {
+		t.Fatalf("Claude tool result = %s, %d parts", result, len(parts))
+	}
+
+	if result, parts = ClaudeToolResultMedia(gjson.Parse(`"plain"`)); result != `"plain"` || parts != nil {
+		t.Fatalf("expected text-only results to pass through, got %s", result)
+	}
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// unsupportedResponseSchemaKeywords lists JSON Schema keywords Gemini's responseSchema
+// rejects; they are inlined or stripped, and their presence is logged.
+var unsupportedResponseSchemaKeywords = []string{"$ref", "additionalProperties", "patternProperties", "$defs", "definitions"}
+
+// ApplyOpenAIResponseFormat maps an OpenAI response_format onto the Gemini generation
+// config at path (e.g. "generationConfig" or "request.generationConfig"). json_schema
+// sets responseMimeType to application/json with the schema, cleaned for Gemini, as
+// responseSchema; json_object only sets the MIME type.
+func ApplyOpenAIResponseFormat(out, rawJSON []byte, path string) []byte {
+	responseFormat := gjson.GetBytes(rawJSON, "response_format")
+	switch responseFormat.Get("type").String() {
+	case "json_object":
+		out, _ = sjson.SetBytes(out, path+".responseMimeType", "application/json")
+	case "json_schema":
+		out, _ = sjson.SetBytes(out, path+".responseMimeType", "application/json")
+		schema := responseFormat.Get("json_schema.schema")
+		if !schema.IsObject() {
+			return out
+		}
+		out, _ = sjson.SetRawBytes(out, path+".responseSchema", []byte(CleanResponseSchema(responseFormat.Get("json_schema.name").String(), schema.Raw)))
+	}
+	return out
+}
+
+// CleanResponseSchema prepares a structured output schema for Gemini: local $ref
+// pointers are inlined and the keywords Gemini does not accept are stripped, with a
+// warning naming the schema and what was dropped.
+func CleanResponseSchema(name, schema string) string {
+	var unsupported []string
+	for _, keyword := range unsupportedResponseSchemaKeywords {
+		if strings.Contains(schema, `"`+keyword+`"`) {
+			unsupported = append(unsupported, keyword)
+		}
+	}
+	if len(unsupported) > 0 {
+		log.Warnf("gemini: response_format schema %q uses %s, which Gemini does not support; inlining or stripping", name, strings.Join(unsupported, ", "))
+	}
+	return util.CleanJSONSchemaForGemini(util.InlineJSONSchemaRefs(schema))
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyOpenAIResponseFormat_JSONSchema(t *testing.T) {
+	request := []byte(`{"response_format":{"type":"json_schema","json_schema":{"name":"catalog","strict":true,"schema":{
+		"type":"object",
+		"additionalProperties":false,
+		"required":["products"],
+		"properties":{
+			"products":{"type":"array","items":{"$ref":"#/$defs/Product"}},
+			"meta":{"type":"object","additionalProperties":{"type":"string"}}
+		},
+		"$defs":{"Product":{"type":"object","additionalProperties":false,"properties":{"name":{"type":"string"},"sizes":{"type":"array","items":{"type":"integer"}}}}}
+	}}}}`)
+
+	for _, path := range []string{"generationConfig", "request.generationConfig"} {
+		out := ApplyOpenAIResponseFormat([]byte(`{}`), request, path)
+		config := gjson.GetBytes(out, path)
+		if config.Get("responseMimeType").String() != "application/json" {
+			t.Fatalf("%s: expected responseMimeType application/json, got %s", path, config.Raw)
+		}
+		schema := config.Get("responseSchema")
+		if item := schema.Get("properties.products.items"); item.Get("properties.name.type").String() != "string" || item.Get("properties.sizes.items.type").String() != "integer" {
+			t.Fatalf("%s: expected the nested array item schema inlined, got %s", path, schema.Raw)
+		}
+		for _, keyword := range []string{"additionalProperties", "$ref", "$defs"} {
+			if strings.Contains(schema.Raw, `"`+keyword+`"`) {
+				t.Fatalf("%s: expected %s to be stripped, got %s", path, keyword, schema.Raw)
+			}
+		}
+		if schema.Get("required.0").String() != "products" {
+			t.Fatalf("%s: expected required to be kept, got %s", path, schema.Raw)
+		}
+	}
+}
+
+func TestApplyOpenAIResponseFormat_JSONObjectAndText(t *testing.T) {
+	out := ApplyOpenAIResponseFormat([]byte(`{}`), []byte(`{"response_format":{"type":"json_object"}}`), "generationConfig")
+	if gjson.GetBytes(out, "generationConfig.responseMimeType").String() != "application/json" || gjson.GetBytes(out, "generationConfig.responseSchema").Exists() {
+		t.Fatalf("unexpected json_object mapping %s", out)
+	}
+	for _, request := range []string{`{"response_format":{"type":"text"}}`, `{}`} {
+		if out := ApplyOpenAIResponseFormat([]byte(`{}`), []byte(request), "generationConfig"); string(out) != `{}` {
+			t.Fatalf("expected %s to leave the request untouched, got %s", request, out)
+		}
+	}
+}
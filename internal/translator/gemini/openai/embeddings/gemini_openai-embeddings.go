@@ -0,0 +1,129 @@
+// Package embeddings converts OpenAI embeddings requests to Gemini batchEmbedContents
+// requests and the resulting Gemini embeddings back to the OpenAI list format.
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// MaxBatchSize is the number of inputs Gemini accepts in one batchEmbedContents call.
+const MaxBatchSize = 100
+
+// ParseInputs returns the texts to embed from an OpenAI embeddings request. input may
+// be a string or an array of strings; token arrays are rejected since Gemini only
+// embeds text.
+func ParseInputs(rawJSON []byte) ([]string, error) {
+	input := gjson.GetBytes(rawJSON, "input")
+	switch {
+	case input.Type == gjson.String:
+		if input.Str == "" {
+			return nil, errors.New("input must not be empty")
+		}
+		return []string{input.Str}, nil
+	case input.IsArray():
+		var inputs []string
+		var err error
+		input.ForEach(func(_, item gjson.Result) bool {
+			if item.Type != gjson.String {
+				err = errors.New("input must be a string or an array of strings; token arrays are not supported")
+				return false
+			}
+			if item.Str == "" {
+				err = errors.New("input must not contain empty strings")
+				return false
+			}
+			inputs = append(inputs, item.Str)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(inputs) == 0 {
+			return nil, errors.New("input must not be empty")
+		}
+		return inputs, nil
+	}
+	return nil, errors.New("input must be a string or an array of strings")
+}
+
+// Batches splits inputs into slices of at most MaxBatchSize.
+func Batches(inputs []string) [][]string {
+	var batches [][]string
+	for len(inputs) > MaxBatchSize {
+		batches = append(batches, inputs[:MaxBatchSize])
+		inputs = inputs[MaxBatchSize:]
+	}
+	if len(inputs) > 0 {
+		batches = append(batches, inputs)
+	}
+	return batches
+}
+
+// ConvertOpenAIEmbeddingsRequestToGemini builds the batchEmbedContents request for one
+// batch of inputs. The OpenAI dimensions parameter maps to outputDimensionality.
+func ConvertOpenAIEmbeddingsRequestToGemini(modelName string, inputs []string, rawJSON []byte) []byte {
+	out := []byte(`{"requests":[]}`)
+	dimensions := gjson.GetBytes(rawJSON, "dimensions")
+	for _, text := range inputs {
+		request := `{"model":"","content":{"parts":[{"text":""}]}}`
+		request, _ = sjson.Set(request, "model", "models/"+modelName)
+		request, _ = sjson.Set(request, "content.parts.0.text", text)
+		if dimensions.Exists() && dimensions.Int() > 0 {
+			request, _ = sjson.Set(request, "outputDimensionality", dimensions.Int())
+		}
+		out, _ = sjson.SetRawBytes(out, "requests.-1", []byte(request))
+	}
+	return out
+}
+
+// GeminiEmbeddingValues returns the raw values arrays of a batchEmbedContents response,
+// in request order, or of an embedContent response holding a single embedding.
+func GeminiEmbeddingValues(rawJSON []byte) []string {
+	root := gjson.ParseBytes(rawJSON)
+	if single := root.Get("embedding.values"); single.IsArray() {
+		return []string{single.Raw}
+	}
+	var values []string
+	root.Get("embeddings").ForEach(func(_, embedding gjson.Result) bool {
+		values = append(values, embedding.Get("values").Raw)
+		return true
+	})
+	return values
+}
+
+// BuildOpenAIEmbeddingsResponse assembles the OpenAI embeddings list from the values
+// arrays of every batch, indexed in input order. With encoding_format "base64" each
+// vector is encoded as little-endian float32, as the OpenAI API does.
+func BuildOpenAIEmbeddingsResponse(modelName string, values []string, promptTokens int64, rawJSON []byte) []byte {
+	useBase64 := gjson.GetBytes(rawJSON, "encoding_format").String() == "base64"
+	out := []byte(`{"object":"list","data":[],"model":"","usage":{"prompt_tokens":0,"total_tokens":0}}`)
+	out, _ = sjson.SetBytes(out, "model", modelName)
+	for i, vector := range values {
+		item := `{"object":"embedding","index":0,"embedding":[]}`
+		item, _ = sjson.Set(item, "index", i)
+		if useBase64 {
+			item, _ = sjson.Set(item, "embedding", encodeFloat32Base64(gjson.Parse(vector)))
+		} else if gjson.Parse(vector).IsArray() {
+			item, _ = sjson.SetRaw(item, "embedding", vector)
+		}
+		out, _ = sjson.SetRawBytes(out, "data.-1", []byte(item))
+	}
+	out, _ = sjson.SetBytes(out, "usage.prompt_tokens", promptTokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", promptTokens)
+	return out
+}
+
+func encodeFloat32Base64(vector gjson.Result) string {
+	values := vector.Array()
+	buf := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(value.Float())))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
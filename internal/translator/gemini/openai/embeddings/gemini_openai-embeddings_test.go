@@ -0,0 +1,85 @@
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestParseInputs(t *testing.T) {
+	inputs, err := ParseInputs([]byte(`{"input":"hello"}`))
+	if err != nil || len(inputs) != 1 || inputs[0] != "hello" {
+		t.Fatalf("string input = %v, %v", inputs, err)
+	}
+	inputs, err = ParseInputs([]byte(`{"input":["a","b"]}`))
+	if err != nil || len(inputs) != 2 || inputs[1] != "b" {
+		t.Fatalf("array input = %v, %v", inputs, err)
+	}
+	for _, body := range []string{`{}`, `{"input":""}`, `{"input":[]}`, `{"input":[1,2]}`, `{"input":["a",""]}`} {
+		if _, err = ParseInputs([]byte(body)); err == nil {
+			t.Fatalf("expected an error for %s", body)
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	batches := Batches(make([]string, 2*MaxBatchSize+1))
+	if len(batches) != 3 || len(batches[0]) != MaxBatchSize || len(batches[2]) != 1 {
+		t.Fatalf("got %d batches", len(batches))
+	}
+	if Batches(nil) != nil {
+		t.Fatalf("expected no batches for no inputs")
+	}
+}
+
+func TestConvertOpenAIEmbeddingsRequestToGemini(t *testing.T) {
+	out := ConvertOpenAIEmbeddingsRequestToGemini("gemini-embedding-001", []string{"a", "b"}, []byte(`{"dimensions":256}`))
+	requests := gjson.GetBytes(out, "requests")
+	if requests.Get("#").Int() != 2 {
+		t.Fatalf("requests = %s", requests.Raw)
+	}
+	if requests.Get("1.model").String() != "models/gemini-embedding-001" || requests.Get("1.content.parts.0.text").String() != "b" {
+		t.Fatalf("request = %s", requests.Get("1").Raw)
+	}
+	if requests.Get("0.outputDimensionality").Int() != 256 {
+		t.Fatalf("expected outputDimensionality 256, got %s", requests.Get("0").Raw)
+	}
+
+	out = ConvertOpenAIEmbeddingsRequestToGemini("gemini-embedding-001", []string{"a"}, []byte(`{}`))
+	if gjson.GetBytes(out, "requests.0.outputDimensionality").Exists() {
+		t.Fatalf("unexpected outputDimensionality: %s", out)
+	}
+}
+
+func TestBuildOpenAIEmbeddingsResponse(t *testing.T) {
+	values := GeminiEmbeddingValues([]byte(`{"embeddings":[{"values":[0.25,-1]},{"values":[2]}]}`))
+	out := BuildOpenAIEmbeddingsResponse("gemini-embedding-001", values, 7, []byte(`{}`))
+	if gjson.GetBytes(out, "object").String() != "list" || gjson.GetBytes(out, "model").String() != "gemini-embedding-001" {
+		t.Fatalf("response = %s", out)
+	}
+	if gjson.GetBytes(out, "data.1.index").Int() != 1 || gjson.GetBytes(out, "data.0.embedding").Raw != "[0.25,-1]" {
+		t.Fatalf("data = %s", gjson.GetBytes(out, "data").Raw)
+	}
+	if gjson.GetBytes(out, "usage.prompt_tokens").Int() != 7 || gjson.GetBytes(out, "usage.total_tokens").Int() != 7 {
+		t.Fatalf("usage = %s", gjson.GetBytes(out, "usage").Raw)
+	}
+
+	out = BuildOpenAIEmbeddingsResponse("gemini-embedding-001", values, 7, []byte(`{"encoding_format":"base64"}`))
+	decoded, err := base64.StdEncoding.DecodeString(gjson.GetBytes(out, "data.0.embedding").String())
+	if err != nil || len(decoded) != 8 {
+		t.Fatalf("base64 embedding = %v, %v", decoded, err)
+	}
+	if got := math.Float32frombits(binary.LittleEndian.Uint32(decoded[4:])); got != -1 {
+		t.Fatalf("second component = %v, want -1", got)
+	}
+}
+
+func TestGeminiEmbeddingValues_Single(t *testing.T) {
+	values := GeminiEmbeddingValues([]byte(`{"embedding":{"values":[1,2]}}`))
+	if len(values) != 1 || values[0] != "[1,2]" {
+		t.Fatalf("values = %v", values)
+	}
+}
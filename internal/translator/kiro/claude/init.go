@@ -13,8 +13,9 @@ func init() {
 		Kiro,
 		ConvertClaudeRequestToKiro,
 		interfaces.TranslateResponse{
-			Stream:    ConvertKiroStreamToClaude,
-			NonStream: ConvertKiroNonStreamToClaude,
+			Stream:     ConvertKiroStreamToClaude,
+			NonStream:  ConvertKiroNonStreamToClaude,
+			TokenCount: ClaudeTokenCount,
 		},
 	)
 }
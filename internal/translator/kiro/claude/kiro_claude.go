@@ -5,6 +5,7 @@ package claude
 
 import (
 	"context"
+	"fmt"
 )
 
 // ConvertKiroStreamToClaude converts Kiro streaming response to Claude format.
@@ -19,3 +20,8 @@ func ConvertKiroStreamToClaude(ctx context.Context, model string, originalReques
 func ConvertKiroNonStreamToClaude(ctx context.Context, model string, originalRequest, request, rawResponse []byte, param *any) string {
 	return string(rawResponse)
 }
+
+// ClaudeTokenCount formats a locally counted token total as a Claude count_tokens response.
+func ClaudeTokenCount(ctx context.Context, count int64) string {
+	return fmt.Sprintf(`{"input_tokens":%d}`, count)
+}
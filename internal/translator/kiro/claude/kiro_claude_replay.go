@@ -0,0 +1,88 @@
+package claude
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// EventData returns the JSON payload of a Claude SSE event emitted by the Kiro
+// executor, which may carry an "event:" line before its "data:" line or be bare JSON.
+func EventData(raw []byte) []byte {
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if line = bytes.TrimSpace(line); bytes.HasPrefix(line, []byte("data:")) {
+			return bytes.TrimSpace(line[len("data:"):])
+		}
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		return trimmed
+	}
+	return nil
+}
+
+// MessageToSSE replays a complete Claude message, as built by BuildClaudeResponse, as
+// the "data:" lines of the equivalent Claude stream, so aggregators written for Claude
+// SSE can convert non-streaming Kiro responses. Usage is copied, not re-distributed.
+func MessageToSSE(message []byte) []byte {
+	root := gjson.ParseBytes(message)
+	var out bytes.Buffer
+	writeEvent := func(event string) {
+		out.WriteString("data: ")
+		out.WriteString(event)
+		out.WriteString("\n")
+	}
+
+	start := `{"type":"message_start","message":{"id":"","type":"message","role":"assistant","model":"","content":[]}}`
+	start, _ = sjson.Set(start, "message.id", root.Get("id").String())
+	start, _ = sjson.Set(start, "message.model", root.Get("model").String())
+	if usage := root.Get("usage"); usage.IsObject() {
+		start, _ = sjson.SetRaw(start, "message.usage", usage.Raw)
+	}
+	writeEvent(start)
+
+	index := 0
+	root.Get("content").ForEach(func(_, block gjson.Result) bool {
+		var blockStart, delta string
+		switch block.Get("type").String() {
+		case "text":
+			blockStart = `{"type":"text","text":""}`
+			delta, _ = sjson.Set(`{"type":"text_delta","text":""}`, "text", block.Get("text").String())
+		case "thinking":
+			blockStart = `{"type":"thinking","thinking":""}`
+			delta, _ = sjson.Set(`{"type":"thinking_delta","thinking":""}`, "thinking", block.Get("thinking").String())
+		case "tool_use":
+			blockStart = `{"type":"tool_use","id":"","name":"","input":{}}`
+			blockStart, _ = sjson.Set(blockStart, "id", block.Get("id").String())
+			blockStart, _ = sjson.Set(blockStart, "name", block.Get("name").String())
+			input := block.Get("input").Raw
+			if input == "" {
+				input = "{}"
+			}
+			delta, _ = sjson.Set(`{"type":"input_json_delta","partial_json":""}`, "partial_json", input)
+		default:
+			return true
+		}
+		event, _ := sjson.SetRaw(`{"type":"content_block_start"}`, "content_block", blockStart)
+		event, _ = sjson.Set(event, "index", index)
+		writeEvent(event)
+		event, _ = sjson.SetRaw(`{"type":"content_block_delta"}`, "delta", delta)
+		event, _ = sjson.Set(event, "index", index)
+		writeEvent(event)
+		event, _ = sjson.Set(`{"type":"content_block_stop"}`, "index", index)
+		writeEvent(event)
+		index++
+		return true
+	})
+
+	messageDelta := `{"type":"message_delta","delta":{"stop_reason":null,"stop_sequence":null}}`
+	if stopReason := root.Get("stop_reason").String(); stopReason != "" {
+		messageDelta, _ = sjson.Set(messageDelta, "delta.stop_reason", stopReason)
+	}
+	if usage := root.Get("usage"); usage.IsObject() {
+		messageDelta, _ = sjson.SetRaw(messageDelta, "usage", usage.Raw)
+	}
+	writeEvent(messageDelta)
+	writeEvent(`{"type":"message_stop"}`)
+	return out.Bytes()
+}
@@ -15,8 +15,27 @@ import (
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
+// kiroMaxOutputTokens is the Kiro output limit, used for max_tokens=-1 and as the
+// default of requests translated to Claude without an output limit.
+const kiroMaxOutputTokens = 32000
+
+// WithDefaultMaxTokens sets max_tokens on a Claude request translated for Kiro when
+// the client gave no output limit. The Claude translators leave it to the Claude
+// executor, which the Kiro path does not go through.
+func WithDefaultMaxTokens(claudeBody []byte) []byte {
+	if gjson.GetBytes(claudeBody, "max_tokens").Exists() {
+		return claudeBody
+	}
+	out, err := sjson.SetBytes(claudeBody, "max_tokens", kiroMaxOutputTokens)
+	if err != nil {
+		return claudeBody
+	}
+	return out
+}
+
 // remoteWebSearchDescription is a minimal fallback for when dynamic fetch from MCP tools/list hasn't completed yet.
 const remoteWebSearchDescription = "WebSearch looks up information outside the model's training data. Supports multiple queries to gather comprehensive information."
 
@@ -123,6 +142,8 @@ type KiroToolUse struct {
 	Input          map[string]interface{} `json:"input"`
 	IsTruncated    bool                   `json:"-"` // Internal flag, not serialized
 	TruncationInfo *TruncationInfo        `json:"-"` // Truncation details, not serialized
+	InvalidInput   bool                   `json:"-"` // Input never became valid JSON; see RawInput
+	RawInput       string                 `json:"-"` // Assembled input text when InvalidInput is set
 }
 
 // ConvertClaudeRequestToKiro converts a Claude API request to Kiro format.
@@ -146,7 +167,6 @@ func ConvertClaudeRequestToKiro(modelName string, inputRawJSON []byte, stream bo
 func BuildKiroPayload(claudeBody []byte, modelID, profileArn, origin string, isAgentic, isChatOnly bool, headers http.Header, metadata map[string]any) ([]byte, bool) {
 	// Extract max_tokens for potential use in inferenceConfig
 	// Handle -1 as "use maximum" (Kiro max output is ~32000 tokens)
-	const kiroMaxOutputTokens = 32000
 	var maxTokens int64
 	if mt := gjson.GetBytes(claudeBody, "max_tokens"); mt.Exists() {
 		maxTokens = mt.Int()
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 
@@ -120,15 +121,30 @@ func BuildClaudeResponse(content string, toolUses []KiroToolUse, model string, u
 		"model":       model,
 		"content":     contentBlocks,
 		"stop_reason": stopReason,
-		"usage": map[string]interface{}{
-			"input_tokens":  usageInfo.InputTokens,
-			"output_tokens": usageInfo.OutputTokens,
-		},
+		"usage":       buildFinalClaudeUsage(model, usageInfo.InputTokens, usageInfo.OutputTokens),
 	}
 	result, _ := json.Marshal(response)
 	return result
 }
 
+// buildClaudeUsage builds a Claude usage object. Kiro has no prompt cache reporting,
+// so the input tokens are split into simulated cache buckets; the cache fields are
+// only present when the total reaches the distribution threshold.
+func buildClaudeUsage(model string, inputTokens, outputTokens int64) internalusage.UsageBlock {
+	return internalusage.UsageBlock{
+		CacheTokenDistribution: internalusage.DistributeCacheTokensForProvider("kiro", model, inputTokens),
+		OutputTokens:           outputTokens,
+	}
+}
+
+// buildFinalClaudeUsage builds the usage object that closes a response and records it
+// in the token metrics, so each response is counted exactly once.
+func buildFinalClaudeUsage(model string, inputTokens, outputTokens int64) internalusage.UsageBlock {
+	block := buildClaudeUsage(model, inputTokens, outputTokens)
+	internalusage.RecordUsage(model, block)
+	return block
+}
+
 // ExtractThinkingFromContent parses content to extract thinking blocks and text.
 // Returns a list of content blocks in the order they appear in the content.
 // Handles interleaved thinking and text blocks correctly.
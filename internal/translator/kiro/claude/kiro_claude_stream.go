@@ -22,7 +22,7 @@ func BuildClaudeMessageStartEvent(model string, inputTokens int64) []byte {
 			"model":         model,
 			"stop_reason":   nil,
 			"stop_sequence": nil,
-			"usage":         map[string]interface{}{"input_tokens": inputTokens, "output_tokens": 0},
+			"usage":         buildClaudeUsage(model, inputTokens, 0),
 		},
 	}
 	result, _ := json.Marshal(event)
@@ -109,18 +109,16 @@ func BuildClaudeThinkingBlockStopEvent(index int) []byte {
 	return []byte("event: content_block_stop\ndata: " + string(result))
 }
 
-// BuildClaudeMessageDeltaEvent creates the message_delta event with stop_reason and usage
-func BuildClaudeMessageDeltaEvent(stopReason string, usageInfo usage.Detail) []byte {
+// BuildClaudeMessageDeltaEvent creates the message_delta event with stop_reason and usage.
+// The input tokens are distributed the same way as in message_start so both events agree.
+func BuildClaudeMessageDeltaEvent(model, stopReason string, usageInfo usage.Detail) []byte {
 	deltaEvent := map[string]interface{}{
 		"type": "message_delta",
 		"delta": map[string]interface{}{
 			"stop_reason":   stopReason,
 			"stop_sequence": nil,
 		},
-		"usage": map[string]interface{}{
-			"input_tokens":  usageInfo.InputTokens,
-			"output_tokens": usageInfo.OutputTokens,
-		},
+		"usage": buildFinalClaudeUsage(model, usageInfo.InputTokens, usageInfo.OutputTokens),
 	}
 	deltaResult, _ := json.Marshal(deltaEvent)
 	return []byte("event: message_delta\ndata: " + string(deltaResult))
@@ -295,7 +293,7 @@ func BuildFallbackTextEvents(contentBlockIndex int, query string, results *WebSe
 	events = append(events, BuildClaudeContentBlockStopEvent(contentBlockIndex))
 
 	// message_delta with end_turn
-	events = append(events, BuildClaudeMessageDeltaEvent("end_turn", usage.Detail{
+	events = append(events, BuildClaudeMessageDeltaEvent("", "end_turn", usage.Detail{
 		OutputTokens: int64(outputTokens),
 	}))
 
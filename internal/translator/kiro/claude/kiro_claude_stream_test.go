@@ -0,0 +1,78 @@
+package claude
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+// parseSSEEvent splits a single "event: ...\ndata: ..." frame into its name and JSON payload.
+func parseSSEEvent(t *testing.T, frame []byte) (string, gjson.Result) {
+	t.Helper()
+	var name, data string
+	for _, line := range strings.Split(string(frame), "\n") {
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if !gjson.Valid(data) {
+		t.Fatalf("invalid SSE data payload: %q", frame)
+	}
+	return name, gjson.Parse(data)
+}
+
+func TestStreamEventsReportCacheDistribution(t *testing.T) {
+	name, start := parseSSEEvent(t, BuildClaudeMessageStartEvent("claude-sonnet-4.5", 1000))
+	if name != "message_start" {
+		t.Fatalf("event = %q, want message_start", name)
+	}
+	startUsage := start.Get("message.usage")
+	if got := startUsage.Get("input_tokens").Int(); got != 35 {
+		t.Fatalf("message_start input_tokens = %d, want 35", got)
+	}
+	if got := startUsage.Get("cache_creation_input_tokens").Int(); got != 71 {
+		t.Fatalf("message_start cache_creation_input_tokens = %d, want 71", got)
+	}
+	if got := startUsage.Get("cache_read_input_tokens").Int(); got != 894 {
+		t.Fatalf("message_start cache_read_input_tokens = %d, want 894", got)
+	}
+	if got := startUsage.Get("output_tokens").Int(); got != 0 {
+		t.Fatalf("message_start output_tokens = %d, want 0", got)
+	}
+
+	name, delta := parseSSEEvent(t, BuildClaudeMessageDeltaEvent("claude-sonnet-4.5", "end_turn", usage.Detail{InputTokens: 1000, OutputTokens: 42}))
+	if name != "message_delta" {
+		t.Fatalf("event = %q, want message_delta", name)
+	}
+	deltaUsage := delta.Get("usage")
+	for _, field := range []string{"input_tokens", "cache_creation_input_tokens", "cache_read_input_tokens"} {
+		if deltaUsage.Get(field).Int() != startUsage.Get(field).Int() {
+			t.Fatalf("message_delta %s = %d, message_start reported %d", field, deltaUsage.Get(field).Int(), startUsage.Get(field).Int())
+		}
+	}
+	total := deltaUsage.Get("input_tokens").Int() + deltaUsage.Get("cache_creation_input_tokens").Int() + deltaUsage.Get("cache_read_input_tokens").Int()
+	if total != 1000 {
+		t.Fatalf("distributed input sums to %d, want 1000", total)
+	}
+	if got := deltaUsage.Get("output_tokens").Int(); got != 42 {
+		t.Fatalf("message_delta output_tokens = %d, want 42", got)
+	}
+}
+
+func TestStreamEventsBelowThresholdKeepInputTokens(t *testing.T) {
+	_, start := parseSSEEvent(t, BuildClaudeMessageStartEvent("claude-sonnet-4.5", 42))
+	_, delta := parseSSEEvent(t, BuildClaudeMessageDeltaEvent("claude-sonnet-4.5", "end_turn", usage.Detail{InputTokens: 42, OutputTokens: 7}))
+	for name, u := range map[string]gjson.Result{"message_start": start.Get("message.usage"), "message_delta": delta.Get("usage")} {
+		if got := u.Get("input_tokens").Int(); got != 42 {
+			t.Fatalf("%s input_tokens = %d, want 42", name, got)
+		}
+		if u.Get("cache_creation_input_tokens").Exists() || u.Get("cache_read_input_tokens").Exists() {
+			t.Fatalf("%s should not report cache fields below threshold: %s", name, u.Raw)
+		}
+	}
+}
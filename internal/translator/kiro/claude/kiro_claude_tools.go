@@ -4,6 +4,7 @@ package claude
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -377,12 +378,35 @@ func escapeNewlinesInStrings(raw string) string {
 	return result.String()
 }
 
-// ProcessToolUseEvent handles a toolUseEvent from the Kiro stream.
-// It accumulates input fragments and emits tool_use blocks when complete.
-// Returns events to emit and updated state.
-func ProcessToolUseEvent(event map[string]interface{}, currentToolUse *ToolUseState, processedIDs map[string]bool) ([]KiroToolUse, *ToolUseState) {
-	var toolUses []KiroToolUse
+// ToolUseAssembler assembles the input of tool uses streamed as toolUseEvent
+// fragments. Kiro may split one call's JSON arguments over many events and interleave
+// the events of several calls, so input is buffered per toolUseId and a tool use is
+// only completed by its own stop event, or by Flush at the end of the stream.
+type ToolUseAssembler struct {
+	pending      map[string]*ToolUseState
+	order        []string // pending IDs in start order
+	lastID       string   // ID of the latest event, for fragments that carry none
+	processedIDs map[string]bool
+}
 
+// NewToolUseAssembler returns an assembler that skips, and records, the tool use IDs in
+// processedIDs. processedIDs may be nil.
+func NewToolUseAssembler(processedIDs map[string]bool) *ToolUseAssembler {
+	if processedIDs == nil {
+		processedIDs = make(map[string]bool)
+	}
+	return &ToolUseAssembler{pending: make(map[string]*ToolUseState), processedIDs: processedIDs}
+}
+
+// Pending reports whether any tool use has started but not completed.
+func (a *ToolUseAssembler) Pending() bool {
+	return len(a.order) > 0
+}
+
+// ProcessToolUseEvent handles a toolUseEvent from the Kiro stream.
+// It accumulates input fragments into the tool use they belong to and returns the
+// tool uses this event completed.
+func (a *ToolUseAssembler) ProcessToolUseEvent(event map[string]interface{}) []KiroToolUse {
 	// Extract from nested toolUseEvent or direct format
 	tu := event
 	if nested, ok := event["toolUseEvent"].(map[string]interface{}); ok {
@@ -396,122 +420,127 @@ func ProcessToolUseEvent(event map[string]interface{}, currentToolUse *ToolUseSt
 		isStop = stop
 	}
 
-	// Get input - can be string (fragment) or object (complete)
-	var inputFragment string
-	var inputMap map[string]interface{}
-
-	if inputRaw, ok := tu["input"]; ok {
-		switch v := inputRaw.(type) {
-		case string:
-			inputFragment = v
-		case map[string]interface{}:
-			inputMap = v
-		}
+	if toolUseID == "" {
+		toolUseID = a.lastID
 	}
-
-	// New tool use starting
-	if toolUseID != "" && toolName != "" {
-		if currentToolUse != nil && currentToolUse.ToolUseID != toolUseID {
-			log.Warnf("kiro: interleaved tool use detected - new ID %s arrived while %s in progress, completing previous",
-				toolUseID, currentToolUse.ToolUseID)
-			if !processedIDs[currentToolUse.ToolUseID] {
-				incomplete := KiroToolUse{
-					ToolUseID: currentToolUse.ToolUseID,
-					Name:      currentToolUse.Name,
-				}
-				if currentToolUse.InputBuffer.Len() > 0 {
-					raw := currentToolUse.InputBuffer.String()
-					repaired := RepairJSON(raw)
-
-					var input map[string]interface{}
-					if err := json.Unmarshal([]byte(repaired), &input); err != nil {
-						log.Warnf("kiro: failed to parse interleaved tool input: %v, raw: %s", err, raw)
-						input = make(map[string]interface{})
-					}
-					incomplete.Input = input
-				}
-				toolUses = append(toolUses, incomplete)
-				processedIDs[currentToolUse.ToolUseID] = true
-			}
-			currentToolUse = nil
+	if toolUseID == "" || a.processedIDs[toolUseID] {
+		if toolUseID != "" {
+			log.Debugf("kiro: skipping toolUseEvent for completed tool use: %s", toolUseID)
 		}
+		return nil
+	}
 
-		if currentToolUse == nil {
-			if processedIDs != nil && processedIDs[toolUseID] {
-				log.Debugf("kiro: skipping duplicate toolUseEvent: %s", toolUseID)
-				return nil, nil
-			}
-
-			currentToolUse = &ToolUseState{
-				ToolUseID: toolUseID,
-				Name:      toolName,
-			}
-			log.Infof("kiro: starting new tool use: %s (ID: %s)", toolName, toolUseID)
+	state, ok := a.pending[toolUseID]
+	if !ok {
+		if len(a.order) > 0 {
+			log.Debugf("kiro: tool use %s started while %d other tool use(s) in progress", toolUseID, len(a.order))
 		}
+		state = &ToolUseState{ToolUseID: toolUseID}
+		a.pending[toolUseID] = state
+		a.order = append(a.order, toolUseID)
+		log.Infof("kiro: starting new tool use: %s (ID: %s)", toolName, toolUseID)
+	}
+	if state.Name == "" {
+		state.Name = toolName
+	}
+	a.lastID = toolUseID
+
+	// Input can be a string (fragment) or an object (complete)
+	switch v := tu["input"].(type) {
+	case string:
+		state.InputBuffer.WriteString(v)
+		log.Debugf("kiro: accumulated input fragment for %s, total length: %d", toolUseID, state.InputBuffer.Len())
+	case map[string]interface{}:
+		inputBytes, _ := json.Marshal(v)
+		state.InputBuffer.Reset()
+		state.InputBuffer.Write(inputBytes)
 	}
 
-	// Accumulate input fragments
-	if currentToolUse != nil && inputFragment != "" {
-		currentToolUse.InputBuffer.WriteString(inputFragment)
-		log.Debugf("kiro: accumulated input fragment, total length: %d", currentToolUse.InputBuffer.Len())
+	if !isStop {
+		return nil
 	}
+	return []KiroToolUse{a.complete(toolUseID)}
+}
 
-	// If complete input object provided directly
-	if currentToolUse != nil && inputMap != nil {
-		inputBytes, _ := json.Marshal(inputMap)
-		currentToolUse.InputBuffer.Reset()
-		currentToolUse.InputBuffer.Write(inputBytes)
+// Flush completes every pending tool use in start order. It is called at the end of
+// the stream, when no more fragments can arrive.
+func (a *ToolUseAssembler) Flush() []KiroToolUse {
+	if len(a.order) == 0 {
+		return nil
+	}
+	toolUses := make([]KiroToolUse, 0, len(a.order))
+	for len(a.order) > 0 {
+		id := a.order[0]
+		log.Warnf("kiro: flushing incomplete tool use at EOF: %s (ID: %s)", a.pending[id].Name, id)
+		toolUses = append(toolUses, a.complete(id))
 	}
+	return toolUses
+}
 
-	// Tool use complete
-	if isStop && currentToolUse != nil {
-		fullInput := currentToolUse.InputBuffer.String()
-
-		// Repair and parse the accumulated JSON
-		repairedJSON := RepairJSON(fullInput)
-		var finalInput map[string]interface{}
-		if err := json.Unmarshal([]byte(repairedJSON), &finalInput); err != nil {
-			log.Warnf("kiro: failed to parse accumulated tool input: %v, raw: %s", err, fullInput)
-			finalInput = make(map[string]interface{})
-		}
-
-		// Detect truncation for all tools
-		truncInfo := DetectTruncation(currentToolUse.Name, currentToolUse.ToolUseID, fullInput, finalInput)
-		if truncInfo.IsTruncated {
-			log.Warnf("kiro: TRUNCATION DETECTED for tool %s (ID: %s): type=%s, raw_size=%d bytes",
-				currentToolUse.Name, currentToolUse.ToolUseID, truncInfo.TruncationType, len(fullInput))
-			log.Warnf("kiro: truncation details: %s", truncInfo.ErrorMessage)
-			if len(truncInfo.ParsedFields) > 0 {
-				log.Infof("kiro: partial fields received: %v", truncInfo.ParsedFields)
-			}
-			// Store truncation info in the state for upstream handling
-			currentToolUse.TruncationInfo = &truncInfo
-		} else {
-			log.Infof("kiro: tool use %s input length: %d bytes (no truncation)", currentToolUse.Name, len(fullInput))
+// complete removes the tool use from the pending set and parses its assembled input.
+// Input that is not valid JSON even after RepairJSON is either reported as truncated,
+// when it looks cut off, or marked InvalidInput with the raw text kept in RawInput.
+func (a *ToolUseAssembler) complete(toolUseID string) KiroToolUse {
+	state := a.pending[toolUseID]
+	delete(a.pending, toolUseID)
+	for i, id := range a.order {
+		if id == toolUseID {
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			break
 		}
+	}
+	if a.lastID == toolUseID {
+		a.lastID = ""
+	}
+	a.processedIDs[toolUseID] = true
+	state.IsComplete = true
 
-		// Create the tool use with truncation info if applicable
-		toolUse := KiroToolUse{
-			ToolUseID:      currentToolUse.ToolUseID,
-			Name:           currentToolUse.Name,
-			Input:          finalInput,
-			IsTruncated:    truncInfo.IsTruncated,
-			TruncationInfo: nil, // Will be set below if truncated
-		}
-		if truncInfo.IsTruncated {
-			toolUse.TruncationInfo = &truncInfo
-		}
-		toolUses = append(toolUses, toolUse)
+	fullInput := state.InputBuffer.String()
+	toolUse := KiroToolUse{ToolUseID: state.ToolUseID, Name: state.Name}
 
-		if processedIDs != nil {
-			processedIDs[currentToolUse.ToolUseID] = true
-		}
+	// Repair and parse the accumulated JSON
+	var finalInput map[string]interface{}
+	if err := json.Unmarshal([]byte(RepairJSON(fullInput)), &finalInput); err != nil || finalInput == nil {
+		log.Warnf("kiro: failed to parse accumulated tool input: %v, raw: %s", err, fullInput)
+		finalInput = nil
+	}
 
-		log.Infof("kiro: completed tool use: %s (ID: %s, truncated: %v)", currentToolUse.Name, currentToolUse.ToolUseID, truncInfo.IsTruncated)
-		return toolUses, nil
+	// Detect truncation for all tools
+	truncInfo := DetectTruncation(state.Name, state.ToolUseID, fullInput, finalInput)
+	switch {
+	case truncInfo.IsTruncated:
+		log.Warnf("kiro: TRUNCATION DETECTED for tool %s (ID: %s): type=%s, raw_size=%d bytes",
+			state.Name, state.ToolUseID, truncInfo.TruncationType, len(fullInput))
+		log.Warnf("kiro: truncation details: %s", truncInfo.ErrorMessage)
+		if len(truncInfo.ParsedFields) > 0 {
+			log.Infof("kiro: partial fields received: %v", truncInfo.ParsedFields)
+		}
+		state.TruncationInfo = &truncInfo
+		toolUse.IsTruncated = true
+		toolUse.TruncationInfo = &truncInfo
+		toolUse.Input = finalInput
+		if toolUse.Input == nil {
+			toolUse.Input = make(map[string]interface{})
+		}
+	case finalInput == nil:
+		log.Warnf("kiro: tool use %s (ID: %s) input never became valid JSON, returning it as text", state.Name, state.ToolUseID)
+		toolUse.InvalidInput = true
+		toolUse.RawInput = fullInput
+	default:
+		log.Infof("kiro: tool use %s input length: %d bytes (no truncation)", state.Name, len(fullInput))
+		toolUse.Input = finalInput
 	}
 
-	return toolUses, currentToolUse
+	log.Infof("kiro: completed tool use: %s (ID: %s, truncated: %v, invalid: %v)", state.Name, state.ToolUseID, toolUse.IsTruncated, toolUse.InvalidInput)
+	return toolUse
+}
+
+// InvalidToolInputText renders a tool use whose input never became valid JSON as
+// assistant text, so the client sees what the model attempted instead of a tool call
+// it cannot parse.
+func InvalidToolInputText(toolUse KiroToolUse) string {
+	return fmt.Sprintf("\n\n[Error: tool call %s (ID: %s) was not executed because its arguments are not valid JSON]\n%s\n",
+		toolUse.Name, toolUse.ToolUseID, toolUse.RawInput)
 }
 
 // DeduplicateToolUses removes duplicate tool uses based on toolUseId and content.
@@ -0,0 +1,119 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// replayToolUseStream feeds a recorded Kiro event transcript through a ToolUseAssembler,
+// returning the tool uses in completion order and the assistant text of the turn.
+func replayToolUseStream(t *testing.T, name string) ([]KiroToolUse, string) {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", "tool_use_streams", name))
+	if err != nil {
+		t.Fatalf("open transcript: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	assembler := NewToolUseAssembler(map[string]bool{})
+	var toolUses []KiroToolUse
+	var text strings.Builder
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record struct {
+			Type    string                 `json:"type"`
+			Payload map[string]interface{} `json:"payload"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode transcript line %q: %v", scanner.Text(), err)
+		}
+		switch record.Type {
+		case "toolUseEvent":
+			toolUses = append(toolUses, assembler.ProcessToolUseEvent(record.Payload)...)
+		case "assistantResponseEvent":
+			content, _ := record.Payload["content"].(string)
+			text.WriteString(content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+	toolUses = append(toolUses, assembler.Flush()...)
+	if assembler.Pending() {
+		t.Fatal("assembler still has pending tool uses after Flush")
+	}
+	return toolUses, text.String()
+}
+
+func TestToolUseAssembler_Transcripts(t *testing.T) {
+	type wantTool struct {
+		id      string
+		name    string
+		input   string
+		invalid bool
+	}
+	tests := []struct {
+		transcript string
+		text       string
+		tools      []wantTool
+	}{
+		{
+			transcript: "split_arguments.jsonl",
+			text:       "Let me read that file.",
+			tools:      []wantTool{{id: "tooluse_split01", name: "Read", input: `{"file_path":"/src/main.go","limit":200}`}},
+		},
+		{
+			transcript: "interleaved_tools.jsonl",
+			text:       "Checking both files. Then listing.",
+			tools: []wantTool{
+				{id: "tooluse_b", name: "Bash", input: `{"command":"ls -la"}`},
+				{id: "tooluse_a", name: "Read", input: `{"file_path":"/a.txt"}`},
+			},
+		},
+		{
+			transcript: "invalid_arguments.jsonl",
+			text:       "Opening it now.",
+			tools:      []wantTool{{id: "tooluse_bad", name: "Read", input: `{"file_path": /etc/hosts}`, invalid: true}},
+		},
+		{
+			transcript: "missing_stop.jsonl",
+			tools:      []wantTool{{id: "tooluse_eof", name: "Read", input: `{"file_path":"/b.txt"}`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transcript, func(t *testing.T) {
+			toolUses, text := replayToolUseStream(t, tt.transcript)
+			if text != tt.text {
+				t.Fatalf("text = %q, want %q", text, tt.text)
+			}
+			if len(toolUses) != len(tt.tools) {
+				t.Fatalf("got %d tool uses, want %d: %+v", len(toolUses), len(tt.tools), toolUses)
+			}
+			for i, want := range tt.tools {
+				got := toolUses[i]
+				if got.ToolUseID != want.id || got.Name != want.name || got.InvalidInput != want.invalid || got.IsTruncated {
+					t.Fatalf("tool use %d = %+v, want %+v", i, got, want)
+				}
+				input := got.RawInput
+				if !want.invalid {
+					raw, _ := json.Marshal(got.Input)
+					input = string(raw)
+				}
+				if input != want.input {
+					t.Fatalf("tool use %d input = %s, want %s", i, input, want.input)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidToolInputText(t *testing.T) {
+	text := InvalidToolInputText(KiroToolUse{ToolUseID: "tooluse_bad", Name: "Read", InvalidInput: true, RawInput: `{"file_path": /etc/hosts}`})
+	if !strings.Contains(text, "[Error: tool call Read (ID: tooluse_bad)") || !strings.Contains(text, `{"file_path": /etc/hosts}`) {
+		t.Fatalf("InvalidToolInputText = %q", text)
+	}
+}
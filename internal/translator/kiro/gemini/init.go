@@ -0,0 +1,22 @@
+// Package gemini provides translation between the Gemini generateContent API and Kiro formats.
+package gemini
+
+import (
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	claudegemini "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/translator"
+)
+
+func init() {
+	translator.Register(
+		Gemini,
+		Kiro,
+		ConvertGeminiRequestToKiro,
+		interfaces.TranslateResponse{
+			Stream:     ConvertKiroStreamToGemini,
+			NonStream:  ConvertKiroNonStreamToGemini,
+			TokenCount: claudegemini.GeminiTokenCount,
+		},
+	)
+}
@@ -0,0 +1,74 @@
+// Package gemini provides translation between the Gemini generateContent API and Kiro formats.
+//
+// The Kiro payload builder consumes Claude requests and the Kiro executor emits
+// Claude-compatible responses, so requests are converted to Claude first and
+// responses are converted from Claude to Gemini's candidates/usageMetadata shape.
+package gemini
+
+import (
+	"context"
+	"time"
+
+	claudegemini "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini"
+	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ConvertGeminiRequestToKiro converts a Gemini generateContent request into the Claude
+// request the Kiro payload builder expects, keeping contents, systemInstruction,
+// functionDeclarations and generationConfig.
+func ConvertGeminiRequestToKiro(modelName string, inputRawJSON []byte, stream bool) []byte {
+	return kiroclaude.WithDefaultMaxTokens(claudegemini.ConvertGeminiRequestToClaude(modelName, inputRawJSON, stream))
+}
+
+// ConvertKiroStreamToGemini converts one Claude SSE event emitted by the Kiro executor
+// ("event: ...\ndata: {...}") into Gemini streaming chunks.
+func ConvertKiroStreamToGemini(ctx context.Context, model string, originalRequest, request, rawResponse []byte, param *any) []string {
+	data := kiroclaude.EventData(rawResponse)
+	if len(data) == 0 {
+		return []string{}
+	}
+	return claudegemini.ConvertClaudeResponseToGemini(ctx, model, originalRequest, request, append([]byte("data: "), data...), param)
+}
+
+// ConvertKiroNonStreamToGemini converts the Claude message built by the Kiro executor
+// into a Gemini generateContent response.
+func ConvertKiroNonStreamToGemini(ctx context.Context, model string, originalRequest, request, rawResponse []byte, param *any) string {
+	response := gjson.ParseBytes(rawResponse)
+
+	out := `{"candidates":[{"content":{"role":"model","parts":[]},"finishReason":"STOP"}],"usageMetadata":{},"modelVersion":"","createTime":"","responseId":""}`
+	out, _ = sjson.Set(out, "modelVersion", model)
+	out, _ = sjson.Set(out, "responseId", response.Get("id").String())
+	out, _ = sjson.Set(out, "createTime", time.Now().Format(time.RFC3339Nano))
+
+	response.Get("content").ForEach(func(_, block gjson.Result) bool {
+		switch block.Get("type").String() {
+		case "text":
+			if text := block.Get("text").String(); text != "" {
+				part, _ := sjson.Set(`{"text":""}`, "text", text)
+				out, _ = sjson.SetRaw(out, "candidates.0.content.parts.-1", part)
+			}
+		case "thinking":
+			if thinking := block.Get("thinking").String(); thinking != "" {
+				part, _ := sjson.Set(`{"thought":true,"text":""}`, "text", thinking)
+				out, _ = sjson.SetRaw(out, "candidates.0.content.parts.-1", part)
+			}
+		case "tool_use":
+			part, _ := sjson.Set(`{"functionCall":{"name":"","args":{}}}`, "functionCall.name", block.Get("name").String())
+			if input := block.Get("input"); input.IsObject() {
+				part, _ = sjson.SetRaw(part, "functionCall.args", input.Raw)
+			}
+			out, _ = sjson.SetRaw(out, "candidates.0.content.parts.-1", part)
+		}
+		return true
+	})
+
+	if response.Get("stop_reason").String() == "max_tokens" {
+		out, _ = sjson.Set(out, "candidates.0.finishReason", "MAX_TOKENS")
+	}
+	if usage := response.Get("usage"); usage.Exists() {
+		out, _ = sjson.SetRaw(out, "usageMetadata", claudegemini.ClaudeUsageToGemini(usage))
+	}
+	return out
+}
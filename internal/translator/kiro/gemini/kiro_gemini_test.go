@@ -0,0 +1,106 @@
+package gemini
+
+import (
+	"context"
+	"testing"
+
+	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertGeminiRequestToKiro_FunctionDeclarations(t *testing.T) {
+	input := `{
+		"systemInstruction":{"parts":[{"text":"Be brief."}]},
+		"contents":[{"role":"user","parts":[{"text":"Weather in Paris?"}]}],
+		"tools":[{"functionDeclarations":[{"name":"get_weather","description":"Look up weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}]}],
+		"generationConfig":{"maxOutputTokens":256}
+	}`
+	out := gjson.ParseBytes(ConvertGeminiRequestToKiro("claude-sonnet-4.5", []byte(input), false))
+	if out.Get("tools.0.name").String() != "get_weather" || !out.Get("tools.0.input_schema.properties.city").Exists() {
+		t.Fatalf("expected the function declaration as a Claude tool, got %s", out.Get("tools").Raw)
+	}
+	if out.Get("max_tokens").Int() != 256 {
+		t.Fatalf("expected maxOutputTokens to map to max_tokens, got %s", out.Raw)
+	}
+	if out.Get("messages.0.content.0.text").String() != "Weather in Paris?" {
+		t.Fatalf("expected the user turn, got %s", out.Get("messages").Raw)
+	}
+}
+
+func TestConvertGeminiRequestToKiro_DefaultMaxTokens(t *testing.T) {
+	input := `{"contents":[{"role":"user","parts":[{"text":"Hi"}]}]}`
+	claudeBody := ConvertGeminiRequestToKiro("claude-sonnet-4.5", []byte(input), false)
+	payload, _ := kiroclaude.BuildKiroPayload(claudeBody, "claude-sonnet-4.5", "", "AI_EDITOR", false, false, nil, nil)
+	if got := gjson.GetBytes(payload, "inferenceConfig.maxTokens").Int(); got != 32000 {
+		t.Fatalf("inferenceConfig.maxTokens = %d, want the Kiro default 32000: %s", got, payload)
+	}
+}
+
+func TestConvertKiroStreamToGemini(t *testing.T) {
+	const model = "claude-sonnet-4.5"
+	var param any
+	ctx := context.Background()
+	events := [][]byte{
+		kiroclaude.BuildClaudeMessageStartEvent(model, 1000),
+		kiroclaude.BuildClaudeContentBlockStartEvent(0, "text", "", ""),
+		kiroclaude.BuildClaudeStreamEvent("Sunny.", 0),
+		kiroclaude.BuildClaudeContentBlockStopEvent(0),
+		kiroclaude.BuildClaudeContentBlockStartEvent(1, "tool_use", "toolu_1", "get_weather"),
+		kiroclaude.BuildClaudeInputJsonDeltaEvent(`{"city":"Paris"}`, 1),
+		kiroclaude.BuildClaudeContentBlockStopEvent(1),
+		kiroclaude.BuildClaudeMessageDeltaEvent(model, "tool_use", usage.Detail{InputTokens: 1000, OutputTokens: 5}),
+	}
+	var chunks []gjson.Result
+	for _, event := range events {
+		for _, chunk := range ConvertKiroStreamToGemini(ctx, model, nil, nil, event, &param) {
+			chunks = append(chunks, gjson.Parse(chunk))
+		}
+	}
+
+	var text string
+	var call, usageMetadata gjson.Result
+	for _, chunk := range chunks {
+		chunk.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+			text += part.Get("text").String()
+			if part.Get("functionCall").Exists() {
+				call = part.Get("functionCall")
+			}
+			return true
+		})
+		if chunk.Get("usageMetadata.promptTokenCount").Exists() {
+			usageMetadata = chunk.Get("usageMetadata")
+		}
+	}
+	if text != "Sunny." {
+		t.Fatalf("expected the streamed text, got %q", text)
+	}
+	if call.Get("name").String() != "get_weather" || call.Get("args.city").String() != "Paris" {
+		t.Fatalf("expected the function call, got %s", call.Raw)
+	}
+	if usageMetadata.Get("promptTokenCount").Int() != 1000 || usageMetadata.Get("candidatesTokenCount").Int() != 5 {
+		t.Fatalf("expected prompt and candidate counts, got %s", usageMetadata.Raw)
+	}
+	if usageMetadata.Get("cachedContentTokenCount").Int() != 894 {
+		t.Fatalf("expected the simulated cache reads, got %s", usageMetadata.Raw)
+	}
+}
+
+func TestConvertKiroNonStreamToGemini(t *testing.T) {
+	const model = "claude-sonnet-4.5"
+	toolUses := []kiroclaude.KiroToolUse{{ToolUseID: "toolu_1", Name: "get_weather", Input: map[string]any{"city": "Paris"}}}
+	response := kiroclaude.BuildClaudeResponse("Checking.", toolUses, model, usage.Detail{InputTokens: 1000, OutputTokens: 7}, "tool_use")
+
+	out := gjson.Parse(ConvertKiroNonStreamToGemini(context.Background(), model, nil, nil, response, nil))
+	parts := out.Get("candidates.0.content.parts")
+	if parts.Get("0.text").String() != "Checking." || parts.Get("1.functionCall.args.city").String() != "Paris" {
+		t.Fatalf("unexpected parts: %s", parts.Raw)
+	}
+	if out.Get("candidates.0.finishReason").String() != "STOP" {
+		t.Fatalf("unexpected finish reason: %s", out.Raw)
+	}
+	usageMetadata := out.Get("usageMetadata")
+	if usageMetadata.Get("promptTokenCount").Int() != 1000 || usageMetadata.Get("totalTokenCount").Int() != 1007 || usageMetadata.Get("cachedContentTokenCount").Int() != 894 {
+		t.Fatalf("unexpected usage metadata: %s", usageMetadata.Raw)
+	}
+}
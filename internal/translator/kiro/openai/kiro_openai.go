@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -142,14 +143,19 @@ func ConvertKiroStreamToOpenAI(ctx context.Context, model string, originalReques
 			results = append(results, chunk)
 		}
 
-		// Extract usage if present
+		// Extract usage if present; simulated cache buckets fold back into prompt tokens
 		if eventJSON.Get("usage").Exists() {
-			inputTokens := eventJSON.Get("usage.input_tokens").Int()
+			inputTokens := internalusage.CollapseToInput(
+				eventJSON.Get("usage.input_tokens").Int(),
+				eventJSON.Get("usage.cache_creation_input_tokens").Int(),
+				eventJSON.Get("usage.cache_read_input_tokens").Int(),
+			)
 			outputTokens := eventJSON.Get("usage.output_tokens").Int()
 			usageInfo := usage.Detail{
 				InputTokens:  inputTokens,
 				OutputTokens: outputTokens,
 				TotalTokens:  inputTokens + outputTokens,
+				CachedTokens: eventJSON.Get("usage.cache_read_input_tokens").Int(),
 			}
 			chunk := BuildOpenAISSEUsage(state, usageInfo)
 			results = append(results, chunk)
@@ -228,10 +234,15 @@ func ConvertKiroNonStreamToOpenAI(ctx context.Context, model string, originalReq
 		}
 	}
 
-	// Extract usage
+	// Extract usage, folding simulated cache buckets back into the prompt token count
 	usageInfo := usage.Detail{
-		InputTokens:  response.Get("usage.input_tokens").Int(),
+		InputTokens: internalusage.CollapseToInput(
+			response.Get("usage.input_tokens").Int(),
+			response.Get("usage.cache_creation_input_tokens").Int(),
+			response.Get("usage.cache_read_input_tokens").Int(),
+		),
 		OutputTokens: response.Get("usage.output_tokens").Int(),
+		CachedTokens: response.Get("usage.cache_read_input_tokens").Int(),
 	}
 	usageInfo.TotalTokens = usageInfo.InputTokens + usageInfo.OutputTokens
 
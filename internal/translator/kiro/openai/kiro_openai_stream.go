@@ -122,16 +122,26 @@ func BuildOpenAISSEUsage(state *OpenAIStreamState, usageInfo usage.Detail) strin
 		"created": state.Created,
 		"model":   state.Model,
 		"choices": []map[string]interface{}{},
-		"usage": map[string]interface{}{
-			"prompt_tokens":     usageInfo.InputTokens,
-			"completion_tokens": usageInfo.OutputTokens,
-			"total_tokens":      usageInfo.InputTokens + usageInfo.OutputTokens,
-		},
+		"usage":   buildOpenAIUsage(usageInfo),
 	}
 	result, _ := json.Marshal(chunk)
 	return FormatSSEEvent(result)
 }
 
+// buildOpenAIUsage builds an OpenAI usage object. Simulated cache reads are reported
+// as prompt_tokens_details.cached_tokens.
+func buildOpenAIUsage(usageInfo usage.Detail) map[string]interface{} {
+	usageBlock := map[string]interface{}{
+		"prompt_tokens":     usageInfo.InputTokens,
+		"completion_tokens": usageInfo.OutputTokens,
+		"total_tokens":      usageInfo.InputTokens + usageInfo.OutputTokens,
+	}
+	if usageInfo.CachedTokens > 0 {
+		usageBlock["prompt_tokens_details"] = map[string]interface{}{"cached_tokens": usageInfo.CachedTokens}
+	}
+	return usageBlock
+}
+
 // BuildOpenAISSEDone creates the final [DONE] SSE event.
 // Note: This returns raw "[DONE]" without "data:" prefix.
 // The SSE "data:" prefix is added by the Handler layer (e.g., openai_handlers.go)
@@ -209,4 +219,4 @@ func NewThinkingTagState() *ThinkingTagState {
 		PendingStartChars: 0,
 		PendingEndChars:   0,
 	}
-}
\ No newline at end of file
+}
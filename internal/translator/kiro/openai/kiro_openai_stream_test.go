@@ -0,0 +1,35 @@
+package openai
+
+import (
+	"context"
+	"testing"
+
+	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertKiroStreamToOpenAI_UsageReportsCachedTokens(t *testing.T) {
+	var param any
+	ctx := context.Background()
+	_ = ConvertKiroStreamToOpenAI(ctx, "claude-sonnet-4.5", nil, nil, kiroclaude.BuildClaudeMessageStartEvent("claude-sonnet-4.5", 1000), &param)
+	delta := kiroclaude.BuildClaudeMessageDeltaEvent("claude-sonnet-4.5", "end_turn", usage.Detail{InputTokens: 1000, OutputTokens: 5})
+	results := ConvertKiroStreamToOpenAI(ctx, "claude-sonnet-4.5", nil, nil, delta, &param)
+
+	var usageChunk gjson.Result
+	for _, result := range results {
+		chunk := gjson.Parse(result)
+		if chunk.Get("usage").Exists() {
+			usageChunk = chunk
+		}
+	}
+	if !usageChunk.Exists() {
+		t.Fatalf("expected a usage chunk, got %q", results)
+	}
+	if got := usageChunk.Get("usage.prompt_tokens").Int(); got != 1000 {
+		t.Fatalf("prompt_tokens = %d, want 1000", got)
+	}
+	if got := usageChunk.Get("usage.prompt_tokens_details.cached_tokens").Int(); got != 894 {
+		t.Fatalf("cached_tokens = %d, want 894", got)
+	}
+}
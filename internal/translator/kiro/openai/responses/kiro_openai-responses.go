@@ -0,0 +1,35 @@
+// Package responses provides translation between the OpenAI Responses API and Kiro formats.
+//
+// The Kiro payload builder consumes Claude requests and the Kiro executor emits
+// Claude-compatible responses, so both directions go through the Claude Responses
+// translators.
+package responses
+
+import (
+	"context"
+
+	clauderesponses "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/openai/responses"
+	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
+)
+
+// ConvertOpenAIResponsesRequestToKiro converts a Responses API request into the Claude
+// request the Kiro payload builder expects.
+func ConvertOpenAIResponsesRequestToKiro(modelName string, inputRawJSON []byte, stream bool) []byte {
+	return kiroclaude.WithDefaultMaxTokens(clauderesponses.ConvertOpenAIResponsesRequestToClaude(modelName, inputRawJSON, stream))
+}
+
+// ConvertKiroStreamToOpenAIResponses converts one Claude SSE event emitted by the Kiro
+// executor into Responses API stream events.
+func ConvertKiroStreamToOpenAIResponses(ctx context.Context, model string, originalRequest, request, rawResponse []byte, param *any) []string {
+	data := kiroclaude.EventData(rawResponse)
+	if len(data) == 0 {
+		return []string{}
+	}
+	return clauderesponses.ConvertClaudeResponseToOpenAIResponses(ctx, model, originalRequest, request, append([]byte("data: "), data...), param)
+}
+
+// ConvertKiroNonStreamToOpenAIResponses converts the Claude message built by the Kiro
+// executor into a Responses API response object.
+func ConvertKiroNonStreamToOpenAIResponses(ctx context.Context, model string, originalRequest, request, rawResponse []byte, param *any) string {
+	return clauderesponses.ConvertClaudeResponseToOpenAIResponsesNonStream(ctx, model, originalRequest, request, kiroclaude.MessageToSSE(rawResponse), param)
+}
@@ -0,0 +1,72 @@
+package responses
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertOpenAIResponsesRequestToKiro_MaxTokens(t *testing.T) {
+	for input, want := range map[string]int64{
+		`{"model":"claude-sonnet-4.5","input":"Hi"}`:                         32000,
+		`{"model":"claude-sonnet-4.5","input":"Hi","max_output_tokens":512}`: 512,
+	} {
+		claudeBody := ConvertOpenAIResponsesRequestToKiro("claude-sonnet-4.5", []byte(input), false)
+		payload, _ := kiroclaude.BuildKiroPayload(claudeBody, "claude-sonnet-4.5", "", "AI_EDITOR", false, false, nil, nil)
+		if got := gjson.GetBytes(payload, "inferenceConfig.maxTokens").Int(); got != want {
+			t.Fatalf("%s: inferenceConfig.maxTokens = %d, want %d: %s", input, got, want, payload)
+		}
+	}
+}
+
+func TestConvertKiroNonStreamToOpenAIResponses(t *testing.T) {
+	const model = "claude-sonnet-4.5"
+	toolUses := []kiroclaude.KiroToolUse{{ToolUseID: "toolu_1", Name: "get_weather", Input: map[string]any{"city": "Paris"}}}
+	message := kiroclaude.BuildClaudeResponse("<thinking>Need a lookup.</thinking>Checking.", toolUses, model, usage.Detail{InputTokens: 1000, OutputTokens: 7}, "tool_use")
+
+	out := gjson.Parse(ConvertKiroNonStreamToOpenAIResponses(context.Background(), model, []byte(`{"model":"claude-sonnet-4.5"}`), nil, message, nil))
+	var types []string
+	out.Get("output").ForEach(func(_, item gjson.Result) bool {
+		types = append(types, item.Get("type").String())
+		return true
+	})
+	if got := strings.Join(types, ","); got != "reasoning,message,function_call" {
+		t.Fatalf("output item types = %s, want reasoning,message,function_call: %s", got, out.Get("output").Raw)
+	}
+	if call := out.Get(`output.#(type=="function_call")`); call.Get("name").String() != "get_weather" || gjson.Get(call.Get("arguments").String(), "city").String() != "Paris" {
+		t.Fatalf("unexpected function call: %s", call.Raw)
+	}
+	if out.Get("usage.output_tokens").Int() != 7 {
+		t.Fatalf("unexpected usage: %s", out.Get("usage").Raw)
+	}
+}
+
+func TestConvertKiroStreamToOpenAIResponses(t *testing.T) {
+	const model = "claude-sonnet-4.5"
+	var param any
+	events := [][]byte{
+		kiroclaude.BuildClaudeMessageStartEvent(model, 1000),
+		kiroclaude.BuildClaudeContentBlockStartEvent(0, "text", "", ""),
+		kiroclaude.BuildClaudeStreamEvent("Sunny.", 0),
+		kiroclaude.BuildClaudeContentBlockStopEvent(0),
+		kiroclaude.BuildClaudeMessageDeltaEvent(model, "end_turn", usage.Detail{InputTokens: 1000, OutputTokens: 5}),
+		kiroclaude.BuildClaudeMessageStopOnlyEvent(),
+	}
+	var stream strings.Builder
+	for _, event := range events {
+		for _, out := range ConvertKiroStreamToOpenAIResponses(context.Background(), model, []byte(`{"model":"claude-sonnet-4.5"}`), nil, event, &param) {
+			stream.WriteString(out)
+			stream.WriteString("\n")
+		}
+	}
+	got := stream.String()
+	for _, event := range []string{"response.created", "response.output_text.delta", "response.completed"} {
+		if !strings.Contains(got, "event: "+event) {
+			t.Fatalf("expected a %s event in:\n%s", event, got)
+		}
+	}
+}
@@ -6,9 +6,13 @@
 package claude
 
 import (
+	"context"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/params"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -26,35 +30,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	// Model mapping
 	out, _ = sjson.Set(out, "model", modelName)
 
-	// Max tokens
-	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
-	}
-
-	// Temperature
-	if temp := root.Get("temperature"); temp.Exists() {
-		out, _ = sjson.Set(out, "temperature", temp.Float())
-	} else if topP := root.Get("top_p"); topP.Exists() { // Top P
-		out, _ = sjson.Set(out, "top_p", topP.Float())
-	}
-
-	// Stop sequences -> stop
-	if stopSequences := root.Get("stop_sequences"); stopSequences.Exists() {
-		if stopSequences.IsArray() {
-			var stops []string
-			stopSequences.ForEach(func(_, value gjson.Result) bool {
-				stops = append(stops, value.String())
-				return true
-			})
-			if len(stops) > 0 {
-				if len(stops) == 1 {
-					out, _ = sjson.Set(out, "stop", stops[0])
-				} else {
-					out, _ = sjson.Set(out, "stop", stops)
-				}
-			}
-		}
-	}
+	// Generation parameters (max_tokens, temperature, top_p, top_k, stop_sequences)
+	out = string(params.Translate(params.Claude, params.OpenAI, modelName, rawJSON, []byte(out), ""))
 
 	// Stream
 	out, _ = sjson.Set(out, "stream", stream)
@@ -129,7 +106,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				var contentItems []string
 				var reasoningParts []string // Accumulate thinking text for reasoning_content
 				var toolCalls []interface{}
-				var toolResults []string // Collect tool_result messages to emit after the main message
+				var toolResults []string     // Collect tool_result messages to emit after the main message
+				var toolResultMedia []string // Images and documents returned by tools, sent as user content
 
 				contentResult.ForEach(func(_, part gjson.Result) bool {
 					partType := part.Get("type").String()
@@ -149,7 +127,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 					case "redacted_thinking":
 						// Explicitly ignore redacted_thinking - never map to reasoning_content (AC2)
 
-					case "text", "image":
+					case "text", "image", "document":
 						if contentItem, ok := convertClaudeContentPart(part); ok {
 							contentItems = append(contentItems, contentItem)
 						}
@@ -177,9 +155,21 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						toolResultJSON, _ = sjson.Set(toolResultJSON, "tool_call_id", part.Get("tool_use_id").String())
 						toolResultJSON, _ = sjson.Set(toolResultJSON, "content", convertClaudeToolResultContentToString(part.Get("content")))
 						toolResults = append(toolResults, toolResultJSON)
+						// OpenAI tool messages carry text only, so images follow in the user message.
+						part.Get("content").ForEach(func(_, item gjson.Result) bool {
+							if t := item.Get("type").String(); t == "image" || t == "document" {
+								if contentItem, ok := convertClaudeContentPart(item); ok {
+									toolResultMedia = append(toolResultMedia, contentItem)
+								}
+							}
+							return true
+						})
 					}
 					return true
 				})
+				if len(toolResultMedia) > 0 && role != "assistant" {
+					contentItems = append(toolResultMedia, contentItems...)
+				}
 
 				// Build reasoning content string
 				reasoningContent := ""
@@ -295,6 +285,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			out, _ = sjson.Set(out, "tool_choice", "auto")
 		case "any":
 			out, _ = sjson.Set(out, "tool_choice", "required")
+		case "none":
+			out, _ = sjson.Set(out, "tool_choice", "none")
 		case "tool":
 			// Specific tool choice
 			toolName := toolChoice.Get("name").String()
@@ -305,6 +297,9 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			// Default to auto if not specified
 			out, _ = sjson.Set(out, "tool_choice", "auto")
 		}
+		if toolChoice.Get("disable_parallel_tool_use").Bool() {
+			out, _ = sjson.Set(out, "parallel_tool_calls", false)
+		}
 	}
 
 	// Handle user parameter (for tracking)
@@ -361,11 +356,56 @@ func convertClaudeContentPart(part gjson.Result) (string, bool) {
 
 		return imageContent, true
 
+	case "document":
+		return convertClaudeDocumentPart(part)
+
 	default:
 		return "", false
 	}
 }
 
+// convertClaudeDocumentPart converts a Claude document block into an OpenAI file part.
+// Plain-text documents become text parts, and URL sources are fetched because OpenAI
+// file parts only carry inline data.
+func convertClaudeDocumentPart(part gjson.Result) (string, bool) {
+	source := part.Get("source")
+	var mediaType, data string
+	switch source.Get("type").String() {
+	case "base64":
+		data = source.Get("data").String()
+		mediaType = util.SniffBase64MediaType(data, source.Get("media_type").String())
+	case "url":
+		var err error
+		mediaType, data, err = util.FetchMedia(context.Background(), source.Get("url").String())
+		if err != nil {
+			log.Warnf("openai: skipping document %s: %v", source.Get("url").String(), err)
+			return "", false
+		}
+	case "text":
+		text := source.Get("data").String()
+		if strings.TrimSpace(text) == "" {
+			return "", false
+		}
+		textContent, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+		return textContent, true
+	}
+	if data == "" {
+		return "", false
+	}
+
+	filename := part.Get("title").String()
+	if filename == "" {
+		filename = "document"
+		if mediaType == "application/pdf" {
+			filename += ".pdf"
+		}
+	}
+	fileContent := `{"type":"file","file":{"filename":"","file_data":""}}`
+	fileContent, _ = sjson.Set(fileContent, "file.filename", filename)
+	fileContent, _ = sjson.Set(fileContent, "file.file_data", "data:"+mediaType+";base64,"+data)
+	return fileContent, true
+}
+
 func convertClaudeToolResultContentToString(content gjson.Result) string {
 	if !content.Exists() {
 		return ""
@@ -383,6 +423,9 @@ func convertClaudeToolResultContentToString(content gjson.Result) string {
 				parts = append(parts, item.String())
 			case item.IsObject() && item.Get("text").Exists() && item.Get("text").Type == gjson.String:
 				parts = append(parts, item.Get("text").String())
+			case item.Get("type").String() == "image" || item.Get("type").String() == "document":
+				// Sent to the model as user content; see ConvertClaudeRequestToOpenAI.
+				parts = append(parts, "["+item.Get("type").String()+" attached]")
 			default:
 				parts = append(parts, item.Raw)
 			}
@@ -588,3 +588,49 @@ func TestConvertClaudeRequestToOpenAI_AssistantThinkingToolUseThinkingSplit(t *t
 		t.Fatalf("Expected reasoning_content %q, got %q", "t1\n\nt2", got)
 	}
 }
+
+func TestConvertClaudeRequestToOpenAI_ToolResultImagesAndDocuments(t *testing.T) {
+	inputJSON := `{
+		"model": "claude-3-opus",
+		"messages": [
+			{
+				"role": "assistant",
+				"content": [
+					{"type": "tool_use", "id": "call_1", "name": "screenshot", "input": {}}
+				]
+			},
+			{
+				"role": "user",
+				"content": [
+					{"type": "tool_result", "tool_use_id": "call_1", "content": [
+						{"type": "text", "text": "captured"},
+						{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}}
+					]},
+					{"type": "document", "title": "spec.pdf", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0xLjcK"}},
+					{"type": "text", "text": "What changed?"}
+				]
+			}
+		]
+	}`
+
+	result := ConvertClaudeRequestToOpenAI("test-model", []byte(inputJSON), false)
+	messages := gjson.ParseBytes(result).Get("messages").Array()
+
+	// assistant(tool_calls) + tool(result) + user(image, document, text)
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d: %s", len(messages), gjson.ParseBytes(result).Get("messages").Raw)
+	}
+	if got := messages[1].Get("content").String(); got != "captured\n\n[image attached]" {
+		t.Fatalf("Expected tool content without base64 data, got %q", got)
+	}
+	content := messages[2].Get("content")
+	if content.Get("0.type").String() != "image_url" || content.Get("0.image_url.url").String() != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Fatalf("Expected the tool image first in the user message, got %s", content.Raw)
+	}
+	if content.Get("1.type").String() != "file" || content.Get("1.file.filename").String() != "spec.pdf" || content.Get("1.file.file_data").String() != "data:application/pdf;base64,JVBERi0xLjcK" {
+		t.Fatalf("Expected the document as a file part, got %s", content.Get("1").Raw)
+	}
+	if content.Get("2.text").String() != "What changed?" {
+		t.Fatalf("Expected the user text last, got %s", content.Raw)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"strings"
 
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -697,17 +698,8 @@ func extractOpenAIUsage(usage gjson.Result) (int64, int64, int64) {
 		return 0, 0, 0
 	}
 
-	inputTokens := usage.Get("prompt_tokens").Int()
 	outputTokens := usage.Get("completion_tokens").Int()
-	cachedTokens := usage.Get("prompt_tokens_details.cached_tokens").Int()
+	dist := internalusage.FromOpenAIUsage(usage.Get("prompt_tokens").Int(), usage.Get("prompt_tokens_details.cached_tokens").Int())
 
-	if cachedTokens > 0 {
-		if inputTokens >= cachedTokens {
-			inputTokens -= cachedTokens
-		} else {
-			inputTokens = 0
-		}
-	}
-
-	return inputTokens, outputTokens, cachedTokens
+	return dist.InputTokens, outputTokens, dist.CacheReadInputTokens
 }
@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/params"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -41,41 +43,11 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	// Model mapping
 	out, _ = sjson.Set(out, "model", modelName)
 
+	// Generation parameters (maxOutputTokens, temperature, topP, topK, stopSequences, penalties, seed, logprobs)
+	out = string(params.Translate(params.Gemini, params.OpenAI, modelName, rawJSON, []byte(out), ""))
+
 	// Generation config mapping
 	if genConfig := root.Get("generationConfig"); genConfig.Exists() {
-		// Temperature
-		if temp := genConfig.Get("temperature"); temp.Exists() {
-			out, _ = sjson.Set(out, "temperature", temp.Float())
-		}
-
-		// Max tokens
-		if maxTokens := genConfig.Get("maxOutputTokens"); maxTokens.Exists() {
-			out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
-		}
-
-		// Top P
-		if topP := genConfig.Get("topP"); topP.Exists() {
-			out, _ = sjson.Set(out, "top_p", topP.Float())
-		}
-
-		// Top K (OpenAI doesn't have direct equivalent, but we can map it)
-		if topK := genConfig.Get("topK"); topK.Exists() {
-			// Store as custom parameter for potential use
-			out, _ = sjson.Set(out, "top_k", topK.Int())
-		}
-
-		// Stop sequences
-		if stopSequences := genConfig.Get("stopSequences"); stopSequences.Exists() && stopSequences.IsArray() {
-			var stops []string
-			stopSequences.ForEach(func(_, value gjson.Result) bool {
-				stops = append(stops, value.String())
-				return true
-			})
-			if len(stops) > 0 {
-				out, _ = sjson.Set(out, "stop", stops)
-			}
-		}
-
 		// Candidate count (OpenAI 'n' parameter)
 		if candidateCount := genConfig.Get("candidateCount"); candidateCount.Exists() {
 			out, _ = sjson.Set(out, "n", candidateCount.Int())
@@ -136,16 +108,26 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				}
 
 				// Handle inline data (e.g., images)
-				if inlineData := part.Get("inlineData"); inlineData.Exists() {
-					mimeType := inlineData.Get("mimeType").String()
-					if mimeType == "" {
-						mimeType = "application/octet-stream"
+				inlineData := part.Get("inlineData")
+				if !inlineData.Exists() {
+					inlineData = part.Get("inline_data")
+				}
+				if inlineData.Exists() {
+					declared := inlineData.Get("mimeType").String()
+					if declared == "" {
+						declared = inlineData.Get("mime_type").String()
 					}
 					data := inlineData.Get("data").String()
-					imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, data)
+					mimeType := util.SniffBase64MediaType(data, declared)
+					dataURI := fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 
 					contentPart := `{"type":"image_url","image_url":{"url":""}}`
-					contentPart, _ = sjson.Set(contentPart, "image_url.url", imageURL)
+					if mimeType == "application/pdf" {
+						contentPart = `{"type":"file","file":{"filename":"document.pdf","file_data":""}}`
+						contentPart, _ = sjson.Set(contentPart, "file.file_data", dataURI)
+					} else {
+						contentPart, _ = sjson.Set(contentPart, "image_url.url", dataURI)
+					}
 					msg, _ = sjson.SetRaw(msg, "content.-1", contentPart)
 					hasContent = true
 				}
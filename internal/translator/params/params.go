@@ -0,0 +1,266 @@
+// Package params translates generation parameters between request dialects.
+// Temperature, nucleus and top-k sampling, stop sequences, output limits, penalties,
+// seed and logprobs are mapped through a single matrix, so every request translator
+// forwards the same parameters, clamps them to the target backend's valid ranges and
+// reports what it could not carry over.
+package params
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Dialect identifies a request format whose generation parameters the matrix maps.
+type Dialect string
+
+const (
+	// OpenAI is the OpenAI Chat Completions request format.
+	OpenAI Dialect = "openai"
+	// Claude is the Anthropic Messages request format.
+	Claude Dialect = "claude"
+	// Gemini is the Gemini generateContent request format.
+	Gemini Dialect = "gemini"
+)
+
+// kind is the JSON shape of a parameter value.
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindInteger
+	kindBool
+	kindStrings
+)
+
+// bounds is the valid range of a parameter on one backend. For string lists it bounds
+// the number of entries.
+type bounds struct {
+	min, max float64
+}
+
+var unbounded = bounds{min: math.Inf(-1), max: math.Inf(1)}
+
+// param is one row of the translation matrix: the field naming the parameter in each
+// dialect, and its valid range on each backend. A dialect without a field does not
+// support the parameter.
+type param struct {
+	name   string
+	kind   kind
+	fields map[Dialect]string
+	ranges map[Dialect]bounds
+}
+
+var matrix = []param{
+	{
+		name:   "temperature",
+		kind:   kindNumber,
+		fields: map[Dialect]string{OpenAI: "temperature", Claude: "temperature", Gemini: "temperature"},
+		ranges: map[Dialect]bounds{OpenAI: {0, 2}, Claude: {0, 1}, Gemini: {0, 2}},
+	},
+	{
+		name:   "top_p",
+		kind:   kindNumber,
+		fields: map[Dialect]string{OpenAI: "top_p", Claude: "top_p", Gemini: "topP"},
+		ranges: map[Dialect]bounds{OpenAI: {0, 1}, Claude: {0, 1}, Gemini: {0, 1}},
+	},
+	{
+		// top_k is not part of the OpenAI API, but OpenAI-compatible providers accept it.
+		name:   "top_k",
+		kind:   kindInteger,
+		fields: map[Dialect]string{OpenAI: "top_k", Claude: "top_k", Gemini: "topK"},
+		ranges: map[Dialect]bounds{OpenAI: {1, math.Inf(1)}, Claude: {1, math.Inf(1)}, Gemini: {1, math.Inf(1)}},
+	},
+	{
+		name:   "max_tokens",
+		kind:   kindInteger,
+		fields: map[Dialect]string{OpenAI: "max_tokens", Claude: "max_tokens", Gemini: "maxOutputTokens"},
+		ranges: map[Dialect]bounds{OpenAI: {1, math.Inf(1)}, Claude: {1, math.Inf(1)}, Gemini: {1, math.Inf(1)}},
+	},
+	{
+		name:   "stop",
+		kind:   kindStrings,
+		fields: map[Dialect]string{OpenAI: "stop", Claude: "stop_sequences", Gemini: "stopSequences"},
+		ranges: map[Dialect]bounds{OpenAI: {0, 4}, Claude: unbounded, Gemini: {0, 5}},
+	},
+	{
+		name:   "frequency_penalty",
+		kind:   kindNumber,
+		fields: map[Dialect]string{OpenAI: "frequency_penalty", Gemini: "frequencyPenalty"},
+		ranges: map[Dialect]bounds{OpenAI: {-2, 2}, Gemini: {-2, 2}},
+	},
+	{
+		name:   "presence_penalty",
+		kind:   kindNumber,
+		fields: map[Dialect]string{OpenAI: "presence_penalty", Gemini: "presencePenalty"},
+		ranges: map[Dialect]bounds{OpenAI: {-2, 2}, Gemini: {-2, 2}},
+	},
+	{
+		name:   "seed",
+		kind:   kindInteger,
+		fields: map[Dialect]string{OpenAI: "seed", Gemini: "seed"},
+		ranges: map[Dialect]bounds{OpenAI: unbounded, Gemini: unbounded},
+	},
+	{
+		name:   "logprobs",
+		kind:   kindBool,
+		fields: map[Dialect]string{OpenAI: "logprobs", Gemini: "responseLogprobs"},
+	},
+	{
+		name:   "top_logprobs",
+		kind:   kindInteger,
+		fields: map[Dialect]string{OpenAI: "top_logprobs", Gemini: "logprobs"},
+		ranges: map[Dialect]bounds{OpenAI: {0, 20}, Gemini: {0, 20}},
+	},
+}
+
+// sourceRoot is the object holding the generation parameters of a source request.
+var sourceRoot = map[Dialect]string{Gemini: "generationConfig"}
+
+// Translate copies the generation parameters of rawJSON, a request in the from dialect,
+// into out, a request in the to dialect whose parameters live under root ("" for the
+// top level, "generationConfig" or "request.generationConfig" for Gemini backends).
+// Values outside the target's valid range are clamped and max tokens are capped at the
+// model's output limit when the registry knows it; parameters the target does not
+// support are left out. Dropped and clamped parameters are logged at debug level.
+func Translate(from, to Dialect, modelName string, rawJSON, out []byte, root string) []byte {
+	source := gjson.ParseBytes(rawJSON)
+	if path := sourceRoot[from]; path != "" {
+		source = source.Get(path)
+	}
+	var dropped, clamped []string
+	for _, p := range matrix {
+		value := p.read(from, source)
+		if !value.Exists() || value.Type == gjson.Null {
+			continue
+		}
+		field, supported := p.fields[to]
+		if !supported {
+			dropped = append(dropped, p.name)
+			continue
+		}
+		if to == Claude && p.name == "top_p" && matrix[0].read(from, source).Exists() {
+			// Claude rejects temperature and top_p together; temperature wins.
+			dropped = append(dropped, p.name+" (temperature is set)")
+			continue
+		}
+		limits, ok := p.ranges[to]
+		if !ok {
+			limits = unbounded
+		}
+		if p.name == "max_tokens" {
+			if limit := outputLimit(modelName); limit > 0 && float64(limit) < limits.max {
+				limits.max = float64(limit)
+			}
+		}
+		var note string
+		var err error
+		out, note, err = p.write(out, joinPath(root, field), value, limits)
+		if err != nil {
+			dropped = append(dropped, p.name+" (invalid)")
+			continue
+		}
+		if note != "" {
+			clamped = append(clamped, p.name+" "+note)
+		}
+	}
+	if len(dropped) > 0 || len(clamped) > 0 {
+		log.Debugf("translator: %s -> %s parameters for model %s: dropped [%s], clamped [%s]", from, to, modelName, strings.Join(dropped, ", "), strings.Join(clamped, ", "))
+	}
+	return out
+}
+
+// read returns the parameter value of a source request. OpenAI's max_completion_tokens
+// supersedes the deprecated max_tokens.
+func (p param) read(from Dialect, source gjson.Result) gjson.Result {
+	field, ok := p.fields[from]
+	if !ok {
+		return gjson.Result{}
+	}
+	if from == OpenAI && p.name == "max_tokens" {
+		if value := source.Get("max_completion_tokens"); value.Exists() && value.Type != gjson.Null {
+			return value
+		}
+	}
+	return source.Get(field)
+}
+
+// write sets the clamped value at path, returning a note describing any clamping.
+func (p param) write(out []byte, path string, value gjson.Result, limits bounds) ([]byte, string, error) {
+	switch p.kind {
+	case kindNumber, kindInteger:
+		if value.Type != gjson.Number {
+			return out, "", fmt.Errorf("%s is not a number", p.name)
+		}
+		number, note := clamp(value.Num, limits)
+		var err error
+		if p.kind == kindInteger {
+			out, err = sjson.SetBytes(out, path, int64(number))
+		} else {
+			out, err = sjson.SetBytes(out, path, number)
+		}
+		return out, note, err
+	case kindBool:
+		if !value.IsBool() {
+			return out, "", fmt.Errorf("%s is not a boolean", p.name)
+		}
+		out, err := sjson.SetBytes(out, path, value.Bool())
+		return out, "", err
+	default:
+		var values []string
+		if value.Type == gjson.String {
+			values = []string{value.String()}
+		} else if value.IsArray() {
+			for _, item := range value.Array() {
+				values = append(values, item.String())
+			}
+		} else {
+			return out, "", fmt.Errorf("%s is not a string list", p.name)
+		}
+		if len(values) == 0 {
+			return out, "", nil
+		}
+		var note string
+		if max := int(limits.max); limits.max < math.Inf(1) && len(values) > max {
+			note = fmt.Sprintf("(kept %d of %d)", max, len(values))
+			values = values[:max]
+		}
+		out, err := sjson.SetBytes(out, path, values)
+		return out, note, err
+	}
+}
+
+// clamp limits number to limits, returning a note describing the change when it was
+// out of range.
+func clamp(number float64, limits bounds) (float64, string) {
+	clamped := math.Min(math.Max(number, limits.min), limits.max)
+	if clamped == number {
+		return number, ""
+	}
+	return clamped, fmt.Sprintf("(%v -> %v)", number, clamped)
+}
+
+// outputLimit returns the model's maximum output tokens from the registry, or 0 when
+// it is unknown.
+func outputLimit(modelName string) int {
+	info := registry.LookupModelInfo(modelName)
+	if info == nil {
+		return 0
+	}
+	if info.OutputTokenLimit > 0 {
+		return info.OutputTokenLimit
+	}
+	return info.MaxCompletionTokens
+}
+
+func joinPath(root, field string) string {
+	if root == "" {
+		return field
+	}
+	return root + "." + field
+}
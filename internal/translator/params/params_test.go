@@ -0,0 +1,151 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to Dialect
+		model    string
+		input    string
+		root     string
+		want     map[string]any
+		absent   []string
+	}{
+		{
+			name:   "openai to claude",
+			from:   OpenAI,
+			to:     Claude,
+			input:  `{"temperature":0.4,"top_k":40,"max_completion_tokens":512,"max_tokens":10,"stop":["END","STOP"],"frequency_penalty":0.5,"seed":7,"logprobs":true}`,
+			want:   map[string]any{"temperature": 0.4, "top_k": int64(40), "max_tokens": int64(512), "stop_sequences.1": "STOP"},
+			absent: []string{"frequency_penalty", "seed", "logprobs"},
+		},
+		{
+			name:   "openai to claude clamps temperature and drops top_p",
+			from:   OpenAI,
+			to:     Claude,
+			input:  `{"temperature":1.7,"top_p":0.9,"stop":"END"}`,
+			want:   map[string]any{"temperature": 1.0, "stop_sequences.0": "END"},
+			absent: []string{"top_p"},
+		},
+		{
+			name:  "openai to gemini",
+			from:  OpenAI,
+			to:    Gemini,
+			input: `{"temperature":0.4,"top_p":0.9,"top_k":40,"max_tokens":256,"stop":["a","b","c","d","e","f"],"frequency_penalty":3,"presence_penalty":-0.5,"seed":42,"logprobs":true,"top_logprobs":30}`,
+			root:  "generationConfig",
+			want: map[string]any{
+				"generationConfig.temperature":      0.4,
+				"generationConfig.topP":             0.9,
+				"generationConfig.topK":             int64(40),
+				"generationConfig.maxOutputTokens":  int64(256),
+				"generationConfig.stopSequences.#":  int64(5),
+				"generationConfig.frequencyPenalty": 2.0,
+				"generationConfig.presencePenalty":  -0.5,
+				"generationConfig.seed":             int64(42),
+				"generationConfig.responseLogprobs": true,
+				"generationConfig.logprobs":         int64(20),
+			},
+		},
+		{
+			name:  "openai to gemini cli caps max tokens at the model limit",
+			from:  OpenAI,
+			to:    Gemini,
+			model: "gemini-2.5-pro",
+			input: `{"max_completion_tokens":200000}`,
+			root:  "request.generationConfig",
+			want:  map[string]any{"request.generationConfig.maxOutputTokens": int64(65536)},
+		},
+		{
+			name:   "claude to openai",
+			from:   Claude,
+			to:     OpenAI,
+			input:  `{"temperature":0.7,"top_p":0.8,"top_k":5,"max_tokens":1024,"stop_sequences":["a","b","c","d","e"]}`,
+			want:   map[string]any{"temperature": 0.7, "top_p": 0.8, "top_k": int64(5), "max_tokens": int64(1024), "stop.#": int64(4)},
+			absent: []string{"stop_sequences"},
+		},
+		{
+			name:  "claude to gemini",
+			from:  Claude,
+			to:    Gemini,
+			input: `{"temperature":0.7,"top_p":0.8,"top_k":5,"max_tokens":1024,"stop_sequences":["END"]}`,
+			root:  "generationConfig",
+			want: map[string]any{
+				"generationConfig.temperature":     0.7,
+				"generationConfig.topP":            0.8,
+				"generationConfig.topK":            int64(5),
+				"generationConfig.maxOutputTokens": int64(1024),
+				"generationConfig.stopSequences.0": "END",
+			},
+		},
+		{
+			name:  "gemini to openai",
+			from:  Gemini,
+			to:    OpenAI,
+			input: `{"generationConfig":{"temperature":2.5,"topP":0.5,"topK":8,"maxOutputTokens":100,"stopSequences":["x"],"frequencyPenalty":0.1,"presencePenalty":0.2,"seed":3,"responseLogprobs":true,"logprobs":4}}`,
+			want: map[string]any{
+				"temperature":       2.0,
+				"top_p":             0.5,
+				"top_k":             int64(8),
+				"max_tokens":        int64(100),
+				"stop.0":            "x",
+				"frequency_penalty": 0.1,
+				"presence_penalty":  0.2,
+				"seed":              int64(3),
+				"logprobs":          true,
+				"top_logprobs":      int64(4),
+			},
+		},
+		{
+			name:   "gemini to claude",
+			from:   Gemini,
+			to:     Claude,
+			input:  `{"generationConfig":{"topP":0.5,"topK":0,"maxOutputTokens":100,"stopSequences":["x"],"presencePenalty":0.2,"seed":3}}`,
+			want:   map[string]any{"top_p": 0.5, "top_k": int64(1), "max_tokens": int64(100), "stop_sequences.0": "x"},
+			absent: []string{"temperature", "presence_penalty", "seed"},
+		},
+		{
+			name:   "invalid values are dropped",
+			from:   OpenAI,
+			to:     Gemini,
+			input:  `{"temperature":"hot","seed":null,"logprobs":"yes"}`,
+			root:   "generationConfig",
+			absent: []string{"generationConfig.temperature", "generationConfig.seed", "generationConfig.responseLogprobs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Translate(tt.from, tt.to, tt.model, []byte(tt.input), []byte(`{}`), tt.root)
+			for path, want := range tt.want {
+				got := gjson.GetBytes(out, path)
+				if !got.Exists() {
+					t.Fatalf("%s missing in %s", path, out)
+				}
+				var match bool
+				switch want := want.(type) {
+				case float64:
+					match = got.Type == gjson.Number && got.Float() == want
+				case int64:
+					match = got.Type == gjson.Number && got.Int() == want
+				case bool:
+					match = got.IsBool() && got.Bool() == want
+				case string:
+					match = got.String() == want
+				}
+				if !match {
+					t.Fatalf("%s = %s, want %v (output %s)", path, got.Raw, want, out)
+				}
+			}
+			for _, path := range tt.absent {
+				if gjson.GetBytes(out, path).Exists() {
+					t.Fatalf("%s should be absent in %s", path, out)
+				}
+			}
+		})
+	}
+}
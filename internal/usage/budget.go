@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Budget is a token allowance, such as a per-key monthly quota, charged with the total
+// input tokens of each distribution. It is safe for concurrent use by request handlers;
+// a Budget must not be copied after first use.
+type Budget struct {
+	// Limit is the allowance in total input tokens.
+	Limit int64
+
+	used atomic.Int64
+}
+
+// NewBudget returns a budget allowing limit total input tokens.
+func NewBudget(limit int64) *Budget {
+	return &Budget{Limit: limit}
+}
+
+// Charge atomically adds d.TotalInputTokens() to the tokens used and reports the
+// allowance left and whether the charge stayed within Limit. The charge is recorded
+// even when it overshoots, so the overshoot shows in Overage; remaining is clamped to
+// zero in that case. Negative totals are charged as zero.
+func (b *Budget) Charge(d CacheTokenDistribution) (remaining int64, ok bool) {
+	amount := max(d.TotalInputTokens(), 0)
+	for {
+		used := b.used.Load()
+		next := used + amount
+		if next < used {
+			next = math.MaxInt64
+		}
+		if b.used.CompareAndSwap(used, next) {
+			return b.remaining(next), next <= b.Limit
+		}
+	}
+}
+
+// Remaining returns the allowance left, or zero once the budget is exhausted.
+func (b *Budget) Remaining() int64 {
+	return b.remaining(b.used.Load())
+}
+
+// Used returns the total input tokens charged so far, including any overage.
+func (b *Budget) Used() int64 {
+	return b.used.Load()
+}
+
+// Overage returns how many tokens the charges have exceeded Limit by, or zero while
+// the budget holds.
+func (b *Budget) Overage() int64 {
+	used := b.used.Load()
+	if used <= b.Limit {
+		return 0
+	}
+	if b.Limit < 0 && used > math.MaxInt64+b.Limit {
+		return math.MaxInt64
+	}
+	return used - b.Limit
+}
+
+// Reset clears the tokens used, starting a new budget period.
+func (b *Budget) Reset() {
+	b.used.Store(0)
+}
+
+func (b *Budget) remaining(used int64) int64 {
+	if used >= b.Limit {
+		return 0
+	}
+	return b.Limit - used
+}
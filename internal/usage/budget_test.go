@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestBudget_Charge(t *testing.T) {
+	b := NewBudget(1500)
+
+	remaining, ok := b.Charge(DistributeCacheTokens(1000))
+	if !ok || remaining != 500 {
+		t.Fatalf("first charge = %d, %t; want 500, true", remaining, ok)
+	}
+	remaining, ok = b.Charge(FromClaudeUsage(100, 100, 300))
+	if !ok || remaining != 0 || b.Overage() != 0 {
+		t.Fatalf("exact charge = %d, %t, overage %d; want 0, true, 0", remaining, ok, b.Overage())
+	}
+	remaining, ok = b.Charge(CacheTokenDistribution{InputTokens: 42})
+	if ok || remaining != 0 {
+		t.Fatalf("overshooting charge = %d, %t; want 0, false", remaining, ok)
+	}
+	if b.Overage() != 42 || b.Used() != 1542 {
+		t.Fatalf("overage = %d, used = %d; want 42 and 1542", b.Overage(), b.Used())
+	}
+
+	b.Reset()
+	if b.Remaining() != 1500 || b.Overage() != 0 {
+		t.Fatalf("after reset remaining = %d, overage = %d", b.Remaining(), b.Overage())
+	}
+	if remaining, ok = b.Charge(CacheTokenDistribution{InputTokens: -10}); !ok || remaining != 1500 {
+		t.Fatalf("negative charge = %d, %t; want 1500, true", remaining, ok)
+	}
+}
+
+func TestBudget_ChargeSaturates(t *testing.T) {
+	b := &Budget{Limit: 10}
+	b.Charge(CacheTokenDistribution{InputTokens: math.MaxInt64})
+	if _, ok := b.Charge(CacheTokenDistribution{InputTokens: math.MaxInt64}); ok {
+		t.Fatalf("expected the saturated charge to exceed the budget")
+	}
+	if b.Used() != math.MaxInt64 || b.Overage() != math.MaxInt64-10 {
+		t.Fatalf("used = %d, overage = %d", b.Used(), b.Overage())
+	}
+}
+
+func TestBudget_ConcurrentCharges(t *testing.T) {
+	const workers, charges = 16, 500
+	b := NewBudget(workers * charges * 10 / 2)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range charges {
+				if _, ok := b.Charge(CacheTokenDistribution{InputTokens: 10}); ok {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if b.Used() != workers*charges*10 {
+		t.Fatalf("used = %d, want %d", b.Used(), workers*charges*10)
+	}
+	if accepted != workers*charges/2 {
+		t.Fatalf("accepted %d charges, want exactly %d to fit the budget", accepted, workers*charges/2)
+	}
+	if b.Overage() != b.Limit || b.Remaining() != 0 {
+		t.Fatalf("overage = %d, remaining = %d", b.Overage(), b.Remaining())
+	}
+}
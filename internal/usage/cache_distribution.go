@@ -0,0 +1,1456 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Kiro does not report prompt cache usage, so the proxy synthesizes a Claude-style
+// breakdown by splitting the total input token count into uncached input, cache
+// creation and cache read buckets, using a 1:2:25 ratio unless configured otherwise.
+const (
+	// DistributionThreshold is the minimum total input token count for which cache
+	// distribution is applied. Smaller requests are reported entirely as input tokens.
+	DistributionThreshold int64 = 100
+
+	inputRatioPart    = 1
+	creationRatioPart = 2
+	readRatioPart     = 25
+)
+
+// CacheTokenDistribution holds the input token breakdown reported to Claude clients.
+//
+// It uses the default gob encoding, which matches fields by name: fields added later
+// decode as zero from older senders and are skipped by older receivers.
+type CacheTokenDistribution struct {
+	InputTokens              int64 `json:"input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+
+	// CacheCreation optionally splits CacheCreationInputTokens by cache TTL, as in
+	// Claude's usage.cache_creation object. The zero value means no split, and only
+	// cache_creation_input_tokens is emitted.
+	CacheCreation CacheCreation `json:"cache_creation"`
+}
+
+// CacheCreation splits cache creation tokens between Claude's 5-minute and 1-hour
+// cache TTL tiers, which are billed differently.
+type CacheCreation struct {
+	Ephemeral5mInputTokens int64 `json:"ephemeral_5m_input_tokens"`
+	Ephemeral1hInputTokens int64 `json:"ephemeral_1h_input_tokens"`
+}
+
+// IsZero reports whether c holds no tier split.
+func (c CacheCreation) IsZero() bool {
+	return c == CacheCreation{}
+}
+
+// FieldDescriptor describes one JSON field of the usage object, for generating API
+// schemas such as OpenAPI components.
+type FieldDescriptor struct {
+	// Name is the Go field name in CacheTokenDistribution.
+	Name string
+	// JSONKey is the key the field is encoded under.
+	JSONKey string
+	// Type is the JSON Schema type of the field.
+	Type        string
+	Description string
+}
+
+// distributionFieldDescriptions documents the distribution buckets by Go field name.
+var distributionFieldDescriptions = map[string]string{
+	"InputTokens":              "Input tokens not read from or written to the prompt cache.",
+	"CacheCreationInputTokens": "Input tokens written to the prompt cache.",
+	"CacheReadInputTokens":     "Input tokens read from the prompt cache.",
+}
+
+// FieldDescriptors describes the three token buckets of CacheTokenDistribution in
+// field order. Keys and types are read from the struct itself, so they follow its
+// json tags.
+func FieldDescriptors() []FieldDescriptor {
+	t := reflect.TypeOf(CacheTokenDistribution{})
+	descriptors := make([]FieldDescriptor, 0, len(distributionFieldDescriptions))
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		description, ok := distributionFieldDescriptions[field.Name]
+		if !ok {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		descriptors = append(descriptors, FieldDescriptor{
+			Name:        field.Name,
+			JSONKey:     key,
+			Type:        jsonSchemaType(field.Type.Kind()),
+			Description: description,
+		})
+	}
+	return descriptors
+}
+
+// jsonSchemaType maps a Go kind to its JSON Schema type.
+func jsonSchemaType(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// claudeUsageJSON mirrors the Claude usage object. The cache fields are pointers so
+// they can be omitted entirely, as Claude does below its caching threshold.
+type claudeUsageJSON struct {
+	InputTokens              int64          `json:"input_tokens"`
+	CacheCreationInputTokens *int64         `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     *int64         `json:"cache_read_input_tokens,omitempty"`
+	CacheCreation            *CacheCreation `json:"cache_creation,omitempty"`
+	OutputTokens             *int64         `json:"output_tokens,omitempty"`
+}
+
+func (d CacheTokenDistribution) claudeJSON() claudeUsageJSON {
+	out := claudeUsageJSON{InputTokens: d.InputTokens}
+	if d.HasCacheTokens() {
+		out.CacheCreationInputTokens = &d.CacheCreationInputTokens
+		out.CacheReadInputTokens = &d.CacheReadInputTokens
+	}
+	if !d.CacheCreation.IsZero() {
+		out.CacheCreation = &d.CacheCreation
+	}
+	return out
+}
+
+// MarshalJSON encodes d with Claude's snake_case usage field names. The cache fields
+// are omitted when both are zero, so a sub-threshold distribution encodes as
+// {"input_tokens":42}; cache_creation is only emitted when d carries a TTL split.
+func (d CacheTokenDistribution) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.claudeJSON())
+}
+
+// UnmarshalJSON decodes a Claude usage object into d. Each bucket may be a JSON number
+// or a string-encoded integer such as "1000", as some upstream gateways send; null or
+// absent buckets decode as zero, as does an absent cache_creation TTL split. Values
+// that are not integers are rejected with an error naming the field.
+func (d *CacheTokenDistribution) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		InputTokens              json.RawMessage `json:"input_tokens"`
+		CacheCreationInputTokens json.RawMessage `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     json.RawMessage `json:"cache_read_input_tokens"`
+		CacheCreation            struct {
+			Ephemeral5mInputTokens json.RawMessage `json:"ephemeral_5m_input_tokens"`
+			Ephemeral1hInputTokens json.RawMessage `json:"ephemeral_1h_input_tokens"`
+		} `json:"cache_creation"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var out CacheTokenDistribution
+	fields := []struct {
+		name  string
+		raw   json.RawMessage
+		value *int64
+	}{
+		{"input_tokens", raw.InputTokens, &out.InputTokens},
+		{"cache_creation_input_tokens", raw.CacheCreationInputTokens, &out.CacheCreationInputTokens},
+		{"cache_read_input_tokens", raw.CacheReadInputTokens, &out.CacheReadInputTokens},
+		{"cache_creation.ephemeral_5m_input_tokens", raw.CacheCreation.Ephemeral5mInputTokens, &out.CacheCreation.Ephemeral5mInputTokens},
+		{"cache_creation.ephemeral_1h_input_tokens", raw.CacheCreation.Ephemeral1hInputTokens, &out.CacheCreation.Ephemeral1hInputTokens},
+	}
+	for _, field := range fields {
+		value, err := parseTokenCount(field.raw)
+		if err != nil {
+			return fmt.Errorf("usage: cache distribution %s: %w", field.name, err)
+		}
+		*field.value = value
+	}
+	*d = out
+	return nil
+}
+
+// parseTokenCount parses a token count encoded as a JSON integer or as a string
+// holding one. Empty or null input yields zero.
+func parseTokenCount(raw json.RawMessage) (int64, error) {
+	text := strings.TrimSpace(string(raw))
+	if text == "" || text == "null" {
+		return 0, nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		var unquoted string
+		if err := json.Unmarshal(raw, &unquoted); err != nil {
+			return 0, err
+		}
+		text = strings.TrimSpace(unquoted)
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer, got %s", string(raw))
+	}
+	return value, nil
+}
+
+// Validate reports an error naming the first negative bucket of d, or the buckets
+// when their sum overflows int64, or a TTL split that does not add up to the cache
+// creation tokens. Distributions parsed from upstream JSON should be validated before
+// they are marshaled back out.
+func (d CacheTokenDistribution) Validate() error {
+	fields := []struct {
+		name  string
+		value int64
+	}{
+		{"input_tokens", d.InputTokens},
+		{"cache_creation_input_tokens", d.CacheCreationInputTokens},
+		{"cache_read_input_tokens", d.CacheReadInputTokens},
+		{"cache_creation.ephemeral_5m_input_tokens", d.CacheCreation.Ephemeral5mInputTokens},
+		{"cache_creation.ephemeral_1h_input_tokens", d.CacheCreation.Ephemeral1hInputTokens},
+	}
+	for _, field := range fields {
+		if field.value < 0 {
+			return fmt.Errorf("usage: cache distribution %s must not be negative, got %d", field.name, field.value)
+		}
+	}
+	if d.InputTokens > math.MaxInt64-d.CacheCreationInputTokens || d.InputTokens+d.CacheCreationInputTokens > math.MaxInt64-d.CacheReadInputTokens {
+		return fmt.Errorf("usage: cache distribution total overflows, got %d+%d+%d", d.InputTokens, d.CacheCreationInputTokens, d.CacheReadInputTokens)
+	}
+	// The tiers are compared by subtraction, since adding them could overflow.
+	if tiers := d.CacheCreation; !tiers.IsZero() && tiers.Ephemeral5mInputTokens != d.CacheCreationInputTokens-tiers.Ephemeral1hInputTokens {
+		return fmt.Errorf("usage: cache distribution cache_creation tiers %d+%d do not sum to cache_creation_input_tokens %d", tiers.Ephemeral5mInputTokens, tiers.Ephemeral1hInputTokens, d.CacheCreationInputTokens)
+	}
+	return nil
+}
+
+// TotalInputTokens returns the sum of all input token buckets.
+func (d CacheTokenDistribution) TotalInputTokens() int64 {
+	return d.InputTokens + d.CacheCreationInputTokens + d.CacheReadInputTokens
+}
+
+// RoundingResidual returns originalTotal minus d.TotalInputTokens(). Distributions
+// produced by Distribute for a non-negative total always sum back to it, so a non-zero
+// residual flags a distribution built or edited by other means. Negative totals
+// distribute to the zero value and therefore leave originalTotal as the residual.
+func (d CacheTokenDistribution) RoundingResidual(originalTotal int64) int64 {
+	return originalTotal - d.TotalInputTokens()
+}
+
+// Normalize returns d adjusted to sum to originalTotal again after one of its buckets
+// was edited: input and creation are kept, clamped so they fit within the total in
+// that order, and cache read becomes the remainder, clamping to zero when input and
+// creation already use the whole total. A TTL split is trimmed from the 5-minute tier
+// first so it keeps matching creation. Negative totals normalize to the zero value.
+// Normalize is idempotent.
+func (d CacheTokenDistribution) Normalize(originalTotal int64) CacheTokenDistribution {
+	total := max(originalTotal, 0)
+	out := CacheTokenDistribution{InputTokens: min(max(d.InputTokens, 0), total)}
+	out.CacheCreationInputTokens = min(max(d.CacheCreationInputTokens, 0), total-out.InputTokens)
+	out.CacheReadInputTokens = total - out.InputTokens - out.CacheCreationInputTokens
+	if !d.CacheCreation.IsZero() {
+		oneHour := min(max(d.CacheCreation.Ephemeral1hInputTokens, 0), out.CacheCreationInputTokens)
+		out.CacheCreation = CacheCreation{Ephemeral5mInputTokens: out.CacheCreationInputTokens - oneHour, Ephemeral1hInputTokens: oneHour}
+	}
+	return out
+}
+
+// String formats d as "input=35 creation=71 read=894 total=1000" for compact,
+// greppable log lines.
+func (d CacheTokenDistribution) String() string {
+	return fmt.Sprintf("input=%d creation=%d read=%d total=%d", d.InputTokens, d.CacheCreationInputTokens, d.CacheReadInputTokens, d.TotalInputTokens())
+}
+
+// CacheHitRate returns the fraction of input tokens served from cache, in [0, 1].
+// It returns 0 when d has no input tokens.
+func (d CacheTokenDistribution) CacheHitRate() float64 {
+	return d.inputFraction(d.CacheReadInputTokens)
+}
+
+// CacheCreationRate returns the fraction of input tokens written to cache, in [0, 1].
+// It returns 0 when d has no input tokens.
+func (d CacheTokenDistribution) CacheCreationRate() float64 {
+	return d.inputFraction(d.CacheCreationInputTokens)
+}
+
+func (d CacheTokenDistribution) inputFraction(tokens int64) float64 {
+	total := d.TotalInputTokens()
+	if total <= 0 {
+		return 0
+	}
+	return float64(tokens) / float64(total)
+}
+
+// HasCacheTokens reports whether any tokens were assigned to the cache buckets.
+func (d CacheTokenDistribution) HasCacheTokens() bool {
+	return d.CacheCreationInputTokens > 0 || d.CacheReadInputTokens > 0
+}
+
+// CollapseToInput returns the single input total represented by a Claude usage
+// breakdown, undoing a distribution so it can be re-distributed with another ratio.
+func CollapseToInput(input, cacheCreation, cacheRead int64) int64 {
+	return input + cacheCreation + cacheRead
+}
+
+// FromClaudeUsage builds a distribution from the three Claude usage fields as reported,
+// without re-applying any ratio.
+func FromClaudeUsage(input, cacheCreation, cacheRead int64) CacheTokenDistribution {
+	return CacheTokenDistribution{
+		InputTokens:              input,
+		CacheCreationInputTokens: cacheCreation,
+		CacheReadInputTokens:     cacheRead,
+	}
+}
+
+// FromGeminiUsage builds a distribution from Gemini's promptTokenCount and
+// cachedContentTokenCount. Gemini reports cached content as part of the prompt, so
+// the cached tokens become cache reads and only the remainder is uncached input.
+// Gemini has no cache creation concept, so that bucket stays zero. Cached counts
+// larger than the prompt clamp input to zero.
+func FromGeminiUsage(promptTokens, cachedTokens int64) CacheTokenDistribution {
+	cachedTokens = max(cachedTokens, 0)
+	return CacheTokenDistribution{
+		InputTokens:          max(promptTokens-cachedTokens, 0),
+		CacheReadInputTokens: cachedTokens,
+	}
+}
+
+// FromOpenAIUsage builds a distribution from OpenAI's prompt_tokens and
+// prompt_tokens_details.cached_tokens. Like Gemini, OpenAI counts cached tokens as
+// part of the prompt, so they become cache reads and the remainder is uncached input.
+// An absent cached_tokens field should be passed as zero. Cached counts larger than
+// the prompt clamp input to zero.
+func FromOpenAIUsage(promptTokens, cachedTokens int64) CacheTokenDistribution {
+	return FromGeminiUsage(promptTokens, cachedTokens)
+}
+
+// ToProtoFields returns the three buckets as the uint64 fields of the usage proto
+// message. Negative buckets, which proto unsigned fields cannot carry, are clamped to
+// zero.
+func (d CacheTokenDistribution) ToProtoFields() (input, cacheCreation, cacheRead uint64) {
+	return uint64(max(d.InputTokens, 0)), uint64(max(d.CacheCreationInputTokens, 0)), uint64(max(d.CacheReadInputTokens, 0))
+}
+
+// FromProtoFields builds a distribution from the uint64 fields of the usage proto
+// message. Values above math.MaxInt64 saturate.
+func FromProtoFields(input, cacheCreation, cacheRead uint64) CacheTokenDistribution {
+	return CacheTokenDistribution{
+		InputTokens:              protoTokenCount(input),
+		CacheCreationInputTokens: protoTokenCount(cacheCreation),
+		CacheReadInputTokens:     protoTokenCount(cacheRead),
+	}
+}
+
+func protoTokenCount(v uint64) int64 {
+	if v > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(v)
+}
+
+// Add merges other into d by summing each bucket independently. It does NOT
+// re-normalize the result to any ratio, so already-distributed chunks of a streaming
+// response can be accumulated without re-applying the distribution.
+func (d CacheTokenDistribution) Add(other CacheTokenDistribution) CacheTokenDistribution {
+	return CacheTokenDistribution{
+		InputTokens:              d.InputTokens + other.InputTokens,
+		CacheCreationInputTokens: d.CacheCreationInputTokens + other.CacheCreationInputTokens,
+		CacheReadInputTokens:     d.CacheReadInputTokens + other.CacheReadInputTokens,
+		CacheCreation: CacheCreation{
+			Ephemeral5mInputTokens: d.CacheCreation.Ephemeral5mInputTokens + other.CacheCreation.Ephemeral5mInputTokens,
+			Ephemeral1hInputTokens: d.CacheCreation.Ephemeral1hInputTokens + other.CacheCreation.Ephemeral1hInputTokens,
+		},
+	}
+}
+
+// Sub returns the per-bucket increase of d over earlier, for computing usage deltas
+// between snapshots. Buckets where earlier is larger, as happens when snapshots
+// arrive out of order due to clock skew, are clamped to zero. Sub is the inverse of
+// Add only when no bucket is clamped.
+func (d CacheTokenDistribution) Sub(earlier CacheTokenDistribution) CacheTokenDistribution {
+	return CacheTokenDistribution{
+		InputTokens:              max(d.InputTokens-earlier.InputTokens, 0),
+		CacheCreationInputTokens: max(d.CacheCreationInputTokens-earlier.CacheCreationInputTokens, 0),
+		CacheReadInputTokens:     max(d.CacheReadInputTokens-earlier.CacheReadInputTokens, 0),
+		CacheCreation: CacheCreation{
+			Ephemeral5mInputTokens: max(d.CacheCreation.Ephemeral5mInputTokens-earlier.CacheCreation.Ephemeral5mInputTokens, 0),
+			Ephemeral1hInputTokens: max(d.CacheCreation.Ephemeral1hInputTokens-earlier.CacheCreation.Ephemeral1hInputTokens, 0),
+		},
+	}
+}
+
+// Scale returns d with every bucket multiplied by factor, for capacity estimates such
+// as "what if traffic doubled". Input and creation are rounded to nearest, halves
+// rounding up, and cache read takes the residual so the result sums to the scaled and
+// rounded total of d; if rounding overshoots that total, creation and then input give
+// the excess back. A TTL split is scaled along with creation and its residual lands in
+// the 5-minute tier. Negative and NaN factors are clamped to zero. Scaling a
+// distribution that passes Validate by 1 returns it unchanged while its total stays
+// below 2^53, where float64 is exact.
+func (d CacheTokenDistribution) Scale(factor float64) CacheTokenDistribution {
+	if math.IsNaN(factor) || factor <= 0 {
+		return CacheTokenDistribution{}
+	}
+	total := scaleTokens(d.TotalInputTokens(), factor)
+	out := CacheTokenDistribution{
+		InputTokens:              scaleTokens(d.InputTokens, factor),
+		CacheCreationInputTokens: scaleTokens(d.CacheCreationInputTokens, factor),
+	}
+	out.CacheReadInputTokens = total - out.InputTokens - out.CacheCreationInputTokens
+	if out.CacheReadInputTokens < 0 {
+		out.CacheCreationInputTokens += out.CacheReadInputTokens
+		out.CacheReadInputTokens = 0
+		if out.CacheCreationInputTokens < 0 {
+			out.InputTokens += out.CacheCreationInputTokens
+			out.CacheCreationInputTokens = 0
+		}
+	}
+	if !d.CacheCreation.IsZero() {
+		oneHour := min(scaleTokens(d.CacheCreation.Ephemeral1hInputTokens, factor), out.CacheCreationInputTokens)
+		out.CacheCreation = CacheCreation{Ephemeral5mInputTokens: out.CacheCreationInputTokens - oneHour, Ephemeral1hInputTokens: oneHour}
+	}
+	return out
+}
+
+// scaleTokens returns tokens*factor rounded to nearest, halves rounding up, saturating
+// at the int64 range.
+func scaleTokens(tokens int64, factor float64) int64 {
+	v := math.Floor(float64(tokens)*factor + 0.5)
+	switch {
+	case v >= math.MaxInt64:
+		return math.MaxInt64
+	case v <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(v)
+}
+
+// EqualWithin reports whether every bucket of d, including the cache creation TTL
+// tiers, differs from the same bucket of other by at most tolerance tokens, for golden
+// comparisons that allow upstream rounding slop. A tolerance of 0 is exact equality; a
+// negative tolerance is treated as 0.
+func (d CacheTokenDistribution) EqualWithin(other CacheTokenDistribution, tolerance int64) bool {
+	tolerance = max(tolerance, 0)
+	return withinTolerance(d.InputTokens, other.InputTokens, tolerance) &&
+		withinTolerance(d.CacheCreationInputTokens, other.CacheCreationInputTokens, tolerance) &&
+		withinTolerance(d.CacheReadInputTokens, other.CacheReadInputTokens, tolerance) &&
+		withinTolerance(d.CacheCreation.Ephemeral5mInputTokens, other.CacheCreation.Ephemeral5mInputTokens, tolerance) &&
+		withinTolerance(d.CacheCreation.Ephemeral1hInputTokens, other.CacheCreation.Ephemeral1hInputTokens, tolerance)
+}
+
+// withinTolerance reports whether |a-b| <= tolerance without overflowing int64.
+func withinTolerance(a, b, tolerance int64) bool {
+	if a < b {
+		a, b = b, a
+	}
+	return uint64(a)-uint64(b) <= uint64(tolerance)
+}
+
+// Sum merges dists with Add. It returns the zero value when dists is empty.
+func Sum(dists ...CacheTokenDistribution) CacheTokenDistribution {
+	var total CacheTokenDistribution
+	for _, d := range dists {
+		total = total.Add(d)
+	}
+	return total
+}
+
+// Ratio describes the relative weights of the input, cache creation and cache read buckets.
+type Ratio struct {
+	Input    int
+	Creation int
+	Read     int
+}
+
+// defaultRatio is the 1:2:25 ratio used when no model-specific ratio is configured.
+var defaultRatio = Ratio{Input: inputRatioPart, Creation: creationRatioPart, Read: readRatioPart}
+
+// DistributionError reports a cache distribution misconfiguration. Field names the
+// offending setting as it is spelled in the config file ("input-part", "threshold",
+// ...), or "ratio" when the parts are only invalid together. The validation and
+// constructor paths of Ratio, DistributionConfig and Distributor return it as a
+// *DistributionError, which callers may wrap and recover with errors.As.
+type DistributionError struct {
+	Field  string
+	Reason string
+}
+
+// Error implements error.
+func (e *DistributionError) Error() string {
+	return fmt.Sprintf("usage: cache distribution %s: %s", e.Field, e.Reason)
+}
+
+func distributionError(field, format string, args ...any) *DistributionError {
+	return &DistributionError{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Validate reports a *DistributionError when any ratio part is not positive.
+func (r Ratio) Validate() error {
+	if r.Input <= 0 {
+		return distributionError("input-part", "must be positive, got %d", r.Input)
+	}
+	if r.Creation <= 0 {
+		return distributionError("creation-part", "must be positive, got %d", r.Creation)
+	}
+	if r.Read <= 0 {
+		return distributionError("read-part", "must be positive, got %d", r.Read)
+	}
+	if r.sum() <= 0 {
+		return distributionError("ratio", "parts must have a positive sum, got %d:%d:%d", r.Input, r.Creation, r.Read)
+	}
+	return nil
+}
+
+func (r Ratio) sum() int64 {
+	return int64(r.Input) + int64(r.Creation) + int64(r.Read)
+}
+
+// ParseRatio parses a colon-separated "input:creation:read" ratio such as "1:2:25",
+// as written in config strings, into a Ratio for NewDistributor or WithRatio. It
+// reports a *DistributionError when s does not have exactly three parts, when a part
+// is not an integer, or when the parsed ratio fails Validate.
+func ParseRatio(s string) (Ratio, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return Ratio{}, distributionError("ratio", "want input:creation:read, got %q", s)
+	}
+	var values [3]int
+	for i, part := range parts {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return Ratio{}, distributionError("ratio", "part %q of %q is not an integer", part, s)
+		}
+		values[i] = value
+	}
+	ratio := Ratio{Input: values[0], Creation: values[1], Read: values[2]}
+	if err := ratio.Validate(); err != nil {
+		return Ratio{}, err
+	}
+	return ratio, nil
+}
+
+// DistributionConfig configures a cache token distribution: the relative weights of
+// the three buckets and the minimum total at which distribution applies.
+type DistributionConfig struct {
+	InputPart    int64
+	CreationPart int64
+	ReadPart     int64
+	Threshold    int64
+}
+
+// DefaultDistributionConfig returns the default 1:2:25 configuration with the standard threshold.
+func DefaultDistributionConfig() DistributionConfig {
+	return DistributionConfig{
+		InputPart:    inputRatioPart,
+		CreationPart: creationRatioPart,
+		ReadPart:     readRatioPart,
+		Threshold:    DistributionThreshold,
+	}
+}
+
+// Validate reports a *DistributionError when any ratio part is not positive or the
+// threshold is negative.
+func (c DistributionConfig) Validate() error {
+	for _, part := range []struct {
+		field string
+		value int64
+	}{{"input-part", c.InputPart}, {"creation-part", c.CreationPart}, {"read-part", c.ReadPart}} {
+		if part.value <= 0 {
+			return distributionError(part.field, "must be positive, got %d", part.value)
+		}
+	}
+	if c.CreationPart > math.MaxInt64-c.ReadPart || c.InputPart > math.MaxInt64-c.CreationPart-c.ReadPart {
+		return distributionError("ratio", "parts overflow, got %d:%d:%d", c.InputPart, c.CreationPart, c.ReadPart)
+	}
+	if c.Threshold < 0 {
+		return distributionError("threshold", "must not be negative, got %d", c.Threshold)
+	}
+	return nil
+}
+
+// onDistribute holds the hook installed by SetOnDistribute.
+var onDistribute atomic.Pointer[func(total int64, d CacheTokenDistribution)]
+
+// SetOnDistribute installs hook to observe every distribution with its total and
+// result, letting tracing or metrics hook in without this package importing them. All
+// entry points report to it, since they all end in a Distributor method. It is called
+// synchronously on the request path, so it must be cheap and must not block, and it may
+// be called concurrently. A nil hook removes the current one.
+func SetOnDistribute(hook func(total int64, d CacheTokenDistribution)) {
+	if hook == nil {
+		onDistribute.Store(nil)
+		return
+	}
+	onDistribute.Store(&hook)
+}
+
+// notifyDistribute reports a distribution to the hook installed by SetOnDistribute.
+func notifyDistribute(total int64, d CacheTokenDistribution) {
+	if hook := onDistribute.Load(); hook != nil {
+		(*hook)(total, d)
+	}
+}
+
+// DistributeCacheTokens splits totalInputTokens using the default 1:2:25 ratio.
+// Totals below DistributionThreshold are returned entirely as input tokens and
+// negative totals yield a zero-value distribution.
+func DistributeCacheTokens(totalInputTokens int64) CacheTokenDistribution {
+	return DefaultDistributor().Distribute(totalInputTokens)
+}
+
+// DistributeInto writes the DistributeCacheTokens split of total into out, so hot
+// paths can reuse a caller-owned or pooled value. A nil out is ignored.
+func DistributeInto(total int64, out *CacheTokenDistribution) {
+	if out == nil {
+		return
+	}
+	*out = DefaultDistributor().Distribute(total)
+}
+
+// DistributeWithKnownCacheRead splits total with the default distributor, keeping the
+// upstream-reported knownCacheRead; see Distributor.DistributeWithKnownCacheRead.
+func DistributeWithKnownCacheRead(total, knownCacheRead int64) CacheTokenDistribution {
+	return DefaultDistributor().DistributeWithKnownCacheRead(total, knownCacheRead)
+}
+
+// DistributeBootstrap splits total with the default distributor as the first request of
+// a session; see Distributor.DistributeBootstrap.
+func DistributeBootstrap(total int64) CacheTokenDistribution {
+	return DefaultDistributor().DistributeBootstrap(total)
+}
+
+// DistributeFull returns DistributeCacheTokens(totalInput) together with output, so a
+// response handler can build its full usage in one call. Output tokens are never
+// distributed: output is returned exactly as given, and only input tokens are split
+// into cache buckets.
+func DistributeFull(totalInput, output int64) (CacheTokenDistribution, int64) {
+	return DistributeCacheTokens(totalInput), output
+}
+
+// DeltaAccumulator collects usage that arrives incrementally, such as the usage of
+// Claude message_delta events in an SSE stream. Current distributes the running input
+// total rather than each delta, so after the last delta it matches distributing the
+// grand total in one call. Output tokens are summed and never distributed. The zero
+// value uses the default distributor. A DeltaAccumulator is not safe for concurrent use.
+type DeltaAccumulator struct {
+	distributor *Distributor
+	input       int64
+	output      int64
+}
+
+// NewDeltaAccumulator returns an accumulator that distributes with d.
+func NewDeltaAccumulator(d Distributor) *DeltaAccumulator {
+	return &DeltaAccumulator{distributor: &d}
+}
+
+// Observe adds one usage delta to the running totals.
+func (a *DeltaAccumulator) Observe(inputDelta, outputDelta int64) {
+	a.input += inputDelta
+	a.output += outputDelta
+}
+
+// Current returns the distribution of the input observed so far and the output total.
+func (a *DeltaAccumulator) Current() (CacheTokenDistribution, int64) {
+	if a.distributor == nil {
+		return DefaultDistributor().Distribute(a.input), a.output
+	}
+	return a.distributor.Distribute(a.input), a.output
+}
+
+// DistributeBatch applies DistributeCacheTokens to each per-prompt total of a batch
+// request, preserving order. It always returns a non-nil slice, including for nil input.
+func DistributeBatch(totals []int64) []CacheTokenDistribution {
+	out := make([]CacheTokenDistribution, len(totals))
+	d := DefaultDistributor()
+	for i, total := range totals {
+		out[i] = d.Distribute(total)
+	}
+	return out
+}
+
+// DistributeStable distributes the total of segments once with DistributeCacheTokens
+// and apportions each bucket across the segments by largest remainder, preserving
+// order. Distributing segments one by one floors each of them separately and drifts
+// from the whole; here Sum(DistributeStable(segments)...) always equals
+// DistributeCacheTokens of their sum, and each result totals its segment. Negative
+// segments count as zero. Segments whose sum overflows int64 are not supported.
+func DistributeStable(segments []int64) []CacheTokenDistribution {
+	out := make([]CacheTokenDistribution, len(segments))
+	sizes := make([]int64, len(segments))
+	var total int64
+	for i, segment := range segments {
+		sizes[i] = max(segment, 0)
+		total += sizes[i]
+	}
+	if total == 0 {
+		return out
+	}
+
+	whole := DistributeCacheTokens(total)
+	// remaining tracks the room left in each segment, so input and creation never
+	// crowd out a segment and cache read takes exactly what is left.
+	remaining := append([]int64(nil), sizes...)
+	input := apportion(whole.InputTokens, sizes, total, remaining)
+	for i := range out {
+		out[i].InputTokens = input[i]
+		remaining[i] -= input[i]
+	}
+	creation := apportion(whole.CacheCreationInputTokens, sizes, total, remaining)
+	for i := range out {
+		out[i].CacheCreationInputTokens = creation[i]
+		out[i].CacheReadInputTokens = remaining[i] - creation[i]
+	}
+	return out
+}
+
+// apportion splits amount across weights, which sum to total, giving each its floored
+// share and handing the leftover out one token at a time by largest remainder, with
+// ties going to the earlier weight. No share exceeds its capacity.
+func apportion(amount int64, weights []int64, total int64, capacity []int64) []int64 {
+	shares := make([]int64, len(weights))
+	remainders := make([]int64, len(weights))
+	left := amount
+	for i, weight := range weights {
+		hi, lo := bits.Mul64(uint64(amount), uint64(weight))
+		quo, rem := bits.Div64(hi, lo, uint64(total))
+		shares[i] = min(int64(quo), capacity[i])
+		remainders[i] = int64(rem)
+		left -= shares[i]
+	}
+	if left == 0 {
+		return shares
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order {
+		if left == 0 {
+			return shares
+		}
+		if remainders[i] > 0 && shares[i] < capacity[i] {
+			shares[i]++
+			left--
+		}
+	}
+	// Capacities can push tokens out of the segments their remainders point at, which
+	// only happens for tiny segments; place them wherever there is still room.
+	for _, i := range order {
+		if left == 0 {
+			break
+		}
+		extra := min(left, capacity[i]-shares[i])
+		shares[i] += extra
+		left -= extra
+	}
+	return shares
+}
+
+// DistributeCacheTokensWithRatio splits totalInputTokens using the given ratio parts.
+// An invalid ratio returns the undistributed total together with the validation error.
+func DistributeCacheTokensWithRatio(totalInputTokens int64, input, creation, read int) (CacheTokenDistribution, error) {
+	ratio := Ratio{Input: input, Creation: creation, Read: read}
+	if err := ratio.Validate(); err != nil {
+		return CacheTokenDistribution{InputTokens: max(totalInputTokens, 0)}, err
+	}
+	return DistributeCacheTokensWith(ratio.config(DistributionThreshold), totalInputTokens), nil
+}
+
+// DistributeCacheTokensWithTTL distributes totalInputTokens like DistributeCacheTokens
+// and splits the cache creation bucket between the TTL tiers, oneHourWeight being the
+// share written to the 1-hour cache. A weight outside [0, 1] returns the untiered
+// distribution together with an error.
+func DistributeCacheTokensWithTTL(totalInputTokens int64, oneHourWeight float64) (CacheTokenDistribution, error) {
+	return DistributeCacheTokens(totalInputTokens).SplitCacheCreation(oneHourWeight)
+}
+
+// SplitCacheCreation returns d with CacheCreationInputTokens split between the TTL
+// tiers: oneHourWeight of it, rounded to nearest, goes to the 1-hour tier and the rest
+// to the 5-minute tier, so the tiers always sum to CacheCreationInputTokens. A weight
+// outside [0, 1] returns d unchanged together with an error.
+func (d CacheTokenDistribution) SplitCacheCreation(oneHourWeight float64) (CacheTokenDistribution, error) {
+	if math.IsNaN(oneHourWeight) || oneHourWeight < 0 || oneHourWeight > 1 {
+		return d, distributionError("one-hour-weight", "must be in [0, 1], got %v", oneHourWeight)
+	}
+	creation := max(d.CacheCreationInputTokens, 0)
+	oneHour := scaleRoundedFloat(creation, oneHourWeight, RoundNearest)
+	d.CacheCreation = CacheCreation{Ephemeral5mInputTokens: creation - oneHour, Ephemeral1hInputTokens: oneHour}
+	return d, nil
+}
+
+// DistributeCacheTokensWith splits tokens according to cfg. The input and creation
+// buckets are floor-divided and the remainder is assigned to cache read, so
+// TotalInputTokens always equals tokens. Totals below cfg.Threshold, or an invalid
+// cfg, are returned entirely as input tokens; negative totals are clamped to zero.
+func DistributeCacheTokensWith(cfg DistributionConfig, tokens int64) CacheTokenDistribution {
+	return cfg.distributor(RoundFloor).Distribute(tokens)
+}
+
+// RoundingMode selects how the input and creation buckets are rounded.
+// The remainder always lands in cache read regardless of mode.
+type RoundingMode int
+
+const (
+	// RoundFloor truncates the input and creation buckets toward zero.
+	RoundFloor RoundingMode = iota
+	// RoundNearest rounds the input and creation buckets to the nearest integer, halves rounding up.
+	RoundNearest
+	// RoundCeil rounds the input and creation buckets up.
+	RoundCeil
+)
+
+// DistributeCacheTokensMode splits totalInputTokens using the default 1:2:25 ratio
+// and the given rounding mode for the input and creation buckets.
+func DistributeCacheTokensMode(totalInputTokens int64, mode RoundingMode) CacheTokenDistribution {
+	d := DefaultDistributor()
+	d.Rounding = mode
+	return d.Distribute(totalInputTokens)
+}
+
+func distributeWithMode(cfg DistributionConfig, tokens int64, mode RoundingMode) CacheTokenDistribution {
+	return cfg.distributor(mode).Distribute(tokens)
+}
+
+// Distributor splits input token totals into simulated cache buckets. Instances are
+// independent, so each proxy instance or provider can carry its own threshold and ratio.
+//
+// Distribution applies when the total is at least Threshold: a total exactly equal to
+// Threshold is distributed, anything below it is reported entirely as input tokens.
+// The ratio parts only shape totals at or above the threshold.
+type Distributor struct {
+	// Threshold is the minimum total that is distributed. Zero distributes every positive total.
+	Threshold int64
+	// InputPart, CreationPart and ReadPart are the relative bucket weights; all must be
+	// positive and finite. They need not be whole: 0.1:0.3:9.6 and 1:3:96 are equivalent.
+	InputPart    float64
+	CreationPart float64
+	ReadPart     float64
+	// Rounding selects how the input and creation buckets are rounded.
+	Rounding RoundingMode
+	// MinCacheRead is the smallest cache read reported for a distributed total. A
+	// shortfall is taken from input first, then creation; totals below the floor are
+	// reported entirely as cache read. Zero disables the floor.
+	MinCacheRead int64
+	// PassThrough disables cache simulation for upstreams without prompt caching: every
+	// total is reported as input tokens regardless of Threshold and the ratio parts.
+	PassThrough bool
+}
+
+// DefaultRatio returns the parts of the default 1:2:25 input:creation:read ratio, so
+// plugins can replicate the default distribution without hardcoding it.
+func DefaultRatio() (input, creation, read int) {
+	return inputRatioPart, creationRatioPart, readRatioPart
+}
+
+// DefaultThreshold returns the minimum total input token count at which the default
+// distribution applies; it equals DistributionThreshold.
+func DefaultThreshold() int64 {
+	return DistributionThreshold
+}
+
+// DefaultDistributor returns the 1:2:25 distributor with the standard threshold and floor rounding.
+func DefaultDistributor() Distributor {
+	return DefaultDistributionConfig().distributor(RoundFloor)
+}
+
+// Option configures a Distributor built by NewDistributor.
+type Option func(*distributorOptions)
+
+// distributorOptions accumulates the options of NewDistributor.
+type distributorOptions struct {
+	distributor Distributor
+	ratioSet    bool
+}
+
+// WithThreshold sets the minimum total that is distributed.
+func WithThreshold(threshold int64) Option {
+	return func(o *distributorOptions) { o.distributor.Threshold = threshold }
+}
+
+// WithRatio sets the input, cache creation and cache read weights.
+func WithRatio(input, creation, read int) Option {
+	return func(o *distributorOptions) {
+		o.distributor.InputPart = float64(input)
+		o.distributor.CreationPart = float64(creation)
+		o.distributor.ReadPart = float64(read)
+		o.ratioSet = true
+	}
+}
+
+// WithRounding selects how the input and creation buckets are rounded.
+func WithRounding(mode RoundingMode) Option {
+	return func(o *distributorOptions) { o.distributor.Rounding = mode }
+}
+
+// WithMinCacheRead sets the smallest cache read reported for a distributed total.
+func WithMinCacheRead(floor int64) Option {
+	return func(o *distributorOptions) { o.distributor.MinCacheRead = floor }
+}
+
+// WithPassThrough disables cache simulation, as PassThroughDistributor does.
+func WithPassThrough() Option {
+	return func(o *distributorOptions) { o.distributor.PassThrough = true }
+}
+
+// NewDistributor returns DefaultDistributor with opts applied in order. It reports a
+// *DistributionError for settings Distribute would reject or ignore: a non-positive ratio part, a
+// negative threshold or cache read floor, an unknown rounding mode, or a ratio given
+// to a pass-through distributor.
+func NewDistributor(opts ...Option) (Distributor, error) {
+	o := distributorOptions{distributor: DefaultDistributor()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	d := o.distributor
+	if d.PassThrough {
+		if o.ratioSet {
+			return Distributor{}, distributionError("pass-through", "a pass-through distributor does not take a ratio")
+		}
+		return d, nil
+	}
+	if d.Rounding < RoundFloor || d.Rounding > RoundCeil {
+		return Distributor{}, distributionError("rounding", "unknown rounding mode %d", d.Rounding)
+	}
+	if err := d.Validate(); err != nil {
+		return Distributor{}, err
+	}
+	return d, nil
+}
+
+// PassThroughDistributor returns a distributor that never simulates cache buckets.
+func PassThroughDistributor() Distributor {
+	return Distributor{PassThrough: true}
+}
+
+// Validate reports a *DistributionError when any ratio part is not positive and finite
+// or the threshold or cache read floor is negative.
+// A pass-through distributor ignores its ratio parts and threshold and is always valid.
+func (d Distributor) Validate() error {
+	if d.PassThrough {
+		return nil
+	}
+	for _, part := range []struct {
+		field string
+		value float64
+	}{{"input-part", d.InputPart}, {"creation-part", d.CreationPart}, {"read-part", d.ReadPart}} {
+		if !(part.value > 0) || math.IsInf(part.value, 0) {
+			return distributionError(part.field, "must be positive and finite, got %g", part.value)
+		}
+	}
+	if math.IsInf(d.InputPart+d.CreationPart+d.ReadPart, 0) {
+		return distributionError("ratio", "parts overflow, got %g:%g:%g", d.InputPart, d.CreationPart, d.ReadPart)
+	}
+	if d.Threshold < 0 {
+		return distributionError("threshold", "must not be negative, got %d", d.Threshold)
+	}
+	if d.MinCacheRead < 0 {
+		return distributionError("min-cache-read", "must not be negative, got %d", d.MinCacheRead)
+	}
+	return nil
+}
+
+// Distribute splits total according to d. The remainder after rounding the input and
+// creation buckets is assigned to cache read, so TotalInputTokens always equals total.
+// Totals below d.Threshold, or any total for a pass-through or invalid d, are returned
+// entirely as input tokens; negative totals yield a zero-value distribution. A
+// distributed total reports at least d.MinCacheRead cache read tokens, or all of it
+// when the floor exceeds the total.
+func (d Distributor) Distribute(total int64) CacheTokenDistribution {
+	out := d.distribute(total)
+	notifyDistribute(total, out)
+	return out
+}
+
+func (d Distributor) distribute(total int64) CacheTokenDistribution {
+	// Malformed upstream usage can yield negative totals; report nothing rather than negative buckets.
+	if total <= 0 {
+		return CacheTokenDistribution{}
+	}
+	if d.PassThrough || total < d.Threshold || d.Validate() != nil {
+		return CacheTokenDistribution{InputTokens: total}
+	}
+	var inputTokens, creationTokens int64
+	if input, creation, read, ok := d.integerParts(); ok {
+		sum := input + creation + read
+		inputTokens = scaleRounded(total, input, sum, d.Rounding)
+		creationTokens = scaleRounded(total, creation, sum, d.Rounding)
+	} else {
+		sum := d.InputPart + d.CreationPart + d.ReadPart
+		inputTokens = scaleRoundedFloat(total, d.InputPart/sum, d.Rounding)
+		creationTokens = scaleRoundedFloat(total, d.CreationPart/sum, d.Rounding)
+	}
+	// Rounding up both buckets can overshoot small totals; take the excess back from
+	// creation first, then input, so cache read never goes negative.
+	if excess := inputTokens + creationTokens - total; excess > 0 {
+		taken := min(excess, creationTokens)
+		creationTokens -= taken
+		inputTokens -= excess - taken
+	}
+	if shortfall := d.MinCacheRead - (total - inputTokens - creationTokens); shortfall > 0 {
+		taken := min(shortfall, inputTokens)
+		inputTokens -= taken
+		creationTokens -= min(shortfall-taken, creationTokens)
+	}
+	return CacheTokenDistribution{
+		InputTokens:              inputTokens,
+		CacheCreationInputTokens: creationTokens,
+		CacheReadInputTokens:     total - inputTokens - creationTokens,
+	}
+}
+
+// DistributeWithKnownCacheRead splits total when the upstream already reported the
+// real cache read: knownCacheRead is kept as the cache read bucket and only the
+// remainder is split between input and creation by their ratio parts (1:2 by default).
+// Threshold and MinCacheRead do not apply, since the cache read is not simulated. A
+// knownCacheRead above total is clamped, reporting total entirely as cache read, and a
+// negative one counts as zero. Pass-through and invalid distributors report the
+// remainder as input tokens.
+func (d Distributor) DistributeWithKnownCacheRead(total, knownCacheRead int64) CacheTokenDistribution {
+	out := d.distributeWithKnownCacheRead(total, knownCacheRead)
+	notifyDistribute(total, out)
+	return out
+}
+
+func (d Distributor) distributeWithKnownCacheRead(total, knownCacheRead int64) CacheTokenDistribution {
+	if total <= 0 {
+		return CacheTokenDistribution{}
+	}
+	read := min(max(knownCacheRead, 0), total)
+	remainder := total - read
+	if d.PassThrough || d.Validate() != nil {
+		return CacheTokenDistribution{InputTokens: remainder, CacheReadInputTokens: read}
+	}
+	var inputTokens int64
+	if input, creation, _, ok := d.integerParts(); ok {
+		inputTokens = scaleRounded(remainder, input, input+creation, d.Rounding)
+	} else {
+		inputTokens = scaleRoundedFloat(remainder, d.InputPart/(d.InputPart+d.CreationPart), d.Rounding)
+	}
+	inputTokens = min(inputTokens, remainder)
+	return CacheTokenDistribution{
+		InputTokens:              inputTokens,
+		CacheCreationInputTokens: remainder - inputTokens,
+		CacheReadInputTokens:     read,
+	}
+}
+
+// DistributeBootstrap splits total the way Kiro bills the first request of a session,
+// when the prompt is written to the cache and nothing can have been read from it yet:
+// the non-input remainder goes entirely to cache creation and cache read is zero. The
+// input bucket keeps its ratio part against creation and read combined (1:27 by
+// default), so the input share matches Distribute.
+//
+// Use it when the proxy has detected a fresh session, and Distribute for every later
+// request, whose prompt mostly replays what the session has already cached. Threshold
+// and Rounding apply as in Distribute; MinCacheRead does not. Pass-through and invalid
+// distributors report total as input tokens, and negative totals yield a zero-value
+// distribution.
+func (d Distributor) DistributeBootstrap(total int64) CacheTokenDistribution {
+	out := d.distributeBootstrap(total)
+	notifyDistribute(total, out)
+	return out
+}
+
+func (d Distributor) distributeBootstrap(total int64) CacheTokenDistribution {
+	if total <= 0 {
+		return CacheTokenDistribution{}
+	}
+	if d.PassThrough || total < d.Threshold || d.Validate() != nil {
+		return CacheTokenDistribution{InputTokens: total}
+	}
+	var inputTokens int64
+	if input, creation, read, ok := d.integerParts(); ok {
+		inputTokens = scaleRounded(total, input, input+creation+read, d.Rounding)
+	} else {
+		inputTokens = scaleRoundedFloat(total, d.InputPart/(d.InputPart+d.CreationPart+d.ReadPart), d.Rounding)
+	}
+	inputTokens = min(inputTokens, total)
+	return CacheTokenDistribution{
+		InputTokens:              inputTokens,
+		CacheCreationInputTokens: total - inputTokens,
+	}
+}
+
+// maxIntegerPart bounds the scaled parts so their sum fits an int64 and each part is
+// still exactly representable as a float64.
+const maxIntegerPart = 1 << 53
+
+// integerParts scales the parts by the smallest power of ten up to 10^9 that makes all
+// of them whole, so weights such as 0.1:0.3:9.6 are distributed with exact integer
+// arithmetic as 1:3:96. It reports false when no such scale exists.
+func (d Distributor) integerParts() (input, creation, read int64, ok bool) {
+	parts := [3]float64{d.InputPart, d.CreationPart, d.ReadPart}
+	for scale := 1.0; scale <= 1e9; scale *= 10 {
+		var scaled [3]int64
+		whole := true
+		for i, part := range parts {
+			v := part * scale
+			r := math.Round(v)
+			if r < 1 || r > maxIntegerPart || math.Abs(v-r) > 1e-12*r {
+				whole = false
+				break
+			}
+			scaled[i] = int64(r)
+		}
+		if whole {
+			return scaled[0], scaled[1], scaled[2], true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+func (c DistributionConfig) distributor(mode RoundingMode) Distributor {
+	return Distributor{
+		Threshold:    c.Threshold,
+		InputPart:    float64(c.InputPart),
+		CreationPart: float64(c.CreationPart),
+		ReadPart:     float64(c.ReadPart),
+		Rounding:     mode,
+	}
+}
+
+// scaleRounded returns tokens*part/sum rounded according to mode. The product is
+// computed in 128 bits so totals near math.MaxInt64 do not overflow; part <= sum
+// guarantees the quotient fits back into an int64.
+func scaleRounded(tokens, part, sum int64, mode RoundingMode) int64 {
+	hi, lo := bits.Mul64(uint64(tokens), uint64(part))
+	quo, rem := bits.Div64(hi, lo, uint64(sum))
+	quotient, remainder := int64(quo), int64(rem)
+	switch mode {
+	case RoundNearest:
+		if remainder >= sum-remainder {
+			quotient++
+		}
+	case RoundCeil:
+		if remainder > 0 {
+			quotient++
+		}
+	}
+	return quotient
+}
+
+// scaleRoundedFloat returns tokens*fraction rounded according to mode and clamped to
+// [0, tokens]. It serves weights that integerParts cannot make whole.
+func scaleRoundedFloat(tokens int64, fraction float64, mode RoundingMode) int64 {
+	v := float64(tokens) * fraction
+	switch mode {
+	case RoundNearest:
+		v = math.Floor(v + 0.5)
+	case RoundCeil:
+		v = math.Ceil(v)
+	default:
+		v = math.Floor(v)
+	}
+	if v <= 0 {
+		return 0
+	}
+	if v >= float64(tokens) {
+		return tokens
+	}
+	return int64(v)
+}
+
+func (r Ratio) config(threshold int64) DistributionConfig {
+	return DistributionConfig{
+		InputPart:    int64(r.Input),
+		CreationPart: int64(r.Creation),
+		ReadPart:     int64(r.Read),
+		Threshold:    threshold,
+	}
+}
+
+var (
+	modelRatiosMu sync.RWMutex
+	modelRatios   = map[string]Ratio{}
+)
+
+// SetModelRatios replaces the model-specific ratio table. Keys are model IDs or
+// families as returned by ModelFamily, matched case-insensitively. The table is left unchanged when any ratio is invalid.
+func SetModelRatios(ratios map[string]Ratio) error {
+	next := make(map[string]Ratio, len(ratios))
+	for model, ratio := range ratios {
+		key := normalizeRatioModelKey(model)
+		if key == "" {
+			continue
+		}
+		if err := ratio.Validate(); err != nil {
+			return fmt.Errorf("model %q: %w", model, err)
+		}
+		next[key] = ratio
+	}
+	modelRatiosMu.Lock()
+	modelRatios = next
+	modelRatiosMu.Unlock()
+	return nil
+}
+
+// ModelRatio returns the ratio configured for model, falling back to the default 1:2:25 ratio.
+// It is equivalent to RatioForModel.
+func ModelRatio(model string) Ratio {
+	return RatioForModel(model)
+}
+
+// RatioForModel returns the ratio configured for model. A ratio registered for the
+// exact model ID wins; otherwise the ID is reduced to its family with ModelFamily, so
+// a single "claude-sonnet" entry covers "claude-3-5-sonnet-20241022",
+// "anthropic/claude-sonnet-4" and "kiro-claude-sonnet". Unknown families get the
+// default 1:2:25 ratio.
+func RatioForModel(model string) Ratio {
+	if ratio, ok := lookupModelRatio(model); ok {
+		return ratio
+	}
+	return defaultRatio
+}
+
+func lookupModelRatio(model string) (Ratio, bool) {
+	modelRatiosMu.RLock()
+	defer modelRatiosMu.RUnlock()
+	if len(modelRatios) == 0 {
+		return Ratio{}, false
+	}
+	if ratio, ok := modelRatios[normalizeRatioModelKey(model)]; ok {
+		return ratio, true
+	}
+	ratio, ok := modelRatios[ModelFamily(model)]
+	return ratio, ok
+}
+
+// modelFamilies maps model IDs to families, most specific first. A family matches
+// when the normalized ID contains every one of its markers.
+var modelFamilies = []struct {
+	family  string
+	markers []string
+}{
+	{"claude-opus", []string{"claude", "opus"}},
+	{"claude-sonnet", []string{"claude", "sonnet"}},
+	{"claude-haiku", []string{"claude", "haiku"}},
+	{"claude", []string{"claude"}},
+	{"gemini-flash-lite", []string{"gemini", "flash-lite"}},
+	{"gemini-flash", []string{"gemini", "flash"}},
+	{"gemini-pro", []string{"gemini", "pro"}},
+	{"gemini", []string{"gemini"}},
+	{"gpt", []string{"gpt"}},
+}
+
+// ModelFamily reduces a client-supplied model ID to its family, case-insensitively.
+// Vendor prefixes ("anthropic/", "models/", Bedrock's "us.anthropic."), thinking
+// suffixes ("(8192)") and version tags ("@20241022", ":0") are dropped before the ID
+// is matched against the known families. IDs of unknown families are returned
+// normalized, with any trailing date removed.
+func ModelFamily(model string) string {
+	id := normalizeRatioModelKey(model)
+	if i := strings.IndexByte(id, '('); i > 0 {
+		id = id[:i]
+	}
+	if i := strings.LastIndexByte(id, '/'); i >= 0 {
+		id = id[i+1:]
+	}
+	if i := strings.LastIndex(id, "anthropic."); i >= 0 {
+		id = id[i+len("anthropic."):]
+	}
+	if i := strings.IndexAny(id, "@:"); i > 0 {
+		id = id[:i]
+	}
+	for _, f := range modelFamilies {
+		matched := true
+		for _, marker := range f.markers {
+			if !strings.Contains(id, marker) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return f.family
+		}
+	}
+	return trimModelDate(id)
+}
+
+// trimModelDate removes a trailing "-YYYYMMDD" or "-YYYY-MM-DD" date from id.
+func trimModelDate(id string) string {
+	if n := len(id); n > 9 && id[n-9] == '-' && isDigits(id[n-8:]) {
+		return id[:n-9]
+	}
+	if n := len(id); n > 11 && id[n-11] == '-' && isDigits(id[n-10:n-6]) && id[n-6] == '-' && isDigits(id[n-5:n-3]) && id[n-3] == '-' && isDigits(id[n-2:]) {
+		return id[:n-11]
+	}
+	return id
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// DistributeCacheTokensForModel splits totalInputTokens using the ratio configured for model.
+func DistributeCacheTokensForModel(totalInputTokens int64, model string) CacheTokenDistribution {
+	return DistributeCacheTokensWith(ModelRatio(model).config(DistributionThreshold), totalInputTokens)
+}
+
+func normalizeRatioModelKey(model string) string {
+	return strings.ToLower(strings.TrimSpace(model))
+}
+
+var (
+	providerDistributionsMu sync.RWMutex
+	providerDistributions   = map[string]Distributor{}
+)
+
+// SetProviderDistributionConfigs replaces the per-provider distribution table.
+// Provider names are matched case-insensitively. The table is left unchanged when any entry is invalid.
+func SetProviderDistributionConfigs(cfgs map[string]DistributionConfig) error {
+	distributors := make(map[string]Distributor, len(cfgs))
+	for provider, cfg := range cfgs {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("provider %q: %w", provider, err)
+		}
+		distributors[provider] = cfg.distributor(RoundFloor)
+	}
+	return SetProviderDistributors(distributors)
+}
+
+// SetProviderDistributors replaces the per-provider distribution table with
+// distributors, whose ratio parts may be fractional. Provider names are matched
+// case-insensitively. The table is left unchanged when any entry is invalid.
+func SetProviderDistributors(distributors map[string]Distributor) error {
+	next := make(map[string]Distributor, len(distributors))
+	for provider, d := range distributors {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if key == "" {
+			continue
+		}
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("provider %q: %w", provider, err)
+		}
+		next[key] = d
+	}
+	providerDistributionsMu.Lock()
+	providerDistributions = next
+	providerDistributionsMu.Unlock()
+	return nil
+}
+
+// ProviderDistributor returns the distributor configured for provider, falling back
+// to DefaultDistributor.
+func ProviderDistributor(provider string) Distributor {
+	providerDistributionsMu.RLock()
+	d, ok := providerDistributions[strings.ToLower(strings.TrimSpace(provider))]
+	providerDistributionsMu.RUnlock()
+	if !ok {
+		return DefaultDistributor()
+	}
+	return d
+}
+
+// DistributeCacheTokensForProvider splits tokens using the distribution configured for
+// provider. A ratio registered for model or its family via SetModelRatios takes
+// precedence over the provider ratio, while the provider threshold and cache read
+// floor still apply. A pass-through provider is never distributed, whatever the model
+// ratio.
+func DistributeCacheTokensForProvider(provider, model string, tokens int64) CacheTokenDistribution {
+	d := ProviderDistributor(provider)
+	if ratio, ok := lookupModelRatio(model); ok && !d.PassThrough {
+		floor, rounding := d.MinCacheRead, d.Rounding
+		d = ratio.config(d.Threshold).distributor(rounding)
+		d.MinCacheRead = floor
+	}
+	return d.Distribute(tokens)
+}
+
+// ApplyCacheDistributionConfig installs the per-provider distributions from the application config.
+func ApplyCacheDistributionConfig(cfg *config.Config) error {
+	if cfg == nil {
+		return SetProviderDistributionConfigs(nil)
+	}
+	distributors := make(map[string]Distributor, len(cfg.CacheDistribution))
+	for provider, dist := range cfg.CacheDistribution {
+		threshold := dist.Threshold
+		if threshold == 0 {
+			threshold = DistributionThreshold
+		}
+		distributors[provider] = Distributor{
+			Threshold:    threshold,
+			InputPart:    dist.InputPart,
+			CreationPart: dist.CreationPart,
+			ReadPart:     dist.ReadPart,
+			MinCacheRead: dist.MinCacheRead,
+			PassThrough:  dist.PassThrough,
+		}
+	}
+	return SetProviderDistributors(distributors)
+}
+
+// UsageBlock is a complete Claude usage block: the distributed input buckets plus output tokens.
+type UsageBlock struct {
+	CacheTokenDistribution
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// MarshalJSON encodes u as a Claude usage object. It is required because the
+// promoted CacheTokenDistribution.MarshalJSON would otherwise drop output_tokens.
+func (u UsageBlock) MarshalJSON() ([]byte, error) {
+	out := u.claudeJSON()
+	out.OutputTokens = &u.OutputTokens
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a Claude usage object into u, accepting string-encoded
+// integers like CacheTokenDistribution.UnmarshalJSON. It is required because the
+// promoted method would otherwise leave output_tokens unset.
+func (u *UsageBlock) UnmarshalJSON(data []byte) error {
+	var dist CacheTokenDistribution
+	if err := dist.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	var raw struct {
+		OutputTokens json.RawMessage `json:"output_tokens"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	output, err := parseTokenCount(raw.OutputTokens)
+	if err != nil {
+		return fmt.Errorf("usage: output_tokens: %w", err)
+	}
+	*u = UsageBlock{CacheTokenDistribution: dist, OutputTokens: output}
+	return nil
+}
+
+// NewUsageBlock distributes totalInput with DistributeCacheTokens and records output verbatim.
+// Output tokens are never distributed.
+func NewUsageBlock(totalInput, output int64) UsageBlock {
+	return UsageBlock{
+		CacheTokenDistribution: DistributeCacheTokens(totalInput),
+		OutputTokens:           output,
+	}
+}
+
+// String formats u like CacheTokenDistribution.String followed by "output=N".
+func (u UsageBlock) String() string {
+	return fmt.Sprintf("%s output=%d", u.CacheTokenDistribution, u.OutputTokens)
+}
+
+// Total returns the combined input and output token count.
+func (u UsageBlock) Total() int64 {
+	return u.TotalInputTokens() + u.OutputTokens
+}
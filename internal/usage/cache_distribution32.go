@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"fmt"
+	"math"
+)
+
+// CacheTokenDistribution32 is CacheTokenDistribution with int32 buckets, halving its
+// footprint in large in-memory tables such as stored analytics rows. Use
+// CacheTokenDistribution at API boundaries and convert with From64 and To64.
+type CacheTokenDistribution32 struct {
+	InputTokens              int32
+	CacheCreationInputTokens int32
+	CacheReadInputTokens     int32
+	CacheCreation            CacheCreation32
+}
+
+// CacheCreation32 is CacheCreation with int32 tiers.
+type CacheCreation32 struct {
+	Ephemeral5mInputTokens int32
+	Ephemeral1hInputTokens int32
+}
+
+// To64 widens d to a CacheTokenDistribution. Widening never loses information.
+func (d CacheTokenDistribution32) To64() CacheTokenDistribution {
+	return CacheTokenDistribution{
+		InputTokens:              int64(d.InputTokens),
+		CacheCreationInputTokens: int64(d.CacheCreationInputTokens),
+		CacheReadInputTokens:     int64(d.CacheReadInputTokens),
+		CacheCreation: CacheCreation{
+			Ephemeral5mInputTokens: int64(d.CacheCreation.Ephemeral5mInputTokens),
+			Ephemeral1hInputTokens: int64(d.CacheCreation.Ephemeral1hInputTokens),
+		},
+	}
+}
+
+// From64 narrows src into d. It reports an error naming the first bucket of src that
+// does not fit in an int32, and leaves d unchanged in that case, so a row is never
+// stored with a silently wrapped count.
+func (d *CacheTokenDistribution32) From64(src CacheTokenDistribution) error {
+	fields := []struct {
+		name  string
+		value int64
+	}{
+		{"input_tokens", src.InputTokens},
+		{"cache_creation_input_tokens", src.CacheCreationInputTokens},
+		{"cache_read_input_tokens", src.CacheReadInputTokens},
+		{"cache_creation.ephemeral_5m_input_tokens", src.CacheCreation.Ephemeral5mInputTokens},
+		{"cache_creation.ephemeral_1h_input_tokens", src.CacheCreation.Ephemeral1hInputTokens},
+	}
+	for _, field := range fields {
+		if field.value < math.MinInt32 || field.value > math.MaxInt32 {
+			return fmt.Errorf("usage: cache distribution %s %d overflows int32", field.name, field.value)
+		}
+	}
+	*d = CacheTokenDistribution32{
+		InputTokens:              int32(src.InputTokens),
+		CacheCreationInputTokens: int32(src.CacheCreationInputTokens),
+		CacheReadInputTokens:     int32(src.CacheReadInputTokens),
+		CacheCreation: CacheCreation32{
+			Ephemeral5mInputTokens: int32(src.CacheCreation.Ephemeral5mInputTokens),
+			Ephemeral1hInputTokens: int32(src.CacheCreation.Ephemeral1hInputTokens),
+		},
+	}
+	return nil
+}
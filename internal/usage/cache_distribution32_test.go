@@ -0,0 +1,44 @@
+package usage
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCacheTokenDistribution32_RoundTrip(t *testing.T) {
+	for _, d := range []CacheTokenDistribution{
+		{},
+		{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894, CacheCreation: CacheCreation{Ephemeral5mInputTokens: 50, Ephemeral1hInputTokens: 21}},
+		{InputTokens: math.MaxInt32, CacheCreationInputTokens: math.MaxInt32, CacheReadInputTokens: math.MaxInt32},
+		{InputTokens: math.MinInt32},
+	} {
+		var narrow CacheTokenDistribution32
+		if err := narrow.From64(d); err != nil {
+			t.Fatalf("From64(%v) error = %v", d, err)
+		}
+		if got := narrow.To64(); got != d {
+			t.Fatalf("round trip of %+v = %+v", d, got)
+		}
+	}
+}
+
+func TestCacheTokenDistribution32_From64Overflow(t *testing.T) {
+	kept := CacheTokenDistribution32{InputTokens: 7}
+	for name, d := range map[string]CacheTokenDistribution{
+		"input_tokens":                             {InputTokens: math.MaxInt32 + 1},
+		"cache_creation_input_tokens":              {CacheCreationInputTokens: math.MinInt32 - 1},
+		"cache_read_input_tokens":                  {CacheReadInputTokens: math.MaxInt64},
+		"cache_creation.ephemeral_5m_input_tokens": {CacheCreation: CacheCreation{Ephemeral5mInputTokens: 1 << 40}},
+		"cache_creation.ephemeral_1h_input_tokens": {CacheCreation: CacheCreation{Ephemeral1hInputTokens: math.MinInt64}},
+	} {
+		narrow := kept
+		err := narrow.From64(d)
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("From64 with %s out of range: error = %v", name, err)
+		}
+		if narrow != kept {
+			t.Fatalf("From64 with %s out of range modified the target: %+v", name, narrow)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package usage
+
+import "context"
+
+// DistributorContextKey is the context key under which WithDistributor stores a
+// *Distributor. Middleware that resolves per-tenant ratios (e.g. per API key) may set
+// it directly with context.WithValue; the stored value must be a *Distributor.
+type DistributorContextKey struct{}
+
+// WithDistributor returns a derived context carrying d for DistributeFromContext.
+// A nil d clears any distributor set by an outer context.
+func WithDistributor(ctx context.Context, d *Distributor) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, DistributorContextKey{}, d)
+}
+
+// DistributorFromContext returns the distributor stored in ctx by WithDistributor,
+// falling back to DefaultDistributor when none (or a nil one) is present.
+func DistributorFromContext(ctx context.Context) Distributor {
+	if ctx != nil {
+		if d, ok := ctx.Value(DistributorContextKey{}).(*Distributor); ok && d != nil {
+			return *d
+		}
+	}
+	return DefaultDistributor()
+}
+
+// DistributeFromContext splits total with the distributor stored in ctx, or with the
+// default 1:2:25 distributor when ctx carries none.
+func DistributeFromContext(ctx context.Context, total int64) CacheTokenDistribution {
+	return DistributorFromContext(ctx).Distribute(total)
+}
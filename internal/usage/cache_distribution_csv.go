@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// csvHeader is the header row written by WriteCSV and expected by ParseCSV.
+var csvHeader = []string{"key", "input", "cache_creation", "cache_read", "total"}
+
+// WriteCSV writes rows as CSV with a header row and one line per key, sorted by key
+// so exports are deterministic.
+func WriteCSV(w io.Writer, rows map[string]CacheTokenDistribution) error {
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		d := rows[key]
+		record := []string{
+			key,
+			strconv.FormatInt(d.InputTokens, 10),
+			strconv.FormatInt(d.CacheCreationInputTokens, 10),
+			strconv.FormatInt(d.CacheReadInputTokens, 10),
+			strconv.FormatInt(d.TotalInputTokens(), 10),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseCSV reads rows written by WriteCSV. It rejects a missing or unexpected header,
+// duplicate keys, invalid distributions, and lines whose total does not match the sum
+// of their buckets.
+func ParseCSV(r io.Reader) (map[string]CacheTokenDistribution, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("usage: csv is missing the header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usage: read csv header: %w", err)
+	}
+	for i, name := range csvHeader {
+		if header[i] != name {
+			return nil, fmt.Errorf("usage: csv header column %d is %q, want %q", i+1, header[i], name)
+		}
+	}
+
+	rows := make(map[string]CacheTokenDistribution)
+	for {
+		record, errRead := cr.Read()
+		if errors.Is(errRead, io.EOF) {
+			return rows, nil
+		}
+		if errRead != nil {
+			return nil, fmt.Errorf("usage: read csv: %w", errRead)
+		}
+		line, _ := cr.FieldPos(0)
+		key := record[0]
+		if _, exists := rows[key]; exists {
+			return nil, fmt.Errorf("usage: csv line %d: duplicate key %q", line, key)
+		}
+
+		values := make([]int64, len(csvHeader)-1)
+		for i := range values {
+			value, errParse := strconv.ParseInt(record[i+1], 10, 64)
+			if errParse != nil {
+				return nil, fmt.Errorf("usage: csv line %d: invalid %s %q", line, csvHeader[i+1], record[i+1])
+			}
+			values[i] = value
+		}
+		d := CacheTokenDistribution{
+			InputTokens:              values[0],
+			CacheCreationInputTokens: values[1],
+			CacheReadInputTokens:     values[2],
+		}
+		if errValidate := d.Validate(); errValidate != nil {
+			return nil, fmt.Errorf("%w (csv line %d)", errValidate, line)
+		}
+		if d.TotalInputTokens() != values[3] {
+			return nil, fmt.Errorf("usage: csv line %d: total %d does not match bucket sum %d", line, values[3], d.TotalInputTokens())
+		}
+		rows[key] = d
+	}
+}
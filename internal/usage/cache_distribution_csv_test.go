@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	rows := map[string]CacheTokenDistribution{
+		"kiro/claude-sonnet": DistributeCacheTokens(1000),
+		"acme, inc":          {InputTokens: 42},
+		"codex/gpt-5":        {},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rows); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "key,input,cache_creation,cache_read,total\n" +
+		"\"acme, inc\",42,0,0,42\n" +
+		"codex/gpt-5,0,0,0,0\n" +
+		"kiro/claude-sonnet,35,71,894,1000\n"
+	if buf.String() != want {
+		t.Fatalf("WriteCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	got, err := ParseCSV(&buf)
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Fatalf("ParseCSV() = %v, want %v", got, rows)
+	}
+
+	buf.Reset()
+	if err = WriteCSV(&buf, nil); err != nil || buf.String() != "key,input,cache_creation,cache_read,total\n" {
+		t.Fatalf("WriteCSV(nil) = %q, %v", buf.String(), err)
+	}
+}
+
+func TestParseCSVRejectsBadInput(t *testing.T) {
+	const header = "key,input,cache_creation,cache_read,total\n"
+	cases := map[string]string{
+		"empty":         "",
+		"wrong header":  "key,input,creation,read,total\n",
+		"short line":    header + "a,1,2,3\n",
+		"not a number":  header + "a,1,x,3,6\n",
+		"negative":      header + "a,-1,2,3,4\n",
+		"total":         header + "a,1,2,3,7\n",
+		"duplicate key": header + "a,1,2,3,6\na,1,2,3,6\n",
+	}
+	for name, input := range cases {
+		if _, err := ParseCSV(strings.NewReader(input)); err == nil {
+			t.Fatalf("ParseCSV(%s) error = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,1546 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestDistributeCacheTokens_BelowThreshold(t *testing.T) {
+	got := DistributeCacheTokens(42)
+	want := CacheTokenDistribution{InputTokens: 42}
+	if got != want {
+		t.Fatalf("DistributeCacheTokens(42) = %+v, want %+v", got, want)
+	}
+	if got.HasCacheTokens() {
+		t.Fatalf("expected no cache tokens below threshold")
+	}
+}
+
+func TestDistributeCacheTokens_DefaultRatio(t *testing.T) {
+	got := DistributeCacheTokens(1000)
+	want := CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}
+	if got != want {
+		t.Fatalf("DistributeCacheTokens(1000) = %+v, want %+v", got, want)
+	}
+	if got.TotalInputTokens() != 1000 {
+		t.Fatalf("TotalInputTokens() = %d, want 1000", got.TotalInputTokens())
+	}
+}
+
+func TestDefaultRatioAndThreshold(t *testing.T) {
+	input, creation, read := DefaultRatio()
+	d, err := NewDistributor(WithRatio(input, creation, read), WithThreshold(DefaultThreshold()))
+	if err != nil {
+		t.Fatalf("NewDistributor from defaults error = %v", err)
+	}
+	for _, total := range []int64{0, DefaultThreshold() - 1, DefaultThreshold(), 1000, 123457} {
+		if got, want := d.Distribute(total), DistributeCacheTokens(total); got != want {
+			t.Fatalf("Distribute(%d) from accessor defaults = %v, want %v", total, got, want)
+		}
+	}
+}
+
+func TestFieldDescriptors(t *testing.T) {
+	d := CacheTokenDistribution{InputTokens: 1, CacheCreationInputTokens: 2, CacheReadInputTokens: 3}
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+	var encoded map[string]json.Number
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		t.Fatalf("Unmarshal %s error = %v", data, err)
+	}
+	want := map[string]int64{"InputTokens": 1, "CacheCreationInputTokens": 2, "CacheReadInputTokens": 3}
+	descriptors := FieldDescriptors()
+	if len(descriptors) != len(want) {
+		t.Fatalf("FieldDescriptors() = %+v, want %d descriptors", descriptors, len(want))
+	}
+	for _, fd := range descriptors {
+		if fd.Type != "integer" || fd.Description == "" {
+			t.Fatalf("descriptor %+v", fd)
+		}
+		if got, ok := encoded[fd.JSONKey]; !ok || got.String() != fmt.Sprint(want[fd.Name]) {
+			t.Fatalf("descriptor %s key %q = %q in %s, want %d", fd.Name, fd.JSONKey, got, data, want[fd.Name])
+		}
+	}
+}
+
+func TestDistributeCacheTokensWithRatio(t *testing.T) {
+	got, err := DistributeCacheTokensWithRatio(1000, 1, 1, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := CacheTokenDistribution{InputTokens: 100, CacheCreationInputTokens: 100, CacheReadInputTokens: 800}
+	if got != want {
+		t.Fatalf("DistributeCacheTokensWithRatio(1000, 1, 1, 8) = %+v, want %+v", got, want)
+	}
+}
+
+func TestDistributeCacheTokensWithRatio_InvalidRatio(t *testing.T) {
+	cases := []Ratio{
+		{Input: 0, Creation: 2, Read: 25},
+		{Input: 1, Creation: -2, Read: 25},
+		{Input: 1, Creation: 2, Read: 0},
+	}
+	for _, ratio := range cases {
+		got, err := DistributeCacheTokensWithRatio(1000, ratio.Input, ratio.Creation, ratio.Read)
+		if err == nil {
+			t.Fatalf("expected error for ratio %+v", ratio)
+		}
+		if got.InputTokens != 1000 || got.HasCacheTokens() {
+			t.Fatalf("expected undistributed total for ratio %+v, got %+v", ratio, got)
+		}
+	}
+}
+
+func TestDistributeCacheTokensForModel(t *testing.T) {
+	t.Cleanup(func() { _ = SetModelRatios(nil) })
+
+	if err := SetModelRatios(map[string]Ratio{"Claude-Haiku-4.5": {Input: 1, Creation: 1, Read: 2}}); err != nil {
+		t.Fatalf("SetModelRatios: %v", err)
+	}
+
+	got := DistributeCacheTokensForModel(1000, "claude-haiku-4.5")
+	want := CacheTokenDistribution{InputTokens: 250, CacheCreationInputTokens: 250, CacheReadInputTokens: 500}
+	if got != want {
+		t.Fatalf("configured model distribution = %+v, want %+v", got, want)
+	}
+
+	if got := DistributeCacheTokensForModel(1000, "claude-sonnet-4.5"); got != DistributeCacheTokens(1000) {
+		t.Fatalf("unknown model should use default ratio, got %+v", got)
+	}
+}
+
+func TestModelFamily(t *testing.T) {
+	tests := map[string]string{
+		"claude-3-5-sonnet-20241022":                  "claude-sonnet",
+		"anthropic/claude-sonnet-4":                   "claude-sonnet",
+		"kiro-claude-sonnet":                          "claude-sonnet",
+		"Claude-Opus-4-1(16384)":                      "claude-opus",
+		"us.anthropic.claude-3-5-haiku-20241022-v1:0": "claude-haiku",
+		"claude-sonnet-4@20250514":                    "claude-sonnet",
+		"models/gemini-2.5-flash-lite":                "gemini-flash-lite",
+		"gemini-2.5-pro":                              "gemini-pro",
+		"openai/gpt-5.2":                              "gpt",
+		"deepseek-chat-2025-01-20":                    "deepseek-chat",
+		"  Mistral-Large-20240701 ":                   "mistral-large",
+	}
+	for model, want := range tests {
+		if got := ModelFamily(model); got != want {
+			t.Errorf("ModelFamily(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestRatioForModel(t *testing.T) {
+	t.Cleanup(func() { _ = SetModelRatios(nil) })
+
+	sonnet := Ratio{Input: 1, Creation: 1, Read: 8}
+	pinned := Ratio{Input: 1, Creation: 3, Read: 6}
+	if err := SetModelRatios(map[string]Ratio{"claude-sonnet": sonnet, "claude-3-5-sonnet-20241022": pinned}); err != nil {
+		t.Fatalf("SetModelRatios: %v", err)
+	}
+
+	for _, model := range []string{"anthropic/claude-sonnet-4", "kiro-claude-sonnet", "CLAUDE-SONNET-4-5-20250929", "claude-sonnet"} {
+		if got := RatioForModel(model); got != sonnet {
+			t.Errorf("RatioForModel(%q) = %+v, want the family ratio %+v", model, got, sonnet)
+		}
+	}
+	if got := RatioForModel("Claude-3-5-Sonnet-20241022"); got != pinned {
+		t.Errorf("expected the exact model entry to win over its family, got %+v", got)
+	}
+	for _, model := range []string{"claude-opus-4", "gemini-2.5-pro", "some-new-model", ""} {
+		if got := RatioForModel(model); got != defaultRatio {
+			t.Errorf("RatioForModel(%q) = %+v, want the default 1:2:25", model, got)
+		}
+	}
+}
+
+func TestSetModelRatios_RejectsInvalid(t *testing.T) {
+	t.Cleanup(func() { _ = SetModelRatios(nil) })
+
+	if err := SetModelRatios(map[string]Ratio{"a": {Input: 1, Creation: 1, Read: 1}}); err != nil {
+		t.Fatalf("SetModelRatios: %v", err)
+	}
+	if err := SetModelRatios(map[string]Ratio{"b": {Input: 0, Creation: 1, Read: 1}}); err == nil {
+		t.Fatalf("expected error for invalid ratio")
+	}
+	if got := ModelRatio("a"); got != (Ratio{Input: 1, Creation: 1, Read: 1}) {
+		t.Fatalf("previous table should be kept on error, got %+v", got)
+	}
+}
+
+func TestDistributeCacheTokensWith_Threshold(t *testing.T) {
+	cfg := DistributionConfig{InputPart: 1, CreationPart: 1, ReadPart: 2, Threshold: 10}
+	if got := DistributeCacheTokensWith(cfg, 9); got != (CacheTokenDistribution{InputTokens: 9}) {
+		t.Fatalf("below threshold = %+v, want all input", got)
+	}
+	got := DistributeCacheTokensWith(cfg, 10)
+	want := CacheTokenDistribution{InputTokens: 2, CacheCreationInputTokens: 2, CacheReadInputTokens: 6}
+	if got != want {
+		t.Fatalf("at threshold = %+v, want %+v", got, want)
+	}
+}
+
+func TestDistributeCacheTokensWith_PreservesTotal(t *testing.T) {
+	cfg := DistributionConfig{InputPart: 3, CreationPart: 7, ReadPart: 11, Threshold: 1}
+	for _, total := range []int64{1, 20, 21, 22, 997, 123457} {
+		if got := DistributeCacheTokensWith(cfg, total); got.TotalInputTokens() != total {
+			t.Fatalf("total %d: TotalInputTokens() = %d (%+v)", total, got.TotalInputTokens(), got)
+		}
+	}
+}
+
+func TestDistributeCacheTokensForProvider(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetProviderDistributionConfigs(nil)
+		_ = SetModelRatios(nil)
+	})
+
+	if err := SetProviderDistributionConfigs(map[string]DistributionConfig{
+		"Kiro": {InputPart: 1, CreationPart: 1, ReadPart: 8, Threshold: 50},
+	}); err != nil {
+		t.Fatalf("SetProviderDistributionConfigs: %v", err)
+	}
+
+	got := DistributeCacheTokensForProvider("kiro", "claude-sonnet-4.5", 60)
+	want := CacheTokenDistribution{InputTokens: 6, CacheCreationInputTokens: 6, CacheReadInputTokens: 48}
+	if got != want {
+		t.Fatalf("provider distribution = %+v, want %+v", got, want)
+	}
+	if got := DistributeCacheTokensForProvider("other", "", 1000); got != DistributeCacheTokens(1000) {
+		t.Fatalf("unknown provider should use default, got %+v", got)
+	}
+
+	if err := SetModelRatios(map[string]Ratio{"claude-haiku-4.5": {Input: 1, Creation: 1, Read: 2}}); err != nil {
+		t.Fatalf("SetModelRatios: %v", err)
+	}
+	got = DistributeCacheTokensForProvider("kiro", "claude-haiku-4.5", 60)
+	want = CacheTokenDistribution{InputTokens: 15, CacheCreationInputTokens: 15, CacheReadInputTokens: 30}
+	if got != want {
+		t.Fatalf("model ratio should override provider ratio, got %+v, want %+v", got, want)
+	}
+
+	if err := SetProviderDistributors(map[string]Distributor{
+		"kiro": {InputPart: 1, CreationPart: 1, ReadPart: 8, Threshold: 50, Rounding: RoundCeil},
+	}); err != nil {
+		t.Fatalf("SetProviderDistributors: %v", err)
+	}
+	got = DistributeCacheTokensForProvider("kiro", "claude-haiku-4.5", 61)
+	want = CacheTokenDistribution{InputTokens: 16, CacheCreationInputTokens: 16, CacheReadInputTokens: 29}
+	if got != want {
+		t.Fatalf("model ratio should keep the provider rounding mode, got %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyCacheDistributionConfig_FractionalParts(t *testing.T) {
+	t.Cleanup(func() { _ = SetProviderDistributionConfigs(nil) })
+
+	cfg := &config.Config{CacheDistribution: map[string]config.CacheDistribution{
+		"kiro": {InputPart: 0.1, CreationPart: 0.3, ReadPart: 9.6},
+	}}
+	if err := ApplyCacheDistributionConfig(cfg); err != nil {
+		t.Fatalf("ApplyCacheDistributionConfig: %v", err)
+	}
+	want := CacheTokenDistribution{InputTokens: 10, CacheCreationInputTokens: 30, CacheReadInputTokens: 960}
+	if got := DistributeCacheTokensForProvider("kiro", "", 1000); got != want {
+		t.Fatalf("fractional provider distribution = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyCacheDistributionConfig_MinCacheRead(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetProviderDistributionConfigs(nil)
+		_ = SetModelRatios(nil)
+	})
+
+	cfg := &config.Config{CacheDistribution: map[string]config.CacheDistribution{
+		"kiro": {InputPart: 1, CreationPart: 2, ReadPart: 25, MinCacheRead: 95},
+	}}
+	if err := ApplyCacheDistributionConfig(cfg); err != nil {
+		t.Fatalf("ApplyCacheDistributionConfig: %v", err)
+	}
+	want := CacheTokenDistribution{InputTokens: 0, CacheCreationInputTokens: 5, CacheReadInputTokens: 95}
+	if got := DistributeCacheTokensForProvider("kiro", "", 100); got != want {
+		t.Fatalf("floored provider distribution = %+v, want %+v", got, want)
+	}
+	// A model ratio replaces the provider ratio but keeps its floor.
+	if err := SetModelRatios(map[string]Ratio{"claude-haiku-4.5": {Input: 1, Creation: 1, Read: 2}}); err != nil {
+		t.Fatalf("SetModelRatios: %v", err)
+	}
+	want = CacheTokenDistribution{InputTokens: 0, CacheCreationInputTokens: 5, CacheReadInputTokens: 95}
+	if got := DistributeCacheTokensForProvider("kiro", "claude-haiku-4.5", 100); got != want {
+		t.Fatalf("floored model distribution = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetProviderDistributionConfigs_RejectsInvalid(t *testing.T) {
+	t.Cleanup(func() { _ = SetProviderDistributionConfigs(nil) })
+
+	cases := []DistributionConfig{
+		{InputPart: 0, CreationPart: 2, ReadPart: 25, Threshold: 100},
+		{InputPart: 1, CreationPart: 2, ReadPart: -1, Threshold: 100},
+		{InputPart: 1, CreationPart: 2, ReadPart: 25, Threshold: -1},
+	}
+	for _, cfg := range cases {
+		if err := SetProviderDistributionConfigs(map[string]DistributionConfig{"kiro": cfg}); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestNewUsageBlock(t *testing.T) {
+	block := NewUsageBlock(1000, 250)
+	if block.CacheTokenDistribution != DistributeCacheTokens(1000) {
+		t.Fatalf("unexpected input distribution: %+v", block.CacheTokenDistribution)
+	}
+	if block.OutputTokens != 250 {
+		t.Fatalf("OutputTokens = %d, want 250", block.OutputTokens)
+	}
+	if block.Total() != 1250 {
+		t.Fatalf("Total() = %d, want 1250", block.Total())
+	}
+
+	small := NewUsageBlock(42, 7)
+	if small.InputTokens != 42 || small.HasCacheTokens() || small.Total() != 49 {
+		t.Fatalf("unexpected below-threshold block: %+v", small)
+	}
+}
+
+func TestDistributeCacheTokensMode(t *testing.T) {
+	cases := []struct {
+		mode RoundingMode
+		want CacheTokenDistribution
+	}{
+		// 1000/28 = 35.71, 2000/28 = 71.43
+		{RoundFloor, CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}},
+		{RoundNearest, CacheTokenDistribution{InputTokens: 36, CacheCreationInputTokens: 71, CacheReadInputTokens: 893}},
+		{RoundCeil, CacheTokenDistribution{InputTokens: 36, CacheCreationInputTokens: 72, CacheReadInputTokens: 892}},
+	}
+	for _, tc := range cases {
+		got := DistributeCacheTokensMode(1000, tc.mode)
+		if got != tc.want {
+			t.Fatalf("mode %d: got %+v, want %+v", tc.mode, got, tc.want)
+		}
+		if got.TotalInputTokens() != 1000 {
+			t.Fatalf("mode %d: total not preserved: %+v", tc.mode, got)
+		}
+	}
+	if got := DistributeCacheTokensMode(42, RoundCeil); got != (CacheTokenDistribution{InputTokens: 42}) {
+		t.Fatalf("below threshold should not distribute, got %+v", got)
+	}
+}
+
+func TestDistributeWithMode_NearestHalfRoundsUp(t *testing.T) {
+	cfg := DistributionConfig{InputPart: 1, CreationPart: 1, ReadPart: 2, Threshold: 0}
+	// 10/4 = 2.5 for both input and creation.
+	got := distributeWithMode(cfg, 10, RoundNearest)
+	want := CacheTokenDistribution{InputTokens: 3, CacheCreationInputTokens: 3, CacheReadInputTokens: 4}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDistributeWithMode_RemainderNeverNegative(t *testing.T) {
+	cfg := DistributionConfig{InputPart: 1, CreationPart: 1, ReadPart: 1, Threshold: 0}
+	// ceil(1/3) + ceil(1/3) = 2 > 1 and round(2/3) + round(2/3) = 2 == 2 would leave nothing for read.
+	cases := []struct {
+		total int64
+		mode  RoundingMode
+		want  CacheTokenDistribution
+	}{
+		{1, RoundCeil, CacheTokenDistribution{InputTokens: 1}},
+		{2, RoundNearest, CacheTokenDistribution{InputTokens: 1, CacheCreationInputTokens: 1}},
+		{4, RoundCeil, CacheTokenDistribution{InputTokens: 2, CacheCreationInputTokens: 2}},
+		{5, RoundCeil, CacheTokenDistribution{InputTokens: 2, CacheCreationInputTokens: 2, CacheReadInputTokens: 1}},
+	}
+	for _, tc := range cases {
+		got := distributeWithMode(cfg, tc.total, tc.mode)
+		if got != tc.want {
+			t.Fatalf("total %d mode %d: got %+v, want %+v", tc.total, tc.mode, got, tc.want)
+		}
+		if got.CacheReadInputTokens < 0 || got.TotalInputTokens() != tc.total {
+			t.Fatalf("total %d mode %d: invalid distribution %+v", tc.total, tc.mode, got)
+		}
+	}
+}
+
+func TestOnDistribute(t *testing.T) {
+	if allocs := testing.AllocsPerRun(100, func() { DistributeCacheTokens(1000) }); allocs != 0 {
+		t.Fatalf("DistributeCacheTokens allocates %v times with a nil hook", allocs)
+	}
+
+	var mu sync.Mutex
+	var totals []int64
+	var seen []CacheTokenDistribution
+	SetOnDistribute(func(total int64, d CacheTokenDistribution) {
+		mu.Lock()
+		defer mu.Unlock()
+		totals = append(totals, total)
+		seen = append(seen, d)
+	})
+	t.Cleanup(func() { SetOnDistribute(nil) })
+
+	got := DistributeCacheTokens(1000)
+	DistributeCacheTokens(10)
+	if len(totals) != 2 || totals[0] != 1000 || totals[1] != 10 || seen[0] != got {
+		t.Fatalf("hook saw totals %v, distributions %+v; want [1000 10] and %+v first", totals, seen, got)
+	}
+
+	totals = nil
+	var into CacheTokenDistribution
+	DistributeInto(100, &into)
+	DistributeBatch([]int64{200, 300})
+	DefaultDistributor().Distribute(400)
+	DistributeWithKnownCacheRead(500, 50)
+	DistributeBootstrap(600)
+	if want := []int64{100, 200, 300, 400, 500, 600}; fmt.Sprint(totals) != fmt.Sprint(want) {
+		t.Fatalf("hook saw totals %v, want %v", totals, want)
+	}
+
+	// Distributing while the hook is replaced must not race.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				DistributeCacheTokens(1000)
+			}
+		}()
+	}
+	SetOnDistribute(func(int64, CacheTokenDistribution) {})
+	wg.Wait()
+}
+
+func TestDistributeCacheTokens_NegativeInput(t *testing.T) {
+	if got := DistributeCacheTokens(-500); got != (CacheTokenDistribution{}) {
+		t.Fatalf("DistributeCacheTokens(-500) = %+v, want zero value", got)
+	}
+	cfg := DistributionConfig{InputPart: 1, CreationPart: 1, ReadPart: 1, Threshold: 0}
+	for _, mode := range []RoundingMode{RoundFloor, RoundNearest, RoundCeil} {
+		if got := distributeWithMode(cfg, -500, mode); got != (CacheTokenDistribution{}) {
+			t.Fatalf("mode %d: got %+v, want zero value", mode, got)
+		}
+	}
+	got, err := DistributeCacheTokensWithRatio(-500, 0, 1, 1)
+	if err == nil || got != (CacheTokenDistribution{}) {
+		t.Fatalf("invalid ratio with negative total = %+v, %v", got, err)
+	}
+}
+
+func TestDistributeCacheTokens_MaxInt64(t *testing.T) {
+	for _, total := range []int64{math.MaxInt64, math.MaxInt64 / 25, math.MaxInt64/25 + 1} {
+		for _, mode := range []RoundingMode{RoundFloor, RoundNearest, RoundCeil} {
+			got := DistributeCacheTokensMode(total, mode)
+			if got.InputTokens < 0 || got.CacheCreationInputTokens < 0 || got.CacheReadInputTokens < 0 {
+				t.Fatalf("total %d mode %d: negative bucket %+v", total, mode, got)
+			}
+			if got.TotalInputTokens() != total {
+				t.Fatalf("total %d mode %d: TotalInputTokens() = %d", total, mode, got.TotalInputTokens())
+			}
+		}
+	}
+	// 9223372036854775807 = 28*329406144173384850 + 7
+	got := DistributeCacheTokens(math.MaxInt64)
+	want := CacheTokenDistribution{
+		InputTokens:              329406144173384850,
+		CacheCreationInputTokens: 658812288346769700,
+		CacheReadInputTokens:     math.MaxInt64 - 329406144173384850 - 658812288346769700,
+	}
+	if got != want {
+		t.Fatalf("DistributeCacheTokens(MaxInt64) = %+v, want %+v", got, want)
+	}
+}
+
+func TestDistributionConfig_RejectsOverflowingParts(t *testing.T) {
+	for _, cfg := range []DistributionConfig{
+		{InputPart: math.MaxInt64, CreationPart: 1, ReadPart: 1},
+		{InputPart: 1, CreationPart: math.MaxInt64, ReadPart: math.MaxInt64},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected error for overflowing parts %d:%d:%d", cfg.InputPart, cfg.CreationPart, cfg.ReadPart)
+		}
+	}
+}
+
+func TestCacheTokenDistribution_Add(t *testing.T) {
+	small := DistributeCacheTokens(42)
+	large := DistributeCacheTokens(1000)
+
+	merged := small.Add(large)
+	want := CacheTokenDistribution{InputTokens: 77, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}
+	if merged != want {
+		t.Fatalf("Add() = %+v, want %+v", merged, want)
+	}
+	// The merged buckets are summed as-is rather than re-distributed from the combined total.
+	if merged == DistributeCacheTokens(1042) {
+		t.Fatalf("Add() should not re-normalize the ratio")
+	}
+	if !merged.HasCacheTokens() {
+		t.Fatalf("merged distribution should report cache tokens")
+	}
+	if small.Add(DistributeCacheTokens(7)).HasCacheTokens() {
+		t.Fatalf("merging sub-threshold distributions should not report cache tokens")
+	}
+}
+
+func TestCacheTokenDistribution_Rates(t *testing.T) {
+	d := CacheTokenDistribution{InputTokens: 100, CacheCreationInputTokens: 150, CacheReadInputTokens: 750}
+	if got := d.CacheHitRate(); got != 0.75 {
+		t.Fatalf("CacheHitRate() = %v, want 0.75", got)
+	}
+	if got := d.CacheCreationRate(); got != 0.15 {
+		t.Fatalf("CacheCreationRate() = %v, want 0.15", got)
+	}
+	var zero CacheTokenDistribution
+	if zero.CacheHitRate() != 0 || zero.CacheCreationRate() != 0 {
+		t.Fatalf("rates of an empty distribution should be 0")
+	}
+	if got := DistributeCacheTokens(42).CacheHitRate(); got != 0 {
+		t.Fatalf("CacheHitRate() below threshold = %v, want 0", got)
+	}
+}
+
+func TestCacheTokenDistribution_Validate(t *testing.T) {
+	if err := DistributeCacheTokens(1000).Validate(); err != nil {
+		t.Fatalf("Validate() on distributed tokens = %v", err)
+	}
+	cases := []struct {
+		d    CacheTokenDistribution
+		want string
+	}{
+		{CacheTokenDistribution{InputTokens: -3}, "input_tokens must not be negative, got -3"},
+		{CacheTokenDistribution{InputTokens: 5, CacheCreationInputTokens: -1}, "cache_creation_input_tokens must not be negative, got -1"},
+		{CacheTokenDistribution{CacheReadInputTokens: -42}, "cache_read_input_tokens must not be negative, got -42"},
+		{CacheTokenDistribution{InputTokens: math.MaxInt64, CacheReadInputTokens: 1}, "total overflows"},
+	}
+	for _, tc := range cases {
+		err := tc.d.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("Validate(%+v) = %v, want error containing %q", tc.d, err, tc.want)
+		}
+	}
+}
+
+func TestCacheTokenDistribution_String(t *testing.T) {
+	d := DistributeCacheTokens(1000)
+	if got, want := d.String(), "input=35 creation=71 read=894 total=1000"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	if got, want := fmt.Sprint(CacheTokenDistribution{}), "input=0 creation=0 read=0 total=0"; got != want {
+		t.Fatalf("fmt.Sprint(zero) = %q, want %q", got, want)
+	}
+	if got, want := fmt.Sprintf("%v", NewUsageBlock(1000, 12)), "input=35 creation=71 read=894 total=1000 output=12"; got != want {
+		t.Fatalf("UsageBlock %%v = %q, want %q", got, want)
+	}
+}
+
+func TestDistributeBatch(t *testing.T) {
+	for _, totals := range [][]int64{nil, {}} {
+		got := DistributeBatch(totals)
+		if got == nil || len(got) != 0 {
+			t.Fatalf("DistributeBatch(%v) = %#v, want non-nil empty slice", totals, got)
+		}
+	}
+	totals := []int64{1000, 42, -5, 100}
+	got := DistributeBatch(totals)
+	if len(got) != len(totals) {
+		t.Fatalf("len(DistributeBatch) = %d, want %d", len(got), len(totals))
+	}
+	for i, total := range totals {
+		if want := DistributeCacheTokens(total); got[i] != want {
+			t.Fatalf("DistributeBatch[%d] = %v, want %v", i, got[i], want)
+		}
+	}
+}
+
+func TestDistributeStable_MatchesWholeDistribution(t *testing.T) {
+	// Segment sizes are kept small enough that their sum cannot overflow, and the
+	// mix of tiny and large segments exercises the threshold and one-token cases.
+	property := func(raw []uint32, tiny []uint8) bool {
+		segments := make([]int64, 0, len(raw)+len(tiny))
+		for _, v := range raw {
+			segments = append(segments, int64(v%50000))
+		}
+		for _, v := range tiny {
+			segments = append(segments, int64(v%4)-1)
+		}
+		got := DistributeStable(segments)
+		if len(got) != len(segments) {
+			return false
+		}
+		var total int64
+		for i, segment := range segments {
+			if got[i].Validate() != nil || got[i].TotalInputTokens() != max(segment, 0) {
+				return false
+			}
+			total += max(segment, 0)
+		}
+		return Sum(got...) == DistributeCacheTokens(total)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDistributeStable_DiffersFromPerSegment(t *testing.T) {
+	segments := []int64{150, 150, 150}
+	got := Sum(DistributeStable(segments)...)
+	if want := DistributeCacheTokens(450); got != want {
+		t.Fatalf("Sum(DistributeStable) = %v, want %v", got, want)
+	}
+	if perSegment := Sum(DistributeBatch(segments)...); perSegment == got {
+		t.Fatalf("expected per-segment distribution %v to drift from the whole", perSegment)
+	}
+	if got := DistributeStable(nil); got == nil || len(got) != 0 {
+		t.Fatalf("DistributeStable(nil) = %#v, want non-nil empty slice", got)
+	}
+}
+
+func TestDistributeFromContext(t *testing.T) {
+	if got, want := DistributeFromContext(context.Background(), 1000), DistributeCacheTokens(1000); got != want {
+		t.Fatalf("DistributeFromContext(no distributor) = %v, want %v", got, want)
+	}
+
+	tenant := &Distributor{InputPart: 1, CreationPart: 1, ReadPart: 2}
+	ctx := WithDistributor(context.Background(), tenant)
+	want := CacheTokenDistribution{InputTokens: 25, CacheCreationInputTokens: 25, CacheReadInputTokens: 50}
+	if got := DistributeFromContext(ctx, 100); got != want {
+		t.Fatalf("DistributeFromContext(tenant) = %v, want %v", got, want)
+	}
+
+	if got, want := DistributeFromContext(WithDistributor(ctx, nil), 1000), DistributeCacheTokens(1000); got != want {
+		t.Fatalf("DistributeFromContext(cleared) = %v, want %v", got, want)
+	}
+}
+
+func TestCacheTokenDistribution_Sub(t *testing.T) {
+	earlier := DistributeCacheTokens(1000)
+	later := earlier.Add(DistributeCacheTokens(280))
+	if got := later.Sub(earlier); got != DistributeCacheTokens(280) {
+		t.Fatalf("Sub() = %v, want %v", got, DistributeCacheTokens(280))
+	}
+	if got := later.Sub(earlier).Add(earlier); got != later {
+		t.Fatalf("Sub then Add = %v, want %v", got, later)
+	}
+
+	// Inverted snapshots clamp each bucket independently instead of going negative.
+	skewed := CacheTokenDistribution{InputTokens: 10, CacheCreationInputTokens: 50, CacheReadInputTokens: 900}
+	got := skewed.Sub(CacheTokenDistribution{InputTokens: 4, CacheCreationInputTokens: 80, CacheReadInputTokens: 1000})
+	want := CacheTokenDistribution{InputTokens: 6}
+	if got != want {
+		t.Fatalf("Sub() with larger earlier = %v, want %v", got, want)
+	}
+}
+
+func TestCacheTokenDistribution_Scale(t *testing.T) {
+	for _, d := range []CacheTokenDistribution{
+		{},
+		DistributeCacheTokens(1000),
+		DistributeCacheTokens(99),
+		{InputTokens: 7, CacheCreationInputTokens: 13, CacheReadInputTokens: 1, CacheCreation: CacheCreation{Ephemeral5mInputTokens: 4, Ephemeral1hInputTokens: 9}},
+		{InputTokens: 1 << 50, CacheReadInputTokens: 3},
+	} {
+		if got := d.Scale(1.0); got != d {
+			t.Fatalf("%v.Scale(1) = %v", d, got)
+		}
+	}
+
+	d := DistributeCacheTokens(1000)
+	for _, factor := range []float64{0.5, 1.5, 2, 2.333, 0.001, 1e6} {
+		got := d.Scale(factor)
+		if want := int64(math.Floor(1000*factor + 0.5)); got.TotalInputTokens() != want {
+			t.Fatalf("Scale(%v) total = %d, want %d", factor, got.TotalInputTokens(), want)
+		}
+		if err := got.Validate(); err != nil {
+			t.Fatalf("Scale(%v) = %v: %v", factor, got, err)
+		}
+	}
+	if got, want := d.Scale(2), (CacheTokenDistribution{InputTokens: 70, CacheCreationInputTokens: 142, CacheReadInputTokens: 1788}); got != want {
+		t.Fatalf("Scale(2) = %v, want %v", got, want)
+	}
+
+	// Input and creation both round a half up, overshooting the rounded total of 1.
+	halves := CacheTokenDistribution{InputTokens: 1, CacheCreationInputTokens: 1}
+	if got, want := halves.Scale(0.5), (CacheTokenDistribution{InputTokens: 1}); got != want {
+		t.Fatalf("Scale(0.5) of %v = %v, want %v", halves, got, want)
+	}
+
+	split, err := d.SplitCacheCreation(0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := split.Scale(3); got.Validate() != nil || got.CacheCreation.Ephemeral1hInputTokens != 3*split.CacheCreation.Ephemeral1hInputTokens {
+		t.Fatalf("Scale(3) of %+v = %+v", split, got)
+	}
+
+	for _, factor := range []float64{-1, 0, math.NaN()} {
+		if got := d.Scale(factor); got != (CacheTokenDistribution{}) {
+			t.Fatalf("Scale(%v) = %v, want zero", factor, got)
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	if got := Sum(); got != (CacheTokenDistribution{}) {
+		t.Fatalf("Sum() = %+v, want zero value", got)
+	}
+	chunks := []CacheTokenDistribution{DistributeCacheTokens(1000), DistributeCacheTokens(100), DistributeCacheTokens(42)}
+	got := Sum(chunks...)
+	if got != chunks[0].Add(chunks[1]).Add(chunks[2]) {
+		t.Fatalf("Sum() = %+v does not match chained Add", got)
+	}
+	if got.TotalInputTokens() != 1142 {
+		t.Fatalf("TotalInputTokens() = %d, want 1142", got.TotalInputTokens())
+	}
+}
+
+func TestCacheTokenDistribution_MarshalJSON(t *testing.T) {
+	got, err := json.Marshal(DistributeCacheTokens(42))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(got) != `{"input_tokens":42}` {
+		t.Fatalf("below threshold = %s, want {\"input_tokens\":42}", got)
+	}
+
+	got, err = json.Marshal(DistributeCacheTokens(1000))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"input_tokens":35,"cache_creation_input_tokens":71,"cache_read_input_tokens":894}`
+	if string(got) != want {
+		t.Fatalf("distributed = %s, want %s", got, want)
+	}
+
+	var decoded CacheTokenDistribution
+	if err = json.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded != DistributeCacheTokens(1000) {
+		t.Fatalf("round trip = %+v", decoded)
+	}
+}
+
+func TestUsageBlock_MarshalJSON(t *testing.T) {
+	got, err := json.Marshal(NewUsageBlock(42, 7))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(got) != `{"input_tokens":42,"output_tokens":7}` {
+		t.Fatalf("below threshold block = %s", got)
+	}
+	got, err = json.Marshal(NewUsageBlock(1000, 0))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"input_tokens":35,"cache_creation_input_tokens":71,"cache_read_input_tokens":894,"output_tokens":0}`
+	if string(got) != want {
+		t.Fatalf("distributed block = %s, want %s", got, want)
+	}
+}
+
+// mixedUsageFixture mimics a Kiro gateway usage object that string-encodes some counts.
+const mixedUsageFixture = `{"input_tokens":"35","cache_creation_input_tokens":71,"cache_read_input_tokens":" 894 ","output_tokens":"12"}`
+
+func TestDistributeCacheTokensWithTTL(t *testing.T) {
+	d, err := DistributeCacheTokensWithTTL(1000, 0.25)
+	if err != nil {
+		t.Fatalf("DistributeCacheTokensWithTTL: %v", err)
+	}
+	base := DistributeCacheTokens(1000)
+	if d.InputTokens != base.InputTokens || d.CacheCreationInputTokens != base.CacheCreationInputTokens || d.CacheReadInputTokens != base.CacheReadInputTokens {
+		t.Fatalf("tiered distribution %+v changed the buckets of %+v", d, base)
+	}
+	if got := d.CacheCreation.Ephemeral5mInputTokens + d.CacheCreation.Ephemeral1hInputTokens; got != d.CacheCreationInputTokens {
+		t.Fatalf("tiers sum to %d, want %d", got, d.CacheCreationInputTokens)
+	}
+	if d.CacheCreation.Ephemeral1hInputTokens != 18 {
+		t.Fatalf("1h tier = %d, want 18 (a quarter of 71, rounded)", d.CacheCreation.Ephemeral1hInputTokens)
+	}
+	if err = d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	raw, _ := json.Marshal(d)
+	if !strings.Contains(string(raw), `"cache_creation":{"ephemeral_5m_input_tokens":53,"ephemeral_1h_input_tokens":18}`) {
+		t.Fatalf("MarshalJSON = %s", raw)
+	}
+	var decoded CacheTokenDistribution
+	if err = json.Unmarshal(raw, &decoded); err != nil || decoded != d {
+		t.Fatalf("round trip = %+v, %v; want %+v", decoded, err, d)
+	}
+
+	for _, weight := range []float64{-0.1, 1.5, math.NaN()} {
+		if got, err := DistributeCacheTokensWithTTL(1000, weight); err == nil || !got.CacheCreation.IsZero() {
+			t.Fatalf("weight %v = %+v, %v; want an untiered distribution and an error", weight, got, err)
+		}
+	}
+}
+
+func TestCacheTokenDistribution_DefaultOmitsCacheCreation(t *testing.T) {
+	raw, _ := json.Marshal(DistributeCacheTokens(1000))
+	if strings.Contains(string(raw), "cache_creation\"") || !strings.Contains(string(raw), `"cache_creation_input_tokens":71`) {
+		t.Fatalf("MarshalJSON = %s, want only cache_creation_input_tokens", raw)
+	}
+	bad := CacheTokenDistribution{CacheCreationInputTokens: 10, CacheCreation: CacheCreation{Ephemeral5mInputTokens: 4, Ephemeral1hInputTokens: 5}}
+	if err := bad.Validate(); err == nil {
+		t.Fatal("expected tiers that do not sum to cache creation to fail validation")
+	}
+}
+
+func TestCacheTokenDistribution_UnmarshalJSONStringNumbers(t *testing.T) {
+	var got CacheTokenDistribution
+	if err := json.Unmarshal([]byte(mixedUsageFixture), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got != DistributeCacheTokens(1000) {
+		t.Fatalf("Unmarshal(mixed) = %+v, want %+v", got, DistributeCacheTokens(1000))
+	}
+
+	var block UsageBlock
+	if err := json.Unmarshal([]byte(mixedUsageFixture), &block); err != nil {
+		t.Fatalf("Unmarshal block: %v", err)
+	}
+	if block != NewUsageBlock(1000, 12) {
+		t.Fatalf("Unmarshal(mixed) block = %+v, want %+v", block, NewUsageBlock(1000, 12))
+	}
+
+	var partial CacheTokenDistribution
+	if err := json.Unmarshal([]byte(`{"input_tokens":"42","cache_read_input_tokens":null}`), &partial); err != nil {
+		t.Fatalf("Unmarshal partial: %v", err)
+	}
+	if partial != (CacheTokenDistribution{InputTokens: 42}) {
+		t.Fatalf("Unmarshal(partial) = %+v", partial)
+	}
+}
+
+func TestCacheTokenDistribution_UnmarshalJSONRejectsNonNumeric(t *testing.T) {
+	cases := map[string]string{
+		`{"input_tokens":"many"}`:                 "input_tokens",
+		`{"cache_creation_input_tokens":"1.5"}`:   "cache_creation_input_tokens",
+		`{"cache_read_input_tokens":true}`:        "cache_read_input_tokens",
+		`{"input_tokens":2.5}`:                    "input_tokens",
+		`{"input_tokens":"99999999999999999999"}`: "input_tokens",
+	}
+	for input, field := range cases {
+		var d CacheTokenDistribution
+		err := json.Unmarshal([]byte(input), &d)
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Fatalf("Unmarshal(%s) = %v, want error naming %s", input, err, field)
+		}
+	}
+	var block UsageBlock
+	if err := json.Unmarshal([]byte(`{"input_tokens":1,"output_tokens":"x"}`), &block); err == nil || !strings.Contains(err.Error(), "output_tokens") {
+		t.Fatalf("Unmarshal block = %v, want error naming output_tokens", err)
+	}
+}
+
+func TestFromClaudeUsage(t *testing.T) {
+	got := FromClaudeUsage(10, 20, 700)
+	want := CacheTokenDistribution{InputTokens: 10, CacheCreationInputTokens: 20, CacheReadInputTokens: 700}
+	if got != want {
+		t.Fatalf("FromClaudeUsage() = %+v, want %+v", got, want)
+	}
+	if got.TotalInputTokens() != CollapseToInput(10, 20, 700) {
+		t.Fatalf("TotalInputTokens() = %d, want %d", got.TotalInputTokens(), CollapseToInput(10, 20, 700))
+	}
+}
+
+func TestCollapseThenDistributeIsIdempotent(t *testing.T) {
+	for _, upstream := range []CacheTokenDistribution{
+		FromClaudeUsage(42, 0, 0),
+		FromClaudeUsage(10, 20, 700),
+		FromClaudeUsage(500, 0, 1500),
+		DistributeCacheTokens(123457),
+	} {
+		total := CollapseToInput(upstream.InputTokens, upstream.CacheCreationInputTokens, upstream.CacheReadInputTokens)
+		once := DistributeCacheTokens(total)
+		if once.TotalInputTokens() != upstream.TotalInputTokens() {
+			t.Fatalf("%+v: redistributed total = %d, want %d", upstream, once.TotalInputTokens(), upstream.TotalInputTokens())
+		}
+		twice := DistributeCacheTokens(CollapseToInput(once.InputTokens, once.CacheCreationInputTokens, once.CacheReadInputTokens))
+		if twice != once {
+			t.Fatalf("%+v: second round trip = %+v, want %+v", upstream, twice, once)
+		}
+	}
+}
+
+func TestDistributor_ThresholdBoundary(t *testing.T) {
+	d := Distributor{Threshold: 28, InputPart: 1, CreationPart: 2, ReadPart: 25}
+	if got := d.Distribute(27); got != (CacheTokenDistribution{InputTokens: 27}) {
+		t.Fatalf("below threshold = %+v, want all input", got)
+	}
+	// A total exactly equal to the threshold is distributed.
+	want := CacheTokenDistribution{InputTokens: 1, CacheCreationInputTokens: 2, CacheReadInputTokens: 25}
+	if got := d.Distribute(28); got != want {
+		t.Fatalf("at threshold = %+v, want %+v", got, want)
+	}
+	if got := DistributeCacheTokens(DistributionThreshold); !got.HasCacheTokens() {
+		t.Fatalf("default distributor should distribute at the threshold, got %+v", got)
+	}
+	if got := DistributeCacheTokens(DistributionThreshold - 1); got.HasCacheTokens() {
+		t.Fatalf("default distributor should not distribute below the threshold, got %+v", got)
+	}
+}
+
+func TestDistributor_MinCacheRead(t *testing.T) {
+	d := DefaultDistributor()
+	if got := d.Distribute(100); got != (CacheTokenDistribution{InputTokens: 3, CacheCreationInputTokens: 7, CacheReadInputTokens: 90}) {
+		t.Fatalf("without a floor = %+v", got)
+	}
+	cases := []struct {
+		floor int64
+		total int64
+		want  CacheTokenDistribution
+	}{
+		{floor: 80, total: 100, want: CacheTokenDistribution{InputTokens: 3, CacheCreationInputTokens: 7, CacheReadInputTokens: 90}},
+		{floor: 92, total: 100, want: CacheTokenDistribution{InputTokens: 1, CacheCreationInputTokens: 7, CacheReadInputTokens: 92}},
+		{floor: 95, total: 100, want: CacheTokenDistribution{InputTokens: 0, CacheCreationInputTokens: 5, CacheReadInputTokens: 95}},
+		{floor: 100, total: 100, want: CacheTokenDistribution{CacheReadInputTokens: 100}},
+		{floor: 500, total: 100, want: CacheTokenDistribution{CacheReadInputTokens: 100}},
+		// The floor only applies to distributed totals.
+		{floor: 500, total: 99, want: CacheTokenDistribution{InputTokens: 99}},
+	}
+	for _, tc := range cases {
+		d.MinCacheRead = tc.floor
+		got := d.Distribute(tc.total)
+		if got != tc.want {
+			t.Errorf("floor %d, total %d = %+v, want %+v", tc.floor, tc.total, got, tc.want)
+		}
+		if got.TotalInputTokens() != tc.total {
+			t.Errorf("floor %d, total %d sums to %d", tc.floor, tc.total, got.TotalInputTokens())
+		}
+	}
+	if err := (Distributor{InputPart: 1, CreationPart: 2, ReadPart: 25, MinCacheRead: -1}).Validate(); err == nil {
+		t.Fatal("expected a negative floor to be rejected")
+	}
+}
+
+func TestDistributor_IndependentInstances(t *testing.T) {
+	low := Distributor{Threshold: 10, InputPart: 1, CreationPart: 1, ReadPart: 2}
+	high := DefaultDistributor()
+	if got := low.Distribute(40); got != (CacheTokenDistribution{InputTokens: 10, CacheCreationInputTokens: 10, CacheReadInputTokens: 20}) {
+		t.Fatalf("low threshold distributor = %+v", got)
+	}
+	if got := high.Distribute(40); got.HasCacheTokens() {
+		t.Fatalf("default distributor should not distribute 40 tokens, got %+v", got)
+	}
+	if DistributeCacheTokens(1000) != high.Distribute(1000) {
+		t.Fatalf("DistributeCacheTokens should match DefaultDistributor")
+	}
+}
+
+func TestDistributor_InvalidReturnsAllInput(t *testing.T) {
+	d := Distributor{Threshold: 0, InputPart: 1, CreationPart: 0, ReadPart: 1}
+	if err := d.Validate(); err == nil {
+		t.Fatalf("expected validation error")
+	}
+	if got := d.Distribute(500); got != (CacheTokenDistribution{InputTokens: 500}) {
+		t.Fatalf("invalid distributor = %+v, want all input", got)
+	}
+}
+
+func TestFromGeminiUsage(t *testing.T) {
+	cases := []struct {
+		name           string
+		prompt, cached int64
+		want           CacheTokenDistribution
+	}{
+		{"partially cached", 1000, 600, CacheTokenDistribution{InputTokens: 400, CacheReadInputTokens: 600}},
+		{"no cache", 1000, 0, CacheTokenDistribution{InputTokens: 1000}},
+		{"cached exceeds prompt", 100, 150, CacheTokenDistribution{CacheReadInputTokens: 150}},
+		{"negative cached", 50, -5, CacheTokenDistribution{InputTokens: 50}},
+	}
+	for _, tc := range cases {
+		got := FromGeminiUsage(tc.prompt, tc.cached)
+		if got != tc.want {
+			t.Fatalf("%s: FromGeminiUsage(%d, %d) = %+v, want %+v", tc.name, tc.prompt, tc.cached, got, tc.want)
+		}
+		if got.CacheCreationInputTokens != 0 {
+			t.Fatalf("%s: Gemini usage must not report cache creation", tc.name)
+		}
+	}
+}
+
+func TestFromOpenAIUsage(t *testing.T) {
+	cases := []struct {
+		name           string
+		prompt, cached int64
+		want           CacheTokenDistribution
+	}{
+		{"partially cached", 2048, 1024, CacheTokenDistribution{InputTokens: 1024, CacheReadInputTokens: 1024}},
+		{"cached_tokens absent", 300, 0, CacheTokenDistribution{InputTokens: 300}},
+		{"cached exceeds prompt", 100, 150, CacheTokenDistribution{CacheReadInputTokens: 150}},
+	}
+	for _, tc := range cases {
+		if got := FromOpenAIUsage(tc.prompt, tc.cached); got != tc.want {
+			t.Fatalf("%s: FromOpenAIUsage(%d, %d) = %+v, want %+v", tc.name, tc.prompt, tc.cached, got, tc.want)
+		}
+	}
+}
+
+func TestProtoFields_RoundTrip(t *testing.T) {
+	property := func(input, creation, read int64) bool {
+		d := CacheTokenDistribution{
+			InputTokens:              max(input, -input, 0),
+			CacheCreationInputTokens: max(creation, -creation, 0),
+			CacheReadInputTokens:     max(read, -read, 0),
+		}
+		return FromProtoFields(d.ToProtoFields()) == d
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProtoFields_Clamps(t *testing.T) {
+	input, creation, read := CacheTokenDistribution{InputTokens: -5, CacheCreationInputTokens: 7, CacheReadInputTokens: -1}.ToProtoFields()
+	if input != 0 || creation != 7 || read != 0 {
+		t.Fatalf("ToProtoFields = %d, %d, %d; want 0, 7, 0", input, creation, read)
+	}
+	if got := FromProtoFields(math.MaxUint64, 1, 0); got.InputTokens != math.MaxInt64 || got.CacheCreationInputTokens != 1 {
+		t.Fatalf("FromProtoFields(MaxUint64, 1, 0) = %+v, want saturated input", got)
+	}
+}
+
+func TestDistributor_FractionalWeights(t *testing.T) {
+	fractional := Distributor{InputPart: 0.1, CreationPart: 0.3, ReadPart: 9.6}
+	whole := Distributor{InputPart: 1, CreationPart: 3, ReadPart: 96}
+	for _, total := range []int64{1, 99, 100, 1000, 123457, math.MaxInt64} {
+		if got, want := fractional.Distribute(total), whole.Distribute(total); got != want {
+			t.Fatalf("Distribute(%d) with 0.1:0.3:9.6 = %+v, want %+v as with 1:3:96", total, got, want)
+		}
+	}
+	want := CacheTokenDistribution{InputTokens: 10, CacheCreationInputTokens: 30, CacheReadInputTokens: 960}
+	if got := fractional.Distribute(1000); got != want {
+		t.Fatalf("Distribute(1000) = %+v, want %+v", got, want)
+	}
+
+	multipliers := Distributor{InputPart: 1, CreationPart: 1.25, ReadPart: 10, Rounding: RoundNearest}
+	if got := multipliers.Distribute(1000); got != (CacheTokenDistribution{InputTokens: 82, CacheCreationInputTokens: 102, CacheReadInputTokens: 816}) {
+		t.Fatalf("Distribute(1000) with 1:1.25:10 = %+v", got)
+	}
+}
+
+func TestDistributor_FractionalWeightsPreserveTotal(t *testing.T) {
+	// 1/3 has no exact decimal scale, so these weights take the floating-point path.
+	irrational := Distributor{InputPart: 1.0 / 3, CreationPart: math.Pi, ReadPart: math.Sqrt2}
+	if _, _, _, ok := irrational.integerParts(); ok {
+		t.Fatalf("expected %+v to need floating-point distribution", irrational)
+	}
+	property := func(total int64, a, b, c uint16, mode uint8) bool {
+		d := Distributor{
+			InputPart:    float64(a)/100 + 0.01,
+			CreationPart: float64(b)/7 + 0.01,
+			ReadPart:     float64(c)/3 + 0.01,
+			Rounding:     RoundingMode(mode % 3),
+		}
+		for _, dist := range []Distributor{d, irrational} {
+			got := dist.Distribute(total)
+			if got.Validate() != nil || got.TotalInputTokens() != max(total, 0) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDistributor_RejectsNonFiniteWeights(t *testing.T) {
+	for _, d := range []Distributor{
+		{InputPart: math.NaN(), CreationPart: 1, ReadPart: 1},
+		{InputPart: 1, CreationPart: math.Inf(1), ReadPart: 1},
+		{InputPart: math.MaxFloat64, CreationPart: math.MaxFloat64, ReadPart: 1},
+		{InputPart: 0.5, CreationPart: -0.5, ReadPart: 1},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Fatalf("Validate(%+v) = nil, want error", d)
+		}
+		if got := d.Distribute(500); got != (CacheTokenDistribution{InputTokens: 500}) {
+			t.Fatalf("invalid distributor %+v = %+v, want all input", d, got)
+		}
+	}
+}
+
+func TestDistributeInto(t *testing.T) {
+	for _, total := range []int64{-5, 0, 1, DistributionThreshold - 1, DistributionThreshold, 1000, 123456789, math.MaxInt64} {
+		out := CacheTokenDistribution{InputTokens: 7, CacheCreationInputTokens: 8, CacheReadInputTokens: 9}
+		DistributeInto(total, &out)
+		if want := DistributeCacheTokens(total); out != want {
+			t.Fatalf("DistributeInto(%d) = %+v, want %+v", total, out, want)
+		}
+	}
+	DistributeInto(1000, nil)
+}
+
+func TestDistributeWithKnownCacheRead(t *testing.T) {
+	cases := []struct {
+		total, known int64
+		want         CacheTokenDistribution
+	}{
+		{total: 1000, known: 700, want: CacheTokenDistribution{InputTokens: 100, CacheCreationInputTokens: 200, CacheReadInputTokens: 700}},
+		// Floor rounding leaves the odd token in creation.
+		{total: 1000, known: 0, want: CacheTokenDistribution{InputTokens: 333, CacheCreationInputTokens: 667}},
+		// Below DistributionThreshold the remainder is still split, the read being real.
+		{total: 10, known: 4, want: CacheTokenDistribution{InputTokens: 2, CacheCreationInputTokens: 4, CacheReadInputTokens: 4}},
+		{total: 1000, known: 1000, want: CacheTokenDistribution{CacheReadInputTokens: 1000}},
+		{total: 1000, known: 5000, want: CacheTokenDistribution{CacheReadInputTokens: 1000}},
+		{total: 1000, known: -5, want: CacheTokenDistribution{InputTokens: 333, CacheCreationInputTokens: 667}},
+		{total: -1, known: 10, want: CacheTokenDistribution{}},
+	}
+	for _, tc := range cases {
+		got := DistributeWithKnownCacheRead(tc.total, tc.known)
+		if got != tc.want {
+			t.Errorf("DistributeWithKnownCacheRead(%d, %d) = %+v, want %+v", tc.total, tc.known, got, tc.want)
+		}
+		if tc.total > 0 && got.TotalInputTokens() != tc.total {
+			t.Errorf("DistributeWithKnownCacheRead(%d, %d) sums to %d", tc.total, tc.known, got.TotalInputTokens())
+		}
+	}
+	passThrough := Distributor{PassThrough: true}
+	if got := passThrough.DistributeWithKnownCacheRead(1000, 600); got != (CacheTokenDistribution{InputTokens: 400, CacheReadInputTokens: 600}) {
+		t.Fatalf("pass-through = %+v", got)
+	}
+}
+
+func TestDistributeBootstrap(t *testing.T) {
+	cases := []struct {
+		total int64
+		want  CacheTokenDistribution
+	}{
+		{total: 1000, want: CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 965}},
+		{total: 28000, want: CacheTokenDistribution{InputTokens: 1000, CacheCreationInputTokens: 27000}},
+		{total: DistributionThreshold - 1, want: CacheTokenDistribution{InputTokens: DistributionThreshold - 1}},
+		{total: -1, want: CacheTokenDistribution{}},
+	}
+	for _, tc := range cases {
+		got := DistributeBootstrap(tc.total)
+		if got != tc.want {
+			t.Errorf("DistributeBootstrap(%d) = %+v, want %+v", tc.total, got, tc.want)
+		}
+		if tc.total > 0 && got.TotalInputTokens() != tc.total {
+			t.Errorf("DistributeBootstrap(%d) sums to %d", tc.total, got.TotalInputTokens())
+		}
+	}
+	// The input share matches the standard distribution; only read moves to creation.
+	if standard, bootstrap := DistributeCacheTokens(123457), DistributeBootstrap(123457); bootstrap.InputTokens != standard.InputTokens {
+		t.Fatalf("bootstrap input %d, standard input %d", bootstrap.InputTokens, standard.InputTokens)
+	}
+	passThrough := PassThroughDistributor()
+	if got := passThrough.DistributeBootstrap(1000); got != (CacheTokenDistribution{InputTokens: 1000}) {
+		t.Fatalf("pass-through = %+v", got)
+	}
+}
+
+func TestDistributeFull(t *testing.T) {
+	for _, tc := range []struct{ input, output int64 }{{0, 0}, {1000, 250}, {DistributionThreshold - 1, 7}, {5000, -3}} {
+		d, output := DistributeFull(tc.input, tc.output)
+		if want := DistributeCacheTokens(tc.input); d != want {
+			t.Fatalf("DistributeFull(%d, %d) distribution = %+v, want %+v", tc.input, tc.output, d, want)
+		}
+		if output != tc.output {
+			t.Fatalf("DistributeFull(%d, %d) output = %d, want it unchanged", tc.input, tc.output, output)
+		}
+	}
+}
+
+// distributionSink keeps the benchmarked results live. Run with -benchmem: both
+// variants report 0 allocs/op, since the distribution is a value type; any hot path
+// allocation comes from boxing it, for example into a log field.
+var distributionSink CacheTokenDistribution
+
+func BenchmarkDistributeCacheTokens(b *testing.B) {
+	b.ReportAllocs()
+	for i := range b.N {
+		distributionSink = DistributeCacheTokens(int64(1000 + i%4096))
+	}
+}
+
+var distributionPool = sync.Pool{New: func() any { return new(CacheTokenDistribution) }}
+
+func BenchmarkDistributeInto(b *testing.B) {
+	b.ReportAllocs()
+	for i := range b.N {
+		out := distributionPool.Get().(*CacheTokenDistribution)
+		DistributeInto(int64(1000+i%4096), out)
+		distributionSink = *out
+		distributionPool.Put(out)
+	}
+}
+
+func TestPassThroughDistributor(t *testing.T) {
+	d := PassThroughDistributor()
+	if err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for _, total := range []int64{1, DistributionThreshold, 1000, math.MaxInt64} {
+		if got := d.Distribute(total); got != (CacheTokenDistribution{InputTokens: total}) {
+			t.Fatalf("Distribute(%d) = %+v, want all input", total, got)
+		}
+	}
+	if got := d.Distribute(-5); got != (CacheTokenDistribution{}) {
+		t.Fatalf("Distribute(-5) = %+v, want zero value", got)
+	}
+
+	flagged := DefaultDistributor()
+	flagged.PassThrough = true
+	flagged.Threshold = 0
+	if got := flagged.Distribute(1000); got != (CacheTokenDistribution{InputTokens: 1000}) {
+		t.Fatalf("pass-through flag with ratio parts = %+v, want all input", got)
+	}
+}
+
+func TestDistributeCacheTokensForProvider_PassThrough(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetProviderDistributors(nil)
+		_ = SetModelRatios(nil)
+	})
+	if err := SetProviderDistributors(map[string]Distributor{"Kiro": PassThroughDistributor()}); err != nil {
+		t.Fatalf("SetProviderDistributors: %v", err)
+	}
+	if err := SetModelRatios(map[string]Ratio{"claude-sonnet": {Input: 1, Creation: 1, Read: 2}}); err != nil {
+		t.Fatalf("SetModelRatios: %v", err)
+	}
+	if got := DistributeCacheTokensForProvider("kiro", "claude-sonnet-4.5", 1000); got != (CacheTokenDistribution{InputTokens: 1000}) {
+		t.Fatalf("expected the pass-through provider to ignore the model ratio, got %+v", got)
+	}
+	if SimulatesCacheUsage("kiro") {
+		t.Fatalf("expected a pass-through kiro not to report simulated cache usage")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    CacheTokenDistribution
+		total int64
+		want  CacheTokenDistribution
+	}{
+		{"bumped input", CacheTokenDistribution{InputTokens: 135, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}, 1000,
+			CacheTokenDistribution{InputTokens: 135, CacheCreationInputTokens: 71, CacheReadInputTokens: 794}},
+		{"input and creation exceed total", CacheTokenDistribution{InputTokens: 700, CacheCreationInputTokens: 500, CacheReadInputTokens: 10}, 1000,
+			CacheTokenDistribution{InputTokens: 700, CacheCreationInputTokens: 300}},
+		{"input alone exceeds total", CacheTokenDistribution{InputTokens: 1200, CacheCreationInputTokens: 5}, 1000,
+			CacheTokenDistribution{InputTokens: 1000}},
+		{"negative buckets", CacheTokenDistribution{InputTokens: -5, CacheCreationInputTokens: -1}, 10,
+			CacheTokenDistribution{CacheReadInputTokens: 10}},
+		{"negative total", DistributeCacheTokens(1000), -1, CacheTokenDistribution{}},
+		{"tiers trimmed", CacheTokenDistribution{InputTokens: 990, CacheCreationInputTokens: 20, CacheCreation: CacheCreation{Ephemeral5mInputTokens: 8, Ephemeral1hInputTokens: 12}}, 1000,
+			CacheTokenDistribution{InputTokens: 990, CacheCreationInputTokens: 10, CacheCreation: CacheCreation{Ephemeral1hInputTokens: 10}}},
+	}
+	for _, tc := range cases {
+		got := tc.in.Normalize(tc.total)
+		if got != tc.want {
+			t.Fatalf("%s: Normalize(%d) = %+v, want %+v", tc.name, tc.total, got, tc.want)
+		}
+		if again := got.Normalize(tc.total); again != got {
+			t.Fatalf("%s: Normalize is not idempotent: %+v then %+v", tc.name, got, again)
+		}
+		if tc.total >= 0 && got.RoundingResidual(tc.total) != 0 {
+			t.Fatalf("%s: normalized distribution %+v does not sum to %d", tc.name, got, tc.total)
+		}
+	}
+}
+
+func TestRoundingResidual(t *testing.T) {
+	property := func(total int64) bool {
+		total = max(total, 0)
+		return DistributeCacheTokens(total).RoundingResidual(total) == 0
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Fatal(err)
+	}
+	for _, total := range []int64{0, 1, DistributionThreshold - 1, DistributionThreshold, 1001, math.MaxInt64} {
+		if residual := DistributeCacheTokens(total).RoundingResidual(total); residual != 0 {
+			t.Fatalf("residual for %d = %d, want 0", total, residual)
+		}
+	}
+
+	tampered := CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 890}
+	if residual := tampered.RoundingResidual(1000); residual != 4 {
+		t.Fatalf("tampered residual = %d, want 4", residual)
+	}
+}
+
+func TestNewDistributor(t *testing.T) {
+	d, err := NewDistributor()
+	if err != nil || d != DefaultDistributor() {
+		t.Fatalf("NewDistributor() = %+v, %v; want the default distributor", d, err)
+	}
+
+	d, err = NewDistributor(WithThreshold(0), WithRatio(1, 3, 96), WithRounding(RoundNearest))
+	if err != nil {
+		t.Fatalf("NewDistributor: %v", err)
+	}
+	if d.Threshold != 0 || d.InputPart != 1 || d.CreationPart != 3 || d.ReadPart != 96 || d.Rounding != RoundNearest {
+		t.Fatalf("unexpected distributor: %+v", d)
+	}
+	if got := d.Distribute(50); got.TotalInputTokens() != 50 || got.CacheReadInputTokens == 0 {
+		t.Fatalf("expected a zero threshold to distribute small totals, got %+v", got)
+	}
+
+	d, err = NewDistributor(WithPassThrough(), WithThreshold(10))
+	if err != nil || !d.PassThrough {
+		t.Fatalf("NewDistributor(WithPassThrough()) = %+v, %v", d, err)
+	}
+	if got := d.Distribute(1000); got != (CacheTokenDistribution{InputTokens: 1000}) {
+		t.Fatalf("pass-through distribution = %+v", got)
+	}
+}
+
+func TestDistributionError_Field(t *testing.T) {
+	t.Cleanup(func() { _ = SetProviderDistributionConfigs(nil) })
+
+	_, errOption := NewDistributor(WithRatio(0, 0, 0))
+	errProvider := SetProviderDistributionConfigs(map[string]DistributionConfig{"kiro": {InputPart: 1, CreationPart: 2, ReadPart: 0}})
+	cases := []struct {
+		name  string
+		err   error
+		field string
+	}{
+		{"distributor zero ratio", Distributor{InputPart: 1, CreationPart: 0, ReadPart: 25}.Validate(), "creation-part"},
+		{"config zero ratio", DistributionConfig{InputPart: 0, CreationPart: 2, ReadPart: 25}.Validate(), "input-part"},
+		{"model zero ratio", Ratio{Input: 1, Creation: 2, Read: 0}.Validate(), "read-part"},
+		{"option zero ratio", errOption, "input-part"},
+		{"wrapped provider zero ratio", errProvider, "read-part"},
+		{"negative threshold", DistributionConfig{InputPart: 1, CreationPart: 2, ReadPart: 25, Threshold: -1}.Validate(), "threshold"},
+		{"negative floor", Distributor{InputPart: 1, CreationPart: 2, ReadPart: 25, MinCacheRead: -1}.Validate(), "min-cache-read"},
+	}
+	for _, tc := range cases {
+		var errDist *DistributionError
+		if !errors.As(tc.err, &errDist) {
+			t.Errorf("%s: error %v is not a *DistributionError", tc.name, tc.err)
+			continue
+		}
+		if errDist.Field != tc.field || errDist.Reason == "" {
+			t.Errorf("%s: got field %q reason %q, want field %q", tc.name, errDist.Field, errDist.Reason, tc.field)
+		}
+	}
+	if got := errProvider.Error(); got != `provider "kiro": usage: cache distribution read-part: must be positive, got 0` {
+		t.Errorf("wrapped error message = %q", got)
+	}
+}
+
+func TestNewDistributor_RejectsInvalidOptions(t *testing.T) {
+	cases := map[string][]Option{
+		"zero ratio":          {WithRatio(0, 0, 0)},
+		"zero ratio part":     {WithRatio(1, 0, 25)},
+		"negative ratio part": {WithRatio(1, -2, 25)},
+		"negative threshold":  {WithThreshold(-1)},
+		"negative floor":      {WithMinCacheRead(-1)},
+		"unknown rounding":    {WithRounding(RoundingMode(42))},
+		"pass-through ratio":  {WithPassThrough(), WithRatio(1, 2, 25)},
+	}
+	for name, opts := range cases {
+		if d, err := NewDistributor(opts...); err == nil {
+			t.Errorf("%s: expected an error, got %+v", name, d)
+		}
+	}
+}
+
+func TestCacheTokenDistribution_GobRoundTrip(t *testing.T) {
+	roundTrip := func(d CacheTokenDistribution) bool {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(d); err != nil {
+			t.Logf("Encode(%+v): %v", d, err)
+			return false
+		}
+		var decoded CacheTokenDistribution
+		if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+			t.Logf("Decode(%+v): %v", d, err)
+			return false
+		}
+		return decoded == d
+	}
+	withTTL, err := DistributeCacheTokensWithTTL(1000, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []CacheTokenDistribution{
+		{},
+		DistributeCacheTokens(1000),
+		withTTL,
+		{InputTokens: math.MaxInt64, CacheCreationInputTokens: math.MinInt64, CacheReadInputTokens: -1},
+	} {
+		if !roundTrip(d) {
+			t.Fatalf("gob round trip changed %+v", d)
+		}
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheTokenDistribution_GobCompatibility(t *testing.T) {
+	// v1 is the shape before the TTL tier split; v3 adds a field a newer process may send.
+	type v1 struct {
+		InputTokens              int64
+		CacheCreationInputTokens int64
+		CacheReadInputTokens     int64
+	}
+	type v3 struct {
+		InputTokens              int64
+		CacheCreationInputTokens int64
+		CacheReadInputTokens     int64
+		CacheCreation            CacheCreation
+		ReasoningTokens          int64
+	}
+	transcode := func(in, out any) {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if err := gob.NewDecoder(&buf).Decode(out); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+	}
+
+	var fromOld CacheTokenDistribution
+	transcode(v1{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}, &fromOld)
+	if want := (CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}); fromOld != want {
+		t.Fatalf("decoded older sender = %+v, want %+v", fromOld, want)
+	}
+
+	var fromNew CacheTokenDistribution
+	tiers := CacheCreation{Ephemeral5mInputTokens: 30, Ephemeral1hInputTokens: 41}
+	transcode(v3{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894, CacheCreation: tiers, ReasoningTokens: 7}, &fromNew)
+	if want := (CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894, CacheCreation: tiers}); fromNew != want {
+		t.Fatalf("decoded newer sender = %+v, want %+v", fromNew, want)
+	}
+}
+
+func TestDistributor_ZeroThreshold(t *testing.T) {
+	d := DefaultDistributor()
+	d.Threshold = 0
+	// With floor rounding at 1:2:25, input gets floor(total/28) and creation
+	// floor(2*total/28), so tiny totals go almost entirely to cache read.
+	for _, tc := range []struct {
+		total int64
+		want  CacheTokenDistribution
+	}{
+		{0, CacheTokenDistribution{}},
+		{1, CacheTokenDistribution{CacheReadInputTokens: 1}},
+		{13, CacheTokenDistribution{CacheReadInputTokens: 13}},
+		{14, CacheTokenDistribution{CacheCreationInputTokens: 1, CacheReadInputTokens: 13}},
+		{27, CacheTokenDistribution{CacheCreationInputTokens: 1, CacheReadInputTokens: 26}},
+		{28, CacheTokenDistribution{InputTokens: 1, CacheCreationInputTokens: 2, CacheReadInputTokens: 25}},
+	} {
+		if got := d.Distribute(tc.total); got != tc.want {
+			t.Errorf("Distribute(%d) with Threshold 0 = %+v, want %+v", tc.total, got, tc.want)
+		}
+	}
+
+	// A negative threshold is invalid rather than a stronger "always distribute": the
+	// distributor fails Validate and reports the total as input.
+	d.Threshold = -1
+	if err := d.Validate(); err == nil {
+		t.Fatal("Validate accepted a negative threshold")
+	}
+	if got := d.Distribute(27); got != (CacheTokenDistribution{InputTokens: 27}) {
+		t.Errorf("Distribute(27) with Threshold -1 = %+v, want all input", got)
+	}
+}
+
+func TestCacheTokenDistribution_EqualWithin(t *testing.T) {
+	golden := CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894}
+	if !golden.EqualWithin(golden, 0) {
+		t.Fatal("tolerance 0 rejected an identical distribution")
+	}
+	offByOne := CacheTokenDistribution{InputTokens: 36, CacheCreationInputTokens: 70, CacheReadInputTokens: 894}
+	if golden.EqualWithin(offByOne, 0) {
+		t.Fatal("tolerance 0 is not exact equality")
+	}
+	if !golden.EqualWithin(offByOne, 1) || !offByOne.EqualWithin(golden, 1) {
+		t.Fatal("±1 per field rejected at tolerance 1")
+	}
+	offByTwo := CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 896}
+	if golden.EqualWithin(offByTwo, 1) || !golden.EqualWithin(offByTwo, 2) {
+		t.Fatal("tolerance boundary is not inclusive")
+	}
+	tiered := golden
+	tiered.CacheCreation = CacheCreation{Ephemeral5mInputTokens: 71}
+	if golden.EqualWithin(tiered, 1) {
+		t.Fatal("TTL tiers are not compared")
+	}
+	if golden.EqualWithin(offByOne, -1) {
+		t.Fatal("negative tolerance is not exact equality")
+	}
+	extreme := CacheTokenDistribution{InputTokens: math.MaxInt64}
+	if extreme.EqualWithin(CacheTokenDistribution{InputTokens: math.MinInt64}, math.MaxInt64) {
+		t.Fatal("difference overflowed")
+	}
+}
+
+func TestDeltaAccumulator_MatchesOneShotDistribution(t *testing.T) {
+	var acc DeltaAccumulator
+	deltas := []struct{ input, output int64 }{{12_000, 40}, {3_500, 260}, {1_234, 700}}
+	var input, output int64
+	for i, delta := range deltas {
+		acc.Observe(delta.input, delta.output)
+		input += delta.input
+		output += delta.output
+		gotDist, gotOutput := acc.Current()
+		if want := DistributeCacheTokens(input); gotDist != want || gotOutput != output {
+			t.Fatalf("after delta %d: Current() = %+v, %d; want %+v, %d", i, gotDist, gotOutput, want, output)
+		}
+	}
+	// Distributing each delta on its own would round three times and drift; the
+	// accumulated total rounds once.
+	gotDist, _ := acc.Current()
+	if want := DistributeCacheTokens(16_734); gotDist != want || gotDist.TotalInputTokens() != 16_734 {
+		t.Fatalf("final distribution = %+v, want %+v", gotDist, want)
+	}
+
+	custom, err := NewDistributor(WithRatio(1, 1, 8), WithThreshold(0))
+	if err != nil {
+		t.Fatalf("NewDistributor: %v", err)
+	}
+	configured := NewDeltaAccumulator(custom)
+	configured.Observe(600, 1)
+	configured.Observe(400, 2)
+	if gotDist, gotOutput := configured.Current(); gotDist != custom.Distribute(1000) || gotOutput != 3 {
+		t.Fatalf("custom distributor Current() = %+v, %d", gotDist, gotOutput)
+	}
+}
+
+func TestParseRatio(t *testing.T) {
+	ratio, err := ParseRatio("1:2:25")
+	if err != nil {
+		t.Fatalf("ParseRatio(1:2:25) error = %v", err)
+	}
+	if ratio != defaultRatio {
+		t.Fatalf("ParseRatio(1:2:25) = %+v, want %+v", ratio, defaultRatio)
+	}
+	d, err := NewDistributor(WithRatio(ratio.Input, ratio.Creation, ratio.Read))
+	if err != nil || d.Distribute(1000) != DistributeCacheTokens(1000) {
+		t.Fatalf("distributor from parsed ratio = %+v, %v", d, err)
+	}
+
+	for input, field := range map[string]string{
+		"0:0:0": "input-part",
+		"1:2":   "ratio",
+		"a:b:c": "ratio",
+	} {
+		_, err := ParseRatio(input)
+		var errDist *DistributionError
+		if !errors.As(err, &errDist) || errDist.Field != field {
+			t.Errorf("ParseRatio(%q) error = %v, want %s *DistributionError", input, err, field)
+		}
+	}
+}
@@ -44,7 +44,7 @@ func (p *LoggerPlugin) HandleUsage(ctx context.Context, record coreusage.Record)
 	if !statisticsEnabled.Load() {
 		return
 	}
-	if p == nil || p.stats == nil {
+	if p == nil || p.stats == nil || record.Hedged {
 		return
 	}
 	p.stats.Record(ctx, record)
@@ -103,6 +103,14 @@ type TokenStats struct {
 	ReasoningTokens int64 `json:"reasoning_tokens"`
 	CachedTokens    int64 `json:"cached_tokens"`
 	TotalTokens     int64 `json:"total_tokens"`
+	// CacheCreationTokens counts tokens written to the upstream's prompt cache.
+	CacheCreationTokens int64 `json:"cache_creation_tokens,omitempty"`
+	// CacheSimulated marks cache counts synthesized by the proxy rather than
+	// reported by the upstream.
+	CacheSimulated bool `json:"cache_simulated,omitempty"`
+	// Estimated marks token counts estimated by the proxy because the upstream did
+	// not report them.
+	Estimated bool `json:"estimated,omitempty"`
 }
 
 // StatisticsSnapshot represents an immutable view of the aggregated metrics.
@@ -443,6 +451,10 @@ func normaliseDetail(detail coreusage.Detail) TokenStats {
 		ReasoningTokens: detail.ReasoningTokens,
 		CachedTokens:    detail.CachedTokens,
 		TotalTokens:     detail.TotalTokens,
+
+		CacheCreationTokens: detail.CacheCreationTokens,
+		CacheSimulated:      detail.CacheSimulated,
+		Estimated:           detail.Estimated,
 	}
 	if tokens.TotalTokens == 0 {
 		tokens.TotalTokens = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
@@ -0,0 +1,29 @@
+package usage
+
+import "sync/atomic"
+
+// tokenRecorder receives the final token counts reported to clients. It stays nil
+// unless metrics are registered, which requires building with the prometheus tag.
+type tokenRecorder struct {
+	distribution func(model string, d CacheTokenDistribution)
+	output       func(model string, tokens int64)
+}
+
+var recorder atomic.Pointer[tokenRecorder]
+
+// RecordDistribution reports the final cache distribution of a response for model to
+// the registered metrics. It is a no-op when no metrics are registered.
+func RecordDistribution(model string, d CacheTokenDistribution) {
+	if r := recorder.Load(); r != nil {
+		r.distribution(model, d)
+	}
+}
+
+// RecordUsage reports a final usage block for model: its distribution through
+// RecordDistribution plus its output tokens.
+func RecordUsage(model string, u UsageBlock) {
+	if r := recorder.Load(); r != nil {
+		r.distribution(model, u.CacheTokenDistribution)
+		r.output(model, u.OutputTokens)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build prometheus
+
+package usage
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+)
+
+// RegisterMetrics registers token counters labeled by model with reg and routes
+// RecordDistribution and RecordUsage to them:
+//
+//	proxy_input_tokens_total
+//	proxy_output_tokens_total
+//	proxy_cache_creation_tokens_total
+//	proxy_cache_read_tokens_total
+//
+// Models are labeled through metrics.ModelLabel to keep the label set bounded.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	newCounter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{"model"})
+	}
+	input := newCounter("proxy_input_tokens_total", "Uncached input tokens reported to clients.")
+	output := newCounter("proxy_output_tokens_total", "Output tokens reported to clients.")
+	creation := newCounter("proxy_cache_creation_tokens_total", "Cache creation input tokens reported to clients.")
+	read := newCounter("proxy_cache_read_tokens_total", "Cache read input tokens reported to clients.")
+	for _, collector := range []prometheus.Collector{input, output, creation, read} {
+		if err := reg.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	recorder.Store(&tokenRecorder{
+		distribution: func(model string, d CacheTokenDistribution) {
+			label := metrics.ModelLabel(model)
+			input.WithLabelValues(label).Add(float64(max(d.InputTokens, 0)))
+			creation.WithLabelValues(label).Add(float64(max(d.CacheCreationInputTokens, 0)))
+			read.WithLabelValues(label).Add(float64(max(d.CacheReadInputTokens, 0)))
+		},
+		output: func(model string, tokens int64) {
+			output.WithLabelValues(metrics.ModelLabel(model)).Add(float64(max(tokens, 0)))
+		},
+	})
+	return nil
+}
@@ -0,0 +1,53 @@
+//go:build prometheus
+
+package usage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func TestRegisterMetricsCountsUsage(t *testing.T) {
+	clientID := "metrics-test-client"
+	registry.GetGlobalRegistry().RegisterClient(clientID, "claude", []*registry.ModelInfo{{ID: "claude-sonnet"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(clientID) })
+
+	reg := prometheus.NewRegistry()
+	if err := RegisterMetrics(reg); err != nil {
+		t.Fatalf("RegisterMetrics() error = %v", err)
+	}
+	t.Cleanup(func() { recorder.Store(nil) })
+
+	RecordUsage("claude-sonnet", UsageBlock{CacheTokenDistribution: DistributeCacheTokens(1000), OutputTokens: 12})
+	RecordDistribution("claude-sonnet(high)", CacheTokenDistribution{InputTokens: 42})
+	RecordUsage("client-typed-name", UsageBlock{CacheTokenDistribution: CacheTokenDistribution{InputTokens: 5}, OutputTokens: 1})
+
+	want := `
+# HELP proxy_cache_creation_tokens_total Cache creation input tokens reported to clients.
+# TYPE proxy_cache_creation_tokens_total counter
+proxy_cache_creation_tokens_total{model="claude-sonnet"} 71
+proxy_cache_creation_tokens_total{model="other"} 0
+# HELP proxy_cache_read_tokens_total Cache read input tokens reported to clients.
+# TYPE proxy_cache_read_tokens_total counter
+proxy_cache_read_tokens_total{model="claude-sonnet"} 894
+proxy_cache_read_tokens_total{model="other"} 0
+# HELP proxy_input_tokens_total Uncached input tokens reported to clients.
+# TYPE proxy_input_tokens_total counter
+proxy_input_tokens_total{model="claude-sonnet"} 77
+proxy_input_tokens_total{model="other"} 5
+# HELP proxy_output_tokens_total Output tokens reported to clients.
+# TYPE proxy_output_tokens_total counter
+proxy_output_tokens_total{model="claude-sonnet"} 12
+proxy_output_tokens_total{model="other"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+	if err := RegisterMetrics(reg); err == nil {
+		t.Fatal("RegisterMetrics() twice on one registry error = nil, want duplicate error")
+	}
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS usage_records (
+    id                          INTEGER PRIMARY KEY AUTOINCREMENT,
+    requested_at                INTEGER NOT NULL,
+    day                         TEXT    NOT NULL,
+    provider                    TEXT    NOT NULL DEFAULT '',
+    model                       TEXT    NOT NULL DEFAULT '',
+    account                     TEXT    NOT NULL DEFAULT '',
+    auth_index                  TEXT    NOT NULL DEFAULT '',
+    source                      TEXT    NOT NULL DEFAULT '',
+    input_tokens                INTEGER NOT NULL DEFAULT 0,
+    cache_creation_input_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_input_tokens     INTEGER NOT NULL DEFAULT 0,
+    output_tokens               INTEGER NOT NULL DEFAULT 0,
+    latency_ms                  INTEGER NOT NULL DEFAULT 0,
+    streamed                    INTEGER NOT NULL DEFAULT 0,
+    failed                      INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_records_requested_at ON usage_records (requested_at);
+CREATE INDEX IF NOT EXISTS idx_usage_records_day ON usage_records (day);
@@ -0,0 +1 @@
+ALTER TABLE usage_records ADD COLUMN cached INTEGER NOT NULL DEFAULT 0;
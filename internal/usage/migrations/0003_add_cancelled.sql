@@ -0,0 +1 @@
+ALTER TABLE usage_records ADD COLUMN cancelled INTEGER NOT NULL DEFAULT 0;
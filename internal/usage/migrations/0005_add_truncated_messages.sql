@@ -0,0 +1 @@
+ALTER TABLE usage_records ADD COLUMN truncated_messages INTEGER NOT NULL DEFAULT 0;
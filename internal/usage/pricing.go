@@ -0,0 +1,216 @@
+package usage
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/shopspring/decimal"
+)
+
+// tokensPerPriceUnit is the token count that configured prices are quoted for.
+var tokensPerPriceUnit = decimal.NewFromInt(1_000_000)
+
+// ModelPrice holds USD prices per million tokens for each token bucket.
+type ModelPrice struct {
+	Input      decimal.Decimal
+	Output     decimal.Decimal
+	CacheRead  decimal.Decimal
+	CacheWrite decimal.Decimal
+}
+
+type pricingEntry struct {
+	pattern string
+	price   ModelPrice
+}
+
+type pricingTable struct {
+	models      []pricingEntry
+	defaultRate *ModelPrice
+}
+
+var (
+	pricingMu sync.RWMutex
+	pricing   pricingTable
+)
+
+func modelPriceFromConfig(p config.ModelPrice) ModelPrice {
+	return ModelPrice{
+		Input:      decimal.NewFromFloat(p.Input),
+		Output:     decimal.NewFromFloat(p.Output),
+		CacheRead:  decimal.NewFromFloat(p.CacheRead),
+		CacheWrite: decimal.NewFromFloat(p.CacheWrite),
+	}
+}
+
+// ApplyPricingConfig installs the model price table from the application config.
+// A nil config clears all prices.
+func ApplyPricingConfig(cfg *config.Config) {
+	var table pricingTable
+	if cfg != nil {
+		for _, p := range cfg.Pricing.Models {
+			pattern := strings.ToLower(strings.TrimSpace(p.Model))
+			if pattern == "" {
+				continue
+			}
+			table.models = append(table.models, pricingEntry{pattern: pattern, price: modelPriceFromConfig(p)})
+		}
+		if cfg.Pricing.Default != nil {
+			def := modelPriceFromConfig(*cfg.Pricing.Default)
+			table.defaultRate = &def
+		}
+	}
+	pricingMu.Lock()
+	pricing = table
+	pricingMu.Unlock()
+}
+
+// PriceForModel returns the price of the first configured pattern matching model,
+// falling back to the default price. It reports false when model is unpriced.
+func PriceForModel(model string) (ModelPrice, bool) {
+	key := strings.ToLower(strings.TrimSpace(model))
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	for _, entry := range pricing.models {
+		if util.MatchWildcard(entry.pattern, key) {
+			return entry.price, true
+		}
+	}
+	if pricing.defaultRate != nil {
+		return *pricing.defaultRate, true
+	}
+	return ModelPrice{}, false
+}
+
+// Cost returns the estimated USD list price of rec, or zero when its model is unpriced.
+func Cost(rec RequestUsage) decimal.Decimal {
+	cost, _ := RequestCost(rec)
+	return cost
+}
+
+// RequestCost prices rec with the rate for its model. Cache read and cache creation
+// tokens are billed at the cache-read and cache-write rates rather than the input rate.
+// Records without a distribution bill InputTokens as uncached input.
+// It reports false when no price applies to the model.
+func RequestCost(rec RequestUsage) (decimal.Decimal, bool) {
+	price, ok := PriceForModel(rec.Model)
+	if !ok {
+		return decimal.Zero, false
+	}
+	dist := rec.Distribution
+	if dist.TotalInputTokens() == 0 {
+		dist = CacheTokenDistribution{InputTokens: rec.InputTokens}
+	}
+	return price.cost(dist, rec.OutputTokens), true
+}
+
+func (p ModelPrice) cost(dist CacheTokenDistribution, outputTokens int64) decimal.Decimal {
+	total := p.Input.Mul(decimal.NewFromInt(dist.InputTokens)).
+		Add(p.CacheWrite.Mul(decimal.NewFromInt(dist.CacheCreationInputTokens))).
+		Add(p.CacheRead.Mul(decimal.NewFromInt(dist.CacheReadInputTokens))).
+		Add(p.Output.Mul(decimal.NewFromInt(outputTokens)))
+	return total.Div(tokensPerPriceUnit)
+}
+
+// Anthropic bills prompt cache writes and reads as multiples of the base input rate.
+const (
+	CacheCreationMultiplier = 1.25
+	CacheReadMultiplier     = 0.1
+)
+
+// Pricing holds USD rates per million tokens for a plain float64 cost estimate.
+type Pricing struct {
+	Input         float64
+	CacheCreation float64
+	CacheRead     float64
+	Output        float64
+}
+
+// AnthropicPricing derives the cache rates from the input rate using Anthropic's
+// published multipliers: cache creation at 1.25x and cache read at 0.1x.
+func AnthropicPricing(input, output float64) Pricing {
+	return Pricing{
+		Input:         input,
+		CacheCreation: input * CacheCreationMultiplier,
+		CacheRead:     input * CacheReadMultiplier,
+		Output:        output,
+	}
+}
+
+// EstimateCost returns the USD cost of d plus output tokens at the rates in p.
+// Each cache bucket is billed at its own rate rather than the input rate.
+func EstimateCost(d CacheTokenDistribution, output int64, p Pricing) float64 {
+	perMillion := float64(d.InputTokens)*p.Input +
+		float64(d.CacheCreationInputTokens)*p.CacheCreation +
+		float64(d.CacheReadInputTokens)*p.CacheRead +
+		float64(output)*p.Output
+	return perMillion / 1_000_000
+}
+
+// CacheSavings returns how many USD caching saved on d: the cost of billing every
+// input token at the full input rate minus the cost at the cache rates in p. Output
+// tokens cost the same either way and are left out. The result is clamped to zero
+// when the cache rates make caching the more expensive option.
+func CacheSavings(d CacheTokenDistribution, p Pricing) float64 {
+	uncached := float64(d.TotalInputTokens()) * p.Input / 1_000_000
+	return max(uncached-EstimateCost(d, 0, p), 0)
+}
+
+// PricingRegistry maps model IDs to Pricing, with an optional default for models
+// without an entry. It is safe for concurrent use; the intended pattern is to register
+// every rate at startup and only look them up afterwards.
+type PricingRegistry struct {
+	mu       sync.RWMutex
+	models   map[string]Pricing
+	fallback *Pricing
+}
+
+// NewPricingRegistry returns an empty registry with no default pricing.
+func NewPricingRegistry() *PricingRegistry {
+	return &PricingRegistry{models: make(map[string]Pricing)}
+}
+
+// Register sets the pricing of model, replacing any earlier entry. Model IDs are
+// matched case-insensitively and without a trailing release date, so registering
+// "claude-3-5-sonnet" also prices "claude-3-5-sonnet-20241022". Model families as
+// returned by ModelFamily, such as "claude-sonnet", are valid IDs too.
+func (r *PricingRegistry) Register(model string, p Pricing) {
+	key := pricingModelKey(model)
+	if key == "" {
+		return
+	}
+	r.mu.Lock()
+	r.models[key] = p
+	r.mu.Unlock()
+}
+
+// SetDefault sets the pricing Lookup returns for models without an entry.
+func (r *PricingRegistry) SetDefault(p Pricing) {
+	r.mu.Lock()
+	r.fallback = &p
+	r.mu.Unlock()
+}
+
+// Lookup returns the pricing registered for model. Like RatioForModel, an entry for
+// the model ID wins over one for its family; models matching neither get the default.
+// It reports false when no entry matches and no default is set.
+func (r *PricingRegistry) Lookup(model string) (Pricing, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.models[pricingModelKey(model)]; ok {
+		return p, true
+	}
+	if p, ok := r.models[ModelFamily(model)]; ok {
+		return p, true
+	}
+	if r.fallback != nil {
+		return *r.fallback, true
+	}
+	return Pricing{}, false
+}
+
+// pricingModelKey normalizes a model ID for the pricing registry.
+func pricingModelKey(model string) string {
+	return trimModelDate(normalizeRatioModelKey(model))
+}
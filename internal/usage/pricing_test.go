@@ -0,0 +1,207 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/shopspring/decimal"
+)
+
+func applyTestPricing(t *testing.T) {
+	t.Helper()
+	ApplyPricingConfig(&config.Config{Pricing: config.PricingConfig{
+		Default: &config.ModelPrice{Input: 1, Output: 2},
+		Models: []config.ModelPrice{
+			{Model: "claude-sonnet-4*", Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+			{Model: "claude-*-4.5", Input: 100, Output: 100},
+		},
+	}})
+	t.Cleanup(func() { ApplyPricingConfig(nil) })
+}
+
+func TestPriceForModel_GlobAndDefault(t *testing.T) {
+	applyTestPricing(t)
+
+	cases := []struct {
+		model string
+		input string
+	}{
+		{"claude-sonnet-4.5", "3"}, // first match wins over the later pattern
+		{"Claude-Sonnet-4-20250514", "3"},
+		{"claude-haiku-4.5", "100"},
+		{"gpt-5", "1"},
+	}
+	for _, tc := range cases {
+		price, ok := PriceForModel(tc.model)
+		if !ok {
+			t.Fatalf("%s: expected a price", tc.model)
+		}
+		if !price.Input.Equal(decimal.RequireFromString(tc.input)) {
+			t.Fatalf("%s: input price = %s, want %s", tc.model, price.Input, tc.input)
+		}
+	}
+
+	ApplyPricingConfig(&config.Config{})
+	if _, ok := PriceForModel("gpt-5"); ok {
+		t.Fatalf("expected no price without a default rate")
+	}
+	if got := Cost(RequestUsage{Model: "gpt-5", InputTokens: 1000}); !got.IsZero() {
+		t.Fatalf("unpriced cost = %s, want 0", got)
+	}
+}
+
+func TestCost_PricesCacheBucketsAtCacheRates(t *testing.T) {
+	applyTestPricing(t)
+
+	rec := RequestUsage{
+		Model:        "claude-sonnet-4.5",
+		InputTokens:  1_000_000,
+		OutputTokens: 100_000,
+		Distribution: CacheTokenDistribution{
+			InputTokens:              100_000,
+			CacheCreationInputTokens: 200_000,
+			CacheReadInputTokens:     700_000,
+		},
+	}
+	// 0.1M*3 + 0.2M*3.75 + 0.7M*0.3 + 0.1M*15 = 0.3 + 0.75 + 0.21 + 1.5
+	if got, want := Cost(rec), decimal.RequireFromString("2.76"); !got.Equal(want) {
+		t.Fatalf("Cost = %s, want %s", got, want)
+	}
+
+	// Without a distribution the whole input is billed at the input rate.
+	rec.Distribution = CacheTokenDistribution{}
+	if got, want := Cost(rec), decimal.RequireFromString("4.5"); !got.Equal(want) {
+		t.Fatalf("Cost without distribution = %s, want %s", got, want)
+	}
+}
+func TestQueryTotals_IncludeCost(t *testing.T) {
+	applyTestPricing(t)
+
+	ts := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	sonnet := RequestUsage{Timestamp: ts, Model: "claude-sonnet-4.5", AuthID: "a", OutputTokens: 1_000_000}
+	other := RequestUsage{Timestamp: ts, Model: "gpt-5", AuthID: "a", OutputTokens: 1_000_000}
+	want := decimal.RequireFromString("17") // 15 + 2
+
+	mem := NewMemoryRecorder(10)
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "usage.db"), 0)
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+	for _, r := range []Recorder{mem, store} {
+		r.Record(ctx, sonnet)
+		r.Record(ctx, other)
+	}
+
+	for name, q := range map[string]UsageQuerier{"memory": mem, "sqlite": store} {
+		groups, err := q.QueryTotals(ctx, UsageQuery{GroupBy: []string{GroupByAccount}})
+		if err != nil {
+			t.Fatalf("%s: QueryTotals: %v", name, err)
+		}
+		if len(groups) != 1 || groups[0].Requests != 2 {
+			t.Fatalf("%s: unexpected groups: %+v", name, groups)
+		}
+		if !groups[0].Cost.Equal(want) {
+			t.Fatalf("%s: Cost = %s, want %s", name, groups[0].Cost, want)
+		}
+	}
+}
+
+func TestEstimateCost_SonnetRates(t *testing.T) {
+	// Claude Sonnet 4.5: $3 input, $3.75 cache write, $0.30 cache read, $15 output per MTok.
+	sonnet := AnthropicPricing(3, 15)
+	if sonnet.CacheCreation != 3.75 || math.Abs(sonnet.CacheRead-0.3) > 1e-12 {
+		t.Fatalf("unexpected derived Sonnet rates: %+v", sonnet)
+	}
+
+	d := CacheTokenDistribution{InputTokens: 100_000, CacheCreationInputTokens: 200_000, CacheReadInputTokens: 700_000}
+	got := EstimateCost(d, 100_000, sonnet)
+	// 0.1M*3 + 0.2M*3.75 + 0.7M*0.3 + 0.1M*15 = 0.3 + 0.75 + 0.21 + 1.5
+	if math.Abs(got-2.76) > 1e-9 {
+		t.Fatalf("EstimateCost = %v, want 2.76", got)
+	}
+
+	// The default split of 1000 tokens bills most of the prompt at the cache read rate.
+	got = EstimateCost(DistributeCacheTokens(1000), 0, sonnet)
+	want := (35*3 + 71*3.75 + 894*0.3) / 1_000_000
+	if math.Abs(got-want) > 1e-12 {
+		t.Fatalf("EstimateCost(default split) = %v, want %v", got, want)
+	}
+	if EstimateCost(CacheTokenDistribution{}, 0, sonnet) != 0 {
+		t.Fatalf("empty usage should cost nothing")
+	}
+}
+
+func TestCacheSavings(t *testing.T) {
+	sonnet := AnthropicPricing(3, 15)
+	d := CacheTokenDistribution{InputTokens: 100_000, CacheCreationInputTokens: 200_000, CacheReadInputTokens: 700_000}
+	// Full rate: 1M*3 = 3.00. Actual: 0.3 + 0.75 + 0.21 = 1.26.
+	if got := CacheSavings(d, sonnet); math.Abs(got-1.74) > 1e-9 {
+		t.Fatalf("CacheSavings = %v, want 1.74", got)
+	}
+
+	// Writes without reads cost more than uncached input; savings never go negative.
+	writesOnly := CacheTokenDistribution{CacheCreationInputTokens: 1_000_000}
+	if got := CacheSavings(writesOnly, sonnet); got != 0 {
+		t.Fatalf("CacheSavings(writes only) = %v, want 0", got)
+	}
+	if got := CacheSavings(CacheTokenDistribution{InputTokens: 500}, sonnet); got != 0 {
+		t.Fatalf("CacheSavings(uncached) = %v, want 0", got)
+	}
+}
+
+func TestPricingRegistry_Lookup(t *testing.T) {
+	r := NewPricingRegistry()
+	if _, ok := r.Lookup("claude-3-5-sonnet"); ok {
+		t.Fatal("empty registry priced a model")
+	}
+
+	sonnet35 := AnthropicPricing(3, 15)
+	opus := AnthropicPricing(15, 75)
+	r.Register("Claude-3-5-Sonnet", sonnet35)
+	r.Register("claude-opus", opus)
+
+	for _, model := range []string{"claude-3-5-sonnet", "claude-3-5-sonnet-20241022", " CLAUDE-3-5-SONNET-2024-10-22 "} {
+		if p, ok := r.Lookup(model); !ok || p != sonnet35 {
+			t.Errorf("Lookup(%q) = %+v, %v; want Sonnet 3.5 rates", model, p, ok)
+		}
+	}
+	if p, ok := r.Lookup("anthropic/claude-opus-4-20250514"); !ok || p != opus {
+		t.Errorf("family fallback = %+v, %v; want Opus rates", p, ok)
+	}
+	if _, ok := r.Lookup("claude-sonnet-4"); ok {
+		t.Error("unregistered family priced without a default")
+	}
+
+	fallback := Pricing{Input: 1, Output: 2}
+	r.SetDefault(fallback)
+	if p, ok := r.Lookup("gpt-4o"); !ok || p != fallback {
+		t.Errorf("default = %+v, %v; want %+v", p, ok, fallback)
+	}
+	if p, _ := r.Lookup("claude-3-5-sonnet-20241022"); p != sonnet35 {
+		t.Errorf("default overrode a registered model: %+v", p)
+	}
+}
+
+func TestPricingRegistry_ConcurrentAccess(t *testing.T) {
+	r := NewPricingRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			r.Register("claude-sonnet", AnthropicPricing(float64(i), 15))
+		}(i)
+		go func() {
+			defer wg.Done()
+			r.Lookup("claude-sonnet-4-5")
+		}()
+	}
+	wg.Wait()
+	if _, ok := r.Lookup("claude-sonnet-4-5"); !ok {
+		t.Fatal("registered family not found")
+	}
+}
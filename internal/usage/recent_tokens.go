@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"sync"
+	"time"
+)
+
+// recentTokensWindow is how far back RecentAuthTokens looks.
+const recentTokensWindow = 5 * time.Minute
+
+// recentTokensBuckets is the number of one-minute buckets kept per auth.
+const recentTokensBuckets = int(recentTokensWindow / time.Minute)
+
+type tokenBuckets struct {
+	minutes [recentTokensBuckets]int64
+	tokens  [recentTokensBuckets]int64
+}
+
+// recentAuthTokens tracks input and output tokens per auth ID in one-minute buckets
+// so credential selectors can compare recent load without querying the recorder.
+type recentAuthTokens struct {
+	mu    sync.Mutex
+	auths map[string]*tokenBuckets
+}
+
+var recentTokens = &recentAuthTokens{auths: make(map[string]*tokenBuckets)}
+
+func (r *recentAuthTokens) add(authID string, at time.Time, tokens int64) {
+	if authID == "" || tokens <= 0 {
+		return
+	}
+	minute := at.Unix() / 60
+	slot := int(minute % int64(recentTokensBuckets))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.auths[authID]
+	if !ok {
+		b = &tokenBuckets{}
+		r.auths[authID] = b
+	}
+	if b.minutes[slot] != minute {
+		b.minutes[slot] = minute
+		b.tokens[slot] = 0
+	}
+	b.tokens[slot] += tokens
+}
+
+func (r *recentAuthTokens) total(authID string, now time.Time) int64 {
+	oldest := now.Unix()/60 - int64(recentTokensBuckets) + 1
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.auths[authID]
+	if !ok {
+		return 0
+	}
+	var total int64
+	for i, minute := range b.minutes {
+		if minute >= oldest {
+			total += b.tokens[i]
+		}
+	}
+	return total
+}
+
+// RecentAuthTokens returns the input and output tokens recorded for authID during
+// the last five minutes.
+func RecentAuthTokens(authID string) int64 {
+	return recentTokens.total(authID, time.Now())
+}
@@ -0,0 +1,29 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecentAuthTokens_ExpiresOldBuckets(t *testing.T) {
+	r := &recentAuthTokens{auths: make(map[string]*tokenBuckets)}
+	start := time.Unix(1_800_000_000, 0)
+	r.add("a", start, 100)
+	r.add("a", start.Add(2*time.Minute), 50)
+	r.add("b", start, 7)
+
+	if got := r.total("a", start.Add(2*time.Minute)); got != 150 {
+		t.Fatalf("total(a) = %d, want 150", got)
+	}
+	if got := r.total("a", start.Add(recentTokensWindow)); got != 50 {
+		t.Fatalf("total(a) after window = %d, want 50", got)
+	}
+	// A later minute reusing the slot must not inherit the expired count.
+	r.add("a", start.Add(recentTokensWindow), 1)
+	if got := r.total("a", start.Add(recentTokensWindow)); got != 51 {
+		t.Fatalf("total(a) after slot reuse = %d, want 51", got)
+	}
+	if got := r.total("missing", start); got != 0 {
+		t.Fatalf("total(missing) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,446 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/shopspring/decimal"
+)
+
+// defaultMemoryRecorderCapacity bounds the number of records kept by the default recorder.
+const defaultMemoryRecorderCapacity = 10000
+
+// simulatedCacheProviders lists providers whose cache buckets are synthesized by the proxy.
+var simulatedCacheProviders = map[string]struct{}{
+	"kiro": {},
+}
+
+// cacheExclusiveInputProviders lists providers that report cache reads and writes
+// apart from their input tokens, as Anthropic does. Other providers count cached
+// tokens as part of the input.
+var cacheExclusiveInputProviders = map[string]struct{}{
+	"claude": {},
+}
+
+// SimulatesCacheUsage reports whether the cache token counts for provider are
+// synthesized by the proxy rather than reported by the upstream. A simulating provider
+// configured as pass-through reports no cache counts at all.
+func SimulatesCacheUsage(provider string) bool {
+	_, ok := simulatedCacheProviders[strings.ToLower(strings.TrimSpace(provider))]
+	return ok && !ProviderDistributor(provider).PassThrough
+}
+
+// RequestUsage describes the token consumption of a single completed provider request.
+type RequestUsage struct {
+	Timestamp    time.Time
+	Provider     string
+	Model        string
+	AuthID       string
+	AuthIndex    string
+	APIKey       string
+	Source       string
+	InputTokens  int64
+	OutputTokens int64
+	Distribution CacheTokenDistribution
+	Latency      time.Duration
+	Streamed     bool
+	Failed       bool
+	// Cached reports that the response came from the response cache, at no cost.
+	Cached bool
+	// Cancelled reports that the client went away before the response completed; the
+	// tokens are those the upstream had reported by then.
+	Cancelled bool
+	// TruncatedMessages counts the conversation messages dropped to fit the context window.
+	TruncatedMessages int
+	// CacheSimulated reports that Distribution was synthesized with the configured
+	// cache ratio instead of taken from the upstream's cache counts.
+	CacheSimulated bool
+	// Estimated reports that the token counts were estimated locally because the
+	// upstream did not report them.
+	Estimated bool
+}
+
+// Recorder receives a RequestUsage for every completed request, including failed ones.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	Record(ctx context.Context, rec RequestUsage)
+}
+
+// UsageFilter narrows the records aggregated by MemoryRecorder.Totals.
+// Empty string fields and zero times match everything.
+type UsageFilter struct {
+	Provider string
+	Model    string
+	AuthID   string
+	Since    time.Time
+	Until    time.Time
+}
+
+// UsageTotals is an aggregate over a set of RequestUsage records.
+type UsageTotals struct {
+	Requests                 int64
+	Failures                 int64
+	StreamedRequests         int64
+	CachedRequests           int64
+	CancelledRequests        int64
+	EstimatedRequests        int64
+	TruncatedMessages        int64
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+	Latency                  time.Duration
+	// Cost is the estimated list price of the aggregated records; see Cost.
+	Cost decimal.Decimal
+}
+
+// MemoryRecorder keeps the most recent request usage records in memory.
+type MemoryRecorder struct {
+	mu       sync.RWMutex
+	records  []RequestUsage
+	next     int
+	full     bool
+	capacity int
+}
+
+// NewMemoryRecorder constructs a recorder retaining at most capacity records.
+// A non-positive capacity uses the default of 10000 records.
+func NewMemoryRecorder(capacity int) *MemoryRecorder {
+	if capacity <= 0 {
+		capacity = defaultMemoryRecorderCapacity
+	}
+	return &MemoryRecorder{records: make([]RequestUsage, capacity), capacity: capacity}
+}
+
+// Record implements Recorder. The oldest record is overwritten once capacity is reached.
+func (r *MemoryRecorder) Record(_ context.Context, rec RequestUsage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.records[r.next] = rec
+	r.next++
+	if r.next == r.capacity {
+		r.next = 0
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// Records returns a copy of the retained records ordered from oldest to newest.
+func (r *MemoryRecorder) Records() []RequestUsage {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.full {
+		return append([]RequestUsage(nil), r.records[:r.next]...)
+	}
+	out := make([]RequestUsage, 0, r.capacity)
+	out = append(out, r.records[r.next:]...)
+	return append(out, r.records[:r.next]...)
+}
+
+// Totals aggregates the retained records matching filter.
+func (r *MemoryRecorder) Totals(filter UsageFilter) UsageTotals {
+	var totals UsageTotals
+	for _, rec := range r.Records() {
+		if !filter.matches(rec) {
+			continue
+		}
+		totals.add(rec)
+	}
+	return totals
+}
+
+// Usage query grouping dimensions accepted by UsageQuery.GroupBy.
+const (
+	GroupByDay      = "day"
+	GroupByModel    = "model"
+	GroupByAccount  = "account"
+	GroupByProvider = "provider"
+)
+
+// UsageQuery selects records in [From, To) and the dimensions to group by.
+// Zero times leave that side of the range open.
+type UsageQuery struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+}
+
+// UsageGroup is one aggregated row; only the fields named in UsageQuery.GroupBy are set.
+type UsageGroup struct {
+	Day      string
+	Model    string
+	Account  string
+	Provider string
+	UsageTotals
+}
+
+func (g UsageGroup) sameKey(other UsageGroup) bool {
+	return g.Day == other.Day && g.Model == other.Model && g.Account == other.Account && g.Provider == other.Provider
+}
+
+// ErrUnsupportedGroupBy is returned when a UsageQuery names an unknown grouping dimension.
+var ErrUnsupportedGroupBy = errors.New("usage: unsupported group dimension")
+
+// UsageQuerier is implemented by recorders that can answer grouped usage queries.
+// Groups are ordered by the selected dimensions.
+type UsageQuerier interface {
+	QueryTotals(ctx context.Context, q UsageQuery) ([]UsageGroup, error)
+}
+
+// QueryActiveUsage runs q against the active recorder.
+func QueryActiveUsage(ctx context.Context, q UsageQuery) ([]UsageGroup, error) {
+	querier, ok := ActiveRecorder().(UsageQuerier)
+	if !ok {
+		return nil, errors.New("usage: active recorder does not support queries")
+	}
+	return querier.QueryTotals(ctx, q)
+}
+
+// normalizeGroupBy lowercases and de-duplicates dims, rejecting unknown dimensions.
+func normalizeGroupBy(dims []string) ([]string, error) {
+	out := make([]string, 0, len(dims))
+	for _, dim := range dims {
+		key := strings.ToLower(strings.TrimSpace(dim))
+		switch key {
+		case GroupByDay, GroupByModel, GroupByAccount, GroupByProvider:
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnsupportedGroupBy, dim)
+		}
+		if slices.Contains(out, key) {
+			continue
+		}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+// QueryTotals implements UsageQuerier over the retained records.
+func (r *MemoryRecorder) QueryTotals(_ context.Context, q UsageQuery) ([]UsageGroup, error) {
+	dims, err := normalizeGroupBy(q.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	filter := UsageFilter{Since: q.From, Until: q.To}
+	groups := make(map[UsageGroup]*UsageTotals)
+	for _, rec := range r.Records() {
+		if !filter.matches(rec) {
+			continue
+		}
+		var key UsageGroup
+		for _, dim := range dims {
+			switch dim {
+			case GroupByDay:
+				key.Day = rec.Timestamp.UTC().Format(usageDayLayout)
+			case GroupByModel:
+				key.Model = rec.Model
+			case GroupByAccount:
+				key.Account = rec.AuthID
+			case GroupByProvider:
+				key.Provider = rec.Provider
+			}
+		}
+		totals, ok := groups[key]
+		if !ok {
+			totals = &UsageTotals{}
+			groups[key] = totals
+		}
+		totals.add(rec)
+	}
+	out := make([]UsageGroup, 0, len(groups))
+	for key, totals := range groups {
+		key.UsageTotals = *totals
+		out = append(out, key)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		for _, dim := range dims {
+			var x, y string
+			switch dim {
+			case GroupByDay:
+				x, y = a.Day, b.Day
+			case GroupByModel:
+				x, y = a.Model, b.Model
+			case GroupByAccount:
+				x, y = a.Account, b.Account
+			case GroupByProvider:
+				x, y = a.Provider, b.Provider
+			}
+			if x != y {
+				return x < y
+			}
+		}
+		return false
+	})
+	return out, nil
+}
+
+func (f UsageFilter) matches(rec RequestUsage) bool {
+	if f.Provider != "" && !strings.EqualFold(f.Provider, rec.Provider) {
+		return false
+	}
+	if f.Model != "" && f.Model != rec.Model {
+		return false
+	}
+	if f.AuthID != "" && f.AuthID != rec.AuthID {
+		return false
+	}
+	if !f.Since.IsZero() && rec.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !rec.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+func (t *UsageTotals) add(rec RequestUsage) {
+	t.Requests++
+	if rec.Failed {
+		t.Failures++
+	}
+	if rec.Streamed {
+		t.StreamedRequests++
+	}
+	if rec.Cached {
+		t.CachedRequests++
+	}
+	if rec.Cancelled {
+		t.CancelledRequests++
+	}
+	if rec.Estimated {
+		t.EstimatedRequests++
+	}
+	t.TruncatedMessages += int64(rec.TruncatedMessages)
+	t.InputTokens += rec.Distribution.InputTokens
+	t.CacheCreationInputTokens += rec.Distribution.CacheCreationInputTokens
+	t.CacheReadInputTokens += rec.Distribution.CacheReadInputTokens
+	t.OutputTokens += rec.OutputTokens
+	t.Latency += rec.Latency
+	t.Cost = t.Cost.Add(Cost(rec))
+}
+
+func (t *UsageTotals) merge(other UsageTotals) {
+	t.Requests += other.Requests
+	t.Failures += other.Failures
+	t.StreamedRequests += other.StreamedRequests
+	t.CachedRequests += other.CachedRequests
+	t.CancelledRequests += other.CancelledRequests
+	t.EstimatedRequests += other.EstimatedRequests
+	t.TruncatedMessages += other.TruncatedMessages
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.CacheCreationInputTokens += other.CacheCreationInputTokens
+	t.CacheReadInputTokens += other.CacheReadInputTokens
+	t.Latency += other.Latency
+	t.Cost = t.Cost.Add(other.Cost)
+}
+
+type recorderHolder struct{ recorder Recorder }
+
+var (
+	defaultMemoryRecorder = NewMemoryRecorder(defaultMemoryRecorderCapacity)
+	activeRecorder        atomic.Value
+)
+
+func init() {
+	activeRecorder.Store(recorderHolder{recorder: defaultMemoryRecorder})
+	coreusage.RegisterPlugin(recorderPlugin{})
+}
+
+// SetRecorder replaces the active recorder. Passing nil restores the default in-memory recorder.
+func SetRecorder(r Recorder) {
+	if r == nil {
+		r = defaultMemoryRecorder
+	}
+	activeRecorder.Store(recorderHolder{recorder: r})
+}
+
+// ActiveRecorder returns the recorder currently receiving request usage.
+func ActiveRecorder() Recorder {
+	return activeRecorder.Load().(recorderHolder).recorder
+}
+
+// DefaultMemoryRecorder returns the built-in in-memory recorder.
+func DefaultMemoryRecorder() *MemoryRecorder { return defaultMemoryRecorder }
+
+// recorderPlugin adapts coreusage records emitted by the runtime into RequestUsage for the active recorder.
+type recorderPlugin struct{}
+
+// HandleUsage implements coreusage.Plugin. Records of hedged duplicates still count
+// toward their credential's recent load but are not recorded as requests.
+func (recorderPlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	rec := RequestUsageFromRecord(record)
+	recentTokens.add(rec.AuthID, rec.Timestamp, rec.InputTokens+rec.OutputTokens)
+	if record.Hedged {
+		return
+	}
+	ActiveRecorder().Record(ctx, rec)
+}
+
+// RequestUsageFromRecord converts a runtime usage record into a RequestUsage.
+// Providers that simulate prompt caching have their input distributed with the
+// configured cache ratio; other providers keep the cache reads and writes the
+// upstream reported.
+func RequestUsageFromRecord(record coreusage.Record) RequestUsage {
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	input := record.Detail.InputTokens
+	simulated := SimulatesCacheUsage(record.Provider)
+	var dist CacheTokenDistribution
+	if simulated {
+		dist = DistributeCacheTokensForProvider(record.Provider, record.Model, input)
+	} else {
+		dist = upstreamCacheDistribution(record.Provider, record.Detail)
+	}
+	return RequestUsage{
+		Timestamp:    timestamp,
+		Provider:     record.Provider,
+		Model:        record.Model,
+		AuthID:       record.AuthID,
+		AuthIndex:    record.AuthIndex,
+		APIKey:       record.APIKey,
+		Source:       record.Source,
+		InputTokens:  input,
+		OutputTokens: record.Detail.OutputTokens,
+		Distribution: dist,
+		Latency:      record.Latency,
+		Streamed:     record.Streamed,
+		Failed:       record.Failed,
+		Cached:       record.Cached,
+		Cancelled:    record.Cancelled,
+
+		TruncatedMessages: record.TruncatedMessages,
+
+		CacheSimulated: simulated,
+		Estimated:      record.Detail.Estimated,
+	}
+}
+
+// upstreamCacheDistribution splits the input of detail into the uncached, cache write
+// and cache read counts reported by provider.
+func upstreamCacheDistribution(provider string, detail coreusage.Detail) CacheTokenDistribution {
+	dist := CacheTokenDistribution{
+		InputTokens:              detail.InputTokens,
+		CacheCreationInputTokens: max(detail.CacheCreationTokens, 0),
+		CacheReadInputTokens:     max(detail.CachedTokens, 0),
+	}
+	if _, ok := cacheExclusiveInputProviders[strings.ToLower(strings.TrimSpace(provider))]; !ok {
+		dist.InputTokens = max(dist.InputTokens-dist.CacheCreationInputTokens-dist.CacheReadInputTokens, 0)
+	}
+	return dist
+}
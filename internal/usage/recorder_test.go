@@ -0,0 +1,175 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestMemoryRecorderTotals(t *testing.T) {
+	rec := NewMemoryRecorder(10)
+	now := time.Now()
+	rec.Record(context.Background(), RequestUsage{
+		Timestamp:    now,
+		Provider:     "kiro",
+		Model:        "claude-sonnet-4.5",
+		AuthID:       "a",
+		OutputTokens: 10,
+		Distribution: CacheTokenDistribution{InputTokens: 35, CacheCreationInputTokens: 71, CacheReadInputTokens: 894},
+		Streamed:     true,
+	})
+	rec.Record(context.Background(), RequestUsage{
+		Timestamp:    now,
+		Provider:     "gemini",
+		Model:        "gemini-2.5-pro",
+		AuthID:       "b",
+		OutputTokens: 5,
+		Distribution: CacheTokenDistribution{InputTokens: 20},
+		Failed:       true,
+	})
+
+	all := rec.Totals(UsageFilter{})
+	if all.Requests != 2 || all.Failures != 1 || all.StreamedRequests != 1 {
+		t.Fatalf("unexpected request counts: %+v", all)
+	}
+	if all.InputTokens != 55 || all.CacheCreationInputTokens != 71 || all.CacheReadInputTokens != 894 || all.OutputTokens != 15 {
+		t.Fatalf("unexpected token totals: %+v", all)
+	}
+
+	kiro := rec.Totals(UsageFilter{Provider: "KIRO"})
+	if kiro.Requests != 1 || kiro.OutputTokens != 10 {
+		t.Fatalf("unexpected provider totals: %+v", kiro)
+	}
+	if got := rec.Totals(UsageFilter{Since: now.Add(time.Second)}); got.Requests != 0 {
+		t.Fatalf("expected no records after since, got %+v", got)
+	}
+}
+
+func TestMemoryRecorderCapacity(t *testing.T) {
+	rec := NewMemoryRecorder(2)
+	for i := int64(1); i <= 3; i++ {
+		rec.Record(context.Background(), RequestUsage{OutputTokens: i})
+	}
+	records := rec.Records()
+	if len(records) != 2 || records[0].OutputTokens != 2 || records[1].OutputTokens != 3 {
+		t.Fatalf("expected the two newest records in order, got %+v", records)
+	}
+}
+
+func TestRequestUsageFromRecord(t *testing.T) {
+	kiro := RequestUsageFromRecord(coreusage.Record{
+		Provider: "kiro",
+		Model:    "claude-sonnet-4.5",
+		Streamed: true,
+		Latency:  time.Second,
+		Detail:   coreusage.Detail{InputTokens: 1000, OutputTokens: 20, Estimated: true},
+	})
+	if kiro.Distribution != DistributeCacheTokens(1000) {
+		t.Fatalf("expected simulated distribution, got %+v", kiro.Distribution)
+	}
+	if !kiro.Streamed || kiro.Latency != time.Second || kiro.OutputTokens != 20 || kiro.InputTokens != 1000 {
+		t.Fatalf("unexpected conversion: %+v", kiro)
+	}
+
+	claude := RequestUsageFromRecord(coreusage.Record{
+		Provider: "claude",
+		Detail:   coreusage.Detail{InputTokens: 1000},
+	})
+	if claude.Distribution != (CacheTokenDistribution{InputTokens: 1000}) {
+		t.Fatalf("expected undistributed input for non-simulating provider, got %+v", claude.Distribution)
+	}
+	if !kiro.CacheSimulated || claude.CacheSimulated {
+		t.Fatalf("expected only kiro to be marked simulated, got %t and %t", kiro.CacheSimulated, claude.CacheSimulated)
+	}
+	if !kiro.Estimated || claude.Estimated {
+		t.Fatalf("expected only kiro to be marked estimated, got %t and %t", kiro.Estimated, claude.Estimated)
+	}
+}
+
+func TestRequestUsageFromRecord_UpstreamCacheCounts(t *testing.T) {
+	claude := RequestUsageFromRecord(coreusage.Record{
+		Provider: "claude",
+		Detail:   coreusage.Detail{InputTokens: 40, CachedTokens: 900, CacheCreationTokens: 60},
+	})
+	if want := (CacheTokenDistribution{InputTokens: 40, CacheCreationInputTokens: 60, CacheReadInputTokens: 900}); claude.Distribution != want {
+		t.Fatalf("claude distribution = %+v, want %+v", claude.Distribution, want)
+	}
+
+	codex := RequestUsageFromRecord(coreusage.Record{
+		Provider: "codex",
+		Detail:   coreusage.Detail{InputTokens: 1000, CachedTokens: 800},
+	})
+	if want := (CacheTokenDistribution{InputTokens: 200, CacheReadInputTokens: 800}); codex.Distribution != want {
+		t.Fatalf("codex distribution = %+v, want %+v", codex.Distribution, want)
+	}
+}
+
+type captureRecorder struct {
+	mu      sync.Mutex
+	records []RequestUsage
+}
+
+func (c *captureRecorder) Record(_ context.Context, rec RequestUsage) {
+	c.mu.Lock()
+	c.records = append(c.records, rec)
+	c.mu.Unlock()
+}
+
+func TestSetRecorder(t *testing.T) {
+	t.Cleanup(func() { SetRecorder(nil) })
+
+	custom := &captureRecorder{}
+	SetRecorder(custom)
+	recorderPlugin{}.HandleUsage(context.Background(), coreusage.Record{Provider: "codex", Failed: true})
+	if len(custom.records) != 1 || !custom.records[0].Failed {
+		t.Fatalf("expected custom recorder to receive the failed record, got %+v", custom.records)
+	}
+	recorderPlugin{}.HandleUsage(context.Background(), coreusage.Record{Provider: "codex", Hedged: true})
+	if len(custom.records) != 1 {
+		t.Fatalf("expected hedged duplicates not to be recorded, got %+v", custom.records)
+	}
+
+	SetRecorder(nil)
+	if ActiveRecorder() != Recorder(DefaultMemoryRecorder()) {
+		t.Fatal("SetRecorder(nil) should restore the default recorder")
+	}
+}
+
+func TestMemoryRecorder_QueryTotals(t *testing.T) {
+	r := NewMemoryRecorder(8)
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	r.Record(ctx, RequestUsage{Timestamp: day, Provider: "kiro", Model: "b", AuthID: "x", Distribution: CacheTokenDistribution{InputTokens: 1}})
+	r.Record(ctx, RequestUsage{Timestamp: day, Provider: "kiro", Model: "a", AuthID: "x", Distribution: CacheTokenDistribution{InputTokens: 2}})
+	r.Record(ctx, RequestUsage{Timestamp: day.Add(24 * time.Hour), Provider: "kiro", Model: "a", AuthID: "y", Distribution: CacheTokenDistribution{InputTokens: 4}})
+
+	groups, err := r.QueryTotals(ctx, UsageQuery{GroupBy: []string{"Model", GroupByDay}})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %+v", groups)
+	}
+	if groups[0].Model != "a" || groups[0].Day != "2026-03-01" || groups[0].InputTokens != 2 {
+		t.Fatalf("unexpected first group: %+v", groups[0])
+	}
+	if groups[2].Model != "b" || groups[2].Account != "" {
+		t.Fatalf("unexpected last group: %+v", groups[2])
+	}
+
+	ranged, err := r.QueryTotals(ctx, UsageQuery{From: day.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(ranged) != 1 || ranged[0].Requests != 1 || ranged[0].InputTokens != 4 {
+		t.Fatalf("unexpected ranged totals: %+v", ranged)
+	}
+
+	if _, err = r.QueryTotals(ctx, UsageQuery{GroupBy: []string{"api_key"}}); !errors.Is(err, ErrUnsupportedGroupBy) {
+		t.Fatalf("expected ErrUnsupportedGroupBy, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// DefaultSizeBoundaries are the input-size bucket boundaries used by SizeBucket,
+// giving the buckets "<100", "100-1k", "1k-10k" and "10k+".
+var DefaultSizeBoundaries = []int64{100, 1000, 10000}
+
+var defaultBucketer = Bucketer{Boundaries: DefaultSizeBoundaries}
+
+// SizeBucket returns the DefaultSizeBoundaries bucket label for a total input size.
+func SizeBucket(total int64) string {
+	return defaultBucketer.Bucket(total)
+}
+
+// Bucketer maps token totals to a small, fixed set of labels suitable as a metrics
+// label dimension.
+//
+// Buckets are lower-inclusive: with Boundaries [100, 1000], a total of 99 is "<100",
+// 100 and 999 are "100-1k", and 1000 is "1k+". Negative totals fall in the first
+// bucket. Labels write multiples of 1000 and 1000000 as "k" and "M".
+type Bucketer struct {
+	// Boundaries are the strictly increasing lower bounds of every bucket but the
+	// first. Empty puts every total in the single bucket "all".
+	Boundaries []int64
+}
+
+// Validate reports whether Boundaries are strictly increasing and positive.
+func (b Bucketer) Validate() error {
+	for i, boundary := range b.Boundaries {
+		if boundary <= 0 {
+			return fmt.Errorf("usage: bucket boundary must be positive, got %d", boundary)
+		}
+		if i > 0 && boundary <= b.Boundaries[i-1] {
+			return errors.New("usage: bucket boundaries must be strictly increasing")
+		}
+	}
+	return nil
+}
+
+// Bucket returns the label of the bucket holding total. Boundaries must pass Validate.
+func (b Bucketer) Bucket(total int64) string {
+	n := len(b.Boundaries)
+	if n == 0 {
+		return "all"
+	}
+	// i is the number of boundaries <= total, so the bucket is [Boundaries[i-1], Boundaries[i]).
+	i := sort.Search(n, func(i int) bool { return b.Boundaries[i] > total })
+	switch i {
+	case 0:
+		return "<" + formatBucketBound(b.Boundaries[0])
+	case n:
+		return formatBucketBound(b.Boundaries[n-1]) + "+"
+	default:
+		return formatBucketBound(b.Boundaries[i-1]) + "-" + formatBucketBound(b.Boundaries[i])
+	}
+}
+
+func formatBucketBound(v int64) string {
+	switch {
+	case v%1000000 == 0:
+		return strconv.FormatInt(v/1000000, 10) + "M"
+	case v%1000 == 0:
+		return strconv.FormatInt(v/1000, 10) + "k"
+	default:
+		return strconv.FormatInt(v, 10)
+	}
+}
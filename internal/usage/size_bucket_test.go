@@ -0,0 +1,48 @@
+package usage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSizeBucket(t *testing.T) {
+	for _, tc := range []struct {
+		total int64
+		want  string
+	}{
+		{math.MinInt64, "<100"},
+		{-1, "<100"},
+		{0, "<100"},
+		{99, "<100"},
+		{100, "100-1k"},
+		{999, "100-1k"},
+		{1000, "1k-10k"},
+		{9999, "1k-10k"},
+		{10000, "10k+"},
+		{math.MaxInt64, "10k+"},
+	} {
+		if got := SizeBucket(tc.total); got != tc.want {
+			t.Errorf("SizeBucket(%d) = %q, want %q", tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestBucketer(t *testing.T) {
+	b := Bucketer{Boundaries: []int64{512, 4000, 2000000}}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	for total, want := range map[int64]string{511: "<512", 512: "512-4k", 3999: "512-4k", 4000: "4k-2M", 2000000: "2M+"} {
+		if got := b.Bucket(total); got != want {
+			t.Errorf("Bucket(%d) = %q, want %q", total, got, want)
+		}
+	}
+	if got := (Bucketer{}).Bucket(42); got != "all" {
+		t.Errorf("empty Bucketer = %q, want all", got)
+	}
+	for _, bad := range [][]int64{{0, 10}, {10, 10}, {100, 50}} {
+		if err := (Bucketer{Boundaries: bad}).Validate(); err == nil {
+			t.Errorf("Validate(%v) accepted invalid boundaries", bad)
+		}
+	}
+}
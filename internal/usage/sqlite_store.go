@@ -0,0 +1,507 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultSQLiteFlushInterval = time.Second
+	defaultSQLiteBatchSize     = 100
+	// maxSQLitePendingRecords bounds the write buffer when the database is unavailable.
+	maxSQLitePendingRecords = 10000
+	sqlitePruneInterval     = time.Hour
+	usageDayLayout          = "2006-01-02"
+)
+
+//go:embed migrations/*.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStoreConfig configures a SQLite-backed usage store.
+type SQLiteStoreConfig struct {
+	// Path is the database file location. Parent directories are created when missing.
+	Path string
+	// RetentionDays prunes rows older than the given number of days. Zero keeps all rows.
+	RetentionDays int
+	// FlushInterval is the maximum time a record waits in the write buffer. Defaults to one second.
+	FlushInterval time.Duration
+	// BatchSize triggers an early flush once this many records are buffered. Defaults to 100.
+	BatchSize int
+}
+
+// SQLiteStore persists RequestUsage rows to SQLite. It implements Recorder and buffers
+// writes so recording never blocks the request path on disk I/O.
+type SQLiteStore struct {
+	db  *sql.DB
+	cfg SQLiteStoreConfig
+
+	mu      sync.Mutex
+	pending []RequestUsage
+
+	flushCh   chan struct{}
+	stopCh    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// OpenSQLiteStore opens (creating if necessary) the database at cfg.Path, applies the
+// embedded migrations, prunes expired rows and starts the background flusher.
+func OpenSQLiteStore(ctx context.Context, cfg SQLiteStoreConfig) (*SQLiteStore, error) {
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		return nil, fmt.Errorf("usage sqlite store: path is required")
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultSQLiteFlushInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultSQLiteBatchSize
+	}
+	if cfg.RetentionDays < 0 {
+		cfg.RetentionDays = 0
+	}
+	cfg.Path = path
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("usage sqlite store: create directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("usage sqlite store: open database: %w", err)
+	}
+	// A single connection serializes writers and avoids SQLITE_BUSY between the flusher and queries.
+	db.SetMaxOpenConns(1)
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("usage sqlite store: ping database: %w", err)
+	}
+
+	store := &SQLiteStore{
+		db:      db,
+		cfg:     cfg,
+		flushCh: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err = store.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if _, err = store.Prune(ctx, time.Now()); err != nil {
+		log.Warnf("usage sqlite store: initial prune failed: %v", err)
+	}
+	go store.run()
+	return store, nil
+}
+
+func (s *SQLiteStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("usage sqlite store: create migrations table: %w", err)
+	}
+	entries, err := fs.ReadDir(sqliteMigrations, "migrations")
+	if err != nil {
+		return fmt.Errorf("usage sqlite store: read migrations: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, _ := strings.Cut(name, "_")
+		version, errParse := strconv.Atoi(prefix)
+		if errParse != nil {
+			return fmt.Errorf("usage sqlite store: invalid migration name %s", name)
+		}
+		var applied int
+		if err = s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM schema_migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+			return fmt.Errorf("usage sqlite store: check migration %s: %w", name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+		script, errRead := sqliteMigrations.ReadFile("migrations/" + name)
+		if errRead != nil {
+			return fmt.Errorf("usage sqlite store: read migration %s: %w", name, errRead)
+		}
+		tx, errTx := s.db.BeginTx(ctx, nil)
+		if errTx != nil {
+			return fmt.Errorf("usage sqlite store: begin migration %s: %w", name, errTx)
+		}
+		if _, err = tx.ExecContext(ctx, string(script)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("usage sqlite store: apply migration %s: %w", name, err)
+		}
+		if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().Unix()); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("usage sqlite store: record migration %s: %w", name, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("usage sqlite store: commit migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Record implements Recorder by buffering rec for the next batch write.
+func (s *SQLiteStore) Record(_ context.Context, rec RequestUsage) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if len(s.pending) >= maxSQLitePendingRecords {
+		s.pending = s.pending[1:]
+		log.Warn("usage sqlite store: write buffer full, dropping oldest record")
+	}
+	s.pending = append(s.pending, rec)
+	full := len(s.pending) >= s.cfg.BatchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *SQLiteStore) run() {
+	defer close(s.done)
+	flushTicker := time.NewTicker(s.cfg.FlushInterval)
+	defer flushTicker.Stop()
+	pruneTicker := time.NewTicker(sqlitePruneInterval)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-flushTicker.C:
+		case <-s.flushCh:
+		case now := <-pruneTicker.C:
+			if _, err := s.Prune(context.Background(), now); err != nil {
+				log.Warnf("usage sqlite store: prune failed: %v", err)
+			}
+			continue
+		}
+		if err := s.Flush(context.Background()); err != nil {
+			log.Warnf("usage sqlite store: flush failed: %v", err)
+		}
+	}
+}
+
+// Flush writes all buffered records in a single transaction.
+// Records are returned to the buffer when the write fails.
+func (s *SQLiteStore) Flush(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := s.insert(ctx, batch); err != nil {
+		s.mu.Lock()
+		s.pending = append(batch, s.pending...)
+		if over := len(s.pending) - maxSQLitePendingRecords; over > 0 {
+			s.pending = s.pending[over:]
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) insert(ctx context.Context, batch []RequestUsage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("usage sqlite store: begin insert: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO usage_records (
+		requested_at, day, provider, model, account, auth_index, source,
+		input_tokens, cache_creation_input_tokens, cache_read_input_tokens, output_tokens,
+		latency_ms, streamed, failed, cached, cancelled, estimated, truncated_messages
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("usage sqlite store: prepare insert: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, rec := range batch {
+		ts := rec.Timestamp.UTC()
+		if _, err = stmt.ExecContext(ctx,
+			ts.UnixMilli(), ts.Format(usageDayLayout), rec.Provider, rec.Model, rec.AuthID, rec.AuthIndex, rec.Source,
+			rec.Distribution.InputTokens, rec.Distribution.CacheCreationInputTokens, rec.Distribution.CacheReadInputTokens, rec.OutputTokens,
+			rec.Latency.Milliseconds(), boolToInt(rec.Streamed), boolToInt(rec.Failed), boolToInt(rec.Cached), boolToInt(rec.Cancelled),
+			boolToInt(rec.Estimated), rec.TruncatedMessages,
+		); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("usage sqlite store: insert record: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("usage sqlite store: commit insert: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes rows older than the configured retention relative to now.
+// It returns the number of deleted rows and is a no-op when retention is disabled.
+func (s *SQLiteStore) Prune(ctx context.Context, now time.Time) (int64, error) {
+	if s == nil || s.cfg.RetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-time.Duration(s.cfg.RetentionDays) * 24 * time.Hour).UnixMilli()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM usage_records WHERE requested_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("usage sqlite store: prune: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+	if deleted > 0 {
+		log.Infof("usage sqlite store: pruned %d records older than %d days", deleted, s.cfg.RetentionDays)
+	}
+	return deleted, nil
+}
+
+// Close stops the background flusher, writes any buffered records and closes the database.
+func (s *SQLiteStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stopCh)
+		<-s.done
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if errFlush := s.Flush(ctx); errFlush != nil {
+			err = errFlush
+		}
+		if errClose := s.db.Close(); errClose != nil && err == nil {
+			err = errClose
+		}
+	})
+	return err
+}
+
+// QueryTotals aggregates persisted usage for q. Buffered records are flushed first so
+// the result includes everything recorded so far.
+func (s *SQLiteStore) QueryTotals(ctx context.Context, q UsageQuery) ([]UsageGroup, error) {
+	if s == nil {
+		return nil, errors.New("usage sqlite store: store is nil")
+	}
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	groupCols, err := normalizeGroupBy(q.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	if !q.From.IsZero() {
+		where = append(where, "requested_at >= ?")
+		args = append(args, q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "requested_at < ?")
+		args = append(args, q.To.UnixMilli())
+	}
+
+	// Cost depends on the model, so rows are always split by model and merged back
+	// into the requested groups after pricing.
+	queryCols := append([]string(nil), groupCols...)
+	modelIdx := slices.Index(queryCols, GroupByModel)
+	if modelIdx < 0 {
+		modelIdx = len(queryCols)
+		queryCols = append(queryCols, GroupByModel)
+	}
+	selectCols := append([]string(nil), queryCols...)
+	selectCols = append(selectCols,
+		"COUNT(1)", "COALESCE(SUM(failed), 0)", "COALESCE(SUM(streamed), 0)", "COALESCE(SUM(cached), 0)", "COALESCE(SUM(cancelled), 0)",
+		"COALESCE(SUM(estimated), 0)",
+		"COALESCE(SUM(truncated_messages), 0)",
+		"COALESCE(SUM(input_tokens), 0)", "COALESCE(SUM(output_tokens), 0)",
+		"COALESCE(SUM(cache_creation_input_tokens), 0)", "COALESCE(SUM(cache_read_input_tokens), 0)",
+		"COALESCE(SUM(latency_ms), 0)",
+	)
+	query := "SELECT " + strings.Join(selectCols, ", ") + " FROM usage_records"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " GROUP BY " + strings.Join(queryCols, ", ") + " ORDER BY " + strings.Join(queryCols, ", ")
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("usage sqlite store: query totals: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []UsageGroup
+	for rows.Next() {
+		var (
+			group     UsageGroup
+			model     string
+			latencyMS int64
+		)
+		dest := make([]any, 0, len(selectCols))
+		for i, col := range queryCols {
+			if i == modelIdx {
+				dest = append(dest, &model)
+				continue
+			}
+			switch col {
+			case GroupByDay:
+				dest = append(dest, &group.Day)
+			case GroupByAccount:
+				dest = append(dest, &group.Account)
+			case GroupByProvider:
+				dest = append(dest, &group.Provider)
+			}
+		}
+		dest = append(dest,
+			&group.Requests, &group.Failures, &group.StreamedRequests, &group.CachedRequests, &group.CancelledRequests,
+			&group.EstimatedRequests,
+			&group.TruncatedMessages,
+			&group.InputTokens, &group.OutputTokens,
+			&group.CacheCreationInputTokens, &group.CacheReadInputTokens,
+			&latencyMS,
+		)
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("usage sqlite store: scan totals: %w", err)
+		}
+		group.Latency = time.Duration(latencyMS) * time.Millisecond
+		group.Cost = Cost(RequestUsage{
+			Model:        model,
+			OutputTokens: group.OutputTokens,
+			Distribution: CacheTokenDistribution{
+				InputTokens:              group.InputTokens,
+				CacheCreationInputTokens: group.CacheCreationInputTokens,
+				CacheReadInputTokens:     group.CacheReadInputTokens,
+			},
+		})
+		if slices.Contains(groupCols, GroupByModel) {
+			group.Model = model
+		}
+		// Rows are ordered by the requested columns first, so rows of one group are adjacent.
+		if n := len(out); n > 0 && out[n-1].sameKey(group) {
+			out[n-1].UsageTotals.merge(group.UsageTotals)
+			continue
+		}
+		out = append(out, group)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("usage sqlite store: iterate totals: %w", err)
+	}
+	return out, nil
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+var (
+	persistenceMu    sync.Mutex
+	persistenceStore *SQLiteStore
+	persistenceCfg   config.UsageConfig
+)
+
+// ConfigurePersistence installs the usage store selected by cfg.Usage as the active
+// recorder. Selecting the memory backend, or passing nil, closes any open SQLite store
+// and restores the default in-memory recorder. Reapplying an unchanged config is a no-op.
+func ConfigurePersistence(cfg *config.Config) error {
+	var next config.UsageConfig
+	if cfg != nil {
+		next = cfg.Usage
+	}
+	persistenceMu.Lock()
+	defer persistenceMu.Unlock()
+
+	if !strings.EqualFold(next.Persistence, config.UsagePersistenceSQLite) {
+		persistenceCfg = next
+		return closePersistenceLocked()
+	}
+	if persistenceStore != nil && persistenceCfg == next {
+		return nil
+	}
+	path, err := ResolveSQLitePath(next.SQLitePath)
+	if err != nil {
+		return err
+	}
+	store, err := OpenSQLiteStore(context.Background(), SQLiteStoreConfig{Path: path, RetentionDays: next.RetentionDays})
+	if err != nil {
+		return err
+	}
+	if errClose := closePersistenceLocked(); errClose != nil {
+		log.Warnf("usage sqlite store: close previous store: %v", errClose)
+	}
+	persistenceStore = store
+	persistenceCfg = next
+	SetRecorder(store)
+	log.Infof("usage records persisted to %s", path)
+	return nil
+}
+
+// ClosePersistence flushes and closes the active SQLite store, if any, and restores
+// the default in-memory recorder.
+func ClosePersistence() error {
+	persistenceMu.Lock()
+	defer persistenceMu.Unlock()
+	return closePersistenceLocked()
+}
+
+func closePersistenceLocked() error {
+	if persistenceStore == nil {
+		return nil
+	}
+	SetRecorder(nil)
+	err := persistenceStore.Close()
+	persistenceStore = nil
+	return err
+}
+
+// PersistentStore returns the active SQLite store, or nil when persistence is disabled.
+func PersistentStore() *SQLiteStore {
+	persistenceMu.Lock()
+	defer persistenceMu.Unlock()
+	return persistenceStore
+}
+
+// ResolveSQLitePath expands a leading ~ and anchors relative paths under WRITABLE_PATH
+// when it is set. An empty path resolves to config.DefaultUsageSQLitePath.
+func ResolveSQLitePath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = config.DefaultUsageSQLitePath
+	}
+	if strings.HasPrefix(path, "~") {
+		return util.ResolveAuthDir(path)
+	}
+	if base := util.WritablePath(); base != "" && !filepath.IsAbs(path) {
+		return filepath.Join(base, path), nil
+	}
+	return filepath.Clean(path), nil
+}
@@ -0,0 +1,208 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func openTestSQLiteStore(t *testing.T, path string, retentionDays int) *SQLiteStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(context.Background(), SQLiteStoreConfig{
+		Path:          path,
+		RetentionDays: retentionDays,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	return store
+}
+
+func testRequestUsage(ts time.Time, model, account string, input int64) RequestUsage {
+	return RequestUsage{
+		Timestamp:    ts,
+		Provider:     "kiro",
+		Model:        model,
+		AuthID:       account,
+		InputTokens:  input,
+		OutputTokens: 10,
+		Distribution: DistributeCacheTokens(input),
+		Latency:      250 * time.Millisecond,
+	}
+}
+
+func TestSQLiteStore_QueryTotalsGrouped(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "usage.db"), 0)
+	t.Cleanup(func() { _ = store.Close() })
+
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	ctx := context.Background()
+	store.Record(ctx, testRequestUsage(day1, "claude-sonnet-4.5", "a", 1000))
+	store.Record(ctx, testRequestUsage(day1.Add(time.Hour), "claude-sonnet-4.5", "a", 1000))
+	store.Record(ctx, testRequestUsage(day1, "claude-haiku-4.5", "b", 50))
+	store.Record(ctx, testRequestUsage(day2, "claude-sonnet-4.5", "a", 1000))
+
+	groups, err := store.QueryTotals(ctx, UsageQuery{From: day1, To: day2, GroupBy: []string{GroupByDay, GroupByModel}})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d: %+v", len(groups), groups)
+	}
+	haiku, sonnet := groups[0], groups[1]
+	if haiku.Day != "2026-03-01" || haiku.Model != "claude-haiku-4.5" || haiku.Requests != 1 || haiku.InputTokens != 50 {
+		t.Fatalf("unexpected haiku group: %+v", haiku)
+	}
+	if sonnet.Model != "claude-sonnet-4.5" || sonnet.Requests != 2 || sonnet.OutputTokens != 20 {
+		t.Fatalf("unexpected sonnet group: %+v", sonnet)
+	}
+	if sonnet.InputTokens != 70 || sonnet.CacheCreationInputTokens != 142 || sonnet.CacheReadInputTokens != 1788 {
+		t.Fatalf("unexpected sonnet distribution: %+v", sonnet.UsageTotals)
+	}
+	if sonnet.Latency != 500*time.Millisecond {
+		t.Fatalf("Latency = %v, want 500ms", sonnet.Latency)
+	}
+	if sonnet.Account != "" {
+		t.Fatalf("ungrouped dimension should be empty, got %q", sonnet.Account)
+	}
+
+	byAccount, err := store.QueryTotals(ctx, UsageQuery{GroupBy: []string{GroupByAccount}})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(byAccount) != 2 || byAccount[0].Account != "a" || byAccount[0].Requests != 3 {
+		t.Fatalf("unexpected account groups: %+v", byAccount)
+	}
+
+	if _, err = store.QueryTotals(ctx, UsageQuery{GroupBy: []string{"model; DROP TABLE usage_records"}}); err == nil {
+		t.Fatalf("expected error for unsupported group dimension")
+	}
+}
+
+func TestSQLiteStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "usage.db")
+	store := openTestSQLiteStore(t, path, 0)
+	estimated := testRequestUsage(time.Now(), "m", "a", 1000)
+	estimated.Estimated = true
+	estimated.TruncatedMessages = 3
+	store.Record(context.Background(), estimated)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := openTestSQLiteStore(t, path, 0)
+	t.Cleanup(func() { _ = reopened.Close() })
+	groups, err := reopened.QueryTotals(context.Background(), UsageQuery{})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Requests != 1 || groups[0].EstimatedRequests != 1 || groups[0].TruncatedMessages != 3 {
+		t.Fatalf("records should persist across reopen, got %+v", groups)
+	}
+}
+
+func TestSQLiteStore_MigratesStoreAtVersion4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.db")
+	store := openTestSQLiteStore(t, path, 0)
+	// Roll the schema back to what a store that applied migration 0004 looks like.
+	for _, stmt := range []string{
+		`ALTER TABLE usage_records DROP COLUMN truncated_messages`,
+		`DELETE FROM schema_migrations WHERE version >= 5`,
+	} {
+		if _, err := store.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := openTestSQLiteStore(t, path, 0)
+	rec := testRequestUsage(time.Now(), "m", "a", 1000)
+	rec.TruncatedMessages = 2
+	reopened.Record(context.Background(), rec)
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened = openTestSQLiteStore(t, path, 0)
+	t.Cleanup(func() { _ = reopened.Close() })
+	groups, err := reopened.QueryTotals(context.Background(), UsageQuery{})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(groups) != 1 || groups[0].TruncatedMessages != 2 {
+		t.Fatalf("record after migrating from version 4 = %+v", groups)
+	}
+}
+
+func TestSQLiteStore_Prune(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "usage.db"), 7)
+	t.Cleanup(func() { _ = store.Close() })
+
+	now := time.Now()
+	ctx := context.Background()
+	store.Record(ctx, testRequestUsage(now.Add(-10*24*time.Hour), "m", "a", 10))
+	store.Record(ctx, testRequestUsage(now.Add(-time.Hour), "m", "a", 10))
+	if err := store.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	deleted, err := store.Prune(ctx, now)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("Prune deleted %d rows, want 1", deleted)
+	}
+	groups, err := store.QueryTotals(ctx, UsageQuery{})
+	if err != nil {
+		t.Fatalf("QueryTotals: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Requests != 1 {
+		t.Fatalf("unexpected totals after prune: %+v", groups)
+	}
+}
+
+func TestConfigurePersistence(t *testing.T) {
+	t.Cleanup(func() { _ = ClosePersistence() })
+
+	cfg := &config.Config{Usage: config.UsageConfig{
+		Persistence: config.UsagePersistenceSQLite,
+		SQLitePath:  filepath.Join(t.TempDir(), "usage.db"),
+	}}
+	if err := ConfigurePersistence(cfg); err != nil {
+		t.Fatalf("ConfigurePersistence: %v", err)
+	}
+	store := PersistentStore()
+	if store == nil || ActiveRecorder() != Recorder(store) {
+		t.Fatalf("sqlite store should be the active recorder")
+	}
+	if err := ConfigurePersistence(cfg); err != nil {
+		t.Fatalf("ConfigurePersistence (unchanged): %v", err)
+	}
+	if PersistentStore() != store {
+		t.Fatalf("unchanged config should keep the open store")
+	}
+
+	if err := ConfigurePersistence(&config.Config{}); err != nil {
+		t.Fatalf("ConfigurePersistence (memory): %v", err)
+	}
+	if PersistentStore() != nil || ActiveRecorder() != Recorder(DefaultMemoryRecorder()) {
+		t.Fatalf("memory backend should restore the default recorder")
+	}
+}
+
+func TestResolveSQLitePath(t *testing.T) {
+	t.Setenv("WRITABLE_PATH", "/data")
+	if got, _ := ResolveSQLitePath(""); got != filepath.Join("/data", config.DefaultUsageSQLitePath) {
+		t.Fatalf("empty path = %q", got)
+	}
+	if got, _ := ResolveSQLitePath("/var/lib/usage.db"); got != "/var/lib/usage.db" {
+		t.Fatalf("absolute path = %q", got)
+	}
+}
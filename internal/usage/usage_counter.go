@@ -0,0 +1,43 @@
+package usage
+
+import "sync"
+
+// UsageCounter accumulates cache token distributions per key, typically a client API key.
+// It is safe for concurrent use by proxy workers.
+type UsageCounter struct {
+	mu     sync.RWMutex
+	totals map[string]CacheTokenDistribution
+}
+
+// NewUsageCounter returns an empty counter. The zero value is also ready to use.
+func NewUsageCounter() *UsageCounter {
+	return &UsageCounter{totals: make(map[string]CacheTokenDistribution)}
+}
+
+// AddDistribution adds d to the running total for key.
+func (c *UsageCounter) AddDistribution(key string, d CacheTokenDistribution) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.totals == nil {
+		c.totals = make(map[string]CacheTokenDistribution)
+	}
+	c.totals[key] = c.totals[key].Add(d)
+}
+
+// Snapshot returns a copy of all running totals. The returned map is owned by the
+// caller and is not affected by later additions.
+func (c *UsageCounter) Snapshot() map[string]CacheTokenDistribution {
+	if c == nil {
+		return map[string]CacheTokenDistribution{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]CacheTokenDistribution, len(c.totals))
+	for key, total := range c.totals {
+		out[key] = total
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package usage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestUsageCounter_ConcurrentAdds(t *testing.T) {
+	counter := NewUsageCounter()
+	d := DistributeCacheTokens(1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counter.AddDistribution(fmt.Sprintf("key-%d", i%2), d)
+			_ = counter.Snapshot()
+		}(i)
+	}
+	wg.Wait()
+
+	snap := counter.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(snap))
+	}
+	want := CacheTokenDistribution{
+		InputTokens:              25 * d.InputTokens,
+		CacheCreationInputTokens: 25 * d.CacheCreationInputTokens,
+		CacheReadInputTokens:     25 * d.CacheReadInputTokens,
+	}
+	for key, got := range snap {
+		if got != want {
+			t.Fatalf("%s = %+v, want %+v", key, got, want)
+		}
+	}
+}
+
+func TestUsageCounter_SnapshotIsCopy(t *testing.T) {
+	var counter UsageCounter
+	counter.AddDistribution("a", CacheTokenDistribution{InputTokens: 5})
+
+	snap := counter.Snapshot()
+	snap["a"] = CacheTokenDistribution{InputTokens: 999}
+	snap["b"] = CacheTokenDistribution{InputTokens: 1}
+	counter.AddDistribution("a", CacheTokenDistribution{CacheReadInputTokens: 7})
+
+	again := counter.Snapshot()
+	if len(again) != 1 || again["a"] != (CacheTokenDistribution{InputTokens: 5, CacheReadInputTokens: 7}) {
+		t.Fatalf("counter was affected by snapshot mutation: %+v", again)
+	}
+	if snap["a"].InputTokens != 999 || snap["a"].CacheReadInputTokens != 0 {
+		t.Fatalf("snapshot was affected by a later add: %+v", snap["a"])
+	}
+}
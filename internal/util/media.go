@@ -0,0 +1,199 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// MaxInlineMediaBytes caps the decoded size of a single image or document carried
+// inline in a request, matching the smallest per-request inline limit of the
+// supported backends. Larger payloads are refused with 413 instead of being forwarded.
+const MaxInlineMediaBytes = 20 << 20
+
+// remoteMediaTimeout bounds fetching a remote image for backends that only accept
+// inline data.
+const remoteMediaTimeout = 15 * time.Second
+
+// ErrMediaTooLarge reports an image or document above MaxInlineMediaBytes.
+var ErrMediaTooLarge = errors.New("media exceeds the inline size limit")
+
+// mediaClient fetches remote media. Its dialer refuses loopback, private and
+// link-local addresses so client-supplied URLs cannot reach internal services.
+var mediaClient = &http.Client{
+	Timeout: remoteMediaTimeout,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: refuseInternalAddress,
+		}).DialContext,
+	},
+}
+
+func refuseInternalAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("refusing to fetch media from internal address %s", host)
+	}
+	return nil
+}
+
+// DetectMediaType returns the media type implied by the magic bytes at the start of
+// data for the image and document formats the backends accept, or "" when unknown.
+func DetectMediaType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "image/gif"
+	case len(data) >= 12 && bytes.HasPrefix(data, []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "image/webp"
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return "application/pdf"
+	}
+	return ""
+}
+
+// ParseDataURI splits a data URI into its media type and base64 payload. Payloads that
+// are not base64-encoded are re-encoded. The media type is taken from the payload's
+// magic bytes when they identify a known format, since clients often label every
+// image as image/png; otherwise the declared type is kept.
+func ParseDataURI(uri string) (mediaType, data string, ok bool) {
+	if !strings.HasPrefix(uri, "data:") {
+		return "", "", false
+	}
+	header, payload, found := strings.Cut(uri[len("data:"):], ",")
+	if !found {
+		return "", "", false
+	}
+	params := strings.Split(header, ";")
+	declared := strings.ToLower(strings.TrimSpace(params[0]))
+	encoded := false
+	for _, param := range params[1:] {
+		if strings.EqualFold(strings.TrimSpace(param), "base64") {
+			encoded = true
+		}
+	}
+	if !encoded {
+		raw, err := url.PathUnescape(payload)
+		if err != nil {
+			return "", "", false
+		}
+		payload = base64.StdEncoding.EncodeToString([]byte(raw))
+	}
+	return SniffBase64MediaType(payload, declared), payload, true
+}
+
+// SniffBase64MediaType returns the media type detected from the first bytes of the
+// base64 payload data, falling back to declared and then application/octet-stream.
+func SniffBase64MediaType(data, declared string) string {
+	head := data[:min(len(data), 32)]
+	head = head[:len(head)/4*4]
+	if decoded, err := base64.StdEncoding.DecodeString(head); err == nil {
+		if detected := DetectMediaType(decoded); detected != "" {
+			return detected
+		}
+	}
+	if declared != "" {
+		return declared
+	}
+	return "application/octet-stream"
+}
+
+// FetchMedia downloads a remote image or document for backends that only accept
+// inline data and returns its media type and base64 payload. Only http and https
+// URLs of public hosts are fetched; bodies above MaxInlineMediaBytes fail with
+// ErrMediaTooLarge.
+func FetchMedia(ctx context.Context, rawURL string) (mediaType, data string, err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", "", fmt.Errorf("unsupported media URL %q", rawURL)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := mediaClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("fetch media %s: status %d", rawURL, resp.StatusCode)
+	}
+	if resp.ContentLength > MaxInlineMediaBytes {
+		return "", "", fmt.Errorf("fetch media %s: %w (%d bytes)", rawURL, ErrMediaTooLarge, resp.ContentLength)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxInlineMediaBytes+1))
+	if err != nil {
+		return "", "", err
+	}
+	if len(body) > MaxInlineMediaBytes {
+		return "", "", fmt.Errorf("fetch media %s: %w", rawURL, ErrMediaTooLarge)
+	}
+	mediaType = DetectMediaType(body)
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	}
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	return mediaType, base64.StdEncoding.EncodeToString(body), nil
+}
+
+// CheckInlineMediaSize reports an error wrapping ErrMediaTooLarge when rawJSON carries
+// an inline image or document whose decoded size exceeds limit bytes. It inspects
+// data URIs and "data" fields, which hold the base64 payloads of Claude image and
+// document sources and Gemini inlineData parts.
+func CheckInlineMediaSize(rawJSON []byte, limit int) error {
+	// Any payload above the limit makes the body larger still.
+	if len(rawJSON) <= limit || !gjson.ValidBytes(rawJSON) {
+		return nil
+	}
+	return checkInlineMediaSize(gjson.ParseBytes(rawJSON), "", limit)
+}
+
+func checkInlineMediaSize(node gjson.Result, key string, limit int) error {
+	switch {
+	case node.IsObject() || node.IsArray():
+		var err error
+		node.ForEach(func(k, value gjson.Result) bool {
+			err = checkInlineMediaSize(value, k.String(), limit)
+			return err == nil
+		})
+		return err
+	case node.Type == gjson.String:
+		payload := node.Str
+		if strings.HasPrefix(payload, "data:") {
+			_, payload, _ = strings.Cut(payload, ",")
+		} else if key != "data" {
+			return nil
+		}
+		if size := base64.StdEncoding.DecodedLen(len(payload)); size > limit {
+			return fmt.Errorf("%w: inline payload of about %d bytes, limit is %d", ErrMediaTooLarge, size, limit)
+		}
+	}
+	return nil
+}
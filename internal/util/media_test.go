@@ -0,0 +1,90 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestParseDataURI_SniffsMediaType(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(pngHeader)
+	tests := []struct {
+		name, uri, wantType, wantData string
+	}{
+		{"lying header", "data:image/jpeg;base64," + encoded, "image/png", encoded},
+		{"unknown payload keeps declared type", "data:image/heic;base64,AAAA", "image/heic", "AAAA"},
+		{"plain text payload", "data:text/plain,hello%20world", "text/plain", base64.StdEncoding.EncodeToString([]byte("hello world"))},
+		{"missing type", "data:;base64,AAAA", "application/octet-stream", "AAAA"},
+	}
+	for _, tt := range tests {
+		mediaType, data, ok := ParseDataURI(tt.uri)
+		if !ok || mediaType != tt.wantType || data != tt.wantData {
+			t.Errorf("%s: got %q, %q, %t; want %q, %q", tt.name, mediaType, data, ok, tt.wantType, tt.wantData)
+		}
+	}
+	if _, _, ok := ParseDataURI("https://example.com/cat.png"); ok {
+		t.Errorf("expected a URL not to parse as a data URI")
+	}
+}
+
+func TestFetchMedia(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cat.png":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(pngHeader)
+		case "/huge":
+			_, _ = w.Write(make([]byte, MaxInlineMediaBytes+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if _, _, err := FetchMedia(context.Background(), srv.URL+"/cat.png"); err == nil || !strings.Contains(err.Error(), "internal address") {
+		t.Fatalf("expected loopback fetch to be refused, got %v", err)
+	}
+
+	previous := mediaClient
+	mediaClient = srv.Client()
+	defer func() { mediaClient = previous }()
+
+	mediaType, data, err := FetchMedia(context.Background(), srv.URL+"/cat.png")
+	if err != nil || mediaType != "image/png" || data != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Fatalf("FetchMedia = %q, %q, %v", mediaType, data, err)
+	}
+	if _, _, err = FetchMedia(context.Background(), srv.URL+"/huge"); !errors.Is(err, ErrMediaTooLarge) {
+		t.Fatalf("expected ErrMediaTooLarge, got %v", err)
+	}
+	if _, _, err = FetchMedia(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Fatalf("expected an error for a 404")
+	}
+	if _, _, err = FetchMedia(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Fatalf("expected non-http URLs to be refused")
+	}
+}
+
+func TestCheckInlineMediaSize(t *testing.T) {
+	payload := strings.Repeat("A", 400)
+	claude := []byte(`{"messages":[{"content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + payload + `"}}]}]}`)
+	openai := []byte(`{"messages":[{"content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + payload + `"}}]}]}`)
+	text := []byte(`{"messages":[{"content":"` + payload + `"}]}`)
+
+	for name, body := range map[string][]byte{"claude": claude, "openai": openai} {
+		if err := CheckInlineMediaSize(body, 200); !errors.Is(err, ErrMediaTooLarge) {
+			t.Errorf("%s: expected ErrMediaTooLarge, got %v", name, err)
+		}
+		if err := CheckInlineMediaSize(body, 400); err != nil {
+			t.Errorf("%s: payload under the limit rejected: %v", name, err)
+		}
+	}
+	if err := CheckInlineMediaSize(text, 200); err != nil {
+		t.Errorf("plain text rejected: %v", err)
+	}
+}
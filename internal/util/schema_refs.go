@@ -0,0 +1,74 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxSchemaRefDepth bounds how many nested $ref expansions InlineJSONSchemaRefs performs
+// along one path, which keeps recursive definitions from expanding without end.
+const maxSchemaRefDepth = 5
+
+// InlineJSONSchemaRefs replaces local "$ref" pointers such as "#/$defs/Item" or
+// "#/definitions/Item" with the schemas they reference, so backends that reject $ref
+// keep the referenced structure. Keywords next to a $ref, like description, are kept.
+// Recursive references beyond maxSchemaRefDepth and pointers that do not resolve are
+// left in place for CleanJSONSchemaForGemini to turn into hints.
+func InlineJSONSchemaRefs(jsonStr string) string {
+	root := gjson.Parse(jsonStr)
+	if !root.IsObject() || !strings.Contains(jsonStr, `"$ref"`) {
+		return jsonStr
+	}
+	return inlineSchemaRefs(root, root, 0)
+}
+
+func inlineSchemaRefs(node, root gjson.Result, depth int) string {
+	switch {
+	case node.IsObject():
+		if ref := node.Get("$ref"); ref.Type == gjson.String && depth < maxSchemaRefDepth {
+			if target, ok := resolveLocalSchemaRef(root, ref.String()); ok && target.IsObject() {
+				inlined := inlineSchemaRefs(target, root, depth+1)
+				node.ForEach(func(key, value gjson.Result) bool {
+					if key.String() != "$ref" {
+						inlined, _ = sjson.SetRaw(inlined, escapeGJSONPathKey(key.String()), inlineSchemaRefs(value, root, depth))
+					}
+					return true
+				})
+				return inlined
+			}
+		}
+		out := "{}"
+		node.ForEach(func(key, value gjson.Result) bool {
+			out, _ = sjson.SetRaw(out, escapeGJSONPathKey(key.String()), inlineSchemaRefs(value, root, depth))
+			return true
+		})
+		return out
+	case node.IsArray():
+		out := "[]"
+		node.ForEach(func(_, value gjson.Result) bool {
+			out, _ = sjson.SetRaw(out, "-1", inlineSchemaRefs(value, root, depth))
+			return true
+		})
+		return out
+	default:
+		return node.Raw
+	}
+}
+
+// resolveLocalSchemaRef resolves a JSON pointer fragment ("#/a/b") against root.
+func resolveLocalSchemaRef(root gjson.Result, ref string) (gjson.Result, bool) {
+	if !strings.HasPrefix(ref, "#/") {
+		return gjson.Result{}, false
+	}
+	current := root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		current = current.Get(escapeGJSONPathKey(token))
+		if !current.Exists() {
+			return gjson.Result{}, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestInlineJSONSchemaRefs(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"items": {"type": "array", "items": {"$ref": "#/$defs/Item"}},
+			"owner": {"$ref": "#/definitions/Person", "description": "Who owns the list"}
+		},
+		"$defs": {
+			"Item": {"type": "object", "properties": {"name": {"type": "string"}, "tags": {"type": "array", "items": {"$ref": "#/$defs/Tag"}}}},
+			"Tag": {"type": "string"}
+		},
+		"definitions": {"Person": {"type": "object", "properties": {"email": {"type": "string"}}}}
+	}`
+	got := gjson.Parse(InlineJSONSchemaRefs(schema))
+
+	if item := got.Get("properties.items.items"); item.Get("type").String() != "object" || item.Get("properties.name.type").String() != "string" {
+		t.Fatalf("expected the Item definition inlined, got %s", item.Raw)
+	}
+	if tag := got.Get("properties.items.items.properties.tags.items"); tag.Raw != `{"type":"string"}` {
+		t.Fatalf("expected nested refs inlined, got %s", tag.Raw)
+	}
+	owner := got.Get("properties.owner")
+	if owner.Get("properties.email.type").String() != "string" || owner.Get("description").String() != "Who owns the list" || owner.Get(`\$ref`).Exists() {
+		t.Fatalf("expected the Person definition inlined with its description kept, got %s", owner.Raw)
+	}
+}
+
+func TestInlineJSONSchemaRefs_RecursiveAndUnresolved(t *testing.T) {
+	schema := `{"type":"object","properties":{"node":{"$ref":"#/$defs/Node"},"remote":{"$ref":"https://example.com/schema.json"}},"$defs":{"Node":{"type":"object","properties":{"child":{"$ref":"#/$defs/Node"}}}}}`
+	got := gjson.Parse(InlineJSONSchemaRefs(schema))
+
+	path := "properties.node"
+	for i := 0; i < maxSchemaRefDepth; i++ {
+		if got.Get(path+".type").String() != "object" {
+			t.Fatalf("expected level %d of the recursive schema inlined, got %s", i, got.Get(path).Raw)
+		}
+		path += ".properties.child"
+	}
+	if got.Get(path+`.\$ref`).String() != "#/$defs/Node" {
+		t.Fatalf("expected the recursion to stop at a $ref, got %s", got.Get(path).Raw)
+	}
+	if got.Get(`properties.remote.\$ref`).String() != "https://example.com/schema.json" {
+		t.Fatalf("expected the remote ref to be left in place, got %s", got.Get("properties.remote").Raw)
+	}
+}
@@ -0,0 +1,47 @@
+package util
+
+// MatchWildcard reports whether value matches pattern, where '*' matches any run of
+// characters, including none. Every other character, '?' included, matches itself.
+// Matching is case-sensitive, so callers that match case-insensitively lower-case both
+// sides first; an empty pattern matches nothing. Examples:
+//
+//	"gpt-*" matches "gpt-5" and "gpt-4o"
+//	"gemini-*-pro" matches "gemini-2.5-pro"
+func MatchWildcard(pattern, value string) bool {
+	return matchPattern(pattern, value, false)
+}
+
+// MatchGlob is MatchWildcard with '?' additionally matching exactly one character,
+// as used by model mappings and the model lists built on them:
+//
+//	"gpt-?o" matches "gpt-4o"
+func MatchGlob(pattern, value string) bool {
+	return matchPattern(pattern, value, true)
+}
+
+func matchPattern(pattern, value string, single bool) bool {
+	if pattern == "" {
+		return false
+	}
+	p, v := 0, 0
+	star, starMatch := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (single && pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, starMatch = p, v
+			p++
+		case star >= 0:
+			starMatch++
+			p, v = star+1, starMatch
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,49 @@
+package util
+
+import "testing"
+
+func TestMatchWildcard(t *testing.T) {
+	cases := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"claude-3-5-*", "claude-3-5-sonnet-20241022", true},
+		{"claude-3-5-*", "claude-3-7-sonnet", false},
+		{"claude-*-4*", "claude-opus-4.1", true},
+		{"*", "team/gpt-5", true},
+		{"*", "", true},
+		{"gpt-?o", "gpt-4o", false},
+		{"gpt-?o", "gpt-?o", true},
+		{"*-mini", "gpt-4o-mini", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"a*a", "a", false},
+		{"gpt-5", "gpt-5", true},
+		{"gpt-5", "gpt-5-mini", false},
+		{"GPT-*", "gpt-5", false},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		if got := MatchWildcard(tc.pattern, tc.value); got != tc.want {
+			t.Fatalf("MatchWildcard(%q, %q) = %v, want %v", tc.pattern, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"gpt-?o", "gpt-4o", true},
+		{"gpt-?o", "gpt-4-o", false},
+		{"claude-?-*", "claude-3-opus", true},
+		{"*", "team/gpt-5", true},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		if got := MatchGlob(tc.pattern, tc.value); got != tc.want {
+			t.Fatalf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// auth_reload.go coalesces bursts of auth directory changes into debounced reloads.
+// The first change applies immediately; changes arriving inside the debounce window
+// are folded into a single trailing reload so editor save sequences reload once.
+package watcher
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// authReloadDebounce is the window during which further auth file changes are
+// coalesced into one trailing reload.
+const authReloadDebounce = 300 * time.Millisecond
+
+// authReloadSummary counts the credential changes applied by one reload.
+type authReloadSummary struct {
+	Added   int
+	Updated int
+	Removed int
+}
+
+func summarizeAuthUpdates(updates []AuthUpdate) authReloadSummary {
+	var summary authReloadSummary
+	for _, update := range updates {
+		switch update.Action {
+		case AuthUpdateActionAdd:
+			summary.Added++
+		case AuthUpdateActionModify:
+			summary.Updated++
+		case AuthUpdateActionDelete:
+			summary.Removed++
+		}
+	}
+	return summary
+}
+
+func (w *Watcher) stopAuthReloadTimer() {
+	w.authReloadMu.Lock()
+	if w.authReloadTimer != nil {
+		w.authReloadTimer.Stop()
+		w.authReloadTimer = nil
+	}
+	w.authReloadPending = false
+	w.authReloadMu.Unlock()
+}
+
+// scheduleAuthReload reloads auth state now when no reload ran within the debounce
+// window, and otherwise marks a trailing reload that runs when the window closes.
+func (w *Watcher) scheduleAuthReload() {
+	w.authReloadMu.Lock()
+	if w.authReloadTimer != nil {
+		w.authReloadPending = true
+		w.authReloadMu.Unlock()
+		return
+	}
+	w.authReloadTimer = time.AfterFunc(authReloadDebounce, w.finishAuthReloadWindow)
+	w.authReloadMu.Unlock()
+	w.reloadAuths()
+}
+
+func (w *Watcher) finishAuthReloadWindow() {
+	w.authReloadMu.Lock()
+	if !w.authReloadPending {
+		w.authReloadTimer = nil
+		w.authReloadMu.Unlock()
+		return
+	}
+	w.authReloadPending = false
+	w.authReloadTimer = time.AfterFunc(authReloadDebounce, w.finishAuthReloadWindow)
+	w.authReloadMu.Unlock()
+	w.reloadAuths()
+}
+
+// reloadAuths pushes the current auth state to the core manager, triggers the
+// server update callback and logs one summary line for the applied changes.
+func (w *Watcher) reloadAuths() {
+	summary := w.refreshAuthState(false)
+
+	w.clientsMutex.RLock()
+	cfg := w.config
+	w.clientsMutex.RUnlock()
+
+	if w.reloadCallback != nil {
+		log.Debugf("triggering server update callback after auth change")
+		w.reloadCallback(cfg)
+	}
+	log.Infof("auth reload: %d added, %d updated, %d removed", summary.Added, summary.Updated, summary.Removed)
+}
@@ -1,10 +1,12 @@
 // config_reload.go implements debounced configuration hot reload.
-// It detects material changes and reloads clients when the config changes.
+// It detects material changes and reloads clients when the config changes. A config
+// that fails validation is rejected and the active one is kept.
 package watcher
 
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"os"
 	"reflect"
 	"time"
@@ -63,30 +65,66 @@ func (w *Watcher) reloadConfigIfChanged() {
 	}
 	log.Infof("config file changed, reloading: %s", w.configPath)
 	if w.reloadConfig() {
-		finalHash := newHash
-		if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
-			sumUpdated := sha256.Sum256(updatedData)
-			finalHash = hex.EncodeToString(sumUpdated[:])
-		} else if errRead != nil {
-			log.WithError(errRead).Debug("failed to compute updated config hash after reload")
-		}
-		w.clientsMutex.Lock()
-		w.lastConfigHash = finalHash
-		w.clientsMutex.Unlock()
-		w.persistConfigAsync()
+		w.rememberConfigHash(newHash)
 	}
 }
 
+// ReloadConfig re-reads the config file regardless of whether it changed, returning
+// the validation error when the file is rejected.
+func (w *Watcher) ReloadConfig() error {
+	data, err := os.ReadFile(w.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err = w.applyConfigFile(); err != nil {
+		log.Errorf("config reload rejected, keeping the active config: %v", err)
+		return err
+	}
+	sum := sha256.Sum256(data)
+	w.rememberConfigHash(hex.EncodeToString(sum[:]))
+	return nil
+}
+
+func (w *Watcher) rememberConfigHash(newHash string) {
+	finalHash := newHash
+	if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
+		sumUpdated := sha256.Sum256(updatedData)
+		finalHash = hex.EncodeToString(sumUpdated[:])
+	} else if errRead != nil {
+		log.WithError(errRead).Debug("failed to compute updated config hash after reload")
+	}
+	w.clientsMutex.Lock()
+	w.lastConfigHash = finalHash
+	w.clientsMutex.Unlock()
+	w.persistConfigAsync()
+}
+
 func (w *Watcher) reloadConfig() bool {
+	if err := w.applyConfigFile(); err != nil {
+		log.Errorf("config reload rejected, keeping the active config: %v", err)
+		return false
+	}
+	return true
+}
+
+// applyConfigFile validates the config file and, only if it passes, swaps it in as
+// the active config and reloads the clients.
+func (w *Watcher) applyConfigFile() error {
 	log.Debug("=========================== CONFIG RELOAD ============================")
 	log.Debugf("starting config reload from: %s", w.configPath)
 
-	newConfig, errLoadConfig := config.LoadConfig(w.configPath)
+	newConfig, errLoadConfig := config.ValidateConfigFile(w.configPath)
 	if errLoadConfig != nil {
-		log.Errorf("failed to reload config: %v", errLoadConfig)
-		return false
+		return errLoadConfig
 	}
+	w.ApplyConfig(newConfig)
+	return nil
+}
 
+// ApplyConfig swaps in newConfig, which must already be validated, as the active
+// config and reloads the clients, as a config file reload does. The config file is
+// left untouched, so its next change replaces newConfig.
+func (w *Watcher) ApplyConfig(newConfig *config.Config) {
 	if w.mirroredAuthDir != "" {
 		newConfig.AuthDir = w.mirroredAuthDir
 	} else {
@@ -117,9 +155,9 @@ func (w *Watcher) reloadConfig() bool {
 	if oldConfig != nil {
 		details := diff.BuildConfigChangeDetails(oldConfig, newConfig)
 		if len(details) > 0 {
-			log.Debugf("config changes detected:")
+			log.Infof("config changes detected:")
 			for _, d := range details {
-				log.Debugf("  %s", d)
+				log.Infof("  %s", d)
 			}
 		} else {
 			log.Debugf("no material config field changes detected")
@@ -131,5 +169,4 @@ func (w *Watcher) reloadConfig() bool {
 
 	log.Infof("config successfully reloaded, triggering client reload")
 	w.reloadClients(authDirChanged, affectedOAuthProviders, forceAuthRefresh)
-	return true
 }
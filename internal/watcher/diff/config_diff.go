@@ -24,6 +24,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.AuthDir != newCfg.AuthDir {
 		changes = append(changes, fmt.Sprintf("auth-dir: %s -> %s", oldCfg.AuthDir, newCfg.AuthDir))
 	}
+	if oldCfg.AuthEncryption != newCfg.AuthEncryption {
+		changes = append(changes, "auth-encryption: updated (restart required)")
+	}
 	if oldCfg.Debug != newCfg.Debug {
 		changes = append(changes, fmt.Sprintf("debug: %t -> %t", oldCfg.Debug, newCfg.Debug))
 	}
@@ -33,6 +36,24 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if strings.TrimSpace(oldCfg.Pprof.Addr) != strings.TrimSpace(newCfg.Pprof.Addr) {
 		changes = append(changes, fmt.Sprintf("pprof.addr: %s -> %s", strings.TrimSpace(oldCfg.Pprof.Addr), strings.TrimSpace(newCfg.Pprof.Addr)))
 	}
+	if oldCfg.AccessLog != newCfg.AccessLog {
+		changes = append(changes, "access-log: updated")
+	}
+	if oldCfg.Capture != newCfg.Capture {
+		changes = append(changes, "capture: updated")
+	}
+	if oldCfg.Metrics.Enable != newCfg.Metrics.Enable {
+		changes = append(changes, fmt.Sprintf("metrics.enable: %t -> %t", oldCfg.Metrics.Enable, newCfg.Metrics.Enable))
+	}
+	if oldCfg.Health.ProbeIntervalSeconds != newCfg.Health.ProbeIntervalSeconds {
+		changes = append(changes, fmt.Sprintf("health.probe-interval-seconds: %d -> %d", oldCfg.Health.ProbeIntervalSeconds, newCfg.Health.ProbeIntervalSeconds))
+	}
+	if oldCfg.Health.ProbeTimeoutSeconds != newCfg.Health.ProbeTimeoutSeconds {
+		changes = append(changes, fmt.Sprintf("health.probe-timeout-seconds: %d -> %d", oldCfg.Health.ProbeTimeoutSeconds, newCfg.Health.ProbeTimeoutSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.Health.Providers, newCfg.Health.Providers) {
+		changes = append(changes, fmt.Sprintf("health.providers: updated (%d -> %d providers)", len(oldCfg.Health.Providers), len(newCfg.Health.Providers)))
+	}
 	if oldCfg.LoggingToFile != newCfg.LoggingToFile {
 		changes = append(changes, fmt.Sprintf("logging-to-file: %t -> %t", oldCfg.LoggingToFile, newCfg.LoggingToFile))
 	}
@@ -57,18 +78,90 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.MaxRetryInterval != newCfg.MaxRetryInterval {
 		changes = append(changes, fmt.Sprintf("max-retry-interval: %d -> %d", oldCfg.MaxRetryInterval, newCfg.MaxRetryInterval))
 	}
+	if !reflect.DeepEqual(oldCfg.RetryPolicies, newCfg.RetryPolicies) {
+		changes = append(changes, fmt.Sprintf("retry-policies: updated (%d -> %d providers)", len(oldCfg.RetryPolicies), len(newCfg.RetryPolicies)))
+	}
 	if oldCfg.ProxyURL != newCfg.ProxyURL {
 		changes = append(changes, fmt.Sprintf("proxy-url: %s -> %s", formatProxyURL(oldCfg.ProxyURL), formatProxyURL(newCfg.ProxyURL)))
 	}
+	if !reflect.DeepEqual(oldCfg.ProviderProxyURLs, newCfg.ProviderProxyURLs) {
+		changes = append(changes, fmt.Sprintf("provider-proxy-urls: updated (%d -> %d providers)", len(oldCfg.ProviderProxyURLs), len(newCfg.ProviderProxyURLs)))
+	}
 	if oldCfg.WebsocketAuth != newCfg.WebsocketAuth {
 		changes = append(changes, fmt.Sprintf("ws-auth: %t -> %t", oldCfg.WebsocketAuth, newCfg.WebsocketAuth))
 	}
+	if !reflect.DeepEqual(oldCfg.WebsocketAllowedOrigins, newCfg.WebsocketAllowedOrigins) {
+		changes = append(changes, fmt.Sprintf("websocket-allowed-origins: %v -> %v", oldCfg.WebsocketAllowedOrigins, newCfg.WebsocketAllowedOrigins))
+	}
+	if oldCfg.ShutdownDrainTimeout != newCfg.ShutdownDrainTimeout {
+		changes = append(changes, fmt.Sprintf("shutdown-drain-timeout: %d -> %d", oldCfg.ShutdownDrainTimeout, newCfg.ShutdownDrainTimeout))
+	}
 	if oldCfg.ForceModelPrefix != newCfg.ForceModelPrefix {
 		changes = append(changes, fmt.Sprintf("force-model-prefix: %t -> %t", oldCfg.ForceModelPrefix, newCfg.ForceModelPrefix))
 	}
 	if oldCfg.NonStreamKeepAliveInterval != newCfg.NonStreamKeepAliveInterval {
 		changes = append(changes, fmt.Sprintf("nonstream-keepalive-interval: %d -> %d", oldCfg.NonStreamKeepAliveInterval, newCfg.NonStreamKeepAliveInterval))
 	}
+	if !reflect.DeepEqual(oldCfg.ModelMappings, newCfg.ModelMappings) {
+		changes = append(changes, fmt.Sprintf("model-mappings: updated (%d -> %d entries)", len(oldCfg.ModelMappings), len(newCfg.ModelMappings)))
+	}
+	if oldCfg.Streaming.IdleTimeoutSeconds != newCfg.Streaming.IdleTimeoutSeconds {
+		changes = append(changes, fmt.Sprintf("streaming.idle-timeout-seconds: %d -> %d", oldCfg.Streaming.IdleTimeoutSeconds, newCfg.Streaming.IdleTimeoutSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.Streaming.Providers, newCfg.Streaming.Providers) {
+		changes = append(changes, fmt.Sprintf("streaming.providers: updated (%d -> %d providers)", len(oldCfg.Streaming.Providers), len(newCfg.Streaming.Providers)))
+	}
+	if oldCfg.StrictModels != newCfg.StrictModels {
+		changes = append(changes, fmt.Sprintf("strict-models: %t -> %t", oldCfg.StrictModels, newCfg.StrictModels))
+	}
+	if oldCfg.DisableProviderOverride != newCfg.DisableProviderOverride {
+		changes = append(changes, fmt.Sprintf("disable-provider-override: %t -> %t", oldCfg.DisableProviderOverride, newCfg.DisableProviderOverride))
+	}
+	if !reflect.DeepEqual(oldCfg.Failover.Chains, newCfg.Failover.Chains) {
+		changes = append(changes, fmt.Sprintf("failover.chains: updated (%d -> %d models)", len(oldCfg.Failover.Chains), len(newCfg.Failover.Chains)))
+	}
+	if oldCfg.Failover.MaxAttempts != newCfg.Failover.MaxAttempts {
+		changes = append(changes, fmt.Sprintf("failover.max-attempts: %d -> %d", oldCfg.Failover.MaxAttempts, newCfg.Failover.MaxAttempts))
+	}
+	if oldCfg.Failover.AttemptTimeout != newCfg.Failover.AttemptTimeout {
+		changes = append(changes, fmt.Sprintf("failover.attempt-timeout: %d -> %d", oldCfg.Failover.AttemptTimeout, newCfg.Failover.AttemptTimeout))
+	}
+	if oldCfg.RequestLimits.MaxBodyMB != newCfg.RequestLimits.MaxBodyMB {
+		changes = append(changes, fmt.Sprintf("request-limits.max-body-mb: %d -> %d", oldCfg.RequestLimits.MaxBodyMB, newCfg.RequestLimits.MaxBodyMB))
+	}
+	if oldCfg.RequestLimits.ContextWindowCheck != newCfg.RequestLimits.ContextWindowCheck || !reflect.DeepEqual(oldCfg.RequestLimits.ContextWindows, newCfg.RequestLimits.ContextWindows) {
+		changes = append(changes, fmt.Sprintf("request-limits.context-window-check: %t -> %t (%d context windows)", oldCfg.RequestLimits.ContextWindowCheck, newCfg.RequestLimits.ContextWindowCheck, len(newCfg.RequestLimits.ContextWindows)))
+	}
+	if !reflect.DeepEqual(oldCfg.RequestLimits.OutputTokens, newCfg.RequestLimits.OutputTokens) {
+		changes = append(changes, fmt.Sprintf("request-limits.output-tokens: %d -> %d models", len(oldCfg.RequestLimits.OutputTokens), len(newCfg.RequestLimits.OutputTokens)))
+	}
+	if oldCfg.ContextManagement.Truncate != newCfg.ContextManagement.Truncate {
+		changes = append(changes, fmt.Sprintf("context-management.truncate: %q -> %q", oldCfg.ContextManagement.Truncate, newCfg.ContextManagement.Truncate))
+	}
+	if oldCfg.ContextManagement.OutputReserve != newCfg.ContextManagement.OutputReserve {
+		changes = append(changes, fmt.Sprintf("context-management.output-reserve: %d -> %d", oldCfg.ContextManagement.OutputReserve, newCfg.ContextManagement.OutputReserve))
+	}
+	if oldCfg.ResponseCache.Enable != newCfg.ResponseCache.Enable {
+		changes = append(changes, fmt.Sprintf("response-cache.enable: %t -> %t", oldCfg.ResponseCache.Enable, newCfg.ResponseCache.Enable))
+	}
+	if oldCfg.ResponseCache.TTL != newCfg.ResponseCache.TTL {
+		changes = append(changes, fmt.Sprintf("response-cache.ttl: %d -> %d", oldCfg.ResponseCache.TTL, newCfg.ResponseCache.TTL))
+	}
+	if oldCfg.ResponseCache.MaxEntries != newCfg.ResponseCache.MaxEntries {
+		changes = append(changes, fmt.Sprintf("response-cache.max-entries: %d -> %d", oldCfg.ResponseCache.MaxEntries, newCfg.ResponseCache.MaxEntries))
+	}
+	if oldCfg.ResponseCache.MaxSizeMB != newCfg.ResponseCache.MaxSizeMB {
+		changes = append(changes, fmt.Sprintf("response-cache.max-size-mb: %d -> %d", oldCfg.ResponseCache.MaxSizeMB, newCfg.ResponseCache.MaxSizeMB))
+	}
+	if oldCfg.ResponseCache.CacheNondeterministic != newCfg.ResponseCache.CacheNondeterministic {
+		changes = append(changes, fmt.Sprintf("response-cache.cache-nondeterministic: %t -> %t", oldCfg.ResponseCache.CacheNondeterministic, newCfg.ResponseCache.CacheNondeterministic))
+	}
+	if !reflect.DeepEqual(oldCfg.Reasoning.EffortBudgets, newCfg.Reasoning.EffortBudgets) {
+		changes = append(changes, "reasoning.effort-budgets: updated")
+	}
+	if oldCfg.Reasoning.StripContent != newCfg.Reasoning.StripContent {
+		changes = append(changes, fmt.Sprintf("reasoning.strip-content: %t -> %t", oldCfg.Reasoning.StripContent, newCfg.Reasoning.StripContent))
+	}
 
 	// Quota-exceeded behavior
 	if oldCfg.QuotaExceeded.SwitchProject != newCfg.QuotaExceeded.SwitchProject {
@@ -77,10 +170,47 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.QuotaExceeded.SwitchPreviewModel != newCfg.QuotaExceeded.SwitchPreviewModel {
 		changes = append(changes, fmt.Sprintf("quota-exceeded.switch-preview-model: %t -> %t", oldCfg.QuotaExceeded.SwitchPreviewModel, newCfg.QuotaExceeded.SwitchPreviewModel))
 	}
+	if oldCfg.QuotaExceeded.CooldownSeconds != newCfg.QuotaExceeded.CooldownSeconds {
+		changes = append(changes, fmt.Sprintf("quota-exceeded.cooldown-seconds: %d -> %d", oldCfg.QuotaExceeded.CooldownSeconds, newCfg.QuotaExceeded.CooldownSeconds))
+	}
+	if oldCfg.QuotaExceeded.MaxCooldownSeconds != newCfg.QuotaExceeded.MaxCooldownSeconds {
+		changes = append(changes, fmt.Sprintf("quota-exceeded.max-cooldown-seconds: %d -> %d", oldCfg.QuotaExceeded.MaxCooldownSeconds, newCfg.QuotaExceeded.MaxCooldownSeconds))
+	}
 
 	if oldCfg.Routing.Strategy != newCfg.Routing.Strategy {
 		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", oldCfg.Routing.Strategy, newCfg.Routing.Strategy))
 	}
+	if !reflect.DeepEqual(oldCfg.Routing.ProviderStrategies, newCfg.Routing.ProviderStrategies) {
+		changes = append(changes, fmt.Sprintf("routing.provider-strategies: updated (%d -> %d providers)", len(oldCfg.Routing.ProviderStrategies), len(newCfg.Routing.ProviderStrategies)))
+	}
+	if oldCfg.Routing.SessionAffinity.Enabled != newCfg.Routing.SessionAffinity.Enabled {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity.enabled: %t -> %t", oldCfg.Routing.SessionAffinity.Enabled, newCfg.Routing.SessionAffinity.Enabled))
+	}
+	if oldCfg.Routing.SessionAffinity.TTLSeconds != newCfg.Routing.SessionAffinity.TTLSeconds {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity.ttl-seconds: %d -> %d", oldCfg.Routing.SessionAffinity.TTLSeconds, newCfg.Routing.SessionAffinity.TTLSeconds))
+	}
+	if oldCfg.Routing.SessionAffinity.MaxEntries != newCfg.Routing.SessionAffinity.MaxEntries {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity.max-entries: %d -> %d", oldCfg.Routing.SessionAffinity.MaxEntries, newCfg.Routing.SessionAffinity.MaxEntries))
+	}
+
+	if !reflect.DeepEqual(oldCfg.CacheDistribution, newCfg.CacheDistribution) {
+		changes = append(changes, fmt.Sprintf("cache-distribution: updated (%d -> %d providers)", len(oldCfg.CacheDistribution), len(newCfg.CacheDistribution)))
+	}
+	if oldCfg.Usage.Persistence != newCfg.Usage.Persistence {
+		changes = append(changes, fmt.Sprintf("usage.persistence: %s -> %s", oldCfg.Usage.Persistence, newCfg.Usage.Persistence))
+	}
+	if oldCfg.Usage.SQLitePath != newCfg.Usage.SQLitePath {
+		changes = append(changes, fmt.Sprintf("usage.sqlite-path: %s -> %s", oldCfg.Usage.SQLitePath, newCfg.Usage.SQLitePath))
+	}
+	if oldCfg.Usage.RetentionDays != newCfg.Usage.RetentionDays {
+		changes = append(changes, fmt.Sprintf("usage.retention-days: %d -> %d", oldCfg.Usage.RetentionDays, newCfg.Usage.RetentionDays))
+	}
+	if !reflect.DeepEqual(oldCfg.Pricing, newCfg.Pricing) {
+		changes = append(changes, fmt.Sprintf("pricing: updated (%d -> %d models)", len(oldCfg.Pricing.Models), len(newCfg.Pricing.Models)))
+	}
+	if !reflect.DeepEqual(oldCfg.RateLimit, newCfg.RateLimit) {
+		changes = append(changes, fmt.Sprintf("rate-limit: updated (%d -> %d keys, redacted)", len(oldCfg.RateLimit.Keys), len(newCfg.RateLimit.Keys)))
+	}
 
 	// API keys (redacted) and counts
 	if len(oldCfg.APIKeys) != len(newCfg.APIKeys) {
@@ -88,6 +218,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if !reflect.DeepEqual(oldCfg.ClientKeys, newCfg.ClientKeys) {
+		changes = append(changes, fmt.Sprintf("client-keys: updated (%d -> %d keys, redacted)", len(oldCfg.ClientKeys), len(newCfg.ClientKeys)))
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -524,6 +524,36 @@ func TestBuildConfigChangeDetails_CountBranches(t *testing.T) {
 	expectContains(t, changes, "vertex-api-key count: 0 -> 1")
 }
 
+func TestBuildConfigChangeDetails_ResponseCacheFields(t *testing.T) {
+	oldCfg := &config.Config{SDKConfig: sdkconfig.SDKConfig{ResponseCache: sdkconfig.ResponseCacheConfig{Enable: true, TTL: 300}}}
+	newCfg := &config.Config{SDKConfig: sdkconfig.SDKConfig{ResponseCache: sdkconfig.ResponseCacheConfig{Enable: true, TTL: 60}}}
+
+	changes := BuildConfigChangeDetails(oldCfg, newCfg)
+	if len(changes) != 1 || changes[0] != "response-cache.ttl: 300 -> 60" {
+		t.Fatalf("changes = %v, want only the TTL change", changes)
+	}
+}
+
+func TestBuildConfigChangeDetails_ContextManagementFields(t *testing.T) {
+	oldCfg := &config.Config{SDKConfig: sdkconfig.SDKConfig{ContextManagement: sdkconfig.ContextManagementConfig{Truncate: "oldest-first", OutputReserve: 4096}}}
+	newCfg := &config.Config{SDKConfig: sdkconfig.SDKConfig{ContextManagement: sdkconfig.ContextManagementConfig{Truncate: "oldest-first", OutputReserve: 8192}}}
+
+	changes := BuildConfigChangeDetails(oldCfg, newCfg)
+	if len(changes) != 1 || changes[0] != "context-management.output-reserve: 4096 -> 8192" {
+		t.Fatalf("changes = %v, want only the output reserve change", changes)
+	}
+}
+
+func TestBuildConfigChangeDetails_HealthFields(t *testing.T) {
+	oldCfg := &config.Config{Health: config.HealthConfig{ProbeIntervalSeconds: 60, Providers: map[string]config.ProviderHealthConfig{"claude": {Required: true}}}}
+	newCfg := &config.Config{Health: config.HealthConfig{ProbeIntervalSeconds: 30, Providers: map[string]config.ProviderHealthConfig{"claude": {Required: true, Probe: true}}}}
+
+	changes := BuildConfigChangeDetails(oldCfg, newCfg)
+	if len(changes) != 2 || changes[0] != "health.probe-interval-seconds: 60 -> 30" || changes[1] != "health.providers: updated (1 -> 1 providers)" {
+		t.Fatalf("changes = %v, want the interval and providers changes", changes)
+	}
+}
+
 func TestTrimStrings(t *testing.T) {
 	out := trimStrings([]string{" a ", "b", "  c"})
 	if len(out) != 3 || out[0] != "a" || out[1] != "b" || out[2] != "c" {
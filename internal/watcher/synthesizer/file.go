@@ -2,6 +2,7 @@ package synthesizer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,8 +10,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/geminicli"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // FileSynthesizer generates Auth entries from OAuth JSON files.
@@ -47,8 +50,14 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 			continue
 		}
 		full := filepath.Join(ctx.AuthDir, name)
-		data, errRead := os.ReadFile(full)
-		if errRead != nil || len(data) == 0 {
+		data, errRead := authcrypt.ReadFile(full)
+		if errRead != nil {
+			if errors.Is(errRead, authcrypt.ErrWrongKey) || errors.Is(errRead, authcrypt.ErrKeyRequired) {
+				log.Warnf("skipping auth file: %v", errRead)
+			}
+			continue
+		}
+		if len(data) == 0 {
 			continue
 		}
 		var metadata map[string]any
@@ -124,6 +133,20 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 				}
 			}
 		}
+		// Read weight from auth file
+		if rawWeight, ok := metadata["weight"]; ok {
+			switch v := rawWeight.(type) {
+			case float64:
+				if v > 0 {
+					a.Attributes["weight"] = strconv.Itoa(int(v))
+				}
+			case string:
+				weight := strings.TrimSpace(v)
+				if parsed, errAtoi := strconv.Atoi(weight); errAtoi == nil && parsed > 0 {
+					a.Attributes["weight"] = weight
+				}
+			}
+		}
 		ApplyAuthExcludedModelsMeta(a, cfg, perAccountExcluded, "oauth")
 		if provider == "gemini-cli" {
 			if virtuals := SynthesizeGeminiVirtualAuths(a, metadata, now); len(virtuals) > 0 {
@@ -187,6 +210,9 @@ func SynthesizeGeminiVirtualAuths(primary *coreauth.Auth, metadata map[string]an
 		if priorityVal, hasPriority := primary.Attributes["priority"]; hasPriority && priorityVal != "" {
 			attrs["priority"] = priorityVal
 		}
+		if weightVal, hasWeight := primary.Attributes["weight"]; hasWeight && weightVal != "" {
+			attrs["weight"] = weightVal
+		}
 		metadataCopy := map[string]any{
 			"email":             email,
 			"project_id":        projectID,
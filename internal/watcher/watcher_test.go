@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/synthesizer"
@@ -271,7 +272,7 @@ func TestStartAndStopSuccess(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir), 0o644); err != nil {
 		t.Fatalf("failed to create config file: %v", err)
 	}
 
@@ -441,6 +442,67 @@ func TestRemoveClientRemovesHash(t *testing.T) {
 	}
 }
 
+func TestAddOrUpdateClientCoalescesBurst(t *testing.T) {
+	tmpDir := t.TempDir()
+	authFile := filepath.Join(tmpDir, "sample.json")
+	var reloads int32
+	w := &Watcher{
+		authDir:        tmpDir,
+		lastAuthHashes: make(map[string]string),
+		reloadCallback: func(*config.Config) {
+			atomic.AddInt32(&reloads, 1)
+		},
+	}
+	w.SetConfig(&config.Config{AuthDir: tmpDir})
+	defer w.stopAuthReloadTimer()
+
+	for i := 0; i < 5; i++ {
+		content := fmt.Sprintf(`{"type":"demo","api_key":"k%d"}`, i)
+		if err := os.WriteFile(authFile, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write auth file: %v", err)
+		}
+		w.addOrUpdateClient(authFile)
+	}
+	if got := atomic.LoadInt32(&reloads); got != 1 {
+		t.Fatalf("expected one immediate reload for the burst, got %d", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&reloads) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a trailing reload after the debounce window")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(2 * authReloadDebounce)
+	if got := atomic.LoadInt32(&reloads); got != 2 {
+		t.Fatalf("expected 2 reloads for the burst, got %d", got)
+	}
+}
+
+func TestRefreshAuthStateSummarizesChanges(t *testing.T) {
+	queue := make(chan AuthUpdate, 8)
+	w := &Watcher{
+		currentAuths: map[string]*coreauth.Auth{
+			"keep":   {ID: "keep", Provider: "demo"},
+			"change": {ID: "change", Provider: "demo", Label: "old"},
+			"gone":   {ID: "gone", Provider: "demo"},
+		},
+		runtimeAuths: map[string]*coreauth.Auth{
+			"keep":   {ID: "keep", Provider: "demo"},
+			"change": {ID: "change", Provider: "demo", Label: "new"},
+			"fresh":  {ID: "fresh", Provider: "demo"},
+		},
+		authQueue: queue,
+	}
+
+	got := w.refreshAuthState(false)
+	want := authReloadSummary{Added: 1, Updated: 1, Removed: 1}
+	if got != want {
+		t.Fatalf("refreshAuthState() = %+v, want %+v", got, want)
+	}
+}
+
 func TestShouldDebounceRemove(t *testing.T) {
 	w := &Watcher{}
 	path := filepath.Clean("test.json")
@@ -491,6 +553,31 @@ func TestAuthFileUnchangedUsesHash(t *testing.T) {
 	}
 }
 
+func TestAuthFileUnchangedComparesDecryptedContent(t *testing.T) {
+	if err := authcrypt.SetKey("watcher-key"); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	t.Cleanup(func() { _ = authcrypt.SetKey("") })
+
+	authFile := filepath.Join(t.TempDir(), "sealed.json")
+	content := []byte(`{"type":"demo"}`)
+	if err := authcrypt.WriteFile(authFile, content, 0o600); err != nil {
+		t.Fatalf("failed to write encrypted auth file: %v", err)
+	}
+
+	w := &Watcher{lastAuthHashes: make(map[string]string)}
+	sum := sha256.Sum256(content)
+	w.lastAuthHashes[w.normalizeAuthPath(authFile)] = hexString(sum[:])
+
+	unchanged, err := w.authFileUnchanged(authFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !unchanged {
+		t.Fatal("expected encrypted file with the same plaintext to report unchanged")
+	}
+}
+
 func TestAuthFileUnchangedEmptyAndMissing(t *testing.T) {
 	tmpDir := t.TempDir()
 	emptyFile := filepath.Join(tmpDir, "empty.json")
@@ -781,7 +868,7 @@ func TestHandleEventIgnoresUnrelatedFiles(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 
@@ -807,7 +894,7 @@ func TestHandleEventConfigChangeSchedulesReload(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 
@@ -835,7 +922,7 @@ func TestHandleEventAuthWriteTriggersUpdate(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "a.json")
@@ -865,7 +952,7 @@ func TestHandleEventRemoveDebounceSkips(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "remove.json")
@@ -895,7 +982,7 @@ func TestHandleEventAtomicReplaceUnchangedSkips(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "same.json")
@@ -928,7 +1015,7 @@ func TestHandleEventAtomicReplaceChangedTriggersUpdate(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "change.json")
@@ -962,7 +1049,7 @@ func TestHandleEventRemoveUnknownFileIgnored(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "unknown.json")
@@ -989,7 +1076,7 @@ func TestHandleEventRemoveKnownFileDeletes(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "known.json")
@@ -1150,7 +1237,7 @@ func TestReloadConfigUsesMirroredAuthDir(t *testing.T) {
 	}
 
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+filepath.Join(tmpDir, "other")+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+filepath.Join(tmpDir, "other")+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config: %v", err)
 	}
 
@@ -1242,7 +1329,7 @@ func TestReloadConfigFiltersAffectedOAuthProviders(t *testing.T) {
 func TestStartFailsWhenAuthDirMissing(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+filepath.Join(tmpDir, "missing-auth")+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+filepath.Join(tmpDir, "missing-auth")+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authDir := filepath.Join(tmpDir, "missing-auth")
@@ -1351,7 +1438,7 @@ func TestScheduleConfigReloadDebounces(t *testing.T) {
 	tmp := t.TempDir()
 	authDir := tmp
 	cfgPath := tmp + "/config.yaml"
-	if err := os.WriteFile(cfgPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(cfgPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config: %v", err)
 	}
 
@@ -1488,3 +1575,45 @@ func TestScheduleProcessEventsStopsOnContextDone(t *testing.T) {
 func hexString(data []byte) string {
 	return strings.ToLower(fmt.Sprintf("%x", data))
 }
+
+func TestReloadConfigRejectsInvalidConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 9000\nportt: 9001\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	reloads := 0
+	active := &config.Config{Port: 8317, AuthDir: tmpDir}
+	w := &Watcher{
+		configPath:      configPath,
+		authDir:         tmpDir,
+		mirroredAuthDir: tmpDir,
+		lastAuthHashes:  make(map[string]string),
+		reloadCallback:  func(*config.Config) { reloads++ },
+	}
+	w.SetConfig(active)
+
+	err := w.ReloadConfig()
+	if err == nil || !strings.Contains(err.Error(), "field portt not found") {
+		t.Fatalf("ReloadConfig() error = %v, want the unknown field", err)
+	}
+	w.clientsMutex.RLock()
+	kept := w.config
+	w.clientsMutex.RUnlock()
+	if kept != active || reloads != 0 {
+		t.Fatalf("invalid config was applied: config %+v, %d reloads", kept, reloads)
+	}
+
+	if err = os.WriteFile(configPath, []byte("port: 9000\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err = w.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	w.clientsMutex.RLock()
+	defer w.clientsMutex.RUnlock()
+	if w.config.Port != 9000 {
+		t.Fatalf("port = %d, want 9000 after a valid reload", w.config.Port)
+	}
+}
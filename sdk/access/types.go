@@ -30,6 +30,10 @@ const (
 
 	// DefaultAccessProviderName is applied when no provider name is supplied.
 	DefaultAccessProviderName = "config-inline"
+
+	// ClientKeyMetadataKey is the Result metadata entry holding the name of the
+	// client-keys entry that authenticated the request.
+	ClientKeyMetadataKey = "client-key"
 )
 
 // MakeInlineAPIKeyProvider constructs an inline API key provider configuration.
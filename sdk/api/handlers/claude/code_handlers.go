@@ -53,7 +53,7 @@ func (h *ClaudeCodeAPIHandler) HandlerType() string {
 func (h *ClaudeCodeAPIHandler) Models() []map[string]any {
 	// Get dynamic models from the global registry
 	modelRegistry := registry.GetGlobalRegistry()
-	return modelRegistry.GetAvailableModels("claude")
+	return h.ApplyModelMappings(modelRegistry.GetAvailableModels("claude"), "id")
 }
 
 // ClaudeMessages handles Claude-compatible streaming chat completions.
@@ -286,6 +286,10 @@ func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.
 			}
 			_, _ = c.Writer.Write(chunk)
 		},
+		// Anthropic SDKs expect heartbeats as ping events rather than SSE comments.
+		WriteKeepAlive: func() {
+			_, _ = c.Writer.Write([]byte("event: ping\ndata: {\"type\": \"ping\"}\n\n"))
+		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
 				return
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// clientKeyFor returns the client-keys entry that authenticated the request. ok is
+// false for requests authenticated otherwise, which are unrestricted.
+func (h *BaseAPIHandler) clientKeyFor(ctx context.Context) (key config.ClientKey, ok bool) {
+	ginCtx := ginContextOf(ctx)
+	if ginCtx == nil || h.Cfg == nil {
+		return config.ClientKey{}, false
+	}
+	name := ginCtx.GetString("apiKeyName")
+	if name == "" {
+		return config.ClientKey{}, false
+	}
+	for _, key = range h.Cfg.ClientKeys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return config.ClientKey{}, false
+}
+
+// clientKeyAllowsModel reports whether key may request model, ignoring any thinking
+// suffix. Model globs match case-insensitively.
+func clientKeyAllowsModel(key config.ClientKey, model string) bool {
+	if len(key.AllowedModels) == 0 {
+		return true
+	}
+	base := strings.ToLower(strings.TrimSpace(thinking.ParseSuffix(model).ModelName))
+	for _, pattern := range key.AllowedModels {
+		if util.MatchGlob(strings.ToLower(pattern), base) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientKeyProviders returns the providers key may use, in order.
+func clientKeyProviders(key config.ClientKey, providers []string) []string {
+	if len(key.AllowedProviders) == 0 {
+		return providers
+	}
+	allowed := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if slices.Contains(key.AllowedProviders, strings.ToLower(provider)) {
+			allowed = append(allowed, provider)
+		}
+	}
+	return allowed
+}
+
+// checkClientKeyModel returns a 403 when the request's client key may not use model.
+func (h *BaseAPIHandler) checkClientKeyModel(ctx context.Context, model string) *interfaces.ErrorMessage {
+	key, ok := h.clientKeyFor(ctx)
+	if !ok || clientKeyAllowsModel(key, model) {
+		return nil
+	}
+	return clientKeyDenied(ctx, fmt.Sprintf("API key %q is not allowed to use model %s", key.Name, model))
+}
+
+// restrictClientKeyProviders narrows the routed providers to those the request's
+// client key may use, returning a 403 when none is left.
+func (h *BaseAPIHandler) restrictClientKeyProviders(ctx context.Context, model string, providers []string) ([]string, *interfaces.ErrorMessage) {
+	key, ok := h.clientKeyFor(ctx)
+	if !ok {
+		return providers, nil
+	}
+	allowed := clientKeyProviders(key, providers)
+	if len(allowed) == 0 {
+		return nil, clientKeyDenied(ctx, fmt.Sprintf("API key %q is not allowed to use provider %s for model %s; allowed providers: %s",
+			key.Name, strings.Join(providers, ","), model, strings.Join(key.AllowedProviders, ", ")))
+	}
+	return allowed, nil
+}
+
+// clientKeyDenied returns a 403 whose body follows the dialect of the called endpoint.
+func clientKeyDenied(ctx context.Context, message string) *interfaces.ErrorMessage {
+	path := ""
+	if ginCtx := ginContextOf(ctx); ginCtx != nil && ginCtx.Request != nil {
+		path = ginCtx.Request.URL.Path
+	}
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusForbidden,
+		Error:      errors.New(string(BuildDialectErrorBody(path, http.StatusForbidden, message))),
+	}
+}
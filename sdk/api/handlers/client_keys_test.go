@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func clientKeyRequestContext(path, keyName string) context.Context {
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, path, nil)
+	if keyName != "" {
+		ginCtx.Set("apiKeyName", keyName)
+	}
+	return context.WithValue(context.Background(), "gin", ginCtx)
+}
+
+func TestRouteRequest_ClientKeyAllowlists(t *testing.T) {
+	handler := newFailoverHandler(t, "claude-sonnet-4-5", 0,
+		&failoverExecutor{provider: "keys-a"}, &failoverExecutor{provider: "keys-b"})
+	handler.Cfg.ClientKeys = []sdkconfig.ClientKey{
+		{Name: "models-only", AllowedModels: []string{"Claude-Sonnet-*"}},
+		{Name: "provider-b", AllowedProviders: []string{"keys-b"}},
+		{Name: "provider-c", AllowedProviders: []string{"keys-c"}},
+	}
+
+	providers, _, _, errMsg := handler.routeRequest(clientKeyRequestContext("/v1/messages", "models-only"), "claude-sonnet-4-5(high)")
+	if errMsg != nil || len(providers) != 2 {
+		t.Fatalf("allowed model = %v, %+v", providers, errMsg)
+	}
+	providers, _, _, errMsg = handler.routeRequest(clientKeyRequestContext("/v1/messages", "provider-b"), "claude-sonnet-4-5")
+	if errMsg != nil || len(providers) != 1 || providers[0] != "keys-b" {
+		t.Fatalf("provider allowlist = %v, %+v; want [keys-b]", providers, errMsg)
+	}
+	providers, _, _, errMsg = handler.routeRequest(clientKeyRequestContext("/v1/messages", ""), "claude-sonnet-4-5")
+	if errMsg != nil || len(providers) != 2 {
+		t.Fatalf("unrestricted key = %v, %+v", providers, errMsg)
+	}
+
+	for _, tc := range []struct {
+		path, key, model, wantPath, wantValue string
+	}{
+		{"/v1/messages", "models-only", "gpt-4o", "error.type", "permission_error"},
+		{"/v1beta/models/claude-sonnet-4-5:generateContent", "provider-c", "claude-sonnet-4-5", "error.status", "PERMISSION_DENIED"},
+		{"/v1/chat/completions", "provider-c", "claude-sonnet-4-5", "error.type", "permission_error"},
+	} {
+		_, _, _, errMsg = handler.routeRequest(clientKeyRequestContext(tc.path, tc.key), tc.model)
+		if errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+			t.Fatalf("routeRequest(%s, %s, %s) error = %+v, want 403", tc.path, tc.key, tc.model, errMsg)
+		}
+		body := errMsg.Error.Error()
+		if got := gjson.Get(body, tc.wantPath).String(); got != tc.wantValue {
+			t.Fatalf("%s body %s: %s = %q, want %q", tc.path, body, tc.wantPath, got, tc.wantValue)
+		}
+	}
+}
+
+func TestFailoverPlan_ClientKeyPrunesChain(t *testing.T) {
+	handler := newFailoverHandler(t, "keys-model", 0,
+		&failoverExecutor{provider: "keys-a"}, &failoverExecutor{provider: "keys-b"}, &failoverExecutor{provider: "keys-c"})
+	handler.Cfg.ClientKeys = []sdkconfig.ClientKey{{Name: "no-b", AllowedProviders: []string{"keys-a", "keys-c"}}}
+
+	plan := handler.failoverPlan(clientKeyRequestContext("/v1/messages", "no-b"), "keys-model", []string{"keys-a", "keys-c"})
+	if len(plan) != 2 || plan[0][0] != "keys-a" || plan[1][0] != "keys-c" {
+		t.Fatalf("plan = %v, want [[keys-a] [keys-c]]", plan)
+	}
+}
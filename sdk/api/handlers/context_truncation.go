@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokencount"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// TruncatedMessagesHeader names the response header reporting how many conversation
+// messages were dropped to fit the request into the model's context window.
+const TruncatedMessagesHeader = "X-CLIProxy-Truncated-Messages"
+
+// truncateToContextWindow applies context-management.truncate to a routed request.
+// When the estimated input exceeds the model's context window minus the output reserve,
+// the oldest turns are dropped until it fits; the dropped count is reported in the
+// response header and recorded on the returned context for usage records. Requests that
+// fit, or that cannot be made to fit, are returned unchanged.
+func (h *BaseAPIHandler) truncateToContextWindow(ctx context.Context, handlerType, model string, rawJSON []byte) (context.Context, []byte) {
+	if h.Cfg == nil || h.Cfg.ContextManagement.Truncate != config.TruncateOldestFirst {
+		return ctx, rawJSON
+	}
+	if conversationPath(handlerType) == "" {
+		return ctx, rawJSON
+	}
+	window := h.contextWindow(model)
+	budget := int64(window) - outputReserve(rawJSON, h.Cfg.ContextManagement.OutputReserve)
+	if window <= 0 || budget <= 0 {
+		return ctx, rawJSON
+	}
+	truncated, dropped := truncateOldestFirst(handlerType, model, rawJSON, budget)
+	if dropped == 0 {
+		return ctx, rawJSON
+	}
+	log.Debugf("context management: dropped %d oldest messages to fit %s into %d tokens", dropped, model, budget)
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+			ginCtx.Header(TruncatedMessagesHeader, strconv.Itoa(dropped))
+		}
+	}
+	return coreusage.WithTruncatedMessages(ctx, dropped), truncated
+}
+
+// conversationPath returns the path of the conversation array in a request of format,
+// or "" when truncation does not support the format.
+func conversationPath(format string) string {
+	switch format {
+	case "claude", "openai":
+		return "messages"
+	case "openai-response":
+		return "input"
+	case "gemini":
+		return "contents"
+	case "gemini-cli":
+		return "request.contents"
+	default:
+		return ""
+	}
+}
+
+// outputReserve returns the tokens to keep free for the response: the request's output
+// limit when it sets one, otherwise fallback.
+func outputReserve(rawJSON []byte, fallback int) int64 {
+	for _, path := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens", "request.generationConfig.maxOutputTokens"} {
+		if value := gjson.GetBytes(rawJSON, path); value.Type == gjson.Number && value.Int() > 0 {
+			return value.Int()
+		}
+	}
+	return int64(fallback)
+}
+
+// truncateOldestFirst drops the oldest messages of a Claude, OpenAI chat, OpenAI
+// Responses or Gemini request until its estimated input fits budget tokens, returning
+// the request and the number of messages dropped. Conversations are only cut before a
+// user message, and never where a tool result would be kept without the tool call it
+// answers; OpenAI system and developer messages are always kept. When no cut fits, the
+// request is returned unchanged with 0 dropped.
+func truncateOldestFirst(format, model string, rawJSON []byte, budget int64) ([]byte, int) {
+	path := conversationPath(format)
+	messages := gjson.GetBytes(rawJSON, path)
+	if path == "" || !messages.IsArray() {
+		return rawJSON, 0
+	}
+	items := messages.Array()
+	base, err := sjson.SetRawBytes(rawJSON, path, []byte("[]"))
+	if err != nil {
+		return rawJSON, 0
+	}
+	total, _ := tokencount.EstimatePrompt(model, format, base)
+	tokens := make([]int64, len(items))
+	pinned := make([]bool, len(items))
+	for i, item := range items {
+		single, _ := sjson.SetRawBytes([]byte("{}"), path, []byte("["+item.Raw+"]"))
+		tokens[i], _ = tokencount.EstimatePrompt(model, format, single)
+		total += tokens[i]
+		role := item.Get("role").String()
+		pinned[i] = (format == "openai" || format == "openai-response") && (role == "system" || role == "developer")
+	}
+	if total <= budget {
+		return rawJSON, 0
+	}
+
+	unsafe := toolPairCuts(format, items)
+	for cut := 1; cut < len(items); cut++ {
+		if !pinned[cut-1] {
+			total -= tokens[cut-1]
+		}
+		if unsafe[cut] || !opensTurn(format, items[cut]) || total > budget {
+			continue
+		}
+		kept := []byte("[]")
+		dropped := 0
+		for i, item := range items {
+			if i >= cut || pinned[i] {
+				kept, _ = sjson.SetRawBytes(kept, "-1", []byte(item.Raw))
+				continue
+			}
+			dropped++
+		}
+		if dropped == 0 {
+			return rawJSON, 0
+		}
+		out, err := sjson.SetRawBytes(rawJSON, path, kept)
+		if err != nil {
+			return rawJSON, 0
+		}
+		return out, dropped
+	}
+	return rawJSON, 0
+}
+
+// opensTurn reports whether a conversation may start at item: a user message, other than
+// a Responses input item that is not a message or a Gemini function response.
+func opensTurn(format string, item gjson.Result) bool {
+	if item.Get("role").String() != "user" {
+		return false
+	}
+	switch format {
+	case "openai-response":
+		itemType := item.Get("type").String()
+		return itemType == "" || itemType == "message"
+	case "gemini", "gemini-cli":
+		return len(item.Get("parts.#.functionResponse").Array()) == 0
+	}
+	return true
+}
+
+// toolPairCuts marks the cut positions that would separate a tool result from its tool
+// call: cut i is marked when a call precedes message i and its result does not.
+func toolPairCuts(format string, items []gjson.Result) []bool {
+	calls := make(map[string]int)
+	unsafe := make([]bool, len(items)+1)
+	for i, item := range items {
+		for _, id := range toolIDs(format, item, false) {
+			calls[id] = i
+		}
+		for _, id := range toolIDs(format, item, true) {
+			call, ok := calls[id]
+			if !ok {
+				continue
+			}
+			for cut := call + 1; cut <= i; cut++ {
+				unsafe[cut] = true
+			}
+		}
+	}
+	return unsafe
+}
+
+// toolIDs returns the tool call IDs a message issues, or those it answers when results
+// is set: Claude tool_use and tool_result content blocks, OpenAI assistant tool_calls
+// and tool messages, Responses function_call and function_call_output items, or Gemini
+// functionCall and functionResponse parts, which are paired by name when they carry no
+// id.
+func toolIDs(format string, item gjson.Result, results bool) []string {
+	var ids []string
+	switch format {
+	case "openai-response":
+		itemType := "function_call"
+		if results {
+			itemType = "function_call_output"
+		}
+		if item.Get("type").String() == itemType {
+			ids = append(ids, item.Get("call_id").String())
+		}
+		return ids
+	case "gemini", "gemini-cli":
+		field := "functionCall"
+		if results {
+			field = "functionResponse"
+		}
+		item.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			if call := part.Get(field); call.Exists() {
+				id := call.Get("id").String()
+				if id == "" {
+					id = call.Get("name").String()
+				}
+				ids = append(ids, id)
+			}
+			return true
+		})
+		return ids
+	case "openai":
+		if results {
+			if item.Get("role").String() == "tool" {
+				ids = append(ids, item.Get("tool_call_id").String())
+			}
+			return ids
+		}
+		item.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+			ids = append(ids, call.Get("id").String())
+			return true
+		})
+		return ids
+	}
+	blockType, idField := "tool_use", "id"
+	if results {
+		blockType, idField = "tool_result", "tool_use_id"
+	}
+	item.Get("content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == blockType {
+			ids = append(ids, block.Get(idField).String())
+		}
+		return true
+	})
+	return ids
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+var longText = strings.Repeat("lorem ipsum dolor sit amet ", 200)
+
+func messageRoles(t *testing.T, body []byte) []string {
+	t.Helper()
+	var roles []string
+	for _, message := range gjson.GetBytes(body, "messages").Array() {
+		roles = append(roles, message.Get("role").String())
+	}
+	return roles
+}
+
+func TestTruncateOldestFirst_DropsWholeTurns(t *testing.T) {
+	body := []byte(`{"system":"be brief","messages":[` +
+		`{"role":"user","content":"` + longText + `"},` +
+		`{"role":"assistant","content":"noted"},` +
+		`{"role":"user","content":"next question"},` +
+		`{"role":"assistant","content":"answer"}]}`)
+	out, dropped := truncateOldestFirst("claude", "claude-sonnet-4-5", body, 200)
+	if dropped != 2 {
+		t.Fatalf("dropped = %d, want 2", dropped)
+	}
+	if got := messageRoles(t, out); strings.Join(got, ",") != "user,assistant" {
+		t.Fatalf("kept roles = %v", got)
+	}
+	if gjson.GetBytes(out, "system").String() != "be brief" {
+		t.Fatalf("system prompt changed: %s", out)
+	}
+
+	if _, dropped = truncateOldestFirst("claude", "claude-sonnet-4-5", body, 100000); dropped != 0 {
+		t.Fatalf("fitting request dropped %d messages", dropped)
+	}
+}
+
+func TestTruncateOldestFirst_SingleMessageLargerThanWindow(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":"` + longText + `"}]}`)
+	out, dropped := truncateOldestFirst("claude", "claude-sonnet-4-5", body, 50)
+	if dropped != 0 || string(out) != string(body) {
+		t.Fatalf("single oversized message: dropped %d, body %s", dropped, out)
+	}
+
+	// Dropping older turns cannot help when the latest one alone is too large.
+	body = []byte(`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"` + longText + `"}]}`)
+	if out, dropped = truncateOldestFirst("claude", "claude-sonnet-4-5", body, 50); dropped != 0 || string(out) != string(body) {
+		t.Fatalf("oversized last message: dropped %d, body %s", dropped, out)
+	}
+}
+
+func TestTruncateOldestFirst_KeepsToolPairsTogether(t *testing.T) {
+	toolUse := `{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"read","input":{}}]}`
+	toolResult := `{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"file contents"}]}`
+
+	// The cheapest cut falls between the tool_use and its tool_result, so the pair goes
+	// with the dropped turns and the cut lands on the next user message.
+	body := []byte(`{"messages":[{"role":"user","content":"` + longText + `"},` + toolUse + `,` + toolResult +
+		`,{"role":"assistant","content":"done"},{"role":"user","content":"thanks"}]}`)
+	out, dropped := truncateOldestFirst("claude", "claude-sonnet-4-5", body, 200)
+	if dropped != 4 {
+		t.Fatalf("dropped = %d, want 4", dropped)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content").String(); got != "thanks" {
+		t.Fatalf("first kept message = %q", got)
+	}
+
+	// With the pair at the end of the conversation there is no safe cut that fits.
+	body = []byte(`{"messages":[{"role":"user","content":"` + longText + `"},` + toolUse + `,` + toolResult + `]}`)
+	if out, dropped = truncateOldestFirst("claude", "claude-sonnet-4-5", body, 200); dropped != 0 || string(out) != string(body) {
+		t.Fatalf("orphaning cut accepted: dropped %d, body %s", dropped, out)
+	}
+}
+
+func TestTruncateOldestFirst_OpenAIKeepsSystemAndToolMessages(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"system","content":"be brief"},` +
+		`{"role":"user","content":"` + longText + `"},` +
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"read","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"call_1","content":"file contents"},` +
+		`{"role":"user","content":"thanks"}]}`)
+	out, dropped := truncateOldestFirst("openai", "gpt-4o", body, 200)
+	if dropped != 3 {
+		t.Fatalf("dropped = %d, want 3", dropped)
+	}
+	if got := messageRoles(t, out); strings.Join(got, ",") != "system,user" {
+		t.Fatalf("kept roles = %v", got)
+	}
+}
+
+func TestTruncateToContextWindow_ReportsDroppedMessages(t *testing.T) {
+	handler := &BaseAPIHandler{Cfg: &config.SDKConfig{}}
+	handler.Cfg.RequestLimits.ContextWindows = map[string]int{"trunc-model": 1200}
+	body := []byte(`{"max_tokens":1000,"messages":[{"role":"user","content":"` + longText + `"},{"role":"assistant","content":"ok"},{"role":"user","content":"hi"}]}`)
+
+	ctx, recorder := ginTestContext()
+	if gotCtx, out := handler.truncateToContextWindow(ctx, "claude", "trunc-model", body); string(out) != string(body) || gotCtx != ctx {
+		t.Fatal("truncation ran while disabled")
+	}
+
+	handler.Cfg.ContextManagement = config.ContextManagementConfig{Truncate: config.TruncateOldestFirst, OutputReserve: 4096}
+	gotCtx, out := handler.truncateToContextWindow(ctx, "claude", "trunc-model", body)
+	if got := messageRoles(t, out); len(got) != 1 {
+		t.Fatalf("kept roles = %v", got)
+	}
+	if n := coreusage.TruncatedMessages(gotCtx); n != 2 {
+		t.Fatalf("usage context truncated messages = %d, want 2", n)
+	}
+	if got := recorder.Header().Get(TruncatedMessagesHeader); got != "2" {
+		t.Fatalf("%s = %q, want 2", TruncatedMessagesHeader, got)
+	}
+
+	if _, out = handler.truncateToContextWindow(ctx, "codex", "trunc-model", body); string(out) != string(body) {
+		t.Fatal("unsupported format was truncated")
+	}
+}
+
+func TestTruncateOldestFirst_OpenAIResponsesKeepsFunctionCallPairs(t *testing.T) {
+	body := []byte(`{"instructions":"be brief","input":[{"role":"developer","content":"stay on topic"},` +
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"` + longText + `"}]},` +
+		`{"type":"function_call","call_id":"call_1","name":"read","arguments":"{}"},` +
+		`{"type":"function_call_output","call_id":"call_1","output":"file contents"},` +
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"thanks"}]}]}`)
+	out, dropped := truncateOldestFirst("openai-response", "gpt-4o", body, 200)
+	if dropped != 3 {
+		t.Fatalf("dropped = %d, want 3", dropped)
+	}
+	input := gjson.GetBytes(out, "input").Array()
+	if len(input) != 2 || input[0].Get("role").String() != "developer" || input[1].Get("content.0.text").String() != "thanks" {
+		t.Fatalf("kept input = %s", gjson.GetBytes(out, "input").Raw)
+	}
+	if gjson.GetBytes(out, "instructions").String() != "be brief" {
+		t.Fatalf("instructions changed: %s", out)
+	}
+}
+
+func TestTruncateOldestFirst_GeminiKeepsFunctionResponses(t *testing.T) {
+	contents := `[{"role":"user","parts":[{"text":"` + longText + `"}]},` +
+		`{"role":"model","parts":[{"functionCall":{"name":"read","args":{}}}]},` +
+		`{"role":"user","parts":[{"functionResponse":{"name":"read","response":{"content":"file contents"}}}]},` +
+		`{"role":"model","parts":[{"text":"done"}]},` +
+		`{"role":"user","parts":[{"text":"thanks"}]}]`
+	out, dropped := truncateOldestFirst("gemini", "gemini-2.5-pro", []byte(`{"contents":`+contents+`}`), 200)
+	if dropped != 4 || gjson.GetBytes(out, "contents.0.parts.0.text").String() != "thanks" {
+		t.Fatalf("dropped %d, body %s", dropped, out)
+	}
+
+	cli := []byte(`{"model":"gemini-2.5-pro","request":{"contents":` + contents + `}}`)
+	if out, dropped = truncateOldestFirst("gemini-cli", "gemini-2.5-pro", cli, 200); dropped != 4 || len(gjson.GetBytes(out, "request.contents").Array()) != 1 {
+		t.Fatalf("gemini-cli: dropped %d, body %s", dropped, out)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokencount"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/sjson"
+)
+
+// checkContextWindow rejects a request whose estimated input tokens exceed the context
+// window of the routed model, with the error the provider returns for the request
+// format. Models without a known context window, and formats without a local token
+// estimate, are not checked.
+func (h *BaseAPIHandler) checkContextWindow(handlerType, model string, rawJSON []byte) *interfaces.ErrorMessage {
+	if h.Cfg == nil || !h.Cfg.RequestLimits.ContextWindowCheck {
+		return nil
+	}
+	window := h.contextWindow(model)
+	if window <= 0 {
+		return nil
+	}
+	tokens, ok := tokencount.EstimatePrompt(model, handlerType, rawJSON)
+	if !ok || tokens <= int64(window) {
+		return nil
+	}
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadRequest,
+		Error:      errors.New(contextWindowErrorBody(handlerType, tokens, window)),
+	}
+}
+
+// contextWindow returns the context window of model in tokens, or 0 when it is unknown.
+// request-limits.context-windows wins over the model registry; there, exact names win
+// over globs and the longest matching glob wins among globs.
+func (h *BaseAPIHandler) contextWindow(model string) int {
+	base := strings.ToLower(strings.TrimSpace(thinking.ParseSuffix(model).ModelName))
+	if windows := h.Cfg.RequestLimits.ContextWindows; len(windows) > 0 {
+		if window, ok := windows[base]; ok {
+			return window
+		}
+		var globs []string
+		for pattern := range windows {
+			if strings.ContainsAny(pattern, "*?") {
+				globs = append(globs, pattern)
+			}
+		}
+		sort.Slice(globs, func(i, j int) bool {
+			if len(globs[i]) != len(globs[j]) {
+				return len(globs[i]) > len(globs[j])
+			}
+			return globs[i] < globs[j]
+		})
+		for _, pattern := range globs {
+			if util.MatchGlob(pattern, base) {
+				return windows[pattern]
+			}
+		}
+	}
+	info := registry.GetGlobalRegistry().GetModelInfo(base, "")
+	if info == nil {
+		return 0
+	}
+	if info.InputTokenLimit > 0 {
+		return info.InputTokenLimit
+	}
+	return info.ContextLength
+}
+
+// contextWindowErrorBody returns the error body the provider behind handlerType sends
+// for a prompt longer than its context window.
+func contextWindowErrorBody(handlerType string, tokens int64, window int) string {
+	switch handlerType {
+	case "claude":
+		body := `{"type":"error","error":{"type":"invalid_request_error","message":""}}`
+		body, _ = sjson.Set(body, "error.message", fmt.Sprintf("prompt is too long: %d tokens > %d maximum", tokens, window))
+		return body
+	case "gemini", "gemini-cli":
+		body := `{"error":{"code":400,"message":"","status":"INVALID_ARGUMENT"}}`
+		body, _ = sjson.Set(body, "error.message", fmt.Sprintf("The input token count (%d) exceeds the maximum number of tokens allowed (%d).", tokens, window))
+		return body
+	}
+	body := `{"error":{"message":"","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`
+	body, _ = sjson.Set(body, "error.message", fmt.Sprintf("This model's maximum context length is %d tokens. However, your messages resulted in %d tokens. Please reduce the length of the messages.", window, tokens))
+	return body
+}
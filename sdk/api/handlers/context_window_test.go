@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestExecuteWithAuthManager_ContextWindowCheck(t *testing.T) {
+	executor := &failoverExecutor{provider: "window-a"}
+	handler := newFailoverHandler(t, "window-model", 0, executor)
+	handler.Cfg.RequestLimits.ContextWindowCheck = true
+
+	long := `{"model":"window-model","messages":[{"role":"user","content":"` + strings.Repeat("lorem ipsum dolor sit amet ", 20) + `"}]}`
+	// The registry entry has no context window, so the request is not checked.
+	ctx, _ := ginTestContext()
+	if _, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "window-model", []byte(long), ""); errMsg != nil {
+		t.Fatalf("unknown context window rejected the request: %v", errMsg.Error)
+	}
+
+	handler.Cfg.RequestLimits.ContextWindows = map[string]int{"window-*": 50}
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "window-model", []byte(long), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("oversized Claude request = %+v", errMsg)
+	}
+	body := errMsg.Error.Error()
+	if gjson.Get(body, "error.type").String() != "invalid_request_error" || !strings.Contains(gjson.Get(body, "error.message").String(), "tokens > 50 maximum") {
+		t.Fatalf("Claude error body = %s", body)
+	}
+
+	_, errs := handler.ExecuteStreamWithAuthManager(ctx, "openai", "window-model", []byte(long), "")
+	errMsg = <-errs
+	if errMsg == nil || gjson.Get(errMsg.Error.Error(), "error.code").String() != "context_length_exceeded" {
+		t.Fatalf("oversized OpenAI stream = %+v", errMsg)
+	}
+
+	gemini := `{"contents":[{"role":"user","parts":[{"text":"` + strings.Repeat("lorem ipsum dolor sit amet ", 20) + `"}]}]}`
+	_, errMsg = handler.ExecuteWithAuthManager(ctx, "gemini", "window-model", []byte(gemini), "")
+	if errMsg == nil || gjson.Get(errMsg.Error.Error(), "error.status").String() != "INVALID_ARGUMENT" {
+		t.Fatalf("oversized Gemini request = %+v", errMsg)
+	}
+
+	short := `{"model":"window-model","messages":[{"role":"user","content":"hi"}]}`
+	if _, errMsg = handler.ExecuteWithAuthManager(ctx, "claude", "window-model", []byte(short), ""); errMsg != nil {
+		t.Fatalf("short request rejected: %v", errMsg.Error)
+	}
+	if executor.Calls() != 2 {
+		t.Fatalf("upstream calls = %d, want 2", executor.Calls())
+	}
+}
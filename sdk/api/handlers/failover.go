@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProviderHeader names the response header reporting which provider served a request.
+const ProviderHeader = "X-CLIProxy-Provider"
+
+// CacheUsageHeader names the response header reporting whether the cache token counts
+// in the response usage come from the upstream ("upstream") or are synthesized by the
+// proxy ("simulated").
+const CacheUsageHeader = "X-CLIProxy-Cache-Usage"
+
+// failoverPlan returns the provider groups to try in order for model. Without a
+// configured chain, or when the client forced the provider, the request runs once
+// against the routed providers. A client key's provider allowlist prunes the chain.
+func (h *BaseAPIHandler) failoverPlan(ctx context.Context, model string, providers []string) [][]string {
+	chain := h.failoverChain(model)
+	if key, ok := h.clientKeyFor(ctx); ok && len(chain) > 0 {
+		chain = clientKeyProviders(key, chain)
+	}
+	if len(chain) == 0 || providerOverridden(ctx) {
+		return [][]string{providers}
+	}
+	if limit := h.Cfg.Failover.MaxAttempts; limit > 0 && len(chain) > limit {
+		chain = chain[:limit]
+	}
+	plan := make([][]string, 0, len(chain))
+	for _, provider := range chain {
+		plan = append(plan, []string{provider})
+	}
+	return plan
+}
+
+// executeWithFailover runs execute against each stage of the failover plan for model
+// until one succeeds, the error is not failover-eligible, or the plan is exhausted.
+// Each stage is bounded by the configured attempt timeout.
+func (h *BaseAPIHandler) executeWithFailover(ctx context.Context, model string, providers []string, execute func(context.Context, []string) (coreexecutor.Response, error)) (coreexecutor.Response, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	plan := h.failoverPlan(ctx, model, providers)
+	timeout := h.failoverAttemptTimeout()
+	for stage, group := range plan {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		resp, err := execute(attemptCtx, group)
+		cancel()
+		if err == nil {
+			setServedProvider(ctx, group)
+			return resp, nil
+		}
+		if stage == len(plan)-1 || !failoverEligible(ctx, err) {
+			return resp, err
+		}
+		log.Warnf("failover: %s failed for model %s, trying %s: %v", strings.Join(group, ","), model, strings.Join(plan[stage+1], ","), err)
+		metrics.RecordFailover(group, plan[stage+1])
+	}
+	return coreexecutor.Response{}, nil
+}
+
+// failoverChain returns the chain configured for model. Exact names win over globs,
+// and the longest matching glob wins among globs.
+func (h *BaseAPIHandler) failoverChain(model string) []string {
+	if h.Cfg == nil || len(h.Cfg.Failover.Chains) == 0 {
+		return nil
+	}
+	base := strings.ToLower(strings.TrimSpace(thinking.ParseSuffix(model).ModelName))
+	var globs []string
+	for pattern, chain := range h.Cfg.Failover.Chains {
+		if !strings.ContainsAny(pattern, "*?") {
+			if strings.EqualFold(pattern, base) {
+				return chain
+			}
+			continue
+		}
+		globs = append(globs, pattern)
+	}
+	sort.Slice(globs, func(i, j int) bool {
+		if len(globs[i]) != len(globs[j]) {
+			return len(globs[i]) > len(globs[j])
+		}
+		return globs[i] < globs[j]
+	})
+	for _, pattern := range globs {
+		if util.MatchGlob(strings.ToLower(pattern), base) {
+			return h.Cfg.Failover.Chains[pattern]
+		}
+	}
+	return nil
+}
+
+// failoverAttemptTimeout returns the configured per-attempt timeout, or 0 when unset.
+func (h *BaseAPIHandler) failoverAttemptTimeout() time.Duration {
+	if h.Cfg == nil || h.Cfg.Failover.AttemptTimeout <= 0 {
+		return 0
+	}
+	return time.Duration(h.Cfg.Failover.AttemptTimeout) * time.Second
+}
+
+// failoverEligible reports whether err from one provider should move the request to
+// the next provider: rate limits, server errors, timeouts and failures without an
+// upstream status. Client errors and cancellation by the caller are final.
+func failoverEligible(ctx context.Context, err error) bool {
+	if err == nil || (ctx != nil && ctx.Err() != nil) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch status := statusFromError(err); {
+	case status == 0:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= http.StatusInternalServerError
+	}
+}
+
+// setServedProvider reports the provider that served the request in ProviderHeader,
+// and in CacheUsageHeader whether its cache counts are real. It is a no-op when the
+// routed group spans several providers, since the manager picks among them.
+func setServedProvider(ctx context.Context, providers []string) {
+	if len(providers) != 1 || ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && !ginCtx.Writer.Written() {
+		ginCtx.Header(ProviderHeader, providers[0])
+		cacheUsage := "upstream"
+		if internalusage.SimulatesCacheUsage(providers[0]) {
+			cacheUsage = "simulated"
+		}
+		ginCtx.Header(CacheUsageHeader, cacheUsage)
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// failoverExecutor serves one provider. A non-zero status makes every call fail with
+// it; otherwise calls succeed with the provider name as payload. partial makes streams
+// send a payload before failing.
+type failoverExecutor struct {
+	provider string
+	status   int
+	partial  bool
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *failoverExecutor) Identifier() string { return e.provider }
+
+func (e *failoverExecutor) record() {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+}
+
+func (e *failoverExecutor) err() error {
+	return &coreauth.Error{Code: "upstream", Message: e.provider + " failed", HTTPStatus: e.status}
+}
+
+func (e *failoverExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.record()
+	if e.status != 0 {
+		return coreexecutor.Response{}, e.err()
+	}
+	return coreexecutor.Response{Payload: []byte(e.provider)}, nil
+}
+
+func (e *failoverExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.record()
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	if e.partial {
+		ch <- coreexecutor.StreamChunk{Payload: []byte("partial")}
+	}
+	if e.status != 0 {
+		ch <- coreexecutor.StreamChunk{Err: e.err()}
+	} else {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(e.provider)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *failoverExecutor) Refresh(ctx context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *failoverExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *failoverExecutor) HttpRequest(ctx context.Context, auth *coreauth.Auth, req *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func (e *failoverExecutor) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+// newFailoverHandler registers one auth per executor, all serving model, and returns a
+// handler whose failover chain for model lists the executors in order.
+func newFailoverHandler(t *testing.T, model string, maxAttempts int, executors ...*failoverExecutor) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	chain := make([]string, 0, len(executors))
+	for _, executor := range executors {
+		manager.RegisterExecutor(executor)
+		auth := &coreauth.Auth{ID: "failover-" + executor.provider, Provider: executor.provider, Status: coreauth.StatusActive}
+		if _, err := manager.Register(context.Background(), auth); err != nil {
+			t.Fatalf("manager.Register(%s): %v", auth.ID, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: model}})
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+		chain = append(chain, executor.provider)
+	}
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Failover: sdkconfig.FailoverConfig{
+			Chains:      map[string][]string{model: chain},
+			MaxAttempts: maxAttempts,
+		},
+	}, manager)
+}
+
+func ginTestContext() (context.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	return context.WithValue(context.Background(), "gin", ginCtx), recorder
+}
+
+func TestExecuteWithAuthManager_FailsOverOnServerError(t *testing.T) {
+	primary := &failoverExecutor{provider: "failover-a", status: http.StatusInternalServerError}
+	secondary := &failoverExecutor{provider: "failover-b"}
+	handler := newFailoverHandler(t, "failover-model", 0, primary, secondary)
+
+	ctx, recorder := ginTestContext()
+	resp, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "failover-model", []byte(`{"model":"failover-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg.Error)
+	}
+	if string(resp) != "failover-b" {
+		t.Fatalf("expected payload from failover-b, got %q", string(resp))
+	}
+	if got := recorder.Header().Get(ProviderHeader); got != "failover-b" {
+		t.Fatalf("expected %s failover-b, got %q", ProviderHeader, got)
+	}
+	if got := recorder.Header().Get(CacheUsageHeader); got != "upstream" {
+		t.Fatalf("expected %s upstream, got %q", CacheUsageHeader, got)
+	}
+	if primary.Calls() == 0 || secondary.Calls() != 1 {
+		t.Fatalf("expected both providers to be tried, got %d and %d calls", primary.Calls(), secondary.Calls())
+	}
+}
+
+func TestExecuteWithAuthManager_DoesNotFailOverOnClientError(t *testing.T) {
+	primary := &failoverExecutor{provider: "failover-a", status: http.StatusBadRequest}
+	secondary := &failoverExecutor{provider: "failover-b"}
+	handler := newFailoverHandler(t, "failover-model", 0, primary, secondary)
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "failover-model", []byte(`{"model":"failover-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 from failover-a, got %+v", errMsg)
+	}
+	if secondary.Calls() != 0 {
+		t.Fatalf("expected no failover on 400, got %d calls to failover-b", secondary.Calls())
+	}
+}
+
+func TestExecuteWithAuthManager_RespectsMaxAttempts(t *testing.T) {
+	primary := &failoverExecutor{provider: "failover-a", status: http.StatusTooManyRequests}
+	secondary := &failoverExecutor{provider: "failover-b", status: http.StatusBadGateway}
+	tertiary := &failoverExecutor{provider: "failover-c"}
+	handler := newFailoverHandler(t, "failover-model", 2, primary, secondary, tertiary)
+
+	_, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "failover-model", []byte(`{"model":"failover-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 from the last attempt, got %+v", errMsg)
+	}
+	if tertiary.Calls() != 0 {
+		t.Fatalf("expected max-attempts to stop before failover-c, got %d calls", tertiary.Calls())
+	}
+}
+
+func TestExecuteStreamWithAuthManager_FailsOverBeforeFirstByte(t *testing.T) {
+	primary := &failoverExecutor{provider: "failover-a", status: http.StatusServiceUnavailable}
+	secondary := &failoverExecutor{provider: "failover-b"}
+	handler := newFailoverHandler(t, "failover-model", 0, primary, secondary)
+
+	ctx, recorder := ginTestContext()
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(ctx, "openai", "failover-model", []byte(`{"model":"failover-model"}`), "")
+	var got []byte
+	for chunk := range dataChan {
+		got = append(got, chunk...)
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected error: %+v", msg)
+		}
+	}
+	if string(got) != "failover-b" {
+		t.Fatalf("expected payload from failover-b, got %q", string(got))
+	}
+	if header := recorder.Header().Get(ProviderHeader); header != "failover-b" {
+		t.Fatalf("expected %s failover-b, got %q", ProviderHeader, header)
+	}
+}
+
+func TestExecuteStreamWithAuthManager_DoesNotFailOverAfterFirstByte(t *testing.T) {
+	primary := &failoverExecutor{provider: "failover-a", status: http.StatusBadGateway, partial: true}
+	secondary := &failoverExecutor{provider: "failover-b"}
+	handler := newFailoverHandler(t, "failover-model", 0, primary, secondary)
+
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "failover-model", []byte(`{"model":"failover-model"}`), "")
+	var got []byte
+	for chunk := range dataChan {
+		got = append(got, chunk...)
+	}
+	var gotStatus int
+	for msg := range errChan {
+		if msg != nil {
+			gotStatus = msg.StatusCode
+		}
+	}
+	if string(got) != "partial" || gotStatus != http.StatusBadGateway {
+		t.Fatalf("expected partial payload then 502, got %q and %d", string(got), gotStatus)
+	}
+	if secondary.Calls() != 0 {
+		t.Fatalf("expected no failover after the first byte, got %d calls to failover-b", secondary.Calls())
+	}
+}
+
+func TestFailoverChainPrefersExactThenLongestGlob(t *testing.T) {
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Failover: sdkconfig.FailoverConfig{
+			Chains: map[string][]string{
+				"claude-*":          {"claude"},
+				"claude-sonnet-*":   {"kiro", "claude"},
+				"claude-sonnet-4-5": {"openrouter"},
+			},
+		},
+	}, nil)
+
+	cases := map[string]string{
+		"claude-sonnet-4-5":      "openrouter",
+		"claude-sonnet-4":        "kiro",
+		"claude-sonnet-4(16384)": "kiro",
+		"claude-opus-4":          "claude",
+		"gpt-5":                  "",
+	}
+	for model, want := range cases {
+		chain := handler.failoverChain(model)
+		got := ""
+		if len(chain) > 0 {
+			got = chain[0]
+		}
+		if got != want {
+			t.Fatalf("failoverChain(%q) starts with %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestSetServedProvider_ReportsSimulatedCacheUsage(t *testing.T) {
+	ctx, recorder := ginTestContext()
+	setServedProvider(ctx, []string{"kiro"})
+	if got := recorder.Header().Get(CacheUsageHeader); got != "simulated" {
+		t.Fatalf("expected %s simulated for kiro, got %q", CacheUsageHeader, got)
+	}
+}
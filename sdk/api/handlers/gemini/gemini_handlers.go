@@ -42,7 +42,7 @@ func (h *GeminiAPIHandler) HandlerType() string {
 func (h *GeminiAPIHandler) Models() []map[string]any {
 	// Get dynamic models from the global registry
 	modelRegistry := registry.GetGlobalRegistry()
-	return modelRegistry.GetAvailableModels("gemini")
+	return h.ApplyModelMappings(modelRegistry.GetAvailableModels("gemini"), "name")
 }
 
 // GeminiModels handles the Gemini models listing endpoint.
@@ -160,9 +160,27 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	default:
+		c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("%s not found.", c.Request.URL.Path),
+				Type:    "invalid_request_error",
+			},
+		})
 	}
 }
 
+// GeminiCachedContents answers the cachedContents management endpoints. Explicit
+// context caches are not proxied, so every method reports 501 Not Implemented.
+func (h *GeminiAPIHandler) GeminiCachedContents(c *gin.Context) {
+	c.JSON(http.StatusNotImplemented, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: "cachedContents is not supported by this proxy",
+			Type:    "not_implemented",
+		},
+	})
+}
+
 // handleStreamGenerateContent handles streaming content generation requests for Gemini models.
 // This function establishes a Server-Sent Events connection and streams the generated content
 // back to the client in real-time. It supports both SSE format and direct streaming based
@@ -14,14 +14,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
 
@@ -102,6 +105,63 @@ func BuildErrorResponseBody(status int, errText string) []byte {
 	return payload
 }
 
+// BuildDialectErrorBody builds an error body shaped like the errors of the compatible
+// API served at path: Claude for /v1/messages, Gemini for /v1beta/ and OpenAI, as
+// BuildErrorResponseBody does, otherwise.
+func BuildDialectErrorBody(path string, status int, message string) []byte {
+	var body []byte
+	switch {
+	case strings.HasPrefix(path, "/v1/messages"):
+		body, _ = json.Marshal(map[string]any{
+			"type":  "error",
+			"error": map[string]string{"type": claudeErrorType(status), "message": message},
+		})
+	case strings.HasPrefix(path, "/v1beta/"):
+		body, _ = json.Marshal(map[string]any{
+			"error": map[string]any{"code": status, "message": message, "status": geminiErrorStatus(status)},
+		})
+	default:
+		body = BuildErrorResponseBody(status, message)
+	}
+	return body
+}
+
+func claudeErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= http.StatusInternalServerError:
+		return "api_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+func geminiErrorStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case status == http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case status == http.StatusNotFound:
+		return "NOT_FOUND"
+	case status == http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case status >= http.StatusInternalServerError:
+		return "INTERNAL"
+	default:
+		return "INVALID_ARGUMENT"
+	}
+}
+
 // StreamingKeepAliveInterval returns the SSE keep-alive interval for this server.
 // Returning 0 disables keep-alives (default when unset).
 func StreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
@@ -115,6 +175,49 @@ func StreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// StreamingKeepAliveIntervalFor returns the SSE keep-alive interval for streams served
+// by provider, applying its override from streaming.providers. Returning 0 disables
+// keep-alives.
+func StreamingKeepAliveIntervalFor(cfg *config.SDKConfig, provider string) time.Duration {
+	interval := StreamingKeepAliveInterval(cfg)
+	if override, ok := streamingProviderConfig(cfg, provider); ok {
+		interval = overrideSeconds(interval, override.KeepAliveSeconds)
+	}
+	return interval
+}
+
+// StreamingIdleTimeout returns how long a stream served by provider may go without
+// upstream data before it is aborted. Returning 0 disables the timeout (default when unset).
+func StreamingIdleTimeout(cfg *config.SDKConfig, provider string) time.Duration {
+	var timeout time.Duration
+	if cfg != nil && cfg.Streaming.IdleTimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.Streaming.IdleTimeoutSeconds) * time.Second
+	}
+	if override, ok := streamingProviderConfig(cfg, provider); ok {
+		timeout = overrideSeconds(timeout, override.IdleTimeoutSeconds)
+	}
+	return timeout
+}
+
+func streamingProviderConfig(cfg *config.SDKConfig, provider string) (config.StreamingProviderConfig, bool) {
+	if cfg == nil || provider == "" {
+		return config.StreamingProviderConfig{}, false
+	}
+	override, ok := cfg.Streaming.Providers[provider]
+	return override, ok
+}
+
+// overrideSeconds applies a per-provider override: 0 keeps fallback, < 0 disables.
+func overrideSeconds(fallback time.Duration, seconds int) time.Duration {
+	switch {
+	case seconds < 0:
+		return 0
+	case seconds > 0:
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
 // NonStreamingKeepAliveInterval returns the keep-alive interval for non-streaming responses.
 // Returning 0 disables keep-alives (default when unset).
 func NonStreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
@@ -144,15 +247,33 @@ func requestExecutionMetadata(ctx context.Context) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
 	key := ""
+	sessionID := ""
 	if ctx != nil {
 		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
 			key = strings.TrimSpace(ginCtx.GetHeader("Idempotency-Key"))
+			sessionID = requestSessionID(ginCtx.Request.Header)
 		}
 	}
 	if key == "" {
 		key = uuid.NewString()
 	}
-	return map[string]any{idempotencyKeyMetadataKey: key}
+	meta := map[string]any{idempotencyKeyMetadataKey: key}
+	if sessionID != "" {
+		meta[coreexecutor.SessionIDMetadataKey] = sessionID
+	}
+	return meta
+}
+
+// sessionIDHeaders are client headers that identify a conversation for session affinity.
+var sessionIDHeaders = []string{"Session_id", "Session-Id", "X-Session-Id"}
+
+func requestSessionID(header http.Header) string {
+	for _, name := range sessionIDHeaders {
+		if value := strings.TrimSpace(header.Get(name)); value != "" {
+			return value
+		}
+	}
+	return ""
 }
 
 // BaseAPIHandler contains the handlers for API endpoints.
@@ -164,6 +285,9 @@ type BaseAPIHandler struct {
 
 	// Cfg holds the current application configuration.
 	Cfg *config.SDKConfig
+
+	// responseCache holds responses replayed to identical deterministic requests.
+	responseCache *cache.ResponseCache
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -177,9 +301,11 @@ type BaseAPIHandler struct {
 //   - *BaseAPIHandler: A new API handlers instance
 func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *BaseAPIHandler {
 	h := &BaseAPIHandler{
-		Cfg:         cfg,
-		AuthManager: authManager,
+		Cfg:           cfg,
+		AuthManager:   authManager,
+		responseCache: cache.NewResponseCache(0, 0, 0),
 	}
+	h.applyResponseCacheConfig(cfg)
 	return h
 }
 
@@ -189,7 +315,10 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *B
 // Parameters:
 //   - clients: The new slice of AI service clients
 //   - cfg: The new application configuration
-func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) { h.Cfg = cfg }
+func (h *BaseAPIHandler) UpdateClients(cfg *config.SDKConfig) {
+	h.Cfg = cfg
+	h.applyResponseCacheConfig(cfg)
+}
 
 // GetAlt extracts the 'alt' parameter from the request query string.
 // It checks both 'alt' and '$alt' parameters and returns the appropriate value.
@@ -244,11 +373,12 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 	}
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
+		done := newCtx.Done()
 		go func() {
 			select {
 			case <-requestCtx.Done():
 				cancel()
-			case <-newCtx.Done():
+			case <-done:
 			}
 		}()
 	}
@@ -372,10 +502,25 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg := checkInlineMedia(rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	providers, normalizedModel, echoModel, errMsg := h.routeRequest(ctx, modelName)
 	if errMsg != nil {
+		metrics.SetModel(ctx, modelName)
 		return nil, errMsg
 	}
+	metrics.SetModel(ctx, normalizedModel)
+	ctx, rawJSON = h.truncateToContextWindow(ctx, handlerType, normalizedModel, rawJSON)
+	if errMsg = h.checkContextWindow(handlerType, normalizedModel, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	cacheKey, cacheable := h.responseCacheKey(ctx, handlerType, normalizedModel, alt, providers, false, rawJSON)
+	if cacheable {
+		if entry, hit := h.lookupResponseCache(ctx, cacheKey, normalizedModel, false); hit {
+			return rewriteResponseModel(cloneBytes(entry.Payload), echoModel), nil
+		}
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
 	payload := rawJSON
@@ -393,7 +538,11 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	var served []string
+	resp, err := h.executeWithFailover(ctx, normalizedModel, providers, func(attemptCtx context.Context, group []string) (coreexecutor.Response, error) {
+		served = group
+		return h.AuthManager.Execute(attemptCtx, group, req, opts)
+	})
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -409,16 +558,24 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		}
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
-	return resp.Payload, nil
+	if cacheable && len(resp.Payload) > 0 {
+		h.responseCache.Put(cacheKey, cache.ResponseEntry{Payload: cloneBytes(resp.Payload), Providers: served})
+	}
+	return rewriteResponseModel(resp.Payload, echoModel), nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg := checkInlineMedia(rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	providers, normalizedModel, _, errMsg := h.routeRequest(ctx, modelName)
 	if errMsg != nil {
+		metrics.SetModel(ctx, modelName)
 		return nil, errMsg
 	}
+	metrics.SetModel(ctx, normalizedModel)
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
 	payload := rawJSON
@@ -436,7 +593,9 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	resp, err := h.executeWithFailover(ctx, normalizedModel, providers, func(attemptCtx context.Context, group []string) (coreexecutor.Response, error) {
+		return h.AuthManager.ExecuteCount(attemptCtx, group, req, opts)
+	})
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -458,8 +617,23 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg := checkInlineMedia(rawJSON); errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	providers, normalizedModel, echoModel, errMsg := h.routeRequest(ctx, modelName)
 	if errMsg != nil {
+		metrics.SetModel(ctx, modelName)
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	metrics.SetModel(ctx, normalizedModel)
+	ctx, rawJSON = h.truncateToContextWindow(ctx, handlerType, normalizedModel, rawJSON)
+	if errMsg = h.checkContextWindow(handlerType, normalizedModel, rawJSON); errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
@@ -482,8 +656,59 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cacheKey, cacheable := h.responseCacheKey(ctx, handlerType, normalizedModel, alt, providers, true, rawJSON)
+	if cacheable {
+		if entry, hit := h.lookupResponseCache(ctx, cacheKey, normalizedModel, true); hit {
+			return replayCachedStream(ctx, entry.Chunks, echoModel)
+		}
+	}
+	plan := h.failoverPlan(ctx, normalizedModel, providers)
+	stage := 0
+	cancelAttempt := context.CancelFunc(func() {})
+	// stopAttemptTimer stops the first-chunk timeout and reports whether it had not fired.
+	stopAttemptTimer := func() bool { return true }
+	// openStage opens the stream for the current plan stage under a fresh attempt
+	// context whose timeout is disarmed once the first chunk arrives.
+	openStage := func() (<-chan coreexecutor.StreamChunk, error) {
+		cancelAttempt()
+		var attemptCtx context.Context
+		attemptCtx, cancelAttempt = context.WithCancel(ctx)
+		stopAttemptTimer = func() bool { return true }
+		if timeout := h.failoverAttemptTimeout(); timeout > 0 {
+			stopAttemptTimer = time.AfterFunc(timeout, cancelAttempt).Stop
+		}
+		return h.AuthManager.ExecuteStream(attemptCtx, plan[stage], req, opts)
+	}
+	// startStage opens the current stage, moving on to the next provider while the
+	// open itself fails with a failover-eligible error.
+	startStage := func() (<-chan coreexecutor.StreamChunk, error) {
+		for {
+			chunks, err := openStage()
+			if err == nil || stage == len(plan)-1 || !failoverEligible(ctx, err) {
+				return chunks, err
+			}
+			log.Warnf("failover: %s failed for model %s, trying %s: %v", strings.Join(plan[stage], ","), normalizedModel, strings.Join(plan[stage+1], ","), err)
+			metrics.RecordFailover(plan[stage], plan[stage+1])
+			stage++
+		}
+	}
+	// failoverNext advances to the next provider after a stream failed before any
+	// payload was sent. It returns nil chunks when no further provider may be tried.
+	failoverNext := func(streamErr error) (<-chan coreexecutor.StreamChunk, error) {
+		if stage == len(plan)-1 || !failoverEligible(ctx, streamErr) {
+			return nil, streamErr
+		}
+		log.Warnf("failover: %s failed for model %s, trying %s: %v", strings.Join(plan[stage], ","), normalizedModel, strings.Join(plan[stage+1], ","), streamErr)
+		metrics.RecordFailover(plan[stage], plan[stage+1])
+		stage++
+		return startStage()
+	}
+	chunks, err := startStage()
 	if err != nil {
+		cancelAttempt()
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -506,7 +731,10 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		defer func() { cancelAttempt() }()
 		sentPayload := false
+		// recorded holds the chunks of a cacheable stream, stored once it ends cleanly.
+		var recorded [][]byte
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
 
@@ -565,22 +793,43 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					chunk, ok = <-chunks
 				}
 				if !ok {
-					return
+					if sentPayload || ctx.Err() != nil || stopAttemptTimer() {
+						// A clean end of stream, or the caller went away.
+						if cacheable && sentPayload && ctx.Err() == nil {
+							h.responseCache.Put(cacheKey, cache.ResponseEntry{Chunks: recorded, Providers: plan[stage]})
+						}
+						return
+					}
+					// The attempt timeout cut the stream off before the first chunk.
+					chunk.Err = context.DeadlineExceeded
 				}
 				if chunk.Err != nil {
+					if ctx != nil && ctx.Err() != nil {
+						// The upstream read was cut off because the client went away;
+						// that is not a failure to retry or fail over.
+						return
+					}
 					streamErr := chunk.Err
 					// Safe bootstrap recovery: if the upstream fails before any payload bytes are sent,
 					// retry a few times (to allow auth rotation / transient recovery) and then attempt model fallback.
 					if !sentPayload {
 						if bootstrapRetries < maxBootstrapRetries && bootstrapEligible(streamErr) {
 							bootstrapRetries++
-							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+							retryChunks, retryErr := openStage()
 							if retryErr == nil {
 								chunks = retryChunks
 								continue outer
 							}
 							streamErr = retryErr
 						}
+						nextChunks, errNext := failoverNext(streamErr)
+						if errNext == nil && nextChunks != nil {
+							chunks = nextChunks
+							continue outer
+						}
+						if errNext != nil {
+							streamErr = errNext
+						}
 					}
 
 					status := http.StatusInternalServerError
@@ -599,8 +848,15 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					return
 				}
 				if len(chunk.Payload) > 0 {
+					if !sentPayload {
+						stopAttemptTimer()
+						setServedProvider(ctx, plan[stage])
+					}
 					sentPayload = true
-					if okSendData := sendData(cloneBytes(chunk.Payload)); !okSendData {
+					if cacheable {
+						recorded = append(recorded, cloneBytes(chunk.Payload))
+					}
+					if okSendData := sendData(rewriteResponseModel(cloneBytes(chunk.Payload), echoModel)); !okSendData {
 						return
 					}
 				}
@@ -622,6 +878,16 @@ func statusFromError(err error) int {
 	return 0
 }
 
+// checkInlineMedia refuses requests carrying an inline image or document above
+// util.MaxInlineMediaBytes with 413, rather than forwarding them for an opaque
+// upstream rejection.
+func checkInlineMedia(rawJSON []byte) *interfaces.ErrorMessage {
+	if err := util.CheckInlineMediaSize(rawJSON, util.MaxInlineMediaBytes); err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusRequestEntityTooLarge, Error: err}
+	}
+	return nil
+}
+
 func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, err *interfaces.ErrorMessage) {
 	resolvedModelName := modelName
 	initialSuffix := thinking.ParseSuffix(modelName)
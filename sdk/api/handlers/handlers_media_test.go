@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+func TestExecuteWithAuthManager_RejectsOversizedInlineMedia(t *testing.T) {
+	payload := strings.Repeat("A", util.MaxInlineMediaBytes/3*4+8)
+	rawJSON := []byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + payload + `"}}]}]}`)
+	h := &BaseAPIHandler{}
+
+	_, errMsg := h.ExecuteWithAuthManager(context.Background(), "openai", "claude-sonnet-4-5", rawJSON, "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusRequestEntityTooLarge || !errors.Is(errMsg.Error, util.ErrMediaTooLarge) {
+		t.Fatalf("non-stream error = %+v, want 413 wrapping ErrMediaTooLarge", errMsg)
+	}
+
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(context.Background(), "openai", "claude-sonnet-4-5", rawJSON, "")
+	if dataChan != nil {
+		t.Fatalf("expected no data channel for an oversized request")
+	}
+	if errMsg = <-errChan; errMsg == nil || errMsg.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("stream error = %+v, want 413", errMsg)
+	}
+}
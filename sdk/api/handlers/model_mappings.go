@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// responseModelPaths lists the JSON paths that carry the model name in OpenAI, Claude,
+// Gemini and OpenAI Responses payloads, including their streaming events.
+var responseModelPaths = []string{"model", "message.model", "modelVersion", "response.model", "response.modelVersion"}
+
+// routeRequest resolves the providers and upstream model for a client-requested model,
+// applying a client provider override or else the configured model mappings first.
+// echoModel is the name to report back to the client when the model was rewritten,
+// and empty otherwise. Requests authenticated by a client key are held to its model and
+// provider allowlists.
+func (h *BaseAPIHandler) routeRequest(ctx context.Context, modelName string) (providers []string, normalizedModel, echoModel string, errMsg *interfaces.ErrorMessage) {
+	if errMsg = h.checkClientKeyModel(ctx, modelName); errMsg != nil {
+		return nil, "", "", errMsg
+	}
+	providers, normalizedModel, echoModel, errMsg = h.resolveRoute(ctx, modelName)
+	if errMsg != nil {
+		return nil, "", "", errMsg
+	}
+	if providers, errMsg = h.restrictClientKeyProviders(ctx, normalizedModel, providers); errMsg != nil {
+		return nil, "", "", errMsg
+	}
+	return providers, normalizedModel, echoModel, nil
+}
+
+// resolveRoute implements routeRequest without the client key allowlists.
+func (h *BaseAPIHandler) resolveRoute(ctx context.Context, modelName string) (providers []string, normalizedModel, echoModel string, errMsg *interfaces.ErrorMessage) {
+	if provider, model, ok, errOverride := h.providerOverride(ctx, modelName); errOverride != nil {
+		return nil, "", "", errOverride
+	} else if ok {
+		if model != modelName {
+			echoModel = modelName
+		}
+		return []string{provider}, model, echoModel, nil
+	}
+
+	var mappings []config.ModelMapping
+	strict := false
+	if h.Cfg != nil {
+		mappings = h.Cfg.ModelMappings
+		strict = h.Cfg.StrictModels
+	}
+
+	parsed := thinking.ParseSuffix(modelName)
+	mapping, ok := findModelMapping(mappings, strings.TrimSpace(parsed.ModelName))
+	if !ok {
+		if strict {
+			return nil, "", "", &interfaces.ErrorMessage{StatusCode: http.StatusNotFound, Error: fmt.Errorf("model %s is not available", modelName)}
+		}
+		providers, normalizedModel, errMsg = h.getRequestDetails(modelName)
+		if errMsg != nil {
+			if errPrefix := h.unknownPrefixError(modelName); errPrefix != nil {
+				return nil, "", "", errPrefix
+			}
+		}
+		return providers, normalizedModel, "", errMsg
+	}
+
+	provider, upstream := mapping.Target()
+	if parsed.HasSuffix && !thinking.ParseSuffix(upstream).HasSuffix {
+		upstream = fmt.Sprintf("%s(%s)", upstream, parsed.RawSuffix)
+	}
+	if provider != "" {
+		return []string{provider}, upstream, modelName, nil
+	}
+	providers, normalizedModel, errMsg = h.getRequestDetails(upstream)
+	return providers, normalizedModel, modelName, errMsg
+}
+
+// findModelMapping returns the mapping for model. Exact names win over globs, and
+// globs are tried in configuration order. Matching is case-insensitive.
+func findModelMapping(mappings []config.ModelMapping, model string) (config.ModelMapping, bool) {
+	if model == "" {
+		return config.ModelMapping{}, false
+	}
+	for _, mapping := range mappings {
+		if !mapping.IsGlob() && strings.EqualFold(mapping.From, model) {
+			return mapping, true
+		}
+	}
+	for _, mapping := range mappings {
+		if mapping.IsGlob() && util.MatchGlob(strings.ToLower(mapping.From), strings.ToLower(model)) {
+			return mapping, true
+		}
+	}
+	return config.ModelMapping{}, false
+}
+
+// rewriteResponseModel replaces the model name in a JSON payload or in the data lines
+// of an SSE chunk with model. Payloads without a model field are returned unchanged.
+func rewriteResponseModel(data []byte, model string) []byte {
+	if model == "" || len(data) == 0 {
+		return data
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return rewriteJSONModel(data, model)
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		rest, found := bytes.CutPrefix(line, []byte("data:"))
+		if !found {
+			continue
+		}
+		payload := bytes.TrimLeft(rest, " ")
+		if len(payload) == 0 || payload[0] != '{' {
+			continue
+		}
+		prefix := line[:len(line)-len(payload)]
+		lines[i] = append(bytes.Clone(prefix), rewriteJSONModel(payload, model)...)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func rewriteJSONModel(data []byte, model string) []byte {
+	for _, path := range responseModelPaths {
+		if value := gjson.GetBytes(data, path); value.Exists() && value.Type == gjson.String {
+			if updated, err := sjson.SetBytes(data, path, model); err == nil {
+				data = updated
+			}
+		}
+	}
+	return data
+}
+
+// ApplyModelMappings adds an entry for every exact model mapping whose target appears
+// in models, copied from the target with key set to the alias. key names the field
+// holding the model name ("id" or "name"). With strict-models enabled only the
+// aliases are listed, since other models are rejected.
+func (h *BaseAPIHandler) ApplyModelMappings(models []map[string]any, key string) []map[string]any {
+	if h.Cfg == nil || len(h.Cfg.ModelMappings) == 0 {
+		if h.Cfg != nil && h.Cfg.StrictModels {
+			return []map[string]any{}
+		}
+		return models
+	}
+
+	modelID := func(entry map[string]any) string {
+		id, _ := entry[key].(string)
+		return strings.TrimPrefix(id, "models/")
+	}
+	byID := make(map[string]map[string]any, len(models))
+	for _, entry := range models {
+		byID[strings.ToLower(modelID(entry))] = entry
+	}
+
+	aliases := make([]map[string]any, 0, len(h.Cfg.ModelMappings))
+	seen := make(map[string]struct{}, len(h.Cfg.ModelMappings))
+	for _, mapping := range h.Cfg.ModelMappings {
+		alias := strings.ToLower(mapping.From)
+		if mapping.IsGlob() {
+			continue
+		}
+		if _, dup := seen[alias]; dup {
+			continue
+		}
+		_, upstream := mapping.Target()
+		target, ok := byID[strings.ToLower(thinking.ParseSuffix(upstream).ModelName)]
+		if !ok {
+			continue
+		}
+		seen[alias] = struct{}{}
+		entry := make(map[string]any, len(target))
+		for k, v := range target {
+			entry[k] = v
+		}
+		name := mapping.From
+		if id, _ := target[key].(string); strings.HasPrefix(id, "models/") {
+			name = "models/" + name
+		}
+		entry[key] = name
+		aliases = append(aliases, entry)
+	}
+
+	if h.Cfg.StrictModels {
+		return aliases
+	}
+	out := make([]map[string]any, 0, len(models)+len(aliases))
+	for _, entry := range models {
+		if _, shadowed := seen[strings.ToLower(modelID(entry))]; !shadowed {
+			out = append(out, entry)
+		}
+	}
+	return append(out, aliases...)
+}
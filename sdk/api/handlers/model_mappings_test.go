@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestRouteRequest_ModelMappings(t *testing.T) {
+	modelRegistry := registry.GetGlobalRegistry()
+	modelRegistry.RegisterClient("test-model-mappings-claude", "claude", []*registry.ModelInfo{
+		{ID: "claude-sonnet-4-5", Created: time.Now().Unix()},
+	})
+	t.Cleanup(func() { modelRegistry.UnregisterClient("test-model-mappings-claude") })
+
+	cfg := &sdkconfig.SDKConfig{ModelMappings: []sdkconfig.ModelMapping{
+		{From: "claude-3-5-*", To: "kiro:claude-sonnet-4"},
+		{From: "claude-3-5-haiku", To: "claude-sonnet-4-5"},
+		{From: "gpt-4o", To: "claude-sonnet-4-5"},
+	}}
+	handler := NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil))
+
+	tests := []struct {
+		input         string
+		wantProviders []string
+		wantModel     string
+		wantEcho      string
+	}{
+		{"gpt-4o", []string{"claude"}, "claude-sonnet-4-5", "gpt-4o"},
+		{"GPT-4o(high)", []string{"claude"}, "claude-sonnet-4-5(high)", "GPT-4o(high)"},
+		{"claude-3-5-sonnet-20241022", []string{"kiro"}, "claude-sonnet-4", "claude-3-5-sonnet-20241022"},
+		{"claude-3-5-haiku", []string{"claude"}, "claude-sonnet-4-5", "claude-3-5-haiku"},
+		{"claude-sonnet-4-5", []string{"claude"}, "claude-sonnet-4-5", ""},
+	}
+	for _, tt := range tests {
+		providers, model, echo, errMsg := handler.routeRequest(context.Background(), tt.input)
+		if errMsg != nil {
+			t.Fatalf("routeRequest(%q) error = %v", tt.input, errMsg.Error)
+		}
+		if !reflect.DeepEqual(providers, tt.wantProviders) || model != tt.wantModel || echo != tt.wantEcho {
+			t.Fatalf("routeRequest(%q) = %v, %q, %q; want %v, %q, %q", tt.input, providers, model, echo, tt.wantProviders, tt.wantModel, tt.wantEcho)
+		}
+	}
+
+	cfg.StrictModels = true
+	if _, _, _, errMsg := handler.routeRequest(context.Background(), "claude-sonnet-4-5"); errMsg == nil || errMsg.StatusCode != http.StatusNotFound {
+		t.Fatalf("strict routeRequest(unmapped) error = %+v, want 404", errMsg)
+	}
+	if _, _, _, errMsg := handler.routeRequest(context.Background(), "gpt-4o"); errMsg != nil {
+		t.Fatalf("strict routeRequest(mapped) error = %v", errMsg.Error)
+	}
+}
+func TestRewriteResponseModel(t *testing.T) {
+	if got := string(rewriteResponseModel([]byte(`{"id":"x","model":"claude-sonnet-4-5"}`), "gpt-4o")); got != `{"id":"x","model":"gpt-4o"}` {
+		t.Fatalf("rewrite JSON = %s", got)
+	}
+	sse := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-sonnet-4\"}}\n\n"
+	want := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-3-5-sonnet\"}}\n\n"
+	if got := string(rewriteResponseModel([]byte(sse), "claude-3-5-sonnet")); got != want {
+		t.Fatalf("rewrite SSE = %q, want %q", got, want)
+	}
+	if got := string(rewriteResponseModel([]byte(`{"usage":{}}`), "gpt-4o")); got != `{"usage":{}}` {
+		t.Fatalf("rewrite without model field = %s", got)
+	}
+}
+
+func TestApplyModelMappings(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{ModelMappings: []sdkconfig.ModelMapping{
+		{From: "gpt-4o", To: "claude-sonnet-4-5"},
+		{From: "claude-3-5-*", To: "claude-sonnet-4-5"},
+		{From: "missing", To: "no-such-model"},
+	}}
+	handler := NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil))
+	models := []map[string]any{
+		{"id": "claude-sonnet-4-5", "owned_by": "anthropic"},
+		{"id": "gemini-2.5-pro"},
+	}
+
+	got := handler.ApplyModelMappings(models, "id")
+	want := []map[string]any{
+		{"id": "claude-sonnet-4-5", "owned_by": "anthropic"},
+		{"id": "gemini-2.5-pro"},
+		{"id": "gpt-4o", "owned_by": "anthropic"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ApplyModelMappings() = %v, want %v", got, want)
+	}
+
+	cfg.StrictModels = true
+	if got = handler.ApplyModelMappings(models, "id"); len(got) != 1 || got[0]["id"] != "gpt-4o" {
+		t.Fatalf("strict ApplyModelMappings() = %v, want only the alias", got)
+	}
+
+	gemini := []map[string]any{{"name": "models/claude-sonnet-4-5"}}
+	if got = handler.ApplyModelMappings(gemini, "name"); got[0]["name"] != "models/gpt-4o" {
+		t.Fatalf("ApplyModelMappings(gemini) = %v", got)
+	}
+}
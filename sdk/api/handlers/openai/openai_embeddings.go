@@ -0,0 +1,77 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// embeddingsAlt routes an OpenAI embeddings request to the executors' embeddings path:
+// Gemini credentials call batchEmbedContents, OpenAI-compatible providers forward it
+// to their own /embeddings endpoint.
+const embeddingsAlt = "embeddings"
+
+// Embeddings handles the /v1/embeddings endpoint, answering in the OpenAI embeddings
+// list format.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) Embeddings(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if msg := validateEmbeddingsRequest(rawJSON); msg != "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: msg,
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, embeddingsAlt)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// validateEmbeddingsRequest returns why rawJSON is not a usable embeddings request, or
+// "" when it is.
+func validateEmbeddingsRequest(rawJSON []byte) string {
+	if !gjson.ValidBytes(rawJSON) {
+		return "Invalid request: body must be a JSON object"
+	}
+	if gjson.GetBytes(rawJSON, "model").String() == "" {
+		return "Invalid request: model is required"
+	}
+	input := gjson.GetBytes(rawJSON, "input")
+	switch {
+	case input.Type == gjson.String && input.Str != "":
+	case input.IsArray() && len(input.Array()) > 0:
+	default:
+		return "Invalid request: input must be a non-empty string or array"
+	}
+	if format := gjson.GetBytes(rawJSON, "encoding_format"); format.Exists() && format.String() != "float" && format.String() != "base64" {
+		return "Invalid request: encoding_format must be float or base64"
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package openai
+
+import "testing"
+
+func TestValidateEmbeddingsRequest(t *testing.T) {
+	valid := []string{
+		`{"model":"gemini-embedding-001","input":"hello"}`,
+		`{"model":"gemini-embedding-001","input":["a","b"],"encoding_format":"base64"}`,
+		`{"model":"text-embedding-3-small","input":[1,2,3],"encoding_format":"float"}`,
+	}
+	for _, body := range valid {
+		if msg := validateEmbeddingsRequest([]byte(body)); msg != "" {
+			t.Fatalf("%s rejected: %s", body, msg)
+		}
+	}
+	invalid := []string{
+		`not json`,
+		`{"input":"hello"}`,
+		`{"model":"gemini-embedding-001"}`,
+		`{"model":"gemini-embedding-001","input":[]}`,
+		`{"model":"gemini-embedding-001","input":"hello","encoding_format":"int8"}`,
+	}
+	for _, body := range invalid {
+		if msg := validateEmbeddingsRequest([]byte(body)); msg == "" {
+			t.Fatalf("%s accepted", body)
+		}
+	}
+}
@@ -53,7 +53,7 @@ func (h *OpenAIAPIHandler) HandlerType() string {
 func (h *OpenAIAPIHandler) Models() []map[string]any {
 	// Get dynamic models from the global registry
 	modelRegistry := registry.GetGlobalRegistry()
-	return modelRegistry.GetAvailableModels("openai")
+	return h.ApplyModelMappings(modelRegistry.GetAvailableModels("openai"), "id")
 }
 
 // OpenAIModels handles the /v1/models endpoint.
@@ -291,26 +291,36 @@ func wrapResponsesPayloadAsCompleted(payload []byte) []byte {
 	return []byte(wrapped)
 }
 
-func writeConvertedResponsesChunk(c *gin.Context, ctx context.Context, modelName string, originalChatJSON, responsesRequestJSON, chunk []byte, param *any) {
+func writeConvertedResponsesChunk(c *gin.Context, ctx context.Context, modelName string, originalChatJSON, responsesRequestJSON, chunk []byte, param *any, usageFilter *streamUsageFilter) {
 	outputs := codexconverter.ConvertCodexResponseToOpenAI(ctx, modelName, originalChatJSON, responsesRequestJSON, chunk, param)
 	for _, out := range outputs {
 		if out == "" {
 			continue
 		}
-		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", out)
+		writeChatStreamChunk(c, usageFilter, []byte(out))
 	}
 }
 
-func (h *OpenAIAPIHandler) forwardResponsesAsChatStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, ctx context.Context, modelName string, originalChatJSON, responsesRequestJSON []byte, param *any) {
+// writeChatStreamChunk writes a chat completion chunk after applying usageFilter.
+func writeChatStreamChunk(c *gin.Context, usageFilter *streamUsageFilter, chunk []byte) {
+	if chunk = usageFilter.Filter(chunk); chunk == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
+}
+
+// writeChatStreamDone writes the requested usage chunk, if any, followed by [DONE].
+func writeChatStreamDone(c *gin.Context, usageFilter *streamUsageFilter) {
+	if final := usageFilter.Final(); final != nil {
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(final))
+	}
+	_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+}
+
+func (h *OpenAIAPIHandler) forwardResponsesAsChatStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, ctx context.Context, modelName string, originalChatJSON, responsesRequestJSON []byte, param *any, usageFilter *streamUsageFilter) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
 		WriteChunk: func(chunk []byte) {
-			outputs := codexconverter.ConvertCodexResponseToOpenAI(ctx, modelName, originalChatJSON, responsesRequestJSON, chunk, param)
-			for _, out := range outputs {
-				if out == "" {
-					continue
-				}
-				_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", out)
-			}
+			writeConvertedResponsesChunk(c, ctx, modelName, originalChatJSON, responsesRequestJSON, chunk, param, usageFilter)
 		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
@@ -328,7 +338,7 @@ func (h *OpenAIAPIHandler) forwardResponsesAsChatStream(c *gin.Context, flusher
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(body))
 		},
 		WriteDone: func() {
-			_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+			writeChatStreamDone(c, usageFilter)
 		},
 	})
 }
@@ -519,6 +529,9 @@ func (h *OpenAIAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []
 		cliCancel(errMsg.Error)
 		return
 	}
+	if h.stripsReasoning(rawJSON) {
+		resp = stripReasoningContent(resp)
+	}
 	_, _ = c.Writer.Write(resp)
 	cliCancel()
 }
@@ -543,6 +556,9 @@ func (h *OpenAIAPIHandler) handleNonStreamingResponseViaResponses(c *gin.Context
 		cliCancel(fmt.Errorf("response conversion failed"))
 		return
 	}
+	if h.stripsReasoning(originalChatJSON) {
+		converted = stripReasoningContent(converted)
+	}
 	_, _ = c.Writer.Write(converted)
 	cliCancel()
 }
@@ -570,6 +586,8 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	usageFilter := newStreamUsageFilter(rawJSON)
+	usageFilter.stripReasoning = h.stripsReasoning(rawJSON)
 
 	setSSEHeaders := func() {
 		c.Header("Content-Type", "text/event-stream")
@@ -611,11 +629,11 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 			// Success! Commit to streaming headers.
 			setSSEHeaders()
 
-			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
+			writeChatStreamChunk(c, usageFilter, chunk)
 			flusher.Flush()
 
 			// Continue streaming the rest
-			h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+			h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, usageFilter)
 			return
 		}
 	}
@@ -637,6 +655,8 @@ func (h *OpenAIAPIHandler) handleStreamingResponseViaResponses(c *gin.Context, r
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, OpenaiResponse, modelName, rawJSON, h.GetAlt(c))
 	var param any
+	usageFilter := newStreamUsageFilter(originalChatJSON)
+	usageFilter.stripReasoning = h.stripsReasoning(originalChatJSON)
 
 	setSSEHeaders := func() {
 		c.Header("Content-Type", "text/event-stream")
@@ -673,10 +693,10 @@ func (h *OpenAIAPIHandler) handleStreamingResponseViaResponses(c *gin.Context, r
 			}
 
 			setSSEHeaders()
-			writeConvertedResponsesChunk(c, cliCtx, modelName, originalChatJSON, rawJSON, chunk, &param)
+			writeConvertedResponsesChunk(c, cliCtx, modelName, originalChatJSON, rawJSON, chunk, &param, usageFilter)
 			flusher.Flush()
 
-			h.forwardResponsesAsChatStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, cliCtx, modelName, originalChatJSON, rawJSON, &param)
+			h.forwardResponsesAsChatStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, cliCtx, modelName, originalChatJSON, rawJSON, &param, usageFilter)
 			return
 		}
 	}
@@ -813,15 +833,15 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			h.handleStreamResult(c, flusher, func(err error) {
 				stop()
 				cliCancel(err)
-			}, convertedChan, errChan)
+			}, convertedChan, errChan, nil)
 			return
 		}
 	}
 }
-func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, usageFilter *streamUsageFilter) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
 		WriteChunk: func(chunk []byte) {
-			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
+			writeChatStreamChunk(c, usageFilter, chunk)
 		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
@@ -839,7 +859,7 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(body))
 		},
 		WriteDone: func() {
-			_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+			writeChatStreamDone(c, usageFilter)
 		},
 	})
 }
@@ -51,7 +51,7 @@ func (h *OpenAIResponsesAPIHandler) HandlerType() string {
 func (h *OpenAIResponsesAPIHandler) Models() []map[string]any {
 	// Get dynamic models from the global registry
 	modelRegistry := registry.GetGlobalRegistry()
-	return modelRegistry.GetAvailableModels("openai")
+	return h.ApplyModelMappings(modelRegistry.GetAvailableModels("openai"), "id")
 }
 
 // OpenAIResponsesModels handles the /v1/models endpoint.
@@ -83,6 +83,13 @@ func (h *OpenAIResponsesAPIHandler) Responses(c *gin.Context) {
 		return
 	}
 
+	// Continue a stored conversation: upstreams are called statelessly with its items.
+	rawJSON, errMsg := resolveResponsesInput(defaultResponseStore, rawJSON)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	stream := streamResult.Type == gjson.True
@@ -171,6 +178,7 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponse(c *gin.Context, r
 		cliCancel(errMsg.Error)
 		return
 	}
+	rememberResponse(defaultResponseStore, rawJSON, resp)
 	_, _ = c.Writer.Write(resp)
 	cliCancel()
 }
@@ -196,6 +204,7 @@ func (h *OpenAIResponsesAPIHandler) handleNonStreamingResponseViaChat(c *gin.Con
 		cliCancel(fmt.Errorf("response conversion failed"))
 		return
 	}
+	rememberResponse(defaultResponseStore, originalResponsesJSON, []byte(converted))
 	_, _ = c.Writer.Write([]byte(converted))
 	cliCancel()
 }
@@ -272,9 +281,10 @@ func (h *OpenAIResponsesAPIHandler) handleStreamingResponse(c *gin.Context, rawJ
 			_, _ = c.Writer.Write(chunk)
 			_, _ = c.Writer.Write([]byte("\n"))
 			flusher.Flush()
+			rememberCompletedResponse(rawJSON, chunk)
 
 			// Continue
-			h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+			h.forwardResponsesStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, rawJSON)
 			return
 		}
 	}
@@ -351,6 +361,15 @@ func writeChatAsResponsesChunk(c *gin.Context, ctx context.Context, modelName st
 		}
 		_, _ = c.Writer.Write([]byte(out))
 		_, _ = c.Writer.Write([]byte("\n"))
+		rememberCompletedResponse(originalResponsesJSON, []byte(out))
+	}
+}
+
+// rememberCompletedResponse stores the conversation when chunk is the
+// response.completed event of a streamed response.
+func rememberCompletedResponse(requestJSON, chunk []byte) {
+	if completed, ok := completedResponse(chunk); ok {
+		rememberResponse(defaultResponseStore, requestJSON, completed)
 	}
 }
 
@@ -367,6 +386,7 @@ func (h *OpenAIResponsesAPIHandler) forwardChatAsResponsesStream(c *gin.Context,
 				}
 				_, _ = c.Writer.Write([]byte(out))
 				_, _ = c.Writer.Write([]byte("\n"))
+				rememberCompletedResponse(originalResponsesJSON, []byte(out))
 			}
 		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
@@ -390,7 +410,7 @@ func (h *OpenAIResponsesAPIHandler) forwardChatAsResponsesStream(c *gin.Context,
 	})
 }
 
-func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, requestJSON []byte) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
 		WriteChunk: func(chunk []byte) {
 			if bytes.HasPrefix(chunk, []byte("event:")) {
@@ -398,6 +418,7 @@ func (h *OpenAIResponsesAPIHandler) forwardResponsesStream(c *gin.Context, flush
 			}
 			_, _ = c.Writer.Write(chunk)
 			_, _ = c.Writer.Write([]byte("\n"))
+			rememberCompletedResponse(requestJSON, chunk)
 		},
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
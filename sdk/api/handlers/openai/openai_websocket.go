@@ -0,0 +1,317 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// WebsocketAuthenticatorKey is the gin context key under which the server stores a
+// WebsocketAuthenticator when a chat completions upgrade request carries no API key.
+const WebsocketAuthenticatorKey = "websocketAuthenticator"
+
+// WebsocketAuthenticator authenticates a WebSocket client with the API key sent in its
+// first frame, recording the client on the gin context as the HTTP auth middleware does.
+type WebsocketAuthenticator func(apiKey string) error
+
+// WebsocketAdmitterKey is the gin context key under which the rate limiter stores a
+// WebsocketAdmitter for a chat completions WebSocket connection.
+const WebsocketAdmitterKey = "websocketAdmitter"
+
+// WebsocketAdmitter admits one request sent over a WebSocket against the client's rate
+// limit budget. It returns the func to call when the request finishes, or the error to
+// send when the request is over budget.
+type WebsocketAdmitter func(request []byte) (release func(), err error)
+
+const (
+	// websocketAuthTimeout bounds the wait for the auth frame of a client that did not
+	// authenticate the upgrade request.
+	websocketAuthTimeout = 30 * time.Second
+	// websocketDefaultReadLimit caps client frames when request-limits.max-body-mb is unset.
+	websocketDefaultReadLimit = 32 << 20
+	// websocketWriteTimeout drops clients that stop accepting frames altogether.
+	websocketWriteTimeout = time.Minute
+)
+
+// ChatCompletionsWebsocket serves chat completions over a WebSocket on GET
+// /v1/chat/completions. Each text frame from the client is either a chat completions
+// request, an auth frame {"type":"auth","api_key":"..."} as the first frame when the
+// upgrade request had no API key, or a cancel frame {"type":"cancel"} that stops the
+// request in flight. Requests are always streamed: every chat.completion.chunk is sent
+// as its own JSON frame, followed by {"type":"done"}, or by an error frame
+// {"type":"error","status":N,"error":{...}}; a cancelled request ends with
+// {"type":"cancelled"}. One request runs at a time.
+//
+// Closing the socket or cancelling a request cancels its upstream context. Chunks are
+// written to the socket as the upstream produces them, with no buffering in between,
+// so a slow client slows down the upstream read instead of growing memory.
+func (h *OpenAIAPIHandler) ChatCompletionsWebsocket(c *gin.Context) {
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "GET /v1/chat/completions requires a WebSocket upgrade; use POST for HTTP requests",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     h.websocketOriginAllowed,
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	readLimit := int64(websocketDefaultReadLimit)
+	if h.Cfg != nil && h.Cfg.RequestLimits.MaxBodyMB > 0 {
+		readLimit = int64(h.Cfg.RequestLimits.MaxBodyMB) << 20
+	}
+	conn.SetReadLimit(readLimit)
+
+	session := &chatWebsocketSession{handler: h, gin: c, conn: conn}
+	if value, exists := c.Get(WebsocketAdmitterKey); exists {
+		session.admit, _ = value.(WebsocketAdmitter)
+	}
+	if value, exists := c.Get(WebsocketAuthenticatorKey); exists {
+		authenticate, _ := value.(WebsocketAuthenticator)
+		if authenticate == nil || !session.authenticate(authenticate) {
+			return
+		}
+	}
+	session.serve()
+}
+
+// websocketOriginAllowed reports whether a browser may open the WebSocket: requests
+// without an Origin header, from the origin of the server itself, or from an origin in
+// websocket-allowed-origins are allowed.
+func (h *OpenAIAPIHandler) websocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	if h.Cfg == nil {
+		return false
+	}
+	for _, allowed := range h.Cfg.WebsocketAllowedOrigins {
+		allowed = strings.TrimSuffix(strings.TrimSpace(allowed), "/")
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// chatWebsocketSession is one chat completions WebSocket connection.
+type chatWebsocketSession struct {
+	handler *OpenAIAPIHandler
+	gin     *gin.Context
+	conn    *websocket.Conn
+	// admit budgets each request against the client's rate limit; nil admits all.
+	admit WebsocketAdmitter
+
+	// writeMu serializes frames from the read loop and the running request.
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// authenticate reads the auth frame and checks its API key. On failure it sends an
+// error frame and reports false; the connection must then be closed.
+func (s *chatWebsocketSession) authenticate(authenticate WebsocketAuthenticator) bool {
+	_ = s.conn.SetReadDeadline(time.Now().Add(websocketAuthTimeout))
+	_, frame, err := s.conn.ReadMessage()
+	if err != nil {
+		return false
+	}
+	_ = s.conn.SetReadDeadline(time.Time{})
+	if gjson.GetBytes(frame, "type").String() != "auth" {
+		s.writeError(http.StatusUnauthorized, "Missing API key: the first frame must be {\"type\":\"auth\",\"api_key\":\"...\"}")
+		return false
+	}
+	if err = authenticate(gjson.GetBytes(frame, "api_key").String()); err != nil {
+		s.writeError(http.StatusUnauthorized, err.Error())
+		return false
+	}
+	return true
+}
+
+// serve reads client frames until the socket closes, then cancels the running request
+// and waits for it to finish.
+func (s *chatWebsocketSession) serve() {
+	defer func() {
+		s.mu.Lock()
+		cancel, done := s.cancel, s.done
+		s.mu.Unlock()
+		if cancel != nil {
+			cancel()
+			<-done
+		}
+	}()
+	for {
+		messageType, frame, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TextMessage {
+			s.writeError(http.StatusBadRequest, "frames must be JSON text messages")
+			continue
+		}
+		if !gjson.ValidBytes(frame) {
+			s.writeError(http.StatusBadRequest, "Invalid request: frame is not valid JSON")
+			continue
+		}
+		switch gjson.GetBytes(frame, "type").String() {
+		case "cancel":
+			s.mu.Lock()
+			if s.cancel != nil {
+				s.cancel()
+			}
+			s.mu.Unlock()
+		case "auth":
+			s.writeError(http.StatusBadRequest, "the connection is already authenticated")
+		default:
+			s.start(frame)
+		}
+	}
+}
+
+// start runs a chat completions request unless one is already running.
+func (s *chatWebsocketSession) start(rawJSON []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.writeError(http.StatusConflict, "a request is already in progress on this connection; send {\"type\":\"cancel\"} to stop it")
+		return
+	}
+	rawJSON, _ = sjson.DeleteBytes(rawJSON, "type")
+	rawJSON, _ = sjson.SetBytes(rawJSON, "stream", true)
+	release := func() {}
+	if s.admit != nil {
+		var err error
+		if release, err = s.admit(rawJSON); err != nil {
+			s.writeError(http.StatusTooManyRequests, err.Error())
+			return
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		defer release()
+		defer func() {
+			s.mu.Lock()
+			s.cancel, s.done = nil, nil
+			s.mu.Unlock()
+			cancel()
+		}()
+		s.stream(ctx, rawJSON)
+	}()
+}
+
+// stream executes one request and relays its chunks as frames.
+func (s *chatWebsocketSession) stream(ctx context.Context, rawJSON []byte) {
+	h := s.handler
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, s.gin, ctx)
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(s.gin))
+	usageFilter := newStreamUsageFilter(rawJSON)
+	usageFilter.stripReasoning = h.stripsReasoning(rawJSON)
+
+	for {
+		select {
+		case <-ctx.Done():
+			cliCancel(ctx.Err())
+			_ = s.writeFrame([]byte(`{"type":"cancelled"}`))
+			return
+		case errMsg, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			if errMsg == nil {
+				continue
+			}
+			s.writeErrorMessage(errMsg)
+			cliCancel(errMsg.Error)
+			return
+		case chunk, ok := <-dataChan:
+			if !ok {
+				// The producer closes errChan before dataChan; report a trailing error.
+				if errChan != nil {
+					if errMsg := <-errChan; errMsg != nil {
+						s.writeErrorMessage(errMsg)
+						cliCancel(errMsg.Error)
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					cliCancel(ctx.Err())
+					_ = s.writeFrame([]byte(`{"type":"cancelled"}`))
+					return
+				}
+				if final := usageFilter.Final(); final != nil {
+					_ = s.writeFrame(final)
+				}
+				_ = s.writeFrame([]byte(`{"type":"done"}`))
+				cliCancel(nil)
+				return
+			}
+			if chunk = usageFilter.Filter(chunk); chunk == nil {
+				continue
+			}
+			if err := s.writeFrame(chunk); err != nil {
+				cliCancel(err)
+				return
+			}
+		}
+	}
+}
+
+// writeErrorMessage sends an upstream or validation error as an error frame.
+func (s *chatWebsocketSession) writeErrorMessage(errMsg *interfaces.ErrorMessage) {
+	status := http.StatusInternalServerError
+	if errMsg.StatusCode > 0 {
+		status = errMsg.StatusCode
+	}
+	errText := http.StatusText(status)
+	if errMsg.Error != nil && errMsg.Error.Error() != "" {
+		errText = errMsg.Error.Error()
+	}
+	s.writeError(status, errText)
+}
+
+// writeError sends an error frame in the OpenAI error shape, tagged with its status.
+func (s *chatWebsocketSession) writeError(status int, errText string) {
+	body := handlers.BuildErrorResponseBody(status, errText)
+	body, _ = sjson.SetBytes(body, "type", "error")
+	body, _ = sjson.SetBytes(body, "status", status)
+	_ = s.writeFrame(body)
+}
+
+// writeFrame sends one JSON text frame, blocking until the socket accepts it or
+// websocketWriteTimeout passes.
+func (s *chatWebsocketSession) writeFrame(frame []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	return s.conn.WriteMessage(websocket.TextMessage, frame)
+}
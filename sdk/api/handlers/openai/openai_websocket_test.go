@@ -0,0 +1,311 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// websocketStreamExecutor streams chunks chunks and, when hold is set, then waits for
+// the request context to end.
+type websocketStreamExecutor struct {
+	chunks    []string
+	hold      bool
+	sent      atomic.Int64
+	cancelled chan struct{}
+}
+
+func (e *websocketStreamExecutor) Identifier() string { return "ws-provider" }
+
+func (e *websocketStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *websocketStreamExecutor) ExecuteStream(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	out := make(chan coreexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		for _, chunk := range e.chunks {
+			select {
+			case out <- coreexecutor.StreamChunk{Payload: []byte(chunk)}:
+				e.sent.Add(1)
+			case <-ctx.Done():
+				close(e.cancelled)
+				return
+			}
+		}
+		if e.hold {
+			<-ctx.Done()
+			close(e.cancelled)
+		}
+	}()
+	return out, nil
+}
+
+func (e *websocketStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *websocketStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *websocketStreamExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+// newWebsocketServer serves ChatCompletionsWebsocket backed by executor, behind
+// middleware when it is set.
+func newWebsocketServer(t *testing.T, executor *websocketStreamExecutor, middleware gin.HandlerFunc) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	executor.cancelled = make(chan struct{})
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "ws-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "ws-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	if middleware != nil {
+		router.Use(middleware)
+	}
+	router.GET("/v1/chat/completions", h.ChatCompletionsWebsocket)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/chat/completions"
+}
+
+func dialWebsocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	_, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return string(frame)
+}
+
+func waitCancelled(t *testing.T, executor *websocketStreamExecutor) {
+	t.Helper()
+	select {
+	case <-executor.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream context was not cancelled")
+	}
+}
+
+const websocketRequest = `{"model":"ws-model","messages":[{"role":"user","content":"hi"}]}`
+
+func TestChatCompletionsWebsocket_StreamsChunks(t *testing.T) {
+	executor := &websocketStreamExecutor{chunks: []string{
+		`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+	}}
+	conn := dialWebsocket(t, newWebsocketServer(t, executor, nil))
+
+	// Two requests run one after the other on the same connection.
+	for round := 0; round < 2; round++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+			t.Fatalf("write request: %v", err)
+		}
+		var content string
+		for {
+			frame := readFrame(t, conn)
+			if gjson.Get(frame, "type").String() == "done" {
+				break
+			}
+			if gjson.Get(frame, "object").String() != "chat.completion.chunk" {
+				t.Fatalf("unexpected frame %s", frame)
+			}
+			content += gjson.Get(frame, "choices.0.delta.content").String()
+		}
+		if content != "Hello" {
+			t.Fatalf("round %d content = %q, want Hello", round, content)
+		}
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`not json`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if frame := readFrame(t, conn); gjson.Get(frame, "type").String() != "error" || gjson.Get(frame, "status").Int() != http.StatusBadRequest {
+		t.Fatalf("invalid frame reply = %s", frame)
+	}
+}
+
+func TestChatCompletionsWebsocket_CancelFrame(t *testing.T) {
+	executor := &websocketStreamExecutor{chunks: []string{`{"object":"chat.completion.chunk","choices":[]}`}, hold: true}
+	conn := dialWebsocket(t, newWebsocketServer(t, executor, nil))
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	readFrame(t, conn)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write second request: %v", err)
+	}
+	if frame := readFrame(t, conn); gjson.Get(frame, "status").Int() != http.StatusConflict {
+		t.Fatalf("concurrent request reply = %s", frame)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"cancel"}`)); err != nil {
+		t.Fatalf("write cancel: %v", err)
+	}
+	if frame := readFrame(t, conn); gjson.Get(frame, "type").String() != "cancelled" {
+		t.Fatalf("cancel reply = %s", frame)
+	}
+	waitCancelled(t, executor)
+}
+
+func TestChatCompletionsWebsocket_CloseCancelsUpstream(t *testing.T) {
+	executor := &websocketStreamExecutor{chunks: []string{`{"object":"chat.completion.chunk","choices":[]}`}, hold: true}
+	conn := dialWebsocket(t, newWebsocketServer(t, executor, nil))
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	readFrame(t, conn)
+	_ = conn.Close()
+	waitCancelled(t, executor)
+}
+
+func TestChatCompletionsWebsocket_SlowConsumerThrottlesUpstream(t *testing.T) {
+	chunk := `{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"` + strings.Repeat("x", 64<<10) + `"}}]}`
+	chunks := make([]string, 1000)
+	for i := range chunks {
+		chunks[i] = chunk
+	}
+	executor := &websocketStreamExecutor{chunks: chunks}
+	conn := dialWebsocket(t, newWebsocketServer(t, executor, nil))
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	// Without reading, only what fits in the socket buffers may leave the upstream.
+	time.Sleep(300 * time.Millisecond)
+	if sent := executor.sent.Load(); sent >= int64(len(chunks)) {
+		t.Fatalf("upstream sent all %d chunks to a client that read none", sent)
+	}
+	_ = conn.Close()
+	waitCancelled(t, executor)
+}
+
+func TestChatCompletionsWebsocket_FirstFrameAuth(t *testing.T) {
+	executor := &websocketStreamExecutor{chunks: []string{`{"object":"chat.completion.chunk","choices":[]}`}}
+	url := newWebsocketServer(t, executor, func(c *gin.Context) {
+		c.Set(WebsocketAuthenticatorKey, WebsocketAuthenticator(func(apiKey string) error {
+			if apiKey != "secret" {
+				return errors.New("Invalid API key")
+			}
+			c.Set("apiKey", apiKey)
+			return nil
+		}))
+	})
+
+	conn := dialWebsocket(t, url)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if frame := readFrame(t, conn); gjson.Get(frame, "status").Int() != http.StatusUnauthorized {
+		t.Fatalf("request without auth frame = %s", frame)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("connection stayed open without authentication")
+	}
+
+	conn = dialWebsocket(t, url)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"auth","api_key":"wrong"}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if frame := readFrame(t, conn); !strings.Contains(gjson.Get(frame, "error.message").String(), "Invalid API key") {
+		t.Fatalf("wrong key reply = %s", frame)
+	}
+
+	conn = dialWebsocket(t, url)
+	for _, frame := range []string{`{"type":"auth","api_key":"secret"}`, websocketRequest} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	readFrame(t, conn)
+	if frame := readFrame(t, conn); gjson.Get(frame, "type").String() != "done" {
+		t.Fatalf("authenticated request ended with %s", frame)
+	}
+}
+
+func TestChatCompletionsWebsocket_RateLimitsEachRequest(t *testing.T) {
+	executor := &websocketStreamExecutor{chunks: []string{`{"object":"chat.completion.chunk","choices":[]}`}}
+	var admitted, released atomic.Int64
+	url := newWebsocketServer(t, executor, func(c *gin.Context) {
+		c.Set(WebsocketAdmitterKey, WebsocketAdmitter(func([]byte) (func(), error) {
+			if admitted.Add(1) > 1 {
+				return nil, errors.New("rate limit exceeded for this API key, retry after 60 seconds")
+			}
+			return func() { released.Add(1) }, nil
+		}))
+	})
+
+	conn := dialWebsocket(t, url)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readFrame(t, conn)
+	if frame := readFrame(t, conn); gjson.Get(frame, "type").String() != "done" {
+		t.Fatalf("admitted request ended with %s", frame)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(websocketRequest)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if frame := readFrame(t, conn); gjson.Get(frame, "status").Int() != http.StatusTooManyRequests {
+		t.Fatalf("request over budget = %s", frame)
+	}
+	if released.Load() != 1 {
+		t.Fatalf("released = %d, want 1", released.Load())
+	}
+}
+
+func TestChatCompletionsWebsocket_ChecksOrigin(t *testing.T) {
+	executor := &websocketStreamExecutor{}
+	url := newWebsocketServer(t, executor, nil)
+	dial := func(origin string) *http.Response {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if err == nil {
+			_ = conn.Close()
+		}
+		return resp
+	}
+	if resp := dial("https://evil.example.com"); resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin upgrade = %+v, want 403", resp)
+	}
+	host := strings.TrimPrefix(strings.TrimSuffix(url, "/v1/chat/completions"), "ws://")
+	if resp := dial("http://" + host); resp == nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("same-origin upgrade = %+v, want 101", resp)
+	}
+}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// stripsReasoning reports whether reasoning_content is dropped from the chat completion
+// answering rawJSON: either reasoning.strip-content is configured or the client opted
+// out with "include_reasoning": false or "reasoning": {"exclude": true}.
+func (h *OpenAIAPIHandler) stripsReasoning(rawJSON []byte) bool {
+	if h.Cfg != nil && h.Cfg.Reasoning.StripContent {
+		return true
+	}
+	if include := gjson.GetBytes(rawJSON, "include_reasoning"); include.Exists() && !include.Bool() {
+		return true
+	}
+	return gjson.GetBytes(rawJSON, "reasoning.exclude").Bool()
+}
+
+// stripReasoningContent removes reasoning_content, and the reasoning alias some
+// backends use, from every choice of a chat completion or chunk. It returns nil for a
+// chunk that carried nothing but reasoning, so no empty delta is sent.
+func stripReasoningContent(payload []byte) []byte {
+	root := gjson.ParseBytes(payload)
+	choices := root.Get("choices")
+	if !choices.IsArray() {
+		return payload
+	}
+	stripped := false
+	emptied := true
+	for i, choice := range choices.Array() {
+		for _, field := range []string{"delta", "message"} {
+			body := choice.Get(field)
+			if !body.IsObject() {
+				continue
+			}
+			for _, key := range []string{"reasoning_content", "reasoning"} {
+				if value := body.Get(key); value.Exists() {
+					payload, _ = sjson.DeleteBytes(payload, "choices."+strconv.Itoa(i)+"."+field+"."+key)
+					stripped = stripped || value.Type != gjson.Null
+				}
+			}
+			if field == "delta" && hasDeltaContent(gjson.GetBytes(payload, "choices."+strconv.Itoa(i)+".delta")) {
+				emptied = false
+			}
+			if field == "message" {
+				emptied = false
+			}
+		}
+		if choice.Get("finish_reason").Type == gjson.String {
+			emptied = false
+		}
+	}
+	if stripped && emptied && !root.Get("usage").Exists() {
+		return nil
+	}
+	return payload
+}
+
+// hasDeltaContent reports whether a streamed delta still carries anything but nulls.
+func hasDeltaContent(delta gjson.Result) bool {
+	found := false
+	delta.ForEach(func(_, value gjson.Result) bool {
+		found = value.Type != gjson.Null
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestStripReasoningContent(t *testing.T) {
+	if got := stripReasoningContent([]byte(`{"choices":[{"index":0,"delta":{"reasoning_content":"thinking..."},"finish_reason":null}]}`)); got != nil {
+		t.Fatalf("expected a reasoning-only chunk to be dropped, got %s", got)
+	}
+
+	mixed := stripReasoningContent([]byte(`{"choices":[{"index":0,"delta":{"role":null,"content":"Hi","reasoning_content":"thinking..."},"finish_reason":null}]}`))
+	if gjson.GetBytes(mixed, "choices.0.delta.reasoning_content").Exists() || gjson.GetBytes(mixed, "choices.0.delta.content").String() != "Hi" {
+		t.Fatalf("expected only the reasoning to be removed, got %s", mixed)
+	}
+
+	// Gemini chunks carry a null reasoning_content placeholder next to the real fields.
+	chunk := `{"choices":[{"index":0,"delta":{"role":null,"content":null,"reasoning_content":null,"tool_calls":null},"finish_reason":null}],"usage":{"prompt_tokens":1}}`
+	if got := stripReasoningContent([]byte(chunk)); got == nil || !gjson.GetBytes(got, "usage").Exists() {
+		t.Fatalf("expected the usage chunk to be kept, got %s", got)
+	}
+
+	final := stripReasoningContent([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"42","reasoning_content":"because"},"finish_reason":"stop"}]}`))
+	if gjson.GetBytes(final, "choices.0.message.reasoning_content").Exists() || gjson.GetBytes(final, "choices.0.message.content").String() != "42" {
+		t.Fatalf("unexpected non-stream result %s", final)
+	}
+}
+
+func TestStripsReasoning(t *testing.T) {
+	h := NewOpenAIAPIHandler(&handlers.BaseAPIHandler{Cfg: &sdkconfig.SDKConfig{}})
+	tests := map[string]bool{
+		`{"model":"m"}`:                   false,
+		`{"include_reasoning":true}`:      false,
+		`{"include_reasoning":false}`:     true,
+		`{"reasoning":{"exclude":true}}`:  true,
+		`{"reasoning":{"effort":"high"}}`: false,
+		`{"reasoning":{"exclude":false}}`: false,
+	}
+	for request, want := range tests {
+		if got := h.stripsReasoning([]byte(request)); got != want {
+			t.Errorf("stripsReasoning(%s) = %t, want %t", request, got, want)
+		}
+	}
+
+	h.Cfg.Reasoning.StripContent = true
+	if !h.stripsReasoning([]byte(`{"include_reasoning":true}`)) {
+		t.Errorf("expected strip-content to apply to every request")
+	}
+}
+
+func TestStreamUsageFilter_StripsReasoning(t *testing.T) {
+	filter := newStreamUsageFilter(nil)
+	filter.stripReasoning = true
+	if got := filter.Filter([]byte(`{"choices":[{"index":0,"delta":{"reasoning_content":"hmm"},"finish_reason":null}]}`)); got != nil {
+		t.Fatalf("expected reasoning-only chunk to be dropped, got %s", got)
+	}
+	if got := filter.Filter([]byte(`{"choices":[{"index":0,"delta":{"content":"ok"},"finish_reason":null}]}`)); gjson.GetBytes(got, "choices.0.delta.content").String() != "ok" {
+		t.Fatalf("expected content chunk to pass, got %s", got)
+	}
+}
@@ -0,0 +1,157 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// responseStoreTTL bounds how long a response can be continued by previous_response_id.
+	responseStoreTTL = time.Hour
+	// responseStoreMaxEntries caps the stored conversations; the oldest is evicted first.
+	responseStoreMaxEntries = 4096
+)
+
+// responseStore keeps the conversation behind recent Responses API results in memory so
+// later requests can continue them with previous_response_id. Upstreams are always
+// called statelessly: the stored items are replayed as input.
+type responseStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	now     func() time.Time
+	entries map[string]storedResponse
+	order   []string
+}
+
+type storedResponse struct {
+	items   string
+	expires time.Time
+}
+
+var defaultResponseStore = newResponseStore(responseStoreTTL, responseStoreMaxEntries)
+
+func newResponseStore(ttl time.Duration, max int) *responseStore {
+	return &responseStore{ttl: ttl, max: max, now: time.Now, entries: make(map[string]storedResponse)}
+}
+
+// get returns the input items of the conversation ending with response id.
+func (s *responseStore) get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok || !s.now().Before(entry.expires) {
+		return "", false
+	}
+	return entry.items, true
+}
+
+// put stores items, a JSON array of Responses input items, under response id.
+func (s *responseStore) put(id, items string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if _, exists := s.entries[id]; !exists {
+		s.order = append(s.order, id)
+	}
+	s.entries[id] = storedResponse{items: items, expires: now.Add(s.ttl)}
+	for len(s.order) > 0 {
+		oldest := s.order[0]
+		entry, ok := s.entries[oldest]
+		if ok && len(s.entries) <= s.max && now.Before(entry.expires) {
+			break
+		}
+		delete(s.entries, oldest)
+		s.order = s.order[1:]
+	}
+}
+
+// resolveResponsesInput replaces previous_response_id in rawJSON with the stored
+// conversation, prepended to the request's own input, and returns the rewritten
+// request. Unknown or expired IDs fail with 400, since silently dropping the history
+// would answer a different conversation.
+func resolveResponsesInput(store *responseStore, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	previousID := gjson.GetBytes(rawJSON, "previous_response_id").String()
+	if previousID == "" {
+		return rawJSON, nil
+	}
+	history, ok := store.get(previousID)
+	if !ok {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("previous_response_id %q was not found; responses are kept in memory for %s and only when store is not false, so resend the full input instead", previousID, responseStoreTTL),
+		}
+	}
+	items := appendInputItems(history, gjson.GetBytes(rawJSON, "input"))
+	out, _ := sjson.SetRawBytes(rawJSON, "input", []byte(items))
+	out, _ = sjson.DeleteBytes(out, "previous_response_id")
+	return out, nil
+}
+
+// rememberResponse stores the input of requestJSON followed by the output items of
+// responseJSON under the response's id, unless the request opted out with store=false.
+func rememberResponse(store *responseStore, requestJSON, responseJSON []byte) {
+	if storeFlag := gjson.GetBytes(requestJSON, "store"); storeFlag.Exists() && !storeFlag.Bool() {
+		return
+	}
+	response := gjson.ParseBytes(responseJSON)
+	if response.Get("response").IsObject() {
+		response = response.Get("response")
+	}
+	id := response.Get("id").String()
+	if id == "" || response.Get("status").String() == "failed" {
+		return
+	}
+	items := appendInputItems("[]", gjson.GetBytes(requestJSON, "input"))
+	response.Get("output").ForEach(func(_, item gjson.Result) bool {
+		// Reasoning summaries without encrypted content cannot be replayed upstream.
+		if item.Get("type").String() == "reasoning" && item.Get("encrypted_content").String() == "" {
+			return true
+		}
+		items, _ = sjson.SetRaw(items, "-1", item.Raw)
+		return true
+	})
+	store.put(id, items)
+}
+
+// completedResponse returns the response carried by a response.completed stream event.
+func completedResponse(chunk []byte) ([]byte, bool) {
+	if !bytes.Contains(chunk, []byte("response.completed")) {
+		return nil, false
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if gjson.GetBytes(payload, "type").String() == "response.completed" {
+			return payload, true
+		}
+	}
+	return nil, false
+}
+
+// appendInputItems appends the Responses input, a string or an item list, to the JSON
+// array items. A string input becomes a user message.
+func appendInputItems(items string, input gjson.Result) string {
+	switch {
+	case input.IsArray():
+		input.ForEach(func(_, item gjson.Result) bool {
+			items, _ = sjson.SetRaw(items, "-1", item.Raw)
+			return true
+		})
+	case input.Type == gjson.String:
+		message := `{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`
+		message, _ = sjson.Set(message, "content.0.text", input.String())
+		items, _ = sjson.SetRaw(items, "-1", message)
+	}
+	return items
+}
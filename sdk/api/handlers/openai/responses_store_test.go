@@ -0,0 +1,85 @@
+package openai
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestResponseStore_ContinuesConversation(t *testing.T) {
+	store := newResponseStore(time.Hour, 8)
+
+	first := []byte(`{"model":"m","input":"What's the weather in Paris?","tools":[{"type":"function","name":"get_weather"}]}`)
+	response := []byte(`{"id":"resp_1","status":"completed","output":[
+		{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"Need a lookup."}]},
+		{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}
+	]}`)
+	rememberResponse(store, first, response)
+
+	next := []byte(`{"model":"m","previous_response_id":"resp_1","input":[{"type":"function_call_output","call_id":"call_1","output":"Sunny"}]}`)
+	resolved, errMsg := resolveResponsesInput(store, next)
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(resolved, "previous_response_id").Exists() {
+		t.Fatalf("expected previous_response_id to be replaced, got %s", resolved)
+	}
+	input := gjson.GetBytes(resolved, "input")
+	if input.Get("#").Int() != 3 {
+		t.Fatalf("expected history plus the new item, got %s", input.Raw)
+	}
+	if input.Get("0.role").String() != "user" || input.Get("0.content.0.text").String() != "What's the weather in Paris?" {
+		t.Fatalf("expected the string input as a user message, got %s", input.Get("0").Raw)
+	}
+	if input.Get("1.type").String() != "function_call" || input.Get("2.type").String() != "function_call_output" {
+		t.Fatalf("expected the unreplayable reasoning summary to be dropped, got %s", input.Raw)
+	}
+}
+
+func TestResolveResponsesInput_UnknownID(t *testing.T) {
+	store := newResponseStore(time.Hour, 8)
+	_, errMsg := resolveResponsesInput(store, []byte(`{"previous_response_id":"resp_missing","input":"hi"}`))
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest || !strings.Contains(errMsg.Error.Error(), "resp_missing") {
+		t.Fatalf("expected a 400 naming the unknown id, got %+v", errMsg)
+	}
+
+	rememberResponse(store, []byte(`{"store":false,"input":"hi"}`), []byte(`{"id":"resp_private","output":[]}`))
+	if _, ok := store.get("resp_private"); ok {
+		t.Fatalf("expected store=false responses not to be kept")
+	}
+}
+
+func TestResponseStore_ExpiresAndEvicts(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := newResponseStore(time.Minute, 2)
+	store.now = func() time.Time { return now }
+
+	store.put("a", "[]")
+	store.put("b", "[]")
+	store.put("c", "[]")
+	if _, ok := store.get("a"); ok {
+		t.Fatalf("expected the oldest entry to be evicted at capacity")
+	}
+	if _, ok := store.get("c"); !ok {
+		t.Fatalf("expected the newest entry to be kept")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := store.get("c"); ok {
+		t.Fatalf("expected entries to expire after the TTL")
+	}
+}
+
+func TestCompletedResponse(t *testing.T) {
+	chunk := []byte("event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_9\",\"output\":[]}}")
+	payload, ok := completedResponse(chunk)
+	if !ok || gjson.GetBytes(payload, "response.id").String() != "resp_9" {
+		t.Fatalf("expected the completed response, got %q", payload)
+	}
+	if _, ok := completedResponse([]byte(`data: {"type":"response.output_text.delta","delta":"hi"}`)); ok {
+		t.Fatalf("expected other events to be ignored")
+	}
+}
@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// streamUsageFilter applies stream_options.include_usage to chat completion chunks.
+// Backends differ in where they report usage: some attach it to every content chunk,
+// others (Kiro, Gemini CLI) only expose accumulated counts. The filter strips usage
+// from every chunk, remembers the latest usage object, and emits it in a single final
+// chunk with empty choices when the client asked for it. When stripReasoning is set it
+// also drops reasoning_content, see stripReasoningContent.
+type streamUsageFilter struct {
+	include        bool
+	stripReasoning bool
+	usage          string
+	id             string
+	model          string
+	created        int64
+}
+
+func newStreamUsageFilter(rawJSON []byte) *streamUsageFilter {
+	return &streamUsageFilter{include: gjson.GetBytes(rawJSON, "stream_options.include_usage").Bool()}
+}
+
+// Filter returns chunk without its usage field, or nil when nothing but usage remains.
+// Chunks that are not JSON objects pass through unchanged.
+func (f *streamUsageFilter) Filter(chunk []byte) []byte {
+	if f == nil || !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	if f.stripReasoning {
+		if chunk = stripReasoningContent(chunk); chunk == nil {
+			return nil
+		}
+	}
+	root := gjson.ParseBytes(chunk)
+	if !root.IsObject() {
+		return chunk
+	}
+	usage := root.Get("usage")
+	if !usage.Exists() {
+		return chunk
+	}
+	if usage.IsObject() {
+		f.usage = usage.Raw
+		f.id = root.Get("id").String()
+		f.model = root.Get("model").String()
+		f.created = root.Get("created").Int()
+	}
+	if choices := root.Get("choices"); !choices.IsArray() || len(choices.Array()) == 0 {
+		return nil
+	}
+	out, err := sjson.DeleteBytes(chunk, "usage")
+	if err != nil {
+		return chunk
+	}
+	return out
+}
+
+// Final returns the usage chunk to write before [DONE], or nil when the client did not
+// request usage or no usage was reported.
+func (f *streamUsageFilter) Final() []byte {
+	if f == nil || !f.include || f.usage == "" {
+		return nil
+	}
+	chunk := []byte(`{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[]}`)
+	chunk, _ = sjson.SetBytes(chunk, "id", f.id)
+	chunk, _ = sjson.SetBytes(chunk, "created", f.created)
+	chunk, _ = sjson.SetBytes(chunk, "model", f.model)
+	chunk, _ = sjson.SetRawBytes(chunk, "usage", []byte(f.usage))
+	return chunk
+}
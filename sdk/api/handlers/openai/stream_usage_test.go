@@ -0,0 +1,82 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func runStreamUsageFilter(t *testing.T, request string, chunks ...string) []string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	filter := newStreamUsageFilter([]byte(request))
+	for _, chunk := range chunks {
+		writeChatStreamChunk(c, filter, []byte(chunk))
+	}
+	writeChatStreamDone(c, filter)
+
+	var events []string
+	for _, frame := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		events = append(events, strings.TrimPrefix(frame, "data: "))
+	}
+	return events
+}
+
+var streamUsageChunks = []string{
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":7,"model":"m","choices":[{"index":0,"delta":{"content":"Hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":7,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":7,"model":"m","choices":[],"usage":{"prompt_tokens":1000,"completion_tokens":5,"total_tokens":1005,"prompt_tokens_details":{"cached_tokens":894}}}`,
+}
+
+func TestStreamUsageFilter_IncludeUsage(t *testing.T) {
+	events := runStreamUsageFilter(t, `{"stream":true,"stream_options":{"include_usage":true}}`, streamUsageChunks...)
+	if len(events) != 4 {
+		t.Fatalf("expected 2 content chunks, a usage chunk and [DONE], got %d: %q", len(events), events)
+	}
+	for _, event := range events[:2] {
+		if gjson.Get(event, "usage").Exists() {
+			t.Fatalf("content chunk should not carry usage: %s", event)
+		}
+	}
+	final := gjson.Parse(events[2])
+	if !final.Get("choices").IsArray() || len(final.Get("choices").Array()) != 0 {
+		t.Fatalf("usage chunk must have empty choices: %s", events[2])
+	}
+	if final.Get("usage.prompt_tokens").Int() != 1000 || final.Get("usage.prompt_tokens_details.cached_tokens").Int() != 894 {
+		t.Fatalf("usage chunk should carry the latest usage: %s", events[2])
+	}
+	if final.Get("id").String() != "chatcmpl-1" || final.Get("model").String() != "m" || final.Get("created").Int() != 7 {
+		t.Fatalf("usage chunk should reuse the stream id, model and created: %s", events[2])
+	}
+	if events[3] != "[DONE]" {
+		t.Fatalf("last event = %q, want [DONE]", events[3])
+	}
+}
+
+func TestStreamUsageFilter_OmitsUsageWithoutFlag(t *testing.T) {
+	events := runStreamUsageFilter(t, `{"stream":true}`, streamUsageChunks...)
+	if len(events) != 3 || events[2] != "[DONE]" {
+		t.Fatalf("expected 2 content chunks and [DONE], got %q", events)
+	}
+	for _, event := range events[:2] {
+		if gjson.Get(event, "usage").Exists() {
+			t.Fatalf("usage must not be emitted without include_usage: %s", event)
+		}
+	}
+}
+
+func TestStreamUsageFilter_PassesThroughNonJSON(t *testing.T) {
+	filter := newStreamUsageFilter(nil)
+	if got := filter.Filter([]byte("not json")); string(got) != "not json" {
+		t.Fatalf("non-JSON chunk should pass through, got %q", got)
+	}
+	if filter.Final() != nil {
+		t.Fatalf("no usage chunk expected without include_usage")
+	}
+}
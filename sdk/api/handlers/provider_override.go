@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// ginProviderOverrideKey marks a request whose provider was forced by the client, so
+// that it is not failed over to other providers.
+const ginProviderOverrideKey = "cliproxy.provider.override"
+
+// providerOverride resolves a client-forced provider from the ProviderHeader request
+// header or a "provider/model" prefix. ok is false when the request carries no
+// override, overrides are disabled, or the prefix belongs to a registered model name
+// (such as a credential prefix). Unknown providers are rejected with 400.
+func (h *BaseAPIHandler) providerOverride(ctx context.Context, modelName string) (provider, model string, ok bool, errMsg *interfaces.ErrorMessage) {
+	if h.Cfg != nil && h.Cfg.DisableProviderOverride {
+		return "", "", false, nil
+	}
+	parsed := thinking.ParseSuffix(modelName)
+	base := strings.TrimSpace(parsed.ModelName)
+	withSuffix := func(name string) string {
+		if parsed.HasSuffix {
+			return fmt.Sprintf("%s(%s)", name, parsed.RawSuffix)
+		}
+		return name
+	}
+
+	if ginCtx := ginContextOf(ctx); ginCtx != nil && ginCtx.Request != nil {
+		if requested := strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(ProviderHeader))); requested != "" {
+			if errMsg = h.checkOverrideProvider(requested, ProviderHeader+" header"); errMsg != nil {
+				return "", "", false, errMsg
+			}
+			if prefix, rest, found := strings.Cut(base, "/"); found && strings.EqualFold(prefix, requested) && len(util.GetProviderName(base)) == 0 {
+				base = rest
+			}
+			return h.overrideTarget(ctx, requested, base, withSuffix(base))
+		}
+	}
+
+	prefix, rest, found := strings.Cut(base, "/")
+	if !found || prefix == "" || rest == "" || len(util.GetProviderName(base)) > 0 {
+		return "", "", false, nil
+	}
+	requested := strings.ToLower(prefix)
+	if !slices.Contains(h.configuredProviders(), requested) {
+		return "", "", false, nil
+	}
+	return h.overrideTarget(ctx, requested, rest, withSuffix(rest))
+}
+
+// overrideTarget checks that provider serves base and marks the request as overridden.
+func (h *BaseAPIHandler) overrideTarget(ctx context.Context, provider, base, model string) (string, string, bool, *interfaces.ErrorMessage) {
+	if serving := util.GetProviderName(base); !slices.Contains(serving, provider) {
+		reason := fmt.Sprintf("model %s is not served by provider %s", base, provider)
+		if len(serving) > 0 {
+			reason += fmt.Sprintf("; it is served by: %s", strings.Join(serving, ", "))
+		}
+		return "", "", false, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(reason)}
+	}
+	if ginCtx := ginContextOf(ctx); ginCtx != nil {
+		ginCtx.Set(ginProviderOverrideKey, provider)
+	}
+	return provider, model, true, nil
+}
+
+// checkOverrideProvider rejects a provider that has no usable credential, listing the
+// valid values.
+func (h *BaseAPIHandler) checkOverrideProvider(provider, source string) *interfaces.ErrorMessage {
+	valid := h.configuredProviders()
+	if slices.Contains(valid, provider) {
+		return nil
+	}
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadRequest,
+		Error:      fmt.Errorf("unknown provider %q in %s; valid providers: %s", provider, source, strings.Join(valid, ", ")),
+	}
+}
+
+// unknownPrefixError returns the 400 reported for a "provider/model" request that
+// neither names a configured provider nor resolves as a model, or nil when modelName
+// has no prefix or overrides are disabled.
+func (h *BaseAPIHandler) unknownPrefixError(modelName string) *interfaces.ErrorMessage {
+	if h.Cfg != nil && h.Cfg.DisableProviderOverride {
+		return nil
+	}
+	prefix, rest, found := strings.Cut(strings.TrimSpace(thinking.ParseSuffix(modelName).ModelName), "/")
+	if !found || prefix == "" || rest == "" {
+		return nil
+	}
+	return h.checkOverrideProvider(strings.ToLower(prefix), fmt.Sprintf("model %q", modelName))
+}
+
+// configuredProviders returns the sorted providers that have at least one enabled
+// credential.
+func (h *BaseAPIHandler) configuredProviders() []string {
+	if h.AuthManager == nil {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	providers := make([]string, 0, 8)
+	for _, auth := range h.AuthManager.List() {
+		if auth == nil || auth.Disabled {
+			continue
+		}
+		provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+		if _, dup := seen[provider]; dup || provider == "" {
+			continue
+		}
+		seen[provider] = struct{}{}
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// providerOverridden reports whether the client forced the provider of the request.
+func providerOverridden(ctx context.Context) bool {
+	ginCtx := ginContextOf(ctx)
+	return ginCtx != nil && ginCtx.GetString(ginProviderOverrideKey) != ""
+}
+
+func ginContextOf(ctx context.Context) *gin.Context {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	return ginCtx
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func overrideRequestContext(header string) (context.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	if header != "" {
+		ginCtx.Request.Header.Set(ProviderHeader, header)
+	}
+	return context.WithValue(context.Background(), "gin", ginCtx), recorder
+}
+
+func TestExecuteWithAuthManager_ProviderOverrideHeader(t *testing.T) {
+	primary := &failoverExecutor{provider: "override-a", status: http.StatusInternalServerError}
+	secondary := &failoverExecutor{provider: "override-b"}
+	handler := newFailoverHandler(t, "override-model", 0, primary, secondary)
+
+	ctx, _ := overrideRequestContext("Override-A")
+	_, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "override-model", []byte(`{"model":"override-model"}`), "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the forced provider's 500, got %+v", errMsg)
+	}
+	if primary.Calls() == 0 || secondary.Calls() != 0 {
+		t.Fatalf("forced request failed over: %d and %d calls", primary.Calls(), secondary.Calls())
+	}
+
+	ctx, recorder := overrideRequestContext("override-b")
+	resp, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "override-model", []byte(`{"model":"override-model"}`), "")
+	if errMsg != nil || string(resp) != "override-b" {
+		t.Fatalf("resp = %q, err = %+v; want override-b", resp, errMsg)
+	}
+	if got := recorder.Header().Get(ProviderHeader); got != "override-b" {
+		t.Fatalf("%s = %q, want override-b", ProviderHeader, got)
+	}
+}
+
+func TestRouteRequest_ProviderOverride(t *testing.T) {
+	handler := newFailoverHandler(t, "override-model", 0,
+		&failoverExecutor{provider: "override-a"}, &failoverExecutor{provider: "override-b"})
+
+	ctx, _ := overrideRequestContext("")
+	providers, model, echo, errMsg := handler.routeRequest(ctx, "override-b/override-model(high)")
+	if errMsg != nil {
+		t.Fatalf("prefix override error = %v", errMsg.Error)
+	}
+	if len(providers) != 1 || providers[0] != "override-b" || model != "override-model(high)" || echo != "override-b/override-model(high)" {
+		t.Fatalf("prefix override = %v, %q, %q", providers, model, echo)
+	}
+	if !providerOverridden(ctx) {
+		t.Fatal("prefix override not marked on the request")
+	}
+
+	for _, tc := range []struct {
+		header, model, want string
+	}{
+		{"override-c", "override-model", `unknown provider "override-c" in X-CLIProxy-Provider header; valid providers: override-a, override-b`},
+		{"", "overide-a/override-model", `unknown provider "overide-a" in model "overide-a/override-model"; valid providers: override-a, override-b`},
+		{"override-a", "unregistered-model", "model unregistered-model is not served by provider override-a"},
+	} {
+		ctx, _ = overrideRequestContext(tc.header)
+		_, _, _, errMsg = handler.routeRequest(ctx, tc.model)
+		if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest || !strings.Contains(errMsg.Error.Error(), tc.want) {
+			t.Fatalf("routeRequest(%q, %q) error = %+v, want 400 %q", tc.header, tc.model, errMsg, tc.want)
+		}
+	}
+
+	handler.Cfg.DisableProviderOverride = true
+	ctx, _ = overrideRequestContext("override-c")
+	providers, _, _, errMsg = handler.routeRequest(ctx, "override-model")
+	if errMsg != nil || len(providers) != 2 || providerOverridden(ctx) {
+		t.Fatalf("disabled override = %v, %+v", providers, errMsg)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// ResponseCacheHeader names the request header that forces ("force") or skips
+// ("bypass") the response cache, and the response header reporting a cache "hit" or
+// "miss" for requests that used it.
+const ResponseCacheHeader = "X-CLIProxy-Cache"
+
+// ResponseCacheStats returns the counters of the handler's response cache.
+func (h *BaseAPIHandler) ResponseCacheStats() cache.ResponseCacheStats {
+	if h == nil || h.responseCache == nil {
+		return cache.ResponseCacheStats{}
+	}
+	return h.responseCache.Stats()
+}
+
+// applyResponseCacheConfig sizes the response cache from cfg. Disabling the cache
+// drops its entries.
+func (h *BaseAPIHandler) applyResponseCacheConfig(cfg *config.SDKConfig) {
+	if h.responseCache == nil {
+		return
+	}
+	if cfg == nil || !cfg.ResponseCache.Enable {
+		h.responseCache.SetLimits(0, 0, 0)
+		return
+	}
+	rc := cfg.ResponseCache
+	h.responseCache.SetLimits(time.Duration(rc.TTL)*time.Second, rc.MaxEntries, int64(rc.MaxSizeMB)<<20)
+}
+
+// responseCacheKey returns the response cache key of a routed request, or false when
+// the request must not use the cache: the cache is off, the client bypassed it, or the
+// request is not deterministic and caching was not forced. The key covers the client
+// key, so that clients never share responses, the request format, routed model and
+// providers, streaming mode and the canonical request body. Streaming and non-streaming
+// requests therefore never share entries, as a cached response is replayed in the form
+// it was recorded.
+func (h *BaseAPIHandler) responseCacheKey(ctx context.Context, handlerType, model, alt string, providers []string, stream bool, rawJSON []byte) (string, bool) {
+	if ctx == nil || h.responseCache == nil || h.Cfg == nil || !h.Cfg.ResponseCache.Enable {
+		return "", false
+	}
+	mode := ""
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if ginCtx != nil && ginCtx.Request != nil {
+		mode = strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(ResponseCacheHeader)))
+	}
+	if mode == "bypass" {
+		return "", false
+	}
+	if mode != "force" && !h.Cfg.ResponseCache.CacheNondeterministic && !deterministicRequest(rawJSON) {
+		return "", false
+	}
+	canonical, ok := canonicalJSON(rawJSON)
+	if !ok {
+		return "", false
+	}
+	sum := sha256.New()
+	for _, part := range []string{requestAPIKey(ginCtx), handlerType, model, alt, strings.Join(providers, ","), strconv.FormatBool(stream)} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	sum.Write(canonical)
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
+
+// deterministicRequest reports whether the request sets a temperature of 0 in any of
+// the supported request formats. Requests without a temperature use the upstream's
+// default, which is not deterministic.
+func deterministicRequest(rawJSON []byte) bool {
+	for _, path := range []string{"temperature", "generationConfig.temperature", "generation_config.temperature"} {
+		if value := gjson.GetBytes(rawJSON, path); value.Exists() {
+			return value.Type == gjson.Number && value.Float() <= 0
+		}
+	}
+	return false
+}
+
+// canonicalJSON re-encodes a JSON request body with sorted object keys and no
+// insignificant whitespace, so that equivalent bodies share a cache key.
+func canonicalJSON(rawJSON []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return canonical, true
+}
+
+// lookupResponseCache returns the cached response under key. On a hit it reports the
+// original providers and the hit in the response headers, and records zero-cost cached
+// usage for model; on a miss it marks the response as a miss.
+func (h *BaseAPIHandler) lookupResponseCache(ctx context.Context, key, model string, stream bool) (cache.ResponseEntry, bool) {
+	entry, hit := h.responseCache.Get(key)
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	if !hit {
+		if ginCtx != nil {
+			ginCtx.Header(ResponseCacheHeader, "miss")
+		}
+		return entry, false
+	}
+	setServedProvider(ctx, entry.Providers)
+	if ginCtx != nil {
+		ginCtx.Header(ResponseCacheHeader, "hit")
+	}
+	coreusage.PublishRecord(ctx, coreusage.Record{
+		Provider:    strings.Join(entry.Providers, ","),
+		Model:       model,
+		APIKey:      requestAPIKey(ginCtx),
+		RequestedAt: time.Now(),
+		Streamed:    stream,
+		Cached:      true,
+	})
+	return entry, true
+}
+
+// replayCachedStream sends the recorded chunks of a cached stream as a fresh stream.
+func replayCachedStream(ctx context.Context, chunks [][]byte, echoModel string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		for _, chunk := range chunks {
+			select {
+			case <-ctx.Done():
+				return
+			case dataChan <- rewriteResponseModel(cloneBytes(chunk), echoModel):
+			}
+		}
+	}()
+	return dataChan, errChan
+}
+
+// requestAPIKey returns the client key name, or the API key, that authenticated the
+// request, as reported in usage records.
+func requestAPIKey(ginCtx *gin.Context) string {
+	if ginCtx == nil {
+		return ""
+	}
+	if name := ginCtx.GetString("apiKeyName"); name != "" {
+		return name
+	}
+	if value, exists := ginCtx.Get("apiKey"); exists {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newResponseCacheHandler(t *testing.T) (*BaseAPIHandler, *failoverExecutor) {
+	t.Helper()
+	executor := &failoverExecutor{provider: "cache-a"}
+	handler := newFailoverHandler(t, "cache-model", 0, executor)
+	handler.UpdateClients(&sdkconfig.SDKConfig{ResponseCache: sdkconfig.ResponseCacheConfig{Enable: true, TTL: 60, MaxEntries: 10, MaxSizeMB: 1}})
+	return handler, executor
+}
+
+func responseCacheContext(cacheHeader string) (context.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if cacheHeader != "" {
+		ginCtx.Request.Header.Set(ResponseCacheHeader, cacheHeader)
+	}
+	return context.WithValue(context.Background(), "gin", ginCtx), recorder
+}
+
+func TestExecuteWithAuthManager_ResponseCache(t *testing.T) {
+	handler, executor := newResponseCacheHandler(t)
+	execute := func(body, cacheHeader string) (string, string) {
+		t.Helper()
+		ctx, recorder := responseCacheContext(cacheHeader)
+		resp, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "cache-model", []byte(body), "")
+		if errMsg != nil {
+			t.Fatalf("ExecuteWithAuthManager: %v", errMsg.Error)
+		}
+		return string(resp), recorder.Header().Get(ResponseCacheHeader)
+	}
+
+	if resp, state := execute(`{"model":"cache-model","temperature":0,"messages":[{"role":"user","content":"hi"}]}`, ""); resp != "cache-a" || state != "miss" {
+		t.Fatalf("first request = %q, %q", resp, state)
+	}
+	// Key order and whitespace do not change the cache key.
+	if resp, state := execute(`{"messages": [{"content":"hi","role":"user"}], "temperature":0, "model":"cache-model"}`, ""); resp != "cache-a" || state != "hit" {
+		t.Fatalf("repeated request = %q, %q", resp, state)
+	}
+	if executor.Calls() != 1 {
+		t.Fatalf("upstream calls = %d, want 1", executor.Calls())
+	}
+
+	execute(`{"model":"cache-model","temperature":0,"messages":[{"role":"user","content":"hi"}]}`, "bypass")
+	for range 2 {
+		if _, state := execute(`{"model":"cache-model","temperature":0.7}`, ""); state != "" {
+			t.Fatalf("non-deterministic request used the cache: %q", state)
+		}
+	}
+	if executor.Calls() != 4 {
+		t.Fatalf("upstream calls = %d, want 4 after bypassed and non-deterministic requests", executor.Calls())
+	}
+	execute(`{"model":"cache-model","temperature":0.7}`, "force")
+	if _, state := execute(`{"model":"cache-model","temperature":0.7}`, "force"); state != "hit" || executor.Calls() != 5 {
+		t.Fatalf("forced request = %q after %d calls", state, executor.Calls())
+	}
+
+	if stats := handler.ResponseCacheStats(); stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestExecuteWithAuthManager_ResponseCacheIsPerClientKey(t *testing.T) {
+	handler, executor := newResponseCacheHandler(t)
+	body := []byte(`{"model":"cache-model","temperature":0,"messages":[{"role":"user","content":"hi"}]}`)
+	execute := func(apiKey string) string {
+		t.Helper()
+		ctx, recorder := responseCacheContext("")
+		ctx.Value("gin").(*gin.Context).Set("apiKey", apiKey)
+		if _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "cache-model", body, ""); errMsg != nil {
+			t.Fatalf("ExecuteWithAuthManager: %v", errMsg.Error)
+		}
+		return recorder.Header().Get(ResponseCacheHeader)
+	}
+
+	if state := execute("key-a"); state != "miss" {
+		t.Fatalf("first request of key-a = %q", state)
+	}
+	if state := execute("key-b"); state != "miss" {
+		t.Fatalf("key-b was served the response cached for key-a: %q", state)
+	}
+	if state := execute("key-a"); state != "hit" || executor.Calls() != 2 {
+		t.Fatalf("repeated request of key-a = %q after %d calls", state, executor.Calls())
+	}
+}
+
+func TestExecuteStreamWithAuthManager_ResponseCacheReplays(t *testing.T) {
+	handler, executor := newResponseCacheHandler(t)
+	stream := func() ([]string, string) {
+		t.Helper()
+		ctx, recorder := responseCacheContext("")
+		data, errs := handler.ExecuteStreamWithAuthManager(ctx, "openai", "cache-model", []byte(`{"model":"cache-model","temperature":0,"stream":true}`), "")
+		var chunks []string
+		for chunk := range data {
+			chunks = append(chunks, string(chunk))
+		}
+		for errMsg := range errs {
+			t.Fatalf("stream error: %v", errMsg.Error)
+		}
+		return chunks, recorder.Header().Get(ResponseCacheHeader)
+	}
+
+	first, state := stream()
+	if state != "miss" || len(first) != 1 || first[0] != "cache-a" {
+		t.Fatalf("first stream = %q, %q", first, state)
+	}
+	replayed, state := stream()
+	if state != "hit" || len(replayed) != 1 || replayed[0] != "cache-a" {
+		t.Fatalf("replayed stream = %q, %q", replayed, state)
+	}
+	if executor.Calls() != 1 {
+		t.Fatalf("upstream calls = %d, want 1", executor.Calls())
+	}
+}
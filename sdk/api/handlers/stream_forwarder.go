@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -49,16 +50,29 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		}
 	}
 
-	keepAliveInterval := StreamingKeepAliveInterval(h.Cfg)
+	// Keep-alives and the idle timeout both count from the last upstream chunk. They
+	// follow the serving provider's overrides once the provider is known.
+	provider := c.Writer.Header().Get(ProviderHeader)
+	keepAliveInterval := StreamingKeepAliveIntervalFor(h.Cfg, provider)
 	if opts.KeepAliveInterval != nil {
 		keepAliveInterval = *opts.KeepAliveInterval
 	}
-	var keepAlive *time.Ticker
-	var keepAliveC <-chan time.Time
-	if keepAliveInterval > 0 {
-		keepAlive = time.NewTicker(keepAliveInterval)
-		defer keepAlive.Stop()
-		keepAliveC = keepAlive.C
+	idleTimeout := StreamingIdleTimeout(h.Cfg, provider)
+	keepAlive := newStreamTimer(keepAliveInterval)
+	defer keepAlive.stop()
+	idle := newStreamTimer(idleTimeout)
+	defer idle.stop()
+	resetTimers := func() {
+		if provider == "" {
+			if provider = c.Writer.Header().Get(ProviderHeader); provider != "" {
+				if opts.KeepAliveInterval == nil {
+					keepAliveInterval = StreamingKeepAliveIntervalFor(h.Cfg, provider)
+				}
+				idleTimeout = StreamingIdleTimeout(h.Cfg, provider)
+			}
+		}
+		keepAlive.reset(keepAliveInterval)
+		idle.reset(idleTimeout)
 	}
 
 	var terminalErr *interfaces.ErrorMessage
@@ -96,6 +110,7 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 			}
 			writeChunk(chunk)
 			flusher.Flush()
+			resetTimers()
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
@@ -113,9 +128,60 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 			}
 			cancel(execErr)
 			return
-		case <-keepAliveC:
+		case <-keepAlive.C():
 			writeKeepAlive()
 			flusher.Flush()
+			keepAlive.reset(keepAliveInterval)
+		case <-idle.C():
+			idleErr := &interfaces.ErrorMessage{
+				StatusCode: http.StatusGatewayTimeout,
+				Error:      fmt.Errorf("upstream stream idle for %s; aborting", idleTimeout),
+			}
+			if opts.WriteTerminalError != nil {
+				opts.WriteTerminalError(idleErr)
+			}
+			flusher.Flush()
+			cancel(idleErr.Error)
+			return
 		}
 	}
 }
+
+// streamTimer is a resettable timer whose channel is nil while disabled, so selecting
+// on it blocks forever.
+type streamTimer struct {
+	timer *time.Timer
+}
+
+func newStreamTimer(d time.Duration) *streamTimer {
+	t := &streamTimer{}
+	t.reset(d)
+	return t
+}
+
+func (t *streamTimer) C() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
+
+// reset restarts the timer for d, or disables it when d <= 0.
+func (t *streamTimer) reset(d time.Duration) {
+	if d <= 0 {
+		t.stop()
+		t.timer = nil
+		return
+	}
+	if t.timer == nil {
+		t.timer = time.NewTimer(d)
+		return
+	}
+	t.timer.Reset(d)
+}
+
+func (t *streamTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newForwardTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	return c, recorder
+}
+
+func TestStreamingTimeoutsForProvider(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{Streaming: sdkconfig.StreamingConfig{
+		KeepAliveSeconds:   15,
+		IdleTimeoutSeconds: 300,
+		Providers: map[string]sdkconfig.StreamingProviderConfig{
+			"kiro":   {KeepAliveSeconds: 5, IdleTimeoutSeconds: 120},
+			"gemini": {KeepAliveSeconds: -1},
+		},
+	}}
+	cases := []struct {
+		provider        string
+		keepAlive, idle time.Duration
+	}{
+		{"kiro", 5 * time.Second, 120 * time.Second},
+		{"gemini", 0, 300 * time.Second},
+		{"claude", 15 * time.Second, 300 * time.Second},
+		{"", 15 * time.Second, 300 * time.Second},
+	}
+	for _, tc := range cases {
+		if got := StreamingKeepAliveIntervalFor(cfg, tc.provider); got != tc.keepAlive {
+			t.Fatalf("keep-alive for %q = %s, want %s", tc.provider, got, tc.keepAlive)
+		}
+		if got := StreamingIdleTimeout(cfg, tc.provider); got != tc.idle {
+			t.Fatalf("idle timeout for %q = %s, want %s", tc.provider, got, tc.idle)
+		}
+	}
+	if StreamingIdleTimeout(nil, "kiro") != 0 {
+		t.Fatalf("expected no idle timeout without config")
+	}
+}
+
+func TestForwardStream_KeepAliveOnlyWhileIdle(t *testing.T) {
+	c, recorder := newForwardTestContext()
+	interval := 100 * time.Millisecond
+	h := &BaseAPIHandler{Cfg: &sdkconfig.SDKConfig{}}
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage)
+	go func() {
+		// Chunks arrive faster than the interval, so no ping may be written between them.
+		for range 5 {
+			data <- []byte("data: chunk\n\n")
+			time.Sleep(interval / 10)
+		}
+		time.Sleep(3 * interval)
+		close(data)
+	}()
+
+	h.ForwardStream(c, recorder, func(error) {}, data, errs, StreamForwardOptions{
+		KeepAliveInterval: &interval,
+		WriteChunk:        func(chunk []byte) { _, _ = c.Writer.Write(chunk) },
+		WriteKeepAlive:    func() { _, _ = c.Writer.Write([]byte("event: ping\ndata: {\"type\": \"ping\"}\n\n")) },
+	})
+
+	body := recorder.Body.String()
+	lastChunk := strings.LastIndex(body, "data: chunk")
+	if strings.Contains(body[:lastChunk], "event: ping") {
+		t.Fatalf("ping written while upstream data was flowing:\n%s", body)
+	}
+	if !strings.Contains(body[lastChunk:], "event: ping") {
+		t.Fatalf("expected a ping after the upstream went quiet:\n%s", body)
+	}
+}
+
+func TestForwardStream_IdleTimeoutAborts(t *testing.T) {
+	c, recorder := newForwardTestContext()
+	c.Writer.Header().Set(ProviderHeader, "kiro")
+	h := &BaseAPIHandler{Cfg: &sdkconfig.SDKConfig{Streaming: sdkconfig.StreamingConfig{
+		IdleTimeoutSeconds: 60,
+		Providers:          map[string]sdkconfig.StreamingProviderConfig{"kiro": {IdleTimeoutSeconds: 1}},
+	}}}
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage)
+	var cancelErr error
+	var terminal *interfaces.ErrorMessage
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ForwardStream(c, recorder, func(err error) { cancelErr = err }, data, errs, StreamForwardOptions{
+			WriteTerminalError: func(errMsg *interfaces.ErrorMessage) { terminal = errMsg },
+		})
+	}()
+	data <- []byte("data: first\n\n")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not aborted after the idle timeout")
+	}
+	if terminal == nil || terminal.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("terminal error = %+v, want a 504", terminal)
+	}
+	if cancelErr == nil || !errors.Is(cancelErr, terminal.Error) {
+		t.Fatalf("cancel error = %v, want the idle timeout error", cancelErr)
+	}
+}
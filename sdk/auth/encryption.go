@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+)
+
+// EncryptionMigrationResult summarizes a MigrateAuthEncryption run.
+type EncryptionMigrationResult struct {
+	// Encrypted counts plaintext files that were encrypted.
+	Encrypted int
+	// AlreadyEncrypted counts files that were already encrypted with the configured key.
+	AlreadyEncrypted int
+	// Failed maps the path of each file that could not be migrated to the reason.
+	Failed map[string]error
+}
+
+// MigrateAuthEncryption encrypts every plaintext auth JSON file under dir with the
+// configured encryption key. Files that are not valid JSON, or are encrypted with a
+// different key, are reported in Failed and left untouched.
+func MigrateAuthEncryption(dir string) (EncryptionMigrationResult, error) {
+	result := EncryptionMigrationResult{Failed: make(map[string]error)}
+	if !authcrypt.Enabled() {
+		return result, fmt.Errorf("auth encryption: no encryption key configured")
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+			return nil
+		}
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			result.Failed[path] = errRead
+			return nil
+		}
+		if authcrypt.IsEncrypted(data) {
+			if _, errDecrypt := authcrypt.Decrypt(data); errDecrypt != nil {
+				result.Failed[path] = errDecrypt
+				return nil
+			}
+			result.AlreadyEncrypted++
+			return nil
+		}
+		if !json.Valid(data) {
+			result.Failed[path] = fmt.Errorf("not a valid auth JSON file")
+			return nil
+		}
+		if errWrite := writeAuthFile(path, data); errWrite != nil {
+			result.Failed[path] = errWrite
+			return nil
+		}
+		result.Encrypted++
+		return nil
+	})
+	return result, err
+}
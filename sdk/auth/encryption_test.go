@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/claude"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func useEncryptionKey(t *testing.T, secret string) {
+	t.Helper()
+	if err := authcrypt.SetKey(secret); err != nil {
+		t.Fatalf("SetKey() error = %v", err)
+	}
+	t.Cleanup(func() { _ = authcrypt.SetKey("") })
+}
+
+func TestFileTokenStore_EncryptsOnSave(t *testing.T) {
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "legacy.json")
+	if err := os.WriteFile(plainPath, []byte(`{"type":"claude","refresh_token":"rt-legacy","disabled":false}`), 0o600); err != nil {
+		t.Fatalf("write legacy file: %v", err)
+	}
+	useEncryptionKey(t, "master-key")
+	store := NewFileTokenStore()
+	store.SetBaseDir(dir)
+
+	auths, err := store.List(context.Background())
+	if err != nil || len(auths) != 1 || auths[0].Metadata["refresh_token"] != "rt-legacy" {
+		t.Fatalf("List() with plaintext file = %v, %v", auths, err)
+	}
+
+	// Saving unchanged content still re-encrypts the plaintext file.
+	if _, err = store.Save(context.Background(), auths[0]); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	raw, _ := os.ReadFile(plainPath)
+	if !authcrypt.IsEncrypted(raw) || strings.Contains(string(raw), "rt-legacy") {
+		t.Fatalf("legacy file not encrypted on save: %q", raw)
+	}
+
+	fresh := &cliproxyauth.Auth{ID: "fresh.json", Metadata: map[string]any{"type": "codex", "access_token": "at-new"}}
+	if _, err = store.Save(context.Background(), fresh); err != nil {
+		t.Fatalf("Save(new) error = %v", err)
+	}
+	if raw, _ = os.ReadFile(filepath.Join(dir, "fresh.json")); !authcrypt.IsEncrypted(raw) {
+		t.Fatalf("new file not encrypted: %q", raw)
+	}
+
+	auths, err = store.List(context.Background())
+	if err != nil || len(auths) != 2 {
+		t.Fatalf("List() after encryption = %v, %v", auths, err)
+	}
+}
+
+func TestFileTokenStore_EncryptsTokenStorage(t *testing.T) {
+	dir := t.TempDir()
+	useEncryptionKey(t, "master-key")
+	store := NewFileTokenStore()
+	store.SetBaseDir(dir)
+
+	auth := &cliproxyauth.Auth{ID: "claude.json", Storage: &claude.ClaudeTokenStorage{RefreshToken: "rt-storage"}}
+	path, err := store.Save(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !authcrypt.IsEncrypted(raw) || strings.Contains(string(raw), "rt-storage") {
+		t.Fatalf("token storage written in plaintext: %q", raw)
+	}
+	plain, err := authcrypt.ReadFile(path)
+	if err != nil || !strings.Contains(string(plain), `"type":"claude"`) {
+		t.Fatalf("ReadFile() = %q, %v", plain, err)
+	}
+}
+
+func TestFileTokenStore_WrongKeySkipsFile(t *testing.T) {
+	dir := t.TempDir()
+	useEncryptionKey(t, "old-key")
+	store := NewFileTokenStore()
+	store.SetBaseDir(dir)
+	for _, id := range []string{"a.json", "b.json"} {
+		if _, err := store.Save(context.Background(), &cliproxyauth.Auth{ID: id, Metadata: map[string]any{"type": "claude"}}); err != nil {
+			t.Fatalf("Save(%s) error = %v", id, err)
+		}
+	}
+	useEncryptionKey(t, "new-key")
+	if _, err := store.Save(context.Background(), &cliproxyauth.Auth{ID: "c.json", Metadata: map[string]any{"type": "claude"}}); err != nil {
+		t.Fatalf("Save(c.json) error = %v", err)
+	}
+
+	auths, err := store.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v, want files with the wrong key skipped", err)
+	}
+	if len(auths) != 1 || auths[0].ID != "c.json" {
+		t.Fatalf("List() = %v, want only c.json", auths)
+	}
+}
+
+func TestMigrateAuthEncryption(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"one.json":   `{"type":"gemini","token":{"refresh_token":"r1"}}`,
+		"two.json":   `{"type":"qwen"}`,
+		"bad.json":   `not json`,
+		"README.txt": `ignored`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if _, err := MigrateAuthEncryption(dir); err == nil {
+		t.Fatal("MigrateAuthEncryption() without key error = nil, want error")
+	}
+
+	useEncryptionKey(t, "master-key")
+	result, err := MigrateAuthEncryption(dir)
+	if err != nil {
+		t.Fatalf("MigrateAuthEncryption() error = %v", err)
+	}
+	if result.Encrypted != 2 || result.AlreadyEncrypted != 0 || len(result.Failed) != 1 {
+		t.Fatalf("result = %+v, want 2 encrypted and bad.json failed", result)
+	}
+	if _, ok := result.Failed[filepath.Join(dir, "bad.json")]; !ok {
+		t.Fatalf("Failed = %v, want bad.json", result.Failed)
+	}
+	plain, err := authcrypt.ReadFile(filepath.Join(dir, "one.json"))
+	if err != nil || string(plain) != files["one.json"] {
+		t.Fatalf("ReadFile(one.json) = %q, %v", plain, err)
+	}
+
+	again, err := MigrateAuthEncryption(dir)
+	if err != nil || again.Encrypted != 0 || again.AlreadyEncrypted != 2 {
+		t.Fatalf("second run = %+v, %v; want 2 already encrypted", again, err)
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -14,7 +15,11 @@ import (
 	"sync"
 	"time"
 
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // FileTokenStore persists token records and auth metadata using the filesystem as backing storage.
@@ -37,6 +42,67 @@ func (s *FileTokenStore) SetBaseDir(dir string) {
 	s.dirLock.Unlock()
 }
 
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory, so a concurrent reader never sees a partially written auth file.
+// An existing file keeps its permissions; a new file is created with mode 0600.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if info, errStat := os.Stat(path); errStat == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	cleanup := func(err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return cleanup(err)
+	}
+	if _, err = tmp.Write(data); err != nil {
+		return cleanup(err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeAuthFile encrypts plain when an encryption key is configured and writes it
+// atomically to path.
+func writeAuthFile(path string, plain []byte) error {
+	data, err := authcrypt.Encrypt(plain)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// encryptFileInPlace encrypts the plaintext auth file at path when an encryption key
+// is configured. Files that are already encrypted are left untouched.
+func encryptFileInPlace(path string) error {
+	if !authcrypt.Enabled() {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || authcrypt.IsEncrypted(data) {
+		return err
+	}
+	return writeAuthFile(path, data)
+}
+
 // Save persists token storage and metadata to the resolved auth file path.
 func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (string, error) {
 	if auth == nil {
@@ -66,9 +132,24 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 
 	switch {
 	case auth.Storage != nil:
+		if marshaler, ok := auth.Storage.(baseauth.TokenMarshaler); ok {
+			raw, errMarshal := marshaler.MarshalToken()
+			if errMarshal != nil {
+				return "", errMarshal
+			}
+			misc.LogSavingCredentials(path)
+			if err = writeAuthFile(path, raw); err != nil {
+				return "", fmt.Errorf("auth filestore: write file failed: %w", err)
+			}
+			break
+		}
 		if err = auth.Storage.SaveTokenToFile(path); err != nil {
 			return "", err
 		}
+		// Storages that cannot be marshaled write plaintext themselves, so seal the file afterwards.
+		if err = encryptFileInPlace(path); err != nil {
+			return "", fmt.Errorf("auth filestore: encrypt file failed: %w", err)
+		}
 	case auth.Metadata != nil:
 		auth.Metadata["disabled"] = auth.Disabled
 		raw, errMarshal := json.Marshal(auth.Metadata)
@@ -76,25 +157,20 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
+			// Rewrite unchanged content when its encryption state no longer matches the
+			// configured key, so plaintext files are encrypted on their next save.
+			plain, errDecrypt := authcrypt.Decrypt(existing)
+			if errDecrypt == nil && jsonEqual(plain, raw) && authcrypt.IsEncrypted(existing) == authcrypt.Enabled() {
 				return path, nil
 			}
-			file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600)
-			if errOpen != nil {
-				return "", fmt.Errorf("auth filestore: open existing failed: %w", errOpen)
-			}
-			if _, errWrite := file.Write(raw); errWrite != nil {
-				_ = file.Close()
+			if errWrite := writeAuthFile(path, raw); errWrite != nil {
 				return "", fmt.Errorf("auth filestore: write existing failed: %w", errWrite)
 			}
-			if errClose := file.Close(); errClose != nil {
-				return "", fmt.Errorf("auth filestore: close existing failed: %w", errClose)
-			}
 			return path, nil
 		} else if !os.IsNotExist(errRead) {
 			return "", fmt.Errorf("auth filestore: read existing failed: %w", errRead)
 		}
-		if errWrite := os.WriteFile(path, raw, 0o600); errWrite != nil {
+		if errWrite := writeAuthFile(path, raw); errWrite != nil {
 			return "", fmt.Errorf("auth filestore: write file failed: %w", errWrite)
 		}
 	default:
@@ -132,6 +208,9 @@ func (s *FileTokenStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 		}
 		auth, err := s.readAuthFile(path, dir)
 		if err != nil {
+			if errors.Is(err, authcrypt.ErrWrongKey) || errors.Is(err, authcrypt.ErrKeyRequired) {
+				log.Warnf("auth filestore: skipping %s: %v", path, err)
+			}
 			return nil
 		}
 		if auth != nil {
@@ -173,7 +252,7 @@ func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -209,10 +288,7 @@ func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 				if errFetch == nil && strings.TrimSpace(fetchedProjectID) != "" {
 					metadata["project_id"] = strings.TrimSpace(fetchedProjectID)
 					if raw, errMarshal := json.Marshal(metadata); errMarshal == nil {
-						if file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600); errOpen == nil {
-							_, _ = file.Write(raw)
-							_ = file.Close()
-						}
+						_ = writeAuthFile(path, raw)
 					}
 				}
 			}
@@ -1,6 +1,11 @@
 package auth
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
 
 func TestExtractAccessToken(t *testing.T) {
 	t.Parallel()
@@ -78,3 +83,33 @@ func TestExtractAccessToken(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileAtomic_PreservesPermissions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "gemini-user.json")
+	if err := os.WriteFile(path, []byte(`{"access_token":"old"}`), 0o640); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	if err := writeFileAtomic(path, []byte(`{"access_token":"new"}`)); err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil || string(raw) != `{"access_token":"new"}` {
+		t.Fatalf("ReadFile() = %q, %v", raw, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+		t.Fatalf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
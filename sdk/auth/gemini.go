@@ -24,7 +24,7 @@ func (a *GeminiAuthenticator) Provider() string {
 }
 
 func (a *GeminiAuthenticator) RefreshLead() *time.Duration {
-	return nil
+	return new(5 * time.Minute)
 }
 
 func (a *GeminiAuthenticator) Login(ctx context.Context, cfg *config.Config, opts *LoginOptions) (*coreauth.Auth, error) {
@@ -48,6 +48,7 @@ func (a *GeminiAuthenticator) Login(ctx context.Context, cfg *config.Config, opt
 		NoBrowser:    opts.NoBrowser,
 		CallbackPort: opts.CallbackPort,
 		Prompt:       opts.Prompt,
+		Device:       opts.Device,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("gemini authentication failed: %w", err)
@@ -19,6 +19,8 @@ type LoginOptions struct {
 	CallbackPort int
 	Metadata     map[string]string
 	Prompt       func(prompt string) (string, error)
+	// Device selects the OAuth device authorization flow where the provider supports it.
+	Device bool
 }
 
 // Authenticator manages login and optional refresh flows for a provider.
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultSessionAffinityTTL        = 30 * time.Minute
+	defaultSessionAffinityMaxEntries = 10000
+)
+
+// SessionAffinityStats describes the session pin table.
+type SessionAffinityStats struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+	Entries    int
+	Hits       int64
+	Misses     int64
+}
+
+// HitRate returns the fraction of keyed picks served by an existing pin, or 0
+// before any keyed pick.
+func (s SessionAffinityStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type sessionPin struct {
+	key       string
+	authID    string
+	expiresAt time.Time
+}
+
+// sessionAffinity pins conversation keys to the credential that served them so
+// consecutive turns reuse the upstream prompt cache. Entries expire after ttl of
+// inactivity and the table is capped with LRU eviction.
+type sessionAffinity struct {
+	mu         sync.Mutex
+	enabled    bool
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List
+	pins       map[string]*list.Element
+	hits       int64
+	misses     int64
+	now        func() time.Time
+}
+
+func newSessionAffinity() *sessionAffinity {
+	return &sessionAffinity{
+		ttl:        defaultSessionAffinityTTL,
+		maxEntries: defaultSessionAffinityMaxEntries,
+		order:      list.New(),
+		pins:       make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (a *sessionAffinity) configure(enabled bool, ttl time.Duration, maxEntries int) {
+	if ttl <= 0 {
+		ttl = defaultSessionAffinityTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultSessionAffinityMaxEntries
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !enabled {
+		a.order.Init()
+		a.pins = make(map[string]*list.Element)
+		a.hits, a.misses = 0, 0
+	}
+	a.enabled = enabled
+	a.ttl = ttl
+	a.maxEntries = maxEntries
+	a.evictLocked()
+}
+
+func (a *sessionAffinity) isEnabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enabled
+}
+
+// lookup returns the auth pinned to key when it is still among the available
+// candidates. A pin whose credential is parked, disabled or removed is dropped.
+func (a *sessionAffinity) lookup(key, provider, model string, candidates []*Auth) *Auth {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	elem, ok := a.pins[key]
+	if !ok {
+		a.misses++
+		return nil
+	}
+	pin := elem.Value.(*sessionPin)
+	now := a.now()
+	if now.After(pin.expiresAt) {
+		a.removeLocked(elem)
+		a.misses++
+		return nil
+	}
+	available, err := getAvailableAuths(candidates, provider, model, now)
+	if err == nil {
+		for _, candidate := range available {
+			if candidate.ID == pin.authID {
+				pin.expiresAt = now.Add(a.ttl)
+				a.order.MoveToFront(elem)
+				a.hits++
+				return candidate
+			}
+		}
+	}
+	a.removeLocked(elem)
+	a.misses++
+	return nil
+}
+
+func (a *sessionAffinity) pin(key, authID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.enabled {
+		return
+	}
+	expiresAt := a.now().Add(a.ttl)
+	if elem, ok := a.pins[key]; ok {
+		pin := elem.Value.(*sessionPin)
+		pin.authID = authID
+		pin.expiresAt = expiresAt
+		a.order.MoveToFront(elem)
+		return
+	}
+	a.pins[key] = a.order.PushFront(&sessionPin{key: key, authID: authID, expiresAt: expiresAt})
+	a.evictLocked()
+}
+
+func (a *sessionAffinity) removeLocked(elem *list.Element) {
+	a.order.Remove(elem)
+	delete(a.pins, elem.Value.(*sessionPin).key)
+}
+
+func (a *sessionAffinity) evictLocked() {
+	for a.order.Len() > a.maxEntries {
+		a.removeLocked(a.order.Back())
+	}
+}
+
+func (a *sessionAffinity) stats() SessionAffinityStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return SessionAffinityStats{
+		Enabled:    a.enabled,
+		TTL:        a.ttl,
+		MaxEntries: a.maxEntries,
+		Entries:    a.order.Len(),
+		Hits:       a.hits,
+		Misses:     a.misses,
+	}
+}
+
+// SetSessionAffinity enables or disables pinning conversations to the credential that
+// served their previous turn. Pins expire after ttl without use and at most maxEntries
+// are kept; non-positive values select 30 minutes and 10000 entries. Disabling clears the table.
+func (m *Manager) SetSessionAffinity(enabled bool, ttl time.Duration, maxEntries int) {
+	if m == nil {
+		return
+	}
+	m.affinity.configure(enabled, ttl, maxEntries)
+}
+
+// SessionAffinityStats reports the size and hit rate of the session pin table.
+func (m *Manager) SessionAffinityStats() SessionAffinityStats {
+	if m == nil {
+		return SessionAffinityStats{}
+	}
+	return m.affinity.stats()
+}
+
+// pickWithAffinity returns the credential pinned to the request's conversation when it
+// is still available, and otherwise picks with the selector and pins the result.
+func (m *Manager) pickWithAffinity(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, candidates []*Auth) (*Auth, error) {
+	key := ""
+	if m.affinity.isEnabled() {
+		if conversation := sessionKey(opts); conversation != "" {
+			key = conversation + "|" + canonicalModelKey(model)
+		}
+	}
+	if key != "" {
+		if pinned := m.affinity.lookup(key, provider, model, candidates); pinned != nil {
+			return pinned, nil
+		}
+	}
+	selected, err := m.selector.Pick(ctx, provider, model, opts, candidates)
+	if err == nil && selected != nil && key != "" {
+		m.affinity.pin(key, selected.ID)
+	}
+	return selected, err
+}
+
+// sessionKey derives a conversation key for opts: an explicit session ID from the
+// request headers, then the client's metadata.user_id, then a hash of the system
+// prompt and first user message. It returns "" when none is present.
+func sessionKey(opts cliproxyexecutor.Options) string {
+	if id, ok := opts.Metadata[cliproxyexecutor.SessionIDMetadataKey].(string); ok && strings.TrimSpace(id) != "" {
+		return "session:" + strings.TrimSpace(id)
+	}
+	if len(opts.OriginalRequest) == 0 || !gjson.ValidBytes(opts.OriginalRequest) {
+		return ""
+	}
+	root := gjson.ParseBytes(opts.OriginalRequest)
+	if userID := strings.TrimSpace(root.Get("metadata.user_id").String()); userID != "" {
+		return "user:" + userID
+	}
+	system, first := conversationSeed(root)
+	if system == "" && first == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(system + "\x00" + first))
+	return "prompt:" + hex.EncodeToString(sum[:16])
+}
+
+// conversationSeed returns the raw system prompt and first user message of a Claude,
+// OpenAI chat, OpenAI responses or Gemini request body.
+func conversationSeed(root gjson.Result) (system, first string) {
+	for _, path := range []string{"system", "systemInstruction", "system_instruction", "instructions"} {
+		if value := root.Get(path); value.Exists() {
+			system = value.Raw
+			break
+		}
+	}
+	for _, path := range []string{"messages", "contents", "input"} {
+		list := root.Get(path)
+		if list.Type == gjson.String {
+			return system, list.Raw
+		}
+		if !list.IsArray() {
+			continue
+		}
+		for _, message := range list.Array() {
+			role := message.Get("role").String()
+			switch {
+			case role == "system" || role == "developer":
+				if system == "" {
+					system = message.Get("content").Raw
+				}
+			case role == "user" || (role == "" && path == "contents"):
+				if content := message.Get("content"); content.Exists() {
+					return system, content.Raw
+				}
+				return system, message.Get("parts").Raw
+			}
+		}
+	}
+	return system, ""
+}
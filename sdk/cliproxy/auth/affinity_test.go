@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestSessionKey_Sources(t *testing.T) {
+	t.Parallel()
+
+	explicit := cliproxyexecutor.Options{
+		Metadata:        map[string]any{cliproxyexecutor.SessionIDMetadataKey: "abc"},
+		OriginalRequest: []byte(`{"metadata":{"user_id":"u1"}}`),
+	}
+	if got := sessionKey(explicit); got != "session:abc" {
+		t.Fatalf("sessionKey(explicit) = %q, want %q", got, "session:abc")
+	}
+	user := cliproxyexecutor.Options{OriginalRequest: []byte(`{"metadata":{"user_id":"u1"},"messages":[]}`)}
+	if got := sessionKey(user); got != "user:u1" {
+		t.Fatalf("sessionKey(user) = %q, want %q", got, "user:u1")
+	}
+	if got := sessionKey(cliproxyexecutor.Options{OriginalRequest: []byte(`{"model":"x"}`)}); got != "" {
+		t.Fatalf("sessionKey(no conversation) = %q, want empty", got)
+	}
+}
+
+func TestSessionKey_StableAcrossTurns(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string][2]string{
+		"claude": {
+			`{"system":"be brief","messages":[{"role":"user","content":"hi"}]}`,
+			`{"system":"be brief","messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"more"}]}`,
+		},
+		"openai": {
+			`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`,
+			`{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"more"}]}`,
+		},
+		"gemini": {
+			`{"systemInstruction":{"parts":[{"text":"be brief"}]},"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`,
+			`{"systemInstruction":{"parts":[{"text":"be brief"}]},"contents":[{"role":"user","parts":[{"text":"hi"}]},{"role":"model","parts":[{"text":"hello"}]}]}`,
+		},
+	}
+	for name, turns := range cases {
+		first := sessionKey(cliproxyexecutor.Options{OriginalRequest: []byte(turns[0])})
+		second := sessionKey(cliproxyexecutor.Options{OriginalRequest: []byte(turns[1])})
+		if first == "" || first != second {
+			t.Fatalf("%s: keys differ across turns: %q vs %q", name, first, second)
+		}
+	}
+	other := sessionKey(cliproxyexecutor.Options{OriginalRequest: []byte(`{"system":"be brief","messages":[{"role":"user","content":"bye"}]}`)})
+	if other == sessionKey(cliproxyexecutor.Options{OriginalRequest: []byte(cases["claude"][0])}) {
+		t.Fatal("different first messages should produce different keys")
+	}
+}
+
+func TestManagerSessionAffinity_PinsAndRepins(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(nil, &RoundRobinSelector{}, nil)
+	m.SetSessionAffinity(true, time.Hour, 0)
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.SessionIDMetadataKey: "conv-1"}}
+
+	first, err := m.pickWithAffinity(context.Background(), "claude", "claude-sonnet-4", opts, auths)
+	if err != nil {
+		t.Fatalf("pick error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		got, _ := m.pickWithAffinity(context.Background(), "claude", "claude-sonnet-4", opts, auths)
+		if got.ID != first.ID {
+			t.Fatalf("pick #%d = %q, want pinned %q", i, got.ID, first.ID)
+		}
+	}
+
+	// Park the pinned credential; the conversation moves and sticks to a new one.
+	parked := make([]*Auth, 0, len(auths))
+	for _, auth := range auths {
+		if auth.ID == first.ID {
+			auth = &Auth{ID: auth.ID, ModelStates: map[string]*ModelState{
+				"claude-sonnet-4": {Unavailable: true, NextRetryAfter: time.Now().Add(time.Hour), Quota: QuotaState{Exceeded: true}},
+			}}
+		}
+		parked = append(parked, auth)
+	}
+	moved, _ := m.pickWithAffinity(context.Background(), "claude", "claude-sonnet-4", opts, parked)
+	if moved.ID == first.ID {
+		t.Fatalf("parked credential %q was still picked", first.ID)
+	}
+	again, _ := m.pickWithAffinity(context.Background(), "claude", "claude-sonnet-4", opts, parked)
+	if again.ID != moved.ID {
+		t.Fatalf("conversation was not re-pinned: %q then %q", moved.ID, again.ID)
+	}
+
+	stats := m.SessionAffinityStats()
+	if stats.Entries != 1 || stats.Hits != 6 || stats.Misses != 2 {
+		t.Fatalf("stats = %+v, want 1 entry, 6 hits, 2 misses", stats)
+	}
+	if rate := stats.HitRate(); rate != 0.75 {
+		t.Fatalf("HitRate() = %v, want 0.75", rate)
+	}
+}
+
+func TestManagerSessionAffinity_DisabledUsesSelector(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(nil, &RoundRobinSelector{}, nil)
+	auths := []*Auth{{ID: "a"}, {ID: "b"}}
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.SessionIDMetadataKey: "conv-1"}}
+	first, _ := m.pickWithAffinity(context.Background(), "claude", "", opts, auths)
+	second, _ := m.pickWithAffinity(context.Background(), "claude", "", opts, auths)
+	if first.ID == second.ID {
+		t.Fatalf("disabled affinity should rotate, got %q twice", first.ID)
+	}
+	if stats := m.SessionAffinityStats(); stats.Entries != 0 || stats.Hits+stats.Misses != 0 {
+		t.Fatalf("disabled affinity recorded stats: %+v", stats)
+	}
+}
+
+func TestSessionAffinity_LRUAndTTL(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_800_000_000, 0)
+	a := newSessionAffinity()
+	a.now = func() time.Time { return now }
+	a.configure(true, time.Minute, 2)
+	auths := []*Auth{{ID: "x"}}
+
+	a.pin("k1", "x")
+	a.pin("k2", "x")
+	if a.lookup("k1", "claude", "", auths) == nil {
+		t.Fatal("k1 should be pinned")
+	}
+	a.pin("k3", "x")
+	if a.lookup("k2", "claude", "", auths) != nil {
+		t.Fatal("k2 should have been evicted as least recently used")
+	}
+	if a.stats().Entries != 2 {
+		t.Fatalf("entries = %d, want 2", a.stats().Entries)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if a.lookup("k1", "claude", "", auths) != nil {
+		t.Fatal("k1 should have expired")
+	}
+	if a.lookup("k3", "claude", "", auths) != nil {
+		t.Fatal("k3 should have expired")
+	}
+	if a.stats().Entries != 0 {
+		t.Fatalf("entries after expiry = %d, want 0", a.stats().Entries)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"hash/fnv"
 	"io"
 	"net/http"
 	"path/filepath"
@@ -17,10 +18,12 @@ import (
 	"github.com/google/uuid"
 	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -50,8 +53,13 @@ const (
 	refreshCheckInterval  = 30 * time.Second
 	refreshPendingBackoff = time.Minute
 	refreshFailureBackoff = 1 * time.Minute
-	quotaBackoffBase      = time.Second
-	quotaBackoffMax       = 30 * time.Minute
+	// refreshFailureBackoffMax caps the doubling retry delay after failed refreshes.
+	refreshFailureBackoffMax = 30 * time.Minute
+	// refreshJitterMax bounds the per-credential head start added to the refresh lead so
+	// credentials with the same expiry do not refresh at once.
+	refreshJitterMax = 2 * time.Minute
+	quotaBackoffBase = time.Second
+	quotaBackoffMax  = 30 * time.Minute
 )
 
 var quotaCooldownDisabled atomic.Bool
@@ -139,6 +147,9 @@ type Manager struct {
 	// It is initialized in NewManager; never Load() before first Store().
 	runtimeConfig atomic.Value
 
+	// affinity pins conversations to the credential that served them.
+	affinity *sessionAffinity
+
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
@@ -161,6 +172,7 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		affinity:        newSessionAffinity(),
 	}
 	// atomic.Value requires non-nil initial value.
 	manager.runtimeConfig.Store(&internalconfig.Config{})
@@ -490,6 +502,7 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 		if !shouldRetry {
 			break
 		}
+		metrics.RecordRetry(normalized)
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
 		}
@@ -521,6 +534,7 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 		if !shouldRetry {
 			break
 		}
+		metrics.RecordRetry(normalized)
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
 		}
@@ -538,6 +552,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	ctx = usage.WithStreaming(ctx)
 
 	_, maxWait := m.retrySettings()
 
@@ -552,6 +567,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 		if !shouldRetry {
 			break
 		}
+		metrics.RecordRetry(normalized)
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return nil, errWait
 		}
@@ -563,6 +579,17 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 }
 
 func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeNonStreamMixedOnce(ctx, providers, req, opts, ProviderExecutor.Execute)
+}
+
+func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return m.executeNonStreamMixedOnce(ctx, providers, req, opts, ProviderExecutor.CountTokens)
+}
+
+// nonStreamCall is the executor method run by executeNonStreamMixedOnce.
+type nonStreamCall func(ProviderExecutor, context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+
+func (m *Manager) executeNonStreamMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, call nonStreamCall) (cliproxyexecutor.Response, error) {
 	if len(providers) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
@@ -578,98 +605,81 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 			}
 			return cliproxyexecutor.Response{}, errPick
 		}
-
-		entry := logEntryWithRequestID(ctx)
-		debugLogAuthSelection(entry, auth, provider, req.Model)
-
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
-		}
-		execReq := req
-		execReq.Model = rewriteModelForAuth(routeModel, auth)
-		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
-		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
-		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+
+		policy := m.retryPolicyFor(provider)
+		attempt := func(auth *Auth, executor ProviderExecutor, provider string) func(context.Context) (cliproxyexecutor.Response, error) {
+			return func(attemptCtx context.Context) (cliproxyexecutor.Response, error) {
+				return m.executeOnAuth(attemptCtx, auth, executor, provider, routeModel, req, opts, policy, call)
+			}
+		}
+		var resp cliproxyexecutor.Response
+		var errExec error
+		if policy.hedgeAfter > 0 {
+			var cancel context.CancelFunc
+			resp, cancel, errExec = runHedged(ctx, policy.hedgeAfter, attempt(auth, executor, provider), func() (func(context.Context) (cliproxyexecutor.Response, error), bool) {
+				hedgeAuth, hedgeExecutor, hedgeProvider, errHedge := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
+				if errHedge != nil {
+					return nil, false
+				}
+				tried[hedgeAuth.ID] = struct{}{}
+				return attempt(hedgeAuth, hedgeExecutor, hedgeProvider), true
+			}, nil)
+			cancel()
+		} else {
+			resp, errExec = attempt(auth, executor, provider)(ctx)
+		}
 		if errExec != nil {
-			if errCtx := execCtx.Err(); errCtx != nil {
+			if errCtx := ctx.Err(); errCtx != nil {
 				return cliproxyexecutor.Response{}, errCtx
 			}
-			result.Error = &Error{Message: errExec.Error()}
-			if se, ok := errors.AsType[cliproxyexecutor.StatusError](errExec); ok && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
-			if ra := retryAfterFromError(errExec); ra != nil {
-				result.RetryAfter = ra
-			}
-			m.MarkResult(execCtx, result)
 			if isRequestInvalidError(errExec) {
 				return cliproxyexecutor.Response{}, errExec
 			}
 			lastErr = errExec
 			continue
 		}
-		m.MarkResult(execCtx, result)
 		return resp, nil
 	}
 }
 
-func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	if len(providers) == 0 {
-		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
+// executeOnAuth runs call against one auth, retrying it as policy allows, and marks the
+// final result. Attempts cut short by ctx return the context error without marking.
+func (m *Manager) executeOnAuth(ctx context.Context, auth *Auth, executor ProviderExecutor, provider, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, policy retryPolicy, call nonStreamCall) (cliproxyexecutor.Response, error) {
+	entry := logEntryWithRequestID(ctx)
+	debugLogAuthSelection(entry, auth, provider, req.Model)
+
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 	}
-	routeModel := req.Model
-	opts = ensureRequestedModelMetadata(opts, routeModel)
-	tried := make(map[string]struct{})
-	var lastErr error
-	for {
-		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
-		if errPick != nil {
-			if lastErr != nil {
-				return cliproxyexecutor.Response{}, lastErr
-			}
-			return cliproxyexecutor.Response{}, errPick
+	execReq := req
+	execReq.Model = rewriteModelForAuth(routeModel, auth)
+	execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
+	execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+	release := inFlight.acquire(auth.ID)
+	resp, errExec := withRetry(execCtx, policy, func(attemptCtx context.Context) (cliproxyexecutor.Response, error) {
+		return call(executor, attemptCtx, auth, execReq, opts)
+	})
+	release()
+	result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+	if errExec != nil {
+		if errCtx := execCtx.Err(); errCtx != nil {
+			return cliproxyexecutor.Response{}, errCtx
 		}
-
-		entry := logEntryWithRequestID(ctx)
-		debugLogAuthSelection(entry, auth, provider, req.Model)
-
-		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
-		}
-		execReq := req
-		execReq.Model = rewriteModelForAuth(routeModel, auth)
-		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
-		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
-		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
-		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
-		if errExec != nil {
-			if errCtx := execCtx.Err(); errCtx != nil {
-				return cliproxyexecutor.Response{}, errCtx
-			}
-			result.Error = &Error{Message: errExec.Error()}
-			if se, ok := errors.AsType[cliproxyexecutor.StatusError](errExec); ok && se != nil {
-				result.Error.HTTPStatus = se.StatusCode()
-			}
-			if ra := retryAfterFromError(errExec); ra != nil {
-				result.RetryAfter = ra
-			}
-			m.MarkResult(execCtx, result)
-			if isRequestInvalidError(errExec) {
-				return cliproxyexecutor.Response{}, errExec
-			}
-			lastErr = errExec
-			continue
+		result.Error = &Error{Message: errExec.Error()}
+		if se, ok := errors.AsType[cliproxyexecutor.StatusError](errExec); ok && se != nil {
+			result.Error.HTTPStatus = se.StatusCode()
+		}
+		if ra := retryAfterFromError(errExec); ra != nil {
+			result.RetryAfter = ra
 		}
 		m.MarkResult(execCtx, result)
-		return resp, nil
+		return cliproxyexecutor.Response{}, errExec
 	}
+	m.MarkResult(execCtx, result)
+	return resp, nil
 }
 
 func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
@@ -688,71 +698,128 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			}
 			return nil, errPick
 		}
-
-		entry := logEntryWithRequestID(ctx)
-		debugLogAuthSelection(entry, auth, provider, req.Model)
-
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
-		}
-		execReq := req
-		execReq.Model = rewriteModelForAuth(routeModel, auth)
-		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
-		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
-		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+
+		policy := m.retryPolicyFor(provider)
+		attempt := func(auth *Auth, executor ProviderExecutor, provider string) func(context.Context) (<-chan cliproxyexecutor.StreamChunk, error) {
+			return func(attemptCtx context.Context) (<-chan cliproxyexecutor.StreamChunk, error) {
+				return withRetry(attemptCtx, policy, func(retryCtx context.Context) (<-chan cliproxyexecutor.StreamChunk, error) {
+					chunks, errStream := m.streamOnAuth(retryCtx, auth, executor, provider, routeModel, req, opts)
+					if errStream != nil || !policy.awaitsFirstPayload() {
+						return chunks, errStream
+					}
+					// Hold the stream until its first payload so a failure before any
+					// content can still be retried or hedged.
+					return awaitFirstPayload(retryCtx, chunks)
+				})
+			}
+		}
+		var chunks <-chan cliproxyexecutor.StreamChunk
+		var errStream error
+		if policy.hedgeAfter > 0 {
+			var cancel context.CancelFunc
+			chunks, cancel, errStream = runHedged(ctx, policy.hedgeAfter, attempt(auth, executor, provider), func() (func(context.Context) (<-chan cliproxyexecutor.StreamChunk, error), bool) {
+				hedgeAuth, hedgeExecutor, hedgeProvider, errHedge := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
+				if errHedge != nil {
+					return nil, false
+				}
+				tried[hedgeAuth.ID] = struct{}{}
+				return attempt(hedgeAuth, hedgeExecutor, hedgeProvider), true
+			}, drainStream)
+			if errStream == nil {
+				chunks = cancelOnClose(ctx, chunks, cancel)
+			} else {
+				cancel()
+			}
+		} else {
+			chunks, errStream = attempt(auth, executor, provider)(ctx)
+		}
 		if errStream != nil {
-			if errCtx := execCtx.Err(); errCtx != nil {
+			if errCtx := ctx.Err(); errCtx != nil {
 				return nil, errCtx
 			}
-			rerr := &Error{Message: errStream.Error()}
-			if se, ok := errors.AsType[cliproxyexecutor.StatusError](errStream); ok && se != nil {
-				rerr.HTTPStatus = se.StatusCode()
-			}
-			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
-			result.RetryAfter = retryAfterFromError(errStream)
-			m.MarkResult(execCtx, result)
 			if isRequestInvalidError(errStream) {
 				return nil, errStream
 			}
 			lastErr = errStream
 			continue
 		}
-		out := make(chan cliproxyexecutor.StreamChunk)
-		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
-			defer close(out)
-			var failed bool
-			forward := true
-			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
-					failed = true
-					rerr := &Error{Message: chunk.Err.Error()}
-					if se, ok := errors.AsType[cliproxyexecutor.StatusError](chunk.Err); ok && se != nil {
-						rerr.HTTPStatus = se.StatusCode()
-					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr})
-				}
-				if !forward {
-					continue
-				}
-				if streamCtx == nil {
-					out <- chunk
-					continue
+		return chunks, nil
+	}
+}
+
+// streamOnAuth opens a stream on one auth and forwards its chunks, marking the result
+// when the stream fails or ends. Opens cut short by ctx return the context error
+// without marking.
+func (m *Manager) streamOnAuth(ctx context.Context, auth *Auth, executor ProviderExecutor, provider, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	entry := logEntryWithRequestID(ctx)
+	debugLogAuthSelection(entry, auth, provider, req.Model)
+
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execReq := req
+	execReq.Model = rewriteModelForAuth(routeModel, auth)
+	execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
+	execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+	release := inFlight.acquire(auth.ID)
+	chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+	if errStream != nil {
+		release()
+		if errCtx := execCtx.Err(); errCtx != nil {
+			return nil, errCtx
+		}
+		rerr := &Error{Message: errStream.Error()}
+		if se, ok := errors.AsType[cliproxyexecutor.StatusError](errStream); ok && se != nil {
+			rerr.HTTPStatus = se.StatusCode()
+		}
+		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
+		result.RetryAfter = retryAfterFromError(errStream)
+		m.MarkResult(execCtx, result)
+		return nil, errStream
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
+		defer close(out)
+		defer release()
+		var failed bool
+		forward := true
+		for chunk := range streamChunks {
+			if chunk.Err != nil && !failed {
+				failed = true
+				rerr := &Error{Message: chunk.Err.Error()}
+				if se, ok := errors.AsType[cliproxyexecutor.StatusError](chunk.Err); ok && se != nil {
+					rerr.HTTPStatus = se.StatusCode()
 				}
-				select {
-				case <-streamCtx.Done():
-					forward = false
-				case out <- chunk:
+				// A stream cut off because the client went away, or a hedged duplicate
+				// cancelled because the other attempt won, did not fail upstream.
+				if !usage.IsHedgeLoser(streamCtx) && (streamCtx == nil || streamCtx.Err() == nil) {
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr})
 				}
 			}
-			if !failed {
-				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
+			if !forward {
+				continue
 			}
-		}(execCtx, auth.Clone(), provider, chunks)
-		return out, nil
-	}
+			if streamCtx == nil {
+				out <- chunk
+				continue
+			}
+			select {
+			case <-streamCtx.Done():
+				// The client went away; stop counting the request as in flight
+				// even if the upstream keeps its stream open.
+				forward = false
+				release()
+			case out <- chunk:
+			}
+		}
+		if !failed && (streamCtx == nil || streamCtx.Err() == nil) {
+			m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
+		}
+	}(execCtx, auth.Clone(), provider, chunks)
+	return out, nil
 }
 
 func ensureRequestedModelMetadata(opts cliproxyexecutor.Options, requestedModel string) cliproxyexecutor.Options {
@@ -1184,7 +1251,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 					auth.StatusMessage = result.Error.Message
 				}
 
-				statusCode := statusCodeFromResult(result.Error)
+				statusCode := quotaStatusCode(result.Error)
 				switch statusCode {
 				case 401:
 					next := now.Add(30 * time.Minute)
@@ -1204,8 +1271,12 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				case 429:
 					var next time.Time
 					backoffLevel := state.Quota.BackoffLevel
-					if result.RetryAfter != nil {
-						next = now.Add(*result.RetryAfter)
+					retryAfter := result.RetryAfter
+					if retryAfter == nil {
+						retryAfter = quotaResetFromError(result.Error, now)
+					}
+					if retryAfter != nil {
+						next = now.Add(*retryAfter)
 					} else {
 						cooldown, nextLevel := nextQuotaCooldown(backoffLevel, quotaCooldownDisabledForAuth(auth))
 						if cooldown > 0 {
@@ -1465,7 +1536,7 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Durati
 			auth.StatusMessage = resultErr.Message
 		}
 	}
-	statusCode := statusCodeFromResult(resultErr)
+	statusCode := quotaStatusCode(resultErr)
 	switch statusCode {
 	case 401:
 		auth.StatusMessage = "unauthorized"
@@ -1481,6 +1552,9 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Durati
 		auth.Quota.Exceeded = true
 		auth.Quota.Reason = "quota"
 		var next time.Time
+		if retryAfter == nil {
+			retryAfter = quotaResetFromError(resultErr, now)
+		}
 		if retryAfter != nil {
 			next = now.Add(*retryAfter)
 		} else {
@@ -1514,12 +1588,13 @@ func nextQuotaCooldown(prevLevel int, disableCooling bool) (time.Duration, int)
 	if disableCooling {
 		return 0, prevLevel
 	}
-	cooldown := quotaBackoffBase * time.Duration(1<<prevLevel)
-	if cooldown < quotaBackoffBase {
-		cooldown = quotaBackoffBase
+	base, maxCooldown := quotaBackoffLimits()
+	cooldown := base * time.Duration(1<<min(prevLevel, 62))
+	if cooldown < base {
+		cooldown = base
 	}
-	if cooldown >= quotaBackoffMax {
-		return quotaBackoffMax, prevLevel
+	if cooldown >= maxCooldown {
+		return maxCooldown, prevLevel
 	}
 	return cooldown, prevLevel + 1
 }
@@ -1583,7 +1658,7 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
+	selected, errPick := m.pickWithAffinity(ctx, provider, model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
 		return nil, nil, errPick
@@ -1655,7 +1730,7 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		m.mu.RUnlock()
 		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
+	selected, errPick := m.pickWithAffinity(ctx, "mixed", model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
 		return nil, nil, "", errPick
@@ -1799,7 +1874,7 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 			if !expiry.After(now) {
 				return true
 			}
-			if expiry.Sub(now) <= interval {
+			if expiry.Sub(now) <= interval+refreshJitter(a.ID, interval) {
 				return true
 			}
 		}
@@ -1821,7 +1896,7 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 		return false
 	}
 	if hasExpiry && !expiry.IsZero() {
-		return time.Until(expiry) <= *lead
+		return expiry.Sub(now) <= *lead+refreshJitter(a.ID, *lead)
 	}
 	if !lastRefresh.IsZero() {
 		return now.Sub(lastRefresh) >= *lead
@@ -1829,6 +1904,29 @@ func (m *Manager) shouldRefresh(a *Auth, now time.Time) bool {
 	return true
 }
 
+// refreshJitter returns a stable pseudo-random extra lead for the credential id of up to
+// half of lead and at most refreshJitterMax, spreading refreshes of accounts that
+// share an expiry time.
+func refreshJitter(id string, lead time.Duration) time.Duration {
+	window := min(lead/2, refreshJitterMax)
+	if window <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	return time.Duration(h.Sum64() % uint64(window))
+}
+
+// refreshRetryBackoff returns the delay before retrying a refresh after failures
+// consecutive failures, doubling from refreshFailureBackoff up to refreshFailureBackoffMax.
+func refreshRetryBackoff(failures int) time.Duration {
+	backoff := refreshFailureBackoff
+	for i := 1; i < failures && backoff < refreshFailureBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, refreshFailureBackoffMax)
+}
+
 func authPreferredInterval(a *Auth) time.Duration {
 	if a == nil {
 		return 0
@@ -2019,9 +2117,13 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	if err != nil {
 		m.mu.Lock()
 		if current := m.auths[id]; current != nil {
-			current.NextRefreshAfter = now.Add(refreshFailureBackoff)
-			current.LastError = &Error{Message: err.Error()}
+			current.RefreshFailures++
+			backoff := refreshRetryBackoff(current.RefreshFailures)
+			current.NextRefreshAfter = now.Add(backoff)
+			current.RefreshError = &Error{Message: err.Error()}
+			current.LastError = current.RefreshError
 			m.auths[id] = current
+			log.Warnf("refresh failed for %s %s (attempt %d), retrying in %s: %v", current.Provider, current.ID, current.RefreshFailures, backoff, err)
 		}
 		m.mu.Unlock()
 		return
@@ -2039,8 +2141,52 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	// If the Authenticator set a reasonable refresh time, it should not be overwritten
 	// If the Authenticator did not set it (zero value), shouldRefresh will use default logic
 	updated.LastError = nil
+	updated.RefreshFailures = 0
+	updated.RefreshError = nil
 	updated.UpdatedAt = now
-	_, _ = m.Update(ctx, updated)
+	// Keep availability recorded by requests that completed while the refresh ran;
+	// only the credential itself is replaced.
+	m.mu.RLock()
+	if current := m.auths[id]; current != nil {
+		updated.Unavailable = current.Unavailable
+		updated.NextRetryAfter = current.NextRetryAfter
+		updated.Quota = current.Quota
+		updated.ModelStates = current.Clone().ModelStates
+	}
+	m.mu.RUnlock()
+	if errPersist := m.persistRefreshed(ctx, updated); errPersist != nil {
+		log.Errorf("refreshed %s %s but saving the new credentials failed, they are kept in memory only: %v", updated.Provider, updated.ID, errPersist)
+	}
+	_, _ = m.Update(WithSkipPersist(ctx), updated)
+}
+
+// refreshPersistAttempts bounds how often a refreshed credential is written before
+// giving up. Providers such as Claude rotate the refresh token on every refresh, so
+// losing the write leaves a file whose refresh token no longer works.
+const refreshPersistAttempts = 3
+
+// refreshPersistRetryDelay is the base delay between persistence attempts.
+var refreshPersistRetryDelay = 200 * time.Millisecond
+
+// persistRefreshed saves a freshly refreshed credential, retrying transient store
+// failures so rotated refresh tokens reach disk before they are needed again.
+func (m *Manager) persistRefreshed(ctx context.Context, auth *Auth) error {
+	var err error
+	for attempt := 1; attempt <= refreshPersistAttempts; attempt++ {
+		if err = m.persist(ctx, auth); err == nil {
+			return nil
+		}
+		if attempt == refreshPersistAttempts {
+			break
+		}
+		log.Warnf("saving refreshed %s %s failed (attempt %d), retrying: %v", auth.Provider, auth.ID, attempt, err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(time.Duration(attempt) * refreshPersistRetryDelay):
+		}
+	}
+	return err
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
@@ -2155,6 +2301,39 @@ func formatOauthIdentity(auth *Auth, provider string, accountInfo string) string
 	return strings.Join(parts, " ")
 }
 
+// ExecuteOnAuth performs a single non-streaming request with the auth identified by
+// authID, bypassing selection, retries and result tracking. It is intended for
+// credential health checks, so disabled or cooling-down auths are still used.
+func (m *Manager) ExecuteOnAuth(ctx context.Context, authID string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	m.mu.RLock()
+	auth := m.auths[authID]
+	var executor ProviderExecutor
+	if auth != nil {
+		executor = m.executors[executorKeyFromAuth(auth)]
+		auth = auth.Clone()
+	}
+	m.mu.RUnlock()
+	if auth == nil {
+		return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "auth not found: " + authID}
+	}
+	if executor == nil {
+		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "executor not registered for provider: " + auth.Provider}
+	}
+	opts = ensureRequestedModelMetadata(opts, req.Model)
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execReq := req
+	execReq.Model = rewriteModelForAuth(req.Model, auth)
+	execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
+	execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+	release := inFlight.acquire(auth.ID)
+	defer release()
+	return executor.Execute(execCtx, auth, execReq, opts)
+}
+
 // InjectCredentials delegates per-provider HTTP request preparation when supported.
 // If the registered executor for the auth provider implements RequestPreparer,
 // it will be invoked to modify the request (e.g., add headers).
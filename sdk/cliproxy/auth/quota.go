@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+var (
+	quotaBackoffBaseOverride atomic.Int64
+	quotaBackoffMaxOverride  atomic.Int64
+)
+
+// SetQuotaBackoff configures the progressive cooldown applied to quota-exhausted
+// credentials when the upstream does not report a reset time. Non-positive values
+// restore the defaults of 1s base and 30m maximum.
+func SetQuotaBackoff(base, maxCooldown time.Duration) {
+	quotaBackoffBaseOverride.Store(int64(max(base, 0)))
+	quotaBackoffMaxOverride.Store(int64(max(maxCooldown, 0)))
+}
+
+func quotaBackoffLimits() (time.Duration, time.Duration) {
+	base := time.Duration(quotaBackoffBaseOverride.Load())
+	if base <= 0 {
+		base = quotaBackoffBase
+	}
+	maxCooldown := time.Duration(quotaBackoffMaxOverride.Load())
+	if maxCooldown <= 0 {
+		maxCooldown = quotaBackoffMax
+	}
+	return base, max(base, maxCooldown)
+}
+
+// quotaErrorMarkers are provider specific error codes that signal an exhausted quota
+// even when the upstream does not answer with 429.
+var quotaErrorMarkers = []string{
+	"insufficient_quota",    // OpenAI compatible and Qwen
+	"quota_exceeded",        // Qwen
+	"free allocated quota",  // Qwen free tier
+	"usage_limit_reached",   // Codex
+	"monthly_request_count", // Kiro
+	"overage_limit_reached", // Kiro
+	"resource_exhausted",    // Gemini
+	"exceeded your current quota",
+}
+
+// quotaStatusCode returns 429 for errors that report an exhausted quota and the
+// recorded status code otherwise.
+func quotaStatusCode(err *Error) int {
+	statusCode := statusCodeFromResult(err)
+	if statusCode == 429 || err == nil {
+		return statusCode
+	}
+	switch statusCode {
+	case 400, 402, 403:
+	default:
+		return statusCode
+	}
+	message := strings.ToLower(err.Message)
+	for _, marker := range quotaErrorMarkers {
+		if strings.Contains(message, marker) {
+			return 429
+		}
+	}
+	return statusCode
+}
+
+// quotaResetFromError extracts the reset delay reported in an upstream quota error
+// body: Codex resets_at / resets_in_seconds and Google RetryInfo retryDelay.
+func quotaResetFromError(err *Error, now time.Time) *time.Duration {
+	if err == nil {
+		return nil
+	}
+	body := strings.TrimSpace(err.Message)
+	if idx := strings.IndexByte(body, '{'); idx > 0 {
+		body = body[idx:]
+	}
+	if !gjson.Valid(body) {
+		return nil
+	}
+	root := gjson.Parse(body)
+	if resetsAt := root.Get("error.resets_at").Int(); resetsAt > 0 {
+		if wait := time.Unix(resetsAt, 0).Sub(now); wait > 0 {
+			return &wait
+		}
+	}
+	if seconds := root.Get("error.resets_in_seconds").Int(); seconds > 0 {
+		wait := time.Duration(seconds) * time.Second
+		return &wait
+	}
+	for _, detail := range root.Get("error.details").Array() {
+		raw := detail.Get("retryDelay").String()
+		if raw == "" {
+			continue
+		}
+		if wait, errParse := time.ParseDuration(raw); errParse == nil && wait > 0 {
+			return &wait
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotaStatusCode_ProviderMarkers(t *testing.T) {
+	cases := []struct {
+		name string
+		err  *Error
+		want int
+	}{
+		{"plain 429", &Error{HTTPStatus: 429, Message: "slow down"}, 429},
+		{"kiro monthly limit", &Error{HTTPStatus: 403, Message: `{"message":"limit reached","reason":"MONTHLY_REQUEST_COUNT"}`}, 429},
+		{"qwen insufficient quota", &Error{HTTPStatus: 400, Message: `{"error":{"code":"insufficient_quota"}}`}, 429},
+		{"plain forbidden", &Error{HTTPStatus: 403, Message: "forbidden"}, 403},
+		{"server error with marker", &Error{HTTPStatus: 500, Message: "insufficient_quota"}, 500},
+		{"nil", nil, 0},
+	}
+	for _, tc := range cases {
+		if got := quotaStatusCode(tc.err); got != tc.want {
+			t.Fatalf("%s: quotaStatusCode = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestQuotaResetFromError(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	cases := []struct {
+		name    string
+		message string
+		want    time.Duration
+	}{
+		{"codex resets_in_seconds", `{"error":{"type":"usage_limit_reached","resets_in_seconds":120}}`, 2 * time.Minute},
+		{"codex resets_at", `{"error":{"type":"usage_limit_reached","resets_at":1800000300}}`, 5 * time.Minute},
+		{"gemini retry delay", `{"error":{"code":429,"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"34.5s"}]}}`, 34500 * time.Millisecond},
+		{"prefixed body", `status 429: {"error":{"resets_in_seconds":5}}`, 5 * time.Second},
+	}
+	for _, tc := range cases {
+		got := quotaResetFromError(&Error{Message: tc.message}, now)
+		if got == nil || *got != tc.want {
+			t.Fatalf("%s: quotaResetFromError = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if got := quotaResetFromError(&Error{Message: "not json"}, now); got != nil {
+		t.Fatalf("expected no reset for a plain message, got %v", *got)
+	}
+}
+
+func TestNextQuotaCooldown_ConfiguredBackoff(t *testing.T) {
+	SetQuotaBackoff(time.Minute, 3*time.Minute)
+	t.Cleanup(func() { SetQuotaBackoff(0, 0) })
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	level := 0
+	for i, expected := range want {
+		var cooldown time.Duration
+		cooldown, level = nextQuotaCooldown(level, false)
+		if cooldown != expected {
+			t.Fatalf("attempt %d: cooldown = %v, want %v", i, cooldown, expected)
+		}
+	}
+}
+
+func TestMarkResult_ParksQuotaExhaustedCredential(t *testing.T) {
+	prev := quotaCooldownDisabled.Load()
+	quotaCooldownDisabled.Store(false)
+	t.Cleanup(func() { quotaCooldownDisabled.Store(prev) })
+
+	m := NewManager(nil, nil, nil)
+	for _, id := range []string{"kiro-a", "kiro-b"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "kiro"}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+
+	model := "claude-sonnet-4.5"
+	m.MarkResult(context.Background(), Result{
+		AuthID:   "kiro-a",
+		Provider: "kiro",
+		Model:    model,
+		Error:    &Error{HTTPStatus: 403, Message: `{"reason":"MONTHLY_REQUEST_COUNT","error":{"resets_in_seconds":3600}}`},
+	})
+
+	parked, _ := m.GetByID("kiro-a")
+	state := parked.ModelStates[model]
+	if state == nil || !state.Quota.Exceeded {
+		t.Fatalf("expected quota-exceeded model state, got %+v", state)
+	}
+	if wait := time.Until(state.NextRetryAfter); wait < 59*time.Minute || wait > time.Hour {
+		t.Fatalf("NextRetryAfter in %v, want about 1h from the upstream reset", wait)
+	}
+
+	// The remaining credential keeps serving; only when it is parked too does selection fail.
+	now := time.Now()
+	available, err := getAvailableAuths(m.List(), "kiro", model, now)
+	if err != nil || len(available) != 1 || available[0].ID != "kiro-b" {
+		t.Fatalf("expected only kiro-b to be available, got %v, %v", available, err)
+	}
+
+	m.MarkResult(context.Background(), Result{
+		AuthID:   "kiro-b",
+		Provider: "kiro",
+		Model:    model,
+		Error:    &Error{HTTPStatus: 429, Message: "rate limited"},
+	})
+	_, err = getAvailableAuths(m.List(), "kiro", model, now)
+	if err == nil {
+		t.Fatalf("expected an error when every credential is parked")
+	}
+	if !strings.Contains(err.Error(), "All 2 credentials for model claude-sonnet-4.5 via provider kiro are parked") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestRefreshJitter_StableAndBounded(t *testing.T) {
+	t.Parallel()
+
+	lead := 5 * time.Minute
+	seen := map[time.Duration]struct{}{}
+	for _, id := range []string{"a.json", "b.json", "c.json", "d.json"} {
+		jitter := refreshJitter(id, lead)
+		if jitter < 0 || jitter >= refreshJitterMax {
+			t.Fatalf("refreshJitter(%q) = %s, want within [0, %s)", id, jitter, refreshJitterMax)
+		}
+		if again := refreshJitter(id, lead); again != jitter {
+			t.Fatalf("refreshJitter(%q) not stable: %s then %s", id, jitter, again)
+		}
+		seen[jitter] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Fatal("refreshJitter should spread different credentials")
+	}
+	if got := refreshJitter("a.json", 30*time.Second); got >= 15*time.Second {
+		t.Fatalf("refreshJitter with short lead = %s, want < 15s", got)
+	}
+}
+
+func TestRefreshRetryBackoff_Doubles(t *testing.T) {
+	t.Parallel()
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 30 * time.Minute, 30 * time.Minute}
+	for i, expected := range want {
+		if got := refreshRetryBackoff(i + 1); got != expected {
+			t.Fatalf("refreshRetryBackoff(%d) = %s, want %s", i+1, got, expected)
+		}
+	}
+}
+
+func TestShouldRefresh_LeadBeforeExpiry(t *testing.T) {
+	t.Parallel()
+
+	RegisterRefreshLeadProvider("refresh-lead-test", func() *time.Duration { return new(5 * time.Minute) })
+	m := NewManager(nil, nil, nil)
+	now := time.Now()
+	auth := &Auth{ID: "lead.json", Provider: "refresh-lead-test", Metadata: map[string]any{
+		"expired": now.Add(5*time.Minute + refreshJitter("lead.json", 5*time.Minute) - time.Second).Format(time.RFC3339Nano),
+	}}
+	if !m.shouldRefresh(auth, now) {
+		t.Fatal("credential inside its jittered lead should refresh")
+	}
+	auth.Metadata["expired"] = now.Add(10 * time.Minute).Format(time.RFC3339Nano)
+	if m.shouldRefresh(auth, now) {
+		t.Fatal("credential far from expiry should not refresh")
+	}
+}
+
+type refreshExecutor struct {
+	err error
+}
+
+func (e *refreshExecutor) Identifier() string { return "refresh-test" }
+
+func (e *refreshExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *refreshExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (e *refreshExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	auth.Metadata = map[string]any{"access_token": "fresh"}
+	return auth, nil
+}
+
+func (e *refreshExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *refreshExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestRefreshAuth_BacksOffAndRecovers(t *testing.T) {
+	t.Parallel()
+
+	executor := &refreshExecutor{err: errors.New("invalid_grant")}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(executor)
+	ctx := context.Background()
+	if _, err := m.Register(ctx, &Auth{ID: "refresh.json", Provider: "refresh-test", Metadata: map[string]any{"access_token": "stale"}}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		m.refreshAuth(ctx, "refresh.json")
+		got, _ := m.GetByID("refresh.json")
+		if got.RefreshFailures != attempt {
+			t.Fatalf("RefreshFailures = %d, want %d", got.RefreshFailures, attempt)
+		}
+		if got.RefreshError == nil || got.RefreshError.Message != "invalid_grant" {
+			t.Fatalf("RefreshError = %+v, want invalid_grant", got.RefreshError)
+		}
+		if wait := got.NextRefreshAfter.Sub(before); wait < refreshRetryBackoff(attempt) {
+			t.Fatalf("attempt %d NextRefreshAfter in %s, want >= %s", attempt, wait, refreshRetryBackoff(attempt))
+		}
+	}
+
+	executor.err = nil
+	m.refreshAuth(ctx, "refresh.json")
+	got, _ := m.GetByID("refresh.json")
+	if got.RefreshFailures != 0 || got.RefreshError != nil {
+		t.Fatalf("refresh state not cleared: failures=%d error=%v", got.RefreshFailures, got.RefreshError)
+	}
+	if got.Metadata["access_token"] != "fresh" {
+		t.Fatalf("access_token = %v, want fresh", got.Metadata["access_token"])
+	}
+}
+
+type flakyStore struct {
+	failures int
+	saved    []string
+}
+
+func (s *flakyStore) List(context.Context) ([]*Auth, error) { return nil, nil }
+
+func (s *flakyStore) Save(_ context.Context, auth *Auth) (string, error) {
+	if s.failures > 0 {
+		s.failures--
+		return "", errors.New("disk full")
+	}
+	token, _ := auth.Metadata["access_token"].(string)
+	s.saved = append(s.saved, token)
+	return auth.ID, nil
+}
+
+func (s *flakyStore) Delete(context.Context, string) error { return nil }
+
+func TestRefreshAuth_RetriesPersistingRotatedCredentials(t *testing.T) {
+	prevDelay := refreshPersistRetryDelay
+	refreshPersistRetryDelay = time.Millisecond
+	t.Cleanup(func() { refreshPersistRetryDelay = prevDelay })
+
+	store := &flakyStore{}
+	m := NewManager(store, nil, nil)
+	m.RegisterExecutor(&refreshExecutor{})
+	ctx := context.Background()
+	if _, err := m.Register(ctx, &Auth{ID: "rotate.json", Provider: "refresh-test", Metadata: map[string]any{"access_token": "stale"}}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	store.saved = nil
+	store.failures = refreshPersistAttempts - 1
+
+	m.refreshAuth(ctx, "rotate.json")
+	if len(store.saved) != 1 || store.saved[0] != "fresh" {
+		t.Fatalf("saved tokens = %v, want exactly one save of the refreshed credential", store.saved)
+	}
+	if got, _ := m.GetByID("rotate.json"); got.Metadata["access_token"] != "fresh" {
+		t.Fatalf("access_token = %v, want fresh", got.Metadata["access_token"])
+	}
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// errStreamCut reports a stream that failed or ended before emitting any content.
+var errStreamCut = &Error{Code: "stream_cut", Message: "upstream stream ended before any content", HTTPStatus: http.StatusBadGateway}
+
+// retryPolicy is the resolved retry and hedging policy of one provider.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	jitter      float64
+	retryOn     map[string]struct{}
+	hedgeAfter  time.Duration
+}
+
+// retryPolicyFor returns the policy configured for provider, falling back to the "*"
+// entry. The zero policy performs a single attempt without hedging.
+func (m *Manager) retryPolicyFor(provider string) retryPolicy {
+	if m == nil {
+		return retryPolicy{}
+	}
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || len(cfg.RetryPolicies) == 0 {
+		return retryPolicy{}
+	}
+	policy, ok := cfg.RetryPolicies[strings.ToLower(strings.TrimSpace(provider))]
+	if !ok {
+		if policy, ok = cfg.RetryPolicies["*"]; !ok {
+			return retryPolicy{}
+		}
+	}
+	conditions := policy.RetryOn
+	if len(conditions) == 0 {
+		conditions = internalconfig.DefaultRetryOn
+	}
+	retryOn := make(map[string]struct{}, len(conditions))
+	for _, condition := range conditions {
+		retryOn[condition] = struct{}{}
+	}
+	return retryPolicy{
+		maxAttempts: max(policy.MaxAttempts, 1),
+		baseDelay:   time.Duration(policy.BaseDelayMs) * time.Millisecond,
+		maxDelay:    time.Duration(policy.MaxDelayMs) * time.Millisecond,
+		jitter:      policy.Jitter,
+		retryOn:     retryOn,
+		hedgeAfter:  time.Duration(policy.HedgeAfterMs) * time.Millisecond,
+	}
+}
+
+// awaitsFirstPayload reports whether streams must be held until their first payload so
+// a failure before any content can still be retried or hedged.
+func (p retryPolicy) awaitsFirstPayload() bool {
+	if p.hedgeAfter > 0 {
+		return true
+	}
+	_, ok := p.retryOn[internalconfig.RetryOnStreamCut]
+	return ok && p.maxAttempts > 1
+}
+
+// retryable reports whether err matches one of the policy's retry conditions.
+func (p retryPolicy) retryable(err error) bool {
+	if err == nil || len(p.retryOn) == 0 || errors.Is(err, context.Canceled) {
+		return false
+	}
+	has := func(condition string) bool {
+		_, ok := p.retryOn[condition]
+		return ok
+	}
+	if err == errStreamCut {
+		return has(internalconfig.RetryOnStreamCut)
+	}
+	if status := statusCodeFromError(err); status > 0 {
+		if has(strconv.Itoa(status)) || (status >= 500 && has(internalconfig.RetryOnServerError)) {
+			return true
+		}
+		return status == http.StatusRequestTimeout && has(internalconfig.RetryOnTimeout)
+	}
+	if has(internalconfig.RetryOnTimeout) && isTimeoutError(err) {
+		return true
+	}
+	return has(internalconfig.RetryOnConnectionReset) && isConnectionResetError(err)
+}
+
+// delay returns the backoff before retry number retry (1-based): the base delay doubled
+// per retry, capped at the maximum and randomized by the jitter fraction.
+func (p retryPolicy) delay(retry int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < retry && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.maxDelay)
+	if p.jitter > 0 && delay > 0 {
+		delay = time.Duration(float64(delay) * (1 - p.jitter + 2*p.jitter*rand.Float64()))
+	}
+	return delay
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isConnectionResetError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Executors often flatten transport errors into their message.
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe") || strings.Contains(msg, "unexpected eof")
+}
+
+// withRetry runs call until it succeeds, fails with an error the policy does not retry,
+// or the attempts are used up, sleeping the backoff delay between attempts.
+func withRetry[T any](ctx context.Context, policy retryPolicy, call func(context.Context) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		value, err := call(ctx)
+		if err == nil || attempt >= policy.maxAttempts || ctx.Err() != nil || !policy.retryable(err) {
+			return value, err
+		}
+		logEntryWithRequestID(ctx).Debugf("retrying upstream attempt %d/%d after %v", attempt+1, policy.maxAttempts, err)
+		if errWait := waitForCooldown(ctx, policy.delay(attempt)); errWait != nil {
+			return value, err
+		}
+	}
+}
+
+type hedgeOutcome[T any] struct {
+	index int
+	value T
+	err   error
+}
+
+// runHedged runs first and, when it has not finished within delay, a second attempt
+// obtained from next, which reports false when no other credential is available. Both
+// attempts join one usage.HedgeRace before they are dispatched, so only one attempt's
+// usage is counted as the request. The first success is served and becomes the race
+// winner; when the other attempt already claimed the race by publishing its usage, that
+// usage stays the counted one. The other attempt is cancelled once a success arrives. discard releases a value produced by a loser. The returned
+// cancel function ends the winner's context once its value is no longer used.
+func runHedged[T any](ctx context.Context, delay time.Duration, first func(context.Context) (T, error), next func() (func(context.Context) (T, error), bool), discard func(T)) (T, context.CancelFunc, error) {
+	results := make(chan hedgeOutcome[T], 2)
+	race := usage.NewHedgeRace()
+	var cancels []context.CancelFunc
+	var attempts []context.Context
+	start := func(call func(context.Context) (T, error)) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attemptCtx = usage.WithHedgeAttempt(attemptCtx, race)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		attempts = append(attempts, attemptCtx)
+		go func() {
+			value, err := call(attemptCtx)
+			results <- hedgeOutcome[T]{index: index, value: value, err: err}
+		}()
+	}
+	// finish cancels every attempt but winner and releases what late losers return.
+	finish := func(winner, pending int) {
+		for i := range cancels {
+			if i == winner {
+				continue
+			}
+			cancels[i]()
+		}
+		if pending == 0 {
+			return
+		}
+		go func() {
+			for ; pending > 0; pending-- {
+				if out := <-results; out.err == nil && discard != nil {
+					discard(out.value)
+				}
+			}
+		}()
+	}
+
+	start(first)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	running := 1
+	var zero T
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) == 1 {
+				if call, ok := next(); ok {
+					logEntryWithRequestID(ctx).Debugf("hedging upstream request after %v without a first byte", delay)
+					start(call)
+					running++
+				}
+			}
+		case out := <-results:
+			running--
+			if out.err == nil {
+				usage.ServeHedgeAttempt(attempts[out.index])
+				finish(out.index, running)
+				return out.value, cancels[out.index], nil
+			}
+			lastErr = out.err
+			if running == 0 {
+				finish(-1, 0)
+				return zero, func() {}, lastErr
+			}
+		}
+	}
+}
+
+// awaitFirstPayload reads chunks until the first payload arrives and returns a channel
+// replaying the chunks read so far followed by the rest of the stream. It returns the
+// stream error, or errStreamCut, when the stream fails or ends before any payload.
+func awaitFirstPayload(ctx context.Context, chunks <-chan cliproxyexecutor.StreamChunk) (<-chan cliproxyexecutor.StreamChunk, error) {
+	var buffered []cliproxyexecutor.StreamChunk
+	for {
+		var chunk cliproxyexecutor.StreamChunk
+		var ok bool
+		select {
+		case <-ctx.Done():
+			go drainStream(chunks)
+			return nil, ctx.Err()
+		case chunk, ok = <-chunks:
+		}
+		if !ok {
+			if errCtx := ctx.Err(); errCtx != nil {
+				return nil, errCtx
+			}
+			return nil, errStreamCut
+		}
+		if chunk.Err != nil {
+			go drainStream(chunks)
+			return nil, chunk.Err
+		}
+		buffered = append(buffered, chunk)
+		if len(chunk.Payload) > 0 {
+			break
+		}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk, len(buffered))
+	for _, chunk := range buffered {
+		out <- chunk
+	}
+	go forwardStream(ctx, chunks, out, nil)
+	return out, nil
+}
+
+// forwardStream copies chunks to out until the stream ends, then closes out and calls
+// done when set. Once ctx is done the rest of the stream is drained instead of sent.
+func forwardStream(ctx context.Context, chunks <-chan cliproxyexecutor.StreamChunk, out chan<- cliproxyexecutor.StreamChunk, done func()) {
+	defer close(out)
+	if done != nil {
+		defer done()
+	}
+	for chunk := range chunks {
+		select {
+		case <-ctx.Done():
+			drainStream(chunks)
+			return
+		case out <- chunk:
+		}
+	}
+}
+
+// drainStream consumes chunks until the producer closes the channel, so the producer
+// goroutine can finish and release its credential.
+func drainStream(chunks <-chan cliproxyexecutor.StreamChunk) {
+	for range chunks {
+	}
+}
+
+// cancelOnClose forwards chunks and calls cancel once the stream has ended.
+func cancelOnClose(ctx context.Context, chunks <-chan cliproxyexecutor.StreamChunk, cancel context.CancelFunc) <-chan cliproxyexecutor.StreamChunk {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go forwardStream(ctx, chunks, out, cancel)
+	return out
+}
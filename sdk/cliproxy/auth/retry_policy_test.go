@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// scriptedExecutor answers the nth call with errs[n]; calls past the script succeed.
+// Calls marked in block wait for their context instead.
+type scriptedExecutor struct {
+	errs  []error
+	block map[int]bool
+
+	mu      sync.Mutex
+	calls   int
+	blocked []context.Context
+}
+
+func (e *scriptedExecutor) Identifier() string { return "retry-test" }
+
+func (e *scriptedExecutor) next(ctx context.Context) error {
+	e.mu.Lock()
+	call := e.calls
+	e.calls++
+	blocks := e.block[call]
+	if blocks {
+		e.blocked = append(e.blocked, ctx)
+	}
+	e.mu.Unlock()
+	if blocks {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if call < len(e.errs) {
+		return e.errs[call]
+	}
+	return nil
+}
+
+func (e *scriptedExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if err := e.next(ctx); err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+}
+
+func (e *scriptedExecutor) ExecuteStream(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	ch := make(chan cliproxyexecutor.StreamChunk, 1)
+	if err := e.next(ctx); err != nil {
+		ch <- cliproxyexecutor.StreamChunk{Err: err}
+	} else {
+		ch <- cliproxyexecutor.StreamChunk{Payload: []byte(auth.ID)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *scriptedExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *scriptedExecutor) CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return e.Execute(ctx, auth, req, opts)
+}
+
+func (e *scriptedExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *scriptedExecutor) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func newRetryTestManager(t *testing.T, executor *scriptedExecutor, policy internalconfig.RetryPolicy, authIDs ...string) *Manager {
+	t.Helper()
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(executor)
+	m.SetConfig(&internalconfig.Config{RetryPolicies: map[string]internalconfig.RetryPolicy{"retry-test": policy}})
+	for _, id := range authIDs {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "retry-test", Status: StatusActive}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+	return m
+}
+
+func TestManagerExecute_RetriesTransientErrorsOnSameAuth(t *testing.T) {
+	badGateway := &Error{Message: "bad gateway", HTTPStatus: http.StatusBadGateway}
+	executor := &scriptedExecutor{errs: []error{badGateway, errors.New("read: connection reset by peer")}}
+	m := newRetryTestManager(t, executor, internalconfig.RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 2, RetryOn: internalconfig.DefaultRetryOn}, "auth-1")
+
+	resp, err := m.Execute(context.Background(), []string{"retry-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+	if err != nil || string(resp.Payload) != "auth-1" {
+		t.Fatalf("Execute() = %q, %v; want auth-1", resp.Payload, err)
+	}
+	if executor.Calls() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", executor.Calls())
+	}
+}
+
+func TestManagerExecute_DoesNotRetryUnlistedErrors(t *testing.T) {
+	executor := &scriptedExecutor{errs: []error{&Error{Message: "bad request", HTTPStatus: http.StatusBadRequest}}}
+	m := newRetryTestManager(t, executor, internalconfig.RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 2, RetryOn: internalconfig.DefaultRetryOn}, "auth-1")
+
+	if _, err := m.Execute(context.Background(), []string{"retry-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{}); err == nil {
+		t.Fatalf("expected the 400 to be returned")
+	}
+	if executor.Calls() != 1 {
+		t.Fatalf("expected a single attempt, got %d", executor.Calls())
+	}
+}
+
+func TestManagerExecuteStream_RetriesBeforeFirstPayload(t *testing.T) {
+	executor := &scriptedExecutor{errs: []error{&Error{Message: "unavailable", HTTPStatus: http.StatusServiceUnavailable}}}
+	m := newRetryTestManager(t, executor, internalconfig.RetryPolicy{MaxAttempts: 2, BaseDelayMs: 1, MaxDelayMs: 2, RetryOn: internalconfig.DefaultRetryOn}, "auth-1")
+
+	chunks, err := m.ExecuteStream(context.Background(), []string{"retry-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	var got []byte
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		got = append(got, chunk.Payload...)
+	}
+	if string(got) != "auth-1" || executor.Calls() != 2 {
+		t.Fatalf("expected auth-1 after 2 attempts, got %q after %d", got, executor.Calls())
+	}
+}
+
+func TestManagerExecute_HedgesSlowFirstAttempt(t *testing.T) {
+	executor := &scriptedExecutor{block: map[int]bool{0: true}}
+	m := newRetryTestManager(t, executor, internalconfig.RetryPolicy{MaxAttempts: 1, HedgeAfterMs: 10}, "auth-1", "auth-2")
+
+	for _, stream := range []bool{false, true} {
+		executor.mu.Lock()
+		executor.calls, executor.blocked = 0, nil
+		executor.mu.Unlock()
+
+		var got []byte
+		if stream {
+			chunks, err := m.ExecuteStream(context.Background(), []string{"retry-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+			if err != nil {
+				t.Fatalf("ExecuteStream() error = %v", err)
+			}
+			for chunk := range chunks {
+				got = append(got, chunk.Payload...)
+			}
+		} else {
+			resp, err := m.Execute(context.Background(), []string{"retry-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			got = resp.Payload
+		}
+		if len(got) == 0 || executor.Calls() != 2 {
+			t.Fatalf("stream=%t: expected the hedged attempt to answer, got %q after %d calls", stream, got, executor.Calls())
+		}
+
+		executor.mu.Lock()
+		loser := executor.blocked[0]
+		executor.mu.Unlock()
+		select {
+		case <-loser.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("stream=%t: expected the slow attempt to be cancelled", stream)
+		}
+		if !usage.IsHedgeLoser(loser) {
+			t.Fatalf("stream=%t: expected the slow attempt to be marked as the hedge loser", stream)
+		}
+	}
+}
+
+type hedgeRecordPlugin chan usage.Record
+
+func (p hedgeRecordPlugin) HandleUsage(_ context.Context, record usage.Record) {
+	if record.Provider == "hedge-race" {
+		p <- record
+	}
+}
+
+func TestRunHedged_CountsOneAttemptWhenBothPublish(t *testing.T) {
+	plugin := make(hedgeRecordPlugin, 2)
+	usage.RegisterPlugin(plugin)
+	t.Cleanup(func() { usage.DefaultManager().Unregister(plugin) })
+
+	published := make(chan struct{})
+	publish := func(ctx context.Context) {
+		usage.PublishRecord(ctx, usage.Record{Provider: "hedge-race", Detail: usage.Detail{InputTokens: 5}})
+	}
+	// The slow attempt publishes its usage after the hedge started but returns only
+	// once it is cancelled, so the hedged attempt's response is the one picked although
+	// its usage is not the counted one.
+	var firstCtx, servedCtx context.Context
+	first := func(ctx context.Context) (string, error) {
+		firstCtx = ctx
+		publish(ctx)
+		close(published)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	second := func(ctx context.Context) (string, error) {
+		<-published
+		servedCtx = ctx
+		publish(ctx)
+		return "hedged", nil
+	}
+	got, cancel, err := runHedged(context.Background(), 10*time.Millisecond, first, func() (func(context.Context) (string, error), bool) {
+		return second, true
+	}, nil)
+	defer cancel()
+	if err != nil || got != "hedged" {
+		t.Fatalf("runHedged() = %q, %v", got, err)
+	}
+
+	counted := 0
+	for range 2 {
+		select {
+		case record := <-plugin:
+			if !record.Hedged {
+				counted++
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected both attempts to publish usage")
+		}
+	}
+	if counted != 1 {
+		t.Fatalf("counted %d hedge attempts as requests, want 1", counted)
+	}
+	// The served attempt wins the race, so its failures and cancellation are still
+	// reported; only the unserved attempt is treated as the loser.
+	if usage.IsHedgeLoser(servedCtx) || !usage.IsHedgeLoser(firstCtx) {
+		t.Fatalf("IsHedgeLoser(served) = %v, IsHedgeLoser(first) = %v; want false, true", usage.IsHedgeLoser(servedCtx), usage.IsHedgeLoser(firstCtx))
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: 350 * time.Millisecond}
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 350 * time.Millisecond, 10: 350 * time.Millisecond} {
+		if got := policy.delay(retry); got != want {
+			t.Fatalf("delay(%d) = %v, want %v", retry, got, want)
+		}
+	}
+	policy.jitter = 0.5
+	for range 100 {
+		if got := policy.delay(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("delay(1) with jitter = %v, want within 50ms-150ms", got)
+		}
+	}
+}
+
+func TestManagerExecuteStream_ClientCancelIsNotAFailure(t *testing.T) {
+	executor := &scriptedExecutor{block: map[int]bool{0: true}}
+	m := newRetryTestManager(t, executor, internalconfig.RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 2, RetryOn: internalconfig.DefaultRetryOn}, "cancel-auth")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opened := make(chan (<-chan cliproxyexecutor.StreamChunk), 1)
+	go func() {
+		chunks, _ := m.ExecuteStream(ctx, []string{"retry-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+		opened <- chunks
+	}()
+	for deadline := time.Now().Add(2 * time.Second); InFlightRequests("cancel-auth") != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("request never became in flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if chunks := <-opened; chunks != nil {
+		for range chunks {
+		}
+	}
+	for deadline := time.Now().Add(2 * time.Second); InFlightRequests("cancel-auth") != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("in-flight requests after cancel = %d, want 0", InFlightRequests("cancel-auth"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if executor.Calls() != 1 {
+		t.Fatalf("cancelled stream was retried: %d attempts", executor.Calls())
+	}
+	auth, _ := m.GetByID("cancel-auth")
+	if auth.Status != StatusActive || auth.LastError != nil {
+		t.Fatalf("client cancel marked the auth as failed: status=%v lastError=%v", auth.Status, auth.LastError)
+	}
+}
@@ -41,9 +41,10 @@ type modelCooldownError struct {
 	model    string
 	resetIn  time.Duration
 	provider string
+	parked   int
 }
 
-func newModelCooldownError(model, provider string, resetIn time.Duration) *modelCooldownError {
+func newModelCooldownError(model, provider string, resetIn time.Duration, parked int) *modelCooldownError {
 	if resetIn < 0 {
 		resetIn = 0
 	}
@@ -51,6 +52,7 @@ func newModelCooldownError(model, provider string, resetIn time.Duration) *model
 		model:    model,
 		provider: provider,
 		resetIn:  resetIn,
+		parked:   parked,
 	}
 }
 
@@ -59,7 +61,7 @@ func (e *modelCooldownError) Error() string {
 	if modelName == "" {
 		modelName = "requested model"
 	}
-	message := fmt.Sprintf("All credentials for model %s are cooling down", modelName)
+	message := fmt.Sprintf("All %d credentials for model %s", e.parked, modelName)
 	if e.provider != "" {
 		message = fmt.Sprintf("%s via provider %s", message, e.provider)
 	}
@@ -73,12 +75,14 @@ func (e *modelCooldownError) Error() string {
 	} else {
 		displayDuration = displayDuration.Round(time.Second)
 	}
+	message = fmt.Sprintf("%s are parked after exhausting their quota; the earliest retries in %s", message, displayDuration)
 	errorBody := map[string]any{
-		"code":          "model_cooldown",
-		"message":       message,
-		"model":         e.model,
-		"reset_time":    displayDuration.String(),
-		"reset_seconds": resetSeconds,
+		"code":               "model_cooldown",
+		"message":            message,
+		"model":              e.model,
+		"reset_time":         displayDuration.String(),
+		"reset_seconds":      resetSeconds,
+		"parked_credentials": e.parked,
 	}
 	if e.provider != "" {
 		errorBody["provider"] = e.provider
@@ -170,7 +174,7 @@ func getAvailableAuths(auths []*Auth, provider, model string, now time.Time) ([]
 			if resetIn < 0 {
 				resetIn = 0
 			}
-			return nil, newModelCooldownError(model, providerForError, resetIn)
+			return nil, newModelCooldownError(model, providerForError, resetIn, cooldownCount)
 		}
 		return nil, &Error{Code: "auth_unavailable", Message: "no auth available"}
 	}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// Routing strategy names accepted by NormalizeStrategy and NewSelector.
+const (
+	StrategyRoundRobin  = "round-robin"
+	StrategyFillFirst   = "fill-first"
+	StrategyWeighted    = "weighted"
+	StrategyLeastLoaded = "least-loaded"
+)
+
+// NormalizeStrategy maps a configured strategy name, including its short and
+// underscore spellings, to its canonical form. Empty selects round-robin.
+func NormalizeStrategy(strategy string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case "", "round-robin", "round_robin", "roundrobin", "rr":
+		return StrategyRoundRobin, true
+	case "fill-first", "fill_first", "fillfirst", "ff":
+		return StrategyFillFirst, true
+	case "weighted", "weighted-round-robin", "wrr":
+		return StrategyWeighted, true
+	case "least-loaded", "least_loaded", "leastloaded", "ll":
+		return StrategyLeastLoaded, true
+	default:
+		return "", false
+	}
+}
+
+// NewSelector returns a fresh selector for strategy. Unknown names fall back to round-robin.
+// recentTokens is used by least-loaded to break ties and may be nil.
+func NewSelector(strategy string, recentTokens func(authID string) int64) Selector {
+	normalized, _ := NormalizeStrategy(strategy)
+	switch normalized {
+	case StrategyFillFirst:
+		return &FillFirstSelector{}
+	case StrategyWeighted:
+		return &WeightedSelector{}
+	case StrategyLeastLoaded:
+		return &LeastLoadedSelector{RecentTokens: recentTokens}
+	default:
+		return &RoundRobinSelector{}
+	}
+}
+
+// ProviderSelector dispatches each pick to the selector configured for the provider
+// of the candidates, falling back to Default. Mixed-provider picks use a provider
+// selector only when every candidate belongs to that provider.
+type ProviderSelector struct {
+	Default   Selector
+	Providers map[string]Selector
+
+	fallback RoundRobinSelector
+}
+
+// Pick implements Selector.
+func (s *ProviderSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	selector := s.Default
+	if selected, ok := s.Providers[candidateProvider(provider, auths)]; ok && selected != nil {
+		selector = selected
+	}
+	if selector == nil {
+		selector = &s.fallback
+	}
+	return selector.Pick(ctx, provider, model, opts, auths)
+}
+
+// candidateProvider returns the provider shared by all auths, or "" when they differ.
+func candidateProvider(provider string, auths []*Auth) string {
+	if provider != "mixed" {
+		return strings.ToLower(strings.TrimSpace(provider))
+	}
+	shared := ""
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		p := strings.ToLower(strings.TrimSpace(auth.Provider))
+		if shared == "" {
+			shared = p
+		} else if p != shared {
+			return ""
+		}
+	}
+	return shared
+}
+
+// authWeight returns the "weight" attribute of auth. Missing, invalid and
+// non-positive weights count as 1.
+func authWeight(auth *Auth) int64 {
+	if auth == nil || auth.Attributes == nil {
+		return 1
+	}
+	parsed, err := strconv.ParseInt(strings.TrimSpace(auth.Attributes["weight"]), 10, 64)
+	if err != nil || parsed <= 0 {
+		return 1
+	}
+	return parsed
+}
+
+// WeightedSelector spreads requests across available auths in proportion to their
+// weight using smooth weighted round-robin, so the sequence is deterministic and
+// heavier credentials are interleaved rather than picked in bursts.
+type WeightedSelector struct {
+	mu      sync.Mutex
+	current map[string]map[string]int64
+	maxKeys int
+}
+
+// Pick implements Selector.
+func (s *WeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	available, err := getAvailableAuths(auths, provider, model, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	key := provider + ":" + canonicalModelKey(model)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = make(map[string]map[string]int64)
+	}
+	limit := s.maxKeys
+	if limit <= 0 {
+		limit = 4096
+	}
+	if _, ok := s.current[key]; !ok && len(s.current) >= limit {
+		s.current = make(map[string]map[string]int64)
+	}
+	previous := s.current[key]
+	// Only credentials that are still available keep their accumulated weight.
+	next := make(map[string]int64, len(available))
+	var total int64
+	var best *Auth
+	for _, candidate := range available {
+		weight := authWeight(candidate)
+		total += weight
+		next[candidate.ID] = previous[candidate.ID] + weight
+		if best == nil || next[candidate.ID] > next[best.ID] {
+			best = candidate
+		}
+	}
+	next[best.ID] -= total
+	s.current[key] = next
+	return best, nil
+}
+
+// inFlightTracker counts requests currently executing per auth ID.
+type inFlightTracker struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+var inFlight = &inFlightTracker{counts: make(map[string]int64)}
+
+// acquire marks a request on authID as started and returns the function that ends it.
+// The returned function is idempotent.
+func (t *inFlightTracker) acquire(authID string) func() {
+	t.mu.Lock()
+	t.counts[authID]++
+	t.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.counts[authID] <= 1 {
+				delete(t.counts, authID)
+				return
+			}
+			t.counts[authID]--
+		})
+	}
+}
+
+func (t *inFlightTracker) count(authID string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[authID]
+}
+
+// InFlightRequests returns the number of requests the manager is currently
+// executing with the auth identified by authID.
+func InFlightRequests(authID string) int64 {
+	return inFlight.count(authID)
+}
+
+// LeastLoadedSelector picks the available auth with the fewest in-flight requests.
+// Ties are broken by the lowest recent token usage and then in round-robin order.
+type LeastLoadedSelector struct {
+	// InFlight overrides the in-flight counter; nil uses InFlightRequests.
+	InFlight func(authID string) int64
+	// RecentTokens reports recent token usage per auth; nil skips that tie-break.
+	RecentTokens func(authID string) int64
+
+	mu      sync.Mutex
+	cursors map[string]int
+	maxKeys int
+}
+
+// Pick implements Selector.
+func (s *LeastLoadedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	available, err := getAvailableAuths(auths, provider, model, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	countInFlight := s.InFlight
+	if countInFlight == nil {
+		countInFlight = InFlightRequests
+	}
+	least := make([]*Auth, 0, len(available))
+	var leastLoad int64
+	for _, candidate := range available {
+		load := countInFlight(candidate.ID)
+		if len(least) == 0 || load < leastLoad {
+			least = append(least[:0], candidate)
+			leastLoad = load
+		} else if load == leastLoad {
+			least = append(least, candidate)
+		}
+	}
+	if len(least) > 1 && s.RecentTokens != nil {
+		tied := least
+		least = make([]*Auth, 0, len(tied))
+		var leastTokens int64
+		for _, candidate := range tied {
+			tokens := s.RecentTokens(candidate.ID)
+			if len(least) == 0 || tokens < leastTokens {
+				least = append(least[:0], candidate)
+				leastTokens = tokens
+			} else if tokens == leastTokens {
+				least = append(least, candidate)
+			}
+		}
+	}
+	if len(least) == 1 {
+		return least[0], nil
+	}
+
+	key := provider + ":" + canonicalModelKey(model)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
+	limit := s.maxKeys
+	if limit <= 0 {
+		limit = 4096
+	}
+	if _, ok := s.cursors[key]; !ok && len(s.cursors) >= limit {
+		s.cursors = make(map[string]int)
+	}
+	index := s.cursors[key]
+	if index >= 2_147_483_640 {
+		index = 0
+	}
+	s.cursors[key] = index + 1
+	return least[index%len(least)], nil
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestNormalizeStrategy(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"":             StrategyRoundRobin,
+		"round_robin":  StrategyRoundRobin,
+		"FF":           StrategyFillFirst,
+		" weighted ":   StrategyWeighted,
+		"least_loaded": StrategyLeastLoaded,
+		"least-loaded": StrategyLeastLoaded,
+	}
+	for in, want := range cases {
+		got, ok := NormalizeStrategy(in)
+		if !ok || got != want {
+			t.Fatalf("NormalizeStrategy(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := NormalizeStrategy("random"); ok {
+		t.Fatal("NormalizeStrategy(random) ok = true, want false")
+	}
+}
+
+func TestWeightedSelector_Distribution(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "free", Attributes: map[string]string{"weight": "1"}},
+		{ID: "pro", Attributes: map[string]string{"weight": "3"}},
+		{ID: "team", Attributes: map[string]string{"weight": "6"}},
+		{ID: "unset"},
+	}
+
+	const requests = 5500
+	counts := map[string]int{}
+	run := 0
+	var previous string
+	for i := 0; i < requests; i++ {
+		got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		counts[got.ID]++
+		if got.ID == previous {
+			run++
+		} else {
+			run = 1
+			previous = got.ID
+		}
+		if run > 2 {
+			t.Fatalf("Pick() #%d picked %q %d times in a row", i, got.ID, run)
+		}
+	}
+
+	want := map[string]int{"free": 500, "pro": 1500, "team": 3000, "unset": 500}
+	for id, n := range want {
+		if counts[id] != n {
+			t.Fatalf("auth %q picked %d times, want %d (counts %v)", id, counts[id], n, counts)
+		}
+	}
+}
+
+func TestWeightedSelector_SkipsUnavailable(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "a", Attributes: map[string]string{"weight": "5"}, Disabled: true},
+		{ID: "b", Attributes: map[string]string{"weight": "1"}},
+	}
+	for i := 0; i < 10; i++ {
+		got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		if got.ID != "b" {
+			t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "b")
+		}
+	}
+}
+
+// lcg is a tiny deterministic generator so the load simulation is reproducible.
+type lcg uint64
+
+func (g *lcg) next(n int) int {
+	*g = *g*6364136223846793005 + 1442695040888963407
+	return int(uint64(*g)>>33) % n
+}
+
+func TestLeastLoadedSelector_SimulatedLoad(t *testing.T) {
+	t.Parallel()
+
+	inFlight := map[string]int64{}
+	selector := &LeastLoadedSelector{InFlight: func(id string) int64 { return inFlight[id] }}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "slow"}}
+
+	type pending struct {
+		id     string
+		doneAt int
+	}
+	var active []pending
+	counts := map[string]int{}
+	rng := lcg(42)
+
+	const ticks = 4000
+	for tick := 0; tick < ticks; tick++ {
+		remaining := active[:0]
+		for _, p := range active {
+			if p.doneAt <= tick {
+				inFlight[p.id]--
+				continue
+			}
+			remaining = append(remaining, p)
+		}
+		active = remaining
+
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() tick %d error = %v", tick, err)
+		}
+		for _, candidate := range auths {
+			if inFlight[candidate.ID] < inFlight[got.ID] {
+				t.Fatalf("tick %d picked %q with %d in flight while %q had %d", tick, got.ID, inFlight[got.ID], candidate.ID, inFlight[candidate.ID])
+			}
+		}
+		duration := 1 + rng.next(8)
+		if got.ID == "slow" {
+			duration *= 4
+		}
+		inFlight[got.ID]++
+		counts[got.ID]++
+		active = append(active, pending{id: got.ID, doneAt: tick + duration})
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if counts["slow"]*2 > counts[id] {
+			t.Fatalf("slow credential served %d requests, want well below %q's %d (counts %v)", counts["slow"], id, counts[id], counts)
+		}
+	}
+	total := counts["a"] + counts["b"] + counts["c"] + counts["slow"]
+	if total != ticks {
+		t.Fatalf("total picks = %d, want %d", total, ticks)
+	}
+}
+
+func TestLeastLoadedSelector_TieBreaksByRecentTokens(t *testing.T) {
+	t.Parallel()
+
+	tokens := map[string]int64{"a": 900, "b": 100, "c": 500}
+	selector := &LeastLoadedSelector{
+		InFlight:     func(string) int64 { return 0 },
+		RecentTokens: func(id string) int64 { return tokens[id] },
+	}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	for i := 0; i < 3000; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		for id, used := range tokens {
+			if used < tokens[got.ID] {
+				t.Fatalf("Pick() #%d picked %q with %d tokens while %q had %d", i, got.ID, tokens[got.ID], id, used)
+			}
+		}
+		tokens[got.ID] += 200
+	}
+	if spread := max(tokens["a"], tokens["b"], tokens["c"]) - min(tokens["a"], tokens["b"], tokens["c"]); spread > 200 {
+		t.Fatalf("token spread = %d, want <= 200 (tokens %v)", spread, tokens)
+	}
+}
+
+func TestLeastLoadedSelector_RotatesFullTies(t *testing.T) {
+	t.Parallel()
+
+	selector := &LeastLoadedSelector{InFlight: func(string) int64 { return 0 }}
+	auths := []*Auth{{ID: "b"}, {ID: "a"}}
+	want := []string{"a", "b", "a", "b"}
+	for i, id := range want {
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != id {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, id)
+		}
+	}
+}
+
+func TestProviderSelector_UsesProviderStrategy(t *testing.T) {
+	t.Parallel()
+
+	selector := &ProviderSelector{
+		Default:   &RoundRobinSelector{},
+		Providers: map[string]Selector{"kiro": &FillFirstSelector{}},
+	}
+	kiro := []*Auth{{ID: "k2", Provider: "kiro"}, {ID: "k1", Provider: "kiro"}}
+	for i := 0; i < 3; i++ {
+		got, err := selector.Pick(context.Background(), "mixed", "", cliproxyexecutor.Options{}, kiro)
+		if err != nil {
+			t.Fatalf("Pick() error = %v", err)
+		}
+		if got.ID != "k1" {
+			t.Fatalf("Pick() #%d auth.ID = %q, want fill-first %q", i, got.ID, "k1")
+		}
+	}
+
+	mixed := []*Auth{{ID: "k1", Provider: "kiro"}, {ID: "g1", Provider: "gemini"}}
+	first, _ := selector.Pick(context.Background(), "mixed", "", cliproxyexecutor.Options{}, mixed)
+	second, _ := selector.Pick(context.Background(), "mixed", "", cliproxyexecutor.Options{}, mixed)
+	if first == nil || second == nil || first.ID == second.ID {
+		t.Fatalf("mixed providers should use the round-robin default, got %v then %v", first, second)
+	}
+}
+
+// streamingExecutor emits chunks until its stream is closed by the test, ignoring
+// cancellation the way a stalled upstream would.
+type streamingExecutor struct {
+	stop chan struct{}
+}
+
+func (e *streamingExecutor) Identifier() string { return "stream-test" }
+
+func (e *streamingExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: []byte("{}")}, nil
+}
+
+func (e *streamingExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	chunks := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for {
+			select {
+			case <-e.stop:
+				return
+			case chunks <- cliproxyexecutor.StreamChunk{Payload: []byte("data")}:
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+func (e *streamingExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *streamingExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *streamingExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestManagerExecuteStream_ReleasesInFlightOnAbort(t *testing.T) {
+	executor := &streamingExecutor{stop: make(chan struct{})}
+	defer close(executor.stop)
+	m := NewManager(nil, &LeastLoadedSelector{}, nil)
+	m.RegisterExecutor(executor)
+	const authID = "stream-abort-auth"
+	if _, err := m.Register(context.Background(), &Auth{ID: authID, Provider: "stream-test"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if _, err := m.Execute(context.Background(), []string{"stream-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if n := InFlightRequests(authID); n != 0 {
+		t.Fatalf("in flight after Execute = %d, want 0", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, err := m.ExecuteStream(ctx, []string{"stream-test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	<-chunks
+	if n := InFlightRequests(authID); n != 1 {
+		t.Fatalf("in flight while streaming = %d, want 1", n)
+	}
+
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for InFlightRequests(authID) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("in flight after abort = %d, want 0", InFlightRequests(authID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
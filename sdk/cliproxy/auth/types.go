@@ -54,6 +54,10 @@ type Auth struct {
 	LastRefreshedAt time.Time `json:"last_refreshed_at"`
 	// NextRefreshAfter is the earliest time a refresh should retrigger.
 	NextRefreshAfter time.Time `json:"next_refresh_after"`
+	// RefreshFailures counts consecutive failed refresh attempts.
+	RefreshFailures int `json:"refresh_failures,omitempty"`
+	// RefreshError stores the error of the last failed refresh, cleared on success.
+	RefreshError *Error `json:"refresh_error,omitempty"`
 	// NextRetryAfter is the earliest time a retry should retrigger.
 	NextRetryAfter time.Time `json:"next_retry_after"`
 	// ModelStates tracks per-model runtime availability data.
@@ -0,0 +1,49 @@
+package cliproxy
+
+import (
+	"strings"
+
+	internalusage "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// routingStrategies returns the normalized default strategy and per-provider
+// overrides configured in cfg. Unknown names fall back to round-robin.
+func routingStrategies(cfg *config.Config) (string, map[string]string) {
+	if cfg == nil {
+		return coreauth.StrategyRoundRobin, nil
+	}
+	normalize := func(strategy string) string {
+		if normalized, ok := coreauth.NormalizeStrategy(strategy); ok {
+			return normalized
+		}
+		return coreauth.StrategyRoundRobin
+	}
+	var providers map[string]string
+	for provider, strategy := range cfg.Routing.ProviderStrategies {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if key == "" {
+			continue
+		}
+		if providers == nil {
+			providers = make(map[string]string, len(cfg.Routing.ProviderStrategies))
+		}
+		providers[key] = normalize(strategy)
+	}
+	return normalize(cfg.Routing.Strategy), providers
+}
+
+// newSelector builds the credential selector for the routing strategies in cfg.
+func newSelector(cfg *config.Config) coreauth.Selector {
+	strategy, providers := routingStrategies(cfg)
+	selector := coreauth.NewSelector(strategy, internalusage.RecentAuthTokens)
+	if len(providers) == 0 {
+		return selector
+	}
+	perProvider := make(map[string]coreauth.Selector, len(providers))
+	for provider, providerStrategy := range providers {
+		perProvider[provider] = coreauth.NewSelector(providerStrategy, internalusage.RecentAuthTokens)
+	}
+	return &coreauth.ProviderSelector{Default: selector, Providers: perProvider}
+}
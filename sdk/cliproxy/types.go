@@ -5,6 +5,7 @@ package cliproxy
 
 import (
 	"context"
+	"errors"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -86,6 +87,8 @@ type WatcherWrapper struct {
 	stop  func() error
 
 	setConfig             func(cfg *config.Config)
+	reloadConfig          func() error
+	applyConfig           func(cfg *config.Config)
 	snapshotAuths         func() []*coreauth.Auth
 	setUpdateQueue        func(queue chan<- watcher.AuthUpdate)
 	dispatchRuntimeUpdate func(update watcher.AuthUpdate) bool
@@ -116,6 +119,24 @@ func (w *WatcherWrapper) SetConfig(cfg *config.Config) {
 	w.setConfig(cfg)
 }
 
+// ReloadConfig re-reads and validates the config file, applying it only when it is
+// valid.
+func (w *WatcherWrapper) ReloadConfig() error {
+	if w == nil || w.reloadConfig == nil {
+		return errors.New("config reload is not supported by this watcher")
+	}
+	return w.reloadConfig()
+}
+
+// ApplyConfig swaps in an already validated config without reading the config file.
+func (w *WatcherWrapper) ApplyConfig(cfg *config.Config) error {
+	if w == nil || w.applyConfig == nil {
+		return errors.New("applying a config is not supported by this watcher")
+	}
+	w.applyConfig(cfg)
+	return nil
+}
+
 // DispatchRuntimeAuthUpdate forwards runtime auth updates (e.g., websocket providers)
 // into the watcher-managed auth update queue when available.
 // Returns true if the update was enqueued successfully.
@@ -3,6 +3,7 @@ package usage
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -17,8 +18,156 @@ type Record struct {
 	AuthIndex   string
 	Source      string
 	RequestedAt time.Time
-	Failed      bool
-	Detail      Detail
+	// Latency is the elapsed time between request dispatch and usage publication.
+	Latency time.Duration
+	// Streamed reports whether the request was served as a streaming response.
+	Streamed bool
+	Failed   bool
+	// Hedged marks a record from the losing attempt of a hedged request. Its tokens were
+	// consumed upstream, but the request itself is already counted by the winner.
+	Hedged bool
+	// Cached marks a request answered from the response cache without an upstream call.
+	Cached bool
+	// Cancelled marks a request the client abandoned before it completed. Its Detail
+	// holds whatever usage the upstream had reported by then.
+	Cancelled bool
+	// TruncatedMessages counts the conversation messages dropped to fit the request into
+	// the model's context window.
+	TruncatedMessages int
+	Detail            Detail
+}
+
+type streamingContextKey struct{}
+
+// WithStreaming marks ctx as belonging to a streaming request so emitted records carry Streamed=true.
+func WithStreaming(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, streamingContextKey{}, true)
+}
+
+// IsStreaming reports whether ctx was marked by WithStreaming.
+func IsStreaming(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	streamed, _ := ctx.Value(streamingContextKey{}).(bool)
+	return streamed
+}
+
+type truncatedMessagesContextKey struct{}
+
+// WithTruncatedMessages records on ctx that n conversation messages were dropped from
+// the request, so that emitted records carry TruncatedMessages=n.
+func WithTruncatedMessages(ctx context.Context, n int) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, truncatedMessagesContextKey{}, n)
+}
+
+// TruncatedMessages returns the count recorded by WithTruncatedMessages, or 0.
+func TruncatedMessages(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	n, _ := ctx.Value(truncatedMessagesContextKey{}).(int)
+	return n
+}
+
+type suppressedContextKey struct{}
+
+// WithoutUsage marks ctx as belonging to an internal request, such as a readiness
+// probe, whose usage must not be recorded. Publish drops records published with it.
+func WithoutUsage(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, suppressedContextKey{}, true)
+}
+
+// IsUsageSuppressed reports whether ctx was marked by WithoutUsage.
+func IsUsageSuppressed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	suppressed, _ := ctx.Value(suppressedContextKey{}).(bool)
+	return suppressed
+}
+
+type hedgeContextKey struct{}
+
+// HedgeRace decides which attempt of a hedged request counts as the request. The
+// first attempt to claim the race wins, either when its successful usage is published
+// or when its response is picked; every other attempt is the loser. The attempt whose
+// response is served always ends up the winner, see ServeHedgeAttempt.
+type HedgeRace struct {
+	winner  atomic.Int32
+	started atomic.Int32
+}
+
+type hedgeAttempt struct {
+	race  *HedgeRace
+	index int32
+}
+
+// NewHedgeRace returns a race without a winner.
+func NewHedgeRace() *HedgeRace {
+	race := &HedgeRace{}
+	race.winner.Store(-1)
+	return race
+}
+
+// WithHedgeAttempt returns a derived context for a new attempt of race. It must be
+// called before the attempt is dispatched, so that every record the attempt publishes
+// is decided by the race.
+func WithHedgeAttempt(ctx context.Context, race *HedgeRace) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attempt := &hedgeAttempt{race: race, index: race.started.Add(1) - 1}
+	return context.WithValue(ctx, hedgeContextKey{}, attempt)
+}
+
+// ClaimHedgeWin makes the attempt of ctx the winner of its race unless another attempt
+// already won, and reports whether the attempt is the winner. A ctx outside a hedged
+// request always wins.
+func ClaimHedgeWin(ctx context.Context) bool {
+	attempt := hedgeAttemptFrom(ctx)
+	if attempt == nil {
+		return true
+	}
+	return attempt.race.winner.CompareAndSwap(-1, attempt.index) || attempt.race.winner.Load() == attempt.index
+}
+
+// ServeHedgeAttempt makes the attempt of ctx the winner of its race because its
+// response is the one served, even if the other attempt claimed the race first by
+// publishing its usage. Records already published keep the way they were counted, so
+// the request is still counted once, but from then on only the other attempt is the
+// loser whose failures and cancellation are not reported.
+func ServeHedgeAttempt(ctx context.Context) {
+	if attempt := hedgeAttemptFrom(ctx); attempt != nil {
+		attempt.race.winner.Store(attempt.index)
+	}
+}
+
+// IsHedgeLoser reports whether another attempt already won the race of ctx.
+func IsHedgeLoser(ctx context.Context) bool {
+	attempt := hedgeAttemptFrom(ctx)
+	if attempt == nil {
+		return false
+	}
+	winner := attempt.race.winner.Load()
+	return winner >= 0 && winner != attempt.index
+}
+
+func hedgeAttemptFrom(ctx context.Context) *hedgeAttempt {
+	if ctx == nil {
+		return nil
+	}
+	attempt, _ := ctx.Value(hedgeContextKey{}).(*hedgeAttempt)
+	return attempt
 }
 
 // Detail holds the token usage breakdown.
@@ -28,6 +177,15 @@ type Detail struct {
 	ReasoningTokens int64
 	CachedTokens    int64
 	TotalTokens     int64
+	// CacheCreationTokens counts prompt tokens written to the upstream's cache, for
+	// providers that report cache writes.
+	CacheCreationTokens int64
+	// CacheSimulated reports that the provider's cache counts are synthesized by the
+	// proxy rather than reported by the upstream.
+	CacheSimulated bool
+	// Estimated reports that input or output token counts were estimated by the proxy
+	// because the upstream did not report them.
+	Estimated bool
 }
 
 // Plugin consumes usage records emitted by the proxy runtime.
@@ -50,6 +208,8 @@ type Manager struct {
 	cond   *sync.Cond
 	queue  []queueItem
 	closed bool
+	// done is closed once the dispatcher has delivered the queue after Stop.
+	done chan struct{}
 
 	pluginsMu sync.RWMutex
 	plugins   []Plugin
@@ -73,11 +233,19 @@ func (m *Manager) Start(ctx context.Context) {
 		}
 		var workerCtx context.Context
 		workerCtx, m.cancel = context.WithCancel(ctx)
-		go m.run(workerCtx)
+		done := make(chan struct{})
+		m.mu.Lock()
+		m.done = done
+		m.mu.Unlock()
+		go func() {
+			defer close(done)
+			m.run(workerCtx)
+		}()
 	})
 }
 
-// Stop stops the dispatcher and drains the queue.
+// Stop stops the dispatcher and waits until the queued records have been delivered
+// to the plugins.
 func (m *Manager) Stop() {
 	if m == nil {
 		return
@@ -88,8 +256,12 @@ func (m *Manager) Stop() {
 		}
 		m.mu.Lock()
 		m.closed = true
+		done := m.done
 		m.mu.Unlock()
 		m.cond.Broadcast()
+		if done != nil {
+			<-done
+		}
 	})
 }
 
@@ -103,14 +275,38 @@ func (m *Manager) Register(plugin Plugin) {
 	m.pluginsMu.Unlock()
 }
 
+// Unregister removes a plugin added by Register. Records already queued may still be
+// delivered to it.
+func (m *Manager) Unregister(plugin Plugin) {
+	if m == nil || plugin == nil {
+		return
+	}
+	m.pluginsMu.Lock()
+	defer m.pluginsMu.Unlock()
+	for i, registered := range m.plugins {
+		if registered == plugin {
+			m.plugins = append(m.plugins[:i:i], m.plugins[i+1:]...)
+			return
+		}
+	}
+}
+
 // Publish enqueues a usage record for processing. If no plugin is registered
-// the record will be discarded downstream.
+// the record will be discarded downstream; records of a ctx marked by WithoutUsage
+// are discarded right away. A successful record of a hedged attempt claims its race;
+// records of attempts that lost it carry Hedged=true.
 func (m *Manager) Publish(ctx context.Context, record Record) {
-	if m == nil {
+	if m == nil || IsUsageSuppressed(ctx) {
 		return
 	}
 	// ensure worker is running even if Start was not called explicitly
 	m.Start(context.Background())
+	if record.Failed && IsHedgeLoser(ctx) || !record.Failed && !ClaimHedgeWin(ctx) {
+		record.Hedged = true
+	}
+	if record.TruncatedMessages == 0 {
+		record.TruncatedMessages = TruncatedMessages(ctx)
+	}
 	m.mu.Lock()
 	if m.closed {
 		m.mu.Unlock()
@@ -171,6 +367,9 @@ func DefaultManager() *Manager { return defaultManager }
 // RegisterPlugin registers a plugin on the default manager.
 func RegisterPlugin(plugin Plugin) { DefaultManager().Register(plugin) }
 
+// UnregisterPlugin removes a plugin from the default manager.
+func UnregisterPlugin(plugin Plugin) { DefaultManager().Unregister(plugin) }
+
 // PublishRecord publishes a record using the default manager.
 func PublishRecord(ctx context.Context, record Record) { DefaultManager().Publish(ctx, record) }
 
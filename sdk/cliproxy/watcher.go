@@ -24,6 +24,12 @@ func defaultWatcherFactory(configPath, authDir string, reload func(*config.Confi
 		setConfig: func(cfg *config.Config) {
 			w.SetConfig(cfg)
 		},
+		reloadConfig: func() error {
+			return w.ReloadConfig()
+		},
+		applyConfig: func(cfg *config.Config) {
+			w.ApplyConfig(cfg)
+		},
 		snapshotAuths: func() []*coreauth.Auth { return w.SnapshotCoreAuths() },
 		setUpdateQueue: func(queue chan<- watcher.AuthUpdate) {
 			w.SetAuthUpdateQueue(queue)
@@ -11,7 +11,20 @@ type SDKConfig = internalconfig.SDKConfig
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type StreamingProviderConfig = internalconfig.StreamingProviderConfig
+type ModelMapping = internalconfig.ModelMapping
+type ClientKey = internalconfig.ClientKey
+type FailoverConfig = internalconfig.FailoverConfig
+type ResponseCacheConfig = internalconfig.ResponseCacheConfig
+type RequestLimitsConfig = internalconfig.RequestLimitsConfig
+type OutputTokenLimits = internalconfig.OutputTokenLimits
+type HealthConfig = internalconfig.HealthConfig
+type ProviderHealthConfig = internalconfig.ProviderHealthConfig
+type ContextManagementConfig = internalconfig.ContextManagementConfig
 type TLSConfig = internalconfig.TLSConfig
+type MetricsConfig = internalconfig.MetricsConfig
+type AccessLogConfig = internalconfig.AccessLogConfig
+type CaptureConfig = internalconfig.CaptureConfig
 type RemoteManagement = internalconfig.RemoteManagement
 type AmpCode = internalconfig.AmpCode
 type OAuthModelAlias = internalconfig.OAuthModelAlias
@@ -19,6 +32,7 @@ type PayloadConfig = internalconfig.PayloadConfig
 type PayloadRule = internalconfig.PayloadRule
 type PayloadFilterRule = internalconfig.PayloadFilterRule
 type PayloadModelRule = internalconfig.PayloadModelRule
+type SystemPromptRule = internalconfig.SystemPromptRule
 
 type GeminiKey = internalconfig.GeminiKey
 type CodexKey = internalconfig.CodexKey
@@ -33,6 +47,7 @@ type TLS = internalconfig.TLSConfig
 
 const (
 	DefaultPanelGitHubRepository = internalconfig.DefaultPanelGitHubRepository
+	TruncateOldestFirst          = internalconfig.TruncateOldestFirst
 )
 
 func LoadConfig(configFile string) (*Config, error) { return internalconfig.LoadConfig(configFile) }